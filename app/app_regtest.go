@@ -304,7 +304,7 @@ func New(
 		claimmoduletypes.StoreKey,
 		// this line is used by starport scaffolding # stargate/app/storeKey
 	)
-	tkeys := sdk.NewTransientStoreKeys(paramstypes.TStoreKey)
+	tkeys := sdk.NewTransientStoreKeys(paramstypes.TStoreKey, arkeomoduletypes.TStoreKey)
 	memKeys := sdk.NewMemoryStoreKeys(capabilitytypes.MemStoreKey)
 
 	app := &App{
@@ -441,6 +441,8 @@ func New(
 		keys[claimmoduletypes.StoreKey],
 		app.AccountKeeper,
 		app.BankKeeper,
+		&stakingKeeper,
+		app.DistrKeeper,
 		keys[claimmoduletypes.MemStoreKey],
 		app.GetSubspace(claimmoduletypes.ModuleName),
 	)
@@ -530,6 +532,7 @@ func New(
 		appCodec,
 		keys[arkeomoduletypes.StoreKey],
 		keys[arkeomoduletypes.MemStoreKey],
+		tkeys[arkeomoduletypes.TStoreKey],
 		app.GetSubspace(arkeomoduletypes.ModuleName),
 		app.BankKeeper,
 		app.AccountKeeper,
@@ -680,6 +683,7 @@ func New(
 
 	app.configurator = module.NewConfigurator(app.appCodec, app.MsgServiceRouter(), app.GRPCQueryRouter())
 	app.mm.RegisterServices(app.configurator)
+	app.setupUpgradeHandlers()
 
 	// create the simulation manager and define the order of the modules for deterministic simulations
 	app.sm = module.NewSimulationManager(
@@ -25,6 +25,7 @@ func newClaimCmd() *cobra.Command {
 	claimCmd.Flags().String("client-pubkey", "", "client pubkey")
 	claimCmd.Flags().String("service", "", "service name")
 	claimCmd.Flags().Int64("nonce", 0, "requests claimed (must increment each call)")
+	claimCmd.Flags().Bool("signature-domain", false, "sign the claim over the chain id and provider pubkey")
 	return claimCmd
 }
 
@@ -78,7 +79,12 @@ func runClaimCmd(cmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 
-	signBytes := types.GetBytesToSign(contract.Id, nonce)
+	signatureDomain, err := cmd.Flags().GetBool("signature-domain")
+	if err != nil {
+		return err
+	}
+	domain := types.SigningDomain{ChainId: clientCtx.ChainID, Provider: contract.Provider, Required: signatureDomain}
+	signBytes := domain.BytesToSign(contract.Id, nonce)
 	signature, _, err := clientCtx.Keyring.Sign(key.Name, signBytes)
 	if err != nil {
 		return errors.Wrapf(err, "error signing")
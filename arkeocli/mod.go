@@ -32,6 +32,7 @@ func newModProviderCmd() *cobra.Command {
 	modProviderCmd.Flags().Uint64("settlement-duration", 0, "settlement duration (in blocks)")
 	modProviderCmd.Flags().Uint64("subscription-rate", 0, "rate for subscription contracts")
 	modProviderCmd.Flags().Uint64("pay-as-you-go-rate", 0, "rate for pay-as-you-go contracts")
+	modProviderCmd.Flags().String("payout-splits", "", "split income across addresses as address:weight pairs in basis points (e.g. addr1:7000,addr2:3000)")
 	return modProviderCmd
 }
 
@@ -165,6 +166,12 @@ func runModProviderCmd(cmd *cobra.Command, args []string) (err error) {
 
 	status := types.ProviderStatus(types.ProviderStatus_value[strings.ToUpper(argStatus)])
 
+	argPayoutSplits, _ := cmd.Flags().GetString("payout-splits")
+	payoutSplits, err := types.ParsePayoutSplits(argPayoutSplits)
+	if err != nil {
+		return err
+	}
+
 	msg := types.NewMsgModProvider(
 		clientCtx.GetFromAddress(),
 		pubkey,
@@ -178,6 +185,7 @@ func runModProviderCmd(cmd *cobra.Command, args []string) (err error) {
 		pRate,
 		int64(argSettlementDuration),
 	)
+	msg.PayoutSplits = payoutSplits
 	if err := msg.ValidateBasic(); err != nil {
 		return err
 	}
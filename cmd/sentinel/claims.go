@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/sentinel"
+)
+
+const claimsUsage = "usage: sentinel claims <export|import|list> [flags]"
+
+// claims manages the claim store of a stopped sentinel: export its claims to
+// a file, import them on another host, or list them
+func claims(args []string) error {
+	if len(args) == 0 {
+		return errors.New(claimsUsage)
+	}
+	switch args[0] {
+	case "export":
+		return exportClaims(args[1:])
+	case "import":
+		return importClaims(args[1:])
+	case "list":
+		return listClaims(args[1:])
+	}
+	return fmt.Errorf("unknown claims command %q, %s", args[0], claimsUsage)
+}
+
+// claimStoreFlag defaults to the claim store the sentinel is configured with
+func claimStoreFlag(flags *flag.FlagSet) *string {
+	return flags.String("store", os.Getenv("CLAIM_STORE_LOCATION"), "claim store directory of the sentinel (default: $CLAIM_STORE_LOCATION)")
+}
+
+func exportClaims(args []string) error {
+	flags := flag.NewFlagSet("claims export", flag.ContinueOnError)
+	location := claimStoreFlag(flags)
+	output := flags.String("output", "", "file the claims are written to (default: stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := sentinel.OpenClaimStore(*location)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	export := store.Export(time.Now())
+
+	var w io.Writer = os.Stdout
+	if len(*output) > 0 {
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return fmt.Errorf("fail to create export file: %w", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := sentinel.WriteClaimExport(w, export); err != nil {
+		return fmt.Errorf("fail to write claims: %w", err)
+	}
+	if len(*output) > 0 {
+		fmt.Printf("exported %d claims to %s\n", len(export.Claims), *output)
+	}
+	return nil
+}
+
+func importClaims(args []string) error {
+	flags := flag.NewFlagSet("claims import", flag.ContinueOnError)
+	location := claimStoreFlag(flags)
+	input := flags.String("input", "", "file the claims are read from")
+	force := flags.Bool("force", false, "import claims with a lower nonce than the stored ones")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(*input) == 0 {
+		flags.Usage()
+		return fmt.Errorf("--input is required")
+	}
+
+	file, err := os.Open(*input)
+	if err != nil {
+		return fmt.Errorf("fail to open export file: %w", err)
+	}
+	defer file.Close()
+	export, err := sentinel.ReadClaimExport(file)
+	if err != nil {
+		return err
+	}
+
+	// a new host has no claim store yet
+	if len(*location) > 0 {
+		if err := os.MkdirAll(*location, 0o755); err != nil {
+			return fmt.Errorf("fail to create claim store folder: %w", err)
+		}
+	}
+	store, err := sentinel.OpenClaimStore(*location)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	result, err := store.Import(export.Claims, *force)
+	if err != nil {
+		return fmt.Errorf("fail to import claims, use --force to import them anyway: %w", err)
+	}
+	fmt.Printf("imported %d claims, %d unchanged\n", result.Imported, result.Unchanged)
+	for _, contractId := range result.Lowered {
+		fmt.Printf("warning: lowered the nonce of contract %d\n", contractId)
+	}
+	return nil
+}
+
+func listClaims(args []string) error {
+	flags := flag.NewFlagSet("claims list", flag.ContinueOnError)
+	location := claimStoreFlag(flags)
+	contractId := flags.Uint64("contract-id", 0, "only list the claim of this contract")
+	claimed := flags.String("claimed", "", "only list claimed (true) or unclaimed (false) claims")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	filter := sentinel.ClaimFilter{ContractId: *contractId}
+	if len(*claimed) > 0 {
+		value, err := strconv.ParseBool(*claimed)
+		if err != nil {
+			return fmt.Errorf("bad --claimed: %w", err)
+		}
+		filter.Claimed = &value
+	}
+
+	store, err := sentinel.OpenClaimStore(*location)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(writer, "CONTRACT\tSPENDER\tNONCE\tCLAIMED\tSIGNATURE")
+	for _, claim := range store.Export(time.Now()).Claims {
+		if !filter.Match(claim) {
+			continue
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%d\t%t\t%s\n", claim.ContractId, claim.Spender, claim.Nonce, claim.Claimed, claim.Signature)
+	}
+	return writer.Flush()
+}
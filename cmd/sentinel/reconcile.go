@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/sentinel"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+)
+
+// reconcileTimeout bounds the queries of the contracts of every claim
+const reconcileTimeout = 5 * time.Minute
+
+// reconcile compares the claims of a stopped sentinel with the contracts on
+// chain, and prints the claims that differ. With --fix, the recoverable claims
+// are submitted again and the orphaned ones pruned.
+func reconcile(args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	location := claimStoreFlag(flags)
+	target := flags.String("grpc", os.Getenv("SOURCE_CHAIN_GRPC"), "grpc address of the arkeo chain (default: $SOURCE_CHAIN_GRPC)")
+	fix := flags.Bool("fix", false, "submit the recoverable claims again, signed with the claim key of the sentinel configuration, and prune the orphaned ones")
+	asJSON := flags.Bool("json", false, "print the report as json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(*target) == 0 {
+		flags.Usage()
+		return fmt.Errorf("--grpc is required")
+	}
+
+	store, err := sentinel.OpenClaimStore(*location)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	fetcher, err := sentinel.NewContractFetcher(*target)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+	report, err := sentinel.Reconcile(ctx, store.List(), fetcher)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else if err := printReconcileReport(report); err != nil {
+		return err
+	}
+	if !*fix {
+		return nil
+	}
+
+	chain, err := sentinel.NewChainClient(conf.NewConfiguration())
+	if err != nil {
+		return fmt.Errorf("fail to create chain client: %w", err)
+	}
+	result, err := sentinel.FixReconcile(report, store, chain)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("resubmitted %d claims, %d failed, pruned %d orphaned claims\n", result.Resubmitted, result.Failed, result.Pruned)
+	return nil
+}
+
+func printReconcileReport(report sentinel.ReconcileReport) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(writer, "CONTRACT\tKIND\tNONCE\tCHAIN NONCE\tCLAIMED\tDEPOSIT\tPAID\tINCOME")
+	for _, entry := range report.Entries {
+		fmt.Fprintf(writer, "%d\t%s\t%d\t%d\t%t\t%s\t%s\t%s\n", entry.Claim.ContractId, entry.Kind, entry.Claim.Nonce, entry.ChainNonce, entry.Claim.Claimed, entry.Deposit, entry.Paid, entry.Income)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("checked %d claims, %d in sync: %d unclaimed, %d rejected, %d missing on chain, %d orphaned\n",
+		report.Checked, report.InSync,
+		report.Count(sentinel.DiscrepancyUnclaimed), report.Count(sentinel.DiscrepancyRejected),
+		report.Count(sentinel.DiscrepancyMissing), report.Count(sentinel.DiscrepancyOrphaned))
+	return nil
+}
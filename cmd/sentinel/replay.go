@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/arkeonetwork/arkeo/sentinel"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+)
+
+// replay re-executes a request recorded in the audit log, either through the
+// handler chain of a dry run proxy or against another sentinel, and prints
+// how the outcome differs from the recorded one
+func replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	auditLog := flags.String("audit-log", "", "audit log file of the sentinel that served the request")
+	requestId := flags.String("request-id", "", "id of the request to replay")
+	against := flags.String("against", "", "base url of a sentinel to replay against (default: local dry run)")
+	bodyFile := flags.String("body", "", "file with the request body, if it was retained")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if len(*auditLog) == 0 || len(*requestId) == 0 {
+		flags.Usage()
+		return fmt.Errorf("--audit-log and --request-id are required")
+	}
+
+	record, err := sentinel.ReadAuditRecord(*auditLog, *requestId)
+	if err != nil {
+		return err
+	}
+	var body []byte
+	if len(*bodyFile) > 0 {
+		body, err = os.ReadFile(*bodyFile)
+		if err != nil {
+			return fmt.Errorf("fail to read body: %w", err)
+		}
+	} else if record.BodySize > 0 {
+		fmt.Printf("warning: request had a %d bytes body, replaying without it\n", record.BodySize)
+	}
+
+	var replayed sentinel.AuditRecord
+	if len(*against) > 0 {
+		replayed, err = sentinel.ReplayAgainst(*against, record, body)
+	} else {
+		config := conf.NewConfiguration()
+		// never touch the stores of a running sentinel
+		config.ClaimStoreLocation = ""
+		config.ContractConfigStoreLocation = ""
+		config.AuditLogLocation = ""
+		config.AccessLogLocation = ""
+		proxy := sentinel.NewProxy(config)
+		proxy.DryRun = true
+		replayed, err = proxy.ReplayDryRun(record, body)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to replay request: %w", err)
+	}
+
+	fmt.Printf("request:  %s %s?%s (%s)\n", record.Method, record.Path, record.Query, record.Time)
+	fmt.Printf("recorded: tier=%q code=%d steps=%v\n", record.Tier, record.Code, record.Steps)
+	fmt.Printf("replayed: tier=%q code=%d steps=%v\n", replayed.Tier, replayed.Code, replayed.Steps)
+	diffs := sentinel.DiffAuditRecords(record, replayed)
+	if len(diffs) == 0 {
+		fmt.Println("no difference")
+		return nil
+	}
+	for _, diff := range diffs {
+		fmt.Println(diff)
+	}
+	return nil
+}
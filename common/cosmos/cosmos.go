@@ -30,6 +30,7 @@ var (
 	ZeroInt                      = sdk.ZeroInt
 	ZeroUint                     = sdkmath.ZeroUint
 	ZeroDec                      = sdk.ZeroDec
+	MinInt                       = sdk.MinInt
 	OneUint                      = sdkmath.OneUint
 	NewInt64Coin                 = sdk.NewInt64Coin
 	NewCoin                      = sdk.NewCoin
@@ -140,3 +140,22 @@ func (c Service) Equals(c2 Service) bool {
 func (c Service) IsEmpty() bool {
 	return strings.TrimSpace(c.String()) == ""
 }
+
+// Contains checks whether the given service is one of the services
+func (s Services) Contains(service Service) bool {
+	for _, item := range s {
+		if item.Equals(service) {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings returns the names of the services
+func (s Services) Strings() []string {
+	names := make([]string, len(s))
+	for i, item := range s {
+		names[i] = item.String()
+	}
+	return names
+}
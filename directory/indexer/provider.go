@@ -59,7 +59,7 @@ func (s *Service) handleModProviderEvent(ctx context.Context, evt atypes.EventMo
 		log.Errorf("updating provider metadata for provider %s failed due to bad MetadataURI %s", provider.Pubkey, provider.MetadataURI)
 		return nil
 	}
-	providerMetadata, err := utils.DownloadProviderMetadata(provider.MetadataURI, 5, 1e6)
+	providerMetadata, err := utils.DownloadProviderMetadata(provider.MetadataURI, evt.MetadataHash, 5, 1e6)
 	if err != nil {
 		log.WithError(err).Errorf("updating provider metadata for provider %s failed", provider.Pubkey)
 		return nil
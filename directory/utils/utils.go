@@ -13,11 +13,16 @@ import (
 
 	"github.com/arkeonetwork/arkeo/directory/types"
 	"github.com/arkeonetwork/arkeo/sentinel"
+	atypes "github.com/arkeonetwork/arkeo/x/arkeo/types"
 	"github.com/pkg/errors"
 
 	resty "github.com/go-resty/resty/v2"
 )
 
+// ErrMetadataHashMismatch is returned when the metadata downloaded doesn't match
+// the hash committed to by the provider
+var ErrMetadataHashMismatch = errors.New("metadata hash mismatch")
+
 func ParseCoordinates(coordinates string) (types.Coordinates, error) {
 	if coordinates == "" {
 		return types.Coordinates{}, errors.New("empty string cannot be parsed into coordinates")
@@ -90,7 +95,9 @@ func readFromFilesystem(u *url.URL) (raw []byte, err error) {
 	return raw, nil
 }
 
-func DownloadProviderMetadata(metadataUrl string, retries, maxBytes int) (*sentinel.Metadata, error) {
+// DownloadProviderMetadata reads the metadata of a provider, when the provider
+// committed to a metadata hash the metadata read must match it
+func DownloadProviderMetadata(metadataUrl, metadataHash string, retries, maxBytes int) (*sentinel.Metadata, error) {
 	u, err := url.Parse(metadataUrl)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error parsing url %s", metadataUrl)
@@ -108,6 +115,12 @@ func DownloadProviderMetadata(metadataUrl string, retries, maxBytes int) (*senti
 		}
 	}
 
+	if len(metadataHash) > 0 {
+		if hash := atypes.MetadataHash(raw); hash != metadataHash {
+			return nil, errors.Wrapf(ErrMetadataHashMismatch, "expected %s, got %s", metadataHash, hash)
+		}
+	}
+
 	result := &sentinel.Metadata{}
 	if err = json.Unmarshal(raw, result); err != nil {
 		return nil, errors.Wrapf(err, "error unmarshaling")
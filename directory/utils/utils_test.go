@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/arkeonetwork/arkeo/directory/types"
+	atypes "github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/pkg/errors"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
@@ -92,7 +94,7 @@ func TestDownloadProviderMetadata(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	metadata, err := DownloadProviderMetadata(ts.URL, 5, 1e6)
+	metadata, err := DownloadProviderMetadata(ts.URL, "", 5, 1e6)
 	if err != nil {
 		t.FailNow()
 	}
@@ -105,8 +107,38 @@ func TestDownloadProviderMetadata(t *testing.T) {
 		t.FailNow()
 	}
 
-	_, err = DownloadProviderMetadata(ts.URL, 5, 1)
+	_, err = DownloadProviderMetadata(ts.URL, "", 5, 1)
 	if err == nil {
 		t.FailNow()
 	}
 }
+
+func TestDownloadProviderMetadataHash(t *testing.T) {
+	sdkConfig := sdk.GetConfig()
+	sdkConfig.SetBech32PrefixForAccount("tarkeo", "tarkeopub")
+
+	raw, err := os.ReadFile("../../testutil/sample/metadata.json")
+	if err != nil {
+		t.Fatalf("Failed to read source file: %v", err)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(raw)
+	}))
+	defer ts.Close()
+
+	// the metadata matches the hash committed to
+	metadata, err := DownloadProviderMetadata(ts.URL, atypes.MetadataHash(raw), 5, 1e6)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metadata == nil || metadata.Version == "" {
+		t.FailNow()
+	}
+
+	// the metadata was changed after the provider committed to it
+	_, err = DownloadProviderMetadata(ts.URL, atypes.MetadataHash([]byte("{}")), 5, 1e6)
+	if !errors.Is(err, ErrMetadataHashMismatch) {
+		t.Fatalf("expected a hash mismatch, got %v", err)
+	}
+}
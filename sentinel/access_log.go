@@ -0,0 +1,282 @@
+package sentinel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogStdout is the access log location writing the records to stdout
+const AccessLogStdout = "stdout"
+
+// formats of the access log records
+const (
+	AccessLogFormatJSON = "json" // one json document per line
+	AccessLogFormatText = "text" // one line of key=value pairs (logfmt)
+)
+
+// levels the access records can be written at, for the log pipelines to
+// filter them
+var accessLogLevels = map[string]bool{
+	"debug": true,
+	"info":  true,
+	"warn":  true,
+	"error": true,
+}
+
+// AccessRecord is the access log record of a request, it holds the billing
+// context of the request (contract, spender and nonce)
+type AccessRecord struct {
+	Time       time.Time `json:"time"`
+	Level      string    `json:"level"`
+	Method     string    `json:"method"`
+	RemoteAddr string    `json:"remote_addr"`
+	ContractId uint64    `json:"contract_id"`
+	Spender    string    `json:"spender"`
+	Nonce      int64     `json:"nonce"`
+	Tier       string    `json:"tier"`
+	Service    string    `json:"service"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"duration_ms"`
+}
+
+// AccessLog appends access records, one per line, in json or text
+type AccessLog struct {
+	lock   sync.Mutex
+	writer io.Writer
+	closer io.Closer
+	format string
+	level  string
+}
+
+// NewAccessLog opens the access log at the given location, rotated once it
+// grows over maxBytes (zero never rotates). An empty location disables the
+// access log. The records are written in the given format, at the given
+// level.
+func NewAccessLog(location string, maxBytes int64, maxBackups int, format, level string) (*AccessLog, error) {
+	if len(location) == 0 {
+		return nil, nil
+	}
+	if format != AccessLogFormatJSON && format != AccessLogFormatText {
+		return nil, fmt.Errorf("unknown access log format %q, must be %q or %q", format, AccessLogFormatJSON, AccessLogFormatText)
+	}
+	if !accessLogLevels[level] {
+		return nil, fmt.Errorf("unknown access log level %q", level)
+	}
+	if location == AccessLogStdout {
+		return &AccessLog{writer: os.Stdout, format: format, level: level}, nil
+	}
+	f, err := newRotatingFile(location, maxBytes, maxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open access log %s: %w", location, err)
+	}
+	return &AccessLog{writer: f, closer: f, format: format, level: level}, nil
+}
+
+// NewAccessLogWriter writes json records at the info level to w
+func NewAccessLogWriter(w io.Writer) *AccessLog {
+	return &AccessLog{writer: w, format: AccessLogFormatJSON, level: "info"}
+}
+
+func (a *AccessLog) Write(record AccessRecord) error {
+	if a == nil {
+		return nil
+	}
+	if len(record.Level) == 0 {
+		record.Level = a.level
+	}
+	var buf []byte
+	var err error
+	if a.format == AccessLogFormatText {
+		buf = record.logfmt()
+	} else {
+		buf, err = json.Marshal(record)
+		if err != nil {
+			return err
+		}
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	_, err = a.writer.Write(append(buf, '\n'))
+	return err
+}
+
+// logfmt returns the record as key=value pairs, in the order of the json
+// fields
+func (r AccessRecord) logfmt() []byte {
+	var b strings.Builder
+	pairs := []struct{ key, value string }{
+		{"time", r.Time.Format(time.RFC3339Nano)},
+		{"level", r.Level},
+		{"method", r.Method},
+		{"remote_addr", r.RemoteAddr},
+		{"contract_id", strconv.FormatUint(r.ContractId, 10)},
+		{"spender", r.Spender},
+		{"nonce", strconv.FormatInt(r.Nonce, 10)},
+		{"tier", r.Tier},
+		{"service", r.Service},
+		{"path", r.Path},
+		{"status", strconv.Itoa(r.Status)},
+		{"bytes", strconv.FormatInt(r.Bytes, 10)},
+		{"duration_ms", strconv.FormatFloat(r.DurationMs, 'f', -1, 64)},
+	}
+	for i, pair := range pairs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(pair.key)
+		b.WriteByte('=')
+		if len(pair.value) == 0 || strings.ContainsAny(pair.value, " =\"\t\n") {
+			b.WriteString(strconv.Quote(pair.value))
+		} else {
+			b.WriteString(pair.value)
+		}
+	}
+	return []byte(b.String())
+}
+
+func (a *AccessLog) Close() error {
+	if a == nil || a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// rotatingFile is a file renamed with a numbered suffix (.1 being the most
+// recent) once it grows over its max size, only maxBackups files are kept
+type rotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, maxBytes int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(b)) > r.maxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups > 0 {
+		_ = os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxBackups))
+		for i := r.maxBackups - 1; i > 0; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	return r.file.Close()
+}
+
+type accessInfoKey struct{}
+
+// accessInfo is the billing context of a request, filled in by the auth
+// middleware
+type accessInfo struct {
+	contractId uint64
+	spender    string
+	nonce      int64
+}
+
+func getAccessInfo(ctx context.Context) *accessInfo {
+	info, _ := ctx.Value(accessInfoKey{}).(*accessInfo)
+	return info
+}
+
+// accessRecorder captures the status code and the size of the response
+type accessRecorder struct {
+	statusRecorder
+	bytes int64
+}
+
+func (a *accessRecorder) Write(b []byte) (int, error) {
+	n, err := a.ResponseWriter.Write(b)
+	a.bytes += int64(n)
+	return n, err
+}
+
+// accessLog writes an access record of every request, once the auth
+// middleware (wrapped by it) decided on its tier and contract
+func (p Proxy) accessLog(next http.Handler) http.Handler {
+	if p.AccessLog == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &accessInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), accessInfoKey{}, info))
+		recorder := &accessRecorder{statusRecorder: statusRecorder{ResponseWriter: w, code: http.StatusOK}}
+		next.ServeHTTP(recorder, r)
+
+		service := r.Header.Get(ServiceHeader)
+		if len(service) == 0 {
+			service = strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+		}
+		record := AccessRecord{
+			Time:       start.UTC(),
+			Method:     r.Method,
+			RemoteAddr: p.getRemoteAddr(r),
+			ContractId: info.contractId,
+			Spender:    info.spender,
+			Nonce:      info.nonce,
+			Tier:       w.Header().Get("tier"),
+			Service:    service,
+			Path:       r.URL.Path,
+			Status:     recorder.code,
+			Bytes:      recorder.bytes,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err := p.AccessLog.Write(record); err != nil {
+			p.logger.Error("fail to write access record", "error", err)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package sentinel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestAccessLog(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("hello"))
+	}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	proxy := NewProxy(newTestConfig())
+	var buf bytes.Buffer
+	proxy.AccessLog = NewAccessLogWriter(&buf)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+
+	contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Id = 77
+	proxy.MemStore.Put(contract)
+	proxy.MemStore.SetHeight(20)
+
+	for _, path := range []string{"/btc-mainnet-fullnode/", fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:1", contract.Id)} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		require.Equal(t, http.StatusOK, response.Code)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	fields := []string{"time", "level", "method", "remote_addr", "contract_id", "spender", "nonce", "tier", "service", "path", "status", "bytes", "duration_ms"}
+	for _, line := range lines {
+		var raw map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &raw))
+		for _, field := range fields {
+			require.Contains(t, raw, field)
+		}
+	}
+
+	var free, paid AccessRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &free))
+	require.Equal(t, "free", free.Tier)
+	require.Equal(t, "info", free.Level)
+	require.Equal(t, http.MethodGet, free.Method)
+	require.Equal(t, "10.0.0.1", free.RemoteAddr)
+	require.Zero(t, free.ContractId)
+	require.Equal(t, "btc-mainnet-fullnode", free.Service)
+	require.Equal(t, "/btc-mainnet-fullnode/", free.Path)
+	require.Equal(t, http.StatusOK, free.Status)
+	require.Equal(t, int64(5), free.Bytes)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &paid))
+	require.Equal(t, "paid", paid.Tier)
+	require.Equal(t, http.MethodGet, paid.Method)
+	require.Equal(t, contract.Id, paid.ContractId)
+	require.Equal(t, contract.Client.String(), paid.Spender)
+	require.Equal(t, int64(1), paid.Nonce)
+	require.Equal(t, http.StatusOK, paid.Status)
+	require.Equal(t, int64(5), paid.Bytes)
+}
+
+func TestAccessLogRotation(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "access.log")
+	accessLog, err := NewAccessLog(location, 300, 2, AccessLogFormatJSON, "info")
+	require.NoError(t, err)
+	defer accessLog.Close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, accessLog.Write(AccessRecord{Path: "/btc-mainnet-fullnode/", Status: http.StatusOK}))
+	}
+	for _, name := range []string{location, location + ".1", location + ".2"} {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		require.LessOrEqual(t, info.Size(), int64(300))
+	}
+	_, err = os.Stat(location + ".3")
+	require.True(t, os.IsNotExist(err))
+
+	disabled, err := NewAccessLog("", 0, 0, "", "")
+	require.NoError(t, err)
+	require.Nil(t, disabled)
+
+	_, err = NewAccessLog(location, 0, 0, "xml", "info")
+	require.Error(t, err)
+	_, err = NewAccessLog(location, 0, 0, AccessLogFormatJSON, "verbose")
+	require.Error(t, err)
+}
+
+func TestAccessLogText(t *testing.T) {
+	var buf bytes.Buffer
+	accessLog := &AccessLog{writer: &buf, format: AccessLogFormatText, level: "debug"}
+	require.NoError(t, accessLog.Write(AccessRecord{
+		Time:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Method:     http.MethodPost,
+		RemoteAddr: "10.0.0.1",
+		ContractId: 77,
+		Spender:    "tarkeopub1spender",
+		Nonce:      3,
+		Tier:       "paid",
+		Service:    "btc-mainnet-fullnode",
+		Path:       "/btc-mainnet-fullnode/a b",
+		Status:     http.StatusOK,
+		Bytes:      5,
+		DurationMs: 1.5,
+	}))
+	require.Equal(t, `time=2024-01-02T03:04:05Z level=debug method=POST remote_addr=10.0.0.1 contract_id=77 spender=tarkeopub1spender nonce=3 tier=paid service=btc-mainnet-fullnode path="/btc-mainnet-fullnode/a b" status=200 bytes=5 duration_ms=1.5`+"\n", buf.String())
+
+	// the free tier has no spender
+	buf.Reset()
+	require.NoError(t, accessLog.Write(AccessRecord{Method: http.MethodGet, Tier: "free", Status: http.StatusTooManyRequests}))
+	require.Contains(t, buf.String(), ` spender="" `)
+	require.Contains(t, buf.String(), ` tier=free `)
+	require.Contains(t, buf.String(), ` status=429 `)
+}
@@ -0,0 +1,232 @@
+package sentinel
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// IPBan refuses the requests of an address, on both tiers
+type IPBan struct {
+	Reason  string `json:"reason"`
+	Expires int64  `json:"expires"` // unix timestamp, zero never expires
+}
+
+// IPBanRecord is a ban listed by the admin api
+type IPBanRecord struct {
+	IP string `json:"ip"`
+	IPBan
+}
+
+// setIPBan is the body of a request banning an address
+type setIPBan struct {
+	Reason      string `json:"reason"`
+	DurationSec int64  `json:"duration_sec"` // zero never expires
+}
+
+// adminReplay refuses the admin requests whose signature was already
+// accepted. The signatures are kept in the state store, so a request can't be
+// replayed after a restart either, until they are too old to pass the max age
+// of the admin auth anyway.
+type adminReplay struct {
+	lock  sync.Mutex
+	state *StateStore
+}
+
+func newAdminReplay(state *StateStore) *adminReplay {
+	return &adminReplay{state: state}
+}
+
+func (a *adminReplay) accept(auth AdminAuth) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	key := hex.EncodeToString(auth.Signature)
+	var timestamp int64
+	found, err := a.state.Get(StateNamespaceAdminSignatures, key, &timestamp)
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("signature already used")
+	}
+	// a timestamp is accepted up to the max age before or after now
+	return a.state.Set(StateNamespaceAdminSignatures, key, auth.Timestamp, 2*adminAuthMaxAge)
+}
+
+// newAdminServer returns the server of the admin api, on the admin port
+func (p Proxy) newAdminServer() *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%s", p.Config.AdminPort),
+		Handler:           p.getAdminRouter(),
+		ReadTimeout:       5 * time.Second,
+		ReadHeaderTimeout: time.Second,
+		WriteTimeout:      30 * time.Second, // claims are broadcast before answering
+		IdleTimeout:       5 * time.Second,
+	}
+}
+
+func (p *Proxy) getAdminRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc(RoutesAdminContracts, p.adminAuth(p.handleAdminContracts)).Methods(http.MethodGet)
+	router.HandleFunc(RoutesAdminContract, p.adminAuth(p.handleAdminEvictContract)).Methods(http.MethodDelete)
+	router.HandleFunc(RoutesAdminClaim, p.adminAuth(p.handleAdminClaim)).Methods(http.MethodPost)
+	router.HandleFunc(RoutesAdminBans, p.adminAuth(p.handleAdminBans)).Methods(http.MethodGet)
+	router.HandleFunc(RoutesAdminBan, p.adminAuth(p.handleAdminSetBan)).Methods(http.MethodPut)
+	router.HandleFunc(RoutesAdminBan, p.adminAuth(p.handleAdminRemoveBan)).Methods(http.MethodDelete)
+	router.HandleFunc(RoutesAdminConfig, p.adminAuth(p.handleAdminConfig)).Methods(http.MethodGet)
+	return router
+}
+
+func (p Proxy) handleAdminContracts(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, p.MemStore.List())
+}
+
+func (p Proxy) handleAdminEvictContract(w http.ResponseWriter, r *http.Request) {
+	contractId, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondWithError(w, fmt.Sprintf("bad contract id: %s", err), http.StatusBadRequest)
+		return
+	}
+	if !p.MemStore.Evict(strconv.FormatUint(contractId, 10)) {
+		respondWithError(w, fmt.Sprintf("contract %d is not held in memory", contractId), http.StatusNotFound)
+		return
+	}
+	p.logger.Info("contract evicted", "id", contractId)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAdminClaim submits the claim of the contract right away, regardless
+// of its income
+func (p Proxy) handleAdminClaim(w http.ResponseWriter, r *http.Request) {
+	if p.Chain == nil {
+		respondWithError(w, "claim submission is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	contractId, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		respondWithError(w, fmt.Sprintf("bad contract id: %s", err), http.StatusBadRequest)
+		return
+	}
+	claim, err := p.ClaimStore.Get(strconv.FormatUint(contractId, 10))
+	if err != nil {
+		respondWithError(w, fmt.Sprintf("fail to get claim: %s", err), http.StatusInternalServerError)
+		return
+	}
+	if claim.ContractId == 0 {
+		respondWithError(w, fmt.Sprintf("no claim for contract %d", contractId), http.StatusNotFound)
+		return
+	}
+	if claim.Claimed {
+		respondWithError(w, fmt.Sprintf("claim of contract %d already submitted", contractId), http.StatusConflict)
+		return
+	}
+	submitted, failed := p.submitClaims(p.Chain, []Claim{claim})
+	respondWithJSON(w, http.StatusOK, DrainSummary{Submitted: 1, Succeeded: len(submitted), Failed: failed})
+}
+
+func (p Proxy) handleAdminBans(w http.ResponseWriter, r *http.Request) {
+	bans := []IPBanRecord{}
+	for _, record := range p.StateStore.List(StateNamespaceBans) {
+		var ban IPBan
+		if err := json.Unmarshal(record.Value, &ban); err != nil {
+			p.logger.Error("fail to unmarshal ban", "error", err, "ip", record.Key)
+			continue
+		}
+		bans = append(bans, IPBanRecord{IP: record.Key, IPBan: ban})
+	}
+	respondWithJSON(w, http.StatusOK, bans)
+}
+
+func (p Proxy) handleAdminSetBan(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(mux.Vars(r)["ip"])
+	if ip == nil {
+		respondWithError(w, fmt.Sprintf("bad ip address: %s", mux.Vars(r)["ip"]), http.StatusBadRequest)
+		return
+	}
+	var req setIPBan
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		respondWithError(w, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			respondWithError(w, fmt.Sprintf("bad ban: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.DurationSec < 0 {
+		respondWithError(w, "bad ban: duration can't be negative", http.StatusBadRequest)
+		return
+	}
+
+	ttl := time.Duration(req.DurationSec) * time.Second
+	ban := IPBan{Reason: req.Reason}
+	if ttl > 0 {
+		ban.Expires = time.Now().Add(ttl).Unix()
+	}
+	if err := p.StateStore.Set(StateNamespaceBans, ip.String(), ban, ttl); err != nil {
+		respondWithError(w, fmt.Sprintf("fail to save ban: %s", err), http.StatusInternalServerError)
+		return
+	}
+	p.logger.Info("ip banned", "ip", ip.String(), "reason", ban.Reason, "expires", ban.Expires)
+	respondWithJSON(w, http.StatusOK, IPBanRecord{IP: ip.String(), IPBan: ban})
+}
+
+func (p Proxy) handleAdminRemoveBan(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(mux.Vars(r)["ip"])
+	if ip == nil {
+		respondWithError(w, fmt.Sprintf("bad ip address: %s", mux.Vars(r)["ip"]), http.StatusBadRequest)
+		return
+	}
+	if _, ok := p.ipBan(ip.String()); !ok {
+		respondWithError(w, fmt.Sprintf("%s is not banned", ip), http.StatusNotFound)
+		return
+	}
+	if err := p.StateStore.Remove(StateNamespaceBans, ip.String()); err != nil {
+		respondWithError(w, fmt.Sprintf("fail to remove ban: %s", err), http.StatusInternalServerError)
+		return
+	}
+	p.logger.Info("ip ban lifted", "ip", ip.String())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p Proxy) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, p.config().Configuration.Redacted())
+}
+
+// ipBan returns the ban of the remote address, false when it isn't banned
+func (p Proxy) ipBan(remoteAddr string) (IPBan, bool) {
+	var ban IPBan
+	key := remoteAddr
+	if ip := net.ParseIP(remoteAddr); ip != nil {
+		key = ip.String()
+	}
+	found, err := p.StateStore.Get(StateNamespaceBans, key, &ban)
+	if err != nil {
+		p.logger.Error("fail to get ban", "error", err, "ip", remoteAddr)
+		return ban, false
+	}
+	return ban, found
+}
+
+// ipBanError returns an error when the remote address is banned, nil
+// otherwise
+func (p Proxy) ipBanError(remoteAddr string) error {
+	ban, ok := p.ipBan(remoteAddr)
+	if !ok {
+		return nil
+	}
+	if len(ban.Reason) > 0 {
+		return newProxyError(ErrCodeIPBanned, "%s is banned: %s", remoteAddr, ban.Reason)
+	}
+	return newProxyError(ErrCodeIPBanned, "%s is banned", remoteAddr)
+}
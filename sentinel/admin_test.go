@@ -0,0 +1,170 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	cKeys "github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/std"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func TestAdminAPI(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	cdc := codec.NewProtoCodec(interfaceRegistry)
+	kb := cKeys.NewInMemory(cdc)
+	info, _, err := kb.NewMnemonic("provider", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+	pub, err := info.GetPubKey()
+	require.NoError(t, err)
+	_, _, err = kb.NewMnemonic("other", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+
+	testConfig := newTestConfig()
+	testConfig.ProviderPubKey, err = common.NewPubKeyFromCrypto(pub)
+	require.NoError(t, err)
+	testConfig.FreeTierClientSecret = "s3cr3t"
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Id = 5
+	proxy.MemStore.Put(contract)
+
+	// each request is signed with its own timestamp, a signature is only
+	// accepted once
+	timestamp := time.Now().Add(-time.Minute).Unix()
+	signed := func(key, method, path string, ts int64, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		require.NoError(t, err)
+		signAdmin(t, kb, key, req, []byte(body), ts)
+		response := httptest.NewRecorder()
+		proxy.getAdminRouter().ServeHTTP(response, req)
+		return response
+	}
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		timestamp++
+		return signed("provider", method, path, timestamp, body)
+	}
+
+	// missing, or not signed by the provider
+	req, err := http.NewRequest(http.MethodGet, RoutesAdminContracts, nil)
+	require.NoError(t, err)
+	response := httptest.NewRecorder()
+	proxy.getAdminRouter().ServeHTTP(response, req)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	timestamp++
+	require.Equal(t, http.StatusUnauthorized, signed("other", http.MethodGet, RoutesAdminContracts, timestamp, "").Code)
+
+	// list the contracts held in memory
+	response = admin(http.MethodGet, RoutesAdminContracts, "")
+	require.Equal(t, http.StatusOK, response.Code)
+	var contracts []types.Contract
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &contracts))
+	require.Len(t, contracts, 1)
+	require.Equal(t, contract.Id, contracts[0].Id)
+
+	// a signed request can't be replayed, one signed before it but never sent
+	// is accepted
+	require.Equal(t, http.StatusUnauthorized, signed("provider", http.MethodGet, RoutesAdminContracts, timestamp, "").Code)
+	require.Equal(t, http.StatusOK, signed("provider", http.MethodGet, RoutesAdminContracts, timestamp-1, "").Code)
+	// nor one too far from now
+	require.Equal(t, http.StatusUnauthorized, signed("provider", http.MethodGet, RoutesAdminContracts, time.Now().Add(time.Hour).Unix(), "").Code)
+
+	// evict a contract
+	require.Equal(t, http.StatusNoContent, admin(http.MethodDelete, "/admin/contracts/5", "").Code)
+	_, ok := proxy.MemStore.Peek("5")
+	require.False(t, ok)
+	require.Equal(t, http.StatusNotFound, admin(http.MethodDelete, "/admin/contracts/5", "").Code)
+	require.Equal(t, http.StatusBadRequest, admin(http.MethodDelete, "/admin/contracts/five", "").Code)
+
+	// force the claim of a contract
+	require.Equal(t, http.StatusServiceUnavailable, admin(http.MethodPost, "/admin/contracts/7/claim", "").Code)
+	chain := &mockChainClient{}
+	proxy.Chain = chain
+	require.Equal(t, http.StatusNotFound, admin(http.MethodPost, "/admin/contracts/7/claim", "").Code)
+	require.NoError(t, proxy.ClaimStore.Set(NewClaim(7, types.GetRandomPubKey(), 3, "sig7")))
+	response = admin(http.MethodPost, "/admin/contracts/7/claim", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	var summary DrainSummary
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &summary))
+	require.Equal(t, DrainSummary{Submitted: 1, Succeeded: 1}, summary)
+	require.Len(t, chain.claims, 1)
+	claim, err := proxy.ClaimStore.Get("7")
+	require.NoError(t, err)
+	require.True(t, claim.Claimed)
+	require.Equal(t, http.StatusConflict, admin(http.MethodPost, "/admin/contracts/7/claim", "").Code)
+
+	// the signature covers the method, the path and the body
+	timestamp++
+	req, err = http.NewRequest(http.MethodPut, "/admin/bans/10.0.0.2", strings.NewReader(`{"duration_sec":0}`))
+	require.NoError(t, err)
+	signAdmin(t, kb, "provider", req, []byte(`{"duration_sec":60}`), timestamp)
+	response = httptest.NewRecorder()
+	proxy.getAdminRouter().ServeHTTP(response, req)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+
+	// ban an address, its requests are refused
+	proxied := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/", nil)
+		req.Header.Set(xRealIPName, ip)
+		response := httptest.NewRecorder()
+		proxy.getRouter().ServeHTTP(response, req)
+		return response
+	}
+	require.Equal(t, http.StatusOK, proxied("10.0.0.1").Code)
+	require.Equal(t, http.StatusBadRequest, admin(http.MethodPut, "/admin/bans/not-an-ip", "").Code)
+	require.Equal(t, http.StatusBadRequest, admin(http.MethodPut, "/admin/bans/10.0.0.1", `{"duration_sec":-1}`).Code)
+	response = admin(http.MethodPut, "/admin/bans/10.0.0.1", `{"reason":"abuse","duration_sec":3600}`)
+	require.Equal(t, http.StatusOK, response.Code)
+	var ban IPBanRecord
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &ban))
+	require.Equal(t, "10.0.0.1", ban.IP)
+	require.Equal(t, "abuse", ban.Reason)
+	require.Greater(t, ban.Expires, time.Now().Unix())
+
+	response = proxied("10.0.0.1")
+	require.Equal(t, http.StatusForbidden, response.Code)
+	var errResp ErrorResponse
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errResp))
+	require.Equal(t, ErrCodeIPBanned, errResp.Code)
+	require.Contains(t, errResp.Error, "abuse")
+	require.Equal(t, http.StatusOK, proxied("10.0.0.2").Code)
+
+	response = admin(http.MethodGet, RoutesAdminBans, "")
+	require.Equal(t, http.StatusOK, response.Code)
+	var bans []IPBanRecord
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &bans))
+	require.Len(t, bans, 1)
+	require.Equal(t, "10.0.0.1", bans[0].IP)
+
+	// lift the ban
+	require.Equal(t, http.StatusNoContent, admin(http.MethodDelete, "/admin/bans/10.0.0.1", "").Code)
+	require.Equal(t, http.StatusNotFound, admin(http.MethodDelete, "/admin/bans/10.0.0.1", "").Code)
+	require.Equal(t, http.StatusOK, proxied("10.0.0.1").Code)
+
+	// dump the configuration, without its secrets
+	response = admin(http.MethodGet, RoutesAdminConfig, "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.NotContains(t, response.Body.String(), "s3cr3t")
+	var dump map[string]interface{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &dump))
+	require.Equal(t, testConfig.Moniker, dump["moniker"])
+	require.Equal(t, testConfig.ProviderPubKey.String(), dump["provider_pubkey"])
+}
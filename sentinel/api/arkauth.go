@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Signer signs the arkauth of a request, the message to sign being
+// "<contract id>:<nonce>"
+type Signer func(contractId uint64, nonce int64) ([]byte, error)
+
+// ArkAuthString returns the arkauth of a request: "<contract id>:<nonce>:<hex
+// signature>"
+func ArkAuthString(contractId uint64, nonce int64, signature []byte) string {
+	return fmt.Sprintf("%d:%d:%s", contractId, nonce, hex.EncodeToString(signature))
+}
+
+// ArkAuthTransport is an http.RoundTripper paying for each request with the
+// next nonce of a contract. Every attempt of a request is a new request to the
+// sentinel, and is signed with a new nonce. The nonce resynchronizes with the
+// nonce the sentinel sends on paid responses, when it is ahead.
+type ArkAuthTransport struct {
+	Base       http.RoundTripper
+	ContractId uint64
+	Sign       Signer
+
+	lock  sync.Mutex
+	nonce int64
+}
+
+// NewArkAuthTransport returns a transport paying with the contract, starting
+// after the given nonce (the last one used). A nil base uses
+// http.DefaultTransport.
+func NewArkAuthTransport(base http.RoundTripper, contractId uint64, nonce int64, sign Signer) *ArkAuthTransport {
+	return &ArkAuthTransport{
+		Base:       base,
+		ContractId: contractId,
+		Sign:       sign,
+		nonce:      nonce,
+	}
+}
+
+// Nonce returns the last nonce used
+func (t *ArkAuthTransport) Nonce() int64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.nonce
+}
+
+func (t *ArkAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	t.nonce++
+	nonce := t.nonce
+	t.lock.Unlock()
+
+	signature, err := t.Sign(t.ContractId, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("fail to sign arkauth: %w", err)
+	}
+	// a RoundTripper must not modify the request
+	signed := req.Clone(req.Context())
+	signed.Header.Set(ArkAuthHeader, ArkAuthString(t.ContractId, nonce, signature))
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(signed)
+	if err != nil {
+		return nil, err
+	}
+	if paid, err := strconv.ParseInt(resp.Header.Get(NonceHeader), 10, 64); err == nil {
+		t.lock.Lock()
+		if paid > t.nonce {
+			t.nonce = paid
+		}
+		t.lock.Unlock()
+	}
+	return resp, nil
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetries      = 2
+	defaultRetryBackoff = 500 * time.Millisecond
+	defaultTimeout      = 10 * time.Second
+	// max size of a response body read by the client
+	maxResponseSize = 10 << 20
+)
+
+// Client calls the public endpoints of a sentinel, on their v1 paths. Requests
+// failing to reach the sentinel, or answered with a 502, 503 or 504, are tried
+// again.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the http client sending the requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries sets how many times a failed request is tried again, and how
+// long to wait before the first retry, doubled on each retry
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithArkAuth signs the requests with an arkauth of the contract, see
+// ArkAuthTransport. It wraps the transport of the http client, so it goes after
+// WithHTTPClient.
+func WithArkAuth(contractId uint64, nonce int64, sign Signer) Option {
+	return func(c *Client) {
+		httpClient := *c.httpClient
+		httpClient.Transport = NewArkAuthTransport(httpClient.Transport, contractId, nonce, sign)
+		c.httpClient = &httpClient
+	}
+}
+
+// NewClient returns a client of the sentinel at the given url
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		retries:    defaultRetries,
+		backoff:    defaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// HTTPClient returns the http client of the client, to send requests to the
+// services of the sentinel with the same transport
+func (c *Client) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
+func (c *Client) Health(ctx context.Context) (Health, error) {
+	var health Health
+	return health, c.get(ctx, PathHealth, &health)
+}
+
+// Ready returns the readiness of the sentinel, a sentinel not ready isn't an
+// error
+func (c *Client) Ready(ctx context.Context) (Readiness, error) {
+	var readiness Readiness
+	return readiness, c.get(ctx, PathReady, &readiness, http.StatusServiceUnavailable)
+}
+
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	return status, c.get(ctx, PathStatus, &status)
+}
+
+// ContractStatus returns what the sentinel knows of a contract, without the
+// sentinel querying the chain
+func (c *Client) ContractStatus(ctx context.Context, contractId uint64) (ContractStatus, error) {
+	var status ContractStatus
+	return status, c.get(ctx, expandPath(PathStatusContract, contractId), &status)
+}
+
+func (c *Client) Metadata(ctx context.Context) (Metadata, error) {
+	var metadata Metadata
+	return metadata, c.get(ctx, PathMetadata, &metadata)
+}
+
+// Contract returns the state of a contract, fetched from the chain when the
+// sentinel doesn't hold it
+func (c *Client) Contract(ctx context.Context, contractId uint64) (ContractState, error) {
+	var state ContractState
+	return state, c.get(ctx, expandPath(PathContract, contractId), &state)
+}
+
+// ActiveContract returns the active contract of the spender on the service
+// with this sentinel's provider, as answered by the chain
+func (c *Client) ActiveContract(ctx context.Context, service, spender string) (json.RawMessage, error) {
+	var contract json.RawMessage
+	return contract, c.get(ctx, expandPath(PathActiveContract, service, spender), &contract)
+}
+
+// Claim returns the latest claim of a contract
+func (c *Client) Claim(ctx context.Context, contractId uint64) (Claim, error) {
+	var claim Claim
+	return claim, c.get(ctx, expandPath(PathClaim, contractId), &claim)
+}
+
+// OpenClaims returns the claims not yet claimed on chain
+func (c *Client) OpenClaims(ctx context.Context) ([]Claim, error) {
+	var claims []Claim
+	return claims, c.get(ctx, PathOpenClaims, &claims)
+}
+
+// Usage returns the usage of a contract, authenticated with an arkauth signed
+// by its spender. The nonce is only checked, not consumed, any nonce will do.
+func (c *Client) Usage(ctx context.Context, contractId uint64, nonce int64, sign Signer) (Usage, error) {
+	var usage Usage
+	signature, err := sign(contractId, nonce)
+	if err != nil {
+		return usage, fmt.Errorf("fail to sign arkauth: %w", err)
+	}
+	query := url.Values{ArkAuthHeader: {ArkAuthString(contractId, nonce, signature)}}
+	return usage, c.get(ctx, expandPath(PathUsage, contractId)+"?"+query.Encode(), &usage)
+}
+
+// ValidateArkAuth diagnoses an arkauth without paying with it, its signature
+// is checked against the spender pubkey when given
+func (c *Client) ValidateArkAuth(ctx context.Context, arkauth, spender string) (ArkAuthValidation, error) {
+	var validation ArkAuthValidation
+	query := url.Values{ArkAuthHeader: {arkauth}}
+	if len(spender) > 0 {
+		query.Set(SpenderParam, spender)
+	}
+	return validation, c.get(ctx, PathValidate+"?"+query.Encode(), &validation)
+}
+
+// get decodes the response of the endpoint into out, a response with a status
+// other than 200 and the accepted ones is returned as an *Error
+func (c *Client) get(ctx context.Context, path string, out interface{}, accepted ...int) error {
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		status, body, err := c.do(ctx, V1Prefix+path)
+		if err == nil && !retryableStatus(status, accepted) {
+			return decodeResponse(status, body, out, accepted)
+		}
+		if attempt >= c.retries || ctx.Err() != nil {
+			if err != nil {
+				return err
+			}
+			return decodeResponse(status, body, out, accepted)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) do(ctx context.Context, path string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+func retryableStatus(status int, accepted []int) bool {
+	for _, code := range accepted {
+		if status == code {
+			return false
+		}
+	}
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func decodeResponse(status int, body []byte, out interface{}, accepted []int) error {
+	ok := status == http.StatusOK
+	for _, code := range accepted {
+		ok = ok || status == code
+	}
+	if !ok {
+		apiErr := &Error{StatusCode: status}
+		if err := json.Unmarshal(body, &apiErr.Response); err != nil || len(apiErr.Response.Error) == 0 {
+			apiErr.Response.Error = strings.TrimSpace(string(body))
+		}
+		return apiErr
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("fail to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.Equal(t, V1Prefix+PathHealth, req.URL.Path)
+		if atomic.AddInt32(&calls, 1) < 3 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	health, err := NewClient(server.URL, WithRetries(2, time.Millisecond)).Health(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "ok", health.Status)
+	require.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// out of retries, the last response is the error
+	atomic.StoreInt32(&calls, 0)
+	_, err = NewClient(server.URL, WithRetries(1, time.Millisecond)).Health(ctx)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case V1Prefix + expandPath(PathStatusContract, 7):
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = rw.Write([]byte(`{"error":"contract 7 not found"}`))
+		case V1Prefix + PathReady:
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = rw.Write([]byte(`{"ready":false,"height":3}`))
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			_, _ = rw.Write([]byte("boom"))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL, WithRetries(0, 0))
+
+	_, err := client.ContractStatus(ctx, 7)
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	require.Equal(t, "contract 7 not found", apiErr.Response.Error)
+
+	// a body that isn't an error response is kept as the message
+	_, err = client.Status(ctx)
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, "boom", apiErr.Response.Error)
+
+	// not being ready isn't an error
+	readiness, err := client.Ready(ctx)
+	require.NoError(t, err)
+	require.False(t, readiness.Ready)
+	require.Equal(t, int64(3), readiness.Height)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = client.Health(canceled)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestArkAuthTransport(t *testing.T) {
+	var paid int64
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received = append(received, req.Header.Get(ArkAuthHeader))
+		if paid > 0 {
+			rw.Header().Set(NonceHeader, strconv.FormatInt(paid, 10))
+		}
+	}))
+	defer server.Close()
+
+	sign := func(contractId uint64, nonce int64) ([]byte, error) {
+		return []byte(fmt.Sprintf("%d:%d", contractId, nonce)), nil
+	}
+	transport := NewArkAuthTransport(nil, 5, 2, sign)
+	client := &http.Client{Transport: transport}
+
+	get := func() {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	get()
+	get()
+	require.Equal(t, []string{
+		ArkAuthString(5, 3, []byte("5:3")),
+		ArkAuthString(5, 4, []byte("5:4")),
+	}, received)
+	require.Equal(t, int64(4), transport.Nonce())
+
+	// the sentinel is ahead, another client paid with the contract
+	paid = 10
+	get()
+	require.Equal(t, int64(10), transport.Nonce())
+	get()
+	require.Equal(t, ArkAuthString(5, 11, []byte("5:11")), received[len(received)-1])
+
+	// a nonce behind isn't taken
+	paid = 1
+	get()
+	require.Equal(t, int64(12), transport.Nonce())
+
+	failing := NewArkAuthTransport(nil, 5, 0, func(uint64, int64) ([]byte, error) {
+		return nil, fmt.Errorf("no key")
+	})
+	_, err := (&http.Client{Transport: failing}).Get(server.URL)
+	require.Error(t, err)
+}
+
+func TestExpandPath(t *testing.T) {
+	require.Equal(t, "/claim/3", expandPath(PathClaim, 3))
+	require.Equal(t, "/active-contract/btc-mainnet-fullnode/a%2Fb", expandPath(PathActiveContract, "btc-mainnet-fullnode", "a/b"))
+	require.Equal(t, "/"+QueryContract+"/9", expandPath(PathContract, 9))
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"fmt"
+)
+
+// ErrorCode is the stable, machine readable reason a request was refused
+type ErrorCode string
+
+const (
+	ErrCodeBadArkAuth          ErrorCode = "BAD_ARKAUTH"
+	ErrCodeBadSignature        ErrorCode = "BAD_SIGNATURE"
+	ErrCodeUnknownContract     ErrorCode = "UNKNOWN_CONTRACT"
+	ErrCodeProviderMismatch    ErrorCode = "PROVIDER_MISMATCH"
+	ErrCodeIPNotWhitelisted    ErrorCode = "IP_NOT_WHITELISTED"
+	ErrCodeIPBanned            ErrorCode = "IP_BANNED"
+	ErrCodeUserRateLimited     ErrorCode = "USER_RATE_LIMITED"
+	ErrCodeServiceMismatch     ErrorCode = "SERVICE_MISMATCH"
+	ErrCodeUnknownService      ErrorCode = "UNKNOWN_SERVICE"
+	ErrCodeContractExpired     ErrorCode = "CONTRACT_EXPIRED"
+	ErrCodeDelegateRotated     ErrorCode = "DELEGATE_ROTATED"
+	ErrCodeUnlistedSpender     ErrorCode = "UNLISTED_SPENDER"
+	ErrCodeBadNonce            ErrorCode = "BAD_NONCE"
+	ErrCodeContractSpent       ErrorCode = "CONTRACT_SPENT"
+	ErrCodeContractRateLimited ErrorCode = "CONTRACT_RATE_LIMITED"
+	ErrCodeBlockQuotaExceeded  ErrorCode = "BLOCK_QUOTA_EXCEEDED"
+	ErrCodeDailySpendCap       ErrorCode = "DAILY_SPEND_CAP_REACHED"
+	ErrCodeFreeTierRateLimited ErrorCode = "FREE_TIER_RATE_LIMITED"
+	ErrCodeFreeTierDisabled    ErrorCode = "FREE_TIER_DISABLED"
+	ErrCodeBadChallenge        ErrorCode = "BAD_CHALLENGE"
+	ErrCodeVerifyRateLimited   ErrorCode = "VERIFY_RATE_LIMITED"
+	ErrCodeSessionsDisabled    ErrorCode = "SESSIONS_DISABLED"
+	ErrCodeBadSession          ErrorCode = "BAD_SESSION"
+	ErrCodeSessionSpent        ErrorCode = "SESSION_SPENT"
+	ErrCodeTooManyInFlight     ErrorCode = "TOO_MANY_IN_FLIGHT"
+	ErrCodeInternal            ErrorCode = "INTERNAL_ERROR"
+	ErrCodeUnknown             ErrorCode = "UNKNOWN"
+)
+
+// ErrorResponse is the body of a refused request. The endpoints other than
+// the proxied services only set Error.
+type ErrorResponse struct {
+	Error      string    `json:"error"`
+	Code       ErrorCode `json:"code,omitempty"`
+	HTTPStatus int       `json:"http_status,omitempty"`
+	// provider the sentinel serves, sent when an arkauth is refused for
+	// clients to tell they reached the wrong sentinel
+	ExpectedProvider string `json:"expected_provider,omitempty"`
+}
+
+// Error is returned by the client when the sentinel refuses a request
+type Error struct {
+	StatusCode int
+	Response   ErrorResponse
+}
+
+func (e *Error) Error() string {
+	if len(e.Response.Code) > 0 {
+		return fmt.Sprintf("sentinel returned %d (%s): %s", e.StatusCode, e.Response.Code, e.Response.Error)
+	}
+	return fmt.Sprintf("sentinel returned %d: %s", e.StatusCode, e.Response.Error)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+)
+
+const (
+	// MinChallengeLength and MaxChallengeLength bound the challenge of the
+	// verify endpoint
+	MinChallengeLength = 16
+	MaxChallengeLength = 128
+)
+
+// challenges are url safe base64 or hex, the message signed can't be mistaken
+// for any other message the provider key signs
+var challengeRegexp = regexp.MustCompile(fmt.Sprintf(`^[A-Za-z0-9_-]{%d,%d}$`, MinChallengeLength, MaxChallengeLength))
+
+// ValidateChallenge returns an error when the challenge isn't accepted by the
+// verify endpoint
+func ValidateChallenge(challenge string) error {
+	if !challengeRegexp.MatchString(challenge) {
+		return fmt.Errorf("challenge must be %d to %d letters, digits, '-' or '_'", MinChallengeLength, MaxChallengeLength)
+	}
+	return nil
+}
+
+// NewChallenge returns a random challenge for the verify endpoint
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// IdentityMessage is the message the provider key signs to answer a
+// challenge: "<challenge>:<height>"
+func IdentityMessage(challenge string, height int64) []byte {
+	return []byte(fmt.Sprintf("%s:%d", challenge, height))
+}
+
+// Verify checks the identity answers the challenge, signed by the provider
+// key. The provider is the pubkey registered on chain, not the one the
+// sentinel claims.
+func (i ProviderIdentity) Verify(challenge string, provider common.PubKey) error {
+	if i.Challenge != challenge {
+		return fmt.Errorf("identity answers challenge %q, not %q", i.Challenge, challenge)
+	}
+	if !i.Provider.Equals(provider) {
+		return fmt.Errorf("sentinel serves provider %s, not %s", i.Provider, provider)
+	}
+	pk, err := cosmos.GetPubKeyFromBech32(cosmos.Bech32PubKeyTypeAccPub, provider.String())
+	if err != nil {
+		return fmt.Errorf("bad provider pubkey: %w", err)
+	}
+	signature, err := hex.DecodeString(i.Signature)
+	if err != nil {
+		return fmt.Errorf("bad signature: %w", err)
+	}
+	if !pk.VerifySignature(IdentityMessage(i.Challenge, i.Height), signature) {
+		return fmt.Errorf("invalid signature, not signed by provider %s", provider)
+	}
+	return nil
+}
+
+// VerifyProvider checks the sentinel controls the key of the provider, before
+// opening a contract with it: the sentinel signs a new random challenge, the
+// signature is verified against the provider pubkey registered on chain
+func (c *Client) VerifyProvider(ctx context.Context, provider common.PubKey) (ProviderIdentity, error) {
+	var identity ProviderIdentity
+	challenge, err := NewChallenge()
+	if err != nil {
+		return identity, fmt.Errorf("fail to generate challenge: %w", err)
+	}
+	query := url.Values{ChallengeParam: {challenge}}
+	if err := c.get(ctx, PathVerify+"?"+query.Encode(), &identity); err != nil {
+		return identity, err
+	}
+	return identity, identity.Verify(challenge, provider)
+}
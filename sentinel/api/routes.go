@@ -0,0 +1,83 @@
+// Package api defines the public HTTP API of the sentinel: the paths of its
+// endpoints, the bodies they answer with, and a client to call them. The
+// sentinel marshals its responses with these types, so they can't drift from
+// the implementation.
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// V1Prefix is the prefix of the versioned paths. The unversioned paths are
+// aliases of the v1 ones, kept for existing clients.
+const V1Prefix = "/v1"
+
+// paths of the public endpoints, relative to the version prefix
+const (
+	PathHealth         = "/health"
+	PathReady          = "/ready"
+	PathStatus         = "/status"
+	PathStatusContract = "/status/contract/{id}"
+	PathMetadata       = "/metadata.json"
+	PathActiveContract = "/active-contract/{service}/{spender}"
+	PathClaim          = "/claim/{id}"
+	PathContract       = "/" + QueryContract + "/{id}"
+	PathOpenClaims     = "/open-claims"
+	PathUsage          = "/usage/{id}"
+	PathValidate       = "/validate"
+	PathVerify         = "/verify"
+	PathSession        = "/session"
+)
+
+const (
+	// QueryContract is the path of the contract endpoint, and the header of
+	// the contract auth
+	QueryContract = "arkcontract"
+	// ArkAuthHeader carries the arkauth of a paid request
+	ArkAuthHeader = "arkauth"
+	// NonceHeader is the highest nonce paid for on the contract, sent on paid
+	// responses for clients to resynchronize their nonce
+	NonceHeader = "X-Ark-Nonce"
+	// SpenderParam is the pubkey the validate endpoint checks the signature
+	// of an arkauth against
+	SpenderParam = "spender"
+	// ChallengeParam is the challenge the verify endpoint signs
+	ChallengeParam = "challenge"
+	// SessionHeader carries the token of a paid session, in place of an
+	// arkauth
+	SessionHeader = "arksession"
+	// DurationParam is the number of seconds a session is requested for
+	DurationParam = "duration"
+)
+
+// PublicPaths are the paths served both unversioned and under V1Prefix
+var PublicPaths = []string{
+	PathHealth,
+	PathReady,
+	PathStatus,
+	PathStatusContract,
+	PathMetadata,
+	PathActiveContract,
+	PathClaim,
+	PathContract,
+	PathOpenClaims,
+	PathUsage,
+	PathValidate,
+	PathVerify,
+}
+
+// expandPath replaces the {name} parameters of the path with the given
+// values, in order
+func expandPath(path string, values ...interface{}) string {
+	for _, value := range values {
+		start := strings.IndexByte(path, '{')
+		end := strings.IndexByte(path, '}')
+		if start < 0 || end < start {
+			break
+		}
+		path = path[:start] + url.PathEscape(fmt.Sprint(value)) + path[end+1:]
+	}
+	return path
+}
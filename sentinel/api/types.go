@@ -0,0 +1,185 @@
+package api
+
+import (
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// Health is the body of the liveness endpoint
+type Health struct {
+	Status string `json:"status"`
+}
+
+type ReadinessCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Readiness is the body of the readiness endpoint, answered with a 503 when
+// the sentinel isn't ready
+type Readiness struct {
+	Ready       bool             `json:"ready"`
+	Height      int64            `json:"height"`
+	ChainHeight int64            `json:"chain_height"`
+	MaxBlockLag int64            `json:"max_block_lag"`
+	Checks      []ReadinessCheck `json:"checks"`
+}
+
+// Metadata is the configuration and version of the sentinel
+type Metadata struct {
+	Configuration conf.Configuration `json:"config"`
+	Version       string             `json:"version"`
+}
+
+// UpstreamStatus is the health of an upstream, as shown by the status endpoint
+type UpstreamStatus struct {
+	URL       string     `json:"url"` // without its password
+	Healthy   bool       `json:"healthy"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Status summarizes what the sentinel serves
+type Status struct {
+	ProviderPubKey          common.PubKey               `json:"provider_pubkey"`
+	Services                []string                    `json:"services"`
+	Upstreams               map[string][]UpstreamStatus `json:"upstreams"`                             // health of the upstreams of each service
+	FreeTierEnabled         bool                        `json:"free_tier_enabled"`                     // requests not paid by a contract are refused when disabled
+	FreeTierRateLimit       int                         `json:"free_tier_rate_limit"`                  // requests per minute
+	FreeTierClientRateLimit int                         `json:"free_tier_client_rate_limit,omitempty"` // requests per minute of each client of an address
+	ServiceFreeTiers        map[string]FreeTierStatus   `json:"service_free_tiers,omitempty"`          // services overriding the free tier
+	Version                 string                      `json:"version"`
+	Height                  int64                       `json:"height"`
+	EventStream             EventStreamStatus           `json:"event_stream"`
+	ContractConfigCache     ContractConfigCacheStatus   `json:"contract_config_cache"`
+	MemStore                MemStoreStatus              `json:"memstore"`
+}
+
+// FreeTierStatus is the free tier of a service overriding the global one
+type FreeTierStatus struct {
+	Enabled   bool `json:"enabled"`
+	RateLimit int  `json:"rate_limit"` // requests per minute
+}
+
+// MemStoreStatus tells how many contracts are held in memory, and how many
+// were evicted
+type MemStoreStatus struct {
+	Entries         int    `json:"entries"`
+	MaxEntries      int    `json:"max_entries,omitempty"` // zero is unbounded
+	EvictedExpired  uint64 `json:"evicted_expired"`       // past their settlement period
+	EvictedCapacity uint64 `json:"evicted_capacity"`      // least recently used expired contracts, over the max entries
+}
+
+// ContractConfigCacheStatus tells how often the contract configurations are
+// served from memory
+type ContractConfigCacheStatus struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"` // lookups read from the store
+}
+
+// EventStreamStatus tells how well the sentinel follows the chain events
+type EventStreamStatus struct {
+	Connected    bool   `json:"connected"`
+	Reconnects   uint64 `json:"reconnects"`    // connections made after the first one
+	Resyncs      uint64 `json:"resyncs"`       // contract resyncs from the chain
+	BlockGaps    uint64 `json:"block_gaps"`    // gaps larger than the block gap threshold
+	MissedBlocks int64  `json:"missed_blocks"` // blocks skipped by those gaps
+}
+
+// ContractStatus is what the sentinel knows of a contract. It doesn't carry
+// the client or delegate of the contract.
+type ContractStatus struct {
+	Id               uint64     `json:"id"`
+	Service          string     `json:"service"`
+	Type             string     `json:"type"`
+	ExpirationHeight int64      `json:"expiration_height"`
+	Expired          bool       `json:"expired"`
+	Deposit          cosmos.Int `json:"deposit"`
+	Spent            cosmos.Int `json:"spent"`
+	RemainingDeposit cosmos.Int `json:"remaining_deposit"`
+	Height           int64      `json:"height"` // current block height of the sentinel
+}
+
+// ContractState is the current state of a contract, as known by the sentinel
+type ContractState struct {
+	Contract         types.Contract `json:"contract"`
+	Height           int64          `json:"height"`            // current block height
+	ExpirationHeight int64          `json:"expiration_height"` // height after which the contract can't be used
+	Expired          bool           `json:"expired"`
+	RemainingDeposit *cosmos.Int    `json:"remaining_deposit,omitempty"` // pay-as-you-go only
+	RemainingQueries *int64         `json:"remaining_queries,omitempty"` // pay-as-you-go only
+}
+
+// Usage is the usage of a contract accounted by the sentinel, for its spender
+// to audit what it is billed
+type Usage struct {
+	ContractId       uint64       `json:"contract_id"`
+	Requests         int64        `json:"requests"`                    // paid requests served
+	Nonce            int64        `json:"nonce"`                       // nonce of the latest claim
+	RemainingDeposit *cosmos.Int  `json:"remaining_deposit,omitempty"` // pay-as-you-go only
+	ExpirationHeight int64        `json:"expiration_height"`
+	Height           int64        `json:"height"` // current block height
+	Daily            []DailyUsage `json:"daily"`  // requests of the last days, oldest first
+}
+
+// DailyUsage is the number of paid requests served on a day (UTC)
+type DailyUsage struct {
+	Date     string `json:"date"` // YYYY-MM-DD
+	Requests int64  `json:"requests"`
+}
+
+// ArkAuthValidation is the diagnosis of an arkauth, for client developers to
+// debug how they build it. The fields are filled up to the part that failed.
+type ArkAuthValidation struct {
+	Valid             bool          `json:"valid"`
+	ContractId        uint64        `json:"contract_id"`
+	Nonce             int64         `json:"nonce"`
+	Signature         string        `json:"signature,omitempty"`       // hex
+	MessageToSign     string        `json:"message_to_sign,omitempty"` // exact message the spender signs
+	Provider          common.PubKey `json:"provider"`                  // provider the arkauth is validated for
+	Spender           string        `json:"spender,omitempty"`         // pubkey the signature is checked against, when given
+	SignatureVerified bool          `json:"signature_verified"`
+	Code              ErrorCode     `json:"code,omitempty"`
+	Error             string        `json:"error,omitempty"` // why the arkauth is invalid
+}
+
+// ProviderIdentity is the answer of the sentinel to a challenge, signed with
+// the provider key, for clients to check it controls the key
+type ProviderIdentity struct {
+	Provider  common.PubKey `json:"provider"`
+	Challenge string        `json:"challenge"`
+	Height    int64         `json:"height"`
+	Signature string        `json:"signature"` // hex, over "<challenge>:<height>"
+}
+
+// Claim is the latest signed nonce of a contract, which the provider can claim
+// the income of
+type Claim struct {
+	Provider   common.PubKey `json:"provider"`
+	ContractId uint64        `json:"contract_id"`
+	Spender    common.PubKey `json:"spender"`
+	Nonce      int64         `json:"nonce"`
+	Signature  string        `json:"signature"`
+	Claimed    bool          `json:"claimed"`
+	// nonce the signature is over, when below the nonce: requests served by
+	// a session closed before its units were spent aren't signed for
+	SignedNonce int64 `json:"signed_nonce,omitempty"`
+}
+
+// Session is a paid session, opened with a single arkauth signed with the
+// highest nonce the session can spend. Its token pays for the requests in
+// place of an arkauth, until it expires or its units are spent.
+type Session struct {
+	Token      string `json:"token"`
+	ContractId uint64 `json:"contract_id"`
+	Nonce      int64  `json:"nonce"`   // nonce of the arkauth opening the session
+	Units      int64  `json:"units"`   // nonce units the session can spend
+	Used       int64  `json:"used"`    // nonce units spent
+	Expires    int64  `json:"expires"` // unix timestamp
+}
@@ -0,0 +1,127 @@
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// TestAPIClient runs the api client against the sentinel, so a response the
+// client can't decode fails the test
+func TestAPIClient(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	proxy.MemStore.SetHeight(20)
+	server := httptest.NewServer(proxy.getRouter())
+	defer server.Close()
+
+	clientKey := secp256k1.GenPrivKey()
+	spender, err := common.NewPubKeyFromCrypto(clientKey.PubKey())
+	require.NoError(t, err)
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, spender)
+	contract.Id = 1
+	proxy.MemStore.Put(contract)
+
+	ctx := context.Background()
+	client := api.NewClient(server.URL, api.WithRetries(0, 0))
+
+	health, err := client.Health(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "ok", health.Status)
+
+	status, err := client.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, testConfig.ProviderPubKey, status.ProviderPubKey)
+	require.Equal(t, int64(20), status.Height)
+
+	metadata, err := client.Metadata(ctx)
+	require.NoError(t, err)
+	require.Equal(t, testConfig.Moniker, metadata.Configuration.Moniker)
+	require.Equal(t, Version, metadata.Version)
+
+	contractStatus, err := client.ContractStatus(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, contract.Id, contractStatus.Id)
+	require.Equal(t, int64(100), contractStatus.RemainingDeposit.Int64())
+
+	state, err := client.Contract(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, contract.Id, state.Contract.Id)
+	require.False(t, state.Expired)
+
+	// a refused request is an *api.Error
+	_, err = client.ContractStatus(ctx, 999)
+	var apiErr *api.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	require.NotEmpty(t, apiErr.Response.Error)
+
+	// paid requests through the arkauth transport
+	sign := func(contractId uint64, nonce int64) ([]byte, error) {
+		return clientKey.Sign(types.GetBytesToSign(contractId, nonce))
+	}
+	paying := api.NewClient(server.URL, api.WithArkAuth(contract.Id, 0, sign))
+	for i := 0; i < 2; i++ {
+		resp, err := paying.HTTPClient().Get(server.URL + "/btc-mainnet-fullnode/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "paid", resp.Header.Get("tier"))
+		require.Equal(t, strconv.Itoa(i+1), resp.Header.Get(api.NonceHeader))
+	}
+
+	claim, err := client.Claim(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), claim.Nonce)
+	require.Equal(t, spender, claim.Spender)
+
+	claims, err := client.OpenClaims(ctx)
+	require.NoError(t, err)
+	require.Len(t, claims, 1)
+	require.Equal(t, contract.Id, claims[0].ContractId)
+
+	// the usage is read with any nonce, it isn't consumed
+	usage, err := client.Usage(ctx, contract.Id, 1, sign)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), usage.Requests)
+	require.Equal(t, int64(2), usage.Nonce)
+	require.Equal(t, int64(98), usage.RemainingDeposit.Int64())
+	require.Len(t, usage.Daily, usageHistoryDays)
+	require.Equal(t, int64(2), usage.Daily[usageHistoryDays-1].Requests)
+
+	// an arkauth is validated without being paid with
+	signature, err := sign(contract.Id, 3)
+	require.NoError(t, err)
+	validation, err := client.ValidateArkAuth(ctx, GenerateArkAuthString(contract.Id, 3, signature), spender.String())
+	require.NoError(t, err)
+	require.True(t, validation.Valid)
+	require.True(t, validation.SignatureVerified)
+	validation, err = client.ValidateArkAuth(ctx, "1:0", "")
+	require.NoError(t, err)
+	require.False(t, validation.Valid)
+	require.Equal(t, api.ErrCodeBadNonce, validation.Code)
+
+	// the unversioned paths are still served
+	for _, path := range []string{api.PathHealth, api.PathStatus, api.PathMetadata, api.PathOpenClaims} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode, path)
+	}
+}
@@ -0,0 +1,372 @@
+package sentinel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// AuditRecordVersion is bumped whenever fields are added to AuditRecord
+	AuditRecordVersion = 1
+	RequestIdHeader    = "X-Request-Id"
+)
+
+// headers captured in the audit log, enough to reconstruct the auth decision
+var auditHeaders = []string{
+	QueryArkAuth,
+	ServiceHeader,
+	forwardHeaderName,
+	xRealIPName,
+	"Content-Type",
+	"Origin",
+}
+
+// AuditRecord is a single request served by the proxy, along with the path
+// the auth middleware went through to serve (or reject) it
+type AuditRecord struct {
+	Version    int               `json:"version"`
+	RequestId  string            `json:"request_id"`
+	Time       time.Time         `json:"time"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Query      string            `json:"query"`
+	Headers    map[string]string `json:"headers"`
+	BodyHash   string            `json:"body_hash"`
+	BodySize   int               `json:"body_size"`
+	RemoteAddr string            `json:"remote_addr"`
+	Tier       string            `json:"tier"`
+	Steps      []string          `json:"steps"`
+	Code       int               `json:"code"`
+}
+
+// AuditLog appends audit records, one json document per line
+type AuditLog struct {
+	lock   sync.Mutex
+	writer io.Writer
+	closer io.Closer
+}
+
+// NewAuditLog opens the audit log at the given location, an empty location
+// disables the audit log
+func NewAuditLog(location string) (*AuditLog, error) {
+	if len(location) == 0 {
+		return nil, nil
+	}
+	f, err := os.OpenFile(location, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open audit log %s: %w", location, err)
+	}
+	return &AuditLog{writer: f, closer: f}, nil
+}
+
+func NewAuditLogWriter(w io.Writer) *AuditLog {
+	return &AuditLog{writer: w}
+}
+
+func (a *AuditLog) Write(record AuditRecord) error {
+	if a == nil {
+		return nil
+	}
+	buf, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	_, err = a.writer.Write(append(buf, '\n'))
+	return err
+}
+
+func (a *AuditLog) Close() error {
+	if a == nil || a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// ReadAuditRecord scans the audit log at the given location for the record of
+// the given request id
+func ReadAuditRecord(location, requestId string) (AuditRecord, error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return AuditRecord{}, fmt.Errorf("fail to open audit log %s: %w", location, err)
+	}
+	defer f.Close()
+	return findAuditRecord(f, requestId)
+}
+
+func findAuditRecord(r io.Reader, requestId string) (AuditRecord, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		if record.RequestId != requestId {
+			continue
+		}
+		if record.Version > AuditRecordVersion {
+			return record, fmt.Errorf("unsupported audit record version %d", record.Version)
+		}
+		return record, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return AuditRecord{}, err
+	}
+	return AuditRecord{}, fmt.Errorf("request %s not found in audit log", requestId)
+}
+
+// Request reconstructs the recorded request against the given base url (an
+// empty url keeps the request relative, to be served by a local handler).
+// When the body was retained, it must match the recorded hash, otherwise the
+// request is replayed without a body.
+func (record AuditRecord) Request(baseURL string, body []byte) (*http.Request, error) {
+	if len(body) > 0 {
+		if hash := hashBody(body); hash != record.BodyHash {
+			return nil, fmt.Errorf("body hash mismatch (%s/%s)", hash, record.BodyHash)
+		}
+	}
+	url := baseURL + record.Path
+	if len(record.Query) > 0 {
+		url = fmt.Sprintf("%s?%s", url, record.Query)
+	}
+	req, err := http.NewRequest(record.Method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range record.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set(RequestIdHeader, record.RequestId)
+	req.RemoteAddr = record.RemoteAddr
+	return req, nil
+}
+
+// DiffAuditRecords lists the differences in the outcome of two records of
+// the same request
+func DiffAuditRecords(recorded, replayed AuditRecord) []string {
+	var diffs []string
+	if recorded.Tier != replayed.Tier {
+		diffs = append(diffs, fmt.Sprintf("tier: %q -> %q", recorded.Tier, replayed.Tier))
+	}
+	if recorded.Code != replayed.Code {
+		diffs = append(diffs, fmt.Sprintf("code: %d -> %d", recorded.Code, replayed.Code))
+	}
+	if replayed.Steps == nil {
+		// steps are unknown when replayed against a remote sentinel
+		return diffs
+	}
+	for i := 0; i < len(recorded.Steps) || i < len(replayed.Steps); i++ {
+		var before, after string
+		if i < len(recorded.Steps) {
+			before = recorded.Steps[i]
+		}
+		if i < len(replayed.Steps) {
+			after = replayed.Steps[i]
+		}
+		if before != after {
+			diffs = append(diffs, fmt.Sprintf("step %d: %q -> %q", i, before, after))
+		}
+	}
+	return diffs
+}
+
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func newRequestId() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+type authTraceKey struct{}
+
+// authTrace collects the steps taken by the auth middleware
+type authTrace struct {
+	steps []string
+}
+
+func getAuthTrace(ctx context.Context) *authTrace {
+	trace, _ := ctx.Value(authTraceKey{}).(*authTrace)
+	return trace
+}
+
+func (t *authTrace) add(step string) {
+	if t == nil {
+		return
+	}
+	t.steps = append(t.steps, step)
+}
+
+// statusRecorder captures the status code written by the next handlers
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.code = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack is required to proxy websockets
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	s.code = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// audit records every request going through the auth middleware, when the
+// audit log is enabled
+func (p Proxy) audit(next http.Handler) http.Handler {
+	if p.AuditLog == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(RequestIdHeader)
+		if len(requestId) == 0 {
+			requestId = newRequestId()
+		}
+		w.Header().Set(RequestIdHeader, requestId)
+
+		record := AuditRecord{
+			Version:    AuditRecordVersion,
+			RequestId:  requestId,
+			Time:       time.Now().UTC(),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Query:      r.URL.RawQuery,
+			Headers:    make(map[string]string),
+			RemoteAddr: r.RemoteAddr,
+		}
+		for _, key := range auditHeaders {
+			if value := r.Header.Get(key); len(value) > 0 {
+				record.Headers[key] = value
+			}
+		}
+		// the body is hashed as it is read, it isn't held in memory
+		var body *hashingReader
+		if r.Body != nil {
+			body = &hashingReader{ReadCloser: r.Body, hash: sha256.New()}
+			r.Body = body
+		}
+
+		trace := &authTrace{}
+		r = r.WithContext(context.WithValue(r.Context(), authTraceKey{}, trace))
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		if body != nil {
+			// the rest of a body the handlers didn't read is hashed up to the
+			// max body size, bodies over it are rejected and only their
+			// beginning is recorded
+			if max := p.maxRequestBodyBytes(); max > 0 && body.size <= max {
+				_, _ = io.Copy(io.Discard, io.LimitReader(body, max+1-body.size))
+			}
+			record.BodyHash = hex.EncodeToString(body.hash.Sum(nil))
+			record.BodySize = int(body.size)
+		} else {
+			record.BodyHash = hashBody(nil)
+		}
+		record.Tier = w.Header().Get("tier")
+		record.Steps = trace.steps
+		record.Code = recorder.code
+		if err := p.AuditLog.Write(record); err != nil {
+			p.logger.Error("fail to write audit record", "error", err, "request_id", requestId)
+		}
+	})
+}
+
+// ReplayDryRun serves the recorded request through the handler chain of a
+// dry run proxy, and returns the record of the replay
+func (p Proxy) ReplayDryRun(record AuditRecord, body []byte) (AuditRecord, error) {
+	if !p.DryRun {
+		return AuditRecord{}, fmt.Errorf("proxy is not in dry run mode")
+	}
+	req, err := record.Request("", body)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	var buf bytes.Buffer
+	p.AuditLog = NewAuditLogWriter(&buf)
+	p.audit(p.auth(http.HandlerFunc(p.handleRequestAndRedirect))).ServeHTTP(newDiscardResponseWriter(), req)
+	return findAuditRecord(&buf, record.RequestId)
+}
+
+// ReplayAgainst sends the recorded request to the sentinel at the given url.
+// Only the outcome (tier and code) of the replay is known.
+func ReplayAgainst(baseURL string, record AuditRecord, body []byte) (AuditRecord, error) {
+	req, err := record.Request(baseURL, body)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	client := http.Client{Timeout: 30 * time.Second}
+	res, err := client.Do(req)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	defer res.Body.Close()
+	replayed := record
+	replayed.Time = time.Now().UTC()
+	replayed.Tier = res.Header.Get("tier")
+	replayed.Code = res.StatusCode
+	replayed.Steps = nil
+	return replayed, nil
+}
+
+// hashingReader hashes the body of a request as it is read
+type hashingReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	size int64
+}
+
+func (h *hashingReader) Read(b []byte) (int, error) {
+	n, err := h.ReadCloser.Read(b)
+	h.hash.Write(b[:n])
+	h.size += int64(n)
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func newDiscardResponseWriter() *discardResponseWriter {
+	return &discardResponseWriter{header: make(http.Header)}
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
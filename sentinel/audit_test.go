@@ -0,0 +1,132 @@
+package sentinel
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRecordRequest(t *testing.T) {
+	body := []byte(`{"method":"getblockcount"}`)
+	record := AuditRecord{
+		Version:   AuditRecordVersion,
+		RequestId: "abc",
+		Method:    http.MethodPost,
+		Path:      "/btc-mainnet-fullnode/",
+		Query:     "arkauth=5%3A3",
+		Headers: map[string]string{
+			ServiceHeader:  "btc-mainnet-fullnode",
+			"Content-Type": "application/json",
+		},
+		BodyHash:   hashBody(body),
+		BodySize:   len(body),
+		RemoteAddr: "10.0.0.1:4000",
+	}
+
+	req, err := record.Request("http://staging:3636", body)
+	require.NoError(t, err)
+	require.Equal(t, http.MethodPost, req.Method)
+	require.Equal(t, "http://staging:3636/btc-mainnet-fullnode/?arkauth=5%3A3", req.URL.String())
+	require.Equal(t, "5:3", req.URL.Query().Get(QueryArkAuth))
+	require.Equal(t, "btc-mainnet-fullnode", req.Header.Get(ServiceHeader))
+	require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	require.Equal(t, "abc", req.Header.Get(RequestIdHeader))
+	require.Equal(t, "10.0.0.1:4000", req.RemoteAddr)
+
+	// body not retained
+	req, err = record.Request("", nil)
+	require.NoError(t, err)
+	require.Equal(t, "/btc-mainnet-fullnode/?arkauth=5%3A3", req.URL.String())
+
+	// body doesn't match the recorded one
+	_, err = record.Request("", []byte("something else"))
+	require.Error(t, err)
+
+	// newer schema versions are rejected
+	var buf bytes.Buffer
+	record.Version = AuditRecordVersion + 1
+	require.NoError(t, NewAuditLogWriter(&buf).Write(record))
+	_, err = findAuditRecord(&buf, record.RequestId)
+	require.Error(t, err)
+}
+
+func TestAuditAndReplay(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	newProxy := func() Proxy {
+		proxy := NewProxy(newTestConfig())
+		setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+		contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+		contract.Authorization = types.ContractAuthorization_OPEN
+		contract.Id = 5
+		proxy.MemStore.Put(contract)
+		return proxy
+	}
+
+	var buf bytes.Buffer
+	proxy := newProxy()
+	proxy.AuditLog = NewAuditLogWriter(&buf)
+	router := proxy.getRouter()
+
+	// serve a paid request
+	req, err := http.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/?arkauth=5:3", nil)
+	require.NoError(t, err)
+	req.RemoteAddr = "10.0.0.2:4000"
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusOK, response.Code)
+	requestId := response.Header().Get(RequestIdHeader)
+	require.NotEmpty(t, requestId)
+	require.Equal(t, int64(3), proxy.ClaimStore.List()[0].Nonce)
+
+	record, err := findAuditRecord(bytes.NewReader(buf.Bytes()), requestId)
+	require.NoError(t, err)
+	require.Equal(t, AuditRecordVersion, record.Version)
+	require.Equal(t, "paid", record.Tier)
+	require.Equal(t, http.StatusOK, record.Code)
+	require.Equal(t, []string{"paid:authorized", "paid:served"}, record.Steps)
+	require.Equal(t, "10.0.0.2:4000", record.RemoteAddr)
+
+	// the body is hashed as it is proxied
+	body := []byte(`{"jsonrpc":"2.0","id":1,"method":"getblockcount"}`)
+	req, err = http.NewRequest(http.MethodPost, "/btc-mainnet-fullnode/?arkauth=5:4", bytes.NewReader(body))
+	require.NoError(t, err)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusOK, response.Code)
+	posted, err := findAuditRecord(bytes.NewReader(buf.Bytes()), response.Header().Get(RequestIdHeader))
+	require.NoError(t, err)
+	require.Equal(t, hashBody(body), posted.BodyHash)
+	require.Equal(t, len(body), posted.BodySize)
+
+	// replay in a fresh dry run proxy takes the same path, without writing claims
+	replayProxy := newProxy()
+	replayProxy.DryRun = true
+	replayed, err := replayProxy.ReplayDryRun(record, nil)
+	require.NoError(t, err)
+	require.Empty(t, DiffAuditRecords(record, replayed))
+	require.Empty(t, replayProxy.ClaimStore.List())
+	contract, err := replayProxy.MemStore.Get("5")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), contract.Nonce)
+
+	// replay against the proxy that served it, the nonce is already consumed
+	proxy.DryRun = true
+	replayed, err = proxy.ReplayDryRun(record, nil)
+	require.NoError(t, err)
+	diffs := DiffAuditRecords(record, replayed)
+	require.Contains(t, diffs, `tier: "paid" -> "free"`)
+	require.Contains(t, diffs, `step 1: "paid:served" -> "paid:rejected:400"`)
+
+	// without an audit log requests aren't recorded
+	response = httptest.NewRecorder()
+	newProxy().getRouter().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/?arkauth=5:1", nil))
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Empty(t, response.Header().Get(RequestIdHeader))
+}
@@ -1,8 +1,16 @@
 package sentinel
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,17 +19,41 @@ import (
 
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 
 	"golang.org/x/time/rate"
 )
 
 const (
-	QueryArkAuth  = "arkauth"
-	QueryContract = "arkcontract"
+	QueryArkAuth  = api.ArkAuthHeader
+	QueryContract = api.QueryContract
+	QueryAdmin    = "arkadmin"
 	ServiceHeader = "arkservice"
+
+	// free tier quota headers
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	UpgradeHeader            = "X-Arkeo-Upgrade"
+	RetryAfterHeader         = "Retry-After"
+
+	// height at which the block quota of a subscription resets
+	QuotaResetHeader = "X-Ark-Quota-Reset"
+	// highest nonce paid for on the contract, sent on paid responses for
+	// clients to resynchronize their nonce
+	NonceHeader = api.NonceHeader
+
+	// max difference between the timestamp of an admin auth and now
+	adminAuthMaxAge = 5 * time.Minute
+	// max size of the body of an admin request, hashed to check its signature
+	adminMaxBodyBytes = 1 << 20
+
+	// algorithms of the client keys signing contract auths
+	SignatureAlgoSecp256k1 = "secp256k1"
+	SignatureAlgoEd25519   = "ed25519"
 )
 
+var errBlockQuotaExceeded = newProxyError(ErrCodeBlockQuotaExceeded, "subscription block quota exceeded")
+
 // Create a map to hold the rate limiters for each visitor and a mutex.
 var (
 	visitors = make(map[string]*rate.Limiter)
@@ -32,6 +64,16 @@ type ContractAuth struct {
 	ContractId uint64
 	Timestamp  int64
 	Signature  []byte
+	// Algorithm of the signing key (optional), to tell signatures made with
+	// a key of the wrong curve apart from invalid ones
+	Algorithm string
+}
+
+// AdminAuth authenticates the provider on admin endpoints, by signing the
+// current unix timestamp with the provider key
+type AdminAuth struct {
+	Timestamp int64
+	Signature []byte
 }
 
 type ArkAuth struct {
@@ -47,18 +89,34 @@ func (aa ArkAuth) String() string {
 }
 
 func GenerateArkAuthString(contractId uint64, nonce int64, signature []byte) string {
-	return fmt.Sprintf("%s:%s", GenerateMessageToSign(contractId, nonce), hex.EncodeToString(signature))
+	return api.ArkAuthString(contractId, nonce, signature)
 }
 
 func GenerateMessageToSign(contractId uint64, nonce int64) string {
 	return fmt.Sprintf("%d:%d", contractId, nonce)
 }
 
+// GenerateDomainMessageToSign returns the message signed for a provider
+// requiring the signing domain, bound to its chain and pubkey
+func GenerateDomainMessageToSign(chainId string, provider common.PubKey, contractId uint64, nonce int64) string {
+	return string(types.GetDomainBytesToSign(chainId, provider, contractId, nonce))
+}
+
+// signingDomain returns the domain the signatures of the arkauths are checked
+// against
+func (p Proxy) signingDomain() types.SigningDomain {
+	return types.SigningDomain{
+		ChainId:  p.Config.ChainId,
+		Provider: p.Config.ProviderPubKey,
+		Required: p.config().SignatureDomain,
+	}
+}
+
 func parseContractAuth(raw string) (ContractAuth, error) {
 	var auth ContractAuth
 	var err error
 
-	parts := strings.SplitN(raw, ":", 3)
+	parts := strings.SplitN(raw, ":", 4)
 
 	if len(parts) > 0 {
 		auth.ContractId, err = strconv.ParseUint(parts[0], 10, 64)
@@ -80,9 +138,50 @@ func parseContractAuth(raw string) (ContractAuth, error) {
 			return auth, err
 		}
 	}
+
+	if len(parts) > 3 {
+		auth.Algorithm = strings.ToLower(parts[3])
+	}
 	return auth, nil
 }
 
+func parseAdminAuth(raw string) (AdminAuth, error) {
+	var auth AdminAuth
+	var err error
+
+	parts := strings.SplitN(raw, ":", 2)
+	if len(parts) != 2 {
+		return auth, fmt.Errorf("admin auth must be formatted as timestamp:signature")
+	}
+
+	auth.Timestamp, err = strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return auth, err
+	}
+
+	auth.Signature, err = hex.DecodeString(parts[1])
+	if err != nil {
+		return auth, err
+	}
+	return auth, nil
+}
+
+// GenerateAdminMessageToSign returns the message the provider key signs for
+// an admin request: its method, its path with the query, the hex sha256 of
+// its body and the timestamp, so the signature can't be used for another
+// request
+func GenerateAdminMessageToSign(method, uri string, body []byte, timestamp int64) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf("admin:%s:%s:%s:%d", method, uri, hex.EncodeToString(sum[:]), timestamp)
+}
+
+// errors of the parts of an arkauth failing to parse
+var (
+	errArkAuthContractId = errors.New("bad contract id")
+	errArkAuthNonce      = errors.New("bad nonce")
+	errArkAuthSignature  = errors.New("bad signature, must be hex encoded")
+)
+
 func parseArkAuth(raw string) (ArkAuth, error) {
 	var aa ArkAuth
 	var err error
@@ -92,21 +191,21 @@ func parseArkAuth(raw string) (ArkAuth, error) {
 	if len(parts) > 0 {
 		aa.ContractId, err = strconv.ParseUint(parts[0], 10, 64)
 		if err != nil {
-			return aa, err
+			return aa, fmt.Errorf("%w: %s", errArkAuthContractId, err)
 		}
 	}
 
 	if len(parts) > 1 {
 		aa.Nonce, err = strconv.ParseInt(parts[1], 10, 64)
 		if err != nil {
-			return aa, err
+			return aa, fmt.Errorf("%w: %s", errArkAuthNonce, err)
 		}
 	}
 
 	if len(parts) > 2 {
 		aa.Signature, err = hex.DecodeString(parts[2])
 		if err != nil {
-			return aa, err
+			return aa, fmt.Errorf("%w: %s", errArkAuthSignature, err)
 		}
 	}
 	return aa, nil
@@ -126,14 +225,23 @@ func (auth ContractAuth) Validate(lastTimestamp int64, client common.PubKey) err
 	if auth.ContractId == 0 {
 		return fmt.Errorf("contract id cannot be zero")
 	}
-	if auth.Timestamp <= lastTimestamp {
-		return fmt.Errorf("timestamp must be larger than %d", lastTimestamp)
+	if err := validateTimestamp(auth.Timestamp, lastTimestamp); err != nil {
+		return err
 	}
 
 	pk, err := cosmos.GetPubKeyFromBech32(cosmos.Bech32PubKeyTypeAccPub, client.String())
 	if err != nil {
 		return err
 	}
+	// both curves sign 64 bytes signatures, the algorithm of the client key
+	// decides how to verify it
+	algo := pk.Type()
+	if algo != SignatureAlgoSecp256k1 && algo != SignatureAlgoEd25519 {
+		return fmt.Errorf("unsupported signature algorithm: %s", algo)
+	}
+	if len(auth.Algorithm) > 0 && auth.Algorithm != algo {
+		return fmt.Errorf("unsupported signature algorithm: signed with %s, but the contract client key is %s", auth.Algorithm, algo)
+	}
 	msg := fmt.Sprintf("%d:%d", auth.ContractId, auth.Timestamp)
 	if !pk.VerifySignature([]byte(msg), auth.Signature) {
 		return fmt.Errorf("invalid signature")
@@ -142,6 +250,32 @@ func (auth ContractAuth) Validate(lastTimestamp int64, client common.PubKey) err
 	return nil
 }
 
+// validateTimestamp refuses an auth whose timestamp isn't larger than the one
+// of the last auth accepted, so a signed auth can't be replayed
+func validateTimestamp(timestamp, lastTimestamp int64) error {
+	if timestamp <= lastTimestamp {
+		return fmt.Errorf("timestamp must be larger than %d", lastTimestamp)
+	}
+	return nil
+}
+
+func (auth AdminAuth) Validate(now time.Time, provider common.PubKey, method, uri string, body []byte) error {
+	age := now.Sub(time.Unix(auth.Timestamp, 0))
+	if age > adminAuthMaxAge || age < -adminAuthMaxAge {
+		return fmt.Errorf("timestamp is too far from the current time")
+	}
+
+	pk, err := cosmos.GetPubKeyFromBech32(cosmos.Bech32PubKeyTypeAccPub, provider.String())
+	if err != nil {
+		return err
+	}
+	if !pk.VerifySignature([]byte(GenerateAdminMessageToSign(method, uri, body, auth.Timestamp)), auth.Signature) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	return nil
+}
+
 func (auth ContractAuth) String() string {
 	sig := hex.EncodeToString(auth.Signature)
 	return fmt.Sprintf("Contract Id: %d, Timestamp: %d, Signature: %s", auth.ContractId, auth.Timestamp, sig)
@@ -167,6 +301,139 @@ func (p Proxy) fetchArkAuth(r *http.Request) (aa ArkAuth, err error) {
 	return aa, nil
 }
 
+// arkAuthError returns why the arkauth can't pay for requests to this
+// provider, nil when it can. The contract is the one of the arkauth, fetchErr
+// the error fetching it.
+func (p Proxy) arkAuthError(aa ArkAuth, contract types.Contract, fetchErr error) error {
+	if fetchErr != nil {
+		// an expired contract is fetched again from the chain, the copy held
+		// in memory still tells why it can't pay
+		held, ok := p.MemStore.Peek(strconv.FormatUint(aa.ContractId, 10))
+		if !ok {
+			return newProxyError(ErrCodeUnknownContract, "unknown contract %d", aa.ContractId)
+		}
+		contract = held
+	}
+	if contract.Client.IsEmpty() {
+		return newProxyError(ErrCodeUnknownContract, "unknown contract %d", aa.ContractId)
+	}
+	if !contract.Provider.Equals(p.Config.ProviderPubKey) {
+		return newProxyError(ErrCodeProviderMismatch, "contract %d is with provider %s, not with this one", aa.ContractId, contract.Provider)
+	}
+	if contract.IsExpired(p.MemStore.GetHeight()) {
+		return newProxyError(ErrCodeContractExpired, "contract %d expired at height %d", aa.ContractId, contract.Expiration())
+	}
+	if contract.IsOpenAuthorization() {
+		return nil
+	}
+	if err := aa.Validate(p.Config.ProviderPubKey); err != nil {
+		return newProxyError(ErrCodeBadSignature, "%w", err)
+	}
+	if _, ok := p.arkAuthSpender(aa, contract); !ok {
+		if err := p.rotatedDelegateError(aa, contract); err != nil {
+			return err
+		}
+		if len(contract.Spenders) > 0 {
+			return newProxyError(ErrCodeUnlistedSpender, "invalid signature, not made by a spender of contract %d", aa.ContractId)
+		}
+		return newProxyError(ErrCodeBadSignature, "invalid signature, not made by the client of contract %d", aa.ContractId)
+	}
+	return nil
+}
+
+// arkAuthSpender returns the spender of the contract that signed the arkauth,
+// false when none of them did
+func (p Proxy) arkAuthSpender(aa ArkAuth, contract types.Contract) (common.PubKey, bool) {
+	for _, spender := range contract.AllowedSpenders() {
+		pk, err := cosmos.GetPubKeyFromBech32(cosmos.Bech32PubKeyTypeAccPub, spender.String())
+		if err != nil {
+			continue
+		}
+		if p.signingDomain().Verify(pk, aa.ContractId, aa.Nonce, aa.Signature) {
+			return spender, true
+		}
+	}
+	return common.EmptyPubKey, false
+}
+
+// unlistedSpenderError returns an error when the contract is restricted to a
+// list of spenders and the arkauth isn't signed by any of them, nil otherwise.
+// The signature is checked even when auth isn't strict, the contract can't be
+// used by anybody else.
+func (p Proxy) unlistedSpenderError(aa ArkAuth, contract types.Contract) error {
+	if len(contract.Spenders) == 0 || contract.IsOpenAuthorization() {
+		return nil
+	}
+	if err := aa.Validate(p.Config.ProviderPubKey); err != nil {
+		return newProxyError(ErrCodeBadSignature, "%w", err)
+	}
+	if _, ok := p.arkAuthSpender(aa, contract); !ok {
+		return newProxyError(ErrCodeUnlistedSpender, "invalid signature, not made by a spender of contract %d", aa.ContractId)
+	}
+	return nil
+}
+
+// rotatedDelegateError returns an error when the arkauth is signed by a
+// delegate the client rotated out of the contract, nil otherwise. The chain
+// refuses the claims it signs, the request can't be paid for.
+func (p Proxy) rotatedDelegateError(aa ArkAuth, contract types.Contract) error {
+	if contract.IsOpenAuthorization() || len(aa.Signature) == 0 {
+		return nil
+	}
+	for _, delegate := range p.MemStore.RotatedDelegates(aa.ContractId) {
+		// the client may have rotated back to it
+		if delegate.Equals(contract.GetSpender()) {
+			continue
+		}
+		pk, err := cosmos.GetPubKeyFromBech32(cosmos.Bech32PubKeyTypeAccPub, delegate.String())
+		if err != nil {
+			continue
+		}
+		if p.signingDomain().Verify(pk, aa.ContractId, aa.Nonce, aa.Signature) {
+			return newProxyError(ErrCodeDelegateRotated, "signed by %s, no longer the delegate of contract %d, requests must be signed by %s", delegate, aa.ContractId, contract.GetSpender())
+		}
+	}
+	return nil
+}
+
+// adminAuth only lets through requests signed by the provider, once: a
+// signature already accepted is refused
+func (p Proxy) adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		raw := r.Header.Get(QueryAdmin)
+		if len(raw) == 0 {
+			respondWithError(w, "missing admin auth", http.StatusUnauthorized)
+			return
+		}
+		auth, err := parseAdminAuth(raw)
+		if err != nil {
+			p.logger.Error("fail to parse admin auth", "error", err)
+			respondWithError(w, fmt.Sprintf("bad admin auth: %s", err), http.StatusBadRequest)
+			return
+		}
+		var body []byte
+		if r.Body != nil {
+			body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, adminMaxBodyBytes))
+			if err != nil {
+				respondWithError(w, fmt.Sprintf("bad admin request body: %s", err), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		if err := auth.Validate(time.Now(), p.Config.ProviderPubKey, r.Method, r.URL.RequestURI(), body); err != nil {
+			p.logger.Error("fail to validate admin auth", "error", err)
+			respondWithError(w, fmt.Sprintf("bad admin auth: %s", err), http.StatusUnauthorized)
+			return
+		}
+		if err := p.adminReplay.accept(auth); err != nil {
+			p.logger.Error("fail to validate admin auth", "error", err)
+			respondWithError(w, fmt.Sprintf("bad admin auth: %s", err), http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
 func (p Proxy) auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// since OPTIONS requests do not include query args, we cannot know
@@ -185,114 +452,377 @@ func (p Proxy) auth(next http.Handler) http.Handler {
 			return
 		}
 
+		trace := getAuthTrace(r.Context())
+		remoteAddr := p.getRemoteAddr(r)
+		if banErr := p.ipBanError(remoteAddr); banErr != nil {
+			trace.add("ip:banned")
+			respondWithProxyError(w, http.StatusForbidden, banErr)
+			return
+		}
+		// a session token pays for the request in place of an arkauth
+		if token := sessionToken(r); len(token) > 0 {
+			p.serveSession(w, r, next, token, remoteAddr)
+			return
+		}
 		aa, err := p.fetchArkAuth(r)
 		if err != nil {
+			trace.add("arkauth:invalid")
 			p.logger.Error("failed to parse ark auth", "error", err)
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respondWithProxyError(w, http.StatusBadRequest, newProxyError(ErrCodeBadArkAuth, "%w", err))
 			return
 		}
-		remoteAddr := p.getRemoteAddr(r)
 		var contract types.Contract
 		if aa.ContractId > 0 {
 			contract, err = p.MemStore.Get(strconv.FormatUint(aa.ContractId, 10))
 			if err != nil {
+				trace.add("contract:not_found")
 				p.logger.Error("failed to fetch contract", "error", err)
 			}
+			if info := getAccessInfo(r.Context()); info != nil {
+				info.contractId = aa.ContractId
+				info.nonce = aa.Nonce
+				if !contract.Client.IsEmpty() {
+					info.spender = contract.GetSpender().String()
+				}
+			}
+		}
+		// an arkauth signed by a rotated out delegate is refused on both tiers,
+		// so its client learns to sign with the new one
+		if aa.ContractId > 0 && err == nil {
+			if rotatedErr := p.rotatedDelegateError(aa, contract); rotatedErr != nil {
+				trace.add("arkauth:delegate_rotated")
+				p.logger.Error("refused ark auth", "error", rotatedErr, "contract_id", aa.ContractId)
+				respondWithAuthError(w, rotatedErr, p.Config.ProviderPubKey)
+				return
+			}
+			if spenderErr := p.unlistedSpenderError(aa, contract); spenderErr != nil {
+				trace.add("arkauth:unlisted_spender")
+				p.logger.Error("refused ark auth", "error", spenderErr, "contract_id", aa.ContractId)
+				respondWithAuthError(w, spenderErr, p.Config.ProviderPubKey)
+				return
+			}
+		}
+		// in strict mode, an arkauth that can't pay for the request is refused
+		// rather than served on the free tier
+		if aa.ContractId > 0 && p.config().StrictAuth {
+			if authErr := p.arkAuthError(aa, contract, err); authErr != nil {
+				trace.add("arkauth:rejected")
+				p.logger.Error("refused ark auth", "error", authErr, "contract_id", aa.ContractId)
+				respondWithAuthError(w, authErr, p.Config.ProviderPubKey)
+				return
+			}
+		}
+		// requests for services this sentinel doesn't serve, or that the
+		// contract doesn't pay for, are refused on both tiers
+		if httpCode, reason, err := p.serviceError(r, contract); err != nil {
+			trace.add(reason)
+			respondWithProxyError(w, httpCode, err)
+			return
 		}
 		// collect contract configuration
+		var pricing map[string]int64
+		var dailySpendCapUSD float64
 		if !contract.Client.IsEmpty() {
 			conf, err := p.ContractConfigStore.Get(contract.Id)
 			if err != nil {
 				p.logger.Error("failed to fetch contract configuration", "error", err)
 			}
+			pricing = conf.Pricing
+			dailySpendCapUSD = conf.DailySpendCapUSD
 			w = p.enableCORS(w, conf.CORs)
 
-			// enfore IP Whitelist
-			if len(conf.WhitelistIPAddresses) > 0 {
-				// TODO: using a map would be faster than iterating over a slice
-				found := false
-				for _, ip := range conf.WhitelistIPAddresses {
-					if strings.EqualFold(remoteAddr, ip) {
-						found = true
-					}
-				}
-				if !found {
-					http.Error(w, "Forbidden", http.StatusForbidden)
-					return
-				}
-			}
-
-			if conf.PerUserRateLimit > 0 {
-				if ok := p.isRateLimited(contract.Id, remoteAddr, conf.PerUserRateLimit); ok {
-					http.Error(w, http.StatusText(429), http.StatusTooManyRequests)
-					return
-				}
+			if httpCode, reason, err := p.contractConfigError(conf, contract.Id, remoteAddr); err != nil {
+				trace.add(reason)
+				respondWithProxyError(w, httpCode, err)
+				return
 			}
 		}
 
+		// reason the paid tier refused the request, reported if the free tier
+		// refuses it as well
+		var paidErr error
 		if err == nil && (contract.IsOpenAuthorization() || aa.Validate(p.Config.ProviderPubKey) == nil) {
+			trace.add("paid:authorized")
 			p.logger.Info("serving paid requests", "remote-addr", remoteAddr)
 			w.Header().Set("tier", "paid")
 
-			// ensure service of the contract matches first item in the path
-			serviceName := r.Header.Get(ServiceHeader)
-			if len(serviceName) == 0 {
-				parts := strings.Split(r.URL.Path, "/")
-				serviceName = parts[1]
-			}
-			ser, err := common.NewService(serviceName)
-			if err != nil || ser != contract.Service {
-				http.Error(w, fmt.Sprintf("contract service doesn't match the serivce name in the path: (%d/%d)", ser, contract.Service), http.StatusUnauthorized)
-				return
-			}
-
-			httpCode, err := p.paidTier(aa, remoteAddr)
+			pay, httpCode, err := p.reservePaidTier(aa, remoteAddr, p.requestCost(r, pricing, contract.MethodRates), dailySpendCapUSD)
 			// paidTier can serve the request
 			if err == nil {
-				next.ServeHTTP(w, r)
+				release, limitErr := p.acquireInFlight(requestServiceName(r), contract.Id)
+				if limitErr != nil {
+					trace.add("paid:too_many_in_flight")
+					pay.release()
+					respondWithProxyError(w, http.StatusTooManyRequests, limitErr)
+					return
+				}
+				// released even when the handler panics
+				defer release()
+				trace.add("paid:served")
+				if p.checkSpendVelocity(aa.ContractId, aa.Nonce, time.Now()) {
+					w.Header().Set(SpendAlertHeader, "high")
+				}
+				// the request is only paid for once answered, the nonce of a
+				// failed request can be used again
+				recorder := &paymentRecorder{ResponseWriter: w}
+				recorder.settle = func(code int) {
+					if code < http.StatusBadRequest {
+						if err := pay.commit(); err != nil {
+							p.logger.Error("fail to commit paid request", "error", err, "contract_id", aa.ContractId)
+						}
+					} else {
+						trace.add(fmt.Sprintf("paid:released:%d", code))
+						pay.release()
+					}
+					if nonce, err := p.paidNonce(aa.ContractId); err == nil {
+						w.Header().Set(NonceHeader, strconv.FormatInt(nonce, 10))
+					}
+				}
+				// a panicking handler didn't answer
+				defer recorder.settleOnce(http.StatusInternalServerError)
+				r = r.WithContext(context.WithValue(r.Context(), contractIdKey{}, contract.Id))
+				next.ServeHTTP(recorder, r)
+				// nothing written is answered with a 200
+				recorder.settleOnce(http.StatusOK)
+				return
+			}
+			if nonce, err := p.paidNonce(aa.ContractId); err == nil {
+				w.Header().Set(NonceHeader, strconv.FormatInt(nonce, 10))
+			}
+			if errors.Is(err, errBlockQuotaExceeded) {
+				trace.add("paid:quota_exceeded")
+				w.Header().Set(QuotaResetHeader, strconv.FormatInt(p.MemStore.GetHeight()+1, 10))
+				respondWithProxyError(w, httpCode, err)
 				return
 			}
+			trace.add(fmt.Sprintf("paid:rejected:%d", httpCode))
 			p.logger.Error("failed to serve paid tier request", "error", err, "http_code", httpCode)
+			paidErr = err
+		}
+
+		serviceName := requestServiceName(r)
+		if httpCode, err := p.freeTierDisabledError(serviceName, paidErr); err != nil {
+			trace.add("free:disabled")
+			if upgrade := p.upgradeURL(serviceName); httpCode == http.StatusUnauthorized && len(upgrade) > 0 {
+				w.Header().Set(UpgradeHeader, upgrade)
+			}
+			respondWithProxyError(w, httpCode, err)
+			return
 		}
 
 		p.logger.Info("serving free tier requests", "remote-addr", remoteAddr)
 		w.Header().Set("tier", "free")
-		httpCode, err := p.freeTier(remoteAddr)
+		remaining, retryAfter, httpCode, err := p.freeTierQuota(serviceName, remoteAddr, p.freeTierClient(w, r, remoteAddr))
+		p.setFreeTierHeaders(w, r, remaining, retryAfter)
 		if err != nil {
+			trace.add(fmt.Sprintf("free:rejected:%d", httpCode))
 			p.logger.Error("failed to serve free tier request", "error", err)
-			http.Error(w, err.Error(), httpCode)
+			if paidErr != nil {
+				err = paidErr
+			}
+			respondWithProxyError(w, httpCode, err)
 			return
 		}
+		trace.add("free:served")
 		next.ServeHTTP(w, r)
 	})
 }
 
+// requestServiceName returns the service a request is for, told by its
+// arkservice header or else the first element of its path
+func requestServiceName(r *http.Request) string {
+	if serviceName := r.Header.Get(ServiceHeader); len(serviceName) > 0 {
+		return serviceName
+	}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) > 1 {
+		return parts[1]
+	}
+	return ""
+}
+
+// serviceError returns why the service of the request can't be served: the
+// sentinel doesn't proxy it, or the contract of the request, when known,
+// isn't for it. The reason is traced.
+func (p Proxy) serviceError(r *http.Request, contract types.Contract) (int, string, error) {
+	serviceName := requestServiceName(r)
+	ser, err := common.NewService(serviceName)
+	if err != nil || len(p.config().proxies[serviceName]) == 0 {
+		return http.StatusBadRequest, "service:unknown", newProxyError(ErrCodeUnknownService, "service %q is not served by this provider", serviceName)
+	}
+	if !contract.Client.IsEmpty() && !contract.HasService(ser) {
+		return http.StatusUnauthorized, "service:mismatch", newProxyError(ErrCodeServiceMismatch, "contract service doesn't match the service name in the path: (%d/%d)", ser, contract.Service)
+	}
+	return http.StatusOK, "", nil
+}
+
+// contractConfigError returns why the configuration of the contract refuses
+// a request of the remote address, with the reason traced
+func (p Proxy) contractConfigError(conf ContractConfiguration, contractId uint64, remoteAddr string) (int, string, error) {
+	// enfore IP Whitelist
+	if len(conf.WhitelistIPAddresses) > 0 {
+		// TODO: using a map would be faster than iterating over a slice
+		found := false
+		for _, ip := range conf.WhitelistIPAddresses {
+			if strings.EqualFold(remoteAddr, ip) {
+				found = true
+			}
+		}
+		if !found {
+			return http.StatusForbidden, "contract_config:ip_not_whitelisted", newProxyError(ErrCodeIPNotWhitelisted, "Forbidden")
+		}
+	}
+
+	if conf.PerUserRateLimit > 0 {
+		if ok := p.isUserRateLimited(contractId, remoteAddr, conf.PerUserRateLimit, conf.PerUserBurstSize); ok {
+			return http.StatusTooManyRequests, "contract_config:user_rate_limited", newProxyError(ErrCodeUserRateLimited, "%s", http.StatusText(429))
+		}
+	}
+	return http.StatusOK, "", nil
+}
+
 const (
 	forwardHeaderName = `X-Forwarded-For`
 	xRealIPName       = `X-Real-Ip`
 )
 
+// getRemoteAddr returns the ip of the client, without its port, as used to
+// key rate limits and match whitelists
 func (p Proxy) getRemoteAddr(r *http.Request) string {
-	realIP := r.Header.Get(xRealIPName)
-	if realIP != "" {
-		return realIP
+	return remoteIP(r.Header.Get(xRealIPName), r.Header.Get(forwardHeaderName), r.RemoteAddr, p.config().TrustedProxyHops)
+}
+
+// remoteIP picks the ip of the client from the X-Real-Ip header, the
+// X-Forwarded-For chain or the address of the connection, in that order. The
+// chain is appended to by each proxy, with trusted proxy hops in front of the
+// sentinel the client is the entry the outermost of them received, the
+// left-most entry otherwise.
+func remoteIP(realIP, forwarded, addr string, trustedHops int) string {
+	if realIP = strings.TrimSpace(realIP); realIP != "" {
+		return stripPort(realIP)
+	}
+	var hops []string
+	for _, hop := range strings.Split(forwarded, ",") {
+		if hop = strings.TrimSpace(hop); hop != "" {
+			hops = append(hops, hop)
+		}
 	}
-	forwardIP := r.Header.Get(forwardHeaderName)
-	if forwardIP != "" {
-		return forwardIP
+	if len(hops) > 0 {
+		i := 0
+		if trustedHops > 0 && trustedHops <= len(hops) {
+			i = len(hops) - trustedHops
+		}
+		return stripPort(hops[i])
 	}
-	return r.RemoteAddr
+	return stripPort(addr)
 }
 
-func (p Proxy) freeTier(remoteAddr string) (int, error) {
-	if ok := p.isRateLimited(0, remoteAddr, p.Config.FreeTierRateLimit); ok {
-		return http.StatusTooManyRequests, fmt.Errorf(http.StatusText(429))
+// stripPort removes the port of an address, and the brackets of an ipv6 one
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
 	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
 
-	return http.StatusOK, nil
+func (p Proxy) freeTier(serviceName, remoteAddr string) (int, error) {
+	_, _, code, err := p.freeTierQuota(serviceName, remoteAddr, "")
+	return code, err
+}
+
+// freeTierDisabledError returns the status and the error refusing a request
+// the paid tier didn't serve when the free tier of its service is disabled,
+// nil when it is enabled. The reason the paid tier refused the request is
+// reported when there is one. Disabled for every service the request is
+// answered with a 402, disabled for its service only with a 401 hinting to
+// open a contract. A free tier enabled with a zero rate limit refuses the
+// requests as rate limited instead.
+func (p Proxy) freeTierDisabledError(serviceName string, paidErr error) (int, error) {
+	config := p.config()
+	if config.FreeTier(serviceName).Enabled {
+		return http.StatusOK, nil
+	}
+	code := http.StatusPaymentRequired
+	err := newProxyError(ErrCodeFreeTierDisabled, "free tier is disabled, requests must be paid by a contract")
+	if _, ok := config.ServiceFreeTiers[serviceName]; ok {
+		code = http.StatusUnauthorized
+		err = newProxyError(ErrCodeFreeTierDisabled, "free tier of %s is disabled, open a contract with provider %s to use it", serviceName, config.ProviderPubKey)
+	}
+	if paidErr != nil {
+		return code, paidErr
+	}
+	return code, err
+}
+
+// freeTierQuota serves a free tier request of the service, and returns the
+// number of requests the remote address has left. A client told apart from
+// the others of the address gets its own allowance, the allowance of the
+// address still applies to all of them. A service overriding the free tier
+// has its own allowance as well. A rejected request is told how long to wait
+// before its next request can be served.
+func (p Proxy) freeTierQuota(serviceName, remoteAddr, client string) (remaining int, retryAfter time.Duration, code int, err error) {
+	config := p.config()
+	remaining = math.MaxInt
+	if len(client) > 0 {
+		var limited bool
+		limited, remaining, retryAfter = p.rateLimitRemaining(0, fmt.Sprintf("%s-client-%s", remoteAddr, client), config.FreeTierClientRateLimit)
+		if limited {
+			return remaining, retryAfter, http.StatusTooManyRequests, newProxyError(ErrCodeFreeTierRateLimited, "%s", http.StatusText(429))
+		}
+	}
+
+	key := remoteAddr
+	if _, ok := config.ServiceFreeTiers[serviceName]; ok {
+		key = fmt.Sprintf("%s-service-%s", remoteAddr, serviceName)
+	}
+	limited, addrRemaining, retryAfter := p.rateLimitRemaining(0, key, config.FreeTier(serviceName).RateLimit)
+	if addrRemaining < remaining {
+		remaining = addrRemaining
+	}
+	if limited {
+		return remaining, retryAfter, http.StatusTooManyRequests, newProxyError(ErrCodeFreeTierRateLimited, "%s", http.StatusText(429))
+	}
+
+	return remaining, 0, http.StatusOK, nil
+}
+
+// setFreeTierHeaders tells free tier clients how many requests they have left
+// and, once they are about to run out, where to find the services and rates of
+// the provider to open a contract. A rejected client is told when to retry.
+func (p Proxy) setFreeTierHeaders(w http.ResponseWriter, r *http.Request, remaining int, retryAfter time.Duration) {
+	config := p.config()
+	if retryAfter > 0 {
+		w.Header().Set(RetryAfterHeader, strconv.Itoa(retryAfterSeconds(retryAfter)))
+	}
+	if config.FreeTierRemainingHeader {
+		w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(remaining))
+	}
+	if config.FreeTierUpgradeThreshold > 0 && remaining < config.FreeTierUpgradeThreshold {
+		if upgrade := p.upgradeURL(requestServiceName(r)); len(upgrade) > 0 {
+			w.Header().Set(UpgradeHeader, upgrade)
+		}
+	}
+}
+
+// upgradeURL returns where the rates of the provider for the service are
+// found, to open a contract. It is empty for an unknown service.
+func (p Proxy) upgradeURL(serviceName string) string {
+	if _, err := common.NewService(serviceName); err != nil {
+		return ""
+	}
+	config := p.config()
+	return fmt.Sprintf("%s/arkeo/provider/%s/%s", strings.TrimSuffix(config.SourceChain, "/"), config.ProviderPubKey, serviceName)
 }
 
 func (p Proxy) isRateLimited(contractId uint64, key string, limitTokens int) bool {
+	limited, _, _ := p.rateLimitRemaining(contractId, key, limitTokens)
+	return limited
+}
+
+// rateLimitRemaining consumes a token of the rate limiter of the key, and
+// returns the number of tokens left. A rejected request doesn't consume a
+// token, the delay until the next one is available is returned instead.
+func (p Proxy) rateLimitRemaining(contractId uint64, key string, limitTokens int) (bool, int, time.Duration) {
 	mu.Lock()
 	defer mu.Unlock()
 
@@ -301,55 +831,238 @@ func (p Proxy) isRateLimited(contractId uint64, key string, limitTokens int) boo
 	if !exists {
 		limiter = rate.NewLimiter(rate.Every(time.Minute), limitTokens)
 		visitors[key] = limiter
+	} else if limiter.Burst() != limitTokens {
+		// the rate limit was reloaded
+		limiter.SetBurst(limitTokens)
+	}
+
+	now := time.Now()
+	reservation := limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		// without any burst no request is ever served
+		return true, 0, 0
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return true, limiterTokens(limiter, now), delay
+	}
+	return false, limiterTokens(limiter, now), 0
+}
+
+// retryAfterSeconds rounds the delay up to whole seconds, with up to a tenth
+// of random jitter so the clients rejected together don't all retry at once
+func retryAfterSeconds(delay time.Duration) int {
+	jitter := time.Duration(rand.Int63n(int64(delay)/10 + 1)) // #nosec G404
+	return int(math.Ceil((delay + jitter).Seconds()))
+}
+
+// limiterTokens returns the whole number of tokens available in the limiter.
+// The limiter doesn't expose its tokens, a reservation of the whole burst is
+// made and cancelled, its delay is the time needed to refill the missing
+// tokens.
+func limiterTokens(limiter *rate.Limiter, now time.Time) int {
+	burst := limiter.Burst()
+	if burst <= 0 || limiter.Limit() <= 0 {
+		return 0
+	}
+	reservation := limiter.ReserveN(now, burst)
+	if !reservation.OK() {
+		return 0
+	}
+	delay := reservation.DelayFrom(now)
+	reservation.CancelAt(now)
+	missing := delay.Seconds() * float64(limiter.Limit())
+	tokens := int(math.Floor(float64(burst) - missing + 1e-6))
+	if tokens < 0 {
+		return 0
+	}
+	return tokens
+}
+
+// isUserRateLimited limits a user of a contract to the given number of queries
+// per minute, in bursts of at most burst queries. Without a burst size, the
+// whole rate limit can be used at once.
+func (p Proxy) isUserRateLimited(contractId uint64, remoteAddr string, perMinute, burst int) bool {
+	if burst <= 0 {
+		return p.isRateLimited(contractId, remoteAddr, perMinute)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	key := fmt.Sprintf("%d-%s-burst", contractId, remoteAddr)
+	limiter, exists := visitors[key]
+	if !exists {
+		limiter = rate.NewLimiter(rate.Limit(float64(perMinute)/60), burst)
+		visitors[key] = limiter
 	}
 
 	return !limiter.Allow()
 }
 
 func (p Proxy) paidTier(aa ArkAuth, remoteAddr string) (code int, err error) {
+	return p.paidTierCost(aa, remoteAddr, 1, 0)
+}
+
+// paidTierCost serves a paid request costing the given number of nonce units,
+// the nonce of a pay-as-you-go request must increase by at least its cost.
+// A pay-as-you-go contract with a daily spend cap (in USD) is rate limited
+// once the cap is reached. The claim is written right away.
+func (p Proxy) paidTierCost(aa ArkAuth, remoteAddr string, cost int64, dailySpendCapUSD float64) (code int, err error) {
+	pay, code, err := p.reservePaidTier(aa, remoteAddr, cost, dailySpendCapUSD)
+	if err != nil {
+		return code, err
+	}
+	if err := pay.commit(); err != nil {
+		return http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
+	}
+	return http.StatusOK, nil
+}
+
+// reservePaidTier checks a paid request the way paidTierCost does. The nonce
+// of a pay-as-you-go request is reserved rather than claimed, the returned
+// payment is committed once the request is answered, or released.
+func (p Proxy) reservePaidTier(aa ArkAuth, remoteAddr string, cost int64, dailySpendCapUSD float64) (pay *payment, code int, err error) {
 	key := strconv.FormatUint(aa.ContractId, 10)
 	contract, err := p.MemStore.Get(key)
 	if err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("internal server error: %w", err)
+		return nil, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
 	}
 
 	if contract.IsExpired(p.MemStore.GetHeight()) {
-		return http.StatusPaymentRequired, fmt.Errorf("open a contract")
+		return nil, http.StatusPaymentRequired, newProxyError(ErrCodeContractExpired, "open a contract")
 	}
 
 	sig := hex.EncodeToString(aa.Signature)
 	claim := NewClaim(aa.ContractId, aa.Spender, aa.Nonce, sig)
-	if p.ClaimStore.Has(key) {
-		var err error
-		claim, err = p.ClaimStore.Get(key)
+	// nonce already paid for by previous requests
+	var paid int64
+	if contract.IsSubscription() {
+		// subscriptions don't have claims, their nonce is only tracked to
+		// reject replayed requests
+		if contract.Nonce >= aa.Nonce {
+			return nil, http.StatusBadRequest, newProxyError(ErrCodeBadNonce, "bad nonce (%d/%d)", aa.Nonce, contract.Nonce)
+		}
+		paid = contract.Nonce
+	} else {
+		claim, paid, code, err = p.reserveNonce(key, aa, cost, claim)
 		if err != nil {
-			return http.StatusInternalServerError, fmt.Errorf("internal server error: %w", err)
+			return nil, code, err
 		}
-		if claim.Nonce >= aa.Nonce {
-			return http.StatusBadRequest, fmt.Errorf("bad nonce (%d/%d)", aa.Nonce, claim.Nonce)
+		// the reservation is dropped if the request is refused below
+		defer func() {
+			if err != nil {
+				p.nonces.release(aa.ContractId, aa.Nonce)
+			}
+		}()
+	}
+
+	// a nonce jumping far ahead would spend the deposit in one request, or
+	// leave a buggy client unable to use its contract. The nonce known by the
+	// chain counts as well, the claims may not be stored locally.
+	if increment := p.maxNonceIncrement(cost); increment > 0 {
+		highWater := paid
+		if contract.Nonce > highWater {
+			highWater = contract.Nonce
+		}
+		if aa.Nonce-highWater > increment {
+			lowest := paid + cost
+			if contract.IsSubscription() {
+				lowest = paid + 1
+			}
+			return nil, http.StatusBadRequest, newProxyError(ErrCodeBadNonce, "bad nonce, increment too large, expected between %d and %d (%d)", lowest, highWater+increment, aa.Nonce)
 		}
 	}
 
 	// check if we've exceed the total number of pay-as-you-go queries
 	if contract.IsPayAsYouGo() {
 		if contract.Deposit.IsNil() || contract.Deposit.LT(cosmos.NewInt(aa.Nonce*contract.Rate.Amount.Int64())) {
-			return http.StatusPaymentRequired, fmt.Errorf("contract spent")
+			return nil, http.StatusPaymentRequired, newProxyError(ErrCodeContractSpent, "contract spent")
 		}
 	}
 
 	if ok := p.isRateLimited(contract.Id, key, int(contract.QueriesPerMinute)); ok {
-		return http.StatusTooManyRequests, fmt.Errorf("client is ratelimited," + http.StatusText(429))
+		return nil, http.StatusTooManyRequests, newProxyError(ErrCodeContractRateLimited, "client is ratelimited,%s", http.StatusText(429))
+	}
+
+	if contract.IsSubscription() {
+		if !p.MemStore.MeterBlockQuery(contract.Id, p.subscriptionBlockQuota(contract)) {
+			return nil, http.StatusTooManyRequests, errBlockQuotaExceeded
+		}
+	}
+
+	now := time.Now()
+	if contract.IsPayAsYouGo() {
+		// a nonce used out of order, below the highest one, spends nothing
+		units := aa.Nonce - paid
+		if units < 0 {
+			units = 0
+		}
+		exceeded, err := p.exceedsDailySpendCap(contract, units, dailySpendCapUSD, now)
+		if err != nil {
+			return nil, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
+		}
+		if exceeded {
+			return nil, http.StatusTooManyRequests, newProxyError(ErrCodeDailySpendCap, "daily spend cap of %.2f USD reached", dailySpendCapUSD)
+		}
+	}
+
+	pay = &payment{proxy: p, contract: contract, cost: cost, dailySpendCapUSD: dailySpendCapUSD, at: now}
+	if p.DryRun {
+		if !contract.IsSubscription() {
+			p.nonces.release(aa.ContractId, aa.Nonce)
+		}
+		return pay, http.StatusOK, nil
+	}
+	pay.pending = true
+
+	if contract.IsSubscription() {
+		// subscriptions are paid per block, queries don't earn claims
+		contract.Nonce = aa.Nonce
+		p.MemStore.Put(contract)
+		return pay, http.StatusOK, nil
 	}
 
 	claim.Nonce = aa.Nonce
 	claim.Signature = sig
+	claim.SignedNonce = 0
 	claim.Claimed = false
-	if err := p.ClaimStore.Set(claim); err != nil {
-		return http.StatusInternalServerError, fmt.Errorf("internal server error: %w", err)
+	pay.claim = claim
+	pay.reserved = true
+	return pay, http.StatusOK, nil
+}
+
+// maxNonceIncrement returns how much the nonce of a request costing the given
+// number of units can increase over the last one. Zero is unlimited.
+func (p Proxy) maxNonceIncrement(cost int64) int64 {
+	increment := p.config().MaxNonceIncrement
+	if increment <= 0 {
+		return 0
 	}
-	contract.Nonce = aa.Nonce
-	p.MemStore.Put(contract)
-	return http.StatusOK, nil
+	if cost > increment {
+		return cost
+	}
+	return increment
+}
+
+// paidNonce returns the highest nonce paid for on the contract
+func (p Proxy) paidNonce(contractId uint64) (int64, error) {
+	key := strconv.FormatUint(contractId, 10)
+	if p.ClaimStore.Has(key) {
+		claim, err := p.ClaimStore.Get(key)
+		return claim.Nonce, err
+	}
+	contract, err := p.MemStore.Get(key)
+	return contract.Nonce, err
+}
+
+// subscriptionBlockQuota returns the number of queries a subscription can make
+// per block, proportional to its rate. Zero is unlimited.
+func (p Proxy) subscriptionBlockQuota(contract types.Contract) int64 {
+	quota := p.config().SubscriptionQuotaPerRate
+	if quota <= 0 || contract.Rate.IsNil() {
+		return 0
+	}
+	return contract.Rate.Amount.Int64() * quota
 }
 
 func (p Proxy) enableCORS(w http.ResponseWriter, cors CORs) http.ResponseWriter {
@@ -1,11 +1,18 @@
 package sentinel
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/sentinel/conf"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 	"github.com/stretchr/testify/require"
@@ -16,6 +23,9 @@ import (
 	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
 	"github.com/cosmos/cosmos-sdk/crypto/hd"
 	cKeys "github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
 	"github.com/cosmos/cosmos-sdk/std"
 	ctypes "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/module"
@@ -58,6 +68,48 @@ func TestArkAuth(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestContractAuthSignatureAlgorithms(t *testing.T) {
+	secpKey := secp256k1.GenPrivKey()
+	edKey := ed25519.GenPrivKey()
+	toPubKey := func(key cryptotypes.PrivKey) common.PubKey {
+		pk, err := common.NewPubKeyFromCrypto(key.PubKey())
+		require.NoError(t, err)
+		return pk
+	}
+	secpClient := toPubKey(secpKey)
+	edClient := toPubKey(edKey)
+
+	sign := func(key cryptotypes.PrivKey, algo string) ContractAuth {
+		sig, err := key.Sign([]byte("7:10"))
+		require.NoError(t, err)
+		raw := fmt.Sprintf("7:10:%s", hex.EncodeToString(sig))
+		if len(algo) > 0 {
+			raw = fmt.Sprintf("%s:%s", raw, algo)
+		}
+		auth, err := parseContractAuth(raw)
+		require.NoError(t, err)
+		return auth
+	}
+
+	// signed with the curve of the client key
+	require.NoError(t, sign(secpKey, "").Validate(0, secpClient))
+	require.NoError(t, sign(secpKey, SignatureAlgoSecp256k1).Validate(0, secpClient))
+	require.NoError(t, sign(edKey, "").Validate(0, edClient))
+	require.NoError(t, sign(edKey, "ED25519").Validate(0, edClient))
+
+	// signed with the other curve
+	err := sign(edKey, SignatureAlgoEd25519).Validate(0, secpClient)
+	require.ErrorContains(t, err, "unsupported signature algorithm")
+	err = sign(secpKey, SignatureAlgoSecp256k1).Validate(0, edClient)
+	require.ErrorContains(t, err, "unsupported signature algorithm")
+
+	// without the algorithm, the signature is only invalid
+	err = sign(edKey, "").Validate(0, secpClient)
+	require.EqualError(t, err, "invalid signature")
+	err = sign(secpKey, "").Validate(0, edClient)
+	require.EqualError(t, err, "invalid signature")
+}
+
 func TestFreeTier(t *testing.T) {
 	config := conf.Configuration{
 		FreeTierRateLimit: 1,
@@ -66,13 +118,32 @@ func TestFreeTier(t *testing.T) {
 
 	remoteAddr := "127.0.0.1:8000"
 
-	code, err := proxy.freeTier(remoteAddr)
+	code, err := proxy.freeTier(common.BTCService.String(), remoteAddr)
 	require.NoError(t, err)
 	require.Equal(t, code, http.StatusOK)
 
-	code, err = proxy.freeTier(remoteAddr)
+	code, err = proxy.freeTier(common.BTCService.String(), remoteAddr)
 	require.Error(t, err)
 	require.Equal(t, code, http.StatusTooManyRequests)
+
+	// the client is told to retry once the next token is refilled, the
+	// rejected requests don't consume it
+	_, retryAfter, code, err := proxy.freeTierQuota(common.BTCService.String(), remoteAddr, "")
+	require.Error(t, err)
+	require.Equal(t, http.StatusTooManyRequests, code)
+	require.Greater(t, retryAfter, 59*time.Second)
+	require.LessOrEqual(t, retryAfter, time.Minute)
+	_, again, _, _ := proxy.freeTierQuota(common.BTCService.String(), remoteAddr, "")
+	require.LessOrEqual(t, again, retryAfter)
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		seconds := retryAfterSeconds(time.Minute)
+		require.GreaterOrEqual(t, seconds, 60)
+		require.LessOrEqual(t, seconds, 66)
+	}
+	require.Equal(t, 1, retryAfterSeconds(time.Millisecond))
 }
 
 func TestPaidTier(t *testing.T) {
@@ -128,9 +199,8 @@ func TestPaidTier(t *testing.T) {
 	contract, err = proxy.MemStore.Get(contract.Key())
 	require.NoError(t, err)
 	require.Equal(t, contract.Nonce, int64(3))
-	claim, err := proxy.ClaimStore.Get(contract.Key())
-	require.NoError(t, err)
-	require.Equal(t, claim.Nonce, int64(3))
+	// subscriptions are paid per block, queries don't earn claims
+	require.False(t, proxy.ClaimStore.Has(contract.Key()))
 
 	// insure that same noonce is rejected.
 	code, err = proxy.paidTier(aa, "127.0.0.1:8080")
@@ -143,3 +213,723 @@ func TestPaidTier(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, code, http.StatusTooManyRequests)
 }
+
+func TestSubscriptionBlockQuota(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.SubscriptionQuotaPerRate = 2
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+
+	contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Type = types.ContractType_SUBSCRIPTION
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Rate = cosmos.NewInt64Coin("uarkeo", 2) // quota of 4 queries per block
+	contract.Deposit = cosmos.NewInt(1000)
+	contract.Height = 5
+	contract.Duration = 100
+	contract.Id = 77
+	contract.QueriesPerMinute = 100
+	proxy.MemStore.SetHeight(10)
+	proxy.MemStore.Put(contract)
+
+	nonce := int64(0)
+	serve := func() *httptest.ResponseRecorder {
+		nonce++
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=77:%d", nonce), nil)
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	for i := 0; i < 4; i++ {
+		response := serve()
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "paid", response.Header().Get("tier"))
+	}
+	require.Equal(t, int64(4), proxy.MemStore.GetBlockQueries(contract.Id))
+
+	response := serve()
+	require.Equal(t, http.StatusTooManyRequests, response.Code)
+	require.Equal(t, "11", response.Header().Get(QuotaResetHeader))
+
+	// counters reset once the height advances
+	proxy.MemStore.SetHeight(11)
+	require.Zero(t, proxy.MemStore.GetBlockQueries(contract.Id))
+	require.Equal(t, http.StatusOK, serve().Code)
+	require.Equal(t, int64(1), proxy.MemStore.GetBlockQueries(contract.Id))
+
+	// subscription traffic doesn't generate claims
+	require.Empty(t, proxy.ClaimStore.List())
+}
+
+func TestBundleContract(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	for _, service := range []common.Service{common.BTCService, common.ETHService, common.MockService} {
+		setServiceURL(proxy, service.String(), common.MustParseURL(upstream.URL))
+	}
+	router := proxy.getRouter()
+
+	contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Services = common.Services{common.BTCService, common.ETHService}
+	contract.BundleId = 3
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Rate = cosmos.NewInt64Coin("uarkeo", 2)
+	contract.Deposit = cosmos.NewInt(1000)
+	contract.Height = 5
+	contract.Duration = 100
+	contract.Id = 77
+	contract.QueriesPerMinute = 100
+	proxy.MemStore.SetHeight(10)
+	proxy.MemStore.Put(contract)
+
+	nonce := int64(0)
+	serve := func(service common.Service) *httptest.ResponseRecorder {
+		nonce++
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/%s/?arkauth=77:%d", service, nonce), nil)
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// both services of the bundle are paid by the same contract
+	for _, service := range contract.Services {
+		response := serve(service)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "paid", response.Header().Get("tier"))
+	}
+	claim, err := proxy.ClaimStore.Get(contract.Key())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), claim.Nonce)
+
+	// a service outside of the bundle is refused
+	response := serve(common.MockService)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestFreeTierHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.FreeTierRateLimit = 5
+	testConfig.FreeTierRemainingHeader = true
+	testConfig.FreeTierUpgradeThreshold = 2
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/", nil)
+		req.RemoteAddr = remoteAddr
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+	upgrade := fmt.Sprintf("http://localhost:1317/arkeo/provider/%s/btc-mainnet-fullnode", testConfig.ProviderPubKey)
+
+	for _, remaining := range []string{"4", "3", "2"} {
+		response := serve("10.0.0.1:1000")
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, remaining, response.Header().Get(RateLimitRemainingHeader))
+		require.Empty(t, response.Header().Get(UpgradeHeader))
+	}
+	// below the threshold, the client is told how to open a contract
+	for _, remaining := range []string{"1", "0"} {
+		response := serve("10.0.0.1:1000")
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, remaining, response.Header().Get(RateLimitRemainingHeader))
+		require.Equal(t, upgrade, response.Header().Get(UpgradeHeader))
+	}
+	response := serve("10.0.0.1:1000")
+	require.Equal(t, http.StatusTooManyRequests, response.Code)
+	require.Equal(t, "0", response.Header().Get(RateLimitRemainingHeader))
+	require.Equal(t, upgrade, response.Header().Get(UpgradeHeader))
+	// the client is told to retry once a request per minute is refilled
+	retryAfter, err := strconv.Atoi(response.Header().Get(RetryAfterHeader))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, retryAfter, 59)
+	require.LessOrEqual(t, retryAfter, 66)
+
+	// both headers can be turned off
+	testConfig.FreeTierRemainingHeader = false
+	testConfig.FreeTierUpgradeThreshold = 0
+	proxy.Reload(testConfig, proxy.config().proxies)
+	for i := 0; i < 6; i++ {
+		response := serve("10.0.0.2:1000")
+		require.Empty(t, response.Header().Get(RateLimitRemainingHeader))
+		require.Empty(t, response.Header().Get(UpgradeHeader))
+	}
+}
+
+func TestNonceIncrement(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.FreeTierRateLimit = 1
+	testConfig.MaxNonceIncrement = 10
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Deposit = cosmos.NewInt(1000)
+	contract.Id = 88
+	proxy.MemStore.SetHeight(20)
+	proxy.MemStore.Put(contract)
+
+	// use up the free tier, so paid rejections are reported
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/", nil))
+
+	serve := func(nonce int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:%d", contract.Id, nonce), nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// legit increments
+	for _, nonce := range []int64{1, 2, 12} {
+		response := serve(nonce)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, strconv.FormatInt(nonce, 10), response.Header().Get(NonceHeader))
+	}
+
+	// a jump above the max increment is refused, with the expected range
+	response := serve(23)
+	require.Equal(t, http.StatusTooManyRequests, response.Code)
+	require.Contains(t, response.Body.String(), "expected between 13 and 22 (23)")
+	require.Equal(t, "12", response.Header().Get(NonceHeader))
+	response = serve(5_000_000)
+	require.Contains(t, response.Body.String(), "expected between 13 and 22 (5000000)")
+
+	// the client resynchronizes from the header
+	response = serve(13)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "13", response.Header().Get(NonceHeader))
+
+	// a request costing more than the max increment can still be paid
+	code, err := proxy.paidTierCost(ArkAuth{ContractId: contract.Id, Spender: contract.Client, Nonce: 63}, "127.0.0.1:8080", 50, 0)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	code, err = proxy.paidTierCost(ArkAuth{ContractId: contract.Id, Spender: contract.Client, Nonce: 200}, "127.0.0.1:8080", 1, 0)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, ErrCodeBadNonce, errorCode(err, code))
+}
+
+func TestFreeTierDisabled(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.FreeTierEnabled = false
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+	proxy.MemStore.SetHeight(20)
+
+	clientKey := secp256k1.GenPrivKey()
+	client, err := common.NewPubKeyFromCrypto(clientKey.PubKey())
+	require.NoError(t, err)
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, client)
+	contract.Id = 1
+	proxy.MemStore.Put(contract)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+	refused := func(path string, code ErrorCode) {
+		response := serve(path)
+		require.Equal(t, http.StatusPaymentRequired, response.Code)
+		require.Empty(t, response.Header().Get("tier"))
+		var body ErrorResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, code, body.Code)
+		require.Equal(t, http.StatusPaymentRequired, body.HTTPStatus)
+	}
+
+	// requests not paid by a contract are refused, with the reason the paid
+	// tier didn't serve them when there is one
+	refused("/btc-mainnet-fullnode/", ErrCodeFreeTierDisabled)
+	refused("/btc-mainnet-fullnode/?arkauth=999:1", ErrCodeUnknownContract)
+
+	// paid requests are still served
+	sig, err := clientKey.Sign(types.GetBytesToSign(contract.Id, 1))
+	require.NoError(t, err)
+	response := serve(fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:1:%s", contract.Id, hex.EncodeToString(sig)))
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+
+	// once enabled, the free tier serves them again
+	testConfig.FreeTierEnabled = true
+	proxy.Reload(testConfig, proxy.config().proxies)
+	response = serve("/btc-mainnet-fullnode/")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "free", response.Header().Get("tier"))
+
+	// a zero rate limit keeps the free tier enabled, without any allowance
+	testConfig.FreeTierRateLimit = 0
+	proxy.Reload(testConfig, proxy.config().proxies)
+	response = serve("/btc-mainnet-fullnode/")
+	require.Equal(t, http.StatusTooManyRequests, response.Code)
+}
+
+func TestServiceFreeTier(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.FreeTierRateLimit = 2
+	testConfig.ServiceFreeTiers = map[string]conf.FreeTier{
+		common.BTCService.String(): {Enabled: true, RateLimit: 4, MaxBodyBytes: 16},
+		common.ETHService.String(): {Enabled: false, RateLimit: 2},
+	}
+	proxy := NewProxy(testConfig)
+	for _, service := range []common.Service{common.MockService, common.BTCService, common.ETHService} {
+		setServiceURL(proxy, service.String(), common.MustParseURL(upstream.URL))
+	}
+	router := proxy.getRouter()
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// the overridden service has its own allowance, the others share the
+	// global one
+	for i := 0; i < 4; i++ {
+		require.Equal(t, http.StatusOK, serve(http.MethodGet, "/btc-mainnet-fullnode/", "").Code)
+	}
+	require.Equal(t, http.StatusTooManyRequests, serve(http.MethodGet, "/btc-mainnet-fullnode/", "").Code)
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, serve(http.MethodGet, "/mock/", "").Code)
+	}
+	require.Equal(t, http.StatusTooManyRequests, serve(http.MethodGet, "/mock/", "").Code)
+
+	// and its own request body limit
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	require.Equal(t, http.StatusRequestEntityTooLarge, serve(http.MethodPost, "/btc-mainnet-fullnode/", strings.Repeat("a", 17)).Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/mock/", strings.Repeat("a", 17)).Code)
+
+	// a service without a free tier refuses unpaid requests, with a hint to
+	// open a contract
+	response := serve(http.MethodGet, "/eth-mainnet-fullnode/", "")
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	require.Empty(t, response.Header().Get("tier"))
+	require.Equal(t, fmt.Sprintf("http://localhost:1317/arkeo/provider/%s/eth-mainnet-fullnode", testConfig.ProviderPubKey), response.Header().Get(UpgradeHeader))
+	var body ErrorResponse
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+	require.Equal(t, ErrCodeFreeTierDisabled, body.Code)
+	require.Contains(t, body.Error, "open a contract")
+
+	// the overrides are reloaded
+	testConfig.ServiceFreeTiers = map[string]conf.FreeTier{
+		common.ETHService.String(): {Enabled: true, RateLimit: 1},
+	}
+	proxy.Reload(testConfig, proxy.config().proxies)
+	require.Equal(t, http.StatusOK, serve(http.MethodGet, "/eth-mainnet-fullnode/", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, serve(http.MethodGet, "/eth-mainnet-fullnode/", "").Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/btc-mainnet-fullnode/", strings.Repeat("a", 17)).Code)
+}
+
+func TestStrictAuth(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+	proxy.MemStore.SetHeight(20)
+
+	clientKey := secp256k1.GenPrivKey()
+	client, err := common.NewPubKeyFromCrypto(clientKey.PubKey())
+	require.NoError(t, err)
+	newContract := func(id uint64, provider common.PubKey) types.Contract {
+		contract := newTestContract(provider, common.BTCService, client)
+		contract.Authorization = types.ContractAuthorization_STRICT
+		contract.Id = id
+		proxy.MemStore.Put(contract)
+		return contract
+	}
+	valid := newContract(1, testConfig.ProviderPubKey)
+	otherProvider := newContract(2, types.GetRandomPubKey())
+	expired := newContract(3, testConfig.ProviderPubKey)
+	expired.Duration = 5
+	proxy.MemStore.Put(expired)
+
+	arkauth := func(key cryptotypes.PrivKey, id uint64, nonce int64) string {
+		sig, err := key.Sign(types.GetBytesToSign(id, nonce))
+		require.NoError(t, err)
+		return fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:%d:%s", id, nonce, hex.EncodeToString(sig))
+	}
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	invalid := []struct {
+		name string
+		path string
+		code ErrorCode
+	}{
+		{"bad signature", arkauth(secp256k1.GenPrivKey(), valid.Id, 1), ErrCodeBadSignature},
+		{"unknown contract", arkauth(clientKey, 999, 1), ErrCodeUnknownContract},
+		{"provider mismatch", arkauth(clientKey, otherProvider.Id, 1), ErrCodeProviderMismatch},
+		{"contract expired", arkauth(clientKey, expired.Id, 1), ErrCodeContractExpired},
+	}
+
+	// by default, an arkauth that can't pay is served on the free tier. The
+	// signature isn't checked, the chain refuses the claim of a bad one.
+	for _, tc := range invalid[1:] {
+		response := serve(tc.path)
+		require.Equal(t, http.StatusOK, response.Code, tc.name)
+		require.Equal(t, "free", response.Header().Get("tier"), tc.name)
+	}
+
+	testConfig.StrictAuth = true
+	proxy.Reload(testConfig, proxy.config().proxies)
+	for _, tc := range invalid {
+		t.Run(tc.name, func(t *testing.T) {
+			response := serve(tc.path)
+			require.Equal(t, http.StatusUnauthorized, response.Code)
+			require.Equal(t, "application/json", response.Header().Get("Content-Type"))
+			var body ErrorResponse
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+			require.Equal(t, tc.code, body.Code)
+			require.Equal(t, http.StatusUnauthorized, body.HTTPStatus)
+			require.Equal(t, testConfig.ProviderPubKey.String(), body.ExpectedProvider)
+			require.NotEmpty(t, body.Error)
+		})
+	}
+
+	// a valid arkauth is still served on the paid tier, no arkauth on the
+	// free tier
+	response := serve(arkauth(clientKey, valid.Id, 1))
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+	response = serve("/btc-mainnet-fullnode/")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "free", response.Header().Get("tier"))
+}
+
+func TestSignatureDomain(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.ChainId = "arkeo-a"
+	testConfig.StrictAuth = true
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+	proxy.MemStore.SetHeight(20)
+
+	clientKey := secp256k1.GenPrivKey()
+	client, err := common.NewPubKeyFromCrypto(clientKey.PubKey())
+	require.NoError(t, err)
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, client)
+	contract.Authorization = types.ContractAuthorization_STRICT
+	contract.Id = 1
+	proxy.MemStore.Put(contract)
+
+	serve := func(message []byte, nonce int64) *httptest.ResponseRecorder {
+		sig, err := clientKey.Sign(message)
+		require.NoError(t, err)
+		path := fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:%d:%s", contract.Id, nonce, hex.EncodeToString(sig))
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// the bare message and the domain one are both accepted by default
+	response := serve(types.GetBytesToSign(contract.Id, 1), 1)
+	require.Equal(t, http.StatusOK, response.Code)
+	message := GenerateDomainMessageToSign("arkeo-a", testConfig.ProviderPubKey, contract.Id, 2)
+	response = serve([]byte(message), 2)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	testConfig.SignatureDomain = true
+	proxy.Reload(testConfig, proxy.config().proxies)
+
+	// a signature for chain B is rejected on chain A, so is the bare message
+	message = GenerateDomainMessageToSign("arkeo-b", testConfig.ProviderPubKey, contract.Id, 3)
+	response = serve([]byte(message), 3)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	var body ErrorResponse
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+	require.Equal(t, ErrCodeBadSignature, body.Code)
+	response = serve(types.GetBytesToSign(contract.Id, 3), 3)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+
+	message = GenerateDomainMessageToSign("arkeo-a", testConfig.ProviderPubKey, contract.Id, 3)
+	response = serve([]byte(message), 3)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+
+	// the dry run tells the message to sign
+	res := proxy.validateArkAuth(GenerateArkAuthString(contract.Id, 4, []byte{1}), "")
+	require.Equal(t, message[:len(message)-1]+"4", res.MessageToSign)
+}
+
+func TestServiceCheck(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.FreeTierRateLimit = 1
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	setServiceURL(proxy, common.ETHService.String())
+	router := proxy.getRouter()
+	proxy.MemStore.SetHeight(20)
+
+	// a strictly authorized contract, its arkauths without signature are
+	// served on the free tier
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_STRICT
+	contract.Id = 4
+	proxy.MemStore.Put(contract)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	rejected := []struct {
+		name   string
+		path   string
+		status int
+		code   ErrorCode
+	}{
+		{"unknown service", "/not-a-service/", http.StatusBadRequest, ErrCodeUnknownService},
+		{"service without upstream", "/eth-mainnet-fullnode/", http.StatusBadRequest, ErrCodeUnknownService},
+		{"free tier against another service", fmt.Sprintf("/bch-mainnet-fullnode/?arkauth=%d:1", contract.Id), http.StatusUnauthorized, ErrCodeServiceMismatch},
+	}
+	for _, tc := range rejected {
+		response := serve(tc.path)
+		require.Equal(t, tc.status, response.Code, tc.name)
+		require.Empty(t, response.Header().Get("tier"), tc.name)
+		var body ErrorResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, tc.code, body.Code, tc.name)
+	}
+
+	// rejected requests don't use the free tier quota
+	response := serve(fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:1", contract.Id))
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "free", response.Header().Get("tier"))
+	require.Equal(t, http.StatusTooManyRequests, serve("/btc-mainnet-fullnode/").Code)
+}
+
+func TestGetRemoteAddr(t *testing.T) {
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	request := func(addr, realIP, forwarded string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/", nil)
+		req.RemoteAddr = addr
+		if len(realIP) > 0 {
+			req.Header.Set(xRealIPName, realIP)
+		}
+		if len(forwarded) > 0 {
+			req.Header.Set(forwardHeaderName, forwarded)
+		}
+		return req
+	}
+
+	require.Equal(t, "10.0.0.1", proxy.getRemoteAddr(request("10.0.0.1:1000", "", "")))
+	require.Equal(t, "2001:db8::1", proxy.getRemoteAddr(request("[2001:db8::1]:1000", "", "")))
+	require.Equal(t, "10.0.0.2", proxy.getRemoteAddr(request("10.0.0.1:1000", "10.0.0.2", "10.0.0.3")))
+	require.Equal(t, "2001:db8::2", proxy.getRemoteAddr(request("10.0.0.1:1000", "[2001:db8::2]", "")))
+	require.Equal(t, "10.0.0.3", proxy.getRemoteAddr(request("10.0.0.1:1000", "", "10.0.0.3:4000")))
+
+	// without trusted hops the left-most entry is taken
+	chain := "203.0.113.9, [2001:db8::3]:443, 10.0.0.4"
+	require.Equal(t, "203.0.113.9", proxy.getRemoteAddr(request("10.0.0.1:1000", "", chain)))
+
+	// with trusted hops the entry received by the outermost trusted proxy
+	for hops, expected := range map[int]string{1: "10.0.0.4", 2: "2001:db8::3", 3: "203.0.113.9", 4: "203.0.113.9"} {
+		config := testConfig
+		config.TrustedProxyHops = hops
+		proxy.Reload(config, proxy.config().proxies)
+		require.Equal(t, expected, proxy.getRemoteAddr(request("10.0.0.1:1000", "", chain)), "%d hops", hops)
+	}
+
+	// the address matches a whitelist
+	config := testConfig
+	config.TrustedProxyHops = 2
+	proxy.Reload(config, proxy.config().proxies)
+	conf := proxy.CreateDefaultContractConfig(1)
+	conf.WhitelistIPAddresses = []string{"2001:db8::3"}
+	code, _, err := proxy.contractConfigError(conf, 1, proxy.getRemoteAddr(request("10.0.0.1:1000", "", chain)))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+}
+
+func TestRotatedDelegate(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+	proxy.MemStore.SetHeight(20)
+
+	newKey := func() (cryptotypes.PrivKey, common.PubKey) {
+		key := secp256k1.GenPrivKey()
+		pubkey, err := common.NewPubKeyFromCrypto(key.PubKey())
+		require.NoError(t, err)
+		return key, pubkey
+	}
+	oldKey, oldDelegate := newKey()
+	newDelegateKey, newDelegate := newKey()
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Delegate = oldDelegate
+	contract.Authorization = types.ContractAuthorization_STRICT
+	contract.Id = 1
+	proxy.MemStore.Put(contract)
+
+	serve := func(key cryptotypes.PrivKey, nonce int64) *httptest.ResponseRecorder {
+		sig, err := key.Sign(types.GetBytesToSign(contract.Id, nonce))
+		require.NoError(t, err)
+		path := fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:%d:%s", contract.Id, nonce, hex.EncodeToString(sig))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+		return response
+	}
+	requireRotated := func(response *httptest.ResponseRecorder) {
+		require.Equal(t, http.StatusUnauthorized, response.Code)
+		var body ErrorResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, ErrCodeDelegateRotated, body.Code)
+		require.Contains(t, body.Error, newDelegate.String())
+	}
+
+	response := serve(oldKey, 1)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+
+	// the client rotates the delegate
+	rotated := contract
+	rotated.Delegate = newDelegate
+	evt := types.NewUpdateContractDelegateEvent(&rotated, oldDelegate, 21)
+	sdkEvt, err := ctypes.TypedEventToEvent(&evt)
+	require.NoError(t, err)
+	proxy.handleUpdateContractDelegateEvent(makeResultEvent(sdkEvt, 21))
+	held, ok := proxy.MemStore.Peek(contract.Key())
+	require.True(t, ok)
+	require.Equal(t, newDelegate, held.Delegate)
+
+	// the old delegate is refused, rather than served on the free tier
+	requireRotated(serve(oldKey, 2))
+	response = serve(newDelegateKey, 2)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+
+	testConfig.StrictAuth = true
+	proxy.Reload(testConfig, proxy.config().proxies)
+	requireRotated(serve(oldKey, 3))
+	response = serve(newDelegateKey, 3)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+}
+
+func TestContractSpenders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+	proxy.MemStore.SetHeight(20)
+
+	newKey := func() (cryptotypes.PrivKey, common.PubKey) {
+		key := secp256k1.GenPrivKey()
+		pubkey, err := common.NewPubKeyFromCrypto(key.PubKey())
+		require.NoError(t, err)
+		return key, pubkey
+	}
+	clientKey, client := newKey()
+	serverA, spenderA := newKey()
+	serverB, spenderB := newKey()
+	unlistedKey, _ := newKey()
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, client)
+	contract.Spenders = []common.PubKey{spenderA, spenderB}
+	contract.Authorization = types.ContractAuthorization_STRICT
+	contract.Id = 1
+	proxy.MemStore.Put(contract)
+
+	serve := func(key cryptotypes.PrivKey, nonce int64) *httptest.ResponseRecorder {
+		sig, err := key.Sign(types.GetBytesToSign(contract.Id, nonce))
+		require.NoError(t, err)
+		path := fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:%d:%s", contract.Id, nonce, hex.EncodeToString(sig))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+		return response
+	}
+	requireUnlisted := func(response *httptest.ResponseRecorder) {
+		require.Equal(t, http.StatusUnauthorized, response.Code)
+		var body ErrorResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, ErrCodeUnlistedSpender, body.Code)
+	}
+
+	for _, strict := range []bool{false, true} {
+		testConfig.StrictAuth = strict
+		proxy.Reload(testConfig, proxy.config().proxies)
+		nonce, err := proxy.paidNonce(contract.Id)
+		require.NoError(t, err)
+
+		// the listed spenders share the nonces of the contract
+		response := serve(serverA, nonce+1)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "paid", response.Header().Get("tier"))
+		response = serve(serverB, nonce+2)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "paid", response.Header().Get("tier"))
+
+		// anybody else, the client included, is refused rather than served on
+		// the free tier
+		requireUnlisted(serve(unlistedKey, nonce+3))
+		requireUnlisted(serve(clientKey, nonce+3))
+
+		claim, err := proxy.ClaimStore.Get(contract.Key())
+		require.NoError(t, err)
+		require.Equal(t, nonce+2, claim.Nonce)
+	}
+}
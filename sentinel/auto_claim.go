@@ -0,0 +1,348 @@
+package sentinel
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/keyring"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	"github.com/cosmos/cosmos-sdk/std"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	authtx "github.com/cosmos/cosmos-sdk/x/auth/tx"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// ChainClient submits contract income claims to the chain, the claims given
+// together are submitted in a single transaction
+type ChainClient interface {
+	ClaimContractIncome(claims ...Claim) error
+}
+
+type txChainClient struct {
+	clientCtx client.Context
+	factory   tx.Factory
+
+	// account sequence, tracked locally so transactions broadcast before the
+	// previous one is committed don't reuse it
+	lock          sync.Mutex
+	synced        bool
+	accountNumber uint64
+	sequence      uint64
+}
+
+// NewChainClient creates a chain client signing claims with the key of the
+// configured keyring, and broadcasting them to the event stream host
+func NewChainClient(config conf.Configuration) (ChainClient, error) {
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	authtypes.RegisterInterfaces(interfaceRegistry)
+	types.RegisterInterfaces(interfaceRegistry)
+	cdc := codec.NewProtoCodec(interfaceRegistry)
+	txConfig := authtx.NewTxConfig(cdc, authtx.DefaultSignModes)
+
+	kr, err := keyring.New("sentinel", keyring.BackendTest, config.ClaimKeyPath, nil, cdc)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open keyring %s: %w", config.ClaimKeyPath, err)
+	}
+	key, err := kr.Key(config.ClaimKeyName)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get key %s: %w", config.ClaimKeyName, err)
+	}
+	addr, err := key.GetAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	rpcClient, err := client.NewClientFromNode(fmt.Sprintf("tcp://%s", config.EventStreamHost))
+	if err != nil {
+		return nil, fmt.Errorf("fail to create rpc client: %w", err)
+	}
+
+	clientCtx := client.Context{}.
+		WithCodec(cdc).
+		WithInterfaceRegistry(interfaceRegistry).
+		WithTxConfig(txConfig).
+		WithLegacyAmino(codec.NewLegacyAmino()).
+		WithAccountRetriever(authtypes.AccountRetriever{}).
+		WithKeyring(kr).
+		WithChainID(config.ChainId).
+		WithClient(rpcClient).
+		WithFromName(config.ClaimKeyName).
+		WithFromAddress(addr).
+		WithBroadcastMode(flags.BroadcastSync).
+		WithSkipConfirmation(true)
+
+	factory := tx.Factory{}.
+		WithChainID(config.ChainId).
+		WithKeybase(kr).
+		WithTxConfig(txConfig).
+		WithAccountRetriever(clientCtx.AccountRetriever).
+		WithGasAdjustment(1.5).
+		WithGasPrices(config.GasPrices).
+		WithSimulateAndExecute(true).
+		WithSignMode(txConfig.SignModeHandler().DefaultMode())
+
+	return &txChainClient{clientCtx: clientCtx, factory: factory}, nil
+}
+
+// Sign signs the message with the key signing the claims, the provider key
+func (c *txChainClient) Sign(msg []byte) ([]byte, cryptotypes.PubKey, error) {
+	return c.clientCtx.Keyring.Sign(c.clientCtx.GetFromName(), msg)
+}
+
+func (c *txChainClient) ClaimContractIncome(claims ...Claim) error {
+	if len(claims) == 0 {
+		return nil
+	}
+	msgs := make([]cosmos.Msg, 0, len(claims))
+	for _, claim := range claims {
+		sig, err := hex.DecodeString(claim.Signature)
+		if err != nil {
+			return fmt.Errorf("bad claim signature (%d): %w", claim.ContractId, err)
+		}
+		msg := types.NewMsgClaimContractIncome(c.clientCtx.GetFromAddress(), claim.ContractId, claim.ClaimNonce(), sig)
+		if err := msg.ValidateBasic(); err != nil {
+			return err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.synced {
+		num, seq, err := c.clientCtx.AccountRetriever.GetAccountNumberSequence(c.clientCtx, c.clientCtx.GetFromAddress())
+		if err != nil {
+			return fmt.Errorf("fail to get account sequence: %w", err)
+		}
+		c.accountNumber, c.sequence, c.synced = num, seq, true
+	}
+	txf := c.factory.WithAccountNumber(c.accountNumber).WithSequence(c.sequence)
+
+	_, gas, err := tx.CalculateGas(c.clientCtx, txf, msgs...)
+	if err != nil {
+		// the simulation fails on a stale sequence as well
+		c.synced = false
+		return fmt.Errorf("fail to simulate claim: %w", err)
+	}
+	txf = txf.WithGas(gas)
+
+	txb, err := txf.BuildUnsignedTx(msgs...)
+	if err != nil {
+		return err
+	}
+	if err := tx.Sign(txf, c.clientCtx.GetFromName(), txb, true); err != nil {
+		return fmt.Errorf("fail to sign claim: %w", err)
+	}
+	txBytes, err := c.clientCtx.TxConfig.TxEncoder()(txb.GetTx())
+	if err != nil {
+		return err
+	}
+	res, err := c.clientCtx.BroadcastTx(txBytes)
+	if err != nil {
+		// unknown whether the transaction made it to the mempool
+		c.synced = false
+		return fmt.Errorf("fail to broadcast claim: %w", err)
+	}
+	if res.Code != 0 {
+		if res.Codespace == sdkerrors.RootCodespace && res.Code == sdkerrors.ErrWrongSequence.ABCICode() {
+			c.synced = false
+		}
+		return fmt.Errorf("claim rejected (%d): %s", res.Code, res.RawLog)
+	}
+	// the transaction is in the mempool, its sequence is used
+	c.sequence++
+	return nil
+}
+
+// claimIncome returns the income of a pay-as-you-go claim (nonce * rate), up
+// to the nonce it is signed for
+func claimIncome(claim Claim, contract types.Contract) cosmos.Int {
+	if !contract.IsPayAsYouGo() || contract.Rate.IsNil() {
+		return cosmos.ZeroInt()
+	}
+	return contract.Rate.Amount.MulRaw(claim.ClaimNonce())
+}
+
+// claimBackoff is the delay between two auto claim runs, doubled after each
+// run with failed broadcasts (up to max) and reset after a clean run
+type claimBackoff struct {
+	interval time.Duration
+	max      time.Duration
+	delay    time.Duration
+}
+
+func newClaimBackoff(interval, max time.Duration) *claimBackoff {
+	if max < interval {
+		max = interval
+	}
+	return &claimBackoff{interval: interval, max: max, delay: interval}
+}
+
+// next returns the delay before the next run
+func (b *claimBackoff) next(failed bool) time.Duration {
+	if !failed {
+		b.delay = b.interval
+		return b.delay
+	}
+	b.delay *= 2
+	if b.delay > b.max {
+		b.delay = b.max
+	}
+	return b.delay
+}
+
+// AutoClaimer periodically submits the claims worth claiming, backing off
+// while broadcasts fail, until the proxy shuts down
+func (p Proxy) AutoClaimer(chain ChainClient, interval time.Duration) {
+	backoff := newClaimBackoff(interval, time.Duration(p.Config.AutoClaimMaxBackoffSec)*time.Second)
+	delay := interval
+	for {
+		select {
+		case <-p.stopping:
+			return
+		case <-time.After(delay):
+		}
+		_, failed := p.autoClaim(chain)
+		delay = backoff.next(failed > 0)
+		if failed > 0 {
+			p.logger.Error("fail to submit claims, backing off", "failed", failed, "next_run", delay)
+		}
+	}
+}
+
+// autoClaim submits the unclaimed claims whose income exceeds the threshold,
+// or whose contract settlement deadline is near, and returns the submitted
+// claims and the number of claims that failed. Claims are only flagged as
+// claimed once accepted by the chain, and the chain rejects nonces already
+// claimed, so it is safe to run again after a restart.
+func (p Proxy) autoClaim(chain ChainClient) ([]Claim, int) {
+	height := p.MemStore.GetHeight()
+	threshold := cosmos.NewInt(p.Config.AutoClaimThreshold)
+	var pending []Claim
+	for _, claim := range p.ClaimStore.List() {
+		if claim.Claimed {
+			continue
+		}
+		contract, err := p.MemStore.Get(claim.Key())
+		if err != nil {
+			p.logger.Error("fail to fetch contract", "error", err, "id", claim.ContractId)
+			continue
+		}
+		// subscriptions don't earn income per query
+		if !contract.IsPayAsYouGo() || contract.IsSettled(height) {
+			continue
+		}
+
+		nearDeadline := contract.SettlementPeriodEnd()-height <= p.Config.AutoClaimDeadlineBlocks
+		income := claimIncome(claim, contract)
+		if !nearDeadline && (threshold.IsZero() || income.LT(threshold)) {
+			continue
+		}
+		pending = append(pending, claim)
+	}
+	return p.submitClaims(chain, pending)
+}
+
+// submitClaims submits the claims in batches of the configured size. A failed
+// batch is submitted again one claim at a time, so a claim rejected by the
+// chain doesn't hold back the others. Returns the claims accepted by the
+// chain, flagged as claimed, and the number of claims that failed.
+func (p Proxy) submitClaims(chain ChainClient, claims []Claim) ([]Claim, int) {
+	size := p.Config.AutoClaimBatchSize
+	if size < 1 {
+		size = 1
+	}
+	var submitted []Claim
+	var failed int
+	accept := func(claim Claim) {
+		p.logger.Info("claim submitted", "id", claim.ContractId, "nonce", claim.Nonce)
+		submitted = append(submitted, claim)
+		p.markClaimed(claim)
+	}
+	for start := 0; start < len(claims); start += size {
+		end := start + size
+		if end > len(claims) {
+			end = len(claims)
+		}
+		batch := claims[start:end]
+		err := chain.ClaimContractIncome(batch...)
+		if err == nil {
+			for _, claim := range batch {
+				accept(claim)
+			}
+			continue
+		}
+		if len(batch) == 1 {
+			p.logger.Error("fail to submit claim", "error", err, "id", batch[0].ContractId, "nonce", batch[0].Nonce)
+			failed++
+			continue
+		}
+		p.logger.Error("fail to submit claim batch, submitting claims one by one", "error", err, "claims", len(batch))
+		for _, claim := range batch {
+			if err := chain.ClaimContractIncome(claim); err != nil {
+				p.logger.Error("fail to submit claim", "error", err, "id", claim.ContractId, "nonce", claim.Nonce)
+				failed++
+				continue
+			}
+			accept(claim)
+		}
+	}
+	return submitted, failed
+}
+
+// markClaimed flags the submitted claim as claimed. New requests may have been
+// served meanwhile, so only the submitted nonce is flagged.
+func (p Proxy) markClaimed(claim Claim) {
+	current, err := p.ClaimStore.Get(claim.Key())
+	if err != nil {
+		p.logger.Error("fail to get claim", "error", err, "id", claim.ContractId)
+		return
+	}
+	if current.ClaimNonce() == claim.ClaimNonce() {
+		current.Claimed = true
+		if err := p.ClaimStore.Set(current); err != nil {
+			p.logger.Error("fail to set claimed", "error", err, "id", claim.ContractId)
+		}
+	}
+}
+
+// DrainSummary is the outcome of submitting all the pending claims
+type DrainSummary struct {
+	Submitted int `json:"submitted"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// drainClaims submits every unclaimed claim, regardless of its income
+func (p Proxy) drainClaims(chain ChainClient) DrainSummary {
+	var pending []Claim
+	for _, claim := range p.ClaimStore.List() {
+		if !claim.Claimed {
+			pending = append(pending, claim)
+		}
+	}
+	submitted, failed := p.submitClaims(chain, pending)
+	summary := DrainSummary{Submitted: len(pending), Succeeded: len(submitted), Failed: failed}
+	p.logger.Info("claims drained", "submitted", summary.Submitted, "succeeded", summary.Succeeded, "failed", summary.Failed)
+	return summary
+}
+
+func (p Proxy) handleDrainClaims(w http.ResponseWriter, r *http.Request) {
+	if p.Chain == nil {
+		respondWithError(w, "claim submission is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, p.drainClaims(p.Chain))
+}
@@ -0,0 +1,247 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	cKeys "github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/std"
+	"github.com/stretchr/testify/require"
+)
+
+type mockChainClient struct {
+	claims  []Claim
+	fail    map[uint64]bool
+	batches [][]Claim // every broadcast, failed or not
+}
+
+// ClaimContractIncome accepts the claims, unless one of them fails, as a
+// transaction would
+func (c *mockChainClient) ClaimContractIncome(claims ...Claim) error {
+	c.batches = append(c.batches, claims)
+	for _, claim := range claims {
+		if c.fail[claim.ContractId] {
+			return fmt.Errorf("broadcast failed")
+		}
+	}
+	c.claims = append(c.claims, claims...)
+	return nil
+}
+
+func TestAutoClaim(t *testing.T) {
+	testConfig := newTestConfig()
+	testConfig.AutoClaimThreshold = 25
+	testConfig.AutoClaimDeadlineBlocks = 10
+	proxy := NewProxy(testConfig)
+	proxy.MemStore.SetHeight(110)
+
+	newContract := func(id uint64, height, rate int64) types.Contract {
+		contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+		contract.Type = types.ContractType_PAY_AS_YOU_GO
+		contract.Rate = cosmos.NewInt64Coin("uarkeo", rate)
+		contract.Deposit = cosmos.NewInt(1000)
+		contract.Height = height
+		contract.Duration = 100
+		contract.Id = id
+		proxy.MemStore.Put(contract)
+		return contract
+	}
+	c1 := newContract(1, 100, 3) // above the threshold
+	c2 := newContract(2, 100, 5) // below the threshold
+	c3 := newContract(3, 100, 7) // already claimed
+	c4 := newContract(4, 20, 1)  // below the threshold, but close to the deadline
+	c5 := newContract(5, 100, 9) // above the threshold, broadcast fails
+
+	claimed := NewClaim(c3.Id, c3.Client, 6, "sig3")
+	claimed.Claimed = true
+	require.NoError(t, proxy.ClaimStore.Batch([]Claim{
+		NewClaim(c1.Id, c1.Client, 10, "sig1"),
+		NewClaim(c2.Id, c2.Client, 4, "sig2"),
+		claimed,
+		NewClaim(c4.Id, c4.Client, 2, "sig4"),
+		NewClaim(c5.Id, c5.Client, 5, "sig5"),
+	}))
+
+	chain := &mockChainClient{fail: map[uint64]bool{c5.Id: true}}
+	submitted, failed := proxy.autoClaim(chain)
+	require.Len(t, submitted, 2)
+	require.Equal(t, 1, failed)
+	ids := []uint64{chain.claims[0].ContractId, chain.claims[1].ContractId}
+	require.ElementsMatch(t, []uint64{c1.Id, c4.Id}, ids)
+
+	for _, id := range []uint64{c1.Id, c4.Id} {
+		claim, err := proxy.ClaimStore.Get(fmt.Sprintf("%d", id))
+		require.NoError(t, err)
+		require.True(t, claim.Claimed)
+	}
+	for _, id := range []uint64{c2.Id, c5.Id} {
+		claim, err := proxy.ClaimStore.Get(fmt.Sprintf("%d", id))
+		require.NoError(t, err)
+		require.False(t, claim.Claimed)
+	}
+
+	// claimed nonces are not submitted again, failed ones are retried
+	chain.fail = nil
+	chain.claims = nil
+	submitted, failed = proxy.autoClaim(chain)
+	require.Len(t, submitted, 1)
+	require.Zero(t, failed)
+	require.Equal(t, c5.Id, submitted[0].ContractId)
+
+	// a newer nonce is claimable again
+	require.NoError(t, proxy.ClaimStore.Set(NewClaim(c1.Id, c1.Client, 20, "sig1b")))
+	chain.claims = nil
+	submitted, _ = proxy.autoClaim(chain)
+	require.Len(t, submitted, 1)
+	require.Equal(t, int64(20), submitted[0].Nonce)
+	submitted, _ = proxy.autoClaim(chain)
+	require.Empty(t, submitted)
+}
+
+func TestAutoClaimBatches(t *testing.T) {
+	testConfig := newTestConfig()
+	testConfig.AutoClaimThreshold = 1
+	testConfig.AutoClaimBatchSize = 2
+	proxy := NewProxy(testConfig)
+	proxy.MemStore.SetHeight(110)
+
+	var claims []Claim
+	for id := uint64(1); id <= 5; id++ {
+		contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+		contract.Type = types.ContractType_PAY_AS_YOU_GO
+		contract.Rate = cosmos.NewInt64Coin("uarkeo", 1)
+		contract.Deposit = cosmos.NewInt(1000)
+		contract.Height = 100
+		contract.Duration = 100
+		contract.Id = id
+		proxy.MemStore.Put(contract)
+		claims = append(claims, NewClaim(id, contract.Client, 10, fmt.Sprintf("sig%d", id)))
+	}
+	require.NoError(t, proxy.ClaimStore.Batch(claims))
+	isClaimed := func(id uint64) bool {
+		claim, err := proxy.ClaimStore.Get(fmt.Sprintf("%d", id))
+		require.NoError(t, err)
+		return claim.Claimed
+	}
+
+	// the whole broadcast fails, nothing is flagged as claimed
+	chain := &mockChainClient{fail: map[uint64]bool{1: true, 2: true, 3: true, 4: true, 5: true}}
+	submitted, failed := proxy.autoClaim(chain)
+	require.Empty(t, submitted)
+	require.Equal(t, 5, failed)
+	for id := uint64(1); id <= 5; id++ {
+		require.False(t, isClaimed(id))
+	}
+
+	// claims are batched, a failed batch is retried one claim at a time
+	chain = &mockChainClient{fail: map[uint64]bool{3: true}}
+	submitted, failed = proxy.autoClaim(chain)
+	require.Len(t, submitted, 4)
+	require.Equal(t, 1, failed)
+	sizes := make([]int, len(chain.batches))
+	for i, batch := range chain.batches {
+		sizes[i] = len(batch)
+	}
+	require.ElementsMatch(t, []int{2, 2, 1, 1, 1}, sizes)
+	for id := uint64(1); id <= 5; id++ {
+		require.Equal(t, id != 3, isClaimed(id))
+	}
+
+	// only the failed claim is retried
+	chain = &mockChainClient{}
+	submitted, failed = proxy.autoClaim(chain)
+	require.Len(t, submitted, 1)
+	require.Zero(t, failed)
+	require.Equal(t, uint64(3), submitted[0].ContractId)
+	require.True(t, isClaimed(3))
+}
+
+func TestClaimBackoff(t *testing.T) {
+	backoff := newClaimBackoff(time.Minute, 5*time.Minute)
+	require.Equal(t, time.Minute, backoff.next(false))
+	require.Equal(t, 2*time.Minute, backoff.next(true))
+	require.Equal(t, 4*time.Minute, backoff.next(true))
+	require.Equal(t, 5*time.Minute, backoff.next(true))
+	require.Equal(t, 5*time.Minute, backoff.next(true))
+	require.Equal(t, time.Minute, backoff.next(false))
+
+	// the max can't be below the interval
+	backoff = newClaimBackoff(time.Minute, 0)
+	require.Equal(t, time.Minute, backoff.next(true))
+}
+
+func TestDrainClaims(t *testing.T) {
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	cdc := codec.NewProtoCodec(interfaceRegistry)
+	kb := cKeys.NewInMemory(cdc)
+	info, _, err := kb.NewMnemonic("provider", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+	pub, err := info.GetPubKey()
+	require.NoError(t, err)
+
+	testConfig := newTestConfig()
+	testConfig.ProviderPubKey, err = common.NewPubKeyFromCrypto(pub)
+	require.NoError(t, err)
+	proxy := NewProxy(testConfig)
+
+	claimed := NewClaim(3, types.GetRandomPubKey(), 6, "sig3")
+	claimed.Claimed = true
+	require.NoError(t, proxy.ClaimStore.Batch([]Claim{
+		NewClaim(1, types.GetRandomPubKey(), 1, "sig1"), // below any threshold, drained anyway
+		NewClaim(2, types.GetRandomPubKey(), 4, "sig2"), // broadcast fails
+		claimed,
+	}))
+
+	// each drain is signed with its own timestamp, a signature is only accepted
+	// once
+	timestamp := time.Now().Add(-time.Minute).Unix()
+	drain := func() (int, DrainSummary) {
+		timestamp++
+		req, err := http.NewRequest(http.MethodPost, RoutesDrainClaims, nil)
+		require.NoError(t, err)
+		signAdmin(t, kb, "provider", req, nil, timestamp)
+		response := httptest.NewRecorder()
+		proxy.getRouter().ServeHTTP(response, req)
+		var summary DrainSummary
+		if response.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &summary))
+		}
+		return response.Code, summary
+	}
+
+	// no claim key configured
+	code, _ := drain()
+	require.Equal(t, http.StatusServiceUnavailable, code)
+
+	chain := &mockChainClient{fail: map[uint64]bool{2: true}}
+	proxy.Chain = chain
+	code, summary := drain()
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, DrainSummary{Submitted: 2, Succeeded: 1, Failed: 1}, summary)
+	require.Len(t, chain.claims, 1)
+	require.Equal(t, uint64(1), chain.claims[0].ContractId)
+
+	claim, err := proxy.ClaimStore.Get("1")
+	require.NoError(t, err)
+	require.True(t, claim.Claimed)
+	claim, err = proxy.ClaimStore.Get("2")
+	require.NoError(t, err)
+	require.False(t, claim.Claimed)
+
+	// only the failed claim is left to drain
+	chain.fail = nil
+	code, summary = drain()
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, DrainSummary{Submitted: 1, Succeeded: 1}, summary)
+}
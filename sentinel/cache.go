@@ -0,0 +1,291 @@
+package sentinel
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	CacheHeader = "X-Ark-Cache"
+	CacheHit    = "HIT"
+	CacheMiss   = "MISS"
+	CacheBypass = "BYPASS"
+)
+
+// cache partitions, a response is only served to the requests of the partition
+// it was cached for. Free tier responses may come from a less synced upstream,
+// they are never served to paid requests.
+const (
+	CachePartitionFree = "free"
+	CachePartitionPaid = "paid"
+)
+
+var cachePartitions = []string{CachePartitionFree, CachePartitionPaid}
+
+// cachePartition returns the cache partition of a request
+func cachePartition(contractId uint64) string {
+	if contractId == 0 {
+		return CachePartitionFree
+	}
+	return CachePartitionPaid
+}
+
+// cacheStatus is the value of the cache header, e.g. "HIT; partition=paid"
+func cacheStatus(status, partition string) string {
+	return fmt.Sprintf("%s; partition=%s", status, partition)
+}
+
+type cachedResponse struct {
+	key     string
+	code    int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// ResponseCache is a bounded LRU cache of upstream responses
+type ResponseCache struct {
+	lock         sync.Mutex
+	ttl          time.Duration
+	maxEntries   int
+	maxEntrySize int
+	entries      map[string]*list.Element
+	lru          *list.List
+}
+
+func NewResponseCache(maxEntries, maxEntrySize int, ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		ttl:          ttl,
+		maxEntries:   maxEntries,
+		maxEntrySize: maxEntrySize,
+		entries:      make(map[string]*list.Element),
+		lru:          list.New(),
+	}
+}
+
+// newResponseCaches creates a cache for each partition of the given services.
+// Each partition evicts its own entries, a hot free tier key can't evict the
+// responses cached for paid requests.
+func newResponseCaches(services []string, maxEntries, maxEntrySize int, ttl time.Duration) map[string]map[string]*ResponseCache {
+	caches := make(map[string]map[string]*ResponseCache)
+	for _, service := range services {
+		caches[service] = make(map[string]*ResponseCache)
+		for _, partition := range cachePartitions {
+			caches[service][partition] = NewResponseCache(maxEntries, maxEntrySize, ttl)
+		}
+	}
+	return caches
+}
+
+func (c *ResponseCache) Get(key string, now time.Time) (cachedResponse, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return cachedResponse{}, false
+	}
+	resp := elem.Value.(cachedResponse)
+	if !now.Before(resp.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return cachedResponse{}, false
+	}
+	c.lru.MoveToFront(elem)
+	return resp, true
+}
+
+// Set caches the response, responses larger than the max entry size are
+// ignored, and the least recently used responses are evicted when full
+func (c *ResponseCache) Set(key string, code int, header http.Header, body []byte, now time.Time) {
+	if len(body) > c.maxEntrySize || c.maxEntries <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	resp := cachedResponse{
+		key:     key,
+		code:    code,
+		header:  header,
+		body:    body,
+		expires: now.Add(c.ttl),
+	}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = resp
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(resp)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(cachedResponse).key)
+	}
+}
+
+func (c *ResponseCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+func responseCacheKey(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, r.Method)
+	_, _ = io.WriteString(hash, "\n")
+	_, _ = io.WriteString(hash, r.URL.Path)
+	_, _ = io.WriteString(hash, "?")
+	_, _ = io.WriteString(hash, r.URL.RawQuery)
+	_, _ = io.WriteString(hash, "\n")
+	_, _ = hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// isCacheable returns whether the request is idempotent and can be served
+// from the cache: any GET request, or a POST of one of the given json-rpc
+// methods. Batched json-rpc requests are never cached.
+func isCacheable(r *http.Request, body []byte, methods []string) bool {
+	switch r.Method {
+	case http.MethodGet:
+		return true
+	case http.MethodPost:
+		var rpc struct {
+			Method string `json:"method"`
+		}
+		if err := json.Unmarshal(body, &rpc); err != nil {
+			return false
+		}
+		for _, method := range methods {
+			if rpc.Method == method {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cacheRecorder forwards the response to the client, and keeps a copy of it
+// as long as it fits in the cache. The headers are copied as sent by the
+// upstream, before the writers it forwards to add their own (compression).
+type cacheRecorder struct {
+	http.ResponseWriter
+	code     int
+	header   http.Header
+	body     bytes.Buffer
+	maxSize  int
+	tooLarge bool
+}
+
+func (c *cacheRecorder) WriteHeader(code int) {
+	c.code = code
+	if c.header == nil && code >= http.StatusOK {
+		c.header = c.ResponseWriter.Header().Clone()
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *cacheRecorder) Write(b []byte) (int, error) {
+	if c.header == nil {
+		c.header = c.ResponseWriter.Header().Clone()
+	}
+	if !c.tooLarge {
+		if c.body.Len()+len(b) > c.maxSize {
+			c.tooLarge = true
+			c.body.Reset()
+		} else {
+			c.body.Write(b)
+		}
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *cacheRecorder) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// serveCached serves the request from the cache of the service, if any, in
+// the partition of the request. Requests reaching this point have been
+// authorized (and metered) already. It returns false when the request has to
+// be proxied, along with the recorder to proxy it through, to cache the
+// response.
+func (p Proxy) serveCached(w http.ResponseWriter, r *http.Request, service string, contractId uint64) (bool, http.ResponseWriter, func()) {
+	partitions, ok := p.ResponseCaches[service]
+	if !ok {
+		return false, w, func() {}
+	}
+	partition := cachePartition(contractId)
+	cache := partitions[partition]
+	if p.cacheBypassed(contractId) {
+		w.Header().Set(CacheHeader, cacheStatus(CacheBypass, partition))
+		return false, w, func() {}
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			p.logger.Error("fail to read request body", "error", err)
+			return false, w, func() {}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if !isCacheable(r, body, p.Config.CacheableMethods) {
+		return false, w, func() {}
+	}
+
+	key := responseCacheKey(r, body)
+	if resp, ok := cache.Get(key, time.Now()); ok {
+		for name, values := range resp.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set(CacheHeader, cacheStatus(CacheHit, partition))
+		w.WriteHeader(resp.code)
+		if _, err := w.Write(resp.body); err != nil {
+			p.logger.Error("fail to write cached response", "error", err)
+		}
+		return true, w, func() {}
+	}
+
+	w.Header().Set(CacheHeader, cacheStatus(CacheMiss, partition))
+	// headers set before proxying are specific to this request, only the
+	// upstream headers are cached
+	before := w.Header().Clone()
+	recorder := &cacheRecorder{ResponseWriter: w, code: http.StatusOK, maxSize: cache.maxEntrySize}
+	done := func() {
+		if recorder.code != http.StatusOK || recorder.tooLarge {
+			return
+		}
+		header := recorder.header
+		if header == nil {
+			header = w.Header().Clone()
+		}
+		for name := range before {
+			header.Del(name)
+		}
+		cache.Set(key, recorder.code, header, recorder.body.Bytes(), time.Now())
+	}
+	return false, recorder, done
+}
+
+// cacheBypassed returns whether the contract opted out of the cache
+func (p Proxy) cacheBypassed(contractId uint64) bool {
+	if contractId == 0 {
+		return false
+	}
+	contractConf, err := p.ContractConfigStore.Get(contractId)
+	if err != nil {
+		p.logger.Error("failed to fetch contract configuration", "error", err, "contract_id", contractId)
+		return false
+	}
+	return contractConf.NoCache
+}
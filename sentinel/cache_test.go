@@ -0,0 +1,198 @@
+package sentinel
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestResponseCacheEviction(t *testing.T) {
+	cache := NewResponseCache(2, 10, time.Minute)
+	now := time.Now()
+
+	cache.Set("a", http.StatusOK, nil, []byte("aaa"), now)
+	cache.Set("b", http.StatusOK, nil, []byte("bbb"), now)
+	_, ok := cache.Get("a", now)
+	require.True(t, ok)
+
+	// least recently used entry is evicted
+	cache.Set("c", http.StatusOK, nil, []byte("ccc"), now)
+	require.Equal(t, 2, cache.Len())
+	_, ok = cache.Get("b", now)
+	require.False(t, ok)
+	resp, ok := cache.Get("a", now)
+	require.True(t, ok)
+	require.Equal(t, []byte("aaa"), resp.body)
+
+	// entries larger than the max size aren't cached
+	cache.Set("d", http.StatusOK, nil, []byte("way too large"), now)
+	_, ok = cache.Get("d", now)
+	require.False(t, ok)
+	require.Equal(t, 2, cache.Len())
+
+	// expired entries are dropped
+	_, ok = cache.Get("a", now.Add(time.Minute))
+	require.False(t, ok)
+	require.Equal(t, 1, cache.Len())
+}
+
+func TestResponseCacheConcurrency(t *testing.T) {
+	cache := NewResponseCache(10, 100, time.Minute)
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mismatches int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("%d", (i+j)%20)
+				cache.Set(key, http.StatusOK, nil, []byte(key), now)
+				if resp, ok := cache.Get(key, now); ok && string(resp.body) != key {
+					atomic.AddInt32(&mismatches, 1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.Zero(t, atomic.LoadInt32(&mismatches))
+	require.Equal(t, 10, cache.Len())
+}
+
+func TestServeCached(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"result":"0x1"}`))
+	}))
+	defer upstream.Close()
+
+	testConfig := newTestConfig()
+	testConfig.CacheServices = []string{common.BTCService.String()}
+	testConfig.CacheableMethods = []string{"eth_chainId"}
+	testConfig.CacheTTLSec = 60
+	testConfig.CacheMaxEntries = 10
+	testConfig.CacheMaxEntrySize = 1024
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Id = 5
+	proxy.MemStore.Put(contract)
+	router := proxy.getRouter()
+
+	serve := func(method string, nonce int64, body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=5:%d", nonce), strings.NewReader(body))
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		require.Equal(t, http.StatusOK, response.Code)
+		return response
+	}
+
+	response := serve(http.MethodGet, 1, "")
+	require.Equal(t, cacheStatus(CacheMiss, CachePartitionPaid), response.Header().Get(CacheHeader))
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// served from the cache, but still metered
+	response = serve(http.MethodGet, 2, "")
+	require.Equal(t, cacheStatus(CacheHit, CachePartitionPaid), response.Header().Get(CacheHeader))
+	require.Equal(t, `{"result":"0x1"}`, response.Body.String())
+	require.Equal(t, "application/json", response.Header().Get("Content-Type"))
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	claim, err := proxy.ClaimStore.Get("5")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), claim.Nonce)
+
+	// cacheable json-rpc method
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`
+	require.Equal(t, cacheStatus(CacheMiss, CachePartitionPaid), serve(http.MethodPost, 3, body).Header().Get(CacheHeader))
+	require.Equal(t, cacheStatus(CacheHit, CachePartitionPaid), serve(http.MethodPost, 4, body).Header().Get(CacheHeader))
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// other methods bypass the cache
+	body = `{"jsonrpc":"2.0","id":1,"method":"eth_sendRawTransaction","params":["0x00"]}`
+	require.Empty(t, serve(http.MethodPost, 5, body).Header().Get(CacheHeader))
+	require.Empty(t, serve(http.MethodPost, 6, body).Header().Get(CacheHeader))
+	require.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestCachePartitions(t *testing.T) {
+	var calls int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = rw.Write([]byte(req.URL.Path))
+	}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.CacheServices = []string{common.BTCService.String()}
+	testConfig.CacheTTLSec = 60
+	testConfig.CacheMaxEntries = 2
+	testConfig.CacheMaxEntrySize = 1024
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	proxy.MemStore.SetHeight(20)
+	newContract := func(id uint64) {
+		contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+		contract.Authorization = types.ContractAuthorization_OPEN
+		contract.Id = id
+		proxy.MemStore.Put(contract)
+	}
+	newContract(1)
+	newContract(2)
+	noCache := proxy.CreateDefaultContractConfig(2)
+	noCache.NoCache = true
+	require.NoError(t, proxy.ContractConfigStore.Set(noCache))
+	router := proxy.getRouter()
+
+	nonces := map[uint64]int64{}
+	serve := func(path string, contractId uint64) string {
+		if contractId > 0 {
+			nonces[contractId]++
+			path = fmt.Sprintf("%s?arkauth=%d:%d", path, contractId, nonces[contractId])
+		}
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		require.Equal(t, http.StatusOK, response.Code)
+		return response.Header().Get(CacheHeader)
+	}
+
+	// a response cached for the free tier isn't served to paid requests
+	require.Equal(t, cacheStatus(CacheMiss, CachePartitionFree), serve("/btc-mainnet-fullnode/a", 0))
+	require.Equal(t, cacheStatus(CacheHit, CachePartitionFree), serve("/btc-mainnet-fullnode/a", 0))
+	require.Equal(t, cacheStatus(CacheMiss, CachePartitionPaid), serve("/btc-mainnet-fullnode/a", 1))
+	require.Equal(t, cacheStatus(CacheHit, CachePartitionPaid), serve("/btc-mainnet-fullnode/a", 1))
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// free tier keys only evict free tier entries
+	for _, key := range []string{"b", "c", "d"} {
+		require.Equal(t, cacheStatus(CacheMiss, CachePartitionFree), serve("/btc-mainnet-fullnode/"+key, 0))
+	}
+	partitions := proxy.ResponseCaches[common.BTCService.String()]
+	require.Equal(t, 2, partitions[CachePartitionFree].Len())
+	require.Equal(t, 1, partitions[CachePartitionPaid].Len())
+	require.Equal(t, cacheStatus(CacheHit, CachePartitionPaid), serve("/btc-mainnet-fullnode/a", 1))
+	require.Equal(t, cacheStatus(CacheMiss, CachePartitionFree), serve("/btc-mainnet-fullnode/a", 0))
+
+	// a contract opting out of the cache always reaches the upstream
+	atomic.StoreInt32(&calls, 0)
+	require.Equal(t, cacheStatus(CacheBypass, CachePartitionPaid), serve("/btc-mainnet-fullnode/a", 2))
+	require.Equal(t, cacheStatus(CacheBypass, CachePartitionPaid), serve("/btc-mainnet-fullnode/a", 2))
+	require.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	require.Equal(t, 1, partitions[CachePartitionPaid].Len())
+}
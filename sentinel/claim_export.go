@@ -0,0 +1,147 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ClaimExportVersion is the version of the claim export format, bumped on
+// incompatible changes
+const ClaimExportVersion = 1
+
+// ClaimExport is the content of a claim store, to move it to another host
+type ClaimExport struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Claims     []Claim   `json:"claims"`
+}
+
+// ClaimFilter selects claims, zero values match every claim
+type ClaimFilter struct {
+	ContractId uint64
+	Claimed    *bool
+}
+
+func (f ClaimFilter) Match(claim Claim) bool {
+	if f.ContractId > 0 && claim.ContractId != f.ContractId {
+		return false
+	}
+	if f.Claimed != nil && claim.Claimed != *f.Claimed {
+		return false
+	}
+	return true
+}
+
+// ClaimImportResult tells what an import changed
+type ClaimImportResult struct {
+	Imported  int      // claims written
+	Unchanged int      // claims already present as is
+	Lowered   []uint64 // contracts whose nonce was lowered, forced imports only
+}
+
+// OpenClaimStore opens the claim store of a sentinel from outside of it. The
+// store is locked while open, it fails to open while the sentinel runs.
+func OpenClaimStore(folder string) (*ClaimStore, error) {
+	if len(folder) == 0 {
+		return nil, fmt.Errorf("claim store location is required")
+	}
+	info, err := os.Stat(folder)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open claim store %s: %w", folder, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("claim store %s is not a directory", folder)
+	}
+	store, err := NewClaimStore(folder)
+	if err != nil {
+		return nil, fmt.Errorf("%w (is a sentinel running on it?)", err)
+	}
+	return store, nil
+}
+
+// Export returns the claims of the store, ordered by contract id
+func (s *ClaimStore) Export(now time.Time) ClaimExport {
+	claims := s.List()
+	sort.Slice(claims, func(i, j int) bool { return claims[i].ContractId < claims[j].ContractId })
+	if claims == nil {
+		claims = []Claim{}
+	}
+	return ClaimExport{
+		Version:    ClaimExportVersion,
+		ExportedAt: now.UTC(),
+		Claims:     claims,
+	}
+}
+
+// Import writes the claims to the store. A claim with a lower nonce than the
+// one held for its contract would let the client replay paid nonces and lose
+// unsettled income, the import is refused unless forced. Nothing is written
+// when the import is refused.
+func (s *ClaimStore) Import(claims []Claim, force bool) (ClaimImportResult, error) {
+	var result ClaimImportResult
+	var lower []string
+	items := make([]Claim, 0, len(claims))
+	for _, claim := range claims {
+		if claim.ContractId == 0 {
+			return result, fmt.Errorf("claim without contract id")
+		}
+		if s.Has(claim.Key()) {
+			current, err := s.Get(claim.Key())
+			if err != nil {
+				return result, err
+			}
+			if current.equals(claim) {
+				result.Unchanged++
+				continue
+			}
+			if claim.Nonce < current.Nonce {
+				lower = append(lower, fmt.Sprintf("%d (%d < %d)", claim.ContractId, claim.Nonce, current.Nonce))
+				result.Lowered = append(result.Lowered, claim.ContractId)
+			}
+		}
+		items = append(items, claim)
+	}
+	if len(lower) > 0 && !force {
+		result.Lowered = nil
+		return result, fmt.Errorf("claims with a lower nonce than the stored ones: %s", strings.Join(lower, ", "))
+	}
+	if err := s.Batch(items); err != nil {
+		return result, err
+	}
+	result.Imported = len(items)
+	return result, nil
+}
+
+func (c Claim) equals(other Claim) bool {
+	return c.ContractId == other.ContractId &&
+		c.Provider.Equals(other.Provider) &&
+		c.Spender.Equals(other.Spender) &&
+		c.Nonce == other.Nonce &&
+		c.Signature == other.Signature &&
+		c.SignedNonce == other.SignedNonce &&
+		c.Claimed == other.Claimed
+}
+
+// WriteClaimExport writes the export as indented json
+func WriteClaimExport(w io.Writer, export ClaimExport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+// ReadClaimExport reads an export, of the current version only
+func ReadClaimExport(r io.Reader) (ClaimExport, error) {
+	var export ClaimExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return export, fmt.Errorf("fail to decode claim export: %w", err)
+	}
+	if export.Version != ClaimExportVersion {
+		return export, fmt.Errorf("unsupported claim export version %d, expected %d", export.Version, ClaimExportVersion)
+	}
+	return export, nil
+}
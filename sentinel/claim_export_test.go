@@ -0,0 +1,79 @@
+package sentinel
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func TestClaimExportImport(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenClaimStore(dir)
+	require.NoError(t, err)
+
+	// the store is locked while open
+	_, err = OpenClaimStore(dir)
+	require.Error(t, err)
+	_, err = OpenClaimStore("")
+	require.Error(t, err)
+
+	spender := types.GetRandomPubKey()
+	claimed := NewClaim(2, spender, 7, "sig2")
+	claimed.Claimed = true
+	require.NoError(t, store.Set(NewClaim(9, spender, 30, "sig9")))
+	require.NoError(t, store.Set(claimed))
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteClaimExport(&buf, store.Export(time.Now())))
+	require.NoError(t, store.Close())
+
+	export, err := ReadClaimExport(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, ClaimExportVersion, export.Version)
+	require.Len(t, export.Claims, 2)
+	require.Equal(t, uint64(2), export.Claims[0].ContractId)
+	require.True(t, export.Claims[0].Claimed)
+
+	// import on another host
+	target, err := OpenClaimStore(t.TempDir())
+	require.NoError(t, err)
+	defer target.Close()
+	require.NoError(t, target.Set(NewClaim(9, spender, 40, "newer")))
+
+	// the stored nonce of contract 9 is higher, nothing is imported
+	_, err = target.Import(export.Claims, false)
+	require.ErrorContains(t, err, "9 (30 < 40)")
+	require.False(t, target.Has("2"))
+
+	result, err := target.Import(export.Claims, true)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Imported)
+	require.Equal(t, []uint64{9}, result.Lowered)
+	for _, claim := range export.Claims {
+		stored, err := target.Get(claim.Key())
+		require.NoError(t, err)
+		require.True(t, stored.equals(claim))
+	}
+
+	// importing again changes nothing
+	result, err = target.Import(export.Claims, false)
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Imported)
+	require.Equal(t, 2, result.Unchanged)
+
+	// filters
+	yes := true
+	require.True(t, ClaimFilter{}.Match(claimed))
+	require.True(t, ClaimFilter{ContractId: 2, Claimed: &yes}.Match(claimed))
+	require.False(t, ClaimFilter{ContractId: 9}.Match(claimed))
+	no := false
+	require.False(t, ClaimFilter{Claimed: &no}.Match(claimed))
+
+	_, err = ReadClaimExport(strings.NewReader(`{"version":2,"claims":[]}`))
+	require.ErrorContains(t, err, "unsupported claim export version")
+}
@@ -1,24 +1,50 @@
 package sentinel
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/syndtr/goleveldb/leveldb/storage"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
+// how the claims of the paid requests are written to the claim store
+const (
+	ClaimWriteThrough = "write-through"
+	ClaimWriteBack    = "write-back"
+)
+
+// ClaimStore holds the claims of the contracts, with their usage and seen
+// nonces. In write-back mode, the claims written by the paid requests are
+// held in memory and only written to disk when flushed, so a hot contract
+// isn't bound by a disk write per request. The reads see the pending writes.
 type ClaimStore struct {
-	logger zerolog.Logger
-	db     *leveldb.DB
+	logger    zerolog.Logger
+	db        *leveldb.DB
+	lock      sync.Mutex
+	writeBack bool
+	pending   map[string][]byte // pending writes by key, nil for deletes
 }
 
+// writes are synced to disk so the latest nonce of a contract survives a
+// crash, otherwise a replayed nonce could be accepted after a restart. In
+// write-back mode, the nonces of the last flush interval are lost on a crash.
+var claimWriteOptions = &opt.WriteOptions{Sync: true}
+
 type Claim struct {
 	Provider   common.PubKey `json:"provider"`
 	ContractId uint64        `json:"contract_id"`
@@ -26,8 +52,15 @@ type Claim struct {
 	Nonce      int64         `json:"nonce"`
 	Signature  string        `json:"signature"`
 	Claimed    bool          `json:"claimed"`
+	// nonce the signature is over, when below the nonce: requests served by
+	// a session closed before its units were spent aren't signed for
+	SignedNonce int64 `json:"signed_nonce,omitempty"`
 }
 
+// clients decode claims as api.Claim, the conversion fails to build when the
+// two drift apart
+var _ = api.Claim(Claim{})
+
 func NewClaim(contractId uint64, spender common.PubKey, nonce int64, signature string) Claim {
 	return Claim{
 		ContractId: contractId,
@@ -50,25 +83,94 @@ func NewClaimStore(levelDbFolder string) (*ClaimStore, error) {
 			return nil, fmt.Errorf("fail to in memory open level db: %w", err)
 		}
 	} else {
+		if err := os.MkdirAll(levelDbFolder, 0o755); err != nil {
+			return nil, fmt.Errorf("fail to create level db folder %s: %w", levelDbFolder, err)
+		}
 		db, err = leveldb.OpenFile(levelDbFolder, nil)
+		if lerrors.IsCorrupted(err) {
+			log.Warn().Err(err).Str("folder", levelDbFolder).Msg("level db is corrupted, attempt to recover")
+			db, err = leveldb.RecoverFile(levelDbFolder, nil)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("fail to open level db %s: %w", levelDbFolder, err)
 		}
 	}
 	return &ClaimStore{
-		logger: log.With().Str("module", "claim-storage").Logger(),
-		db:     db,
+		logger:  log.With().Str("module", "claim-storage").Logger(),
+		db:      db,
+		pending: make(map[string][]byte),
 	}, nil
 }
 
+// SetWriteBack sets whether the claims of the paid requests are held in
+// memory until flushed, rather than written to disk right away. The pending
+// writes are flushed when it is disabled.
+func (s *ClaimStore) SetWriteBack(enabled bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.writeBack = enabled
+	if enabled {
+		return nil
+	}
+	return s.flush()
+}
+
+// write writes the values by key, nil for deletes. The writes of the paid
+// requests are pending until flushed in write-back mode, the other ones are
+// written to disk right away and supersede the pending writes of their keys.
+func (s *ClaimStore) write(values map[string][]byte, paid bool) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if paid && s.writeBack {
+		for key, buf := range values {
+			s.pending[key] = buf
+		}
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	for key, buf := range values {
+		if buf == nil {
+			batch.Delete([]byte(key))
+		} else {
+			batch.Put([]byte(key), buf)
+		}
+	}
+	if err := s.db.Write(batch, claimWriteOptions); err != nil {
+		return err
+	}
+	for key := range values {
+		delete(s.pending, key)
+	}
+	return nil
+}
+
+// get returns the value of the key, pending or on disk, and whether it was
+// found
+func (s *ClaimStore) get(key string) ([]byte, bool, error) {
+	s.lock.Lock()
+	buf, ok := s.pending[key]
+	s.lock.Unlock()
+	if ok {
+		// removed, not flushed yet
+		return buf, buf != nil, nil
+	}
+	buf, err := s.db.Get([]byte(key), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return buf, true, nil
+}
+
 func (s *ClaimStore) Set(item Claim) error {
-	key := item.Key()
 	buf, err := json.Marshal(item)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("fail to marshal to claim store item")
 		return err
 	}
-	if err := s.db.Put([]byte(key), buf, nil); err != nil {
+	if err := s.write(map[string][]byte{item.Key(): buf}, false); err != nil {
 		s.logger.Error().Err(err).Msg("fail to set claim item")
 		return err
 	}
@@ -76,25 +178,124 @@ func (s *ClaimStore) Set(item Claim) error {
 }
 
 func (s *ClaimStore) Batch(items []Claim) error {
-	batch := new(leveldb.Batch)
+	values := make(map[string][]byte, len(items))
 	for _, item := range items {
-		key := item.Key()
 		buf, err := json.Marshal(item)
 		if err != nil {
 			s.logger.Error().Err(err).Msg("fail to marshal to claim store item")
 			return err
 		}
-		batch.Put([]byte(key), buf)
+		values[item.Key()] = buf
+	}
+	return s.write(values, false)
+}
+
+// Commit writes at once the usage of a contract with, when given, its claim
+// and its seen nonces. It is called for each paid request, the writes are
+// pending until flushed in write-back mode.
+func (s *ClaimStore) Commit(item *Claim, usage ContractUsage, seen *SeenNonces) error {
+	values := make(map[string][]byte, 3)
+	if item != nil {
+		buf, err := json.Marshal(item)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("fail to marshal to claim store item")
+			return err
+		}
+		values[item.Key()] = buf
+	}
+	buf, err := json.Marshal(usage)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("fail to marshal contract usage")
+		return err
+	}
+	values[usage.Key()] = buf
+	if seen != nil {
+		buf, err := json.Marshal(seen)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("fail to marshal seen nonces")
+			return err
+		}
+		values[seen.Key()] = buf
 	}
-	return s.db.Write(batch, nil)
+	return s.write(values, true)
+}
+
+// Flush writes the pending writes to disk
+func (s *ClaimStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.flush()
+}
+
+func (s *ClaimStore) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	for key, buf := range s.pending {
+		if buf == nil {
+			batch.Delete([]byte(key))
+		} else {
+			batch.Put([]byte(key), buf)
+		}
+	}
+	if err := s.db.Write(batch, claimWriteOptions); err != nil {
+		return err
+	}
+	s.pending = make(map[string][]byte)
+	return nil
+}
+
+// Flusher periodically writes the pending writes to disk, until stop is
+// closed
+func (s *ClaimStore) Flusher(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := s.Flush(); err != nil {
+			s.logger.Error().Err(err).Msg("fail to flush claim store")
+		}
+	}
+}
+
+// GetUsage returns the usage of a contract, empty if it wasn't used
+func (s *ClaimStore) GetUsage(contractId uint64) (ContractUsage, error) {
+	usage := ContractUsage{ContractId: contractId}
+	buf, ok, err := s.get(usage.Key())
+	if err != nil || !ok {
+		return usage, err
+	}
+	if err := json.Unmarshal(buf, &usage); err != nil {
+		s.logger.Error().Err(err).Msg("fail to unmarshal contract usage")
+		return usage, err
+	}
+	return usage, nil
+}
+
+// GetSeenNonces returns the seen nonces of a contract, if any
+func (s *ClaimStore) GetSeenNonces(contractId uint64) (SeenNonces, bool, error) {
+	seen := SeenNonces{ContractId: contractId}
+	buf, ok, err := s.get(seen.Key())
+	if err != nil || !ok {
+		return seen, false, err
+	}
+	if err := json.Unmarshal(buf, &seen); err != nil {
+		s.logger.Error().Err(err).Msg("fail to unmarshal seen nonces")
+		return seen, false, err
+	}
+	return seen, true, nil
 }
 
 func (s *ClaimStore) Get(key string) (item Claim, err error) {
-	ok, err := s.db.Has([]byte(key), nil)
+	buf, ok, err := s.get(key)
 	if !ok || err != nil {
 		return
 	}
-	buf, err := s.db.Get([]byte(key), nil)
 	if err := json.Unmarshal(buf, &item); err != nil {
 		s.logger.Error().Err(err).Msg("fail to unmarshal to claim store item")
 		return item, err
@@ -103,25 +304,40 @@ func (s *ClaimStore) Get(key string) (item Claim, err error) {
 	return
 }
 
+// HasUnclaimed returns whether the contract has income not claimed yet
+func (s *ClaimStore) HasUnclaimed(contractId uint64) bool {
+	claim, err := s.Get(strconv.FormatUint(contractId, 10))
+	if err != nil {
+		return false
+	}
+	return claim.Nonce > 0 && !claim.Claimed
+}
+
 // Has check whether the given key exist in key value store
 func (s *ClaimStore) Has(key string) (ok bool) {
-	ok, _ = s.db.Has([]byte(key), nil)
+	_, ok, _ = s.get(key)
 	return
 }
 
 // Remove remove the given item from key values store
 func (s *ClaimStore) Remove(key string) error {
-	return s.db.Delete([]byte(key), nil)
+	return s.write(map[string][]byte{key: nil}, false)
 }
 
 // List send back tx out to retry depending on arg failed only
 func (s *ClaimStore) List() []Claim {
+	if err := s.Flush(); err != nil {
+		s.logger.Error().Err(err).Msg("fail to flush claim store")
+	}
 	iterator := s.db.NewIterator(util.BytesPrefix([]byte(nil)), nil)
 	defer iterator.Release()
 	var results []Claim
 	for iterator.Next() {
 		buf := iterator.Value()
-		if len(buf) == 0 {
+		// the usage and seen nonces of the contracts are stored alongside
+		// their claims
+		key := iterator.Key()
+		if len(buf) == 0 || bytes.HasPrefix(key, []byte(usageKeyPrefix)) || bytes.HasPrefix(key, []byte(seenNoncesKeyPrefix)) {
 			continue
 		}
 
@@ -137,8 +353,21 @@ func (s *ClaimStore) List() []Claim {
 	return results
 }
 
-// Close underlying db
+// Ping ensures the store is writable, by writing and removing a probe key.
+// The probe value is empty, so it is never returned by List.
+func (s *ClaimStore) Ping() error {
+	key := []byte("__ping__")
+	if err := s.db.Put(key, nil, claimWriteOptions); err != nil {
+		return err
+	}
+	return s.db.Delete(key, claimWriteOptions)
+}
+
+// Close flushes the pending writes and closes the underlying db
 func (s *ClaimStore) Close() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
 	return s.db.Close()
 }
 
@@ -149,3 +378,12 @@ func (s *ClaimStore) GetInternalDb() *leveldb.DB {
 func (c Claim) Key() string {
 	return strconv.FormatUint(c.ContractId, 10)
 }
+
+// ClaimNonce returns the nonce the claim is submitted with, the one its
+// signature is over
+func (c Claim) ClaimNonce() int64 {
+	if c.SignedNonce > 0 {
+		return c.SignedNonce
+	}
+	return c.Nonce
+}
@@ -15,7 +15,7 @@ type ClaimStoreSuite struct {
 	dir string
 }
 
-func (s *ClaimStoreSuite) SetUpTest() {
+func (s *ClaimStoreSuite) SetupTest() {
 	var err error
 	s.dir, err = ioutil.TempDir("/tmp", "claim-store")
 	require.NoError(s.T(), err)
@@ -43,8 +43,87 @@ func (s *ClaimStoreSuite) TestStore() {
 	require.False(s.T(), store.Has(claim.Key()))
 }
 
-func (s *ClaimStoreSuite) TearDownSuite(t *testing.T) {
-	defer os.RemoveAll(s.dir)
+func (s *ClaimStoreSuite) TestReopen() {
+	store, err := NewClaimStore(s.dir)
+	require.NoError(s.T(), err)
+
+	claim1 := NewClaim(uint64(60), types.GetRandomPubKey(), 12, "signature")
+	claim2 := NewClaim(uint64(61), types.GetRandomPubKey(), 34, "signature")
+	require.NoError(s.T(), store.Set(claim1))
+	require.NoError(s.T(), store.Set(claim2))
+	claim1.Nonce = 15
+	require.NoError(s.T(), store.Set(claim1))
+	require.NoError(s.T(), store.Close())
+
+	// simulate a restart of sentinel
+	store, err = NewClaimStore(s.dir)
+	require.NoError(s.T(), err)
+	defer store.Close()
+
+	require.True(s.T(), store.Has(claim1.Key()))
+	claim, err := store.Get(claim1.Key())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int64(15), claim.Nonce)
+	require.Equal(s.T(), claim1.Spender, claim.Spender)
+
+	claim, err = store.Get(claim2.Key())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int64(34), claim.Nonce)
+}
+
+func (s *ClaimStoreSuite) TestWriteBack() {
+	store, err := NewClaimStore(s.dir)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), store.SetWriteBack(true))
+
+	// the claims of the paid requests are pending, but read back
+	claim1 := NewClaim(uint64(70), types.GetRandomPubKey(), 12, "signature")
+	usage := ContractUsage{ContractId: claim1.ContractId}
+	require.NoError(s.T(), store.Commit(&claim1, usage, nil))
+	require.True(s.T(), store.Has(claim1.Key()))
+	claim, err := store.Get(claim1.Key())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int64(12), claim.Nonce)
+	onDisk, err := store.GetInternalDb().Has([]byte(claim1.Key()), nil)
+	require.NoError(s.T(), err)
+	require.False(s.T(), onDisk)
+
+	// until flushed
+	require.NoError(s.T(), store.Flush())
+	onDisk, err = store.GetInternalDb().Has([]byte(claim1.Key()), nil)
+	require.NoError(s.T(), err)
+	require.True(s.T(), onDisk)
+
+	// the other writes are written right away, over the pending ones
+	claim1.Nonce = 20
+	require.NoError(s.T(), store.Commit(&claim1, usage, nil))
+	claim1.Claimed = true
+	require.NoError(s.T(), store.Set(claim1))
+	claim, err = store.Get(claim1.Key())
+	require.NoError(s.T(), err)
+	require.True(s.T(), claim.Claimed)
+	require.NoError(s.T(), store.Remove(claim1.Key()))
+	require.False(s.T(), store.Has(claim1.Key()))
+
+	// the pending claims are listed, and written when closed
+	claim2 := NewClaim(uint64(71), types.GetRandomPubKey(), 34, "signature")
+	require.NoError(s.T(), store.Commit(&claim2, ContractUsage{ContractId: claim2.ContractId}, nil))
+	require.Len(s.T(), store.List(), 1)
+	claim2.Nonce = 40
+	require.NoError(s.T(), store.Commit(&claim2, ContractUsage{ContractId: claim2.ContractId}, nil))
+	require.NoError(s.T(), store.Close())
+
+	store, err = NewClaimStore(s.dir)
+	require.NoError(s.T(), err)
+	defer store.Close()
+	require.False(s.T(), store.Has(claim1.Key()))
+	claim, err = store.Get(claim2.Key())
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), int64(40), claim.Nonce)
+}
+
+func (s *ClaimStoreSuite) TearDownTest() {
+	_ = os.RemoveAll(s.dir)
 }
 
 func TestClaimStoreSuite(t *testing.T) {
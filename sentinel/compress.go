@@ -0,0 +1,246 @@
+package sentinel
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// content codings proxied responses can be compressed with, by order of
+// preference when the client accepts several of them with the same quality
+var responseEncoders = []struct {
+	name    string
+	encoder func(w io.Writer) io.WriteCloser
+}{
+	{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+	{"deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }},
+}
+
+// acceptedEncoding returns the preferred content coding the Accept-Encoding
+// header of a request accepts, empty when none
+func acceptedEncoding(accept string) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if len(coding) == 0 {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		qualities[coding] = quality
+	}
+
+	best, bestQuality := "", 0.0
+	for _, enc := range responseEncoders {
+		quality, ok := qualities[enc.name]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > bestQuality {
+			best, bestQuality = enc.name, quality
+		}
+	}
+	return best
+}
+
+// compressWriter compresses the response with the given content coding when
+// it is compressible: its media type is allowed and it isn't smaller than
+// the min size. The body is buffered until the min size is reached, the
+// status is only sent once it is known whether the response is compressed.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	newEncoder  func(w io.Writer) io.WriteCloser
+	minSize     int
+	types       []string
+	code        int
+	wroteHeader bool
+	decided     bool
+	buf         bytes.Buffer
+	encoder     io.WriteCloser // nil when the response is sent as is
+}
+
+// compressResponse wraps the writer to compress the response with an
+// encoding the client accepts, when enabled. The returned func finalizes the
+// response, it must be called once the response is written.
+func (p Proxy) compressResponse(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	config := p.config()
+	if !config.CompressResponses || r.Method == http.MethodHead {
+		return w, func() {}
+	}
+	encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+	if len(encoding) == 0 {
+		return w, func() {}
+	}
+	cw := &compressWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		minSize:        config.CompressMinBytes,
+		types:          config.CompressContentTypes,
+		code:           http.StatusOK,
+	}
+	for _, enc := range responseEncoders {
+		if enc.name == encoding {
+			cw.newEncoder = enc.encoder
+		}
+	}
+	return cw, func() {
+		if err := cw.Close(); err != nil {
+			p.logger.Error("fail to compress response", "error", err, "encoding", encoding)
+		}
+	}
+}
+
+// allowedType returns whether the media type of the response is one of the
+// types compressed
+func (c *compressWriter) allowedType() bool {
+	mediaType, _, err := mime.ParseMediaType(c.Header().Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, allowed := range c.types {
+		if strings.EqualFold(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressible returns whether the response can be compressed, regardless
+// of its size
+func (c *compressWriter) compressible() bool {
+	switch c.code {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	// already encoded by the upstream
+	if len(c.Header().Get("Content-Encoding")) > 0 {
+		return false
+	}
+	return c.allowedType()
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.wroteHeader {
+		return
+	}
+	// informational responses are followed by the final one
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	c.code = code
+	c.wroteHeader = true
+	if code == http.StatusSwitchingProtocols || !c.compressible() {
+		c.start(false)
+		return
+	}
+	if length, err := strconv.Atoi(c.Header().Get("Content-Length")); err == nil {
+		c.start(length >= c.minSize)
+	}
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.decided {
+		if c.encoder != nil {
+			return c.encoder.Write(b)
+		}
+		return c.ResponseWriter.Write(b)
+	}
+	n, _ := c.buf.Write(b)
+	if c.buf.Len() >= c.minSize {
+		if err := c.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// start sends the status, along with the buffered body, compressed or not
+func (c *compressWriter) start(compress bool) error {
+	c.decided = true
+	header := c.Header()
+	if compress {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", c.encoding)
+		c.encoder = c.newEncoder(c.ResponseWriter)
+	}
+	if compress || c.allowedType() {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	c.ResponseWriter.WriteHeader(c.code)
+	if c.buf.Len() == 0 {
+		return nil
+	}
+	body := c.buf.Bytes()
+	c.buf = bytes.Buffer{}
+	if c.encoder != nil {
+		_, err := c.encoder.Write(body)
+		return err
+	}
+	_, err := c.ResponseWriter.Write(body)
+	return err
+}
+
+// Flush sends what has been written so far, so streamed responses keep
+// flowing. A response flushed before reaching the min size is sent as is.
+func (c *compressWriter) Flush() {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if !c.decided {
+		_ = c.start(false)
+	}
+	if f, ok := c.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close sends the buffered body, if any, and ends the compressed stream
+func (c *compressWriter) Close() error {
+	if !c.decided {
+		if !c.wroteHeader && c.buf.Len() == 0 {
+			// nothing written, the response is left to the server
+			return nil
+		}
+		if err := c.start(c.buf.Len() >= c.minSize); err != nil {
+			return err
+		}
+	}
+	if c.encoder == nil {
+		return nil
+	}
+	return c.encoder.Close()
+}
+
+// Hijack is required to proxy websockets, an upgraded connection is never
+// compressed
+func (c *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	c.decided = true
+	return h.Hijack()
+}
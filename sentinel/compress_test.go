@@ -0,0 +1,189 @@
+package sentinel
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	require.Equal(t, "", acceptedEncoding(""))
+	require.Equal(t, "", acceptedEncoding("identity"))
+	require.Equal(t, "", acceptedEncoding("br"))
+	require.Equal(t, "gzip", acceptedEncoding("gzip"))
+	require.Equal(t, "gzip", acceptedEncoding("deflate, gzip"))
+	require.Equal(t, "deflate", acceptedEncoding("gzip;q=0.5, deflate"))
+	require.Equal(t, "deflate", acceptedEncoding("gzip;q=0, *"))
+	require.Equal(t, "gzip", acceptedEncoding("*"))
+	require.Equal(t, "", acceptedEncoding("gzip;q=0, deflate;q=0"))
+	require.Equal(t, "gzip", acceptedEncoding("br;q=1.0, GZIP;q=0.8, deflate;q=bad"))
+}
+
+func TestCompressResponses(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	large := fmt.Sprintf(`{"result":"%s"}`, strings.Repeat("0x00", 1024))
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/small":
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = rw.Write([]byte(`{"result":"0x1"}`))
+		case "/binary":
+			rw.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = rw.Write([]byte(large))
+		case "/encoded":
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(rw)
+			_, _ = gz.Write([]byte(large))
+			_ = gz.Close()
+		default:
+			rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = rw.Write([]byte(large))
+		}
+	}))
+	defer upstream.Close()
+
+	testConfig := newTestConfig()
+	testConfig.CompressResponses = true
+	testConfig.CompressMinBytes = 512
+	testConfig.CompressContentTypes = []string{"application/json"}
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Id = 5
+	proxy.MemStore.Put(contract)
+	router := proxy.getRouter()
+
+	nonce := int64(0)
+	serve := func(path, accept string) *httptest.ResponseRecorder {
+		nonce++
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/btc-mainnet-fullnode%s?arkauth=5:%d", path, nonce), nil)
+		if len(accept) > 0 {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		require.Equal(t, http.StatusOK, response.Code)
+		return response
+	}
+
+	// uncompressed when the client doesn't accept any encoding
+	plain := serve("/large", "")
+	require.Empty(t, plain.Header().Get("Content-Encoding"))
+	require.Equal(t, large, plain.Body.String())
+
+	compressed := serve("/large", "gzip")
+	require.Equal(t, "gzip", compressed.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", compressed.Header().Get("Vary"))
+	require.Empty(t, compressed.Header().Get("Content-Length"))
+	require.Less(t, compressed.Body.Len(), plain.Body.Len())
+	gz, err := gzip.NewReader(compressed.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, large, string(body))
+
+	deflated := serve("/large", "gzip;q=0.1, deflate")
+	require.Equal(t, "deflate", deflated.Header().Get("Content-Encoding"))
+	require.Less(t, deflated.Body.Len(), plain.Body.Len())
+	zr, err := zlib.NewReader(deflated.Body)
+	require.NoError(t, err)
+	body, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, large, string(body))
+
+	// smaller than the min size
+	small := serve("/small", "gzip")
+	require.Empty(t, small.Header().Get("Content-Encoding"))
+	require.Equal(t, `{"result":"0x1"}`, small.Body.String())
+
+	// media type not compressed
+	binary := serve("/binary", "gzip")
+	require.Empty(t, binary.Header().Get("Content-Encoding"))
+	require.Equal(t, large, binary.Body.String())
+
+	// already compressed by the upstream, passed through as is
+	encoded := serve("/encoded", "gzip")
+	require.Equal(t, "gzip", encoded.Header().Get("Content-Encoding"))
+	gz, err = gzip.NewReader(encoded.Body)
+	require.NoError(t, err)
+	body, err = io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, large, string(body))
+
+	// every request is paid for, compressed or not
+	claim, err := proxy.ClaimStore.Get("5")
+	require.NoError(t, err)
+	require.Equal(t, nonce, claim.Nonce)
+
+	// disabled
+	testConfig.CompressResponses = false
+	proxy.Reload(testConfig, proxy.config().proxies)
+	require.Empty(t, serve("/large", "gzip").Header().Get("Content-Encoding"))
+}
+
+func TestCompressStreaming(t *testing.T) {
+	newWriter := func(recorder *httptest.ResponseRecorder) *compressWriter {
+		cw := &compressWriter{
+			ResponseWriter: recorder,
+			encoding:       "gzip",
+			newEncoder:     responseEncoders[0].encoder,
+			minSize:        8,
+			types:          []string{"application/json"},
+			code:           http.StatusOK,
+		}
+		cw.Header().Set("Content-Type", "application/json")
+		return cw
+	}
+
+	// each part is sent compressed as it is flushed
+	recorder := httptest.NewRecorder()
+	cw := newWriter(recorder)
+	_, err := cw.Write([]byte(`{"id":1}`))
+	require.NoError(t, err)
+	require.False(t, recorder.Flushed)
+	cw.Flush()
+	require.True(t, recorder.Flushed)
+	require.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(strings.NewReader(recorder.Body.String()))
+	require.NoError(t, err)
+	part := make([]byte, len(`{"id":1}`))
+	_, err = io.ReadFull(gz, part)
+	require.NoError(t, err)
+	require.Equal(t, `{"id":1}`, string(part))
+
+	_, err = cw.Write([]byte(`{"id":2}`))
+	require.NoError(t, err)
+	require.NoError(t, cw.Close())
+	gz, err = gzip.NewReader(recorder.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, `{"id":1}{"id":2}`, string(body))
+
+	// flushed before reaching the min size, the stream is sent as is
+	recorder = httptest.NewRecorder()
+	cw = newWriter(recorder)
+	_, err = cw.Write([]byte(`{}`))
+	require.NoError(t, err)
+	cw.Flush()
+	require.True(t, recorder.Flushed)
+	require.Equal(t, `{}`, recorder.Body.String())
+	_, err = cw.Write([]byte(`{"id":3}`))
+	require.NoError(t, err)
+	require.NoError(t, cw.Close())
+	require.Empty(t, recorder.Header().Get("Content-Encoding"))
+	require.Equal(t, `{}{"id":3}`, recorder.Body.String())
+}
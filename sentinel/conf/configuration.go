@@ -1,8 +1,13 @@
 package conf
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
@@ -11,23 +16,211 @@ import (
 )
 
 type TLSConfiguration struct {
-	Cert string `json:"tls_certificate"`
-	Key  string `json:"tls_key"`
+	Cert         string `json:"tls_certificate"`
+	Key          string `json:"tls_key"`
+	Port         string `json:"tls_port"`          // port https is served on
+	RedirectHTTP bool   `json:"tls_redirect_http"` // redirect the requests on the http port to https
+	SelfSigned   bool   `json:"tls_self_signed"`   // serve https with a generated self-signed certificate when no certificate is set, for development
+}
+
+// ProxyLimits bounds the requests proxied to an upstream service, zero values
+// inherit the limit of the enclosing scope (global -> service -> contract)
+type ProxyLimits struct {
+	MaxRequestBodyBytes   int64 `json:"max_request_body_bytes"`
+	MaxResponseBytes      int64 `json:"max_response_bytes"`
+	UpstreamTimeoutSec    int   `json:"upstream_timeout_sec"`
+	MaxConcurrentRequests int   `json:"max_concurrent_requests"` // requests of a contract served at the same time
+}
+
+// Merge returns the limits overridden by the non zero values of the given
+// limits
+func (l ProxyLimits) Merge(override ProxyLimits) ProxyLimits {
+	if override.MaxRequestBodyBytes > 0 {
+		l.MaxRequestBodyBytes = override.MaxRequestBodyBytes
+	}
+	if override.MaxResponseBytes > 0 {
+		l.MaxResponseBytes = override.MaxResponseBytes
+	}
+	if override.UpstreamTimeoutSec > 0 {
+		l.UpstreamTimeoutSec = override.UpstreamTimeoutSec
+	}
+	if override.MaxConcurrentRequests > 0 {
+		l.MaxConcurrentRequests = override.MaxConcurrentRequests
+	}
+	return l
+}
+
+// Tighten returns the limits lowered by the non zero values of the given
+// limits, it never raises a limit
+func (l ProxyLimits) Tighten(override ProxyLimits) ProxyLimits {
+	if override.MaxRequestBodyBytes > 0 && (l.MaxRequestBodyBytes == 0 || override.MaxRequestBodyBytes < l.MaxRequestBodyBytes) {
+		l.MaxRequestBodyBytes = override.MaxRequestBodyBytes
+	}
+	if override.MaxResponseBytes > 0 && (l.MaxResponseBytes == 0 || override.MaxResponseBytes < l.MaxResponseBytes) {
+		l.MaxResponseBytes = override.MaxResponseBytes
+	}
+	if override.UpstreamTimeoutSec > 0 && (l.UpstreamTimeoutSec == 0 || override.UpstreamTimeoutSec < l.UpstreamTimeoutSec) {
+		l.UpstreamTimeoutSec = override.UpstreamTimeoutSec
+	}
+	if override.MaxConcurrentRequests > 0 && (l.MaxConcurrentRequests == 0 || override.MaxConcurrentRequests < l.MaxConcurrentRequests) {
+		l.MaxConcurrentRequests = override.MaxConcurrentRequests
+	}
+	return l
+}
+
+// FreeTier is what the requests not paid by a contract are served with
+type FreeTier struct {
+	Enabled      bool  `json:"enabled"`        // unpaid requests are refused when disabled
+	RateLimit    int   `json:"rate_limit"`     // requests per minute of each address, zero rate limits every request
+	MaxBodyBytes int64 `json:"max_body_bytes"` // largest request body
+}
+
+// ServiceRewrites are the request transformations of the services, by service
+// name
+type ServiceRewrites map[string]ServiceRewrite
+
+// redacted replaces secrets in the printed and served configuration
+const redacted = "<redacted>"
+
+// ServiceRewrite transforms the requests proxied to the upstreams of a
+// service, once they are authorized and paid for
+type ServiceRewrite struct {
+	StripPrefix     string            `json:"strip_prefix"`      // removed from the path requested below the service name
+	AddPrefix       string            `json:"add_prefix"`        // added to the path once stripped
+	RegexMatch      string            `json:"regex_match"`       // replaced in the path once prefixed
+	RegexReplace    string            `json:"regex_replace"`     // replacement of the regex matches, $1 expands to the first group
+	Headers         map[string]string `json:"headers"`           // static headers set on the upstream requests, such as api keys
+	ForwardClientIP bool              `json:"forward_client_ip"` // send the client ip as the only X-Forwarded-For, dropping the inbound one
+
+	regex *regexp.Regexp // compiled regex match
+}
+
+func (r ServiceRewrite) IsEmpty() bool {
+	return len(r.StripPrefix) == 0 && len(r.AddPrefix) == 0 && len(r.RegexMatch) == 0 && len(r.Headers) == 0 && !r.ForwardClientIP
+}
+
+// PathRegexp returns the compiled regex match, nil when there is none or it
+// doesn't compile
+func (r ServiceRewrite) PathRegexp() *regexp.Regexp {
+	if r.regex != nil || len(r.RegexMatch) == 0 {
+		return r.regex
+	}
+	regex, err := regexp.Compile(r.RegexMatch)
+	if err != nil {
+		return nil
+	}
+	return regex
+}
+
+// Redacted returns the rewrite with the values of its headers hidden, as they
+// usually are secrets
+func (r ServiceRewrite) Redacted() ServiceRewrite {
+	r.regex = nil
+	if len(r.Headers) == 0 {
+		return r
+	}
+	headers := make(map[string]string, len(r.Headers))
+	for name := range r.Headers {
+		headers[name] = redacted
+	}
+	r.Headers = headers
+	return r
+}
+
+// MarshalJSON marshals the redacted rewrite, so the configuration can be
+// served and logged
+func (r ServiceRewrite) MarshalJSON() ([]byte, error) {
+	type rewrite ServiceRewrite
+	return json.Marshal(rewrite(r.Redacted()))
 }
 
 type Configuration struct {
-	Moniker                     string           `json:"moniker"`
-	Website                     string           `json:"website"`
-	Description                 string           `json:"description"`
-	Location                    string           `json:"location"`
-	Port                        string           `json:"port"`
-	SourceChain                 string           `json:"source_chain"` // base url for arceo block chain
-	EventStreamHost             string           `json:"event_stream_host"`
-	ClaimStoreLocation          string           `json:"claim_store_location"`           // file location where claims are stored
-	ContractConfigStoreLocation string           `json:"contract_config_store_location"` // file location where contract configurations are stored
-	ProviderPubKey              common.PubKey    `json:"provider_pubkey"`
-	FreeTierRateLimit           int              `json:"free_tier_rate_limit"`
-	TLS                         TLSConfiguration `json:"tls"`
+	Moniker                     string                 `json:"moniker"`
+	Website                     string                 `json:"website"`
+	Description                 string                 `json:"description"`
+	Location                    string                 `json:"location"`
+	Port                        string                 `json:"port"`
+	SourceChain                 string                 `json:"source_chain"` // base url for arceo block chain
+	EventStreamHost             string                 `json:"event_stream_host"`
+	SourceChainGRPC             string                 `json:"source_chain_grpc"`              // grpc address of the arkeo chain, contracts are resynced from it when the event stream reconnects, empty disables
+	EventStreamTimeoutSec       int                    `json:"event_stream_timeout_sec"`       // seconds without a new block after which the event stream is reconnected
+	EventStreamMaxBackoffSec    int                    `json:"event_stream_max_backoff_sec"`   // max seconds between two reconnection attempts of the event stream
+	BlockGapThreshold           int64                  `json:"block_gap_threshold"`            // missed blocks above which a gap in the event stream is reported
+	ClaimStoreLocation          string                 `json:"claim_store_location"`           // file location where claims are stored
+	ClaimWriteMode              string                 `json:"claim_write_mode"`               // how the claims of paid requests are stored: "write-through" (on each request) or "write-back" (held in memory until flushed)
+	ClaimFlushIntervalSec       int                    `json:"claim_flush_interval_sec"`       // seconds between two flushes of the claims held in memory in write-back mode
+	ContractConfigStoreLocation string                 `json:"contract_config_store_location"` // file location where contract configurations are stored
+	ContractConfigCacheSize     int                    `json:"contract_config_cache_size"`     // max number of contract configurations cached in memory, zero disables
+	ContractConfigCacheTTLSec   int                    `json:"contract_config_cache_ttl_sec"`  // seconds a contract configuration is cached for
+	StateStoreLocation          string                 `json:"state_store_location"`           // file location where bans, sessions and flags are stored
+	MemStoreMaxEntries          int                    `json:"memstore_max_entries"`           // contracts held in memory above which the least recently used expired ones are evicted, zero is unbounded
+	ProviderPubKey              common.PubKey          `json:"provider_pubkey"`
+	StrictAuth                  bool                   `json:"strict_auth"`                     // refuse requests with an invalid arkauth rather than serving them on the free tier
+	TrustedProxyHops            int                    `json:"trusted_proxy_hops"`              // trusted proxies appending to X-Forwarded-For in front of sentinel, zero takes its left-most address
+	SignatureDomain             bool                   `json:"signature_domain"`                // require arkauth signatures over the chain id and provider pubkey, the bare contract_id:nonce is refused
+	FreeTierEnabled             bool                   `json:"free_tier_enabled"`               // serve the requests not paid by a contract, they are answered with a 402 when disabled
+	FreeTierRateLimit           int                    `json:"free_tier_rate_limit"`            // free tier requests per minute of each address, zero rate limits every request (429) while the free tier is enabled
+	VerifyRateLimit             int                    `json:"verify_rate_limit"`               // challenges of the verify endpoint signed per minute for each address
+	FreeTierRemainingHeader     bool                   `json:"free_tier_remaining_header"`      // send the number of requests left to free tier clients
+	FreeTierUpgradeThreshold    int                    `json:"free_tier_upgrade_threshold"`     // requests left below which free tier clients get an upgrade hint, zero disables
+	FreeTierClientMode          string                 `json:"free_tier_client_mode"`           // how clients sharing an address are told apart: "fingerprint", "token" or empty to disable
+	FreeTierClientRateLimit     int                    `json:"free_tier_client_rate_limit"`     // free tier requests per minute of each client of an address, capped by the rate limit of the address
+	FreeTierClientSecret        string                 `json:"free_tier_client_secret"`         // key of the client fingerprints and tokens, a random key is used when empty
+	FreeTierClientTokenTTLSec   int                    `json:"free_tier_client_token_ttl_sec"`  // seconds a client token is valid for
+	FreeTierMaxBodyBytes        int64                  `json:"free_tier_max_body_bytes"`        // largest request body of the free tier, paid requests get the limits
+	MaxExpectedQueriesPerMinute int                    `json:"max_expected_queries_per_minute"` // spend velocity (per contract) above which an alert is raised, zero disables
+	MaxNonceIncrement           int64                  `json:"max_nonce_increment"`             // max increase of the nonce of a paid request over the last one (or its cost when higher), zero disables
+	NonceWindow                 int64                  `json:"nonce_window"`                    // nonces below the highest one of a contract accepted out of order if not used yet, zero only accepts increasing nonces
+	SessionMaxDurationSec       int                    `json:"session_max_duration_sec"`        // longest a paid session is valid for, zero disables the sessions
+	SessionMaxRequests          int64                  `json:"session_max_requests"`            // most nonce units a paid session can span
+	AlertCooldownSec            int                    `json:"alert_cooldown_sec"`              // minimum seconds between two alerts of the same contract
+	OperatorAlertSinks          []string               `json:"operator_alert_sinks"`            // where alerts about the provider are sent: "log", "webhook" and/or "exec", empty disables
+	OperatorAlertWebhook        string                 `json:"operator_alert_webhook"`          // url the alerts are posted to, as json
+	OperatorAlertCommand        string                 `json:"operator_alert_command"`          // command run for each alert, with the alert as json on its stdin
+	ReadyMaxBlockLag            int64                  `json:"ready_max_block_lag"`             // max number of blocks sentinel can be behind the chain and still be ready
+	ReadyServices               []string               `json:"ready_services"`                  // services whose upstream must be reachable to be ready
+	AuditLogLocation            string                 `json:"audit_log_location"`              // file location where served requests are recorded, empty disables
+	AccessLogLocation           string                 `json:"access_log_location"`             // file location of the access log, "stdout" or empty to disable
+	AccessLogFormat             string                 `json:"access_log_format"`               // format of the access log records: "json" or "text" (logfmt)
+	AccessLogLevel              string                 `json:"access_log_level"`                // level the access log records are written at: "debug", "info", "warn" or "error"
+	AccessLogMaxSizeMB          int                    `json:"access_log_max_size_mb"`          // size above which the access log is rotated, zero never rotates
+	AccessLogMaxBackups         int                    `json:"access_log_max_backups"`          // number of rotated access logs kept
+	AutoClaimInterval           int                    `json:"auto_claim_interval"`             // seconds between two auto claim runs, zero disables
+	AutoClaimThreshold          int64                  `json:"auto_claim_threshold"`            // accrued income above which a claim is submitted
+	AutoClaimDeadlineBlocks     int64                  `json:"auto_claim_deadline_blocks"`      // claims are submitted once the settlement deadline is within this many blocks
+	AutoClaimBatchSize          int                    `json:"auto_claim_batch_size"`           // max number of claims submitted in a single transaction
+	AutoClaimMaxBackoffSec      int                    `json:"auto_claim_max_backoff_sec"`      // max seconds between two auto claim runs while broadcasts fail
+	ClaimKeyPath                string                 `json:"claim_key_path"`                  // keyring directory holding the key signing claim transactions
+	ClaimKeyName                string                 `json:"claim_key_name"`                  // name of the key signing claim transactions
+	ChainId                     string                 `json:"chain_id"`
+	GasPrices                   string                 `json:"gas_prices"`
+	CacheServices               []string               `json:"cache_services"`              // services whose responses are cached, empty disables
+	CacheableMethods            []string               `json:"cacheable_methods"`           // json-rpc methods cached on POST requests, GET requests are always cached
+	CacheTTLSec                 int                    `json:"cache_ttl_sec"`               // seconds a cached response is served for
+	CacheMaxEntries             int                    `json:"cache_max_entries"`           // max number of cached responses per service
+	CacheMaxEntrySize           int                    `json:"cache_max_entry_size"`        // max size (in bytes) of a cached response body
+	CompressResponses           bool                   `json:"compress_responses"`          // compress the proxied responses with an encoding the client accepts
+	CompressMinBytes            int                    `json:"compress_min_bytes"`          // responses smaller than this (in bytes) are sent uncompressed
+	CompressContentTypes        []string               `json:"compress_content_types"`      // media types of the responses compressed
+	SignResponsesMaxBytes       int                    `json:"sign_responses_max_bytes"`    // larger responses of the contracts asking for signed responses are sent unsigned
+	Limits                      ProxyLimits            `json:"limits"`                      // limits of proxied requests, zero disables
+	ServiceLimits               map[string]ProxyLimits `json:"service_limits"`              // per service overrides of the limits
+	ServiceRewrites             ServiceRewrites        `json:"service_rewrites"`            // per service transformations of the proxied requests
+	ServiceFreeTiers            map[string]FreeTier    `json:"service_free_tiers"`          // per service overrides of the free tier, the others get the global one
+	UpstreamBalancing           string                 `json:"upstream_balancing"`          // how requests are spread among the healthy upstreams of a service: "failover" or "round-robin"
+	UpstreamHealthInterval      int                    `json:"upstream_health_interval"`    // seconds between two health checks of the upstreams, zero disables
+	UpstreamHealthPath          string                 `json:"upstream_health_path"`        // path probed with a GET by the health checks, upstreams are only dialed when empty
+	SubscriptionQuotaPerRate    int64                  `json:"subscription_quota_per_rate"` // queries per block a subscription gets for each unit of its rate, zero is unlimited
+	DefaultPerUserRateLimit     int                    `json:"default_per_user_rate_limit"` // per user rate limit (per minute) of newly opened contracts
+	DefaultPerUserBurstSize     int                    `json:"default_per_user_burst_size"` // per user burst size of newly opened contracts
+	WebSocketRateLimit          string                 `json:"websocket_rate_limit"`        // what counts against the rate limit of websockets: "connections" or "messages"
+	GRPCPort                    string                 `json:"grpc_port"`                   // port of the grpc proxy, empty disables
+	GRPCServices                []string               `json:"grpc_services"`               // services whose upstreams speak grpc, served on the grpc port
+	AdminPort                   string                 `json:"admin_port"`                  // port of the admin api, signed by the provider key, empty disables
+	ShutdownTimeoutSec          int                    `json:"shutdown_timeout_sec"`        // seconds the requests being served are given to complete on shutdown
+	ConfigFile                  string                 `json:"config_file"`                 // env file (KEY=VALUE lines) read on start and reload, empty disables
+	USDPrices                   map[string]float64     `json:"usd_prices"`                  // price in USD of one unit of each denom, used by contract daily spend caps
+	TLS                         TLSConfiguration       `json:"tls"`
 }
 
 // Simple helper function to read an environment or return a default value
@@ -38,6 +231,83 @@ func getEnv(key, defaultVal string) string {
 	return defaultVal
 }
 
+// Simple helper function to read an integer environment or return a default value
+func getEnvInt(key string, defaultVal int) int {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultVal
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil {
+		panic(fmt.Errorf("env var %s is not an integer: %s", key, err))
+	}
+	return i
+}
+
+// Simple helper function to read a boolean environment or return a default value
+func getEnvBool(key string, defaultVal bool) bool {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultVal
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(val))
+	if err != nil {
+		panic(fmt.Errorf("env var %s is not a boolean: %s", key, err))
+	}
+	return b
+}
+
+// Simple helper function to read a comma separated environment or return a default value
+func getEnvList(key string, defaultVal []string) []string {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultVal
+	}
+	var list []string
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Simple helper function to read a comma separated list of denom:price pairs
+// from the environment
+func getEnvPrices(key string) map[string]float64 {
+	prices := make(map[string]float64)
+	for _, item := range getEnvList(key, nil) {
+		denom, value, ok := strings.Cut(item, ":")
+		if !ok {
+			panic(fmt.Errorf("env var %s: %s is not a denom:price pair", key, item))
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || price < 0 {
+			panic(fmt.Errorf("env var %s: bad price of %s: %s", key, denom, value))
+		}
+		prices[strings.TrimSpace(denom)] = price
+	}
+	return prices
+}
+
+// Simple helper function to read a comma separated list of Name:value
+// headers from the environment
+func getEnvHeaders(key string) map[string]string {
+	var headers map[string]string
+	for _, item := range getEnvList(key, nil) {
+		name, value, ok := strings.Cut(item, ":")
+		if !ok || len(strings.TrimSpace(name)) == 0 {
+			panic(fmt.Errorf("env var %s: %s is not a Name:value header", key, item))
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[http.CanonicalHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
 func loadVarString(key string) string {
 	val, ok := os.LookupEnv(key)
 	if !ok {
@@ -70,18 +340,163 @@ func loadVarInt(key string) int {
 	return i
 }
 
+// loadProxyLimits reads the limits from the env vars with the given prefix
+func loadProxyLimits(prefix string, defaults ProxyLimits) ProxyLimits {
+	return ProxyLimits{
+		MaxRequestBodyBytes:   int64(getEnvInt(prefix+"MAX_REQUEST_BODY_BYTES", int(defaults.MaxRequestBodyBytes))),
+		MaxResponseBytes:      int64(getEnvInt(prefix+"MAX_RESPONSE_BYTES", int(defaults.MaxResponseBytes))),
+		UpstreamTimeoutSec:    getEnvInt(prefix+"UPSTREAM_TIMEOUT_SEC", defaults.UpstreamTimeoutSec),
+		MaxConcurrentRequests: getEnvInt(prefix+"MAX_CONCURRENT_REQUESTS", defaults.MaxConcurrentRequests),
+	}
+}
+
+// loadServiceLimits reads the limits overrides of each service, prefixed with
+// the service name (ie BTC_MAINNET_FULLNODE_MAX_REQUEST_BODY_BYTES)
+func loadServiceLimits() map[string]ProxyLimits {
+	limits := make(map[string]ProxyLimits)
+	for serviceName := range common.ServiceLookup {
+		prefix := strings.ToUpper(strings.ReplaceAll(serviceName, "-", "_")) + "_"
+		serviceLimits := loadProxyLimits(prefix, ProxyLimits{})
+		if serviceLimits != (ProxyLimits{}) {
+			limits[serviceName] = serviceLimits
+		}
+	}
+	return limits
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// loadServiceFreeTiers reads the free tier overrides of each service, prefixed
+// with the service name (ie ETH_MAINNET_FULLNODE_FREE_TIER_RATE_LIMIT). Only
+// the services whose free tier differs from the global one are kept.
+func loadServiceFreeTiers(defaults FreeTier) map[string]FreeTier {
+	freeTiers := make(map[string]FreeTier)
+	for serviceName := range common.ServiceLookup {
+		prefix := strings.ToUpper(strings.ReplaceAll(serviceName, "-", "_")) + "_"
+		freeTier := FreeTier{
+			Enabled:      getEnvBool(prefix+"FREE_TIER_ENABLED", defaults.Enabled),
+			RateLimit:    getEnvInt(prefix+"FREE_TIER_RATE_LIMIT", defaults.RateLimit),
+			MaxBodyBytes: int64(getEnvInt(prefix+"FREE_TIER_MAX_BODY_BYTES", int(defaults.MaxBodyBytes))),
+		}
+		if freeTier != defaults {
+			freeTiers[serviceName] = freeTier
+		}
+	}
+	return freeTiers
+}
+
+// loadServiceRewrites reads the request transformations of each service,
+// prefixed with the service name (ie ETH_MAINNET_FULLNODE_HEADERS)
+func loadServiceRewrites() ServiceRewrites {
+	rewrites := make(ServiceRewrites)
+	for serviceName := range common.ServiceLookup {
+		prefix := strings.ToUpper(strings.ReplaceAll(serviceName, "-", "_")) + "_"
+		rewrite := ServiceRewrite{
+			StripPrefix:     getEnv(prefix+"STRIP_PREFIX", ""),
+			AddPrefix:       getEnv(prefix+"ADD_PREFIX", ""),
+			RegexMatch:      getEnv(prefix+"REGEX_MATCH", ""),
+			RegexReplace:    getEnv(prefix+"REGEX_REPLACE", ""),
+			Headers:         getEnvHeaders(prefix + "HEADERS"),
+			ForwardClientIP: getEnvBool(prefix+"FORWARD_CLIENT_IP", false),
+		}
+		if len(rewrite.RegexMatch) > 0 {
+			regex, err := regexp.Compile(rewrite.RegexMatch)
+			if err != nil {
+				panic(fmt.Errorf("env var %sREGEX_MATCH is not a regular expression: %s", prefix, err))
+			}
+			rewrite.regex = regex
+		}
+		if !rewrite.IsEmpty() {
+			rewrites[serviceName] = rewrite
+		}
+	}
+	return rewrites
+}
+
+// ReloadConfiguration reads the configuration again, after loading the config
+// file into the env. Unlike NewConfiguration, a bad value is returned as an
+// error, so a running proxy can keep its current configuration.
+func ReloadConfiguration(configFile string) (config Configuration, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("fail to load configuration: %v", r)
+		}
+	}()
+	if len(configFile) > 0 {
+		if err := LoadEnvFile(configFile); err != nil {
+			return config, err
+		}
+	}
+	return NewConfiguration(), nil
+}
+
+// LoadEnvFile sets the env vars of a file of KEY=VALUE lines, empty lines and
+// lines starting with # are ignored. Vars removed from the file keep their
+// last value.
+func LoadEnvFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("fail to open config file %s: %w", path, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || len(strings.TrimSpace(key)) == 0 {
+			return fmt.Errorf("config file %s: line %d is not KEY=VALUE", path, n)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if err := os.Setenv(strings.TrimSpace(key), value); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
 func NewTLSConfiguration() TLSConfiguration {
 	return TLSConfiguration{
-		Cert: getEnv("TLS_CERT", ""),
-		Key:  getEnv("TLS_KEY", ""),
+		Cert:         getEnv("TLS_CERT", ""),
+		Key:          getEnv("TLS_KEY", ""),
+		Port:         getEnv("TLS_PORT", "443"),
+		RedirectHTTP: getEnvBool("TLS_REDIRECT_HTTP", true),
+		SelfSigned:   getEnvBool("TLS_SELF_SIGNED", false),
 	}
 }
 
-func (c TLSConfiguration) HasTLS() bool {
+// HasCertificate returns whether a certificate and its key are set
+func (c TLSConfiguration) HasCertificate() bool {
 	return len(c.Cert) > 0 && len(c.Key) > 0
 }
 
+// HasTLS returns whether https is served, with the certificate set or a
+// self-signed one
+func (c TLSConfiguration) HasTLS() bool {
+	return c.HasCertificate() || c.SelfSigned
+}
+
 func NewConfiguration() Configuration {
+	configFile := getEnv("CONFIG_FILE", "")
+	if len(configFile) > 0 {
+		if err := LoadEnvFile(configFile); err != nil {
+			panic(err)
+		}
+	}
+	freeTier := FreeTier{
+		Enabled:      getEnvBool("FREE_TIER_ENABLED", true),
+		RateLimit:    loadVarInt("FREE_RATE_LIMIT"),
+		MaxBodyBytes: int64(getEnvInt("FREE_TIER_MAX_BODY_BYTES", 64*1024)),
+	}
 	return Configuration{
 		Moniker:                     loadVarString("MONIKER"),
 		Website:                     loadVarString("WEBSITE"),
@@ -90,14 +505,110 @@ func NewConfiguration() Configuration {
 		Port:                        getEnv("PORT", "3636"),
 		SourceChain:                 loadVarString("SOURCE_CHAIN"),
 		EventStreamHost:             loadVarString("EVENT_STREAM_HOST"),
+		SourceChainGRPC:             getEnv("SOURCE_CHAIN_GRPC", ""),
+		EventStreamTimeoutSec:       getEnvInt("EVENT_STREAM_TIMEOUT_SEC", 60),
+		EventStreamMaxBackoffSec:    getEnvInt("EVENT_STREAM_MAX_BACKOFF_SEC", 60),
+		BlockGapThreshold:           int64(getEnvInt("BLOCK_GAP_THRESHOLD", 5)),
 		ProviderPubKey:              loadVarPubKey("PROVIDER_PUBKEY"),
-		FreeTierRateLimit:           loadVarInt("FREE_RATE_LIMIT"),
+		StrictAuth:                  getEnvBool("STRICT_AUTH", false),
+		TrustedProxyHops:            getEnvInt("TRUSTED_PROXY_HOPS", 0),
+		SignatureDomain:             getEnvBool("SIGNATURE_DOMAIN", false),
+		FreeTierEnabled:             freeTier.Enabled,
+		FreeTierRateLimit:           freeTier.RateLimit,
+		VerifyRateLimit:             getEnvInt("VERIFY_RATE_LIMIT", 10),
+		FreeTierRemainingHeader:     getEnvBool("FREE_TIER_REMAINING_HEADER", true),
+		FreeTierUpgradeThreshold:    getEnvInt("FREE_TIER_UPGRADE_THRESHOLD", 5),
+		FreeTierClientMode:          getEnv("FREE_TIER_CLIENT_MODE", ""),
+		FreeTierClientRateLimit:     getEnvInt("FREE_TIER_CLIENT_RATE_LIMIT", 0),
+		FreeTierClientSecret:        getEnv("FREE_TIER_CLIENT_SECRET", ""),
+		FreeTierClientTokenTTLSec:   getEnvInt("FREE_TIER_CLIENT_TOKEN_TTL_SEC", 86400),
+		FreeTierMaxBodyBytes:        freeTier.MaxBodyBytes,
 		ClaimStoreLocation:          loadVarString("CLAIM_STORE_LOCATION"),
+		ClaimWriteMode:              getEnv("CLAIM_WRITE_MODE", "write-through"),
+		ClaimFlushIntervalSec:       getEnvInt("CLAIM_FLUSH_INTERVAL_SEC", 1),
 		ContractConfigStoreLocation: loadVarString("CONTRACT_CONFIG_STORE_LOCATION"),
+		ContractConfigCacheSize:     getEnvInt("CONTRACT_CONFIG_CACHE_SIZE", 10000),
+		ContractConfigCacheTTLSec:   getEnvInt("CONTRACT_CONFIG_CACHE_TTL_SEC", 60),
+		StateStoreLocation:          getEnv("STATE_STORE_LOCATION", ""),
+		MemStoreMaxEntries:          getEnvInt("MEMSTORE_MAX_ENTRIES", 0),
+		MaxExpectedQueriesPerMinute: getEnvInt("MAX_EXPECTED_QUERIES_PER_MINUTE", 0),
+		MaxNonceIncrement:           int64(getEnvInt("MAX_NONCE_INCREMENT", 100)),
+		NonceWindow:                 int64(getEnvInt("NONCE_WINDOW", 0)),
+		SessionMaxDurationSec:       getEnvInt("SESSION_MAX_DURATION_SEC", 0),
+		SessionMaxRequests:          int64(getEnvInt("SESSION_MAX_REQUESTS", 1000)),
+		AlertCooldownSec:            getEnvInt("ALERT_COOLDOWN_SEC", 300),
+		OperatorAlertSinks:          getEnvList("OPERATOR_ALERT_SINKS", nil),
+		OperatorAlertWebhook:        getEnv("OPERATOR_ALERT_WEBHOOK", ""),
+		OperatorAlertCommand:        getEnv("OPERATOR_ALERT_COMMAND", ""),
+		ReadyMaxBlockLag:            int64(getEnvInt("READY_MAX_BLOCK_LAG", 10)),
+		ReadyServices:               getEnvList("READY_SERVICES", nil),
+		AuditLogLocation:            getEnv("AUDIT_LOG_LOCATION", ""),
+		AccessLogLocation:           getEnv("ACCESS_LOG_LOCATION", ""),
+		AccessLogFormat:             getEnv("ACCESS_LOG_FORMAT", "json"),
+		AccessLogLevel:              getEnv("ACCESS_LOG_LEVEL", "info"),
+		AccessLogMaxSizeMB:          getEnvInt("ACCESS_LOG_MAX_SIZE_MB", 100),
+		AccessLogMaxBackups:         getEnvInt("ACCESS_LOG_MAX_BACKUPS", 5),
+		AutoClaimInterval:           getEnvInt("AUTO_CLAIM_INTERVAL", 0),
+		AutoClaimThreshold:          int64(getEnvInt("AUTO_CLAIM_THRESHOLD", 0)),
+		AutoClaimDeadlineBlocks:     int64(getEnvInt("AUTO_CLAIM_DEADLINE_BLOCKS", 10)),
+		AutoClaimBatchSize:          getEnvInt("AUTO_CLAIM_BATCH_SIZE", 10),
+		AutoClaimMaxBackoffSec:      getEnvInt("AUTO_CLAIM_MAX_BACKOFF_SEC", 3600),
+		ClaimKeyPath:                getEnv("CLAIM_KEY_PATH", ""),
+		ClaimKeyName:                getEnv("CLAIM_KEY_NAME", ""),
+		ChainId:                     getEnv("CHAIN_ID", ""),
+		GasPrices:                   getEnv("GAS_PRICES", ""),
+		CacheServices:               getEnvList("CACHE_SERVICES", nil),
+		CacheableMethods:            getEnvList("CACHEABLE_METHODS", nil),
+		CacheTTLSec:                 getEnvInt("CACHE_TTL_SEC", 10),
+		CacheMaxEntries:             getEnvInt("CACHE_MAX_ENTRIES", 1000),
+		CacheMaxEntrySize:           getEnvInt("CACHE_MAX_ENTRY_SIZE", 1024*1024),
+		CompressResponses:           getEnvBool("COMPRESS_RESPONSES", false),
+		CompressMinBytes:            getEnvInt("COMPRESS_MIN_BYTES", 1024),
+		CompressContentTypes:        getEnvList("COMPRESS_CONTENT_TYPES", []string{"application/json", "application/javascript", "text/plain", "text/html"}),
+		SignResponsesMaxBytes:       getEnvInt("SIGN_RESPONSES_MAX_BYTES", 1024*1024),
+		Limits:                      loadProxyLimits("", ProxyLimits{MaxRequestBodyBytes: 10 * 1024 * 1024, MaxResponseBytes: 100 * 1024 * 1024, UpstreamTimeoutSec: 60}),
+		ServiceLimits:               loadServiceLimits(),
+		ServiceRewrites:             loadServiceRewrites(),
+		ServiceFreeTiers:            loadServiceFreeTiers(freeTier),
+		UpstreamBalancing:           getEnv("UPSTREAM_BALANCING", "failover"),
+		UpstreamHealthInterval:      getEnvInt("UPSTREAM_HEALTH_INTERVAL", 10),
+		UpstreamHealthPath:          getEnv("UPSTREAM_HEALTH_PATH", ""),
+		WebSocketRateLimit:          getEnv("WEBSOCKET_RATE_LIMIT", "connections"),
+		GRPCPort:                    getEnv("GRPC_PORT", ""),
+		GRPCServices:                getEnvList("GRPC_SERVICES", nil),
+		AdminPort:                   getEnv("ADMIN_PORT", ""),
+		ShutdownTimeoutSec:          getEnvInt("SHUTDOWN_TIMEOUT_SEC", 30),
+		DefaultPerUserRateLimit:     getEnvInt("DEFAULT_PER_USER_RATE_LIMIT", 600),
+		DefaultPerUserBurstSize:     getEnvInt("DEFAULT_PER_USER_BURST_SIZE", 100),
+		SubscriptionQuotaPerRate:    int64(getEnvInt("SUBSCRIPTION_QUOTA_PER_RATE", 0)),
+		ConfigFile:                  configFile,
+		USDPrices:                   getEnvPrices("USD_PRICES"),
 		TLS:                         NewTLSConfiguration(),
 	}
 }
 
+// FreeTier returns the free tier of the service, its override or else the
+// global free tier
+func (c Configuration) FreeTier(serviceName string) FreeTier {
+	if freeTier, ok := c.ServiceFreeTiers[serviceName]; ok {
+		return freeTier
+	}
+	return FreeTier{
+		Enabled:      c.FreeTierEnabled,
+		RateLimit:    c.FreeTierRateLimit,
+		MaxBodyBytes: c.FreeTierMaxBodyBytes,
+	}
+}
+
+// Redacted returns the configuration with its secrets hidden, so it can be
+// served
+func (c Configuration) Redacted() Configuration {
+	if len(c.FreeTierClientSecret) > 0 {
+		c.FreeTierClientSecret = redacted
+	}
+	return c
+}
+
 func (c Configuration) Print() {
 	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', tabwriter.AlignRight)
 	fmt.Fprintln(writer, "Moniker\t", c.Moniker)
@@ -109,9 +620,92 @@ func (c Configuration) Print() {
 	fmt.Fprintln(writer, "TLS Key\t", c.TLS.Key)
 	fmt.Fprintln(writer, "Source Chain\t", c.SourceChain)
 	fmt.Fprintln(writer, "Event Stream Host\t", c.EventStreamHost)
+	fmt.Fprintln(writer, "Source Chain GRPC\t", c.SourceChainGRPC)
+	fmt.Fprintln(writer, "Event Stream Timeout\t", fmt.Sprintf("%ds", c.EventStreamTimeoutSec))
+	fmt.Fprintln(writer, "Event Stream Max Backoff\t", fmt.Sprintf("%ds", c.EventStreamMaxBackoffSec))
+	fmt.Fprintln(writer, "Block Gap Threshold\t", fmt.Sprintf("%d blocks", c.BlockGapThreshold))
 	fmt.Fprintln(writer, "Provider PubKey\t", c.ProviderPubKey)
 	fmt.Fprintln(writer, "Claim Store Location\t", c.ClaimStoreLocation)
+	fmt.Fprintln(writer, "Claim Write Mode\t", c.ClaimWriteMode)
+	fmt.Fprintln(writer, "Claim Flush Interval\t", fmt.Sprintf("%ds", c.ClaimFlushIntervalSec))
 	fmt.Fprintln(writer, "Contract Config Store Location\t", c.ContractConfigStoreLocation)
+	fmt.Fprintln(writer, "Contract Config Cache Size\t", c.ContractConfigCacheSize)
+	fmt.Fprintln(writer, "Contract Config Cache TTL\t", fmt.Sprintf("%ds", c.ContractConfigCacheTTLSec))
+	fmt.Fprintln(writer, "State Store Location\t", c.StateStoreLocation)
+	fmt.Fprintln(writer, "MemStore Max Entries\t", c.MemStoreMaxEntries)
+	fmt.Fprintln(writer, "Strict Auth\t", c.StrictAuth)
+	fmt.Fprintln(writer, "Trusted Proxy Hops\t", c.TrustedProxyHops)
+	fmt.Fprintln(writer, "Signature Domain\t", c.SignatureDomain)
+	fmt.Fprintln(writer, "Free Tier Enabled\t", c.FreeTierEnabled)
 	fmt.Fprintln(writer, "Free Tier Rate Limit\t", fmt.Sprintf("%d requests per 1m", c.FreeTierRateLimit))
+	fmt.Fprintln(writer, "Verify Rate Limit\t", fmt.Sprintf("%d challenges per 1m", c.VerifyRateLimit))
+	fmt.Fprintln(writer, "Free Tier Remaining Header\t", c.FreeTierRemainingHeader)
+	fmt.Fprintln(writer, "Free Tier Upgrade Threshold\t", c.FreeTierUpgradeThreshold)
+	fmt.Fprintln(writer, "Free Tier Client Mode\t", c.FreeTierClientMode)
+	fmt.Fprintln(writer, "Free Tier Client Rate Limit\t", fmt.Sprintf("%d requests per 1m", c.FreeTierClientRateLimit))
+	fmt.Fprintln(writer, "Free Tier Client Token TTL\t", fmt.Sprintf("%ds", c.FreeTierClientTokenTTLSec))
+	fmt.Fprintln(writer, "Free Tier Max Request Body\t", fmt.Sprintf("%d bytes", c.FreeTierMaxBodyBytes))
+	for _, service := range sortedKeys(c.ServiceFreeTiers) {
+		fmt.Fprintln(writer, "Free Tier "+service+"\t", fmt.Sprintf("%+v", c.ServiceFreeTiers[service]))
+	}
+	fmt.Fprintln(writer, "Max Expected Queries\t", fmt.Sprintf("%d requests per 1m", c.MaxExpectedQueriesPerMinute))
+	fmt.Fprintln(writer, "Alert Cooldown\t", fmt.Sprintf("%ds", c.AlertCooldownSec))
+	fmt.Fprintln(writer, "Operator Alert Sinks\t", strings.Join(c.OperatorAlertSinks, ", "))
+	fmt.Fprintln(writer, "Operator Alert Command\t", c.OperatorAlertCommand)
+	fmt.Fprintln(writer, "Max Nonce Increment\t", c.MaxNonceIncrement)
+	fmt.Fprintln(writer, "Nonce Window\t", c.NonceWindow)
+	fmt.Fprintln(writer, "Session Max Duration\t", fmt.Sprintf("%ds", c.SessionMaxDurationSec))
+	fmt.Fprintln(writer, "Session Max Requests\t", c.SessionMaxRequests)
+	fmt.Fprintln(writer, "Ready Max Block Lag\t", c.ReadyMaxBlockLag)
+	fmt.Fprintln(writer, "Ready Services\t", strings.Join(c.ReadyServices, ", "))
+	fmt.Fprintln(writer, "Audit Log Location\t", c.AuditLogLocation)
+	fmt.Fprintln(writer, "Access Log Location\t", c.AccessLogLocation)
+	fmt.Fprintln(writer, "Access Log Format\t", c.AccessLogFormat)
+	fmt.Fprintln(writer, "Access Log Level\t", c.AccessLogLevel)
+	fmt.Fprintln(writer, "Access Log Max Size\t", fmt.Sprintf("%dMB", c.AccessLogMaxSizeMB))
+	fmt.Fprintln(writer, "Access Log Max Backups\t", c.AccessLogMaxBackups)
+	fmt.Fprintln(writer, "Auto Claim Interval\t", fmt.Sprintf("%ds", c.AutoClaimInterval))
+	fmt.Fprintln(writer, "Auto Claim Threshold\t", c.AutoClaimThreshold)
+	fmt.Fprintln(writer, "Auto Claim Deadline\t", fmt.Sprintf("%d blocks", c.AutoClaimDeadlineBlocks))
+	fmt.Fprintln(writer, "Auto Claim Batch Size\t", c.AutoClaimBatchSize)
+	fmt.Fprintln(writer, "Auto Claim Max Backoff\t", fmt.Sprintf("%ds", c.AutoClaimMaxBackoffSec))
+	fmt.Fprintln(writer, "Claim Key Path\t", c.ClaimKeyPath)
+	fmt.Fprintln(writer, "Claim Key Name\t", c.ClaimKeyName)
+	fmt.Fprintln(writer, "Chain Id\t", c.ChainId)
+	fmt.Fprintln(writer, "Cache Services\t", strings.Join(c.CacheServices, ", "))
+	fmt.Fprintln(writer, "Cacheable Methods\t", strings.Join(c.CacheableMethods, ", "))
+	fmt.Fprintln(writer, "Cache TTL\t", fmt.Sprintf("%ds", c.CacheTTLSec))
+	fmt.Fprintln(writer, "Cache Max Entries\t", c.CacheMaxEntries)
+	fmt.Fprintln(writer, "Cache Max Entry Size\t", c.CacheMaxEntrySize)
+	fmt.Fprintln(writer, "Compress Responses\t", c.CompressResponses)
+	fmt.Fprintln(writer, "Compress Min Size\t", fmt.Sprintf("%d bytes", c.CompressMinBytes))
+	fmt.Fprintln(writer, "Compress Content Types\t", strings.Join(c.CompressContentTypes, ", "))
+	fmt.Fprintln(writer, "Sign Responses Max Size\t", fmt.Sprintf("%d bytes", c.SignResponsesMaxBytes))
+	fmt.Fprintln(writer, "Max Request Body\t", fmt.Sprintf("%d bytes", c.Limits.MaxRequestBodyBytes))
+	fmt.Fprintln(writer, "Max Response\t", fmt.Sprintf("%d bytes", c.Limits.MaxResponseBytes))
+	fmt.Fprintln(writer, "Upstream Timeout\t", fmt.Sprintf("%ds", c.Limits.UpstreamTimeoutSec))
+	fmt.Fprintln(writer, "Max Concurrent Requests\t", c.Limits.MaxConcurrentRequests)
+	fmt.Fprintln(writer, "Upstream Balancing\t", c.UpstreamBalancing)
+	for _, service := range sortedKeys(c.ServiceRewrites) {
+		fmt.Fprintln(writer, "Rewrite "+service+"\t", fmt.Sprintf("%+v", c.ServiceRewrites[service].Redacted()))
+	}
+	fmt.Fprintln(writer, "Upstream Health Interval\t", fmt.Sprintf("%ds", c.UpstreamHealthInterval))
+	fmt.Fprintln(writer, "Upstream Health Path\t", c.UpstreamHealthPath)
+	fmt.Fprintln(writer, "WebSocket Rate Limit\t", c.WebSocketRateLimit)
+	fmt.Fprintln(writer, "TLS\t", c.TLS.HasTLS())
+	if c.TLS.HasTLS() {
+		fmt.Fprintln(writer, "TLS Port\t", c.TLS.Port)
+		fmt.Fprintln(writer, "TLS Redirect HTTP\t", c.TLS.RedirectHTTP)
+		fmt.Fprintln(writer, "TLS Self Signed\t", c.TLS.SelfSigned && !c.TLS.HasCertificate())
+	}
+	fmt.Fprintln(writer, "GRPC Port\t", c.GRPCPort)
+	fmt.Fprintln(writer, "GRPC Services\t", strings.Join(c.GRPCServices, ", "))
+	fmt.Fprintln(writer, "Admin Port\t", c.AdminPort)
+	fmt.Fprintln(writer, "Shutdown Timeout\t", fmt.Sprintf("%ds", c.ShutdownTimeoutSec))
+	fmt.Fprintln(writer, "Subscription Quota Per Rate\t", c.SubscriptionQuotaPerRate)
+	fmt.Fprintln(writer, "Default Per User Rate Limit\t", c.DefaultPerUserRateLimit)
+	fmt.Fprintln(writer, "Default Per User Burst Size\t", c.DefaultPerUserBurstSize)
+	fmt.Fprintln(writer, "USD Prices\t", c.USDPrices)
+	fmt.Fprintln(writer, "Config File\t", c.ConfigFile)
 	writer.Flush()
 }
@@ -1,7 +1,9 @@
 package conf
 
 import (
+	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -19,6 +21,16 @@ func TestConfiguration(t *testing.T) {
 	os.Setenv("FREE_RATE_LIMIT", "99")
 	os.Setenv("CLAIM_STORE_LOCATION", "clammy")
 	os.Setenv("CONTRACT_CONFIG_STORE_LOCATION", "configy")
+	os.Setenv("MAX_EXPECTED_QUERIES_PER_MINUTE", "120")
+	os.Setenv("READY_SERVICES", "btc-mainnet-fullnode, eth-mainnet-fullnode")
+	os.Setenv("MAX_REQUEST_BODY_BYTES", "2048")
+	os.Setenv("BTC_MAINNET_FULLNODE_UPSTREAM_TIMEOUT_SEC", "5")
+	os.Setenv("BTC_MAINNET_FULLNODE_MAX_CONCURRENT_REQUESTS", "8")
+	os.Setenv("ETH_MAINNET_FULLNODE_STRIP_PREFIX", "/eth")
+	os.Setenv("ETH_MAINNET_FULLNODE_ADD_PREFIX", "/v1/mainnet")
+	os.Setenv("ETH_MAINNET_FULLNODE_HEADERS", "x-api-key: secret, Accept:application/json")
+	os.Setenv("BTC_MAINNET_FULLNODE_FREE_TIER_RATE_LIMIT", "300")
+	os.Setenv("ETH_MAINNET_FULLNODE_FREE_TIER_ENABLED", "false")
 
 	config := NewConfiguration()
 
@@ -31,5 +43,87 @@ func TestConfiguration(t *testing.T) {
 	require.Equal(t, config.ProviderPubKey.String(), "cosmospub1addwnpepqg3523h7e7ggeh6na2lsde6s394tqxnvufsz0urld6zwl8687ue9c3dasgu")
 	require.Equal(t, config.FreeTierRateLimit, 99)
 	require.Equal(t, config.ClaimStoreLocation, "clammy")
+	require.Equal(t, config.ClaimWriteMode, "write-through")
+	require.Equal(t, config.ClaimFlushIntervalSec, 1)
 	require.Equal(t, config.ContractConfigStoreLocation, "configy")
+	require.Equal(t, config.MaxExpectedQueriesPerMinute, 120)
+	require.Equal(t, config.AlertCooldownSec, 300)
+	require.Equal(t, config.MaxNonceIncrement, int64(100))
+	require.Equal(t, config.NonceWindow, int64(0))
+	require.Equal(t, config.SessionMaxDurationSec, 0)
+	require.Equal(t, config.SessionMaxRequests, int64(1000))
+	require.Equal(t, config.ReadyMaxBlockLag, int64(10))
+	require.False(t, config.CompressResponses)
+	require.Equal(t, config.CompressMinBytes, 1024)
+	require.Equal(t, config.CompressContentTypes, []string{"application/json", "application/javascript", "text/plain", "text/html"})
+	require.Equal(t, config.SignResponsesMaxBytes, 1024*1024)
+	require.Equal(t, config.ReadyServices, []string{"btc-mainnet-fullnode", "eth-mainnet-fullnode"})
+	require.Equal(t, config.Limits, ProxyLimits{MaxRequestBodyBytes: 2048, MaxResponseBytes: 100 * 1024 * 1024, UpstreamTimeoutSec: 60})
+	require.Equal(t, config.ServiceLimits, map[string]ProxyLimits{"btc-mainnet-fullnode": {UpstreamTimeoutSec: 5, MaxConcurrentRequests: 8}})
+	require.Equal(t, config.Limits.Merge(config.ServiceLimits["btc-mainnet-fullnode"]).UpstreamTimeoutSec, 5)
+	require.Equal(t, config.ServiceRewrites, ServiceRewrites{"eth-mainnet-fullnode": {
+		StripPrefix: "/eth",
+		AddPrefix:   "/v1/mainnet",
+		Headers:     map[string]string{"X-Api-Key": "secret", "Accept": "application/json"},
+	}})
+
+	require.Equal(t, config.ServiceFreeTiers, map[string]FreeTier{
+		"btc-mainnet-fullnode": {Enabled: true, RateLimit: 300, MaxBodyBytes: 64 * 1024},
+		"eth-mainnet-fullnode": {Enabled: false, RateLimit: 99, MaxBodyBytes: 64 * 1024},
+	})
+	require.Equal(t, FreeTier{Enabled: true, RateLimit: 99, MaxBodyBytes: 64 * 1024}, config.FreeTier("bch-mainnet-fullnode"))
+	require.Equal(t, 300, config.FreeTier("btc-mainnet-fullnode").RateLimit)
+	require.False(t, config.FreeTier("eth-mainnet-fullnode").Enabled)
+
+	// injected headers are redacted when served
+	raw, err := json.Marshal(config)
+	require.NoError(t, err)
+	require.NotContains(t, string(raw), "secret")
+	require.Contains(t, string(raw), `"X-Api-Key":"<redacted>"`)
+	require.Equal(t, "secret", config.ServiceRewrites["eth-mainnet-fullnode"].Headers["X-Api-Key"])
+}
+
+func TestReloadConfiguration(t *testing.T) {
+	TestConfiguration(t)
+	configFile := filepath.Join(t.TempDir(), "sentinel.env")
+
+	// values of the config file override the env
+	require.NoError(t, os.WriteFile(configFile, []byte("# reloaded limits\nFREE_RATE_LIMIT=5\n\nMONIKER=\"reloaded\"\n"), 0o600))
+	config, err := ReloadConfiguration(configFile)
+	require.NoError(t, err)
+	require.Equal(t, config.FreeTierRateLimit, 5)
+	require.Equal(t, 5, config.FreeTier("eth-mainnet-fullnode").RateLimit) // overrides inherit the global free tier
+	require.Equal(t, config.Moniker, "reloaded")
+	require.Equal(t, config.Port, "4000")
+
+	// bad values are returned as errors
+	require.NoError(t, os.WriteFile(configFile, []byte("FREE_RATE_LIMIT=lots\n"), 0o600))
+	_, err = ReloadConfiguration(configFile)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(configFile, []byte("FREE_RATE_LIMIT\n"), 0o600))
+	_, err = ReloadConfiguration(configFile)
+	require.Error(t, err)
+	_, err = ReloadConfiguration(filepath.Join(t.TempDir(), "missing.env"))
+	require.Error(t, err)
+	os.Setenv("FREE_RATE_LIMIT", "99")
+}
+
+func TestServiceRewriteRegex(t *testing.T) {
+	TestConfiguration(t)
+	t.Setenv("BTC_MAINNET_FULLNODE_REGEX_MATCH", `^/block/(\d+)$`)
+	t.Setenv("BTC_MAINNET_FULLNODE_REGEX_REPLACE", "/api/v1/blocks/$1")
+
+	config, err := ReloadConfiguration("")
+	require.NoError(t, err)
+	rewrite := config.ServiceRewrites["btc-mainnet-fullnode"]
+	require.Equal(t, `^/block/(\d+)$`, rewrite.RegexMatch)
+	require.Equal(t, "/api/v1/blocks/$1", rewrite.RegexReplace)
+	require.NotNil(t, rewrite.PathRegexp())
+	require.Equal(t, "/api/v1/blocks/12", rewrite.PathRegexp().ReplaceAllString("/block/12", rewrite.RegexReplace))
+
+	// a bad regex fails to load
+	t.Setenv("BTC_MAINNET_FULLNODE_REGEX_MATCH", "(")
+	_, err = ReloadConfiguration("")
+	require.ErrorContains(t, err, "BTC_MAINNET_FULLNODE_REGEX_MATCH")
 }
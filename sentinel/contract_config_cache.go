@@ -0,0 +1,106 @@
+package sentinel
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+)
+
+type ContractConfigCacheStatus = api.ContractConfigCacheStatus
+
+type cachedContractConfig struct {
+	config  ContractConfiguration
+	expires time.Time
+}
+
+// contractConfigCache is a bounded LRU cache of the contract configurations in
+// front of the store, so the configuration of a contract isn't read from disk
+// on every request. The configurations returned are shared with the cache,
+// their slices and maps must not be modified in place.
+type contractConfigCache struct {
+	lock       sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[uint64]*list.Element
+	lru        *list.List
+	hits       atomic.Uint64
+	misses     atomic.Uint64
+}
+
+func newContractConfigCache(maxEntries int, ttl time.Duration) *contractConfigCache {
+	return &contractConfigCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[uint64]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (c *contractConfigCache) Get(id uint64, now time.Time) (ContractConfiguration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, ok := c.entries[id]
+	if !ok {
+		c.misses.Add(1)
+		return ContractConfiguration{}, false
+	}
+	cached := elem.Value.(cachedContractConfig)
+	if !now.Before(cached.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, id)
+		c.misses.Add(1)
+		return ContractConfiguration{}, false
+	}
+	c.lru.MoveToFront(elem)
+	c.hits.Add(1)
+	return cached.config, true
+}
+
+// Set caches the configuration, the least recently used ones are evicted when
+// full
+func (c *contractConfigCache) Set(config ContractConfiguration, now time.Time) {
+	if c.maxEntries <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cached := cachedContractConfig{config: config, expires: now.Add(c.ttl)}
+	if elem, ok := c.entries[config.ContractId]; ok {
+		elem.Value = cached
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[config.ContractId] = c.lru.PushFront(cached)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(cachedContractConfig).config.ContractId)
+	}
+}
+
+// Remove drops the configuration, it is read from the store again next time
+func (c *contractConfigCache) Remove(id uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.entries[id]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, id)
+	}
+}
+
+func (c *contractConfigCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+func (c *contractConfigCache) Status() ContractConfigCacheStatus {
+	return ContractConfigCacheStatus{
+		Entries: c.Len(),
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+	}
+}
@@ -0,0 +1,70 @@
+package sentinel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContractConfigCacheEviction(t *testing.T) {
+	cache := newContractConfigCache(2, time.Minute)
+	now := time.Now()
+	for id := uint64(1); id <= 2; id++ {
+		cache.Set(ContractConfiguration{ContractId: id}, now)
+	}
+
+	// reading 1 makes 2 the least recently used
+	_, ok := cache.Get(1, now)
+	require.True(t, ok)
+	cache.Set(ContractConfiguration{ContractId: 3}, now)
+	require.Equal(t, 2, cache.Len())
+	_, ok = cache.Get(2, now)
+	require.False(t, ok)
+	_, ok = cache.Get(3, now)
+	require.True(t, ok)
+
+	// expired entries are dropped
+	_, ok = cache.Get(1, now.Add(time.Minute))
+	require.False(t, ok)
+	require.Equal(t, ContractConfigCacheStatus{Entries: 1, Hits: 2, Misses: 2}, cache.Status())
+
+	// a zero size disables the cache
+	cache = newContractConfigCache(0, time.Minute)
+	cache.Set(ContractConfiguration{ContractId: 1}, now)
+	_, ok = cache.Get(1, now)
+	require.False(t, ok)
+}
+
+func TestContractConfigStoreCache(t *testing.T) {
+	store, err := NewContractConfigurationStore("", 10, time.Minute)
+	require.NoError(t, err)
+	defer store.Close()
+
+	// the default configuration of a contract is cached too
+	conf, err := store.Get(1)
+	require.NoError(t, err)
+	require.Zero(t, conf.PerUserRateLimit)
+	_, err = store.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, ContractConfigCacheStatus{Entries: 1, Hits: 1, Misses: 1}, store.CacheStatus())
+
+	// an update invalidates the cached configuration
+	conf.PerUserRateLimit = 10
+	require.NoError(t, store.Set(conf))
+	require.Zero(t, store.CacheStatus().Entries)
+	conf, err = store.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, 10, conf.PerUserRateLimit)
+
+	conf.PerUserRateLimit = 20
+	require.NoError(t, store.Batch(ContractConfigurations{conf}))
+	conf, err = store.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, 20, conf.PerUserRateLimit)
+
+	require.NoError(t, store.Remove(1))
+	conf, err = store.Get(1)
+	require.NoError(t, err)
+	require.Zero(t, conf.PerUserRateLimit)
+}
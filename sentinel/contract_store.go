@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/syndtr/goleveldb/leveldb"
@@ -15,6 +19,8 @@ import (
 type ContractConfigurationStore struct {
 	logger zerolog.Logger
 	db     *leveldb.DB
+	lock   sync.RWMutex // orders the writes with the reads filling the cache
+	cache  *contractConfigCache
 }
 
 type CORs struct {
@@ -31,12 +37,50 @@ func NewCORs() CORs {
 	}
 }
 
+// limits of the metadata a client can attach to its contract
+const (
+	MaxContractMetadataKeys        = 16
+	MaxContractMetadataKeyLength   = 64
+	MaxContractMetadataValueLength = 256
+)
+
+// ContractLimits are the proxy limits a client sets on its contract, they can
+// only lower the limits of the provider
+type ContractLimits = conf.ProxyLimits
+
 type ContractConfiguration struct {
-	ContractId           uint64   `json:"contract_id"`
-	LastTimeStamp        int64    `json:"last_timestamp"`
-	PerUserRateLimit     int      `json:"per_user_rate_limit"`
-	CORs                 CORs     `json:"cors"`
-	WhitelistIPAddresses []string `json:"white_listed_ip_addresses"`
+	ContractId           uint64            `json:"contract_id"`
+	LastTimeStamp        int64             `json:"last_timestamp"`
+	PerUserRateLimit     int               `json:"per_user_rate_limit"`
+	PerUserBurstSize     int               `json:"per_user_burst_size"` // max burst of a user, zero allows the whole rate limit at once
+	CORs                 CORs              `json:"cors"`
+	WhitelistIPAddresses []string          `json:"white_listed_ip_addresses"`
+	Metadata             map[string]string `json:"metadata,omitempty"` // free form key/values set by the client, e.g. a customer id
+	Limits               ContractLimits    `json:"limits"`
+	Pricing              map[string]int64  `json:"pricing,omitempty"`             // cost multiplier by url path or JSON-RPC method, defaults to one
+	DailySpendCapUSD     float64           `json:"daily_spend_cap_usd,omitempty"` // max USD spent over a rolling 24 hours, zero disables
+	NoCache              bool              `json:"no_cache,omitempty"`            // responses are never served from nor stored in the cache
+	SignResponses        bool              `json:"sign_responses,omitempty"`      // responses carry the provenance headers, signed by the provider key
+}
+
+// ValidateContractMetadata checks the metadata fits the size limits and is
+// valid UTF-8
+func ValidateContractMetadata(metadata map[string]string) error {
+	if len(metadata) > MaxContractMetadataKeys {
+		return fmt.Errorf("too many metadata keys (%d/%d)", len(metadata), MaxContractMetadataKeys)
+	}
+	for key, value := range metadata {
+		if len(key) == 0 || len(key) > MaxContractMetadataKeyLength {
+			return fmt.Errorf("metadata key must be between 1 and %d bytes", MaxContractMetadataKeyLength)
+		}
+		if len(value) > MaxContractMetadataValueLength {
+			return fmt.Errorf("metadata value of %q is too long (%d/%d)", key, len(value), MaxContractMetadataValueLength)
+		}
+		if !utf8.ValidString(key) || !utf8.ValidString(value) {
+			return fmt.Errorf("metadata of %q is not valid UTF-8", key)
+		}
+	}
+	return nil
 }
 
 func (c ContractConfiguration) Key() string {
@@ -55,7 +99,22 @@ func NewContractConfiguration(contractId uint64, cors CORs, ips []string, rateLi
 	}
 }
 
-func NewContractConfigurationStore(levelDbFolder string) (*ContractConfigurationStore, error) {
+// CreateDefaultContractConfig creates the configuration of a newly opened
+// contract from the defaults of the provider, rather than leaving the contract
+// unlimited
+func (p Proxy) CreateDefaultContractConfig(contractId uint64) ContractConfiguration {
+	cors := NewCORs()
+	cors.AllowMethods = []string{"GET", "POST"}
+	defaults := p.config()
+	config := NewContractConfiguration(contractId, cors, make([]string, 0), defaults.DefaultPerUserRateLimit)
+	config.PerUserBurstSize = defaults.DefaultPerUserBurstSize
+	return config
+}
+
+// NewContractConfigurationStore opens the store, the configurations read are
+// cached in memory for the given ttl, up to cacheSize of them. A zero cache
+// size disables the cache.
+func NewContractConfigurationStore(levelDbFolder string, cacheSize int, cacheTTL time.Duration) (*ContractConfigurationStore, error) {
 	var db *leveldb.DB
 	var err error
 	if len(levelDbFolder) == 0 {
@@ -75,10 +134,13 @@ func NewContractConfigurationStore(levelDbFolder string) (*ContractConfiguration
 	return &ContractConfigurationStore{
 		logger: log.With().Str("module", "contract-config-storage").Logger(),
 		db:     db,
+		cache:  newContractConfigCache(cacheSize, cacheTTL),
 	}, nil
 }
 
 func (s *ContractConfigurationStore) Set(item ContractConfiguration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	key := item.Key()
 	buf, err := json.Marshal(item)
 	if err != nil {
@@ -89,10 +151,13 @@ func (s *ContractConfigurationStore) Set(item ContractConfiguration) error {
 		s.logger.Error().Err(err).Msg("fail to set claim item")
 		return err
 	}
+	s.cache.Remove(item.ContractId)
 	return nil
 }
 
 func (s *ContractConfigurationStore) Batch(items ContractConfigurations) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	batch := new(leveldb.Batch)
 	for _, item := range items {
 		key := item.Key()
@@ -103,10 +168,31 @@ func (s *ContractConfigurationStore) Batch(items ContractConfigurations) error {
 		}
 		batch.Put([]byte(key), buf)
 	}
-	return s.db.Write(batch, nil)
+	if err := s.db.Write(batch, nil); err != nil {
+		return err
+	}
+	for _, item := range items {
+		s.cache.Remove(item.ContractId)
+	}
+	return nil
 }
 
-func (s *ContractConfigurationStore) Get(id uint64) (item ContractConfiguration, err error) {
+// Get returns the configuration of the contract, the default one if none is
+// stored. It is served from the cache when possible.
+func (s *ContractConfigurationStore) Get(id uint64) (ContractConfiguration, error) {
+	if item, ok := s.cache.Get(id, time.Now()); ok {
+		return item, nil
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	item, err := s.get(id)
+	if err == nil {
+		s.cache.Set(item, time.Now())
+	}
+	return item, err
+}
+
+func (s *ContractConfigurationStore) get(id uint64) (item ContractConfiguration, err error) {
 	item = NewContractConfiguration(id, NewCORs(), make([]string, 0), 0)
 	key := item.Key()
 	ok, err := s.db.Has([]byte(key), nil)
@@ -131,8 +217,19 @@ func (s *ContractConfigurationStore) Has(id uint64) (ok bool) {
 
 // Remove remove the given item from key values store
 func (s *ContractConfigurationStore) Remove(id uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	key := strconv.FormatUint(id, 10)
-	return s.db.Delete([]byte(key), nil)
+	if err := s.db.Delete([]byte(key), nil); err != nil {
+		return err
+	}
+	s.cache.Remove(id)
+	return nil
+}
+
+// CacheStatus returns the usage of the configuration cache
+func (s *ContractConfigurationStore) CacheStatus() ContractConfigCacheStatus {
+	return s.cache.Status()
 }
 
 // List send back tx out to retry depending on arg failed only
@@ -0,0 +1,97 @@
+package sentinel
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+)
+
+type (
+	ErrorCode     = api.ErrorCode
+	ErrorResponse = api.ErrorResponse
+)
+
+const (
+	ErrCodeBadArkAuth          = api.ErrCodeBadArkAuth
+	ErrCodeBadSignature        = api.ErrCodeBadSignature
+	ErrCodeUnknownContract     = api.ErrCodeUnknownContract
+	ErrCodeProviderMismatch    = api.ErrCodeProviderMismatch
+	ErrCodeIPNotWhitelisted    = api.ErrCodeIPNotWhitelisted
+	ErrCodeIPBanned            = api.ErrCodeIPBanned
+	ErrCodeUserRateLimited     = api.ErrCodeUserRateLimited
+	ErrCodeServiceMismatch     = api.ErrCodeServiceMismatch
+	ErrCodeUnknownService      = api.ErrCodeUnknownService
+	ErrCodeContractExpired     = api.ErrCodeContractExpired
+	ErrCodeDelegateRotated     = api.ErrCodeDelegateRotated
+	ErrCodeUnlistedSpender     = api.ErrCodeUnlistedSpender
+	ErrCodeBadNonce            = api.ErrCodeBadNonce
+	ErrCodeContractSpent       = api.ErrCodeContractSpent
+	ErrCodeContractRateLimited = api.ErrCodeContractRateLimited
+	ErrCodeBlockQuotaExceeded  = api.ErrCodeBlockQuotaExceeded
+	ErrCodeDailySpendCap       = api.ErrCodeDailySpendCap
+	ErrCodeFreeTierRateLimited = api.ErrCodeFreeTierRateLimited
+	ErrCodeFreeTierDisabled    = api.ErrCodeFreeTierDisabled
+	ErrCodeBadChallenge        = api.ErrCodeBadChallenge
+	ErrCodeVerifyRateLimited   = api.ErrCodeVerifyRateLimited
+	ErrCodeSessionsDisabled    = api.ErrCodeSessionsDisabled
+	ErrCodeBadSession          = api.ErrCodeBadSession
+	ErrCodeSessionSpent        = api.ErrCodeSessionSpent
+	ErrCodeTooManyInFlight     = api.ErrCodeTooManyInFlight
+	ErrCodeInternal            = api.ErrCodeInternal
+	ErrCodeUnknown             = api.ErrCodeUnknown
+)
+
+// proxyError is an error carrying the code reported to the client
+type proxyError struct {
+	code ErrorCode
+	err  error
+}
+
+func newProxyError(code ErrorCode, format string, args ...interface{}) error {
+	return &proxyError{code: code, err: fmt.Errorf(format, args...)}
+}
+
+func (e *proxyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *proxyError) Unwrap() error {
+	return e.err
+}
+
+// errorCode returns the code of the error, falling back on a code matching
+// the http status
+func errorCode(err error, status int) ErrorCode {
+	var perr *proxyError
+	if errors.As(err, &perr) {
+		return perr.code
+	}
+	if status >= http.StatusInternalServerError {
+		return ErrCodeInternal
+	}
+	return ErrCodeUnknown
+}
+
+// respondWithProxyError writes the error as a JSON ErrorResponse
+func respondWithProxyError(w http.ResponseWriter, status int, err error) {
+	respondWithJSON(w, status, ErrorResponse{
+		Error:      err.Error(),
+		Code:       errorCode(err, status),
+		HTTPStatus: status,
+	})
+}
+
+// respondWithAuthError writes the reason an arkauth was refused, with the
+// provider the arkauth must be made for
+func respondWithAuthError(w http.ResponseWriter, err error, provider common.PubKey) {
+	status := http.StatusUnauthorized
+	respondWithJSON(w, status, ErrorResponse{
+		Error:            err.Error(),
+		Code:             errorCode(err, status),
+		HTTPStatus:       status,
+		ExpectedProvider: provider.String(),
+	})
+}
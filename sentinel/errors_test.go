@@ -0,0 +1,136 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestErrorResponses(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.FreeTierRateLimit = 1
+	testConfig.SubscriptionQuotaPerRate = 1
+	testConfig.USDPrices = map[string]float64{"uarkeo": 1}
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+	proxy.MemStore.SetHeight(20)
+
+	newContract := func(id uint64, contractType types.ContractType) types.Contract {
+		contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+		contract.Type = contractType
+		contract.Authorization = types.ContractAuthorization_OPEN
+		contract.Deposit = cosmos.NewInt(5)
+		contract.Id = id
+		proxy.MemStore.Put(contract)
+		return contract
+	}
+	payg := newContract(1, types.ContractType_PAY_AS_YOU_GO)
+	expired := newContract(2, types.ContractType_PAY_AS_YOU_GO)
+	expired.Duration = 5
+	proxy.MemStore.Put(expired)
+	subscription := newContract(3, types.ContractType_SUBSCRIPTION)
+	whitelisted := newContract(4, types.ContractType_PAY_AS_YOU_GO)
+	whitelistedConf := proxy.CreateDefaultContractConfig(whitelisted.Id)
+	whitelistedConf.WhitelistIPAddresses = []string{"10.0.0.9"}
+	require.NoError(t, proxy.ContractConfigStore.Set(whitelistedConf))
+	userLimited := newContract(5, types.ContractType_PAY_AS_YOU_GO)
+	userLimitedConf := proxy.CreateDefaultContractConfig(userLimited.Id)
+	userLimitedConf.PerUserRateLimit = 1
+	require.NoError(t, proxy.ContractConfigStore.Set(userLimitedConf))
+	capped := newContract(6, types.ContractType_PAY_AS_YOU_GO)
+	cappedConf := proxy.CreateDefaultContractConfig(capped.Id)
+	cappedConf.DailySpendCapUSD = 1.5
+	require.NoError(t, proxy.ContractConfigStore.Set(cappedConf))
+
+	// use up the free tier, so paid rejections are reported
+	req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+	arkauth := func(id uint64, nonce int64) string {
+		return fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:%d", id, nonce)
+	}
+	require.Equal(t, http.StatusOK, serve(arkauth(payg.Id, 1)).Code)
+	require.Equal(t, http.StatusOK, serve(arkauth(subscription.Id, 1)).Code)
+	require.Equal(t, http.StatusOK, serve(arkauth(userLimited.Id, 1)).Code)
+	require.Equal(t, http.StatusOK, serve(arkauth(capped.Id, 1)).Code)
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		status int
+		code   ErrorCode
+	}{
+		{"bad arkauth", "/btc-mainnet-fullnode/?arkauth=bogus", http.StatusBadRequest, ErrCodeBadArkAuth},
+		{"ip not whitelisted", arkauth(whitelisted.Id, 1), http.StatusForbidden, ErrCodeIPNotWhitelisted},
+		{"user rate limited", arkauth(userLimited.Id, 2), http.StatusTooManyRequests, ErrCodeUserRateLimited},
+		{"service mismatch", fmt.Sprintf("/eth-mainnet-fullnode/?arkauth=%d:2", payg.Id), http.StatusUnauthorized, ErrCodeServiceMismatch},
+		{"block quota exceeded", arkauth(subscription.Id, 2), http.StatusTooManyRequests, ErrCodeBlockQuotaExceeded},
+		{"contract expired", arkauth(expired.Id, 1), http.StatusTooManyRequests, ErrCodeContractExpired},
+		{"bad nonce", arkauth(payg.Id, 1), http.StatusTooManyRequests, ErrCodeBadNonce},
+		{"contract spent", arkauth(payg.Id, 6), http.StatusTooManyRequests, ErrCodeContractSpent},
+		{"daily spend cap", arkauth(capped.Id, 2), http.StatusTooManyRequests, ErrCodeDailySpendCap},
+		{"free tier rate limited", "/btc-mainnet-fullnode/", http.StatusTooManyRequests, ErrCodeFreeTierRateLimited},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			response := serve(tc.path)
+			require.Equal(t, tc.status, response.Code)
+			require.Equal(t, "application/json", response.Header().Get("Content-Type"))
+			var body ErrorResponse
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+			require.Equal(t, tc.code, body.Code)
+			require.Equal(t, tc.status, body.HTTPStatus)
+			require.NotEmpty(t, body.Error)
+		})
+	}
+}
+
+func TestPaidTierErrorCodes(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	proxy := NewProxy(newTestConfig())
+
+	contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Id = 7
+	contract.QueriesPerMinute = 1
+	proxy.MemStore.Put(contract)
+	proxy.MemStore.SetHeight(20)
+
+	pay := func(nonce int64) (int, ErrorCode) {
+		aa := ArkAuth{ContractId: contract.Id, Spender: contract.Client, Nonce: nonce}
+		status, err := proxy.paidTier(aa, "127.0.0.1:8080")
+		require.Error(t, err)
+		return status, errorCode(err, status)
+	}
+
+	_, err := proxy.paidTier(ArkAuth{ContractId: contract.Id, Spender: contract.Client, Nonce: 1}, "127.0.0.1:8080")
+	require.NoError(t, err)
+	status, code := pay(2)
+	require.Equal(t, http.StatusTooManyRequests, status)
+	require.Equal(t, ErrCodeContractRateLimited, code)
+
+	proxy.MemStore.SetHeight(200)
+	status, code = pay(3)
+	require.Equal(t, http.StatusPaymentRequired, status)
+	require.Equal(t, ErrCodeContractExpired, code)
+
+	require.Equal(t, ErrCodeInternal, errorCode(fmt.Errorf("boom"), http.StatusInternalServerError))
+	require.Equal(t, ErrCodeUnknown, errorCode(fmt.Errorf("boom"), http.StatusTeapot))
+}
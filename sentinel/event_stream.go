@@ -2,15 +2,18 @@ package sentinel
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/gogo/protobuf/proto"
 
+	abci "github.com/tendermint/tendermint/abci/types"
 	"github.com/tendermint/tendermint/libs/log"
 
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
@@ -20,51 +23,249 @@ import (
 	tmtypes "github.com/tendermint/tendermint/types"
 )
 
-func subscribe(client *tmclient.HTTP, logger log.Logger, query string) <-chan tmCoreTypes.ResultEvent {
-	out, err := client.Subscribe(context.Background(), "", query)
-	if err != nil {
-		logger.Error("Failed to subscribe to query", "err", err, "query", query)
-		os.Exit(1)
-	}
-	return out
+var (
+	eventStreamMinBackoff = time.Second
+	errSubscriptionClosed = errors.New("subscription closed")
+)
+
+// eventClient is the part of the tendermint websocket client the event
+// listener uses
+type eventClient interface {
+	Start() error
+	Stop() error
+	Subscribe(ctx context.Context, subscriber, query string, outCapacity ...int) (<-chan tmCoreTypes.ResultEvent, error)
 }
 
-func (p Proxy) EventListener(host string) {
-	logger := p.logger
+// eventSubscriptions are the events the sentinel follows, the provider ones
+// are nil unless the operator is alerted
+type eventSubscriptions struct {
+	newBlock, openContract, closeContract, claimContract <-chan tmCoreTypes.ResultEvent
+	setContractConfig, extendContract, updateDelegate    <-chan tmCoreTypes.ResultEvent
+	topUpContract                                        <-chan tmCoreTypes.ResultEvent
+	modProvider, bondProvider, reportProvider            <-chan tmCoreTypes.ResultEvent
+}
+
+func dialEventClient(host string, logger log.Logger) (eventClient, error) {
 	client, err := tmclient.New(fmt.Sprintf("tcp://%s", host), "/websocket")
 	if err != nil {
-		logger.Error("failure to create websocket client", "error", err)
-		panic(err)
+		return nil, fmt.Errorf("failure to create websocket client: %w", err)
 	}
 	client.SetLogger(logger)
-	err = client.Start()
-	if err != nil {
-		logger.Error("Failed to start a client", "err", err)
-		os.Exit(1)
+	if err := client.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start a client: %w", err)
 	}
-	defer client.Stop() // nolint
+	return client, nil
+}
 
-	// receive height changes
-	newBlockOut := subscribe(client, logger, "tm.event = 'NewBlockHeader'")
-	openContractOut := subscribe(client, logger, "tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgOpenContract'")
-	closeContractOut := subscribe(client, logger, "tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgCloseContract'")
-	claimContractOut := subscribe(client, logger, "tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgClaimContractIncome'")
+func (p Proxy) EventListener(host string) {
+	var source ContractSource
+	if len(p.Config.SourceChainGRPC) > 0 {
+		var err error
+		source, err = NewContractSource(p.Config.SourceChainGRPC)
+		if err != nil {
+			p.logger.Error("contracts won't be resynced", "error", err)
+		}
+	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		<-quit
+		close(done)
+	}()
+
+	p.listenEvents(func() (eventClient, error) { return dialEventClient(host, p.logger) }, source, done)
+}
 
+// listenEvents follows the chain events until done is closed. The event
+// stream is reconnected with an exponential backoff whenever it drops or
+// stalls, and the memstore is resynced from the source on every connection
+// as events may have been missed in between.
+func (p Proxy) listenEvents(dial func() (eventClient, error), source ContractSource, done <-chan struct{}) {
+	maxBackoff := time.Duration(p.Config.EventStreamMaxBackoffSec) * time.Second
+	backoff := eventStreamMinBackoff
+	connected := false
 	for {
+		err := func() error {
+			client, err := dial()
+			if err != nil {
+				return err
+			}
+			defer client.Stop() // nolint
+			subs, err := p.subscribeEvents(client)
+			if err != nil {
+				return err
+			}
+			if connected {
+				p.eventStream.reconnects.Add(1)
+				p.logger.Info("event stream reconnected")
+			}
+			connected = true
+			backoff = eventStreamMinBackoff
+			p.eventStream.connected.Store(true)
+			defer p.eventStream.connected.Store(false)
+			if err := p.resync(source); err != nil {
+				p.logger.Error("fail to resync contracts", "error", err)
+			}
+			return p.handleEvents(subs, source, done)
+		}()
+		if err == nil {
+			return
+		}
+
+		p.logger.Error("event stream disconnected", "error", err, "retry_in", backoff)
 		select {
-		case result := <-newBlockOut:
+		case <-done:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if maxBackoff > 0 && backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func (p Proxy) subscribeEvents(client eventClient) (eventSubscriptions, error) {
+	var subs eventSubscriptions
+	subscribe := func(query string) (<-chan tmCoreTypes.ResultEvent, error) {
+		out, err := client.Subscribe(context.Background(), "", query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to subscribe to query %q: %w", query, err)
+		}
+		return out, nil
+	}
+
+	var err error
+	// receive height changes
+	if subs.newBlock, err = subscribe("tm.event = 'NewBlockHeader'"); err != nil {
+		return subs, err
+	}
+	if subs.openContract, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgOpenContract'"); err != nil {
+		return subs, err
+	}
+	if subs.closeContract, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgCloseContract'"); err != nil {
+		return subs, err
+	}
+	if subs.claimContract, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgClaimContractIncome'"); err != nil {
+		return subs, err
+	}
+	if subs.setContractConfig, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgSetContractConfig'"); err != nil {
+		return subs, err
+	}
+	if subs.extendContract, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgExtendContract'"); err != nil {
+		return subs, err
+	}
+	if subs.updateDelegate, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgUpdateContractDelegate'"); err != nil {
+		return subs, err
+	}
+	if subs.topUpContract, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgTopUpContract'"); err != nil {
+		return subs, err
+	}
+
+	// events affecting the provider, only watched when the operator is alerted
+	if p.OperatorWatcher != nil {
+		p.logger.Info("watching provider events", "last_alert_height", p.OperatorWatcher.LastHeight())
+		if subs.modProvider, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgModProvider'"); err != nil {
+			return subs, err
+		}
+		if subs.bondProvider, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgBondProvider'"); err != nil {
+			return subs, err
+		}
+		if subs.reportProvider, err = subscribe("tm.event = 'Tx' AND message.action='/arkeo.arkeo.MsgReportProvider'"); err != nil {
+			return subs, err
+		}
+	}
+	return subs, nil
+}
+
+// handleEvents dispatches the events until done is closed, nil is returned
+// then. It fails when a subscription is closed or no block is received for
+// the event stream timeout.
+func (p Proxy) handleEvents(subs eventSubscriptions, source ContractSource, done <-chan struct{}) error {
+	timeout := time.Duration(p.Config.EventStreamTimeoutSec) * time.Second
+	var stalled <-chan time.Time
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.NewTimer(timeout)
+		defer timer.Stop()
+		stalled = timer.C
+	}
+
+	for {
+		select {
+		case result, ok := <-subs.newBlock:
+			if !ok {
+				return errSubscriptionClosed
+			}
+			if timer != nil {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(timeout)
+			}
+			previous := p.MemStore.GetHeight()
 			p.handleNewBlockHeaderEvent(result)
-		case result := <-openContractOut:
+			if p.checkBlockGap(previous, p.MemStore.GetHeight()) {
+				if err := p.resync(source); err != nil {
+					p.logger.Error("fail to resync contracts", "error", err)
+				}
+			}
+		case result, ok := <-subs.openContract:
+			if !ok {
+				return errSubscriptionClosed
+			}
 			p.handleOpenContractEvent(result)
-		case result := <-closeContractOut:
+		case result, ok := <-subs.closeContract:
+			if !ok {
+				return errSubscriptionClosed
+			}
 			p.handleCloseContractEvent(result)
-		case result := <-claimContractOut: // MsgClaimContractIncome emits a contract settlement event
+		case result, ok := <-subs.claimContract: // MsgClaimContractIncome emits a contract settlement event
+			if !ok {
+				return errSubscriptionClosed
+			}
 			p.handleContractSettlementEvent(result)
-		case <-quit:
-			return
+		case result, ok := <-subs.setContractConfig:
+			if !ok {
+				return errSubscriptionClosed
+			}
+			p.handleSetContractConfigEvent(result)
+		case result, ok := <-subs.extendContract:
+			if !ok {
+				return errSubscriptionClosed
+			}
+			p.handleExtendContractEvent(result)
+		case result, ok := <-subs.updateDelegate:
+			if !ok {
+				return errSubscriptionClosed
+			}
+			p.handleUpdateContractDelegateEvent(result)
+		case result, ok := <-subs.topUpContract:
+			if !ok {
+				return errSubscriptionClosed
+			}
+			p.handleTopUpContractEvent(result)
+		case result, ok := <-subs.modProvider:
+			if !ok {
+				return errSubscriptionClosed
+			}
+			p.OperatorWatcher.HandleTx(result)
+		case result, ok := <-subs.bondProvider:
+			if !ok {
+				return errSubscriptionClosed
+			}
+			p.OperatorWatcher.HandleTx(result)
+		case result, ok := <-subs.reportProvider:
+			if !ok {
+				return errSubscriptionClosed
+			}
+			p.OperatorWatcher.HandleTx(result)
+		case <-stalled:
+			return fmt.Errorf("no new block for %s", timeout)
+		case <-done:
+			return nil
 		}
 	}
 }
@@ -103,7 +304,7 @@ func (p Proxy) handleContractSettlementEvent(result tmCoreTypes.ResultEvent) {
 		p.logger.Error("failed to get claim", "error", err)
 		return
 	}
-	if currClaim.Nonce == newClaim.Nonce {
+	if currClaim.ClaimNonce() == newClaim.Nonce {
 		currClaim.Claimed = true
 		if err := p.ClaimStore.Set(currClaim); err != nil {
 			p.logger.Error("failed to set claimed", "error", err)
@@ -129,6 +330,7 @@ func (p Proxy) handleCloseContractEvent(result tmCoreTypes.ResultEvent) {
 	contract := types.Contract{
 		Provider: evt.Provider,
 		Service:  service,
+		Services: parseServices(evt.Services),
 		Client:   evt.Client,
 		Delegate: evt.Delegate,
 		Id:       evt.ContractId,
@@ -136,7 +338,23 @@ func (p Proxy) handleCloseContractEvent(result tmCoreTypes.ResultEvent) {
 	if !p.isMyPubKey(contract.Provider) {
 		return
 	}
+	p.SpendTracker.Remove(contract.Id)
+	p.DailySpendTracker.Remove(contract.Id)
 	p.MemStore.Put(contract)
+	p.closeContractSessions(contract.Id)
+}
+
+// parseServices returns the services of a bundle contract, nil for a contract
+// covering a single service
+func parseServices(names []string) common.Services {
+	if len(names) < 2 {
+		return nil
+	}
+	services := make(common.Services, 0, len(names))
+	for _, name := range names {
+		services = append(services, common.Service(common.ServiceLookup[name]))
+	}
+	return services
 }
 
 func (p Proxy) handleOpenContractEvent(result tmCoreTypes.ResultEvent) {
@@ -167,6 +385,10 @@ func (p Proxy) handleOpenContractEvent(result tmCoreTypes.ResultEvent) {
 		SettlementDuration: evt.SettlementDuration,
 		Authorization:      evt.Authorization,
 		QueriesPerMinute:   evt.QueriesPerMinute,
+		Services:           parseServices(evt.Services),
+		BundleId:           evt.BundleId,
+		MethodRates:        evt.MethodRates,
+		Spenders:           evt.Spenders,
 	}
 
 	if !p.isMyPubKey(evt.Provider) {
@@ -177,6 +399,143 @@ func (p Proxy) handleOpenContractEvent(result tmCoreTypes.ResultEvent) {
 		return
 	}
 	p.MemStore.Put(contract)
+
+	// keep the configuration a client may have already set
+	if !p.ContractConfigStore.Has(contract.Id) {
+		if err := p.ContractConfigStore.Set(p.CreateDefaultContractConfig(contract.Id)); err != nil {
+			p.logger.Error("fail to save default contract config", "error", err, "id", contract.Id)
+		}
+	}
+}
+
+// handleSetContractConfigEvent applies the settings a client set on chain to
+// the configuration of its contract, the settings only kept by the sentinel
+// are left as is
+func (p Proxy) handleSetContractConfigEvent(result tmCoreTypes.ResultEvent) {
+	typedEvent, err := parseTypedEvent(result, types.EventTypeSetContractConfig)
+	if err != nil {
+		p.logger.Error("failed to parse typed event", "error", err)
+		return
+	}
+
+	evt, ok := typedEvent.(*types.EventSetContractConfig)
+	if !ok {
+		p.logger.Error(fmt.Sprintf("failed to cast %T to EventSetContractConfig", typedEvent))
+		return
+	}
+
+	if !p.isMyPubKey(evt.Provider) {
+		return
+	}
+
+	conf := p.CreateDefaultContractConfig(evt.ContractId)
+	if p.ContractConfigStore.Has(evt.ContractId) {
+		if conf, err = p.ContractConfigStore.Get(evt.ContractId); err != nil {
+			p.logger.Error("fail to fetch contract config", "error", err, "id", evt.ContractId)
+			return
+		}
+	}
+	conf.CORs = CORs{
+		AllowOrigins: evt.Cors.AllowOrigins,
+		AllowMethods: evt.Cors.AllowMethods,
+		AllowHeaders: evt.Cors.AllowHeaders,
+	}
+	conf.WhitelistIPAddresses = evt.WhitelistIps
+	conf.PerUserRateLimit = int(evt.PerUserRateLimit)
+	if err := p.ContractConfigStore.Set(conf); err != nil {
+		p.logger.Error("fail to save contract config", "error", err, "id", evt.ContractId)
+	}
+}
+
+// handleExtendContractEvent applies the new duration and deposit to the
+// contract held in memory, so it keeps being served past its old expiration.
+// A contract not held in memory is fetched with its new terms when used.
+func (p Proxy) handleExtendContractEvent(result tmCoreTypes.ResultEvent) {
+	typedEvent, err := parseTypedEvent(result, types.EventTypeExtendContract)
+	if err != nil {
+		p.logger.Error("failed to parse typed event", "error", err)
+		return
+	}
+
+	evt, ok := typedEvent.(*types.EventExtendContract)
+	if !ok {
+		p.logger.Error(fmt.Sprintf("failed to cast %T to EventExtendContract", typedEvent))
+		return
+	}
+
+	if !p.isMyPubKey(evt.Provider) {
+		return
+	}
+
+	contract, ok := p.MemStore.Peek(types.Contract{Id: evt.ContractId}.Key())
+	if !ok {
+		return
+	}
+	contract.Duration = evt.Duration
+	contract.Deposit = evt.Deposit
+	p.MemStore.Put(contract)
+}
+
+// handleTopUpContractEvent applies the new deposit to the contract held in
+// memory, so the paid tier serves the nonces it pays for right away. A
+// contract not held in memory is fetched with its new deposit when used.
+func (p Proxy) handleTopUpContractEvent(result tmCoreTypes.ResultEvent) {
+	typedEvent, err := parseTypedEvent(result, types.EventTypeTopUpContract)
+	if err != nil {
+		p.logger.Error("failed to parse typed event", "error", err)
+		return
+	}
+
+	evt, ok := typedEvent.(*types.EventTopUpContract)
+	if !ok {
+		p.logger.Error(fmt.Sprintf("failed to cast %T to EventTopUpContract", typedEvent))
+		return
+	}
+
+	if !p.isMyPubKey(evt.Provider) {
+		return
+	}
+
+	contract, ok := p.MemStore.Peek(types.Contract{Id: evt.ContractId}.Key())
+	if !ok {
+		return
+	}
+	contract.Deposit = evt.Deposit
+	p.MemStore.Put(contract)
+}
+
+// handleUpdateContractDelegateEvent applies the new delegate to the contract
+// held in memory, and records the old one so the requests it still signs are
+// told it was rotated out
+func (p Proxy) handleUpdateContractDelegateEvent(result tmCoreTypes.ResultEvent) {
+	typedEvent, err := parseTypedEvent(result, types.EventTypeUpdateContractDelegate)
+	if err != nil {
+		p.logger.Error("failed to parse typed event", "error", err)
+		return
+	}
+
+	evt, ok := typedEvent.(*types.EventUpdateContractDelegate)
+	if !ok {
+		p.logger.Error(fmt.Sprintf("failed to cast %T to EventUpdateContractDelegate", typedEvent))
+		return
+	}
+
+	if !p.isMyPubKey(evt.Provider) {
+		return
+	}
+
+	// without a delegate, the client was spending the contract
+	oldSpender := evt.OldDelegate
+	if oldSpender.IsEmpty() {
+		oldSpender = evt.Client
+	}
+	p.MemStore.AddRotatedDelegate(evt.ContractId, oldSpender)
+	contract, ok := p.MemStore.Peek(types.Contract{Id: evt.ContractId}.Key())
+	if !ok {
+		return
+	}
+	contract.Delegate = evt.NewDelegate
+	p.MemStore.Put(contract)
 }
 
 func (p Proxy) handleNewBlockHeaderEvent(result tmCoreTypes.ResultEvent) {
@@ -190,6 +549,10 @@ func (p Proxy) handleNewBlockHeaderEvent(result tmCoreTypes.ResultEvent) {
 	p.MemStore.SetHeight(height)
 
 	for _, evt := range data.ResultEndBlock.Events {
+		if evt.Type == types.EventTypeContractExpired {
+			p.handleContractExpiredEvent(evt)
+			continue
+		}
 		if evt.Type == types.EventTypeSettleContract {
 			input := make(map[string]string)
 			for _, attr := range evt.Attributes {
@@ -210,7 +573,7 @@ func (p Proxy) handleNewBlockHeaderEvent(result tmCoreTypes.ResultEvent) {
 				p.logger.Error("failed to get claim", "error", err)
 				continue
 			}
-			if currClaim.Nonce == newClaim.Nonce {
+			if currClaim.ClaimNonce() == newClaim.Nonce {
 				currClaim.Claimed = true
 				if err := p.ClaimStore.Set(currClaim); err != nil {
 					p.logger.Error("failed to set claimed", "error", err)
@@ -220,6 +583,38 @@ func (p Proxy) handleNewBlockHeaderEvent(result tmCoreTypes.ResultEvent) {
 	}
 }
 
+// handleContractExpiredEvent evicts the contract from the memstore, it can't
+// be used from the next block on. Its claims are left to be settled.
+func (p Proxy) handleContractExpiredEvent(event abci.Event) {
+	typedEvent, err := sdk.ParseTypedEvent(event)
+	if err != nil {
+		p.logger.Error("failed to parse typed event", "error", err)
+		return
+	}
+
+	evt, ok := typedEvent.(*types.EventContractExpired)
+	if !ok {
+		p.logger.Error(fmt.Sprintf("failed to cast %T to EventContractExpired", typedEvent))
+		return
+	}
+
+	if !p.isMyPubKey(evt.Provider) {
+		return
+	}
+	p.logger.Info("contract expired", "id", evt.ContractId, "nonce", evt.Nonce, "settlement_pending", evt.SettlementPending)
+	p.SpendTracker.Remove(evt.ContractId)
+	p.DailySpendTracker.Remove(evt.ContractId)
+	p.MemStore.Put(types.Contract{
+		Provider: evt.Provider,
+		Service:  common.Service(common.ServiceLookup[evt.Service]),
+		Services: parseServices(evt.Services),
+		Client:   evt.Client,
+		Delegate: evt.Delegate,
+		Id:       evt.ContractId,
+	})
+	p.closeContractSessions(evt.ContractId)
+}
+
 func (p Proxy) isMyPubKey(pk common.PubKey) bool {
 	return pk.Equals(p.Config.ProviderPubKey)
 }
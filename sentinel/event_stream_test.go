@@ -1,8 +1,14 @@
 package sentinel
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/arkeonetwork/arkeo/app"
 	"github.com/arkeonetwork/arkeo/common"
@@ -14,6 +20,7 @@ import (
 	abciTypes "github.com/tendermint/tendermint/abci/types"
 	tmCoreTypes "github.com/tendermint/tendermint/rpc/core/types"
 	tmtypes "github.com/tendermint/tendermint/types"
+	"golang.org/x/time/rate"
 )
 
 func newTestConfig() conf.Configuration {
@@ -28,6 +35,7 @@ func newTestConfig() conf.Configuration {
 		SourceChain:        "http://localhost:1317", // this should point to arkeo rpc endpoints, but we can ignore for testing
 		EventStreamHost:    "localhost",
 		ProviderPubKey:     types.GetRandomPubKey(),
+		FreeTierEnabled:    true,
 		FreeTierRateLimit:  100,
 		ClaimStoreLocation: "",
 	}
@@ -105,6 +113,105 @@ func TestHandleOpenContractEvent(t *testing.T) {
 	require.Equal(t, inputContract, outputContract)
 }
 
+func TestOpenContractDefaultConfig(t *testing.T) {
+	testConfig := newTestConfig()
+	testConfig.DefaultPerUserRateLimit = 60
+	testConfig.DefaultPerUserBurstSize = 2
+	proxy := NewProxy(testConfig)
+	inputContract := types.Contract{
+		Provider:           testConfig.ProviderPubKey,
+		Service:            common.BTCService,
+		Client:             types.GetRandomPubKey(),
+		Delegate:           common.EmptyPubKey,
+		Type:               types.ContractType_PAY_AS_YOU_GO,
+		Height:             100,
+		Duration:           100,
+		Rate:               cosmos.NewInt64Coin("uarkeo", 1),
+		Deposit:            sdk.NewInt(100),
+		Id:                 31,
+		SettlementDuration: 10,
+		QueriesPerMinute:   10,
+	}
+	openEvent := types.NewOpenContractEvent(100, &inputContract)
+	open := func() {
+		sdkEvt, err := sdk.TypedEventToEvent(&openEvent)
+		require.NoError(t, err)
+		proxy.handleOpenContractEvent(makeResultEvent(sdkEvt, openEvent.Height))
+	}
+
+	require.False(t, proxy.ContractConfigStore.Has(inputContract.Id))
+	open()
+	require.True(t, proxy.ContractConfigStore.Has(inputContract.Id))
+	config, err := proxy.ContractConfigStore.Get(inputContract.Id)
+	require.NoError(t, err)
+	require.Equal(t, 60, config.PerUserRateLimit)
+	require.Equal(t, 2, config.PerUserBurstSize)
+	require.Equal(t, []string{"GET", "POST"}, config.CORs.AllowMethods)
+
+	// users are limited to the burst size
+	require.False(t, proxy.isUserRateLimited(inputContract.Id, "10.0.0.1", config.PerUserRateLimit, config.PerUserBurstSize))
+	require.False(t, proxy.isUserRateLimited(inputContract.Id, "10.0.0.1", config.PerUserRateLimit, config.PerUserBurstSize))
+	require.True(t, proxy.isUserRateLimited(inputContract.Id, "10.0.0.1", config.PerUserRateLimit, config.PerUserBurstSize))
+
+	// the configuration set by the client is kept on a later open event
+	config.PerUserRateLimit = 5
+	require.NoError(t, proxy.ContractConfigStore.Set(config))
+	open()
+	config, err = proxy.ContractConfigStore.Get(inputContract.Id)
+	require.NoError(t, err)
+	require.Equal(t, 5, config.PerUserRateLimit)
+}
+
+func TestHandleSetContractConfigEvent(t *testing.T) {
+	testConfig := newTestConfig()
+	testConfig.DefaultPerUserRateLimit = 60
+	proxy := NewProxy(testConfig)
+	evt := types.EventSetContractConfig{
+		ContractId:       41,
+		Provider:         testConfig.ProviderPubKey,
+		Service:          common.BTCService.String(),
+		Client:           types.GetRandomPubKey(),
+		Cors:             types.ContractCORs{AllowOrigins: []string{"https://example.com"}, AllowMethods: []string{"GET"}},
+		WhitelistIps:     []string{"10.0.0.1"},
+		PerUserRateLimit: 30,
+	}
+	set := func() {
+		sdkEvt, err := sdk.TypedEventToEvent(&evt)
+		require.NoError(t, err)
+		proxy.handleSetContractConfigEvent(makeResultEvent(sdkEvt, 10))
+	}
+
+	// a contract without a configuration gets one
+	set()
+	config, err := proxy.ContractConfigStore.Get(evt.ContractId)
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://example.com"}, config.CORs.AllowOrigins)
+	require.Equal(t, []string{"GET"}, config.CORs.AllowMethods)
+	require.Empty(t, config.CORs.AllowHeaders)
+	require.Equal(t, []string{"10.0.0.1"}, config.WhitelistIPAddresses)
+	require.Equal(t, 30, config.PerUserRateLimit)
+
+	// the settings only kept by the sentinel are left as is
+	config.DailySpendCapUSD = 5
+	config.PerUserBurstSize = 3
+	require.NoError(t, proxy.ContractConfigStore.Set(config))
+	evt.WhitelistIps = nil
+	evt.PerUserRateLimit = 0
+	set()
+	config, err = proxy.ContractConfigStore.Get(evt.ContractId)
+	require.NoError(t, err)
+	require.Empty(t, config.WhitelistIPAddresses)
+	require.Equal(t, 0, config.PerUserRateLimit)
+	require.Equal(t, 5.0, config.DailySpendCapUSD)
+	require.Equal(t, 3, config.PerUserBurstSize)
+
+	// the contracts of other providers are ignored
+	evt.ContractId = 42
+	evt.Provider = types.GetRandomPubKey()
+	set()
+	require.False(t, proxy.ContractConfigStore.Has(evt.ContractId))
+}
+
 func TestHandleCloseContractEvent(t *testing.T) {
 	testConfig := newTestConfig()
 	proxy := NewProxy(testConfig)
@@ -155,6 +262,106 @@ func TestHandleCloseContractEvent(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestHandleExtendContractEvent(t *testing.T) {
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	contract := types.Contract{
+		Provider:         testConfig.ProviderPubKey,
+		Service:          common.BTCService,
+		Client:           types.GetRandomPubKey(),
+		Delegate:         common.EmptyPubKey,
+		Type:             types.ContractType_SUBSCRIPTION,
+		Height:           100,
+		Duration:         100,
+		Rate:             cosmos.NewInt64Coin("uarkeo", 1),
+		Deposit:          sdk.NewInt(100),
+		Id:               1,
+		QueriesPerMinute: 1,
+	}
+	openEvent := types.NewOpenContractEvent(100, &contract)
+	sdkEvt, err := sdk.TypedEventToEvent(&openEvent)
+	require.NoError(t, err)
+	proxy.handleOpenContractEvent(makeResultEvent(sdkEvt, openEvent.Height))
+
+	extended := contract
+	extended.Duration = 200
+	extended.Deposit = sdk.NewInt(200)
+	extendEvent := types.NewExtendContractEvent(&extended, contract.Expiration())
+	sdkEvt, err = sdk.TypedEventToEvent(&extendEvent)
+	require.NoError(t, err)
+	proxy.handleExtendContractEvent(makeResultEvent(sdkEvt, 150))
+
+	// the contract is served past its old expiration without being fetched
+	proxy.MemStore.SetHeight(250)
+	output, err := proxy.MemStore.Get(contract.Key())
+	require.NoError(t, err)
+	require.Equal(t, extended, output)
+
+	// the contracts of other providers are ignored
+	other := extended
+	other.Duration = 300
+	extendEvent = types.NewExtendContractEvent(&other, extended.Expiration())
+	extendEvent.Provider = types.GetRandomPubKey()
+	sdkEvt, err = sdk.TypedEventToEvent(&extendEvent)
+	require.NoError(t, err)
+	proxy.handleExtendContractEvent(makeResultEvent(sdkEvt, 260))
+	output, ok := proxy.MemStore.Peek(contract.Key())
+	require.True(t, ok)
+	require.Equal(t, int64(200), output.Duration)
+}
+
+func TestHandleTopUpContractEvent(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Deposit = cosmos.NewInt(3)
+	contract.Id = 5
+	proxy.MemStore.SetHeight(20)
+	proxy.MemStore.Put(contract)
+
+	paid := func(nonce int64) int {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=%d:%d", contract.Id, nonce), nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response.Code
+	}
+
+	// the deposit is spent
+	require.Equal(t, http.StatusOK, paid(3))
+	require.Equal(t, http.StatusPaymentRequired, paid(4))
+
+	// and served again as soon as it is topped up
+	toppedUp := contract
+	toppedUp.Deposit = cosmos.NewInt(10)
+	topUpEvent := types.NewTopUpContractEvent(&toppedUp, cosmos.NewInt(7))
+	sdkEvt, err := sdk.TypedEventToEvent(&topUpEvent)
+	require.NoError(t, err)
+	proxy.handleTopUpContractEvent(makeResultEvent(sdkEvt, 21))
+	output, ok := proxy.MemStore.Peek(contract.Key())
+	require.True(t, ok)
+	require.Equal(t, cosmos.NewInt(10), output.Deposit)
+	require.Equal(t, http.StatusOK, paid(4))
+
+	// the contracts of other providers are ignored
+	toppedUp.Deposit = cosmos.NewInt(20)
+	topUpEvent = types.NewTopUpContractEvent(&toppedUp, cosmos.NewInt(10))
+	topUpEvent.Provider = types.GetRandomPubKey()
+	sdkEvt, err = sdk.TypedEventToEvent(&topUpEvent)
+	require.NoError(t, err)
+	proxy.handleTopUpContractEvent(makeResultEvent(sdkEvt, 22))
+	output, ok = proxy.MemStore.Peek(contract.Key())
+	require.True(t, ok)
+	require.Equal(t, cosmos.NewInt(10), output.Deposit)
+}
+
 func TestHandleHandleContractSettlementEvent(t *testing.T) {
 	testConfig := newTestConfig()
 	proxy := NewProxy(testConfig)
@@ -233,7 +440,175 @@ func TestHandleHandleContractSettlementEvent(t *testing.T) {
 }
 
 func TestHandleNewBlockHeaderEvent(t *testing.T) {
-	// TODO: add tests
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	contract := types.Contract{
+		Provider:           testConfig.ProviderPubKey,
+		Service:            common.BTCService,
+		Client:             types.GetRandomPubKey(),
+		Delegate:           common.EmptyPubKey,
+		Type:               types.ContractType_PAY_AS_YOU_GO,
+		Height:             100,
+		Duration:           100,
+		Rate:               cosmos.NewInt64Coin("uarkeo", 1),
+		Deposit:            sdk.NewInt(100),
+		Id:                 1,
+		SettlementDuration: 10,
+	}
+	other := contract
+	other.Provider = types.GetRandomPubKey()
+	proxy.MemStore.Put(contract)
+
+	endBlock := func(height int64, contracts ...types.Contract) tmCoreTypes.ResultEvent {
+		result := newBlockEvent(height)
+		data := result.Data.(tmtypes.EventDataNewBlockHeader)
+		for _, contract := range contracts {
+			evt := types.NewContractExpiredEvent(&contract, height)
+			sdkEvt, err := sdk.TypedEventToEvent(&evt)
+			require.NoError(t, err)
+			data.ResultEndBlock.Events = append(data.ResultEndBlock.Events, abciTypes.Event(sdkEvt))
+		}
+		result.Data = data
+		return result
+	}
+
+	// the contract of another provider expiring is ignored
+	proxy.handleNewBlockHeaderEvent(endBlock(150, other))
+	require.Equal(t, int64(150), proxy.MemStore.GetHeight())
+	_, ok := proxy.MemStore.Peek(contract.Key())
+	require.True(t, ok)
+
+	// the contract is evicted once it expires, before its settlement
+	proxy.handleNewBlockHeaderEvent(endBlock(contract.Expiration(), contract))
+	_, ok = proxy.MemStore.Peek(contract.Key())
+	require.False(t, ok)
+}
+
+// fakeEventClient delivers the blocks sent on newBlock, its other
+// subscriptions never receive anything
+type fakeEventClient struct {
+	newBlock chan tmCoreTypes.ResultEvent
+}
+
+func (c fakeEventClient) Start() error { return nil }
+
+func (c fakeEventClient) Stop() error { return nil }
+
+func (c fakeEventClient) Subscribe(_ context.Context, _, query string, _ ...int) (<-chan tmCoreTypes.ResultEvent, error) {
+	if query == "tm.event = 'NewBlockHeader'" {
+		return c.newBlock, nil
+	}
+	return make(chan tmCoreTypes.ResultEvent), nil
+}
+
+type fakeContractSource struct {
+	lock      sync.Mutex
+	height    int64
+	contracts []types.Contract
+}
+
+func (s *fakeContractSource) set(height int64, contracts ...types.Contract) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.height, s.contracts = height, contracts
+}
+
+func (s *fakeContractSource) LatestHeight(context.Context) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.height, nil
+}
+
+func (s *fakeContractSource) ProviderContracts(_ context.Context, provider common.PubKey) ([]types.Contract, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.contracts, nil
+}
+
+func newBlockEvent(height int64) tmCoreTypes.ResultEvent {
+	return tmCoreTypes.ResultEvent{
+		Data: tmtypes.EventDataNewBlockHeader{Header: tmtypes.Header{Height: height}},
+	}
+}
+
+func TestEventStreamReconnect(t *testing.T) {
+	defer func(backoff time.Duration) { eventStreamMinBackoff = backoff }(eventStreamMinBackoff)
+	eventStreamMinBackoff = 10 * time.Millisecond
+
+	testConfig := newTestConfig()
+	testConfig.BlockGapThreshold = 5
+	testConfig.EventStreamTimeoutSec = 60
+	proxy := NewProxy(testConfig)
+	newContract := func(id uint64) types.Contract {
+		contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+		contract.Type = types.ContractType_PAY_AS_YOU_GO
+		contract.Rate = cosmos.NewInt64Coin("uarkeo", 1)
+		contract.Deposit = cosmos.NewInt(100)
+		contract.Height = 5
+		contract.Duration = 100
+		contract.Id = id
+		return contract
+	}
+	closed, missed := newContract(1), newContract(2)
+	source := &fakeContractSource{}
+	source.set(10, closed)
+
+	// the first dial fails, then the stream is served by two clients in turn
+	first := fakeEventClient{newBlock: make(chan tmCoreTypes.ResultEvent)}
+	second := fakeEventClient{newBlock: make(chan tmCoreTypes.ResultEvent)}
+	dials := make(chan eventClient, 2)
+	dials <- first
+	dials <- second
+	failed := false
+	dial := func() (eventClient, error) {
+		if !failed {
+			failed = true
+			return nil, errors.New("connection refused")
+		}
+		return <-dials, nil
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		proxy.listenEvents(dial, source, done)
+		close(stopped)
+	}()
+
+	first.newBlock <- newBlockEvent(11)
+	require.Eventually(t, func() bool { return proxy.MemStore.GetHeight() == 11 }, time.Second, 5*time.Millisecond)
+	_, ok := proxy.MemStore.Peek(closed.Key())
+	require.True(t, ok)
+
+	// while the subscription is down, a contract is closed and another opened
+	source.set(50, missed)
+	close(first.newBlock)
+
+	require.Eventually(t, func() bool { return proxy.eventStream.Status().Resyncs == 2 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, int64(50), proxy.MemStore.GetHeight())
+	_, ok = proxy.MemStore.Peek(closed.Key())
+	require.False(t, ok)
+	contract, ok := proxy.MemStore.Peek(missed.Key())
+	require.True(t, ok)
+	require.Equal(t, missed, contract)
+	require.True(t, proxy.ContractConfigStore.Has(missed.Id))
+
+	second.newBlock <- newBlockEvent(51)
+	require.Eventually(t, func() bool { return proxy.MemStore.GetHeight() == 51 }, time.Second, 5*time.Millisecond)
+	status := proxy.eventStream.Status()
+	require.True(t, status.Connected)
+	require.Equal(t, uint64(1), status.Reconnects)
+	require.Equal(t, uint64(1), status.BlockGaps)
+	require.Equal(t, int64(38), status.MissedBlocks)
+
+	// a jump of the height over the threshold resyncs as well
+	second.newBlock <- newBlockEvent(60)
+	require.Eventually(t, func() bool { return proxy.eventStream.Status().Resyncs == 3 }, time.Second, 5*time.Millisecond)
+	require.Equal(t, uint64(2), proxy.eventStream.Status().BlockGaps)
+
+	close(done)
+	<-stopped
+	require.False(t, proxy.eventStream.Status().Connected)
 }
 
 func makeResultEvent(sdkEvent sdk.Event, height int64) tmCoreTypes.ResultEvent {
@@ -73,6 +73,8 @@ func parseProviderModEvent(input map[string]string) (ProviderModEvent, error) {
 			}
 		case "metadata_uri":
 			evt.Provider.MetadataUri = v
+		case "metadata_hash":
+			evt.Provider.MetadataHash = v
 		case "metadata_nonce":
 			evt.Provider.MetadataNonce, err = strconv.ParseUint(v, 10, 64)
 			if err != nil {
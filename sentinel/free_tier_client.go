@@ -0,0 +1,123 @@
+package sentinel
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ways to tell apart free tier clients sharing an address
+	FreeTierClientFingerprint = "fingerprint"
+	FreeTierClientToken       = "token"
+
+	// anonymous client token, issued on the first free tier response and sent
+	// back by the client in the header or the cookie
+	FreeTierClientHeader = "X-Ark-Client"
+	FreeTierClientCookie = "ark_client"
+)
+
+// newClientSecret returns the key of the client fingerprints and tokens. A
+// random key is generated when none is configured, tokens are then only valid
+// until sentinel restarts.
+func newClientSecret(secret string) []byte {
+	if len(secret) > 0 {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func (p Proxy) clientMAC(parts ...string) string {
+	mac := hmac.New(sha256.New, p.clientSecret)
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// freeTierClient identifies the client of a free tier request among the
+// others of its remote address, it returns an empty id when clients aren't
+// told apart
+func (p Proxy) freeTierClient(w http.ResponseWriter, r *http.Request, remoteAddr string) string {
+	config := p.config()
+	if config.FreeTierClientRateLimit <= 0 {
+		return ""
+	}
+	switch p.Config.FreeTierClientMode {
+	case FreeTierClientFingerprint:
+		return p.clientFingerprint(r, remoteAddr)
+	case FreeTierClientToken:
+		token := r.Header.Get(FreeTierClientHeader)
+		if len(token) == 0 {
+			if cookie, err := r.Cookie(FreeTierClientCookie); err == nil {
+				token = cookie.Value
+			}
+		}
+		now := time.Now()
+		id, err := p.verifyClientToken(token, now)
+		if err != nil {
+			var expiry time.Time
+			id, token, expiry = p.newClientToken(now)
+			http.SetCookie(w, &http.Cookie{
+				Name:     FreeTierClientCookie,
+				Value:    token,
+				Path:     "/",
+				Expires:  expiry,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+			w.Header().Set(FreeTierClientHeader, token)
+		}
+		return id
+	default:
+		return ""
+	}
+}
+
+// clientFingerprint is a keyed hash of the address, user agent and language
+// of the client, so fingerprints can't be computed without the secret
+func (p Proxy) clientFingerprint(r *http.Request, remoteAddr string) string {
+	return p.clientMAC(remoteAddr, r.UserAgent(), r.Header.Get("Accept-Language"))[:32]
+}
+
+// newClientToken issues a token for a new client id, it is made of the id,
+// its expiry (unix seconds) and their HMAC
+func (p Proxy) newClientToken(now time.Time) (id, token string, expiry time.Time) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	id = hex.EncodeToString(buf)
+	expiry = now.Add(time.Duration(p.Config.FreeTierClientTokenTTLSec) * time.Second)
+	exp := strconv.FormatInt(expiry.Unix(), 10)
+	return id, fmt.Sprintf("%s.%s.%s", id, exp, p.clientMAC(id, exp)), expiry
+}
+
+// verifyClientToken returns the client id of a token issued by this sentinel
+// that hasn't expired
+func (p Proxy) verifyClientToken(token string, now time.Time) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || len(parts[0]) == 0 {
+		return "", errors.New("malformed client token")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(p.clientMAC(parts[0], parts[1]))) {
+		return "", errors.New("invalid client token")
+	}
+	exp, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid client token expiry: %w", err)
+	}
+	if now.Unix() >= exp {
+		return "", errors.New("client token expired")
+	}
+	return parts[0], nil
+}
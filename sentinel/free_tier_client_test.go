@@ -0,0 +1,134 @@
+package sentinel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func newFreeTierClientProxy(t *testing.T, mode string) (Proxy, func(userAgent, token string) *httptest.ResponseRecorder) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte("ok"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	testConfig := newTestConfig()
+	testConfig.FreeTierRateLimit = 5
+	testConfig.FreeTierRemainingHeader = true
+	testConfig.FreeTierClientMode = mode
+	testConfig.FreeTierClientRateLimit = 2
+	testConfig.FreeTierClientSecret = "secret"
+	testConfig.FreeTierClientTokenTTLSec = 3600
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+
+	// every client is behind the same address
+	serve := func(userAgent, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/", nil)
+		req.Header.Set(xRealIPName, "10.0.0.1")
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set("Accept-Language", "en-US")
+		if len(token) > 0 {
+			req.Header.Set(FreeTierClientHeader, token)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+	return proxy, serve
+}
+
+func TestFreeTierClientFingerprint(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	_, serve := newFreeTierClientProxy(t, FreeTierClientFingerprint)
+
+	// each client has its own allowance
+	for _, ua := range []string{"alice", "bob"} {
+		for i := 0; i < 2; i++ {
+			require.Equal(t, http.StatusOK, serve(ua, "").Code, ua)
+		}
+		response := serve(ua, "")
+		require.Equal(t, http.StatusTooManyRequests, response.Code, ua)
+		require.Equal(t, "0", response.Header().Get(RateLimitRemainingHeader))
+	}
+
+	// the allowance of the address caps them all
+	response := serve("carol", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "0", response.Header().Get(RateLimitRemainingHeader))
+	require.Equal(t, http.StatusTooManyRequests, serve("carol", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, serve("dave", "").Code)
+}
+
+func TestFreeTierClientToken(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	proxy, serve := newFreeTierClientProxy(t, FreeTierClientToken)
+
+	// the first response issues a token to the client
+	tokens := make([]string, 3)
+	for i := range tokens {
+		response := serve("browser", "")
+		require.Equal(t, http.StatusOK, response.Code)
+		tokens[i] = response.Header().Get(FreeTierClientHeader)
+		require.NotEmpty(t, tokens[i])
+		cookies := response.Result().Cookies()
+		require.Len(t, cookies, 1)
+		require.Equal(t, FreeTierClientCookie, cookies[0].Name)
+		require.Equal(t, tokens[i], cookies[0].Value)
+	}
+
+	// clients sending their token back keep it, and their own allowance
+	response := serve("browser", tokens[0])
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Empty(t, response.Header().Get(FreeTierClientHeader))
+	require.Equal(t, http.StatusTooManyRequests, serve("browser", tokens[0]).Code)
+	require.Equal(t, http.StatusOK, serve("browser", tokens[1]).Code)
+
+	// the allowance of the address (5) is used up
+	require.Equal(t, http.StatusTooManyRequests, serve("browser", tokens[2]).Code)
+
+	// the token is read from the cookie as well
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/", nil)
+	req.Header.Set(xRealIPName, "10.0.0.1")
+	req.AddCookie(&http.Cookie{Name: FreeTierClientCookie, Value: tokens[0]})
+	response = httptest.NewRecorder()
+	proxy.getRouter().ServeHTTP(response, req)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Empty(t, response.Header().Get(FreeTierClientHeader))
+
+	// forged tokens are replaced
+	parts := strings.Split(tokens[0], ".")
+	forged := []string{
+		"bogus",
+		strings.Join([]string{"another-id", parts[1], parts[2]}, "."),
+		strings.Join([]string{parts[0], "9999999999", parts[2]}, "."),
+	}
+	for _, token := range forged {
+		_, err := proxy.verifyClientToken(token, time.Now())
+		require.Error(t, err, token)
+		response := serve("browser", token)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.NotEmpty(t, response.Header().Get(FreeTierClientHeader))
+		require.NotEqual(t, token, response.Header().Get(FreeTierClientHeader))
+	}
+
+	// tokens expire
+	id, err := proxy.verifyClientToken(tokens[0], time.Now())
+	require.NoError(t, err)
+	require.Equal(t, parts[0], id)
+	_, err = proxy.verifyClientToken(tokens[0], time.Now().Add(time.Hour))
+	require.ErrorContains(t, err, "expired")
+
+	// tokens of another secret are invalid
+	other := NewProxy(newTestConfig())
+	_, err = other.verifyClientToken(tokens[0], time.Now())
+	require.Error(t, err)
+}
@@ -0,0 +1,443 @@
+package sentinel
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// GRPCErrorCodeKey is the trailer carrying the code of a call refused by the
+// proxy, see ErrorCode
+const GRPCErrorCodeKey = "x-ark-error-code"
+
+// how often the contract of a grpc call is checked for expiry
+var grpcExpiryInterval = 5 * time.Second
+
+// rawCodec passes the messages of the proxied calls through as is, the proxy
+// doesn't know their types
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	frame, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("grpc proxy can't marshal %T", v)
+	}
+	return *frame, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	frame, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("grpc proxy can't unmarshal into %T", v)
+	}
+	*frame = append((*frame)[:0], data...)
+	return nil
+}
+
+// Name is the one of the proto codec, the content type of the calls is left
+// unchanged
+func (rawCodec) Name() string { return "proto" }
+
+// grpcConns holds a connection to each grpc upstream, shared by the calls
+type grpcConns struct {
+	lock  sync.Mutex
+	conns map[string]*grpc.ClientConn
+	opts  []grpc.DialOption // added to the options of every connection
+}
+
+func newGRPCConns(opts ...grpc.DialOption) *grpcConns {
+	return &grpcConns{conns: make(map[string]*grpc.ClientConn), opts: opts}
+}
+
+func (c *grpcConns) get(upstream *url.URL) (*grpc.ClientConn, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	key := upstream.Host
+	if conn, ok := c.conns[key]; ok {
+		return conn, nil
+	}
+	creds := insecure.NewCredentials()
+	if upstream.Scheme == "https" {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	opts := append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, c.opts...)
+	conn, err := grpc.Dial(upstream.Host, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[key] = conn
+	return conn, nil
+}
+
+// NewGRPCServer returns the proxy of the services whose upstreams speak grpc.
+// The service of a call is told by its arkservice metadata, which can be left
+// out when a single service is proxied.
+func (p Proxy) NewGRPCServer() (*grpc.Server, error) {
+	opts := []grpc.ServerOption{
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(p.handleGRPC),
+	}
+	if p.Config.TLS.HasTLS() {
+		tlsConfig, err := serverTLSConfig(p.Config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	return grpc.NewServer(opts...), nil
+}
+
+// ServeGRPC serves the grpc proxy on the grpc port, until the server is
+// stopped
+func (p Proxy) ServeGRPC(server *grpc.Server) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", p.Config.GRPCPort))
+	if err != nil {
+		return err
+	}
+	return server.Serve(listener)
+}
+
+// handleGRPC authorizes and forwards a call of any method, unary or streamed
+func (p Proxy) handleGRPC(_ interface{}, stream grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(stream)
+	if !ok {
+		return status.Error(codes.Internal, "unknown grpc method")
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+
+	serviceName, err := p.grpcService(md)
+	if err != nil {
+		return err
+	}
+	upstreams, exists := p.config().proxies[serviceName]
+	if !exists || len(upstreams) == 0 {
+		return status.Error(codes.Unimplemented, "could not find service")
+	}
+
+	pay, err := p.grpcAuth(stream, md, serviceName)
+	if err != nil {
+		return err
+	}
+
+	if p.DryRun {
+		p.settleGRPC(stream, pay, true)
+		return nil
+	}
+
+	candidates := p.Upstreams.candidates(serviceName, upstreams, p.config().UpstreamBalancing == UpstreamBalancingRoundRobin)
+	conn, err := p.grpcConns.get(candidates[0])
+	if err != nil {
+		p.settleGRPC(stream, pay, false)
+		p.logger.Error("fail to dial grpc upstream", "error", err, "service", serviceName)
+		return status.Error(codes.Unavailable, "fail to connect to upstream service")
+	}
+	return p.proxyGRPC(stream, conn, method, md, candidates[0], pay)
+}
+
+// grpcService returns the service of a call
+func (p Proxy) grpcService(md metadata.MD) (string, error) {
+	services := p.Config.GRPCServices
+	name := grpcMetadataValue(md, ServiceHeader)
+	if len(name) == 0 && len(services) == 1 {
+		return services[0], nil
+	}
+	for _, service := range services {
+		if service == name {
+			return name, nil
+		}
+	}
+	return "", status.Errorf(codes.Unimplemented, "could not find grpc service %q, set the %s metadata", name, ServiceHeader)
+}
+
+// parseGRPCArkAuth reads the arkauth of a grpc call from its arkauth
+// metadata, the way fetchArkAuth reads the one of an http request. A call
+// without one gets an empty arkauth.
+func parseGRPCArkAuth(md metadata.MD) (ArkAuth, error) {
+	raw := grpcMetadataValue(md, QueryArkAuth)
+	if len(raw) == 0 {
+		return ArkAuth{}, nil
+	}
+	return parseArkAuth(raw)
+}
+
+// grpcAuth serves the call on the paid or the free tier, the same way the
+// auth middleware does for http requests. Each call is paid once, a streamed
+// call when it is opened. The payment of a paid call is returned, nil on the
+// free tier, it is settled once the upstream answers.
+func (p Proxy) grpcAuth(stream grpc.ServerStream, md metadata.MD, serviceName string) (*payment, error) {
+	remoteAddr := p.grpcRemoteAddr(stream.Context(), md)
+	if banErr := p.ipBanError(remoteAddr); banErr != nil {
+		return nil, grpcError(stream, http.StatusForbidden, banErr)
+	}
+
+	aa, err := parseGRPCArkAuth(md)
+	if err != nil {
+		p.logger.Error("failed to parse ark auth", "error", err)
+		return nil, grpcError(stream, http.StatusBadRequest, newProxyError(ErrCodeBadArkAuth, "%w", err))
+	}
+	var contract types.Contract
+	if aa.ContractId > 0 {
+		contract, err = p.MemStore.Get(strconv.FormatUint(aa.ContractId, 10))
+		if err != nil {
+			p.logger.Error("failed to fetch contract", "error", err)
+		}
+	}
+	if aa.ContractId > 0 && err == nil {
+		if rotatedErr := p.rotatedDelegateError(aa, contract); rotatedErr != nil {
+			p.logger.Error("refused ark auth", "error", rotatedErr, "contract_id", aa.ContractId)
+			return nil, grpcError(stream, http.StatusUnauthorized, rotatedErr)
+		}
+		if spenderErr := p.unlistedSpenderError(aa, contract); spenderErr != nil {
+			p.logger.Error("refused ark auth", "error", spenderErr, "contract_id", aa.ContractId)
+			return nil, grpcError(stream, http.StatusUnauthorized, spenderErr)
+		}
+	}
+	if aa.ContractId > 0 && p.config().StrictAuth {
+		if authErr := p.arkAuthError(aa, contract, err); authErr != nil {
+			p.logger.Error("refused ark auth", "error", authErr, "contract_id", aa.ContractId)
+			return nil, grpcError(stream, http.StatusUnauthorized, authErr)
+		}
+	}
+	// calls the contract doesn't pay for are refused on both tiers
+	ser, serviceErr := common.NewService(serviceName)
+	if serviceErr != nil || (!contract.Client.IsEmpty() && !contract.HasService(ser)) {
+		return nil, grpcError(stream, http.StatusUnauthorized, newProxyError(ErrCodeServiceMismatch, "contract service doesn't match the grpc service: (%s/%d)", serviceName, contract.Service))
+	}
+	var dailySpendCapUSD float64
+	if !contract.Client.IsEmpty() {
+		conf, err := p.ContractConfigStore.Get(contract.Id)
+		if err != nil {
+			p.logger.Error("failed to fetch contract configuration", "error", err)
+		}
+		dailySpendCapUSD = conf.DailySpendCapUSD
+		if httpCode, _, err := p.contractConfigError(conf, contract.Id, remoteAddr); err != nil {
+			return nil, grpcError(stream, httpCode, err)
+		}
+	}
+
+	var paidErr error
+	if err == nil && (contract.IsOpenAuthorization() || aa.Validate(p.Config.ProviderPubKey) == nil) {
+		header := metadata.Pairs("tier", "paid")
+		pay, httpCode, err := p.reservePaidTier(aa, remoteAddr, 1, dailySpendCapUSD)
+		if err == nil {
+			if p.checkSpendVelocity(aa.ContractId, aa.Nonce, time.Now()) {
+				header.Set(SpendAlertHeader, "high")
+			}
+			_ = stream.SetHeader(header)
+			return pay, nil
+		}
+		if nonce, err := p.paidNonce(aa.ContractId); err == nil {
+			header.Set(NonceHeader, strconv.FormatInt(nonce, 10))
+		}
+		_ = stream.SetTrailer(header)
+		if errors.Is(err, errBlockQuotaExceeded) {
+			return nil, grpcError(stream, httpCode, err)
+		}
+		p.logger.Error("failed to serve paid tier grpc call", "error", err, "http_code", httpCode)
+		paidErr = err
+	}
+
+	if httpCode, err := p.freeTierDisabledError(serviceName, paidErr); err != nil {
+		return nil, grpcError(stream, httpCode, err)
+	}
+	_, retryAfter, httpCode, err := p.freeTierQuota(serviceName, remoteAddr, "")
+	if err != nil {
+		if retryAfter > 0 {
+			_ = stream.SetTrailer(metadata.Pairs(strings.ToLower(RetryAfterHeader), strconv.Itoa(retryAfterSeconds(retryAfter))))
+		}
+		if paidErr != nil {
+			err = paidErr
+		}
+		return nil, grpcError(stream, httpCode, err)
+	}
+	_ = stream.SetHeader(metadata.Pairs("tier", "free"))
+	return nil, nil
+}
+
+// settleGRPC commits the payment of a call answered by the upstream, the
+// nonce paid is sent with the headers. The payment of a call that wasn't
+// answered is released, its nonce can be used again.
+func (p Proxy) settleGRPC(stream grpc.ServerStream, pay *payment, answered bool) {
+	if pay == nil {
+		return
+	}
+	if !answered {
+		pay.release()
+		return
+	}
+	if err := pay.commit(); err != nil {
+		p.logger.Error("fail to commit paid grpc call", "error", err, "contract_id", pay.contract.Id)
+	}
+	if nonce, err := p.paidNonce(pay.contract.Id); err == nil {
+		_ = stream.SetHeader(metadata.Pairs(NonceHeader, strconv.FormatInt(nonce, 10)))
+	}
+}
+
+// proxyGRPC forwards the call to the upstream and copies the messages both
+// ways. The call is paid for once the upstream sends a message or ends it
+// successfully, and canceled once the contract paying for it expires.
+func (p Proxy) proxyGRPC(stream grpc.ServerStream, conn *grpc.ClientConn, method string, md metadata.MD, upstream *url.URL, pay *payment) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	var contractId uint64
+	if pay != nil {
+		contractId = pay.contract.Id
+	}
+	var settled sync.Once
+	settle := func(answered bool) {
+		settled.Do(func() { p.settleGRPC(stream, pay, answered) })
+	}
+	defer settle(false)
+
+	out := md.Copy()
+	out.Delete(QueryArkAuth)
+	out.Delete(ServiceHeader)
+	out.Delete(":authority")
+	if upstream.User != nil {
+		passwd, _ := upstream.User.Password()
+		out.Set("authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(upstream.User.Username()+":"+passwd)))
+	}
+	ctx = metadata.NewOutgoingContext(ctx, out)
+
+	desc := &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}
+	upstreamStream, err := conn.NewStream(ctx, desc, method, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return err
+	}
+
+	clientErrs := make(chan error, 1)
+	upstreamErrs := make(chan error, 1)
+	go func() { clientErrs <- forwardGRPCRequests(upstreamStream, stream) }()
+	go func() { upstreamErrs <- forwardGRPCResponses(stream, upstreamStream, func() { settle(true) }) }()
+
+	ticker := time.NewTicker(grpcExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-clientErrs:
+			if !errors.Is(err, io.EOF) {
+				return status.Error(codes.Canceled, err.Error())
+			}
+			// the client is done sending, the upstream still answers
+			_ = upstreamStream.CloseSend()
+			clientErrs = nil
+		case err := <-upstreamErrs:
+			if errors.Is(err, io.EOF) {
+				settle(true)
+				stream.SetTrailer(upstreamStream.Trailer())
+				return nil
+			}
+			stream.SetTrailer(upstreamStream.Trailer())
+			return err
+		case <-ticker.C:
+			if contractId > 0 && p.isContractExpired(contractId) {
+				p.logger.Info("closing grpc call of expired contract", "contract_id", contractId, "method", method)
+				return grpcError(stream, http.StatusPaymentRequired, newProxyError(ErrCodeContractExpired, "contract %d expired", contractId))
+			}
+		}
+	}
+}
+
+// forwardGRPCRequests copies the messages of the client to the upstream
+func forwardGRPCRequests(dst grpc.ClientStream, src grpc.ServerStream) error {
+	for {
+		var frame []byte
+		if err := src.RecvMsg(&frame); err != nil {
+			return err
+		}
+		if err := dst.SendMsg(&frame); err != nil {
+			return err
+		}
+	}
+}
+
+// forwardGRPCResponses copies the headers and messages of the upstream to the
+// client, answered is called once the upstream sent its first message
+func forwardGRPCResponses(dst grpc.ServerStream, src grpc.ClientStream, answered func()) error {
+	for i := 0; ; i++ {
+		var frame []byte
+		if err := src.RecvMsg(&frame); err != nil {
+			return err
+		}
+		if i == 0 {
+			answered()
+			// the headers of the upstream are known once it answered
+			header, err := src.Header()
+			if err != nil {
+				return err
+			}
+			if err := dst.SendHeader(header); err != nil {
+				return err
+			}
+		}
+		if err := dst.SendMsg(&frame); err != nil {
+			return err
+		}
+	}
+}
+
+// grpcError returns the status of a call refused by the proxy, its code is
+// sent in the trailer
+func grpcError(stream grpc.ServerStream, httpCode int, err error) error {
+	stream.SetTrailer(metadata.Pairs(GRPCErrorCodeKey, string(errorCode(err, httpCode))))
+	return status.Error(grpcCode(httpCode), err.Error())
+}
+
+// grpcCode returns the grpc code matching the http status of a refusal
+func grpcCode(httpCode int) codes.Code {
+	switch httpCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusPaymentRequired, http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+func grpcMetadataValue(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// grpcRemoteAddr returns the ip of the client, see getRemoteAddr
+func (p Proxy) grpcRemoteAddr(ctx context.Context, md metadata.MD) string {
+	var addr string
+	if pr, ok := peer.FromContext(ctx); ok {
+		addr = pr.Addr.String()
+	}
+	realIP := grpcMetadataValue(md, strings.ToLower(xRealIPName))
+	forwarded := strings.Join(md.Get(strings.ToLower(forwardHeaderName)), ",")
+	return remoteIP(realIP, forwarded, addr, p.config().TrustedProxyHops)
+}
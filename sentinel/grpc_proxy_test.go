@@ -0,0 +1,213 @@
+package sentinel
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	testpb "google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// echoServer answers every call with the payload it was sent. A streamed
+// call gets a response per response parameter, and is kept open until the
+// client goes away when there are none.
+type echoServer struct {
+	testpb.UnimplementedTestServiceServer
+}
+
+func (echoServer) UnaryCall(_ context.Context, req *testpb.SimpleRequest) (*testpb.SimpleResponse, error) {
+	return &testpb.SimpleResponse{Payload: req.Payload}, nil
+}
+
+func (echoServer) StreamingOutputCall(req *testpb.StreamingOutputCallRequest, stream testpb.TestService_StreamingOutputCallServer) error {
+	if len(req.ResponseParameters) == 0 {
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{Payload: req.Payload}); err != nil {
+			return err
+		}
+		<-stream.Context().Done()
+		return stream.Context().Err()
+	}
+	for range req.ResponseParameters {
+		if err := stream.Send(&testpb.StreamingOutputCallResponse{Payload: req.Payload}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serveGRPC serves the server in memory and returns the dialer connecting to
+// it
+func serveGRPC(t *testing.T, server *grpc.Server) func(context.Context, string) (net.Conn, error) {
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}
+}
+
+func TestGRPCProxy(t *testing.T) {
+	defer func(interval time.Duration) { grpcExpiryInterval = interval }(grpcExpiryInterval)
+	grpcExpiryInterval = 10 * time.Millisecond
+
+	upstream := grpc.NewServer()
+	testpb.RegisterTestServiceServer(upstream, echoServer{})
+	upstreamDialer := serveGRPC(t, upstream)
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	service := "gaia-mainnet-grpc"
+	testConfig := newTestConfig()
+	testConfig.GRPCServices = []string{service}
+	proxy := NewProxy(testConfig)
+	proxy.grpcConns = newGRPCConns(grpc.WithContextDialer(upstreamDialer))
+	setServiceURL(proxy, service, common.MustParseURL("http://bufnet"))
+	proxy.MemStore.SetHeight(20)
+	server, err := proxy.NewGRPCServer()
+	require.NoError(t, err)
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(serveGRPC(t, server)), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := testpb.NewTestServiceClient(conn)
+
+	clientKey := secp256k1.GenPrivKey()
+	spender, err := common.NewPubKeyFromCrypto(clientKey.PubKey())
+	require.NoError(t, err)
+	ser, err := common.NewService(service)
+	require.NoError(t, err)
+	contract := newTestContract(testConfig.ProviderPubKey, ser, spender)
+	contract.Id = 1
+	proxy.MemStore.Put(contract)
+
+	withArkAuth := func(t *testing.T, key *secp256k1.PrivKey, nonce int64) context.Context {
+		sig, err := key.Sign(types.GetBytesToSign(contract.Id, nonce))
+		require.NoError(t, err)
+		return metadata.AppendToOutgoingContext(context.Background(), QueryArkAuth, fmt.Sprintf("%d:%d:%s", contract.Id, nonce, hex.EncodeToString(sig)))
+	}
+	payload := &testpb.Payload{Body: []byte("ping")}
+	unary := func(ctx context.Context) (metadata.MD, metadata.MD, error) {
+		var header, trailer metadata.MD
+		resp, err := client.UnaryCall(ctx, &testpb.SimpleRequest{Payload: payload}, grpc.Header(&header), grpc.Trailer(&trailer))
+		if err == nil && string(resp.Payload.GetBody()) != string(payload.Body) {
+			err = fmt.Errorf("echoed %q", resp.Payload.GetBody())
+		}
+		return header, trailer, err
+	}
+	paidNonce := func(t *testing.T) int64 {
+		nonce, err := proxy.paidNonce(contract.Id)
+		require.NoError(t, err)
+		return nonce
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		header, _, err := unary(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []string{"free"}, header.Get("tier"))
+
+		header, _, err = unary(withArkAuth(t, clientKey, 1))
+		require.NoError(t, err)
+		require.Equal(t, []string{"paid"}, header.Get("tier"))
+		require.Equal(t, []string{"1"}, header.Get(NonceHeader))
+
+		// each call is metered, a replayed nonce doesn't pay
+		header, _, err = unary(withArkAuth(t, clientKey, 1))
+		require.NoError(t, err)
+		require.Equal(t, []string{"free"}, header.Get("tier"))
+		require.Equal(t, int64(1), paidNonce(t))
+	})
+
+	t.Run("server streaming", func(t *testing.T) {
+		params := []*testpb.ResponseParameters{{Size: 4}, {Size: 4}, {Size: 4}}
+		stream, err := client.StreamingOutputCall(withArkAuth(t, clientKey, 2), &testpb.StreamingOutputCallRequest{ResponseParameters: params, Payload: payload})
+		require.NoError(t, err)
+		header, err := stream.Header()
+		require.NoError(t, err)
+		require.Equal(t, []string{"paid"}, header.Get("tier"))
+		for range params {
+			resp, err := stream.Recv()
+			require.NoError(t, err)
+			require.Equal(t, payload.Body, resp.Payload.Body)
+		}
+		_, err = stream.Recv()
+		require.Equal(t, io.EOF, err)
+		// a stream is paid once, when it is opened
+		require.Equal(t, int64(2), paidNonce(t))
+	})
+
+	t.Run("auth failure", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), QueryArkAuth, "1:abc")
+		_, trailer, err := unary(ctx)
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		require.Equal(t, []string{string(ErrCodeBadArkAuth)}, trailer.Get(GRPCErrorCodeKey))
+
+		ctx = metadata.AppendToOutgoingContext(context.Background(), ServiceHeader, "btc-mainnet-fullnode")
+		_, _, err = unary(ctx)
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+
+		config := testConfig
+		config.StrictAuth = true
+		proxy.Reload(config, proxy.config().proxies)
+		defer proxy.Reload(testConfig, proxy.config().proxies)
+		_, trailer, err = unary(withArkAuth(t, secp256k1.GenPrivKey(), 3))
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+		require.Equal(t, []string{string(ErrCodeBadSignature)}, trailer.Get(GRPCErrorCodeKey))
+		require.Equal(t, int64(2), paidNonce(t))
+	})
+
+	t.Run("upstream failure", func(t *testing.T) {
+		// a call the upstream fails isn't paid, its nonce can be used again
+		_, err := client.EmptyCall(withArkAuth(t, clientKey, 3), &testpb.Empty{})
+		require.Equal(t, codes.Unimplemented, status.Code(err))
+		require.Equal(t, int64(2), paidNonce(t))
+
+		header, _, err := unary(withArkAuth(t, clientKey, 3))
+		require.NoError(t, err)
+		require.Equal(t, []string{"paid"}, header.Get("tier"))
+		require.Equal(t, []string{"3"}, header.Get(NonceHeader))
+		require.Equal(t, int64(3), paidNonce(t))
+	})
+
+	t.Run("contract expiry", func(t *testing.T) {
+		stream, err := client.StreamingOutputCall(withArkAuth(t, clientKey, 4), &testpb.StreamingOutputCallRequest{Payload: payload})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		require.NoError(t, err)
+
+		proxy.MemStore.SetHeight(200)
+		_, err = stream.Recv()
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		require.True(t, strings.Contains(status.Convert(err).Message(), "expired"))
+		require.Equal(t, []string{string(ErrCodeContractExpired)}, stream.Trailer().Get(GRPCErrorCodeKey))
+	})
+}
+
+func TestParseGRPCArkAuth(t *testing.T) {
+	aa, err := parseGRPCArkAuth(metadata.MD{})
+	require.NoError(t, err)
+	require.Equal(t, ArkAuth{}, aa)
+
+	aa, err = parseGRPCArkAuth(metadata.Pairs(QueryArkAuth, "5:3:abcd"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), aa.ContractId)
+	require.Equal(t, int64(3), aa.Nonce)
+	require.Equal(t, []byte{0xab, 0xcd}, aa.Signature)
+
+	_, err = parseGRPCArkAuth(metadata.Pairs(QueryArkAuth, "5:three:abcd"))
+	require.ErrorIs(t, err, errArkAuthNonce)
+}
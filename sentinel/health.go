@@ -0,0 +1,84 @@
+package sentinel
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+)
+
+const upstreamDialTimeout = 2 * time.Second
+
+type (
+	ReadinessCheck = api.ReadinessCheck
+	Readiness      = api.Readiness
+)
+
+func newReadinessCheck(name string, err error) ReadinessCheck {
+	check := ReadinessCheck{Name: name, OK: err == nil}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
+
+// handleHealth reports the process is up (liveness)
+func (p Proxy) handleHealth(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, api.Health{Status: "ok"})
+}
+
+// handleReady reports whether sentinel is able to serve requests
+// (readiness), so it can be pulled from rotation when it loses sync
+func (p Proxy) handleReady(w http.ResponseWriter, r *http.Request) {
+	readiness := p.readiness()
+	code := http.StatusOK
+	if !readiness.Ready {
+		code = http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, code, readiness)
+}
+
+func (p Proxy) readiness() Readiness {
+	readiness := Readiness{
+		Height:      p.MemStore.GetHeight(),
+		MaxBlockLag: p.Config.ReadyMaxBlockLag,
+	}
+
+	var err error
+	readiness.ChainHeight, err = p.MemStore.FetchLatestHeight()
+	if err == nil && readiness.ChainHeight-readiness.Height > readiness.MaxBlockLag {
+		err = fmt.Errorf("%d blocks behind the chain", readiness.ChainHeight-readiness.Height)
+	}
+	readiness.Checks = append(readiness.Checks, newReadinessCheck("block_height", err))
+
+	for _, service := range p.Config.ReadyServices {
+		readiness.Checks = append(readiness.Checks, newReadinessCheck("upstream:"+service, p.pingUpstream(service)))
+	}
+
+	readiness.Checks = append(readiness.Checks, newReadinessCheck("claim_store", p.ClaimStore.Ping()))
+
+	readiness.Ready = true
+	for _, check := range readiness.Checks {
+		if !check.OK {
+			readiness.Ready = false
+		}
+	}
+	return readiness
+}
+
+// pingUpstream checks one of the upstreams of the given service accepts
+// connections
+func (p Proxy) pingUpstream(service string) error {
+	upstreams, ok := p.config().proxies[service]
+	if !ok || len(upstreams) == 0 {
+		return fmt.Errorf("could not find service")
+	}
+	var err error
+	for _, uri := range upstreams {
+		if err = dialUpstream(uri); err == nil {
+			return nil
+		}
+	}
+	return err
+}
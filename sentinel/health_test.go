@@ -0,0 +1,81 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleHealth(t *testing.T) {
+	proxy := NewProxy(newTestConfig())
+	router := proxy.getRouter()
+
+	req, err := http.NewRequest(http.MethodGet, RoutesHealth, nil)
+	require.NoError(t, err)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusOK, response.Code)
+}
+
+func TestHandleReady(t *testing.T) {
+	chain := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch {
+		case strings.HasSuffix(req.RequestURI, "/cosmos/base/tendermint/v1beta1/blocks/latest"):
+			httpTestHandler(t, rw, `{"block":{"header":{"height":"100"}}}`)
+		default:
+			panic(fmt.Sprintf("could not serve request: %s", req.RequestURI))
+		}
+	}))
+	defer chain.Close()
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+
+	testConfig := newTestConfig()
+	testConfig.SourceChain = chain.URL
+	testConfig.ReadyMaxBlockLag = 10
+	testConfig.ReadyServices = []string{common.BTCService.String()}
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	router := proxy.getRouter()
+
+	getReady := func() (int, Readiness) {
+		req, err := http.NewRequest(http.MethodGet, RoutesReady, nil)
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		var readiness Readiness
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &readiness))
+		return response.Code, readiness
+	}
+
+	// in sync with the chain
+	proxy.MemStore.SetHeight(95)
+	code, readiness := getReady()
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, readiness.Ready)
+	require.Equal(t, int64(100), readiness.ChainHeight)
+	require.Equal(t, int64(95), readiness.Height)
+	require.Len(t, readiness.Checks, 3)
+
+	// stale block height
+	proxy.MemStore.SetHeight(50)
+	code, readiness = getReady()
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.False(t, readiness.Ready)
+	require.Equal(t, "block_height", readiness.Checks[0].Name)
+	require.False(t, readiness.Checks[0].OK)
+
+	// upstream unreachable
+	proxy.MemStore.SetHeight(100)
+	upstream.Close()
+	code, readiness = getReady()
+	require.Equal(t, http.StatusServiceUnavailable, code)
+	require.True(t, readiness.Checks[0].OK)
+	require.False(t, readiness.Checks[1].OK)
+	require.True(t, readiness.Checks[2].OK)
+}
@@ -0,0 +1,68 @@
+package sentinel
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strconv"
+
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+)
+
+type ProviderIdentity = api.ProviderIdentity
+
+// IdentitySigner signs the challenges of the verify endpoint with the
+// provider key, and returns the pubkey it signed with
+type IdentitySigner interface {
+	Sign(msg []byte) ([]byte, cryptotypes.PubKey, error)
+}
+
+// handleVerify answers the challenge of a client with a signature of the
+// provider key, for the client to check the sentinel controls the key before
+// opening a contract with it. The height is signed along with the challenge.
+// Each address is rate limited, for the sentinel not to be used as a signing
+// oracle.
+func (p Proxy) handleVerify(w http.ResponseWriter, r *http.Request) {
+	if p.Identity == nil {
+		respondWithError(w, "provider identity signing is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	challenge := r.URL.Query().Get(api.ChallengeParam)
+	if err := api.ValidateChallenge(challenge); err != nil {
+		respondWithProxyError(w, http.StatusBadRequest, newProxyError(ErrCodeBadChallenge, "bad challenge: %w", err))
+		return
+	}
+	limited, _, retryAfter := p.rateLimitRemaining(0, "verify-"+p.getRemoteAddr(r), p.config().VerifyRateLimit)
+	if limited {
+		if retryAfter > 0 {
+			w.Header().Set(RetryAfterHeader, strconv.Itoa(retryAfterSeconds(retryAfter)))
+		}
+		respondWithProxyError(w, http.StatusTooManyRequests, newProxyError(ErrCodeVerifyRateLimited, "too many challenges, try again later"))
+		return
+	}
+
+	height := p.MemStore.GetHeight()
+	signature, pk, err := p.Identity.Sign(api.IdentityMessage(challenge, height))
+	if err != nil {
+		p.logger.Error("fail to sign challenge", "error", err)
+		respondWithProxyError(w, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "fail to sign challenge"))
+		return
+	}
+	// a claim key other than the provider key would sign a challenge the
+	// client can't verify
+	signer, err := common.NewPubKeyFromCrypto(pk)
+	if err != nil || !signer.Equals(p.Config.ProviderPubKey) {
+		p.logger.Error("claim key isn't the provider key", "error", err, "signer", signer)
+		respondWithProxyError(w, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "claim key isn't the provider key"))
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, ProviderIdentity{
+		Provider:  p.Config.ProviderPubKey,
+		Challenge: challenge,
+		Height:    height,
+		Signature: hex.EncodeToString(signature),
+	})
+}
@@ -0,0 +1,129 @@
+package sentinel
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	cKeys "github.com/cosmos/cosmos-sdk/crypto/keyring"
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	"github.com/cosmos/cosmos-sdk/std"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// keyringSigner signs with a key of the keyring, as the chain client does
+type keyringSigner struct {
+	kr   cKeys.Keyring
+	name string
+}
+
+func (s keyringSigner) Sign(msg []byte) ([]byte, cryptotypes.PubKey, error) {
+	return s.kr.Sign(s.name, msg)
+}
+
+func TestVerifyIdentity(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	kb := cKeys.NewInMemory(codec.NewProtoCodec(interfaceRegistry))
+	info, _, err := kb.NewMnemonic("provider", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+	pub, err := info.GetPubKey()
+	require.NoError(t, err)
+	_, _, err = kb.NewMnemonic("other", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+
+	testConfig := newTestConfig()
+	testConfig.ProviderPubKey, err = common.NewPubKeyFromCrypto(pub)
+	require.NoError(t, err)
+	testConfig.VerifyRateLimit = 3
+	proxy := NewProxy(testConfig)
+	proxy.MemStore.SetHeight(42)
+
+	verify := func(challenge string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, RoutesVerify+"?"+api.ChallengeParam+"="+challenge, nil)
+		response := httptest.NewRecorder()
+		proxy.getRouter().ServeHTTP(response, req)
+		return response
+	}
+	challenge, err := api.NewChallenge()
+	require.NoError(t, err)
+
+	// without a claim key, challenges can't be signed
+	require.Equal(t, http.StatusServiceUnavailable, verify(challenge).Code)
+	proxy.Identity = keyringSigner{kr: kb, name: "provider"}
+
+	response := verify(challenge)
+	require.Equal(t, http.StatusOK, response.Code)
+	var identity ProviderIdentity
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &identity))
+	require.Equal(t, testConfig.ProviderPubKey, identity.Provider)
+	require.Equal(t, int64(42), identity.Height)
+	require.NoError(t, identity.Verify(challenge, testConfig.ProviderPubKey))
+	sig, err := hex.DecodeString(identity.Signature)
+	require.NoError(t, err)
+	require.True(t, pub.VerifySignature([]byte(challenge+":42"), sig))
+
+	// the signature doesn't verify against another provider, nor for another
+	// challenge or height
+	require.Error(t, identity.Verify(challenge, types.GetRandomPubKey()))
+	require.Error(t, identity.Verify(strings.Repeat("a", 32), testConfig.ProviderPubKey))
+	identity.Height++
+	require.ErrorContains(t, identity.Verify(challenge, testConfig.ProviderPubKey), "invalid signature")
+
+	// malformed challenges are refused before being signed
+	for _, bad := range []string{
+		"",
+		"short",
+		strings.Repeat("a", api.MaxChallengeLength+1),
+		strings.Repeat("a", 20) + ":1",
+		strings.Repeat("a", 20) + "%20b",
+	} {
+		response := verify(bad)
+		require.Equal(t, http.StatusBadRequest, response.Code, bad)
+		var body ErrorResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+		require.Equal(t, ErrCodeBadChallenge, body.Code)
+	}
+
+	// a claim key other than the provider key isn't used
+	proxy.Identity = keyringSigner{kr: kb, name: "other"}
+	require.Equal(t, http.StatusInternalServerError, verify(challenge).Code)
+	proxy.Identity = keyringSigner{kr: kb, name: "provider"}
+
+	// the client helper verifies the sentinel against the on-chain pubkey
+	server := httptest.NewServer(proxy.getRouter())
+	defer server.Close()
+	client := api.NewClient(server.URL, api.WithRetries(0, 0))
+	identity, err = client.VerifyProvider(context.Background(), testConfig.ProviderPubKey)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), identity.Height)
+	_, err = client.VerifyProvider(context.Background(), types.GetRandomPubKey())
+	require.ErrorContains(t, err, "sentinel serves provider")
+
+	// each address is rate limited, the challenges refused as malformed
+	// aren't counted
+	require.Equal(t, http.StatusOK, verify(challenge).Code)
+	response = verify(challenge)
+	require.Equal(t, http.StatusTooManyRequests, response.Code)
+	require.NotEmpty(t, response.Header().Get(RetryAfterHeader))
+	_, err = client.VerifyProvider(context.Background(), testConfig.ProviderPubKey)
+	require.NoError(t, err)
+	_, err = client.VerifyProvider(context.Background(), testConfig.ProviderPubKey)
+	var apiErr *api.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, ErrCodeVerifyRateLimited, apiErr.Response.Code)
+}
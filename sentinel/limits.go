@@ -0,0 +1,197 @@
+package sentinel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+)
+
+var errResponseTooLarge = errors.New("upstream response too large")
+
+type contractIdKey struct{}
+
+// getContractId returns the id of the contract paying for the request, zero
+// for free tier requests
+func getContractId(ctx context.Context) uint64 {
+	id, _ := ctx.Value(contractIdKey{}).(uint64)
+	return id
+}
+
+// proxyLimits resolves the limits of a request, the service limits override
+// the global ones, and the contract limits can only lower them further as
+// they are set by the client. Free tier requests get the smaller request body
+// limit of the free tier of the service.
+func (p Proxy) proxyLimits(service string, contractId uint64) conf.ProxyLimits {
+	limits := p.Config.Limits.Merge(p.Config.ServiceLimits[service])
+	if contractId == 0 {
+		return limits.Tighten(conf.ProxyLimits{MaxRequestBodyBytes: p.config().FreeTier(service).MaxBodyBytes})
+	}
+	contractConf, err := p.ContractConfigStore.Get(contractId)
+	if err != nil {
+		p.logger.Error("failed to fetch contract configuration", "error", err, "contract_id", contractId)
+		return limits
+	}
+	return limits.Tighten(contractConf.Limits)
+}
+
+// inFlightRequests counts the paid requests of each contract being served, so
+// a contract can't hold more upstream connections than its limit
+type inFlightRequests struct {
+	lock   sync.Mutex
+	counts map[uint64]int
+}
+
+func newInFlightRequests() *inFlightRequests {
+	return &inFlightRequests{counts: make(map[uint64]int)}
+}
+
+// acquire counts a request of the contract, it returns false when the
+// contract already has max requests in flight. A zero max is unlimited.
+func (f *inFlightRequests) acquire(contractId uint64, max int) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if max > 0 && f.counts[contractId] >= max {
+		return false
+	}
+	f.counts[contractId]++
+	return true
+}
+
+// release uncounts a request of the contract once it completes
+func (f *inFlightRequests) release(contractId uint64) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.counts[contractId] <= 1 {
+		delete(f.counts, contractId)
+		return
+	}
+	f.counts[contractId]--
+}
+
+// count returns the requests of the contract in flight
+func (f *inFlightRequests) count(contractId uint64) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.counts[contractId]
+}
+
+// acquireInFlight counts a paid request of the contract against its max
+// concurrent requests. The returned func must be called once the request
+// completes, it is nil when the contract has too many requests in flight.
+func (p Proxy) acquireInFlight(service string, contractId uint64) (func(), error) {
+	max := p.proxyLimits(service, contractId).MaxConcurrentRequests
+	if !p.inFlight.acquire(contractId, max) {
+		return nil, newProxyError(ErrCodeTooManyInFlight, "contract %d has too many requests in flight (%d)", contractId, max)
+	}
+	return func() { p.inFlight.release(contractId) }, nil
+}
+
+// maxRequestBodyBytes returns the largest request body any service accepts,
+// zero when unlimited
+func (p Proxy) maxRequestBodyBytes() int64 {
+	max := p.Config.Limits.MaxRequestBodyBytes
+	if max <= 0 {
+		return 0
+	}
+	for _, limits := range p.Config.ServiceLimits {
+		if limits.MaxRequestBodyBytes > max {
+			max = limits.MaxRequestBodyBytes
+		}
+	}
+	return max
+}
+
+// limitRequestBody reads the request body up front, so an oversized body is
+// rejected before reaching the upstream service. It returns false when the
+// request was rejected.
+func (p Proxy) limitRequestBody(w http.ResponseWriter, r *http.Request, max int64) bool {
+	if max <= 0 || r.Body == nil {
+		return true
+	}
+	if r.ContentLength > max {
+		respondWithError(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, max))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondWithError(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		p.logger.Error("fail to read request body", "error", err)
+		respondWithError(w, "fail to read request body", http.StatusBadRequest)
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return true
+}
+
+// limitProxy maps upstream failures to a status code, and truncates upstream
+// responses larger than the max response size. The returned func reports
+// whether the response was truncated.
+func (p Proxy) limitProxy(proxy *httputil.ReverseProxy, limits conf.ProxyLimits, contractId uint64) func() bool {
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			p.logger.Error("upstream timeout", "error", err, "contract_id", contractId, "timeout", limits.UpstreamTimeoutSec)
+			respondWithError(w, "upstream timeout", http.StatusGatewayTimeout)
+			return
+		}
+		p.logger.Error("upstream error", "error", err, "contract_id", contractId)
+		respondWithError(w, "bad gateway", http.StatusBadGateway)
+	}
+
+	if limits.MaxResponseBytes <= 0 {
+		return func() bool { return false }
+	}
+	body := &limitedBody{
+		max: limits.MaxResponseBytes,
+		onExceeded: func() {
+			// the reverse proxy closes the connection on read errors, the
+			// client only gets the truncated response
+			p.logger.Error("upstream response too large, truncated", "contract_id", contractId, "limit", limits.MaxResponseBytes)
+		},
+	}
+	proxy.ModifyResponse = func(res *http.Response) error {
+		body.ReadCloser = res.Body
+		res.Body = body
+		return nil
+	}
+	return func() bool { return body.exceeded }
+}
+
+// limitedBody fails once more than max bytes are read from the upstream
+// response
+type limitedBody struct {
+	io.ReadCloser
+	max        int64
+	read       int64
+	exceeded   bool
+	onExceeded func()
+}
+
+func (l *limitedBody) Read(b []byte) (int, error) {
+	if l.exceeded {
+		return 0, errResponseTooLarge
+	}
+	// read one extra byte, to detect responses over the limit
+	if remaining := l.max - l.read + 1; int64(len(b)) > remaining {
+		b = b[:remaining]
+	}
+	n, err := l.ReadCloser.Read(b)
+	if l.read+int64(n) > l.max {
+		n = int(l.max - l.read)
+		l.read = l.max
+		l.exceeded = true
+		l.onExceeded()
+		return n, errResponseTooLarge
+	}
+	l.read += int64(n)
+	return n, err
+}
@@ -0,0 +1,304 @@
+package sentinel
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func newLimitsTestProxy(limits conf.ProxyLimits, upstream *httptest.Server) Proxy {
+	testConfig := newTestConfig()
+	testConfig.Limits = limits
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Id = 5
+	proxy.MemStore.Put(contract)
+	return proxy
+}
+
+func TestProxyLimits(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	proxy := newLimitsTestProxy(conf.ProxyLimits{MaxRequestBodyBytes: 100, MaxResponseBytes: 1000, UpstreamTimeoutSec: 60}, upstream)
+	proxy.Config.ServiceLimits = map[string]conf.ProxyLimits{
+		common.BTCService.String(): {MaxRequestBodyBytes: 200},
+	}
+	require.Equal(t, conf.ProxyLimits{MaxRequestBodyBytes: 100, MaxResponseBytes: 1000, UpstreamTimeoutSec: 60}, proxy.proxyLimits(common.ETHService.String(), 0))
+	require.Equal(t, conf.ProxyLimits{MaxRequestBodyBytes: 200, MaxResponseBytes: 1000, UpstreamTimeoutSec: 60}, proxy.proxyLimits(common.BTCService.String(), 0))
+	require.Equal(t, int64(200), proxy.maxRequestBodyBytes())
+
+	// contracts can lower the limits, but not raise them
+	contractConf := NewContractConfiguration(5, NewCORs(), nil, 0)
+	contractConf.Limits = ContractLimits{MaxRequestBodyBytes: 50, MaxResponseBytes: 5000, UpstreamTimeoutSec: 10}
+	require.NoError(t, proxy.ContractConfigStore.Set(contractConf))
+	require.Equal(t, conf.ProxyLimits{MaxRequestBodyBytes: 50, MaxResponseBytes: 1000, UpstreamTimeoutSec: 10}, proxy.proxyLimits(common.BTCService.String(), 5))
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	var received int
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		received = len(body)
+	}))
+	defer upstream.Close()
+
+	proxy := newLimitsTestProxy(conf.ProxyLimits{MaxRequestBodyBytes: 10}, upstream)
+	router := proxy.getRouter()
+
+	serve := func(nonce int64, body io.Reader) int {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=5:%d", nonce), body)
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response.Code
+	}
+
+	// at the limit
+	require.Equal(t, http.StatusOK, serve(1, strings.NewReader(strings.Repeat("a", 10))))
+	require.Equal(t, 10, received)
+
+	// over the limit, known content length
+	received = 0
+	require.Equal(t, http.StatusRequestEntityTooLarge, serve(2, strings.NewReader(strings.Repeat("a", 11))))
+	require.Zero(t, received)
+
+	// over the limit, chunked body of unknown length
+	require.Equal(t, http.StatusRequestEntityTooLarge, serve(3, io.NopCloser(strings.NewReader(strings.Repeat("a", 11)))))
+	require.Zero(t, received)
+
+	// lowered by the contract configuration
+	contractConf := NewContractConfiguration(5, NewCORs(), nil, 0)
+	contractConf.Limits = ContractLimits{MaxRequestBodyBytes: 5}
+	require.NoError(t, proxy.ContractConfigStore.Set(contractConf))
+	require.Equal(t, http.StatusOK, serve(4, strings.NewReader(strings.Repeat("a", 5))))
+	require.Equal(t, http.StatusRequestEntityTooLarge, serve(5, strings.NewReader(strings.Repeat("a", 6))))
+}
+
+func TestRequestBodyLimitTiers(t *testing.T) {
+	var received int
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		received = len(body)
+	}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	proxy := newLimitsTestProxy(conf.ProxyLimits{MaxRequestBodyBytes: 100}, upstream)
+	testConfig := proxy.config().Configuration
+	testConfig.FreeTierMaxBodyBytes = 10
+	proxy.Reload(testConfig, proxy.config().proxies)
+	router := proxy.getRouter()
+
+	serve := func(path string, size int) *httptest.ResponseRecorder {
+		received = 0
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(strings.Repeat("a", size)))
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// the free tier accepts small bodies only
+	response := serve("/btc-mainnet-fullnode/", 10)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "free", response.Header().Get("tier"))
+	require.Equal(t, 10, received)
+	response = serve("/btc-mainnet-fullnode/", 11)
+	require.Equal(t, http.StatusRequestEntityTooLarge, response.Code)
+	require.Zero(t, received)
+
+	// the paid tier accepts larger bodies
+	response = serve("/btc-mainnet-fullnode/?arkauth=5:1", 100)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+	require.Equal(t, 100, received)
+	response = serve("/btc-mainnet-fullnode/?arkauth=5:2", 101)
+	require.Equal(t, http.StatusRequestEntityTooLarge, response.Code)
+	require.Zero(t, received)
+
+	// the nonce of the refused request isn't consumed
+	nonce, err := proxy.paidNonce(5)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), nonce)
+	response = serve("/btc-mainnet-fullnode/?arkauth=5:2", 50)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+}
+
+func TestUpstreamTimeout(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("slow") == "true" {
+			select {
+			case <-time.After(3 * time.Second):
+			case <-req.Context().Done():
+			}
+		}
+	}))
+	defer upstream.Close()
+
+	proxy := newLimitsTestProxy(conf.ProxyLimits{UpstreamTimeoutSec: 1}, upstream)
+	router := proxy.getRouter()
+
+	serve := func(nonce int64, slow bool) int {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=5:%d&slow=%t", nonce, slow), nil)
+		require.NoError(t, err)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response.Code
+	}
+
+	require.Equal(t, http.StatusOK, serve(1, false))
+	require.Equal(t, http.StatusGatewayTimeout, serve(2, true))
+}
+
+func TestResponseLimit(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		size, _ := strconv.Atoi(req.URL.Query().Get("size"))
+		_, _ = rw.Write([]byte(strings.Repeat("a", size)))
+	}))
+	defer upstream.Close()
+
+	proxy := newLimitsTestProxy(conf.ProxyLimits{MaxResponseBytes: 10}, upstream)
+	server := httptest.NewServer(proxy.getRouter())
+	defer server.Close()
+
+	// post requests aren't retried by the client when the connection closes
+	post := func(nonce int64, size int) ([]byte, error) {
+		res, err := http.Post(fmt.Sprintf("%s/btc-mainnet-fullnode/?arkauth=5:%d&size=%d", server.URL, nonce, size), "application/json", nil)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		return io.ReadAll(res.Body)
+	}
+
+	// at the limit
+	body, err := post(1, 10)
+	require.NoError(t, err)
+	require.Len(t, body, 10)
+
+	// over the limit, the response is truncated and the connection closed
+	body, err = post(2, 11)
+	require.Error(t, err)
+	require.LessOrEqual(t, len(body), 10)
+
+	// large responses are cut once already streaming to the client
+	body, err = post(3, 1024*1024)
+	require.Error(t, err)
+	require.LessOrEqual(t, len(body), 10)
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	var inFlight, maxInFlight int32
+	entered := make(chan struct{}, 10)
+	unblock := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		if req.URL.Query().Get("block") == "true" {
+			entered <- struct{}{}
+			<-unblock
+		}
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer upstream.Close()
+
+	// the contract lowers the limit of the provider
+	proxy := newLimitsTestProxy(conf.ProxyLimits{MaxConcurrentRequests: 3}, upstream)
+	contractConf := NewContractConfiguration(5, NewCORs(), nil, 0)
+	contractConf.Limits = ContractLimits{MaxConcurrentRequests: 2}
+	require.NoError(t, proxy.ContractConfigStore.Set(contractConf))
+	require.Equal(t, 2, proxy.proxyLimits(common.BTCService.String(), 5).MaxConcurrentRequests)
+	// concurrent requests reach the sentinel in any order
+	config := proxy.config().Configuration
+	config.NonceWindow = 100
+	proxy.Reload(config, proxy.config().proxies)
+	router := proxy.getRouter()
+
+	serve := func(nonce int64, block bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=5:%d&block=%t", nonce, block), nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// two requests held by the upstream
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = serve(int64(i+1), true).Code
+		}(i)
+	}
+	<-entered
+	<-entered
+	require.Equal(t, 2, proxy.inFlight.count(5))
+
+	// a third is refused, without consuming its nonce
+	refused := serve(3, false)
+	require.Equal(t, http.StatusTooManyRequests, refused.Code)
+	require.Contains(t, refused.Body.String(), string(ErrCodeTooManyInFlight))
+
+	close(unblock)
+	wg.Wait()
+	require.Equal(t, []int{http.StatusOK, http.StatusOK}, codes)
+	require.Zero(t, proxy.inFlight.count(5))
+	require.Equal(t, http.StatusOK, serve(3, false).Code)
+
+	// the limit holds under a burst of requests
+	atomic.StoreInt32(&maxInFlight, 0)
+	var served, limited int32
+	for nonce := int64(4); nonce < 24; nonce++ {
+		wg.Add(1)
+		go func(nonce int64) {
+			defer wg.Done()
+			switch serve(nonce, false).Code {
+			case http.StatusOK:
+				atomic.AddInt32(&served, 1)
+			case http.StatusTooManyRequests:
+				atomic.AddInt32(&limited, 1)
+			}
+		}(nonce)
+	}
+	wg.Wait()
+	require.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+	require.Positive(t, served)
+	require.Equal(t, int32(20), served+limited)
+	require.Zero(t, proxy.inFlight.count(5))
+
+	// released when the handler panics
+	panicking := proxy.auth(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		panic("handler failure")
+	}))
+	require.Panics(t, func() {
+		req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/?arkauth=5:30", nil)
+		panicking.ServeHTTP(httptest.NewRecorder(), req)
+	})
+	require.Zero(t, proxy.inFlight.count(5))
+}
@@ -1,10 +1,12 @@
 package sentinel
 
 import (
+	"container/list"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -14,26 +16,48 @@ import (
 
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 )
 
 var ModuleBasics = module.NewBasicManager()
 
-// TODO: this should receive events from arceo chain to update its database
-// TODO: clean up contracts from memory after they expire
+type MemStoreStatus = api.MemStoreStatus
+
+// MemStore holds the contracts of the provider in memory. Contracts past their
+// settlement period are evicted as the height advances, and once over its max
+// entries the least recently used expired contracts are evicted. A contract
+// with unclaimed income is pinned, it is never evicted.
 type MemStore struct {
 	storeLock   *sync.Mutex
 	db          map[string]types.Contract
+	queries     map[uint64]blockQueries
+	rotated     map[uint64][]common.PubKey
+	lru         *list.List               // keys of the contracts, most recently used first
+	elements    map[string]*list.Element // element of each key in the lru list
+	maxEntries  int                      // zero is unbounded
+	pinned      func(contractId uint64) bool
+	evicted     evictionCounters
 	client      http.Client
 	baseURL     string
 	blockHeight int64
 	logger      log.Logger
 }
 
+// evictionCounters counts the contracts evicted from memory, by reason
+type evictionCounters struct {
+	expired  uint64 // past their settlement period
+	capacity uint64 // least recently used, over the max entries
+}
+
 func NewMemStore(baseURL string, logger log.Logger) *MemStore {
 	return &MemStore{
 		storeLock: &sync.Mutex{},
 		db:        make(map[string]types.Contract),
+		queries:   make(map[uint64]blockQueries),
+		rotated:   make(map[uint64][]common.PubKey),
+		lru:       list.New(),
+		elements:  make(map[string]*list.Element),
 		client: http.Client{
 			Timeout: 10 * time.Second,
 		},
@@ -42,6 +66,97 @@ func NewMemStore(baseURL string, logger log.Logger) *MemStore {
 	}
 }
 
+// SetMaxEntries bounds the number of contracts held, zero is unbounded. Only
+// expired contracts are evicted to stay under it, so it may be exceeded while
+// more contracts are open.
+func (k *MemStore) SetMaxEntries(maxEntries int) {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	k.maxEntries = maxEntries
+	k.evictOverCapacity()
+}
+
+// SetPinned sets the function telling whether a contract has unclaimed
+// income, such contracts are never evicted
+func (k *MemStore) SetPinned(pinned func(contractId uint64) bool) {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	k.pinned = pinned
+}
+
+// Status returns the number of contracts held and evicted
+func (k *MemStore) Status() MemStoreStatus {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	return MemStoreStatus{
+		Entries:         len(k.db),
+		EvictedExpired:  k.evicted.expired,
+		EvictedCapacity: k.evicted.capacity,
+		MaxEntries:      k.maxEntries,
+	}
+}
+
+// store holds the contract as the most recently used one
+func (k *MemStore) store(key string, contract types.Contract) {
+	k.db[key] = contract
+	k.touch(key)
+}
+
+// touch marks the contract as the most recently used one
+func (k *MemStore) touch(key string) {
+	if element, ok := k.elements[key]; ok {
+		k.lru.MoveToFront(element)
+		return
+	}
+	k.elements[key] = k.lru.PushFront(key)
+}
+
+// remove drops the contract, and what is tracked of it
+func (k *MemStore) remove(key string) {
+	if contract, ok := k.db[key]; ok {
+		delete(k.queries, contract.Id)
+		delete(k.rotated, contract.Id)
+	}
+	delete(k.db, key)
+	if element, ok := k.elements[key]; ok {
+		k.lru.Remove(element)
+		delete(k.elements, key)
+	}
+}
+
+func (k *MemStore) isPinned(contract types.Contract) bool {
+	return k.pinned != nil && k.pinned(contract.Id)
+}
+
+// evictSettled evicts the contracts past their settlement period, they can't
+// be used nor claimed anymore
+func (k *MemStore) evictSettled() {
+	for key, contract := range k.db {
+		if k.blockHeight <= contract.SettlementPeriodEnd() || k.isPinned(contract) {
+			continue
+		}
+		k.remove(key)
+		k.evicted.expired++
+	}
+}
+
+// evictOverCapacity evicts the least recently used expired contracts, until
+// the store is back under its max entries
+func (k *MemStore) evictOverCapacity() {
+	if k.maxEntries <= 0 {
+		return
+	}
+	for element := k.lru.Back(); element != nil && len(k.db) > k.maxEntries; {
+		prev := element.Prev()
+		key := element.Value.(string)
+		if contract := k.db[key]; contract.IsExpired(k.blockHeight) && !k.isPinned(contract) {
+			k.remove(key)
+			k.evicted.capacity++
+		}
+		element = prev
+	}
+}
+
 func (k *MemStore) Key(pubkey, service, spender string) string {
 	return fmt.Sprintf("%s/%s/%s", pubkey, service, spender)
 }
@@ -50,8 +165,13 @@ func (k *MemStore) GetHeight() int64 {
 	return k.blockHeight
 }
 
+// SetHeight sets the current height, and evicts the contracts past their
+// settlement period
 func (k *MemStore) SetHeight(height int64) {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
 	k.blockHeight = height
+	k.evictSettled()
 }
 
 func (k *MemStore) Get(key string) (types.Contract, error) {
@@ -65,23 +185,147 @@ func (k *MemStore) Get(key string) (types.Contract, error) {
 			return crtUpStream, err
 		}
 		if !crtUpStream.IsExpired(k.blockHeight) {
-			k.db[key] = crtUpStream
+			k.store(key, crtUpStream)
+			k.evictOverCapacity()
 		}
 		return crtUpStream, nil
 	}
 	// contract still valid
+	k.touch(key)
 	return contract, nil
 }
 
+// Peek returns the contract if it is held in memory, without fetching it
+func (k *MemStore) Peek(key string) (types.Contract, bool) {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	contract, ok := k.db[key]
+	return contract, ok
+}
+
 func (k *MemStore) Put(contract types.Contract) {
 	k.storeLock.Lock()
 	defer k.storeLock.Unlock()
 	key := contract.Key()
 	if contract.IsExpired(k.blockHeight) {
-		delete(k.db, key)
+		k.remove(key)
 		return
 	}
-	k.db[key] = contract
+	k.store(key, contract)
+	k.evictOverCapacity()
+}
+
+// List returns the contracts held in memory, ordered by id
+func (k *MemStore) List() []types.Contract {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	contracts := make([]types.Contract, 0, len(k.db))
+	for _, contract := range k.db {
+		contracts = append(contracts, contract)
+	}
+	sort.Slice(contracts, func(i, j int) bool { return contracts[i].Id < contracts[j].Id })
+	return contracts
+}
+
+// Evict removes the contract from memory, it is fetched again from the chain
+// when next used. Returns whether it was held.
+func (k *MemStore) Evict(key string) bool {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	_, ok := k.db[key]
+	k.remove(key)
+	return ok
+}
+
+// AddRotatedDelegate records a spender rotated out of the contract, its
+// signatures are no longer accepted by the chain
+func (k *MemStore) AddRotatedDelegate(contractId uint64, delegate common.PubKey) {
+	if delegate.IsEmpty() {
+		return
+	}
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	k.rotated[contractId] = append(k.rotated[contractId], delegate)
+}
+
+// RotatedDelegates returns the spenders rotated out of the contract
+func (k *MemStore) RotatedDelegates(contractId uint64) []common.PubKey {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	return append([]common.PubKey(nil), k.rotated[contractId]...)
+}
+
+// Reconcile replaces the contracts of the provider held in memory with the
+// given ones, the contracts open on chain at the given height, and fast
+// forwards the height. Pinned contracts are kept. It returns the ids of the contracts added and dropped.
+func (k *MemStore) Reconcile(provider common.PubKey, contracts []types.Contract, height int64) (added, dropped []uint64) {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	if height > k.blockHeight {
+		k.blockHeight = height
+	}
+
+	open := make(map[string]types.Contract, len(contracts))
+	for _, contract := range contracts {
+		if !contract.Provider.Equals(provider) || contract.IsExpired(k.blockHeight) {
+			continue
+		}
+		open[contract.Key()] = contract
+	}
+	for key, contract := range k.db {
+		// a contract with unclaimed income is kept until claimed
+		if _, ok := open[key]; ok || !contract.Provider.Equals(provider) || k.isPinned(contract) {
+			continue
+		}
+		k.remove(key)
+		dropped = append(dropped, contract.Id)
+	}
+	for key, contract := range open {
+		if _, ok := k.db[key]; !ok {
+			added = append(added, contract.Id)
+		}
+		k.store(key, contract)
+	}
+	k.evictOverCapacity()
+	sort.Slice(added, func(i, j int) bool { return added[i] < added[j] })
+	sort.Slice(dropped, func(i, j int) bool { return dropped[i] < dropped[j] })
+	return added, dropped
+}
+
+// blockQueries counts the queries served for a contract at a block height
+type blockQueries struct {
+	height int64
+	count  int64
+}
+
+// MeterBlockQuery counts a query of the contract at the current height, unless
+// the contract already reached the given quota for the block (zero is
+// unlimited). Counters reset when the height advances.
+func (k *MemStore) MeterBlockQuery(contractId uint64, quota int64) bool {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	queries := k.queries[contractId]
+	if queries.height != k.blockHeight {
+		queries = blockQueries{height: k.blockHeight}
+	}
+	if quota > 0 && queries.count >= quota {
+		return false
+	}
+	queries.count++
+	k.queries[contractId] = queries
+	return true
+}
+
+// GetBlockQueries returns the number of queries of the contract served at the
+// current height
+func (k *MemStore) GetBlockQueries(contractId uint64) int64 {
+	k.storeLock.Lock()
+	defer k.storeLock.Unlock()
+	queries := k.queries[contractId]
+	if queries.height != k.blockHeight {
+		return 0
+	}
+	return queries.count
 }
 
 func (k *MemStore) GetActiveContract(provider common.PubKey, service common.Service, spender common.PubKey) (types.Contract, error) {
@@ -98,6 +342,33 @@ func (k *MemStore) GetActiveContract(provider common.PubKey, service common.Serv
 	return types.Contract{}, fmt.Errorf("contract not found")
 }
 
+// FetchLatestHeight returns the height of the latest block of the chain
+func (k *MemStore) FetchLatestHeight() (int64, error) {
+	type fetch struct {
+		Block struct {
+			Header struct {
+				Height string `json:"height"`
+			} `json:"header"`
+		} `json:"block"`
+	}
+
+	requestURL := fmt.Sprintf("%s/cosmos/base/tendermint/v1beta1/blocks/latest", k.baseURL)
+	res, err := k.client.Get(requestURL)
+	if err != nil {
+		return 0, fmt.Errorf("fail to send http request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	var data fetch
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return 0, fmt.Errorf("fail to unmarshal response: %w", err)
+	}
+	return strconv.ParseInt(data.Block.Header.Height, 10, 64)
+}
+
 func (k *MemStore) fetchContract(key string) (types.Contract, error) {
 	// TODO: this should cache a "miss" for 5 seconds, to stop DoS/thrashing
 	var contract types.Contract
@@ -118,6 +389,8 @@ func (k *MemStore) fetchContract(key string) (types.Contract, error) {
 		SettlementHeight string                      `protobuf:"varint,12,opt,name=settlement_height,json=settlementHeight,proto3" json:"settlement_height,omitempty"`
 		Authorization    types.ContractAuthorization `protobuf:"varint,15,opt,name=authorization,proto3,enum=arkeo.arkeo.ContractAuthorization" json:"authorization,omitempty"`
 		QueriesPerMinute string                      `protobuf:"varint,16,opt,name=queries_per_minute,json=queriesPerMinute,proto3" json:"queries_per_minute,omitempty"`
+		Services         []common.Service            `protobuf:"varint,17,rep,packed,name=services,proto3,casttype=github.com/arkeonetwork/arkeo/common.Service" json:"services,omitempty"`
+		BundleId         string                      `protobuf:"varint,18,opt,name=bundle_id,json=bundleId,proto3" json:"bundle_id,omitempty"`
 	}
 
 	type fetch struct {
@@ -168,6 +441,8 @@ func (k *MemStore) fetchContract(key string) (types.Contract, error) {
 	contract.SettlementHeight, _ = strconv.ParseInt(data.Contract.SettlementHeight, 10, 64)
 	contract.Authorization = data.Contract.Authorization
 	contract.QueriesPerMinute, _ = strconv.ParseInt(data.Contract.QueriesPerMinute, 10, 64)
+	contract.Services = data.Contract.Services
+	contract.BundleId, _ = strconv.ParseUint(data.Contract.BundleId, 10, 64)
 
 	return contract, nil
 }
@@ -92,3 +92,85 @@ func (s *MemStoreSuite) TestMemStore() {
 	require.Equal(s.T(), contract.Deposit.Int64(), int64(500))
 	require.Equal(s.T(), contract.Paid.Int64(), int64(0))
 }
+
+func TestMemStoreEviction(t *testing.T) {
+	mem := NewMemStore("http://localhost:1317", log.NewNopLogger())
+	unclaimed := map[uint64]bool{3: true, 50: true}
+	mem.SetPinned(func(contractId uint64) bool { return unclaimed[contractId] })
+
+	// a contract is opened every block, it expires 10 blocks later and its
+	// settlement period ends 5 blocks after that
+	provider := types.GetRandomPubKey()
+	for i := int64(1); i <= 100; i++ {
+		mem.SetHeight(i)
+		contract := types.NewContract(provider, common.BTCService, types.GetRandomPubKey())
+		contract.Type = types.ContractType_PAY_AS_YOU_GO
+		contract.Height = i
+		contract.Duration = 10
+		contract.SettlementDuration = 5
+		contract.Id = uint64(i)
+		mem.Put(contract)
+	}
+
+	// only the contracts in their settlement period are held, and the ones
+	// with unclaimed income
+	require.Equal(t, MemStoreStatus{Entries: 18, EvictedExpired: 82}, mem.Status())
+	for _, id := range []string{"3", "50", "85", "100"} {
+		_, ok := mem.Peek(id)
+		require.True(t, ok, id)
+	}
+	_, ok := mem.Peek("84")
+	require.False(t, ok)
+
+	// a claimed contract is evicted on the next block
+	unclaimed[3] = false
+	mem.SetHeight(101)
+	_, ok = mem.Peek("3")
+	require.False(t, ok)
+	require.Equal(t, MemStoreStatus{Entries: 16, EvictedExpired: 84}, mem.Status())
+
+	// over the max entries, the least recently used expired contracts are
+	// evicted (86 to 89), never the open or pinned ones
+	mem.SetMaxEntries(12)
+	require.Equal(t, MemStoreStatus{Entries: 12, MaxEntries: 12, EvictedExpired: 84, EvictedCapacity: 4}, mem.Status())
+	for _, id := range []string{"50", "90", "91"} {
+		_, ok := mem.Peek(id)
+		require.True(t, ok, id)
+	}
+	_, ok = mem.Peek("89")
+	require.False(t, ok)
+
+	// the max entries are exceeded rather than evicting open contracts
+	mem.SetMaxEntries(5)
+	require.Equal(t, MemStoreStatus{Entries: 11, MaxEntries: 5, EvictedExpired: 84, EvictedCapacity: 5}, mem.Status())
+	_, ok = mem.Peek("50")
+	require.True(t, ok)
+	require.Len(t, mem.List(), 11)
+}
+
+func TestMemStorePinnedClaims(t *testing.T) {
+	proxy := NewProxy(newTestConfig())
+	contract := types.NewContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Height = 10
+	contract.Duration = 100
+	contract.Id = 9
+	proxy.MemStore.SetHeight(20)
+	proxy.MemStore.Put(contract)
+	require.NoError(t, proxy.ClaimStore.Set(NewClaim(contract.Id, contract.Client, 4, "sig")))
+
+	// the contract is held past its settlement period until claimed
+	proxy.MemStore.SetHeight(200)
+	_, ok := proxy.MemStore.Peek(contract.Key())
+	require.True(t, ok)
+	require.Equal(t, 1, proxy.MemStore.Status().Entries)
+
+	claim, err := proxy.ClaimStore.Get(contract.Key())
+	require.NoError(t, err)
+	claim.Claimed = true
+	require.NoError(t, proxy.ClaimStore.Set(claim))
+	proxy.MemStore.SetHeight(201)
+	_, ok = proxy.MemStore.Peek(contract.Key())
+	require.False(t, ok)
+	require.Equal(t, uint64(1), proxy.MemStore.Status().EvictedExpired)
+}
@@ -1,15 +1,13 @@
 package sentinel
 
 import (
+	"github.com/arkeonetwork/arkeo/sentinel/api"
 	"github.com/arkeonetwork/arkeo/sentinel/conf"
 )
 
 var Version = "0.0.0"
 
-type Metadata struct {
-	Configuration conf.Configuration `json:"config"`
-	Version       string             `json:"version"`
-}
+type Metadata = api.Metadata
 
 func NewMetadata(config conf.Configuration) Metadata {
 	return Metadata{
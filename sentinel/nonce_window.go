@@ -0,0 +1,87 @@
+package sentinel
+
+import (
+	"strconv"
+)
+
+// prefix of the keys of the seen nonces in the claim store
+const seenNoncesKeyPrefix = "nonces/"
+
+// SeenNonces records which nonces of a contract were used, when the nonces are
+// accepted out of order within a window below the highest one. The nonces are
+// tracked in a ring of bits indexed by nonce, covering at least the window.
+// Nonces below the ring are all considered used.
+type SeenNonces struct {
+	ContractId uint64   `json:"contract_id"`
+	Highest    int64    `json:"highest"`
+	Bits       []uint64 `json:"bits"`
+}
+
+// newSeenNonces returns the seen nonces of a contract for the given window,
+// with all the nonces up to the highest one used
+func newSeenNonces(contractId uint64, highest, window int64) SeenNonces {
+	seen := SeenNonces{
+		ContractId: contractId,
+		Highest:    highest,
+		Bits:       make([]uint64, seenNoncesWords(window)),
+	}
+	for i := range seen.Bits {
+		seen.Bits[i] = ^uint64(0)
+	}
+	return seen
+}
+
+// seenNoncesWords returns the number of words of a ring covering the window
+// and the highest nonce
+func seenNoncesWords(window int64) int {
+	return int((window + 64) / 64)
+}
+
+func (s SeenNonces) Key() string {
+	return seenNoncesKeyPrefix + strconv.FormatUint(s.ContractId, 10)
+}
+
+func (s SeenNonces) size() int64 {
+	return int64(len(s.Bits)) * 64
+}
+
+// Has tells whether the nonce was used
+func (s SeenNonces) Has(nonce int64) bool {
+	if nonce > s.Highest {
+		return false
+	}
+	if nonce <= s.Highest-s.size() {
+		return true
+	}
+	slot := nonce % s.size()
+	return s.Bits[slot/64]&(1<<(slot%64)) != 0
+}
+
+// Add marks the nonce as used, the nonces skipped when the highest nonce
+// increases are left unused
+func (s *SeenNonces) Add(nonce int64) {
+	size := s.size()
+	if nonce <= s.Highest-size {
+		return
+	}
+	if nonce > s.Highest {
+		from := s.Highest + 1
+		if nonce-s.Highest > size {
+			from = nonce - size + 1
+		}
+		for n := from; n <= nonce; n++ {
+			s.set(n, false)
+		}
+		s.Highest = nonce
+	}
+	s.set(nonce, true)
+}
+
+func (s *SeenNonces) set(nonce int64, used bool) {
+	slot := nonce % s.size()
+	if used {
+		s.Bits[slot/64] |= 1 << (slot % 64)
+	} else {
+		s.Bits[slot/64] &^= 1 << (slot % 64)
+	}
+}
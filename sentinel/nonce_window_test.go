@@ -0,0 +1,169 @@
+package sentinel
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+)
+
+func TestSeenNonces(t *testing.T) {
+	seen := newSeenNonces(1, 10, 100)
+	require.Len(t, seen.Bits, 2)
+	require.True(t, seen.Has(1))
+	require.True(t, seen.Has(10))
+	require.False(t, seen.Has(11))
+
+	// the nonces skipped by a higher nonce are left unused
+	seen.Add(15)
+	require.Equal(t, int64(15), seen.Highest)
+	require.True(t, seen.Has(15))
+	require.True(t, seen.Has(10))
+	for nonce := int64(11); nonce < 15; nonce++ {
+		require.False(t, seen.Has(nonce))
+	}
+	seen.Add(12)
+	require.True(t, seen.Has(12))
+	require.False(t, seen.Has(13))
+	require.Equal(t, int64(15), seen.Highest)
+
+	// nonces below the ring are all used, a jump clears the whole ring
+	seen.Add(1000)
+	require.True(t, seen.Has(1000-seen.size()))
+	require.True(t, seen.Has(13))
+	for nonce := 1001 - seen.size(); nonce < 1000; nonce++ {
+		require.False(t, seen.Has(nonce))
+	}
+}
+
+func TestNonceWindow(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	proxy := newLimitsTestProxy(conf.ProxyLimits{}, upstream)
+	config := proxy.config().Configuration
+	config.NonceWindow = 10
+	proxy.Reload(config, proxy.config().proxies)
+	router := proxy.getRouter()
+	contract, err := proxy.MemStore.Get("5")
+	require.NoError(t, err)
+	pay := func(nonce, cost int64) (int, error) {
+		return proxy.paidTierCost(ArkAuth{ContractId: 5, Spender: contract.Client, Nonce: nonce}, "127.0.0.1:8080", cost, 0)
+	}
+	paidNonce := func() int64 {
+		nonce, err := proxy.paidNonce(5)
+		require.NoError(t, err)
+		return nonce
+	}
+
+	// pipelined requests arrive out of order
+	nonces := rand.Perm(10)
+	var wg sync.WaitGroup
+	codes := make([]int, len(nonces))
+	for i, nonce := range nonces {
+		wg.Add(1)
+		go func(i int, nonce int64) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=5:%d", nonce), nil)
+			req.RemoteAddr = "10.0.0.1:1000"
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, req)
+			if response.Header().Get("tier") == "paid" {
+				codes[i] = response.Code
+			}
+		}(i, int64(nonce+1))
+	}
+	wg.Wait()
+	for i := range codes {
+		require.Equal(t, http.StatusOK, codes[i], "nonce %d", nonces[i]+1)
+	}
+	// the income accrues on the highest nonce
+	require.Equal(t, int64(10), paidNonce())
+	usage, err := proxy.ClaimStore.GetUsage(5)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), usage.Requests)
+
+	// every nonce is used once
+	for nonce := int64(1); nonce <= 10; nonce++ {
+		code, err := pay(nonce, 1)
+		require.Equal(t, http.StatusBadRequest, code)
+		require.Equal(t, ErrCodeBadNonce, errorCode(err, code))
+	}
+
+	// skipped nonces of the window can be used later, not the ones below it
+	code, err := pay(20, 1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	code, err = pay(11, 1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	code, err = pay(10, 1)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, err.Error(), "below the window")
+	require.Equal(t, int64(20), paidNonce())
+
+	// a request costing several units uses the nonces it spans
+	code, err = pay(15, 3)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	for _, nonce := range []int64{13, 14} {
+		code, err = pay(nonce, 1)
+		require.Equal(t, http.StatusBadRequest, code, "nonce %d", nonce)
+		require.Contains(t, err.Error(), "already used")
+	}
+	code, err = pay(16, 1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	// a replay racing the request it copies is refused
+	var served atomic.Int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pay(18, 1); err == nil {
+				served.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int64(1), served.Load())
+	require.Equal(t, int64(20), paidNonce())
+
+	// a nonce in flight is reserved until answered
+	reserved, code, err := proxy.reservePaidTier(ArkAuth{ContractId: 5, Spender: contract.Client, Nonce: 19}, "127.0.0.1:8080", 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	_, err = pay(19, 1)
+	require.Contains(t, err.Error(), "in flight")
+	reserved.release()
+	code, err = pay(19, 1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	// without a window the nonces must increase again
+	config.NonceWindow = 0
+	proxy.Reload(config, proxy.config().proxies)
+	code, err = pay(17, 1)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, ErrCodeBadNonce, errorCode(err, code))
+	code, err = pay(21, 1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	// the nonces claimed while not tracked are all used
+	config.NonceWindow = 10
+	proxy.Reload(config, proxy.config().proxies)
+	code, err = pay(17, 1)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, err.Error(), "already used")
+}
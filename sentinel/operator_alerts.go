@@ -0,0 +1,347 @@
+package sentinel
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/tendermint/tendermint/libs/log"
+	tmCoreTypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// kinds of operator alerts
+const (
+	OperatorAlertProviderModified = "provider_modified"
+	OperatorAlertStatusChanged    = "provider_status_changed"
+	OperatorAlertBondChanged      = "provider_bond_changed"
+	OperatorAlertStrike           = "provider_strike"
+)
+
+// sinks operator alerts can be sent to
+const (
+	OperatorAlertSinkLog     = "log"
+	OperatorAlertSinkWebhook = "webhook"
+	OperatorAlertSinkExec    = "exec"
+)
+
+const (
+	// namespace of the state store holding the state of the operator watcher
+	StateNamespaceOperatorAlerts = "operator-alerts"
+	// how long an alert is remembered, so it isn't sent twice when the chain
+	// sends its event again
+	operatorAlertDedupTTL = 24 * time.Hour
+	// max time a sink can take to deliver an alert
+	operatorAlertTimeout = 10 * time.Second
+
+	operatorWatcherHeightKey = "height"
+	operatorStatusKeyPrefix  = "status-"
+	operatorAlertKeyPrefix   = "alert-"
+)
+
+// OperatorAlert is a chain event affecting the provider, sent to the operator
+type OperatorAlert struct {
+	Kind     string            `json:"kind"`
+	Provider common.PubKey     `json:"provider"`
+	Service  string            `json:"service"`
+	Height   int64             `json:"height"`
+	TxHash   string            `json:"tx_hash,omitempty"`
+	Message  string            `json:"message"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+// key identifies the alert, an event delivered twice gives the same key
+func (a OperatorAlert) key() string {
+	return fmt.Sprintf("%s%d/%s/%s/%s", operatorAlertKeyPrefix, a.Height, a.TxHash, a.Kind, a.Service)
+}
+
+// AlertSink delivers operator alerts
+type AlertSink interface {
+	Name() string
+	Notify(alert OperatorAlert) error
+}
+
+// LogSink logs the alerts
+type LogSink struct {
+	logger log.Logger
+}
+
+func (s LogSink) Name() string { return OperatorAlertSinkLog }
+
+func (s LogSink) Notify(alert OperatorAlert) error {
+	s.logger.Error("OperatorAlert", "kind", alert.Kind, "service", alert.Service, "height", alert.Height, "tx_hash", alert.TxHash, "message", alert.Message)
+	return nil
+}
+
+// WebhookSink posts the alerts as json to a url
+type WebhookSink struct {
+	URL    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) WebhookSink {
+	return WebhookSink{URL: url, client: &http.Client{Timeout: operatorAlertTimeout}}
+}
+
+func (s WebhookSink) Name() string { return OperatorAlertSinkWebhook }
+
+func (s WebhookSink) Notify(alert OperatorAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// CommandSink runs a command for each alert, with the alert as json on its
+// stdin, and its kind and message in the ARKEO_ALERT_KIND and
+// ARKEO_ALERT_MESSAGE environment variables
+type CommandSink struct {
+	Command string
+}
+
+func (s CommandSink) Name() string { return OperatorAlertSinkExec }
+
+func (s CommandSink) Notify(alert OperatorAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), operatorAlertTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", s.Command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"ARKEO_ALERT_KIND="+alert.Kind,
+		"ARKEO_ALERT_MESSAGE="+alert.Message,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// NewAlertSinks returns the configured sinks, unknown ones are an error
+func NewAlertSinks(names []string, webhook, command string, logger log.Logger) ([]AlertSink, error) {
+	sinks := make([]AlertSink, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case OperatorAlertSinkLog:
+			sinks = append(sinks, LogSink{logger: logger})
+		case OperatorAlertSinkWebhook:
+			if len(webhook) == 0 {
+				return nil, fmt.Errorf("webhook sink requires a webhook url")
+			}
+			sinks = append(sinks, NewWebhookSink(webhook))
+		case OperatorAlertSinkExec:
+			if len(command) == 0 {
+				return nil, fmt.Errorf("exec sink requires a command")
+			}
+			sinks = append(sinks, CommandSink{Command: command})
+		default:
+			return nil, fmt.Errorf("unknown operator alert sink %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// OperatorWatcher sends alerts for the chain events affecting the provider:
+// changes of its records, of its bond, and strikes reported by clients. The
+// chain has no slashing of provider bonds, a slash shows as a bond decrease.
+// The last notified height, the alerts already sent and the last known status
+// of each service are kept in the state store, so a restart neither sends an
+// alert twice nor misses a status change.
+type OperatorWatcher struct {
+	provider common.PubKey
+	sinks    []AlertSink
+	state    *StateStore
+	logger   log.Logger
+	lock     sync.Mutex
+}
+
+func NewOperatorWatcher(provider common.PubKey, sinks []AlertSink, state *StateStore, logger log.Logger) *OperatorWatcher {
+	return &OperatorWatcher{
+		provider: provider,
+		sinks:    sinks,
+		state:    state,
+		logger:   logger,
+	}
+}
+
+// LastHeight returns the height of the last alert sent
+func (w *OperatorWatcher) LastHeight() int64 {
+	var height int64
+	if _, err := w.state.Get(StateNamespaceOperatorAlerts, operatorWatcherHeightKey, &height); err != nil {
+		w.logger.Error("fail to read operator watcher height", "error", err)
+	}
+	return height
+}
+
+// HandleTx sends an alert for each event of the transaction affecting the
+// provider
+func (w *OperatorWatcher) HandleTx(result tmCoreTypes.ResultEvent) {
+	data, ok := result.Data.(tmtypes.EventDataTx)
+	if !ok {
+		w.logger.Error(fmt.Sprintf("failed cast %T to EventDataTx", result.Data))
+		return
+	}
+	height := data.TxResult.Height
+	if height < w.LastHeight() {
+		// already handled before a restart
+		return
+	}
+	txHash := strings.ToUpper(hex.EncodeToString(tmtypes.Tx(data.TxResult.Tx).Hash()))
+
+	for _, evt := range data.TxResult.Result.Events {
+		switch evt.Type {
+		case types.EventTypeModProvider, types.EventTypeBondProvider, types.EventTypeProviderStrike:
+		default:
+			continue
+		}
+		msg, err := sdk.ParseTypedEvent(evt)
+		if err != nil {
+			w.logger.Error("failed to parse typed event", "error", err, "type", evt.Type)
+			continue
+		}
+		for _, alert := range w.alerts(msg) {
+			alert.Provider = w.provider
+			alert.Height = height
+			alert.TxHash = txHash
+			w.Notify(alert)
+		}
+	}
+}
+
+// alerts returns the alerts of an event, none when it doesn't affect the
+// provider
+func (w *OperatorWatcher) alerts(msg proto.Message) []OperatorAlert {
+	switch evt := msg.(type) {
+	case *types.EventModProvider:
+		if !evt.Provider.Equals(w.provider) {
+			return nil
+		}
+		alerts := []OperatorAlert{{
+			Kind:    OperatorAlertProviderModified,
+			Service: evt.Service,
+			Message: fmt.Sprintf("provider of %s was modified by %s", evt.Service, evt.Creator),
+			Details: map[string]string{
+				"creator":      evt.Creator.String(),
+				"status":       evt.Status.String(),
+				"metadata_uri": evt.MetadataUri,
+			},
+		}}
+		previous, known := w.status(evt.Service)
+		if known && previous != evt.Status {
+			alerts = append(alerts, OperatorAlert{
+				Kind:    OperatorAlertStatusChanged,
+				Service: evt.Service,
+				Message: fmt.Sprintf("provider of %s went %s from %s", evt.Service, evt.Status, previous),
+				Details: map[string]string{"previous": previous.String(), "status": evt.Status.String()},
+			})
+		}
+		w.setStatus(evt.Service, evt.Status)
+		return alerts
+	case *types.EventBondProvider:
+		if !evt.Provider.Equals(w.provider) {
+			return nil
+		}
+		change := "increased"
+		if evt.BondRel.IsNegative() {
+			change = "decreased"
+		}
+		return []OperatorAlert{{
+			Kind:    OperatorAlertBondChanged,
+			Service: evt.Service,
+			Message: fmt.Sprintf("bond of %s %s by %s to %s", evt.Service, change, evt.BondRel.Abs(), evt.BondAbs),
+			Details: map[string]string{"bond_rel": evt.BondRel.String(), "bond_abs": evt.BondAbs.String()},
+		}}
+	case *types.EventProviderStrike:
+		if !evt.Provider.Equals(w.provider) {
+			return nil
+		}
+		return []OperatorAlert{{
+			Kind:    OperatorAlertStrike,
+			Service: evt.Service,
+			Message: fmt.Sprintf("provider of %s reported by the client of contract %d, %d strikes", evt.Service, evt.ContractId, evt.Strikes),
+			Details: map[string]string{
+				"contract_id": fmt.Sprintf("%d", evt.ContractId),
+				"reporter":    evt.Reporter.String(),
+				"reason":      evt.Reason,
+				"strikes":     fmt.Sprintf("%d", evt.Strikes),
+			},
+		}}
+	}
+	return nil
+}
+
+// Notify sends the alert to every sink, unless it was already sent or is older
+// than the last alert sent
+func (w *OperatorWatcher) Notify(alert OperatorAlert) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if alert.Height < w.LastHeight() {
+		return
+	}
+	var sent bool
+	found, err := w.state.Get(StateNamespaceOperatorAlerts, alert.key(), &sent)
+	if err != nil {
+		w.logger.Error("fail to read operator alert", "error", err)
+	}
+	if found {
+		return
+	}
+
+	for _, sink := range w.sinks {
+		if err := sink.Notify(alert); err != nil {
+			w.logger.Error("fail to send operator alert", "error", err, "sink", sink.Name(), "kind", alert.Kind)
+		}
+	}
+
+	if err := w.state.Set(StateNamespaceOperatorAlerts, alert.key(), true, operatorAlertDedupTTL); err != nil {
+		w.logger.Error("fail to save operator alert", "error", err)
+	}
+	if err := w.state.Set(StateNamespaceOperatorAlerts, operatorWatcherHeightKey, alert.Height, 0); err != nil {
+		w.logger.Error("fail to save operator watcher height", "error", err)
+	}
+	// the height must survive a crash, not only a clean shutdown
+	if err := w.state.Flush(); err != nil {
+		w.logger.Error("fail to flush operator watcher state", "error", err)
+	}
+}
+
+func (w *OperatorWatcher) status(service string) (types.ProviderStatus, bool) {
+	var status types.ProviderStatus
+	found, err := w.state.Get(StateNamespaceOperatorAlerts, operatorStatusKeyPrefix+service, &status)
+	if err != nil {
+		w.logger.Error("fail to read provider status", "error", err, "service", service)
+	}
+	return status, found
+}
+
+func (w *OperatorWatcher) setStatus(service string, status types.ProviderStatus) {
+	if err := w.state.Set(StateNamespaceOperatorAlerts, operatorStatusKeyPrefix+service, status, 0); err != nil {
+		w.logger.Error("fail to save provider status", "error", err, "service", service)
+	}
+}
@@ -0,0 +1,174 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+	tmCoreTypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// recordingSink keeps the alerts it is sent
+type recordingSink struct {
+	alerts []OperatorAlert
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Notify(alert OperatorAlert) error {
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func syntheticEvent(t *testing.T, evt proto.Message, height int64) tmCoreTypes.ResultEvent {
+	sdkEvt, err := sdk.TypedEventToEvent(evt)
+	require.NoError(t, err)
+	return makeResultEvent(sdkEvt, height)
+}
+
+func TestOperatorWatcher(t *testing.T) {
+	dir := t.TempDir()
+	logger := log.NewNopLogger()
+	provider := types.GetRandomPubKey()
+	service := common.BTCService.String()
+
+	state, err := NewStateStore(dir)
+	require.NoError(t, err)
+	sink := &recordingSink{}
+	watcher := NewOperatorWatcher(provider, []AlertSink{sink}, state, logger)
+
+	modProvider := func(pk common.PubKey, status types.ProviderStatus) *types.EventModProvider {
+		return &types.EventModProvider{
+			Creator:  types.GetRandomBech32Addr(),
+			Provider: pk,
+			Service:  service,
+			Status:   status,
+			Bond:     cosmos.NewInt(100),
+		}
+	}
+	kinds := func() []string {
+		var kinds []string
+		for _, alert := range sink.alerts {
+			kinds = append(kinds, alert.Kind)
+		}
+		sink.alerts = nil
+		return kinds
+	}
+
+	// events of other providers are ignored
+	watcher.HandleTx(syntheticEvent(t, modProvider(types.GetRandomPubKey(), types.ProviderStatus_ONLINE), 10))
+	require.Empty(t, kinds())
+
+	// the first modification only tells the record changed
+	online := syntheticEvent(t, modProvider(provider, types.ProviderStatus_ONLINE), 10)
+	watcher.HandleTx(online)
+	require.Len(t, sink.alerts, 1)
+	require.Equal(t, provider, sink.alerts[0].Provider)
+	require.Equal(t, int64(10), sink.alerts[0].Height)
+	require.Equal(t, []string{OperatorAlertProviderModified}, kinds())
+
+	// an event delivered twice is only sent once
+	watcher.HandleTx(online)
+	require.Empty(t, kinds())
+
+	watcher.HandleTx(syntheticEvent(t, modProvider(provider, types.ProviderStatus_OFFLINE), 11))
+	require.Equal(t, []string{OperatorAlertProviderModified, OperatorAlertStatusChanged}, kinds())
+
+	watcher.HandleTx(syntheticEvent(t, &types.EventBondProvider{
+		Provider: provider,
+		Service:  service,
+		BondRel:  cosmos.NewInt(-50),
+		BondAbs:  cosmos.NewInt(50),
+	}, 12))
+	require.Len(t, sink.alerts, 1)
+	require.Contains(t, sink.alerts[0].Message, "decreased")
+	require.Equal(t, []string{OperatorAlertBondChanged}, kinds())
+
+	strike := syntheticEvent(t, &types.EventProviderStrike{
+		Provider:   provider,
+		Service:    service,
+		ContractId: 3,
+		Reporter:   types.GetRandomPubKey(),
+		Reason:     "stale responses",
+		Strikes:    1,
+	}, 13)
+	watcher.HandleTx(strike)
+	require.Equal(t, []string{OperatorAlertStrike}, kinds())
+	require.Equal(t, int64(13), watcher.LastHeight())
+
+	// restart, the state of the watcher is kept
+	require.NoError(t, state.Close())
+	state, err = NewStateStore(dir)
+	require.NoError(t, err)
+	defer state.Close()
+	watcher = NewOperatorWatcher(provider, []AlertSink{sink}, state, logger)
+	require.Equal(t, int64(13), watcher.LastHeight())
+
+	// already sent, or older than the last alert
+	watcher.HandleTx(strike)
+	watcher.HandleTx(syntheticEvent(t, modProvider(provider, types.ProviderStatus_ONLINE), 12))
+	require.Empty(t, kinds())
+
+	// the status before the restart is known
+	watcher.HandleTx(syntheticEvent(t, modProvider(provider, types.ProviderStatus_ONLINE), 14))
+	require.Equal(t, []string{OperatorAlertProviderModified, OperatorAlertStatusChanged}, kinds())
+}
+
+func TestAlertSinks(t *testing.T) {
+	logger := log.NewNopLogger()
+	alert := OperatorAlert{
+		Kind:     OperatorAlertBondChanged,
+		Provider: types.GetRandomPubKey(),
+		Service:  common.BTCService.String(),
+		Height:   10,
+		Message:  "bond decreased",
+	}
+
+	var received OperatorAlert
+	webhook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.Equal(t, http.MethodPost, req.Method)
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&received))
+	}))
+	defer webhook.Close()
+
+	out := filepath.Join(t.TempDir(), "alert.json")
+	sinks, err := NewAlertSinks([]string{OperatorAlertSinkLog, OperatorAlertSinkWebhook, OperatorAlertSinkExec}, webhook.URL, "cat > "+out, logger)
+	require.NoError(t, err)
+	require.Len(t, sinks, 3)
+	for _, sink := range sinks {
+		require.NoError(t, sink.Notify(alert), sink.Name())
+	}
+
+	require.Equal(t, alert, received)
+	buf, err := os.ReadFile(out)
+	require.NoError(t, err)
+	var executed OperatorAlert
+	require.NoError(t, json.Unmarshal(buf, &executed))
+	require.Equal(t, alert, executed)
+
+	// failures are reported
+	require.Error(t, CommandSink{Command: "exit 1"}.Notify(alert))
+	failing := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	require.Error(t, NewWebhookSink(failing.URL).Notify(alert))
+
+	_, err = NewAlertSinks([]string{"pager"}, "", "", logger)
+	require.Error(t, err)
+	_, err = NewAlertSinks([]string{OperatorAlertSinkWebhook}, "", "", logger)
+	require.Error(t, err)
+	_, err = NewAlertSinks([]string{OperatorAlertSinkExec}, "", "", logger)
+	require.Error(t, err)
+}
@@ -0,0 +1,301 @@
+package sentinel
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// nonceReservations holds the nonces of the pay-as-you-go requests being
+// served. A nonce is reserved until the request paying with it is answered,
+// so it can't be replayed by a concurrent request in the meantime.
+type nonceReservations struct {
+	lock    *sync.Mutex
+	pending map[uint64]map[int64]int64 // cost of the requests by nonce, by contract
+}
+
+func newNonceReservations() *nonceReservations {
+	return &nonceReservations{
+		lock:    &sync.Mutex{},
+		pending: make(map[uint64]map[int64]int64),
+	}
+}
+
+// highest returns the highest nonce reserved for the contract, the caller
+// holds the lock
+func (n *nonceReservations) highest(contractId uint64) int64 {
+	var highest int64
+	for nonce := range n.pending[contractId] {
+		if nonce > highest {
+			highest = nonce
+		}
+	}
+	return highest
+}
+
+// overlaps tells whether a nonce between low and high is reserved, the caller
+// holds the lock. A request reserves the nonces its cost spans.
+func (n *nonceReservations) overlaps(contractId uint64, low, high int64) bool {
+	for nonce, cost := range n.pending[contractId] {
+		if nonce >= low && nonce-cost < high {
+			return true
+		}
+	}
+	return false
+}
+
+// add reserves the nonce, the caller holds the lock
+func (n *nonceReservations) add(contractId uint64, nonce, cost int64) {
+	if _, ok := n.pending[contractId]; !ok {
+		n.pending[contractId] = make(map[int64]int64)
+	}
+	n.pending[contractId][nonce] = cost
+}
+
+// remove drops the reservation of the nonce, the caller holds the lock
+func (n *nonceReservations) remove(contractId uint64, nonce int64) {
+	delete(n.pending[contractId], nonce)
+	if len(n.pending[contractId]) == 0 {
+		delete(n.pending, contractId)
+	}
+}
+
+// release drops the reservation of a nonce that wasn't paid
+func (n *nonceReservations) release(contractId uint64, nonce int64) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.remove(contractId, nonce)
+}
+
+// reserveNonce checks the nonce of a pay-as-you-go request against the claim
+// of the contract and the requests in flight, then reserves it. It returns
+// the stored claim, or the given one if the contract has none, and the highest
+// nonce paid for or in flight.
+//
+// The nonce of a request must be above the highest one by at least its cost,
+// unless a nonce window is configured: the nonces of the window below the
+// highest one can then be used out of order, once each.
+func (p Proxy) reserveNonce(key string, aa ArkAuth, cost int64, claim Claim) (Claim, int64, int, error) {
+	p.nonces.lock.Lock()
+	defer p.nonces.lock.Unlock()
+	var paid int64
+	if p.ClaimStore.Has(key) {
+		var err error
+		claim, err = p.ClaimStore.Get(key)
+		if err != nil {
+			return claim, 0, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
+		}
+		paid = claim.Nonce
+	}
+	highest := paid
+	if pending := p.nonces.highest(aa.ContractId); pending > highest {
+		highest = pending
+	}
+
+	window := p.config().NonceWindow
+	if window <= 0 {
+		if highest >= aa.Nonce {
+			return claim, highest, http.StatusBadRequest, newProxyError(ErrCodeBadNonce, "bad nonce (%d/%d)", aa.Nonce, highest)
+		}
+		if highest+cost > aa.Nonce {
+			return claim, highest, http.StatusBadRequest, newProxyError(ErrCodeBadNonce, "bad nonce, request costs %d (%d/%d)", cost, aa.Nonce, highest+cost)
+		}
+		p.nonces.add(aa.ContractId, aa.Nonce, cost)
+		return claim, highest, http.StatusOK, nil
+	}
+
+	// the request uses the nonces its cost spans, up to its own
+	low := aa.Nonce - cost + 1
+	if low <= 0 || low <= paid-window {
+		lowest := cost
+		if paid-window > 0 {
+			lowest += paid - window
+		}
+		return claim, highest, http.StatusBadRequest, newProxyError(ErrCodeBadNonce, "bad nonce, below the window, request costs %d and the lowest nonce accepted is %d (%d)", cost, lowest, aa.Nonce)
+	}
+	seen, err := p.seenNonces(aa.ContractId, paid, window)
+	if err != nil {
+		return claim, highest, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
+	}
+	for nonce := low; nonce <= aa.Nonce; nonce++ {
+		if seen.Has(nonce) {
+			return claim, highest, http.StatusBadRequest, newProxyError(ErrCodeBadNonce, "bad nonce, %d already used (%d/%d)", nonce, aa.Nonce, highest)
+		}
+	}
+	if p.nonces.overlaps(aa.ContractId, low, aa.Nonce) {
+		return claim, highest, http.StatusBadRequest, newProxyError(ErrCodeBadNonce, "bad nonce, used by a request in flight (%d/%d)", aa.Nonce, highest)
+	}
+	p.nonces.add(aa.ContractId, aa.Nonce, cost)
+	return claim, highest, http.StatusOK, nil
+}
+
+// seenNonces returns the seen nonces of the contract, for the window and the
+// highest nonce claimed. Nonces claimed while they weren't tracked, or tracked
+// with another window, are all considered used.
+func (p Proxy) seenNonces(contractId uint64, claimed, window int64) (SeenNonces, error) {
+	seen, ok, err := p.ClaimStore.GetSeenNonces(contractId)
+	if err != nil {
+		return seen, err
+	}
+	if ok && seen.Highest == claimed && len(seen.Bits) == seenNoncesWords(window) {
+		return seen, nil
+	}
+	if seen.Highest > claimed {
+		claimed = seen.Highest
+	}
+	return newSeenNonces(contractId, claimed, window), nil
+}
+
+// payment is a paid request accepted by reservePaidTier. The nonce of a
+// pay-as-you-go request is only reserved, the claim is written when the
+// payment is committed. Committed requests are counted in the usage of the
+// contract.
+type payment struct {
+	proxy            Proxy
+	contract         types.Contract
+	claim            Claim
+	cost             int64
+	dailySpendCapUSD float64
+	at               time.Time
+	pending          bool // yet to be committed or released
+	reserved         bool // the nonce is reserved, pay-as-you-go only
+}
+
+// commit counts the request in the usage of the contract, writes its claim
+// and frees its reservation. The claim is only written when the nonce of the
+// request is the highest one, the income accrues on the highest nonce.
+func (pay *payment) commit() error {
+	if !pay.pending {
+		return nil
+	}
+	pay.pending = false
+	p := pay.proxy
+	p.nonces.lock.Lock()
+	defer p.nonces.lock.Unlock()
+	if pay.reserved {
+		defer p.nonces.remove(pay.contract.Id, pay.claim.Nonce)
+	}
+
+	usage, err := p.ClaimStore.GetUsage(pay.contract.Id)
+	if err != nil {
+		return fmt.Errorf("fail to get usage: %w", err)
+	}
+	usage.Add(pay.at)
+	if !pay.reserved {
+		if err := p.ClaimStore.Commit(nil, usage, nil); err != nil {
+			return fmt.Errorf("fail to save usage: %w", err)
+		}
+		return nil
+	}
+
+	var claimed int64
+	key := strconv.FormatUint(pay.contract.Id, 10)
+	if p.ClaimStore.Has(key) {
+		stored, err := p.ClaimStore.Get(key)
+		if err != nil {
+			return fmt.Errorf("fail to get claim: %w", err)
+		}
+		claimed = stored.Nonce
+	}
+	var seen *SeenNonces
+	if window := p.config().NonceWindow; window > 0 {
+		nonces, err := p.seenNonces(pay.contract.Id, claimed, window)
+		if err != nil {
+			return fmt.Errorf("fail to get seen nonces: %w", err)
+		}
+		for nonce := pay.claim.Nonce - pay.cost + 1; nonce <= pay.claim.Nonce; nonce++ {
+			nonces.Add(nonce)
+		}
+		seen = &nonces
+	}
+	// a higher nonce may have been committed while the request was served
+	if claimed >= pay.claim.Nonce {
+		if err := p.ClaimStore.Commit(nil, usage, seen); err != nil {
+			return fmt.Errorf("fail to save usage: %w", err)
+		}
+		return nil
+	}
+
+	if err := p.ClaimStore.Commit(&pay.claim, usage, seen); err != nil {
+		return fmt.Errorf("fail to save claim: %w", err)
+	}
+	if contract, ok := p.MemStore.Peek(key); ok && contract.Nonce < pay.claim.Nonce {
+		contract.Nonce = pay.claim.Nonce
+		p.MemStore.Put(contract)
+	}
+	if pay.dailySpendCapUSD > 0 {
+		p.DailySpendTracker.Add(pay.contract.Id, pay.claim.Nonce-claimed, pay.at)
+	}
+	return nil
+}
+
+// release frees the reservation of a request that wasn't answered, its nonce
+// can be used again
+func (pay *payment) release() {
+	if !pay.pending {
+		return
+	}
+	pay.pending = false
+	if pay.reserved {
+		pay.proxy.nonces.release(pay.contract.Id, pay.claim.Nonce)
+	}
+}
+
+// paymentRecorder settles the payment of a request once the status of its
+// response is known, before the response headers are sent
+type paymentRecorder struct {
+	http.ResponseWriter
+	settle  func(code int)
+	settled bool
+}
+
+func (s *paymentRecorder) settleOnce(code int) {
+	if s.settled {
+		return
+	}
+	s.settled = true
+	s.settle(code)
+}
+
+func (s *paymentRecorder) WriteHeader(code int) {
+	// informational responses are followed by the final one
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		s.ResponseWriter.WriteHeader(code)
+		return
+	}
+	s.settleOnce(code)
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *paymentRecorder) Write(b []byte) (int, error) {
+	s.settleOnce(http.StatusOK)
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *paymentRecorder) Flush() {
+	s.settleOnce(http.StatusOK)
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack is required to proxy websockets, an upgraded connection is answered
+func (s *paymentRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil {
+		s.settleOnce(http.StatusInternalServerError)
+		return conn, rw, err
+	}
+	s.settleOnce(http.StatusSwitchingProtocols)
+	return conn, rw, nil
+}
@@ -0,0 +1,181 @@
+package sentinel
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// newWriteModeTestProxy returns a proxy storing its claims on disk with the
+// given write mode, serving a pay-as-you-go contract without rate limit
+func newWriteModeTestProxy(dir, mode string) (Proxy, types.Contract) {
+	config := newTestConfig()
+	config.ClaimStoreLocation = dir
+	config.ClaimWriteMode = mode
+	proxy := NewProxy(config)
+	contract := newTestContract(config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Deposit = cosmos.NewInt(math.MaxInt64)
+	contract.Id = 5
+	contract.QueriesPerMinute = math.MaxInt32
+	proxy.MemStore.Put(contract)
+	return proxy, contract
+}
+
+func TestPaidTierWriteBack(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	proxy, contract := newWriteModeTestProxy(t.TempDir(), ClaimWriteBack)
+	defer proxy.ClaimStore.Close()
+
+	aa := ArkAuth{ContractId: contract.Id, Spender: contract.Client, Nonce: 1}
+	code, err := proxy.paidTier(aa, "127.0.0.1:8080")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	// the nonce is only held in memory, replays are refused all the same
+	onDisk, err := proxy.ClaimStore.GetInternalDb().Has([]byte(contract.Key()), nil)
+	require.NoError(t, err)
+	require.False(t, onDisk)
+	code, err = proxy.paidTier(aa, "127.0.0.1:8080")
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, code)
+	nonce, err := proxy.paidNonce(contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), nonce)
+
+	// and written to disk once flushed
+	require.NoError(t, proxy.ClaimStore.Flush())
+	onDisk, err = proxy.ClaimStore.GetInternalDb().Has([]byte(contract.Key()), nil)
+	require.NoError(t, err)
+	require.True(t, onDisk)
+}
+
+// BenchmarkPaidTier measures the paid requests of a hot contract, with its
+// claim written to disk on each request or held in memory until flushed
+func BenchmarkPaidTier(b *testing.B) {
+	for _, mode := range []string{ClaimWriteThrough, ClaimWriteBack} {
+		b.Run(mode, func(b *testing.B) {
+			visitors = make(map[string]*rate.Limiter) // reset visitors
+			proxy, contract := newWriteModeTestProxy(b.TempDir(), mode)
+			defer proxy.ClaimStore.Close()
+			go proxy.ClaimStore.Flusher(time.Second, proxy.stopping)
+			defer close(proxy.stopping)
+
+			aa := ArkAuth{ContractId: contract.Id, Spender: contract.Client}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				aa.Nonce = int64(i + 1)
+				if _, err := proxy.paidTier(aa, "127.0.0.1:8080"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPaidRequestUpstreamFailure(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+
+	var status atomic.Int64
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(int(status.Load()))
+	}))
+	defer upstream.Close()
+
+	proxy := newLimitsTestProxy(conf.ProxyLimits{}, upstream)
+	router := proxy.getRouter()
+
+	serve := func(code int, nonce int64) *httptest.ResponseRecorder {
+		status.Store(int64(code))
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=5:%d", nonce), nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		require.Equal(t, code, response.Code)
+		require.Equal(t, "paid", response.Header().Get("tier"))
+		return response
+	}
+	paidNonce := func() int64 {
+		nonce, err := proxy.paidNonce(5)
+		require.NoError(t, err)
+		return nonce
+	}
+
+	// the failed request isn't paid for, its nonce is used again
+	response := serve(http.StatusBadGateway, 1)
+	require.Equal(t, "0", response.Header().Get(NonceHeader))
+	require.Zero(t, paidNonce())
+	require.False(t, proxy.ClaimStore.Has("5"))
+
+	response = serve(http.StatusOK, 1)
+	require.Equal(t, "1", response.Header().Get(NonceHeader))
+	require.Equal(t, int64(1), paidNonce())
+
+	// a redirect is an answer, a client error isn't
+	serve(http.StatusFound, 2)
+	require.Equal(t, int64(2), paidNonce())
+	serve(http.StatusNotFound, 3)
+	serve(http.StatusServiceUnavailable, 3)
+	require.Equal(t, int64(2), paidNonce())
+	claim, err := proxy.ClaimStore.Get("5")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), claim.Nonce)
+}
+
+func TestNonceReservation(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+	proxy := newLimitsTestProxy(conf.ProxyLimits{}, upstream)
+	contract, err := proxy.MemStore.Get("5")
+	require.NoError(t, err)
+
+	reserve := func(nonce int64) (*payment, int, error) {
+		return proxy.reservePaidTier(ArkAuth{ContractId: 5, Spender: contract.Client, Nonce: nonce}, "127.0.0.1:8080", 1, 0)
+	}
+
+	// a nonce in flight can't be replayed
+	first, code, err := reserve(1)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	_, code, err = reserve(1)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Equal(t, ErrCodeBadNonce, errorCode(err, code))
+
+	// a higher nonce is served meanwhile, and answered first
+	second, _, err := reserve(2)
+	require.NoError(t, err)
+	require.NoError(t, second.commit())
+	first.release()
+	nonce, err := proxy.paidNonce(5)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), nonce)
+
+	// the failed request doesn't roll back the higher nonce
+	third, _, err := reserve(3)
+	require.NoError(t, err)
+	fourth, _, err := reserve(4)
+	require.NoError(t, err)
+	require.NoError(t, fourth.commit())
+	require.NoError(t, third.commit())
+	nonce, err = proxy.paidNonce(5)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), nonce)
+
+	// a refused request releases its reservation
+	_, code, err = reserve(1000)
+	require.Equal(t, http.StatusPaymentRequired, code)
+	require.Error(t, err)
+	require.Empty(t, proxy.nonces.pending)
+}
@@ -0,0 +1,114 @@
+package sentinel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// MaxRequestCost is the highest multiplier a pricing table can charge for a
+// single request
+const MaxRequestCost = 1000
+
+// ValidatePricing checks every entry of a pricing table charges at least one
+// unit, and not more than MaxRequestCost
+func ValidatePricing(pricing map[string]int64) error {
+	for key, cost := range pricing {
+		if len(key) == 0 {
+			return fmt.Errorf("pricing key cannot be empty")
+		}
+		if cost < 1 || cost > MaxRequestCost {
+			return fmt.Errorf("cost of %q must be between 1 and %d", key, MaxRequestCost)
+		}
+	}
+	return nil
+}
+
+type jsonRPCCall struct {
+	Method string `json:"method"`
+}
+
+// requestCost returns the number of nonce units a request costs, according to
+// the on-chain rate schedule and the pricing table of its contract, the
+// highest of both. The url path (without the service name) is looked up first,
+// then the JSON-RPC method of the body. A batch of JSON-RPC calls costs the sum
+// of its calls. Anything not priced costs one.
+func (p Proxy) requestCost(r *http.Request, pricing map[string]int64, rates []types.MethodRate) int64 {
+	if len(pricing) == 0 && len(rates) == 0 {
+		return 1
+	}
+	if cost, ok := lookupCost(pricing, rates, pricingPath(r)); ok {
+		return cost
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return 1
+	}
+
+	// peek at the body, the request body limit is enforced later on
+	reader := io.Reader(r.Body)
+	if max := p.maxRequestBodyBytes(); max > 0 {
+		reader = io.LimitReader(r.Body, max+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		p.logger.Error("fail to read request body", "error", err)
+	}
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var calls []jsonRPCCall
+		if err := json.Unmarshal(body, &calls); err != nil || len(calls) == 0 {
+			return 1
+		}
+		var total int64
+		for _, call := range calls {
+			total += methodCost(pricing, rates, call.Method)
+		}
+		return total
+	}
+	var call jsonRPCCall
+	if err := json.Unmarshal(body, &call); err != nil {
+		return 1
+	}
+	return methodCost(pricing, rates, call.Method)
+}
+
+func methodCost(pricing map[string]int64, rates []types.MethodRate, method string) int64 {
+	if cost, ok := lookupCost(pricing, rates, method); ok && len(method) > 0 {
+		return cost
+	}
+	return 1
+}
+
+// lookupCost returns the cost of a method (or url path), the highest of its
+// multiplier in the rate schedule and its pricing. False is returned when
+// neither prices it.
+func lookupCost(pricing map[string]int64, rates []types.MethodRate, key string) (int64, bool) {
+	cost, ok := pricing[key]
+	if multiplier, found := types.MethodRateMultiplier(rates, key); found {
+		if !ok || int64(multiplier) > cost {
+			cost = int64(multiplier)
+		}
+		ok = true
+	}
+	return cost, ok
+}
+
+// pricingPath returns the path of the request as sent to the upstream service,
+// without the service name when it is the first item of the path
+func pricingPath(r *http.Request) string {
+	if len(r.Header.Get(ServiceHeader)) > 0 {
+		return r.URL.Path
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) < 2 {
+		return "/"
+	}
+	return "/" + parts[1]
+}
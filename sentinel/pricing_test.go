@@ -0,0 +1,152 @@
+package sentinel
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRequestCost(t *testing.T) {
+	proxy := NewProxy(newTestConfig())
+	pricing := map[string]int64{
+		"/expensive":      5,
+		"eth_getLogs":     10,
+		"eth_blockNumber": 2,
+	}
+	cost := func(method, path, body string, p map[string]int64) int64 {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		c := proxy.requestCost(req, p, nil)
+		// the body is still readable by the upstream service
+		buf := new(strings.Builder)
+		_, err := buf.ReadFrom(req.Body)
+		require.NoError(t, err)
+		require.Equal(t, body, buf.String())
+		return c
+	}
+
+	require.Equal(t, int64(1), cost(http.MethodGet, "/eth-mainnet-fullnode/expensive", "", nil))
+	require.Equal(t, int64(1), cost(http.MethodGet, "/eth-mainnet-fullnode/cheap", "", pricing))
+	require.Equal(t, int64(5), cost(http.MethodGet, "/eth-mainnet-fullnode/expensive", "", pricing))
+	require.Equal(t, int64(10), cost(http.MethodPost, "/eth-mainnet-fullnode/", `{"jsonrpc":"2.0","method":"eth_getLogs","id":1}`, pricing))
+	require.Equal(t, int64(1), cost(http.MethodPost, "/eth-mainnet-fullnode/", `{"jsonrpc":"2.0","method":"eth_chainId","id":1}`, pricing))
+	require.Equal(t, int64(13), cost(http.MethodPost, "/eth-mainnet-fullnode/", `[{"method":"eth_getLogs"},{"method":"eth_blockNumber"},{"method":"eth_chainId"}]`, pricing))
+	require.Equal(t, int64(1), cost(http.MethodPost, "/eth-mainnet-fullnode/", `not json`, pricing))
+	// the path is looked up before the body
+	require.Equal(t, int64(5), cost(http.MethodPost, "/eth-mainnet-fullnode/expensive", `{"method":"eth_getLogs"}`, pricing))
+
+	// the on-chain rate schedule of the contract, the highest cost wins
+	rates := []types.MethodRate{types.NewMethodRate("debug_*", 20), types.NewMethodRate("eth_getLogs", 3), types.NewMethodRate("/trace/*", 7)}
+	costWithRates := func(path, body string, p map[string]int64) int64 {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		return proxy.requestCost(req, p, rates)
+	}
+	require.Equal(t, int64(20), costWithRates("/eth-mainnet-fullnode/", `{"method":"debug_traceTransaction"}`, nil))
+	require.Equal(t, int64(10), costWithRates("/eth-mainnet-fullnode/", `{"method":"eth_getLogs"}`, pricing))
+	require.Equal(t, int64(1), costWithRates("/eth-mainnet-fullnode/", `{"method":"eth_chainId"}`, nil))
+	require.Equal(t, int64(7), costWithRates("/eth-mainnet-fullnode/trace/block", ``, nil))
+	require.Equal(t, int64(23), costWithRates("/eth-mainnet-fullnode/", `[{"method":"debug_traceCall"},{"method":"eth_blockNumber"},{"method":"eth_chainId"}]`, pricing))
+	// a cheaper pricing of the contract can't undercut the rate schedule
+	require.Equal(t, int64(20), costWithRates("/eth-mainnet-fullnode/", `{"method":"debug_traceTransaction"}`, map[string]int64{"debug_traceTransaction": 2}))
+
+	require.NoError(t, ValidatePricing(pricing))
+	require.Error(t, ValidatePricing(map[string]int64{"eth_getLogs": 0}))
+	require.Error(t, ValidatePricing(map[string]int64{"eth_getLogs": MaxRequestCost + 1}))
+	require.Error(t, ValidatePricing(map[string]int64{"": 2}))
+}
+
+func TestPaidTierMixedCost(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	proxy := NewProxy(newTestConfig())
+
+	contract := newTestContract(proxy.Config.ProviderPubKey, common.ETHService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Deposit = cosmos.NewInt(10)
+	contract.Id = 77
+	proxy.MemStore.Put(contract)
+	proxy.MemStore.SetHeight(20)
+
+	pay := func(nonce, cost int64) int {
+		aa := ArkAuth{ContractId: contract.Id, Spender: contract.Client, Nonce: nonce}
+		code, _ := proxy.paidTierCost(aa, "127.0.0.1:8080", cost, 0)
+		return code
+	}
+	nonce := func() int64 {
+		claim, err := proxy.ClaimStore.Get(contract.Key())
+		require.NoError(t, err)
+		return claim.Nonce
+	}
+
+	// a cheap request
+	require.Equal(t, http.StatusOK, pay(1, 1))
+	require.Equal(t, int64(1), nonce())
+
+	// an expensive request must increase the nonce by its cost
+	require.Equal(t, http.StatusBadRequest, pay(2, 5))
+	require.Equal(t, http.StatusOK, pay(6, 5))
+	require.Equal(t, int64(6), nonce())
+
+	// a request costing more than the remaining deposit is refused
+	require.Equal(t, http.StatusPaymentRequired, pay(11, 5))
+	require.Equal(t, int64(6), nonce())
+
+	// the remaining deposit can still be spent by cheaper requests
+	require.Equal(t, http.StatusOK, pay(9, 3))
+	require.Equal(t, http.StatusOK, pay(10, 1))
+	require.Equal(t, int64(10), nonce())
+
+	// the deposit is spent
+	require.Equal(t, http.StatusPaymentRequired, pay(11, 1))
+}
+
+func TestPaidTierMethodRates(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	proxy := NewProxy(newTestConfig())
+	setServiceURL(proxy, common.ETHService.String(), common.MustParseURL(upstream.URL))
+	contract := newTestContract(proxy.Config.ProviderPubKey, common.ETHService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Deposit = cosmos.NewInt(1000)
+	contract.Id = 78
+	contract.MethodRates = []types.MethodRate{types.NewMethodRate("debug_*", 20), types.NewMethodRate("eth_getLogs", 5)}
+	proxy.MemStore.Put(contract)
+	router := proxy.getRouter()
+
+	serve := func(nonce int64, body string) int {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/eth-mainnet-fullnode/?arkauth=78:%d", nonce), strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:1000"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response.Code
+	}
+	claimed := func() int64 {
+		claim, err := proxy.ClaimStore.Get(contract.Key())
+		require.NoError(t, err)
+		return claim.Nonce
+	}
+
+	// an unknown method is charged the rate
+	require.Equal(t, http.StatusOK, serve(1, `{"jsonrpc":"2.0","method":"eth_blockNumber","id":1}`))
+	require.Equal(t, int64(1), claimed())
+
+	// an expensive method must increase the nonce by its multiplier, the claim
+	// of the nonce settles the multiplied cost
+	require.Equal(t, http.StatusBadRequest, serve(2, `{"jsonrpc":"2.0","method":"debug_traceTransaction","id":1}`))
+	require.Equal(t, http.StatusOK, serve(21, `{"jsonrpc":"2.0","method":"debug_traceTransaction","id":1}`))
+	require.Equal(t, int64(21), claimed())
+
+	// a batch costs the sum of its calls
+	batch := `[{"method":"debug_traceCall"},{"method":"eth_getLogs"},{"method":"eth_chainId"}]`
+	require.Equal(t, http.StatusBadRequest, serve(46, batch))
+	require.Equal(t, http.StatusOK, serve(47, batch))
+	require.Equal(t, int64(47), claimed())
+}
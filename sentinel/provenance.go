@@ -0,0 +1,224 @@
+package sentinel
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+)
+
+const (
+	// provider whose key signed the response
+	ProviderHeader = "X-Ark-Provider"
+	// height the response was signed at
+	HeightHeader = "X-Ark-Height"
+	// hex signature of ResponseProvenanceMessage by the provider key
+	SignatureHeader = "X-Ark-Signature"
+)
+
+// ResponseProvenanceMessage is the message the provider key signs for the
+// response of a contract: "<hex sha256 of the body>:<height>:<contract id>"
+func ResponseProvenanceMessage(body []byte, height int64, contractId uint64) []byte {
+	sum := sha256.Sum256(body)
+	return []byte(fmt.Sprintf("%s:%d:%d", hex.EncodeToString(sum[:]), height, contractId))
+}
+
+// VerifyResponse checks the provenance headers of a response of the contract
+// prove its body was served by the provider. The provider is the pubkey
+// registered on chain, not the one the response claims. The body is the
+// decoded one, the signature is made before the response is compressed.
+func VerifyResponse(provider common.PubKey, contractId uint64, header http.Header, body []byte) error {
+	signer, err := common.NewPubKey(header.Get(ProviderHeader))
+	if err != nil {
+		return fmt.Errorf("bad provider header: %w", err)
+	}
+	if !signer.Equals(provider) {
+		return fmt.Errorf("response signed by provider %s, not %s", signer, provider)
+	}
+	height, err := strconv.ParseInt(header.Get(HeightHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad height header: %w", err)
+	}
+	signature, err := hex.DecodeString(header.Get(SignatureHeader))
+	if err != nil || len(signature) == 0 {
+		return fmt.Errorf("bad signature header: %q", header.Get(SignatureHeader))
+	}
+	pk, err := cosmos.GetPubKeyFromBech32(cosmos.Bech32PubKeyTypeAccPub, provider.String())
+	if err != nil {
+		return fmt.Errorf("bad provider pubkey: %w", err)
+	}
+	if !pk.VerifySignature(ResponseProvenanceMessage(body, height, contractId), signature) {
+		return fmt.Errorf("invalid signature, the response of contract %d at height %d wasn't signed by provider %s", contractId, height, provider)
+	}
+	return nil
+}
+
+// signProvenance signs the body of a response of the contract with the
+// provider key, at the current height
+func (p Proxy) signProvenance(body []byte, contractId uint64) (int64, []byte, error) {
+	height := p.MemStore.GetHeight()
+	signature, pk, err := p.Identity.Sign(ResponseProvenanceMessage(body, height, contractId))
+	if err != nil {
+		return 0, nil, err
+	}
+	// a claim key other than the provider key would sign responses the
+	// clients can't verify
+	signer, err := common.NewPubKeyFromCrypto(pk)
+	if err != nil || !signer.Equals(p.Config.ProviderPubKey) {
+		return 0, nil, fmt.Errorf("claim key isn't the provider key")
+	}
+	return height, signature, nil
+}
+
+// signResponse wraps the writer to sign the response of the contract, when
+// its configuration asks for it. The returned func sends the signed response,
+// it must be called once the response is written.
+func (p Proxy) signResponse(w http.ResponseWriter, r *http.Request, contractId uint64) (http.ResponseWriter, func()) {
+	if contractId == 0 || p.Identity == nil || r.Method == http.MethodHead {
+		return w, func() {}
+	}
+	conf, err := p.ContractConfigStore.Get(contractId)
+	if err != nil {
+		p.logger.Error("failed to fetch contract configuration", "error", err, "contract_id", contractId)
+		return w, func() {}
+	}
+	if !conf.SignResponses {
+		return w, func() {}
+	}
+	sw := &signingWriter{
+		ResponseWriter: w,
+		provider:       p.Config.ProviderPubKey,
+		maxSize:        p.config().SignResponsesMaxBytes,
+		code:           http.StatusOK,
+		sign: func(body []byte) (int64, []byte, error) {
+			return p.signProvenance(body, contractId)
+		},
+	}
+	return sw, func() {
+		if err := sw.Close(); err != nil {
+			p.logger.Error("fail to sign response", "error", err, "contract_id", contractId)
+		}
+	}
+}
+
+// signingWriter buffers a successful response, up to the max size, to send it
+// along with the signature of its body. Responses larger than the max size,
+// streamed or unsuccessful are sent as is, unsigned.
+type signingWriter struct {
+	http.ResponseWriter
+	provider    common.PubKey
+	maxSize     int
+	sign        func(body []byte) (int64, []byte, error)
+	code        int
+	wroteHeader bool
+	passthrough bool // the status is sent, the rest is written as is
+	buf         bytes.Buffer
+}
+
+func (s *signingWriter) WriteHeader(code int) {
+	if s.wroteHeader {
+		return
+	}
+	// informational responses are followed by the final one
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		s.ResponseWriter.WriteHeader(code)
+		return
+	}
+	s.code = code
+	s.wroteHeader = true
+	if code < 200 || code >= 300 || s.streamed() {
+		_ = s.send()
+		return
+	}
+	if length, err := strconv.Atoi(s.Header().Get("Content-Length")); err == nil && length > s.maxSize {
+		_ = s.send()
+	}
+}
+
+// streamed returns whether the response is an event stream, which never ends
+func (s *signingWriter) streamed() bool {
+	mediaType, _, _ := mime.ParseMediaType(s.Header().Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+func (s *signingWriter) Write(b []byte) (int, error) {
+	if !s.wroteHeader {
+		s.WriteHeader(http.StatusOK)
+	}
+	if s.passthrough {
+		return s.ResponseWriter.Write(b)
+	}
+	n, _ := s.buf.Write(b)
+	if s.buf.Len() > s.maxSize {
+		if err := s.send(); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// send sends the status, along with the buffered body, the rest of the
+// response is passed through as is
+func (s *signingWriter) send() error {
+	s.passthrough = true
+	s.ResponseWriter.WriteHeader(s.code)
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	body := s.buf.Bytes()
+	s.buf = bytes.Buffer{}
+	_, err := s.ResponseWriter.Write(body)
+	return err
+}
+
+// Flush is only forwarded once the response is sent unsigned, a signed
+// response is held until its body is complete
+func (s *signingWriter) Flush() {
+	if !s.passthrough {
+		return
+	}
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close signs the buffered body and sends the response, with the provenance
+// headers. The response is sent unsigned when it can't be signed.
+func (s *signingWriter) Close() error {
+	if s.passthrough || (!s.wroteHeader && s.buf.Len() == 0) {
+		// sent already, or nothing written and left to the server
+		return nil
+	}
+	body := s.buf.Bytes()
+	height, signature, err := s.sign(body)
+	if err != nil {
+		if sendErr := s.send(); sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	header := s.Header()
+	header.Set(ProviderHeader, s.provider.String())
+	header.Set(HeightHeader, strconv.FormatInt(height, 10))
+	header.Set(SignatureHeader, hex.EncodeToString(signature))
+	return s.send()
+}
+
+// Hijack is required to proxy websockets, an upgraded connection is never
+// signed
+func (s *signingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	s.passthrough = true
+	return h.Hijack()
+}
@@ -0,0 +1,187 @@
+package sentinel
+
+import (
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	cKeys "github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/std"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// newProvenanceSigner returns a keyring signer with a provider key, and its
+// pubkey
+func newProvenanceSigner(t *testing.T) (keyringSigner, common.PubKey) {
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	kb := cKeys.NewInMemory(codec.NewProtoCodec(interfaceRegistry))
+	info, _, err := kb.NewMnemonic("provider", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+	pub, err := info.GetPubKey()
+	require.NoError(t, err)
+	provider, err := common.NewPubKeyFromCrypto(pub)
+	require.NoError(t, err)
+	return keyringSigner{kr: kb, name: "provider"}, provider
+}
+
+func TestVerifyResponse(t *testing.T) {
+	newTestConfig() // sets the bech32 prefixes
+	signer, provider := newProvenanceSigner(t)
+	body := []byte(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`)
+	signature, _, err := signer.Sign(ResponseProvenanceMessage(body, 42, 5))
+	require.NoError(t, err)
+	header := http.Header{}
+	header.Set(ProviderHeader, provider.String())
+	header.Set(HeightHeader, "42")
+	header.Set(SignatureHeader, hex.EncodeToString(signature))
+
+	require.NoError(t, VerifyResponse(provider, 5, header, body))
+
+	// tampered body
+	tampered := []byte(strings.Replace(string(body), "0x10", "0x11", 1))
+	require.ErrorContains(t, VerifyResponse(provider, 5, header, tampered), "invalid signature")
+	// another contract
+	require.ErrorContains(t, VerifyResponse(provider, 6, header, body), "invalid signature")
+	// another provider
+	require.ErrorContains(t, VerifyResponse(types.GetRandomPubKey(), 5, header, body), "response signed by provider")
+
+	// tampered height
+	header.Set(HeightHeader, "43")
+	require.ErrorContains(t, VerifyResponse(provider, 5, header, body), "invalid signature")
+	header.Set(HeightHeader, "42")
+
+	// unsigned
+	header.Del(SignatureHeader)
+	require.ErrorContains(t, VerifyResponse(provider, 5, header, body), "bad signature header")
+	header.Del(ProviderHeader)
+	require.ErrorContains(t, VerifyResponse(provider, 5, header, body), "bad provider header")
+}
+
+func TestSignResponses(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	small := `{"jsonrpc":"2.0","id":1,"result":"0x10"}`
+	large := fmt.Sprintf(`{"result":"%s"}`, strings.Repeat("0x00", 256))
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/large":
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = rw.Write([]byte(large))
+		case "/chunked":
+			// chunked, the reverse proxy flushes each write
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = rw.Write([]byte(small[:10]))
+			rw.(http.Flusher).Flush()
+			_, _ = rw.Write([]byte(small[10:]))
+		case "/events":
+			rw.Header().Set("Content-Type", "text/event-stream")
+			_, _ = rw.Write([]byte("data: 1\n\n"))
+		case "/error":
+			rw.WriteHeader(http.StatusInternalServerError)
+			_, _ = rw.Write([]byte(small))
+		default:
+			rw.Header().Set("Content-Type", "application/json")
+			_, _ = rw.Write([]byte(small))
+		}
+	}))
+	defer upstream.Close()
+
+	signer, provider := newProvenanceSigner(t)
+	testConfig := newTestConfig()
+	testConfig.ProviderPubKey = provider
+	testConfig.SignResponsesMaxBytes = 512
+	testConfig.CompressMinBytes = 8
+	testConfig.CompressContentTypes = []string{"application/json"}
+	proxy := NewProxy(testConfig)
+	proxy.Identity = signer
+	proxy.MemStore.SetHeight(42)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	contract := newTestContract(provider, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Id = 5
+	proxy.MemStore.Put(contract)
+	router := proxy.getRouter()
+
+	nonce := int64(0)
+	serve := func(path, accept string) *httptest.ResponseRecorder {
+		nonce++
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/btc-mainnet-fullnode%s?arkauth=5:%d", path, nonce), nil)
+		if len(accept) > 0 {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// the contract doesn't ask for signed responses
+	response := serve("/", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Empty(t, response.Header().Get(SignatureHeader))
+
+	contractConf := NewContractConfiguration(5, NewCORs(), nil, 0)
+	contractConf.SignResponses = true
+	require.NoError(t, proxy.ContractConfigStore.Set(contractConf))
+
+	response = serve("/", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, small, response.Body.String())
+	require.Equal(t, provider.String(), response.Header().Get(ProviderHeader))
+	require.Equal(t, "42", response.Header().Get(HeightHeader))
+	require.NoError(t, VerifyResponse(provider, 5, response.Header(), response.Body.Bytes()))
+	require.Error(t, VerifyResponse(provider, 5, response.Header(), []byte(strings.ToUpper(small))))
+
+	// a chunked response is signed once complete
+	response = serve("/chunked", "")
+	require.Equal(t, small, response.Body.String())
+	require.NoError(t, VerifyResponse(provider, 5, response.Header(), response.Body.Bytes()))
+
+	// larger than the max size, streamed or unsuccessful responses are sent
+	// unsigned
+	response = serve("/large", "")
+	require.Equal(t, large, response.Body.String())
+	require.Empty(t, response.Header().Get(SignatureHeader))
+	response = serve("/events", "")
+	require.Equal(t, "data: 1\n\n", response.Body.String())
+	require.Empty(t, response.Header().Get(SignatureHeader))
+	response = serve("/error", "")
+	require.Equal(t, http.StatusInternalServerError, response.Code)
+	require.Empty(t, response.Header().Get(SignatureHeader))
+
+	// the signature covers the decoded body of a compressed response
+	config := proxy.config().Configuration
+	config.CompressResponses = true
+	proxy.Reload(config, proxy.config().proxies)
+	response = serve("/", "gzip")
+	require.Equal(t, "gzip", response.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(response.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, small, string(body))
+	require.NoError(t, VerifyResponse(provider, 5, response.Header(), body))
+
+	// signed at the current height
+	proxy.MemStore.SetHeight(43)
+	response = serve("/", "")
+	require.Equal(t, strconv.FormatInt(43, 10), response.Header().Get(HeightHeader))
+	require.NoError(t, VerifyResponse(provider, 5, response.Header(), response.Body.Bytes()))
+
+	// signed requests are paid for
+	claim, err := proxy.ClaimStore.Get("5")
+	require.NoError(t, err)
+	require.Equal(t, nonce, claim.Nonce)
+}
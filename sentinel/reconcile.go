@@ -0,0 +1,180 @@
+package sentinel
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// ContractFetcher queries the chain for a contract by its id, an empty
+// contract is returned when the chain doesn't know it
+type ContractFetcher interface {
+	FetchContract(ctx context.Context, contractId uint64) (types.Contract, error)
+}
+
+// NewContractFetcher queries the contracts from the grpc endpoint of the
+// chain
+func NewContractFetcher(target string) (ContractFetcher, error) {
+	return dialContractSource(target)
+}
+
+func (s grpcContractSource) FetchContract(ctx context.Context, contractId uint64) (types.Contract, error) {
+	res, err := s.query.FetchContract(ctx, &types.QueryFetchContractRequest{ContractId: contractId})
+	if status.Code(err) == codes.NotFound {
+		return types.Contract{}, nil
+	}
+	if err != nil {
+		return types.Contract{}, fmt.Errorf("fail to fetch contract %d: %w", contractId, err)
+	}
+	return res.Contract, nil
+}
+
+// Discrepancy is how a claim of the claim store differs from the contract on
+// chain
+type Discrepancy string
+
+const (
+	// DiscrepancyUnclaimed is income not claimed yet, the claim is
+	// resubmitted
+	DiscrepancyUnclaimed Discrepancy = "unclaimed"
+	// DiscrepancyRejected is a claim flagged as claimed whose nonce the chain
+	// didn't settle, the claim is resubmitted
+	DiscrepancyRejected Discrepancy = "rejected"
+	// DiscrepancyMissing is a claim of a contract the chain doesn't know, it
+	// is kept as the chain may be the wrong one or lagging behind
+	DiscrepancyMissing Discrepancy = "missing"
+	// DiscrepancyOrphaned is a claim of a contract settled on chain, it can't
+	// be paid anymore and is pruned
+	DiscrepancyOrphaned Discrepancy = "orphaned"
+)
+
+// ReconcileEntry is a claim that differs from its contract on chain
+type ReconcileEntry struct {
+	Kind       Discrepancy `json:"kind"`
+	Claim      Claim       `json:"claim"`
+	ChainNonce int64       `json:"chain_nonce"` // nonce settled on chain
+	Deposit    cosmos.Int  `json:"deposit"`
+	Paid       cosmos.Int  `json:"paid"`
+	Income     cosmos.Int  `json:"income"` // owed by the nonces not settled on chain, capped by the deposit left
+}
+
+// Recoverable tells whether resubmitting the claim can still pay it
+func (e ReconcileEntry) Recoverable() bool {
+	return e.Kind == DiscrepancyUnclaimed || e.Kind == DiscrepancyRejected
+}
+
+// ReconcileReport compares the claims of the claim store with the contracts
+// on chain
+type ReconcileReport struct {
+	Checked int              `json:"checked"`
+	InSync  int              `json:"in_sync"`
+	Entries []ReconcileEntry `json:"entries"`
+}
+
+// Count returns the number of entries of the kind
+func (r ReconcileReport) Count(kind Discrepancy) int {
+	count := 0
+	for _, entry := range r.Entries {
+		if entry.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// Reconcile fetches the contract of each claim from the chain, and reports the
+// claims that differ from it
+func Reconcile(ctx context.Context, claims []Claim, chain ContractFetcher) (ReconcileReport, error) {
+	report := ReconcileReport{Entries: []ReconcileEntry{}}
+	for _, claim := range claims {
+		contract, err := chain.FetchContract(ctx, claim.ContractId)
+		if err != nil {
+			return report, err
+		}
+		report.Checked++
+		entry, ok := reconcileClaim(claim, contract)
+		if !ok {
+			report.InSync++
+			continue
+		}
+		report.Entries = append(report.Entries, entry)
+	}
+	return report, nil
+}
+
+// reconcileClaim compares a claim with its contract on chain, false when they
+// agree
+func reconcileClaim(claim Claim, contract types.Contract) (ReconcileEntry, bool) {
+	entry := ReconcileEntry{
+		Claim:   claim,
+		Deposit: cosmos.ZeroInt(),
+		Paid:    cosmos.ZeroInt(),
+		Income:  cosmos.ZeroInt(),
+	}
+	if contract.IsEmpty() {
+		entry.Kind = DiscrepancyMissing
+		return entry, true
+	}
+	entry.ChainNonce = contract.Nonce
+	if !contract.Deposit.IsNil() {
+		entry.Deposit = contract.Deposit
+	}
+	if !contract.Paid.IsNil() {
+		entry.Paid = contract.Paid
+	}
+	if claim.Nonce > contract.Nonce && contract.IsPayAsYouGo() && !contract.Rate.Amount.IsNil() {
+		entry.Income = cosmos.MinInt(contract.Rate.Amount.MulRaw(claim.Nonce-contract.Nonce), entry.Deposit.Sub(entry.Paid))
+	}
+
+	switch {
+	case contract.SettlementHeight > 0:
+		entry.Kind = DiscrepancyOrphaned
+	case claim.Nonce <= contract.Nonce:
+		return entry, false
+	case claim.Claimed:
+		entry.Kind = DiscrepancyRejected
+	default:
+		entry.Kind = DiscrepancyUnclaimed
+	}
+	return entry, true
+}
+
+// ReconcileFix is the outcome of fixing the discrepancies of a report
+type ReconcileFix struct {
+	Resubmitted int `json:"resubmitted"`
+	Failed      int `json:"failed"`
+	Pruned      int `json:"pruned"`
+}
+
+// FixReconcile resubmits the recoverable claims of the report, one at a time
+// so a claim rejected again doesn't hold back the others, and prunes the
+// orphaned ones from the claim store
+func FixReconcile(report ReconcileReport, store *ClaimStore, chain ChainClient) (ReconcileFix, error) {
+	var fix ReconcileFix
+	for _, entry := range report.Entries {
+		switch {
+		case entry.Recoverable():
+			claim := entry.Claim
+			if err := chain.ClaimContractIncome(claim); err != nil {
+				fix.Failed++
+				continue
+			}
+			fix.Resubmitted++
+			claim.Claimed = true
+			if err := store.Set(claim); err != nil {
+				return fix, fmt.Errorf("fail to set claimed: %w", err)
+			}
+		case entry.Kind == DiscrepancyOrphaned:
+			if err := store.Remove(entry.Claim.Key()); err != nil {
+				return fix, fmt.Errorf("fail to prune claim: %w", err)
+			}
+			fix.Pruned++
+		}
+	}
+	return fix, nil
+}
@@ -0,0 +1,140 @@
+package sentinel
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// contractQueryServer answers the contract queries of the chain with the
+// contracts it is given
+type contractQueryServer struct {
+	types.UnimplementedQueryServer
+	contracts map[uint64]types.Contract
+	fail      map[uint64]bool
+}
+
+func (s contractQueryServer) FetchContract(_ context.Context, req *types.QueryFetchContractRequest) (*types.QueryFetchContractResponse, error) {
+	if s.fail[req.ContractId] {
+		return nil, status.Error(codes.Unavailable, "unavailable")
+	}
+	contract, ok := s.contracts[req.ContractId]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return &types.QueryFetchContractResponse{Contract: contract}, nil
+}
+
+func TestReconcile(t *testing.T) {
+	provider := types.GetRandomPubKey()
+	newContract := func(id uint64, nonce int64, deposit, paid int64) types.Contract {
+		contract := types.NewContract(provider, common.BTCService, types.GetRandomPubKey())
+		contract.Id = id
+		contract.Type = types.ContractType_PAY_AS_YOU_GO
+		contract.Rate = cosmos.NewInt64Coin("uarkeo", 2)
+		contract.Height = 10
+		contract.Duration = 100
+		contract.Nonce = nonce
+		contract.Deposit = cosmos.NewInt(deposit)
+		contract.Paid = cosmos.NewInt(paid)
+		return contract
+	}
+	settled := newContract(4, 3, 100, 6)
+	settled.SettlementHeight = 50
+	queries := contractQueryServer{
+		contracts: map[uint64]types.Contract{
+			1: newContract(1, 5, 100, 10),
+			2: newContract(2, 5, 20, 16),
+			4: settled,
+			5: newContract(5, 7, 100, 14),
+		},
+		fail: map[uint64]bool{},
+	}
+	server := grpc.NewServer(grpc.ForceServerCodec(codec.NewProtoCodec(nil).GRPCCodec()))
+	types.RegisterQueryServer(server, queries)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+	fetcher, err := NewContractFetcher(listener.Addr().String())
+	require.NoError(t, err)
+
+	store, err := NewClaimStore("")
+	require.NoError(t, err)
+	defer store.Close()
+	claim := func(id uint64, nonce int64, claimed bool) Claim {
+		c := NewClaim(id, types.GetRandomPubKey(), nonce, "sig")
+		c.Claimed = claimed
+		require.NoError(t, store.Set(c))
+		return c
+	}
+	unclaimed := claim(1, 8, false)
+	rejected := claim(2, 9, true)
+	missing := claim(3, 2, false)
+	orphaned := claim(4, 3, true)
+	claim(5, 7, true) // in sync
+
+	ctx := context.Background()
+	report, err := Reconcile(ctx, store.List(), fetcher)
+	require.NoError(t, err)
+	require.Equal(t, 5, report.Checked)
+	require.Equal(t, 1, report.InSync)
+	require.Len(t, report.Entries, 4)
+	entries := make(map[uint64]ReconcileEntry, len(report.Entries))
+	for _, entry := range report.Entries {
+		entries[entry.Claim.ContractId] = entry
+	}
+
+	// income owed by the nonces not settled yet
+	require.Equal(t, DiscrepancyUnclaimed, entries[1].Kind)
+	require.Equal(t, unclaimed, entries[1].Claim)
+	require.Equal(t, int64(5), entries[1].ChainNonce)
+	require.Equal(t, cosmos.NewInt(6), entries[1].Income)
+	require.True(t, entries[1].Recoverable())
+
+	// capped by the deposit left
+	require.Equal(t, DiscrepancyRejected, entries[2].Kind)
+	require.Equal(t, rejected, entries[2].Claim)
+	require.Equal(t, cosmos.NewInt(4), entries[2].Income)
+	require.True(t, entries[2].Recoverable())
+
+	require.Equal(t, DiscrepancyMissing, entries[3].Kind)
+	require.Equal(t, missing, entries[3].Claim)
+	require.False(t, entries[3].Recoverable())
+
+	require.Equal(t, DiscrepancyOrphaned, entries[4].Kind)
+	require.Equal(t, orphaned, entries[4].Claim)
+	require.Equal(t, cosmos.NewInt(100), entries[4].Deposit)
+	require.Equal(t, cosmos.NewInt(6), entries[4].Paid)
+	require.False(t, entries[4].Recoverable())
+	require.Equal(t, 1, report.Count(DiscrepancyOrphaned))
+
+	// the recoverable claims are submitted again, the orphaned ones pruned
+	chain := &mockChainClient{fail: map[uint64]bool{2: true}}
+	fix, err := FixReconcile(report, store, chain)
+	require.NoError(t, err)
+	require.Equal(t, ReconcileFix{Resubmitted: 1, Failed: 1, Pruned: 1}, fix)
+	require.Len(t, chain.claims, 1)
+	require.Equal(t, unclaimed, chain.claims[0])
+	stored, err := store.Get(unclaimed.Key())
+	require.NoError(t, err)
+	require.True(t, stored.Claimed)
+	require.False(t, store.Has(orphaned.Key()))
+	require.True(t, store.Has(missing.Key()))
+	require.True(t, store.Has(rejected.Key()))
+
+	// a failed query fails the whole report
+	queries.fail[5] = true
+	_, err = Reconcile(ctx, store.List(), fetcher)
+	require.Error(t, err)
+}
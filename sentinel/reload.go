@@ -0,0 +1,157 @@
+package sentinel
+
+import (
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+)
+
+// configSnapshot is the configuration read by the request handlers. It is
+// never modified once stored, a reload swaps it as a whole.
+type configSnapshot struct {
+	conf.Configuration
+	proxies map[string][]*url.URL // upstreams of each service, in the order they are tried
+}
+
+// fields of the configuration that can be changed without a restart, the
+// others (port, provider pubkey, stores, etc) keep their value on reload
+var reloadableConfig = map[string]bool{
+	"FreeTierEnabled":          true,
+	"FreeTierRateLimit":        true,
+	"VerifyRateLimit":          true,
+	"FreeTierRemainingHeader":  true,
+	"FreeTierUpgradeThreshold": true,
+	"FreeTierClientRateLimit":  true,
+	"FreeTierMaxBodyBytes":     true,
+	"ServiceFreeTiers":         true,
+	"SubscriptionQuotaPerRate": true,
+	"MaxNonceIncrement":        true,
+	"NonceWindow":              true,
+	"SessionMaxDurationSec":    true,
+	"SessionMaxRequests":       true,
+	"DefaultPerUserRateLimit":  true,
+	"DefaultPerUserBurstSize":  true,
+	"UpstreamBalancing":        true,
+	"CompressResponses":        true,
+	"CompressMinBytes":         true,
+	"CompressContentTypes":     true,
+	"SignResponsesMaxBytes":    true,
+	"UpstreamHealthPath":       true,
+	"StrictAuth":               true,
+	"TrustedProxyHops":         true,
+	"SignatureDomain":          true,
+}
+
+var reloadLock sync.Mutex
+
+// config returns the current configuration snapshot, reloadable fields must
+// be read from it rather than from Config, which never changes
+func (p Proxy) config() *configSnapshot {
+	return p.snapshot.Load()
+}
+
+// Reload swaps the configuration and the upstream urls of the services. A
+// changed field that can't be reloaded is logged and keeps its current value.
+func (p Proxy) Reload(config conf.Configuration, proxies map[string][]*url.URL) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	current := p.config()
+	next := current.Configuration
+	currentValue := reflect.ValueOf(current.Configuration)
+	newValue := reflect.ValueOf(config)
+	nextValue := reflect.ValueOf(&next).Elem()
+	for i := 0; i < newValue.NumField(); i++ {
+		field := newValue.Type().Field(i)
+		if reflect.DeepEqual(currentValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			continue
+		}
+		if !reloadableConfig[field.Name] {
+			p.logger.Error("configuration requires a restart, keeping current value", "field", field.Tag.Get("json"))
+			continue
+		}
+		nextValue.Field(i).Set(newValue.Field(i))
+		p.logger.Info("configuration reloaded", "field", field.Tag.Get("json"))
+	}
+
+	for service, upstreams := range proxies {
+		if old, ok := current.proxies[service]; !ok || !reflect.DeepEqual(old, upstreams) {
+			p.logger.Info("service url reloaded", "service", service)
+		}
+	}
+	for service := range current.proxies {
+		if _, ok := proxies[service]; !ok {
+			p.logger.Info("service removed", "service", service)
+		}
+	}
+
+	p.snapshot.Store(&configSnapshot{Configuration: next, proxies: proxies})
+}
+
+// reload reads the configuration again, it is kept as is when invalid
+func (p Proxy) reload() {
+	config, err := conf.ReloadConfiguration(p.Config.ConfigFile)
+	if err != nil {
+		p.logger.Error("fail to reload configuration", "error", err)
+		return
+	}
+	p.Reload(config, loadProxies())
+}
+
+// reloadOnChange reloads the configuration on SIGHUP, and whenever the config
+// file changes
+func (p Proxy) reloadOnChange() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	configFile := filepath.Clean(p.Config.ConfigFile)
+	if len(p.Config.ConfigFile) > 0 {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			p.logger.Error("fail to watch config file", "error", err)
+		} else {
+			defer watcher.Close()
+			// watch the directory, editors and config maps replace the file
+			// rather than writing to it
+			if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+				p.logger.Error("fail to watch config file", "error", err, "file", configFile)
+			}
+			events = watcher.Events
+			errs = watcher.Errors
+		}
+	}
+
+	for {
+		select {
+		case <-hup:
+			p.logger.Info("SIGHUP received, reloading configuration")
+			p.reload()
+		case evt, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if filepath.Clean(evt.Name) != configFile || !(evt.Has(fsnotify.Write) || evt.Has(fsnotify.Create)) {
+				continue
+			}
+			p.logger.Info("config file changed, reloading configuration", "file", configFile)
+			p.reload()
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			p.logger.Error("fail to watch config file", "error", err)
+		}
+	}
+}
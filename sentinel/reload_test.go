@@ -0,0 +1,93 @@
+package sentinel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// setServiceURL points the service to the given upstreams
+func setServiceURL(p Proxy, service string, uris ...*url.URL) {
+	current := p.config()
+	proxies := make(map[string][]*url.URL, len(current.proxies)+1)
+	for name, u := range current.proxies {
+		proxies[name] = u
+	}
+	proxies[service] = uris
+	p.snapshot.Store(&configSnapshot{Configuration: current.Configuration, proxies: proxies})
+}
+
+func TestReload(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+
+	newUpstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			_, _ = rw.Write([]byte(name))
+		}))
+	}
+	first := newUpstream("first")
+	defer first.Close()
+	second := newUpstream("second")
+	defer second.Close()
+
+	testConfig := newTestConfig()
+	testConfig.FreeTierRateLimit = 100
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(first.URL))
+	router := proxy.getRouter()
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/", nil)
+		req.RemoteAddr = remoteAddr
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	for i := 0; i < 5; i++ {
+		response := serve("10.0.0.1:1000")
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "first", response.Body.String())
+	}
+
+	// reload while requests are in flight
+	reloaded := testConfig
+	reloaded.FreeTierRateLimit = 2
+	reloaded.Port = "9999"
+	reloaded.ProviderPubKey = types.GetRandomPubKey()
+	proxies := loadProxies()
+	proxies[common.BTCService.String()] = []*url.URL{common.MustParseURL(second.URL)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve("10.0.0.2:1000")
+		}()
+	}
+	proxy.Reload(reloaded, proxies)
+	wg.Wait()
+
+	// reloadable fields are swapped, the others keep their value
+	require.Equal(t, 2, proxy.config().FreeTierRateLimit)
+	require.Equal(t, testConfig.Port, proxy.config().Port)
+	require.Equal(t, testConfig.ProviderPubKey, proxy.config().ProviderPubKey)
+
+	// the new limit applies to clients already seen, and to new ones
+	for _, remoteAddr := range []string{"10.0.0.1:1000", "10.0.0.3:1000"} {
+		for i := 0; i < 2; i++ {
+			response := serve(remoteAddr)
+			require.Equal(t, http.StatusOK, response.Code)
+			require.Equal(t, "second", response.Body.String())
+		}
+		require.Equal(t, http.StatusTooManyRequests, serve(remoteAddr).Code)
+	}
+}
@@ -0,0 +1,162 @@
+package sentinel
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/grpc/tmservice"
+	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+const (
+	resyncTimeout    = 30 * time.Second
+	contractPageSize = 200
+)
+
+type EventStreamStatus = api.EventStreamStatus
+
+// ContractSource queries the chain for the contracts of a provider, to resync
+// the memstore when chain events may have been missed
+type ContractSource interface {
+	LatestHeight(ctx context.Context) (int64, error)
+	ProviderContracts(ctx context.Context, provider common.PubKey) ([]types.Contract, error)
+}
+
+type grpcContractSource struct {
+	query types.QueryClient
+	tm    tmservice.ServiceClient
+}
+
+// NewContractSource queries the contracts from the grpc endpoint of the chain
+func NewContractSource(target string) (ContractSource, error) {
+	return dialContractSource(target)
+}
+
+func dialContractSource(target string) (grpcContractSource, error) {
+	conn, err := grpc.Dial(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec.NewProtoCodec(nil).GRPCCodec())),
+	)
+	if err != nil {
+		return grpcContractSource{}, fmt.Errorf("fail to dial %s: %w", target, err)
+	}
+	return grpcContractSource{
+		query: types.NewQueryClient(conn),
+		tm:    tmservice.NewServiceClient(conn),
+	}, nil
+}
+
+func (s grpcContractSource) LatestHeight(ctx context.Context) (int64, error) {
+	res, err := s.tm.GetLatestBlock(ctx, &tmservice.GetLatestBlockRequest{})
+	if err != nil {
+		return 0, fmt.Errorf("fail to get latest block: %w", err)
+	}
+	if res.Block == nil {
+		return 0, fmt.Errorf("latest block is empty")
+	}
+	return res.Block.Header.Height, nil
+}
+
+// ProviderContracts pages through all the contracts of the chain, there is no
+// query of the contracts of a provider
+func (s grpcContractSource) ProviderContracts(ctx context.Context, provider common.PubKey) ([]types.Contract, error) {
+	var contracts []types.Contract
+	var key []byte
+	for {
+		res, err := s.query.ContractAll(ctx, &types.QueryAllContractRequest{
+			Pagination: &query.PageRequest{Key: key, Limit: contractPageSize},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("fail to list contracts: %w", err)
+		}
+		for _, contract := range res.Contract {
+			if contract.Provider.Equals(provider) {
+				contracts = append(contracts, contract)
+			}
+		}
+		if res.Pagination == nil || len(res.Pagination.NextKey) == 0 {
+			return contracts, nil
+		}
+		key = res.Pagination.NextKey
+	}
+}
+
+// eventStreamStats counts the disruptions of the event stream, shown by the
+// status endpoint
+type eventStreamStats struct {
+	connected    atomic.Bool
+	reconnects   atomic.Uint64
+	resyncs      atomic.Uint64
+	blockGaps    atomic.Uint64
+	missedBlocks atomic.Int64
+}
+
+func (s *eventStreamStats) Status() EventStreamStatus {
+	return EventStreamStatus{
+		Connected:    s.connected.Load(),
+		Reconnects:   s.reconnects.Load(),
+		Resyncs:      s.resyncs.Load(),
+		BlockGaps:    s.blockGaps.Load(),
+		MissedBlocks: s.missedBlocks.Load(),
+	}
+}
+
+// checkBlockGap reports a jump from the previous height to the new one larger
+// than the block gap threshold, events of the blocks in between were missed
+func (p Proxy) checkBlockGap(previous, height int64) bool {
+	if previous <= 0 || height-previous <= p.Config.BlockGapThreshold {
+		return false
+	}
+	missed := height - previous - 1
+	p.eventStream.blockGaps.Add(1)
+	p.eventStream.missedBlocks.Add(missed)
+	p.logger.Error("gap in the event stream", "from", previous, "to", height, "missed_blocks", missed)
+	return true
+}
+
+// resync reconciles the memstore with the contracts open on chain, and fast
+// forwards its height. Contracts opened while no events were received get a
+// default configuration, as they would from their open event.
+func (p Proxy) resync(source ContractSource) error {
+	if source == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resyncTimeout)
+	defer cancel()
+
+	height, err := source.LatestHeight(ctx)
+	if err != nil {
+		return err
+	}
+	contracts, err := source.ProviderContracts(ctx, p.Config.ProviderPubKey)
+	if err != nil {
+		return err
+	}
+
+	p.checkBlockGap(p.MemStore.GetHeight(), height)
+	added, dropped := p.MemStore.Reconcile(p.Config.ProviderPubKey, contracts, height)
+	for _, id := range added {
+		if p.ContractConfigStore.Has(id) {
+			continue
+		}
+		if err := p.ContractConfigStore.Set(p.CreateDefaultContractConfig(id)); err != nil {
+			p.logger.Error("fail to save default contract config", "error", err, "id", id)
+		}
+	}
+	for _, id := range dropped {
+		p.SpendTracker.Remove(id)
+		p.DailySpendTracker.Remove(id)
+	}
+	p.eventStream.resyncs.Add(1)
+	p.logger.Info("resynced contracts", "height", height, "added", len(added), "dropped", len(dropped))
+	return nil
+}
@@ -0,0 +1,80 @@
+package sentinel
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+)
+
+// rewritePath applies the path rules of the service to the path requested
+// below the service name: the strip prefix is removed, then the add prefix is
+// added, then the regex matches are replaced. The query is left as is.
+func rewritePath(incoming url.URL, pulledFromPath bool, rewrite conf.ServiceRewrite) url.URL {
+	if len(rewrite.StripPrefix) == 0 && len(rewrite.AddPrefix) == 0 && len(rewrite.RegexMatch) == 0 {
+		return incoming
+	}
+	service, rest := "", incoming.Path
+	if pulledFromPath {
+		parts := strings.SplitN(incoming.Path, "/", 3)
+		service, rest = "/"+parts[1], "/"
+		if len(parts) > 2 {
+			rest += parts[2]
+		}
+	}
+
+	strip := "/" + strings.Trim(rewrite.StripPrefix, "/")
+	if strip != "/" && (rest == strip || strings.HasPrefix(rest, strip+"/")) {
+		rest = "/" + strings.TrimPrefix(rest[len(strip):], "/")
+	}
+	if len(rewrite.AddPrefix) > 0 {
+		trailing := strings.HasSuffix(rest, "/")
+		rest = path.Join("/", rewrite.AddPrefix, rest)
+		if trailing && rest != "/" {
+			rest += "/"
+		}
+	}
+
+	if regex := rewrite.PathRegexp(); regex != nil {
+		rest = regex.ReplaceAllString(rest, rewrite.RegexReplace)
+		if !strings.HasPrefix(rest, "/") {
+			rest = "/" + rest
+		}
+	}
+
+	incoming.Path = service + rest
+	incoming.RawPath = ""
+	return incoming
+}
+
+// rewriteHeaders returns a copy of the request with the static headers of the
+// service set, the headers of the request itself are left as is so the
+// injected secrets are never logged
+func rewriteHeaders(r *http.Request, rewrite conf.ServiceRewrite) *http.Request {
+	if len(rewrite.Headers) == 0 {
+		return r
+	}
+	r = r.Clone(r.Context())
+	for name, value := range rewrite.Headers {
+		r.Header.Set(name, value)
+	}
+	return r
+}
+
+// forwardClientIP makes the proxy send the client ip as the only
+// X-Forwarded-For of the upstream request. The inbound forwarding headers,
+// which the client can spoof, are dropped.
+func forwardClientIP(proxy *httputil.ReverseProxy, clientIP string) {
+	director := proxy.Director
+	proxy.Director = nil
+	proxy.Rewrite = func(pr *httputil.ProxyRequest) {
+		// the X-Forwarded headers are already removed from the outgoing
+		// request when rewriting
+		director(pr.Out)
+		pr.Out.Header.Del(xRealIPName)
+		pr.Out.Header.Set(forwardHeaderName, clientIP)
+	}
+}
@@ -0,0 +1,138 @@
+package sentinel
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func TestRewritePath(t *testing.T) {
+	rewrite := func(path string, pulledFromPath bool, rule conf.ServiceRewrite) string {
+		incoming := url.URL{Path: path}
+		rewritten := rewritePath(incoming, pulledFromPath, rule)
+		return rewritten.Path
+	}
+	replace := conf.ServiceRewrite{StripPrefix: "/eth", AddPrefix: "/v1/mainnet"}
+
+	require.Equal(t, "/svc/v1/mainnet/", rewrite("/svc/eth/", true, replace))
+	require.Equal(t, "/svc/v1/mainnet/block", rewrite("/svc/eth/block", true, replace))
+	require.Equal(t, "/v1/mainnet/block", rewrite("/eth/block", false, replace))
+	// the prefix is only stripped on a path boundary
+	require.Equal(t, "/svc/v1/mainnet/ethereum", rewrite("/svc/ethereum", true, replace))
+	require.Equal(t, "/svc/", rewrite("/svc/eth", true, conf.ServiceRewrite{StripPrefix: "eth/"}))
+	require.Equal(t, "/svc/api/", rewrite("/svc", true, conf.ServiceRewrite{AddPrefix: "api"}))
+	require.Equal(t, "/svc/block", rewrite("/svc/block", true, conf.ServiceRewrite{}))
+
+	// regex replaced once the prefixes are applied
+	regex := conf.ServiceRewrite{StripPrefix: "/eth", RegexMatch: `^/block/(\d+)$`, RegexReplace: "/api/v1/blocks/$1"}
+	require.Equal(t, "/svc/api/v1/blocks/12", rewrite("/svc/eth/block/12", true, regex))
+	require.Equal(t, "/api/v1/blocks/12", rewrite("/eth/block/12", false, regex))
+	require.Equal(t, "/svc/block/latest", rewrite("/svc/eth/block/latest", true, regex))
+	require.Equal(t, "/svc/v2/rpc", rewrite("/svc/v1/rpc", true, conf.ServiceRewrite{RegexMatch: "^/v1/", RegexReplace: "v2/"}))
+	// a bad regex is ignored
+	require.Equal(t, "/svc/block", rewrite("/svc/block", true, conf.ServiceRewrite{RegexMatch: "("}))
+}
+
+func TestServiceRewrite(t *testing.T) {
+	var seen *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		seen = req
+	}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	service := common.ETHService.String()
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	proxy.Config.ServiceRewrites = conf.ServiceRewrites{}
+	setServiceURL(proxy, service, common.MustParseURL(upstream.URL+"/base"))
+	router := proxy.getRouter()
+
+	serve := func(rule conf.ServiceRewrite, path string, header http.Header) *http.Request {
+		proxy.Config.ServiceRewrites[service] = rule
+		seen = nil
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.NotNil(t, seen)
+		// the request of the client is left as is
+		require.Empty(t, req.Header.Get("X-Api-Key"))
+		return seen
+	}
+	spoofed := http.Header{forwardHeaderName: {"1.2.3.4"}}
+
+	// no rule, the forwarding header is appended to
+	got := serve(conf.ServiceRewrite{}, "/"+service+"/eth/block?id=1", spoofed)
+	require.Equal(t, "/base/eth/block", got.URL.Path)
+	require.Equal(t, "id=1", got.URL.RawQuery)
+	require.Equal(t, "1.2.3.4, 10.0.0.1", got.Header.Get(forwardHeaderName))
+
+	// replaced path prefix
+	got = serve(conf.ServiceRewrite{StripPrefix: "/eth", AddPrefix: "/v1/mainnet"}, "/"+service+"/eth/block?id=1", nil)
+	require.Equal(t, "/base/v1/mainnet/block", got.URL.Path)
+	require.Equal(t, "id=1", got.URL.RawQuery)
+
+	// regex rewrite, the query is kept
+	got = serve(conf.ServiceRewrite{StripPrefix: "/eth", RegexMatch: `^/block/(\d+)$`, RegexReplace: "/api/v1/blocks/$1"}, "/"+service+"/eth/block/12?id=1&full=true", nil)
+	require.Equal(t, "/base/api/v1/blocks/12", got.URL.Path)
+	require.Equal(t, "id=1&full=true", got.URL.RawQuery)
+
+	// injected headers, the service picked by header
+	headers := map[string]string{"X-Api-Key": "secret", "Accept": "application/json"}
+	got = serve(conf.ServiceRewrite{AddPrefix: "/v1", Headers: headers}, "/block", http.Header{ServiceHeader: {service}, "Accept": {"text/html"}})
+	require.Equal(t, "/v1/block", got.URL.Path)
+	require.Equal(t, "secret", got.Header.Get("X-Api-Key"))
+	require.Equal(t, "application/json", got.Header.Get("Accept"))
+
+	// the client ip is the only forwarded address
+	got = serve(conf.ServiceRewrite{ForwardClientIP: true, Headers: headers}, "/"+service+"/", nil)
+	require.Equal(t, []string{"10.0.0.1"}, got.Header.Values(forwardHeaderName))
+	require.Equal(t, "secret", got.Header.Get("X-Api-Key"))
+
+	// behind a trusted proxy, the spoofed entries of the chain are dropped
+	config := testConfig
+	config.TrustedProxyHops = 1
+	proxy.Reload(config, proxy.config().proxies)
+	got = serve(conf.ServiceRewrite{ForwardClientIP: true}, "/"+service+"/", http.Header{forwardHeaderName: {"1.2.3.4, 10.0.0.9"}})
+	require.Equal(t, []string{"10.0.0.9"}, got.Header.Values(forwardHeaderName))
+
+	// the rewrite happens once the request is paid for
+	contract := newTestContract(testConfig.ProviderPubKey, common.ETHService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Id = 7
+	proxy.MemStore.Put(contract)
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s/eth/?arkauth=7:1", service), nil)
+	proxy.Config.ServiceRewrites[service] = conf.ServiceRewrite{StripPrefix: "/eth", Headers: headers}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+	require.Equal(t, "/base", seen.URL.Path)
+	nonce, err := proxy.paidNonce(contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), nonce)
+
+	// the contract is checked against the service of the original path
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/%s/eth/block/12?arkauth=7:2&id=1", service), nil)
+	proxy.Config.ServiceRewrites[service] = conf.ServiceRewrite{RegexMatch: `^/eth/block/(\d+)$`, RegexReplace: "/btc-mainnet-fullnode/$1"}
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "paid", response.Header().Get("tier"))
+	require.Equal(t, "/base/btc-mainnet-fullnode/12", seen.URL.Path)
+	require.Equal(t, "arkauth=7:2&id=1", seen.URL.RawQuery)
+}
@@ -1,9 +1,30 @@
 package sentinel
 
+import "github.com/arkeonetwork/arkeo/sentinel/api"
+
+// the public routes are served both unversioned and under api.V1Prefix
 const (
-	RoutesMetaData       = "/metadata.json"
-	RoutesActiveContract = "/active-contract/{service}/{spender}"
-	RoutesClaim          = "/claim/{id}"
-	RoutesOpenClaims     = "/open-claims"
-	RouteManage          = "/manage/contract/{id}"
+	RoutesMetaData          = api.PathMetadata
+	RoutesActiveContract    = api.PathActiveContract
+	RoutesClaim             = api.PathClaim
+	RoutesQueryContract     = api.PathContract
+	RoutesOpenClaims        = api.PathOpenClaims
+	RoutesUsage             = api.PathUsage
+	RoutesValidate          = api.PathValidate
+	RoutesVerify            = api.PathVerify
+	RoutesSession           = api.PathSession
+	RoutesClaims            = "/claims"
+	RoutesContractsMetadata = "/contracts/metadata"
+	RoutesDrainClaims       = "/admin/drain-claims"
+	RoutesAdminContracts    = "/admin/contracts"
+	RoutesAdminContract     = "/admin/contracts/{id}"
+	RoutesAdminClaim        = "/admin/contracts/{id}/claim"
+	RoutesAdminBans         = "/admin/bans"
+	RoutesAdminBan          = "/admin/bans/{ip}"
+	RoutesAdminConfig       = "/admin/config"
+	RouteManage             = "/manage/contract/{id}"
+	RoutesHealth            = api.PathHealth
+	RoutesReady             = api.PathReady
+	RoutesStatus            = api.PathStatus
+	RoutesStatusContract    = api.PathStatusContract
 )
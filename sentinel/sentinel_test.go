@@ -1,16 +1,23 @@
 package sentinel
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	cKeys "github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/std"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/stretchr/testify/require"
 )
@@ -26,6 +33,27 @@ func setUpTest(t *testing.T, pk1, pk2 common.PubKey) *httptest.Server {
 	}))
 }
 
+// signAdmin sets the admin auth of the request, signed with the key of the
+// keyring
+func signAdmin(t *testing.T, kb cKeys.Keyring, key string, req *http.Request, body []byte, timestamp int64) {
+	sig, _, err := kb.Sign(key, []byte(GenerateAdminMessageToSign(req.Method, req.URL.RequestURI(), body, timestamp)))
+	require.NoError(t, err)
+	req.Header.Set(QueryAdmin, fmt.Sprintf("%d:%s", timestamp, hex.EncodeToString(sig)))
+}
+
+// newTestContract returns a pay-as-you-go contract open from block 10 for 100
+// blocks, with a deposit of 100 at a rate of 1 and 100 queries per minute
+func newTestContract(provider common.PubKey, service common.Service, client common.PubKey) types.Contract {
+	contract := types.NewContract(provider, service, client)
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Rate = cosmos.NewInt64Coin("uarkeo", 1)
+	contract.Deposit = cosmos.NewInt(100)
+	contract.Height = 10
+	contract.Duration = 100
+	contract.QueriesPerMinute = 100
+	return contract
+}
+
 func TestHandleActiveContract(t *testing.T) {
 	testConfig := newTestConfig()
 	proxy := NewProxy(testConfig)
@@ -50,7 +78,7 @@ func TestHandleActiveContract(t *testing.T) {
 	require.NoError(t, err)
 	server := setUpTest(t, testConfig.ProviderPubKey, inputContract.GetSpender())
 	defer server.Close()
-	proxy.proxies["arkeo-mainnet-fullnode"] = common.MustParseURL(server.URL)
+	setServiceURL(proxy, "arkeo-mainnet-fullnode", common.MustParseURL(server.URL))
 
 	resultEvent := makeResultEvent(sdkEvt, openEvent.Height)
 	proxy.handleOpenContractEvent(resultEvent)
@@ -216,3 +244,280 @@ func TestHandleOpenClaims(t *testing.T) {
 	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &openClaims))
 	require.Equal(t, 2, len(openClaims))
 }
+
+func TestClaimsIncome(t *testing.T) {
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	proxy.MemStore.SetHeight(110)
+
+	newContract := func(id uint64, contractType types.ContractType, rate int64) types.Contract {
+		contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+		contract.Type = contractType
+		contract.Rate = cosmos.NewInt64Coin("uarkeo", rate)
+		contract.Deposit = cosmos.NewInt(1000)
+		contract.Height = 100
+		contract.Duration = 100
+		contract.Id = id
+		proxy.MemStore.Put(contract)
+		return contract
+	}
+	c1 := newContract(1, types.ContractType_PAY_AS_YOU_GO, 3)
+	c2 := newContract(2, types.ContractType_PAY_AS_YOU_GO, 5)
+	c3 := newContract(3, types.ContractType_PAY_AS_YOU_GO, 7)
+	c4 := newContract(4, types.ContractType_SUBSCRIPTION, 11)
+
+	claimed := NewClaim(c3.Id, c3.Client, 6, "sig3")
+	claimed.Claimed = true
+	require.NoError(t, proxy.ClaimStore.Batch([]Claim{
+		NewClaim(c1.Id, c1.Client, 10, "sig1"),
+		NewClaim(c2.Id, c2.Client, 4, "sig2"),
+		claimed,
+		NewClaim(c4.Id, c4.Client, 9, "sig4"),
+	}))
+
+	result := proxy.claimsIncome()
+	require.Len(t, result.Claims, 4)
+	incomes := make(map[uint64]ClaimIncome)
+	for _, item := range result.Claims {
+		incomes[item.ContractId] = item
+	}
+	require.Equal(t, int64(30), incomes[c1.Id].Income.Amount.Int64())
+	require.Equal(t, int64(10), incomes[c1.Id].Nonce)
+	require.Equal(t, "sig1", incomes[c1.Id].Signature)
+	require.Equal(t, int64(20), incomes[c2.Id].Income.Amount.Int64())
+	require.Equal(t, int64(42), incomes[c3.Id].Income.Amount.Int64())
+	require.True(t, incomes[c3.Id].Claimed)
+	require.True(t, incomes[c4.Id].Income.Amount.IsZero()) // subscriptions don't earn per query
+
+	// claimed income isn't part of the total
+	require.Equal(t, int64(50), result.Total.AmountOf("uarkeo").Int64())
+}
+
+func TestHandleClaims(t *testing.T) {
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	cdc := codec.NewProtoCodec(interfaceRegistry)
+	kb := cKeys.NewInMemory(cdc)
+	info, _, err := kb.NewMnemonic("provider", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+	pub, err := info.GetPubKey()
+	require.NoError(t, err)
+
+	testConfig := newTestConfig()
+	testConfig.ProviderPubKey, err = common.NewPubKeyFromCrypto(pub)
+	require.NoError(t, err)
+	proxy := NewProxy(testConfig)
+	router := proxy.getRouter()
+
+	contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Rate = cosmos.NewInt64Coin("uarkeo", 2)
+	contract.Height = 100
+	contract.Duration = 100
+	contract.Id = 8
+	proxy.MemStore.Put(contract)
+	require.NoError(t, proxy.ClaimStore.Set(NewClaim(contract.Id, contract.Client, 21, "sig")))
+
+	// missing auth
+	req, err := http.NewRequest(http.MethodGet, RoutesClaims, nil)
+	require.NoError(t, err)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+
+	// signed by someone else
+	other, _, err := kb.NewMnemonic("other", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+	timestamp := time.Now().Unix()
+	signAdmin(t, kb, other.Name, req, nil, timestamp)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+
+	// signed by the provider
+	signAdmin(t, kb, "provider", req, nil, timestamp)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusOK, response.Code)
+	var result ClaimsIncome
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	require.Len(t, result.Claims, 1)
+	require.Equal(t, int64(42), result.Claims[0].Income.Amount.Int64())
+	require.Equal(t, int64(42), result.Total.AmountOf("uarkeo").Int64())
+
+	// replayed
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	require.Contains(t, response.Body.String(), "signature already used")
+
+	// signed for another route
+	drain, err := http.NewRequest(http.MethodPost, RoutesDrainClaims, nil)
+	require.NoError(t, err)
+	signAdmin(t, kb, "provider", req, nil, timestamp+1)
+	drain.Header.Set(QueryAdmin, req.Header.Get(QueryAdmin))
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, drain)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	require.Contains(t, response.Body.String(), "bad admin auth")
+
+	// signed for it in the same second as the claims request, it gets past the
+	// auth, claim submission isn't configured
+	signAdmin(t, kb, "provider", drain, nil, timestamp)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, drain)
+	require.Equal(t, http.StatusServiceUnavailable, response.Code)
+
+	// stale timestamp
+	timestamp = time.Now().Add(-time.Hour).Unix()
+	signAdmin(t, kb, "provider", req, nil, timestamp)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestValidateContractMetadata(t *testing.T) {
+	require.NoError(t, ValidateContractMetadata(nil))
+	require.NoError(t, ValidateContractMetadata(map[string]string{"customer_id": "c-42"}))
+
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxContractMetadataKeys; i++ {
+		tooMany[fmt.Sprintf("key%d", i)] = "value"
+	}
+	require.Error(t, ValidateContractMetadata(tooMany))
+	require.Error(t, ValidateContractMetadata(map[string]string{"": "value"}))
+	require.Error(t, ValidateContractMetadata(map[string]string{strings.Repeat("k", MaxContractMetadataKeyLength+1): "value"}))
+	require.Error(t, ValidateContractMetadata(map[string]string{"key": strings.Repeat("v", MaxContractMetadataValueLength+1)}))
+	require.Error(t, ValidateContractMetadata(map[string]string{"key": "\xff\xfe"}))
+}
+
+func TestContractMetadata(t *testing.T) {
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	cdc := codec.NewProtoCodec(interfaceRegistry)
+	kb := cKeys.NewInMemory(cdc)
+	info, _, err := kb.NewMnemonic("client", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+	pub, err := info.GetPubKey()
+	require.NoError(t, err)
+
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	router := proxy.getRouter()
+
+	client, err := common.NewPubKeyFromCrypto(pub)
+	require.NoError(t, err)
+	contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, client)
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Rate = cosmos.NewInt64Coin("uarkeo", 2)
+	contract.Height = 100
+	contract.Duration = 100
+	contract.Id = 8
+	proxy.MemStore.Put(contract)
+	require.NoError(t, proxy.ClaimStore.Set(NewClaim(contract.Id, contract.Client, 21, "sig")))
+
+	timestamp := time.Now().Unix()
+	postConfig := func(body string) int {
+		timestamp++
+		sig, _, err := kb.Sign("client", []byte(fmt.Sprintf("%d:%d", contract.Id, timestamp)))
+		require.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/manage/contract/%d", contract.Id), strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set(QueryContract, fmt.Sprintf("%d:%d:%s", contract.Id, timestamp, hex.EncodeToString(sig)))
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response.Code
+	}
+
+	// invalid metadata is rejected
+	require.Equal(t, http.StatusBadRequest, postConfig(fmt.Sprintf(`{"metadata":{"customer_id":"%s"}}`, strings.Repeat("x", MaxContractMetadataValueLength+1))))
+	conf, err := proxy.ContractConfigStore.Get(contract.Id)
+	require.NoError(t, err)
+	require.Empty(t, conf.Metadata)
+
+	require.Equal(t, http.StatusOK, postConfig(`{"per_user_rate_limit":10,"metadata":{"customer_id":"c-42"}}`))
+	conf, err = proxy.ContractConfigStore.Get(contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"customer_id": "c-42"}, conf.Metadata)
+
+	// included in the claims report
+	result := proxy.claimsIncome()
+	require.Len(t, result.Claims, 1)
+	require.Equal(t, "c-42", result.Claims[0].Metadata["customer_id"])
+
+	// exportable
+	response := httptest.NewRecorder()
+	proxy.handleContractsMetadata(response, httptest.NewRequest(http.MethodGet, RoutesContractsMetadata, nil))
+	require.Equal(t, http.StatusOK, response.Code)
+	var exported []ContractMetadata
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &exported))
+	require.Equal(t, []ContractMetadata{{ContractId: contract.Id, Metadata: map[string]string{"customer_id": "c-42"}}}, exported)
+}
+
+func TestHandleQueryContract(t *testing.T) {
+	testConfig := newTestConfig()
+	client := types.GetRandomPubKey()
+	chain := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/arkeo/contract/2":
+			_, _ = rw.Write([]byte(fmt.Sprintf(`{"contract":{"provider_pub_key":"%s","service":10,"client":"%s","type":1,"height":"10","duration":"5","rate":{"denom":"uarkeo","amount":"2"},"deposit":"100","paid":"40","nonce":"20","id":"2","queries_per_minute":"10"}}`, testConfig.ProviderPubKey, client)))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = rw.Write([]byte(`{"code":5,"message":"contract not found","details":[]}`))
+		}
+	}))
+	defer chain.Close()
+	testConfig.SourceChain = chain.URL
+	proxy := NewProxy(testConfig)
+	router := proxy.getRouter()
+
+	contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, client)
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Rate = cosmos.NewInt64Coin("uarkeo", 2)
+	contract.Deposit = cosmos.NewInt(100)
+	contract.Height = 10
+	contract.Duration = 100
+	contract.Id = 1
+	contract.Nonce = 15
+	proxy.MemStore.SetHeight(20)
+	proxy.MemStore.Put(contract)
+
+	query := func(id string) (*httptest.ResponseRecorder, ContractState) {
+		req := httptest.NewRequest(http.MethodGet, "/arkcontract/"+id, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		var state ContractState
+		if response.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &state))
+		}
+		return response, state
+	}
+
+	// open contract, served from memory
+	response, state := query("1")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, contract.Id, state.Contract.Id)
+	require.Equal(t, int64(15), state.Contract.Nonce)
+	require.Equal(t, int64(20), state.Height)
+	require.Equal(t, int64(110), state.ExpirationHeight)
+	require.False(t, state.Expired)
+	require.Equal(t, int64(70), state.RemainingDeposit.Int64())
+	require.Equal(t, int64(35), *state.RemainingQueries)
+	// querying the contract doesn't go through the paid tier
+	require.Empty(t, response.Header().Get("tier"))
+	require.Empty(t, proxy.ClaimStore.List())
+
+	// expired contract, fetched from the chain
+	response, state = query("2")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, uint64(2), state.Contract.Id)
+	require.True(t, state.Expired)
+	require.Equal(t, int64(15), state.ExpirationHeight)
+	require.Equal(t, int64(30), *state.RemainingQueries)
+
+	// unknown contract
+	response, _ = query("3")
+	require.Equal(t, http.StatusNotFound, response.Code)
+	response, _ = query("bogus")
+	require.Equal(t, http.StatusBadRequest, response.Code)
+}
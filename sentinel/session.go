@@ -0,0 +1,707 @@
+package sentinel
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+const (
+	// SessionHeader carries the token of a paid session
+	SessionHeader = api.SessionHeader
+	// SessionRemainingHeader is the number of nonce units a session has left,
+	// sent on the responses of its requests
+	SessionRemainingHeader = "X-Ark-Session-Remaining"
+
+	// sessionTokenBytes is the number of random bytes of a session token
+	sessionTokenBytes = 32
+	// sessionSweepInterval is how often the expired sessions are closed
+	sessionSweepInterval = 10 * time.Second
+)
+
+// Session is a paid session, opened by a single arkauth. The spender signs the
+// highest nonce the session can spend, the units between the nonce paid for
+// and that one are spent by the requests sent with the token of the session.
+// Once the session closes, the claim of the contract is bumped by the units
+// spent: at the nonce of the arkauth when all of them were, its signature is
+// claimable right away. Otherwise the previous signature is kept for the
+// claim, until the spender signs a higher nonce.
+type Session struct {
+	Token        string        `json:"token"`
+	ContractId   uint64        `json:"contract_id"`
+	Spender      common.PubKey `json:"spender"`
+	RemoteAddr   string        `json:"remote_addr"` // address the session was opened from, the only one it serves
+	Subscription bool          `json:"subscription"`
+	Paid         int64         `json:"paid"`      // nonce paid for, or in flight, when the session was opened
+	Nonce        int64         `json:"nonce"`     // nonce of the arkauth opening the session
+	Signature    string        `json:"signature"` // hex, of the arkauth opening the session
+	Used         int64         `json:"used"`      // nonce units spent by the requests answered
+	Expires      int64         `json:"expires"`   // unix timestamp
+
+	reserved int64 // nonce units of the requests in flight
+	closing  bool  // closed with requests in flight, settled by the last one
+}
+
+// Units returns the number of nonce units the session can spend
+func (s Session) Units() int64 {
+	return s.Nonce - s.Paid
+}
+
+// Remaining returns the number of nonce units the session has left, the
+// requests in flight excluded
+func (s Session) Remaining() int64 {
+	return s.Units() - s.Used - s.reserved
+}
+
+// IsExpired returns whether the session is past its expiration
+func (s Session) IsExpired(now time.Time) bool {
+	return now.Unix() >= s.Expires
+}
+
+// view returns the session as answered to its client
+func (s Session) view() api.Session {
+	return api.Session{
+		Token:      s.Token,
+		ContractId: s.ContractId,
+		Nonce:      s.Nonce,
+		Units:      s.Units(),
+		Used:       s.Used,
+		Expires:    s.Expires,
+	}
+}
+
+// sessionStore holds the open sessions in memory, and in the state store so
+// they survive a restart. The requests in flight are only held in memory.
+type sessionStore struct {
+	lock     sync.Mutex
+	state    *StateStore
+	sessions map[string]*Session // by token
+}
+
+// newSessionStore loads the sessions of the state store, and reserves the
+// nonces of the pay-as-you-go ones
+func newSessionStore(state *StateStore, nonces *nonceReservations) *sessionStore {
+	store := &sessionStore{state: state, sessions: make(map[string]*Session)}
+	nonces.lock.Lock()
+	defer nonces.lock.Unlock()
+	for _, record := range state.List(StateNamespaceSessions) {
+		var session Session
+		if err := json.Unmarshal(record.Value, &session); err != nil {
+			continue
+		}
+		store.sessions[session.Token] = &session
+		if !session.Subscription {
+			nonces.add(session.ContractId, session.Nonce, session.Units())
+		}
+	}
+	return store
+}
+
+// add stores a new session
+func (s *sessionStore) add(session Session) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.state.Set(StateNamespaceSessions, session.Token, session, 0); err != nil {
+		return err
+	}
+	s.sessions[session.Token] = &session
+	return nil
+}
+
+// get returns a copy of the session of the token
+func (s *sessionStore) get(token string) (Session, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	session, ok := s.sessions[token]
+	if !ok {
+		return Session{}, false
+	}
+	return *session, true
+}
+
+// list returns a copy of the open sessions
+func (s *sessionStore) list() []Session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sessions := make([]Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, *session)
+	}
+	return sessions
+}
+
+// reserve sets aside the units of a request of the session, the session must
+// still be open and have enough units left
+func (s *sessionStore) reserve(token string, cost int64, now time.Time) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	session, ok := s.sessions[token]
+	if !ok || session.closing {
+		return http.StatusUnauthorized, newProxyError(ErrCodeBadSession, "session closed")
+	}
+	if session.IsExpired(now) {
+		return http.StatusUnauthorized, newProxyError(ErrCodeBadSession, "session expired")
+	}
+	if remaining := session.Remaining(); remaining < cost {
+		return http.StatusPaymentRequired, newProxyError(ErrCodeSessionSpent, "session spent, %d units left, request costs %d", remaining, cost)
+	}
+	session.reserved += cost
+	return http.StatusOK, nil
+}
+
+// settle frees the units reserved by a request, and spends them when it was
+// answered. The session is returned, with true, once it is done: closing
+// without requests in flight, or with all its units spent. It is then
+// removed from memory, and left to the caller to remove from the state store
+// once its claim is written.
+func (s *sessionStore) settle(token string, cost int64, spent bool) (Session, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	session, ok := s.sessions[token]
+	if !ok {
+		return Session{}, false, nil
+	}
+	session.reserved -= cost
+	if spent {
+		session.Used += cost
+	}
+	if session.reserved == 0 && (session.closing || session.Used >= session.Units()) {
+		delete(s.sessions, token)
+		return *session, true, nil
+	}
+	if !spent {
+		return *session, false, nil
+	}
+	return *session, false, s.state.Set(StateNamespaceSessions, token, session, 0)
+}
+
+// close closes the session, it is returned with true when it is done. A
+// session with requests in flight is flagged as closing, it is done once the
+// last of them is settled.
+func (s *sessionStore) close(token string) (Session, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	session, ok := s.sessions[token]
+	if !ok {
+		return Session{}, false
+	}
+	if session.reserved > 0 {
+		session.closing = true
+		return *session, false
+	}
+	delete(s.sessions, token)
+	return *session, true
+}
+
+// sessionPayment is a request paid by a session, its units are reserved until
+// it is answered
+type sessionPayment struct {
+	proxy            Proxy
+	token            string
+	contractId       uint64
+	cost             int64
+	dailySpendCapUSD float64
+	at               time.Time
+	pending          bool // yet to be committed or released
+}
+
+// commit spends the units of the request, and settles the session if it is
+// done
+func (pay *sessionPayment) commit() error {
+	if !pay.pending {
+		return nil
+	}
+	pay.pending = false
+	p := pay.proxy
+	session, done, err := p.sessions.settle(pay.token, pay.cost, true)
+	if done {
+		p.finalizeSession(session)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to save session: %w", err)
+	}
+	if pay.dailySpendCapUSD > 0 {
+		p.DailySpendTracker.Add(pay.contractId, pay.cost, pay.at)
+	}
+	return p.addUsage(pay.contractId, pay.at)
+}
+
+// release frees the units of a request that wasn't answered, they can be
+// spent again
+func (pay *sessionPayment) release() {
+	if !pay.pending {
+		return
+	}
+	pay.pending = false
+	if session, done, _ := pay.proxy.sessions.settle(pay.token, pay.cost, false); done {
+		pay.proxy.finalizeSession(session)
+	}
+}
+
+// addUsage counts a request in the usage of the contract
+func (p Proxy) addUsage(contractId uint64, at time.Time) error {
+	p.nonces.lock.Lock()
+	defer p.nonces.lock.Unlock()
+	usage, err := p.ClaimStore.GetUsage(contractId)
+	if err != nil {
+		return fmt.Errorf("fail to get usage: %w", err)
+	}
+	usage.Add(at)
+	if err := p.ClaimStore.Commit(nil, usage, nil); err != nil {
+		return fmt.Errorf("fail to save usage: %w", err)
+	}
+	return nil
+}
+
+// sessionToken returns the session token of the request, empty when it has
+// none
+func sessionToken(r *http.Request) string {
+	if token := r.Header.Get(SessionHeader); len(token) > 0 {
+		return token
+	}
+	return r.URL.Query().Get(SessionHeader)
+}
+
+// newSessionToken returns a random opaque token
+func newSessionToken() (string, error) {
+	buf := make([]byte, sessionTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// sessionDuration returns the duration a session is requested for, capped by
+// the max duration. Without any, the session lasts the max duration.
+func sessionDuration(raw string, max time.Duration) (time.Duration, error) {
+	if len(raw) == 0 {
+		return max, nil
+	}
+	seconds, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("bad duration %q, must be a positive number of seconds", raw)
+	}
+	if duration := time.Duration(seconds) * time.Second; duration < max {
+		return duration, nil
+	}
+	return max, nil
+}
+
+// handleOpenSession opens a session paid by the arkauth of the request, for
+// the duration requested
+func (p Proxy) handleOpenSession(w http.ResponseWriter, r *http.Request) {
+	config := p.config()
+	if config.SessionMaxDurationSec <= 0 {
+		respondWithProxyError(w, http.StatusNotFound, newProxyError(ErrCodeSessionsDisabled, "sessions are disabled"))
+		return
+	}
+	remoteAddr := p.getRemoteAddr(r)
+	if banErr := p.ipBanError(remoteAddr); banErr != nil {
+		respondWithProxyError(w, http.StatusForbidden, banErr)
+		return
+	}
+	aa, err := p.fetchArkAuth(r)
+	if err == nil && aa.ContractId == 0 {
+		err = fmt.Errorf("missing arkauth")
+	}
+	if err != nil {
+		respondWithProxyError(w, http.StatusBadRequest, newProxyError(ErrCodeBadArkAuth, "%w", err))
+		return
+	}
+	duration, err := sessionDuration(r.URL.Query().Get(api.DurationParam), time.Duration(config.SessionMaxDurationSec)*time.Second)
+	if err != nil {
+		respondWithProxyError(w, http.StatusBadRequest, newProxyError(ErrCodeBadSession, "%w", err))
+		return
+	}
+
+	// the arkauth pays for every request of the session, its signature is
+	// checked regardless of strict auth
+	contract, err := p.MemStore.Get(strconv.FormatUint(aa.ContractId, 10))
+	if authErr := p.arkAuthError(aa, contract, err); authErr != nil {
+		p.logger.Error("refused session ark auth", "error", authErr, "contract_id", aa.ContractId)
+		respondWithAuthError(w, authErr, p.Config.ProviderPubKey)
+		return
+	}
+	conf, err := p.ContractConfigStore.Get(contract.Id)
+	if err != nil {
+		p.logger.Error("failed to fetch contract configuration", "error", err)
+	}
+	w = p.enableCORS(w, conf.CORs)
+	if httpCode, _, err := p.contractConfigError(conf, contract.Id, remoteAddr); err != nil {
+		respondWithProxyError(w, httpCode, err)
+		return
+	}
+
+	session, httpCode, err := p.openSession(aa, remoteAddr, duration, conf.DailySpendCapUSD, time.Now())
+	if err != nil {
+		p.logger.Error("failed to open session", "error", err, "contract_id", aa.ContractId)
+		respondWithProxyError(w, httpCode, err)
+		return
+	}
+	p.logger.Info("session opened", "contract_id", session.ContractId, "units", session.Units(), "expires", session.Expires)
+	respondWithJSON(w, http.StatusOK, session.view())
+}
+
+// openSession reserves the nonces of the arkauth for a session of the remote
+// address. A pay-as-you-go session can't spend more than the deposit of its
+// contract.
+func (p Proxy) openSession(aa ArkAuth, remoteAddr string, duration time.Duration, dailySpendCapUSD float64, now time.Time) (session Session, code int, err error) {
+	key := strconv.FormatUint(aa.ContractId, 10)
+	contract, err := p.MemStore.Get(key)
+	if err != nil {
+		return session, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
+	}
+	if contract.IsExpired(p.MemStore.GetHeight()) {
+		return session, http.StatusPaymentRequired, newProxyError(ErrCodeContractExpired, "open a contract")
+	}
+	token, err := newSessionToken()
+	if err != nil {
+		return session, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
+	}
+	session = Session{
+		Token:        token,
+		ContractId:   aa.ContractId,
+		Spender:      aa.Spender,
+		RemoteAddr:   remoteAddr,
+		Subscription: contract.IsSubscription(),
+		Nonce:        aa.Nonce,
+		Signature:    hex.EncodeToString(aa.Signature),
+		Expires:      now.Add(duration).Unix(),
+	}
+	maxUnits := p.config().SessionMaxRequests
+
+	if contract.IsSubscription() {
+		// subscriptions are paid per block, the nonces are only tracked to
+		// reject replayed arkauths
+		if err := sessionUnitsError(aa.Nonce, contract.Nonce, maxUnits); err != nil {
+			return session, http.StatusBadRequest, err
+		}
+		session.Paid = contract.Nonce
+		if !p.DryRun {
+			contract.Nonce = aa.Nonce
+			p.MemStore.Put(contract)
+		}
+	} else {
+		session.Paid, code, err = p.reserveSessionNonces(aa, contract, maxUnits)
+		if err != nil {
+			return session, code, err
+		}
+		// the reservation is dropped if the session is refused below
+		defer func() {
+			if err != nil || p.DryRun {
+				p.nonces.release(aa.ContractId, aa.Nonce)
+			}
+		}()
+		if contract.Deposit.IsNil() || contract.Deposit.LT(cosmos.NewInt(aa.Nonce*contract.Rate.Amount.Int64())) {
+			return session, http.StatusPaymentRequired, newProxyError(ErrCodeContractSpent, "contract spent, the deposit doesn't pay up to nonce %d", aa.Nonce)
+		}
+		exceeded, err := p.exceedsDailySpendCap(contract, session.Units(), dailySpendCapUSD, now)
+		if err != nil {
+			return session, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
+		}
+		if exceeded {
+			return session, http.StatusTooManyRequests, newProxyError(ErrCodeDailySpendCap, "daily spend cap of %.2f USD reached", dailySpendCapUSD)
+		}
+	}
+
+	if err := p.sessions.add(session); err != nil {
+		return session, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
+	}
+	return session, http.StatusOK, nil
+}
+
+// reserveSessionNonces reserves the nonces between the highest one paid for,
+// or in flight, and the nonce of the arkauth for a session. It returns the
+// highest nonce. The nonce known by the chain counts as well, the claims may
+// not be stored locally.
+func (p Proxy) reserveSessionNonces(aa ArkAuth, contract types.Contract, maxUnits int64) (int64, int, error) {
+	p.nonces.lock.Lock()
+	defer p.nonces.lock.Unlock()
+	highest := contract.Nonce
+	key := strconv.FormatUint(aa.ContractId, 10)
+	if p.ClaimStore.Has(key) {
+		claim, err := p.ClaimStore.Get(key)
+		if err != nil {
+			return 0, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err)
+		}
+		if claim.Nonce > highest {
+			highest = claim.Nonce
+		}
+	}
+	if pending := p.nonces.highest(aa.ContractId); pending > highest {
+		highest = pending
+	}
+	if err := sessionUnitsError(aa.Nonce, highest, maxUnits); err != nil {
+		return highest, http.StatusBadRequest, err
+	}
+	p.nonces.add(aa.ContractId, aa.Nonce, aa.Nonce-highest)
+	return highest, http.StatusOK, nil
+}
+
+// sessionUnitsError returns an error when the nonce of a session isn't above
+// the highest one, or spans more than the max number of units
+func sessionUnitsError(nonce, highest, maxUnits int64) error {
+	if nonce <= highest {
+		return newProxyError(ErrCodeBadNonce, "bad nonce (%d/%d)", nonce, highest)
+	}
+	if maxUnits > 0 && nonce-highest > maxUnits {
+		return newProxyError(ErrCodeBadNonce, "bad nonce, a session spans at most %d units, expected between %d and %d (%d)", maxUnits, highest+1, highest+maxUnits, nonce)
+	}
+	return nil
+}
+
+// handleCloseSession closes the session of the request before it expires
+func (p Proxy) handleCloseSession(w http.ResponseWriter, r *http.Request) {
+	token := sessionToken(r)
+	session, ok := p.sessions.get(token)
+	if !ok || session.RemoteAddr != p.getRemoteAddr(r) {
+		respondWithProxyError(w, http.StatusNotFound, newProxyError(ErrCodeBadSession, "unknown session"))
+		return
+	}
+	if closed, done := p.sessions.close(token); done {
+		p.finalizeSession(closed)
+		session = closed
+	}
+	respondWithJSON(w, http.StatusOK, session.view())
+}
+
+// serveSession serves a request paid by a session, in place of the paid tier
+// of the auth middleware. The session is only valid for the address that
+// opened it, until it expires, its units are spent or its contract expires.
+func (p Proxy) serveSession(w http.ResponseWriter, r *http.Request, next http.Handler, token, remoteAddr string) {
+	trace := getAuthTrace(r.Context())
+	session, ok := p.sessions.get(token)
+	if !ok || session.RemoteAddr != remoteAddr {
+		trace.add("session:unknown")
+		respondWithProxyError(w, http.StatusUnauthorized, newProxyError(ErrCodeBadSession, "unknown session"))
+		return
+	}
+	key := strconv.FormatUint(session.ContractId, 10)
+	contract, err := p.MemStore.Get(key)
+	if err != nil {
+		// an expired contract is fetched again from the chain, the copy held
+		// in memory still tells it expired
+		held, ok := p.MemStore.Peek(key)
+		if !ok {
+			p.logger.Error("failed to fetch contract", "error", err)
+			respondWithProxyError(w, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "internal server error: %w", err))
+			return
+		}
+		contract = held
+	}
+	if info := getAccessInfo(r.Context()); info != nil {
+		info.contractId = contract.Id
+		info.spender = contract.GetSpender().String()
+	}
+	if contract.IsExpired(p.MemStore.GetHeight()) {
+		trace.add("session:contract_expired")
+		p.closeSession(token)
+		respondWithProxyError(w, http.StatusPaymentRequired, newProxyError(ErrCodeContractExpired, "contract %d expired at height %d", contract.Id, contract.Expiration()))
+		return
+	}
+	if httpCode, reason, err := p.serviceError(r, contract); err != nil {
+		trace.add(reason)
+		respondWithProxyError(w, httpCode, err)
+		return
+	}
+	conf, err := p.ContractConfigStore.Get(contract.Id)
+	if err != nil {
+		p.logger.Error("failed to fetch contract configuration", "error", err)
+	}
+	w = p.enableCORS(w, conf.CORs)
+	if httpCode, reason, err := p.contractConfigError(conf, contract.Id, remoteAddr); err != nil {
+		trace.add(reason)
+		respondWithProxyError(w, httpCode, err)
+		return
+	}
+
+	pay, httpCode, err := p.reserveSession(token, contract, p.requestCost(r, conf.Pricing, contract.MethodRates), conf.DailySpendCapUSD, time.Now())
+	if err != nil {
+		trace.add(fmt.Sprintf("session:rejected:%d", httpCode))
+		if errors.Is(err, errBlockQuotaExceeded) {
+			w.Header().Set(QuotaResetHeader, strconv.FormatInt(p.MemStore.GetHeight()+1, 10))
+		}
+		respondWithProxyError(w, httpCode, err)
+		return
+	}
+	release, err := p.acquireInFlight(requestServiceName(r), contract.Id)
+	if err != nil {
+		trace.add("session:too_many_in_flight")
+		pay.release()
+		respondWithProxyError(w, http.StatusTooManyRequests, err)
+		return
+	}
+	// released even when the handler panics
+	defer release()
+	trace.add("session:served")
+	w.Header().Set("tier", "paid")
+	recorder := &paymentRecorder{ResponseWriter: w}
+	recorder.settle = func(code int) {
+		if code < http.StatusBadRequest {
+			if err := pay.commit(); err != nil {
+				p.logger.Error("fail to commit session request", "error", err, "contract_id", contract.Id)
+			}
+		} else {
+			trace.add(fmt.Sprintf("session:released:%d", code))
+			pay.release()
+		}
+		if session, ok := p.sessions.get(token); ok {
+			w.Header().Set(SessionRemainingHeader, strconv.FormatInt(session.Remaining(), 10))
+		} else {
+			w.Header().Set(SessionRemainingHeader, "0")
+		}
+	}
+	// a panicking handler didn't answer
+	defer recorder.settleOnce(http.StatusInternalServerError)
+	r = r.WithContext(context.WithValue(r.Context(), contractIdKey{}, contract.Id))
+	next.ServeHTTP(recorder, r)
+	// nothing written is answered with a 200
+	recorder.settleOnce(http.StatusOK)
+}
+
+// reserveSession reserves the units of a request of the session, then checks
+// it against the rate limits of its contract. An expired session is closed.
+func (p Proxy) reserveSession(token string, contract types.Contract, cost int64, dailySpendCapUSD float64, now time.Time) (*sessionPayment, int, error) {
+	if code, err := p.sessions.reserve(token, cost, now); err != nil {
+		if session, ok := p.sessions.get(token); ok && session.IsExpired(now) {
+			p.closeSession(token)
+		}
+		return nil, code, err
+	}
+	pay := &sessionPayment{proxy: p, token: token, contractId: contract.Id, cost: cost, dailySpendCapUSD: dailySpendCapUSD, at: now, pending: true}
+
+	key := strconv.FormatUint(contract.Id, 10)
+	if ok := p.isRateLimited(contract.Id, key, int(contract.QueriesPerMinute)); ok {
+		pay.release()
+		return nil, http.StatusTooManyRequests, newProxyError(ErrCodeContractRateLimited, "client is ratelimited,%s", http.StatusText(429))
+	}
+	if contract.IsSubscription() {
+		if !p.MemStore.MeterBlockQuery(contract.Id, p.subscriptionBlockQuota(contract)) {
+			pay.release()
+			return nil, http.StatusTooManyRequests, errBlockQuotaExceeded
+		}
+	}
+	return pay, http.StatusOK, nil
+}
+
+// closeSession closes the session, it is settled right away unless requests
+// are in flight
+func (p Proxy) closeSession(token string) {
+	if session, done := p.sessions.close(token); done {
+		p.finalizeSession(session)
+	}
+}
+
+// closeContractSessions closes the sessions of the contract
+func (p Proxy) closeContractSessions(contractId uint64) {
+	for _, session := range p.sessions.list() {
+		if session.ContractId == contractId {
+			p.closeSession(session.Token)
+		}
+	}
+}
+
+// finalizeSession writes the claim of a closed session, and removes it from
+// the state store
+func (p Proxy) finalizeSession(session Session) {
+	if err := p.settleSession(session); err != nil {
+		p.logger.Error("fail to settle session", "error", err, "contract_id", session.ContractId)
+	}
+	if err := p.StateStore.Remove(StateNamespaceSessions, session.Token); err != nil {
+		p.logger.Error("fail to remove session", "error", err, "contract_id", session.ContractId)
+	}
+	p.logger.Info("session closed", "contract_id", session.ContractId, "used", session.Used, "units", session.Units())
+}
+
+// settleSession bumps the claim of the contract by the units the session
+// spent, and frees its nonces. All of them spent, the claim is the arkauth of
+// the session. Otherwise the nonce is bumped but the signature of the stored
+// claim is kept, along with the nonce it is over, as the spender didn't sign
+// the nonce reached.
+func (p Proxy) settleSession(session Session) error {
+	if session.Subscription || p.DryRun {
+		// subscriptions are paid per block, queries don't earn claims
+		return nil
+	}
+	p.nonces.lock.Lock()
+	defer p.nonces.lock.Unlock()
+	defer p.nonces.remove(session.ContractId, session.Nonce)
+	if session.Used <= 0 {
+		return nil
+	}
+
+	key := strconv.FormatUint(session.ContractId, 10)
+	var stored Claim
+	if p.ClaimStore.Has(key) {
+		var err error
+		stored, err = p.ClaimStore.Get(key)
+		if err != nil {
+			return fmt.Errorf("fail to get claim: %w", err)
+		}
+	}
+	nonce := session.Paid + session.Used
+	if stored.Nonce >= nonce {
+		return nil
+	}
+	claim := NewClaim(session.ContractId, session.Spender, session.Nonce, session.Signature)
+	if nonce < session.Nonce {
+		claim.Nonce = nonce
+		claim.Signature = stored.Signature
+		claim.SignedNonce = stored.ClaimNonce()
+		claim.Claimed = stored.Claimed
+		if len(stored.Signature) == 0 {
+			// nothing signed yet, nothing to claim until the spender signs a
+			// higher nonce
+			claim.SignedNonce = 0
+			claim.Claimed = true
+		}
+	}
+	if err := p.ClaimStore.Set(claim); err != nil {
+		return fmt.Errorf("fail to save claim: %w", err)
+	}
+	if contract, ok := p.MemStore.Peek(key); ok && contract.Nonce < nonce {
+		contract.Nonce = nonce
+		p.MemStore.Put(contract)
+	}
+	return nil
+}
+
+// SessionSweeper closes the sessions expired, or whose contract expired, until
+// the proxy shuts down
+func (p Proxy) SessionSweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stopping:
+			return
+		case <-ticker.C:
+		}
+		p.sweepSessions(time.Now())
+	}
+}
+
+func (p Proxy) sweepSessions(now time.Time) {
+	height := p.MemStore.GetHeight()
+	for _, session := range p.sessions.list() {
+		expired := session.IsExpired(now)
+		if contract, ok := p.MemStore.Peek(strconv.FormatUint(session.ContractId, 10)); ok && contract.IsExpired(height) {
+			expired = true
+		}
+		if expired {
+			p.closeSession(session.Token)
+		}
+	}
+}
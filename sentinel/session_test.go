@@ -0,0 +1,291 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// newSessionTestProxy returns a proxy with sessions of up to a minute and ten
+// units, serving an open pay-as-you-go contract
+func newSessionTestProxy(t *testing.T) (Proxy, types.Contract) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	t.Cleanup(upstream.Close)
+
+	config := newTestConfig()
+	config.FreeTierEnabled = false // refused arkauths aren't served for free
+	config.SessionMaxDurationSec = 60
+	config.SessionMaxRequests = 10
+	proxy := NewProxy(config)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	proxy.MemStore.SetHeight(20)
+
+	contract := newTestContract(config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Id = 5
+	proxy.MemStore.Put(contract)
+	return proxy, contract
+}
+
+// sessionRequest serves a request of the router from the given address
+func sessionRequest(proxy Proxy, method, target, ip, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set(xRealIPName, ip)
+	if len(token) > 0 {
+		req.Header.Set(SessionHeader, token)
+	}
+	response := httptest.NewRecorder()
+	proxy.getRouter().ServeHTTP(response, req)
+	return response
+}
+
+// openTestSession opens a session of the contract up to the nonce
+func openTestSession(t *testing.T, proxy Proxy, contractId uint64, nonce int64, ip string) api.Session {
+	response := sessionRequest(proxy, http.MethodPost, fmt.Sprintf("/session?arkauth=%d:%d", contractId, nonce), ip, "")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var session api.Session
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &session))
+	return session
+}
+
+func requireErrorCode(t *testing.T, response *httptest.ResponseRecorder, status int, code ErrorCode) {
+	require.Equal(t, status, response.Code, response.Body.String())
+	var body ErrorResponse
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+	require.Equal(t, code, body.Code)
+}
+
+func TestSessionIssuance(t *testing.T) {
+	proxy, contract := newSessionTestProxy(t)
+	const ip = "10.0.0.1"
+
+	// sessions are disabled by default
+	disabled := NewProxy(newTestConfig())
+	requireErrorCode(t, sessionRequest(disabled, http.MethodPost, "/session?arkauth=5:5", ip, ""), http.StatusNotFound, ErrCodeSessionsDisabled)
+
+	// the arkauth is required, the duration positive and the units capped
+	requireErrorCode(t, sessionRequest(proxy, http.MethodPost, "/session", ip, ""), http.StatusBadRequest, ErrCodeBadArkAuth)
+	requireErrorCode(t, sessionRequest(proxy, http.MethodPost, "/session?arkauth=5:5&duration=-1", ip, ""), http.StatusBadRequest, ErrCodeBadSession)
+	requireErrorCode(t, sessionRequest(proxy, http.MethodPost, "/session?arkauth=5:11", ip, ""), http.StatusBadRequest, ErrCodeBadNonce)
+	requireErrorCode(t, sessionRequest(proxy, http.MethodPost, "/session?arkauth=9:5", ip, ""), http.StatusUnauthorized, ErrCodeUnknownContract)
+
+	// the duration is capped by the max one
+	before := time.Now()
+	session := openTestSession(t, proxy, contract.Id, 5, ip)
+	require.Len(t, session.Token, 2*sessionTokenBytes)
+	require.Equal(t, contract.Id, session.ContractId)
+	require.Equal(t, int64(5), session.Units)
+	require.LessOrEqual(t, session.Expires, before.Add(time.Minute).Unix()+1)
+	other := openTestSession(t, proxy, contract.Id, 8, ip)
+	require.NotEqual(t, session.Token, other.Token)
+	require.Equal(t, int64(3), other.Units)
+	require.Equal(t, http.StatusOK, sessionRequest(proxy, http.MethodDelete, "/session", ip, other.Token).Code)
+
+	// the nonces of the session can't be used by arkauths meanwhile
+	requireErrorCode(t, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/?arkauth=5:3", ip, ""), http.StatusPaymentRequired, ErrCodeBadNonce)
+	requireErrorCode(t, sessionRequest(proxy, http.MethodPost, "/v1/session?arkauth=5:4", ip, ""), http.StatusBadRequest, ErrCodeBadNonce)
+
+	// the token is bound to the address that opened the session
+	requireErrorCode(t, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/", "10.0.0.2", session.Token), http.StatusUnauthorized, ErrCodeBadSession)
+	requireErrorCode(t, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/", ip, "bogus"), http.StatusUnauthorized, ErrCodeBadSession)
+	require.Equal(t, http.StatusNotFound, sessionRequest(proxy, http.MethodDelete, "/session", "10.0.0.2", session.Token).Code)
+
+	// requests are paid by the session without any arkauth
+	for remaining := 4; remaining >= 0; remaining-- {
+		response := sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/", ip, session.Token)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "paid", response.Header().Get("tier"))
+		require.Equal(t, fmt.Sprint(remaining), response.Header().Get(SessionRemainingHeader))
+	}
+	// spent, the session is closed
+	requireErrorCode(t, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/", ip, session.Token), http.StatusUnauthorized, ErrCodeBadSession)
+	_, ok := proxy.sessions.get(session.Token)
+	require.False(t, ok)
+	found, err := proxy.StateStore.Get(StateNamespaceSessions, session.Token, &Session{})
+	require.NoError(t, err)
+	require.False(t, found)
+
+	usage, err := proxy.ClaimStore.GetUsage(contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), usage.Requests)
+	require.Equal(t, http.StatusOK, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/?arkauth=5:6", ip, "").Code)
+}
+
+func TestSessionAccounting(t *testing.T) {
+	proxy, contract := newSessionTestProxy(t)
+	const ip = "10.0.0.1"
+	serve := func(token string) {
+		require.Equal(t, http.StatusOK, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/", ip, token).Code)
+	}
+
+	// all the units spent, the claim is the arkauth of the session
+	aa := ArkAuth{ContractId: contract.Id, Nonce: 3, Signature: []byte{0xab}}
+	session, code, err := proxy.openSession(aa, ip, time.Minute, 0, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	for i := 0; i < 3; i++ {
+		serve(session.Token)
+	}
+	claim, err := proxy.ClaimStore.Get(contract.Key())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), claim.Nonce)
+	require.Equal(t, "ab", claim.Signature)
+	require.Zero(t, claim.SignedNonce)
+	require.Equal(t, int64(3), claim.ClaimNonce())
+	require.False(t, claim.Claimed)
+
+	// some of them spent, the nonce is bumped by the units spent, the claim
+	// keeps the signature of the last nonce signed
+	aa = ArkAuth{ContractId: contract.Id, Nonce: 8, Signature: []byte{0xcd}}
+	session, _, err = proxy.openSession(aa, ip, time.Minute, 0, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), session.Paid)
+	serve(session.Token)
+	serve(session.Token)
+	require.Equal(t, http.StatusOK, sessionRequest(proxy, http.MethodDelete, "/session", ip, session.Token).Code)
+	claim, err = proxy.ClaimStore.Get(contract.Key())
+	require.NoError(t, err)
+	require.Equal(t, int64(5), claim.Nonce)
+	require.Equal(t, "ab", claim.Signature)
+	require.Equal(t, int64(3), claim.SignedNonce)
+	require.Equal(t, int64(3), claim.ClaimNonce())
+	require.Equal(t, cosmos.NewInt(3), claimIncome(claim, contract))
+	nonce, err := proxy.paidNonce(contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), nonce)
+	held, _ := proxy.MemStore.Peek(contract.Key())
+	require.Equal(t, int64(5), held.Nonce)
+
+	// the unspent nonces are freed, the next arkauth goes above the units spent
+	requireErrorCode(t, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/?arkauth=5:5", ip, ""), http.StatusPaymentRequired, ErrCodeBadNonce)
+	require.Equal(t, http.StatusOK, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/?arkauth=5:6", ip, "").Code)
+	claim, err = proxy.ClaimStore.Get(contract.Key())
+	require.NoError(t, err)
+	require.Equal(t, int64(6), claim.Nonce)
+	require.Zero(t, claim.SignedNonce)
+
+	// a session can't spend more than the deposit
+	spent := contract
+	spent.Deposit = cosmos.NewInt(10)
+	proxy.MemStore.Put(spent)
+	_, code, err = proxy.openSession(ArkAuth{ContractId: contract.Id, Nonce: 11}, ip, time.Minute, 0, time.Now())
+	require.Error(t, err)
+	require.Equal(t, http.StatusPaymentRequired, code)
+	require.Equal(t, ErrCodeContractSpent, errorCode(err, code))
+	proxy.nonces.lock.Lock()
+	require.Equal(t, int64(0), proxy.nonces.highest(contract.Id))
+	proxy.nonces.lock.Unlock()
+
+	// without any nonce signed before, nothing can be claimed
+	fresh := contract
+	fresh.Id = 6
+	proxy.MemStore.Put(fresh)
+	session, _, err = proxy.openSession(ArkAuth{ContractId: fresh.Id, Nonce: 4}, ip, time.Minute, 0, time.Now())
+	require.NoError(t, err)
+	serve(session.Token)
+	proxy.closeSession(session.Token)
+	claim, err = proxy.ClaimStore.Get(fresh.Key())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), claim.Nonce)
+	require.True(t, claim.Claimed)
+	require.False(t, proxy.ClaimStore.HasUnclaimed(fresh.Id))
+}
+
+func TestSessionExpiry(t *testing.T) {
+	proxy, contract := newSessionTestProxy(t)
+	const ip = "10.0.0.1"
+	now := time.Now()
+
+	// closed with a request in flight, the session is settled once the
+	// request is answered
+	session, _, err := proxy.openSession(ArkAuth{ContractId: contract.Id, Nonce: 5}, ip, time.Minute, 0, now)
+	require.NoError(t, err)
+	pay, _, err := proxy.reserveSession(session.Token, contract, 1, 0, now)
+	require.NoError(t, err)
+	proxy.closeSession(session.Token)
+	_, _, err = proxy.reserveSession(session.Token, contract, 1, 0, now)
+	require.Error(t, err)
+	require.Equal(t, ErrCodeBadSession, errorCode(err, http.StatusUnauthorized))
+	require.False(t, proxy.ClaimStore.Has(contract.Key()))
+	require.NoError(t, pay.commit())
+	_, ok := proxy.sessions.get(session.Token)
+	require.False(t, ok)
+	claim, err := proxy.ClaimStore.Get(contract.Key())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), claim.Nonce)
+
+	// a request in flight failing after the close spends nothing
+	session, _, err = proxy.openSession(ArkAuth{ContractId: contract.Id, Nonce: 6}, ip, time.Minute, 0, now)
+	require.NoError(t, err)
+	pay, _, err = proxy.reserveSession(session.Token, contract, 1, 0, now)
+	require.NoError(t, err)
+	proxy.closeSession(session.Token)
+	pay.release()
+	_, ok = proxy.sessions.get(session.Token)
+	require.False(t, ok)
+	claim, err = proxy.ClaimStore.Get(contract.Key())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), claim.Nonce)
+	proxy.nonces.lock.Lock()
+	require.Equal(t, int64(0), proxy.nonces.highest(contract.Id))
+	proxy.nonces.lock.Unlock()
+
+	// an expired session refuses the requests and is closed
+	session, _, err = proxy.openSession(ArkAuth{ContractId: contract.Id, Nonce: 6}, ip, time.Minute, 0, now)
+	require.NoError(t, err)
+	_, code, err := proxy.reserveSession(session.Token, contract, 1, 0, now.Add(time.Minute))
+	require.Error(t, err)
+	require.Equal(t, http.StatusUnauthorized, code)
+	_, ok = proxy.sessions.get(session.Token)
+	require.False(t, ok)
+
+	// or closed by the sweeper, with its requests in flight settled later
+	session, _, err = proxy.openSession(ArkAuth{ContractId: contract.Id, Nonce: 6}, ip, time.Minute, 0, now)
+	require.NoError(t, err)
+	pay, _, err = proxy.reserveSession(session.Token, contract, 1, 0, now)
+	require.NoError(t, err)
+	proxy.sweepSessions(now)
+	_, ok = proxy.sessions.get(session.Token)
+	require.True(t, ok)
+	proxy.sweepSessions(now.Add(2 * time.Minute))
+	_, ok = proxy.sessions.get(session.Token)
+	require.True(t, ok, "closing until its request is answered")
+	require.NoError(t, pay.commit())
+	_, ok = proxy.sessions.get(session.Token)
+	require.False(t, ok)
+	claim, err = proxy.ClaimStore.Get(contract.Key())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), claim.Nonce)
+
+	// sessions survive a restart, along with their units spent
+	session = openTestSession(t, proxy, contract.Id, 7, ip)
+	require.Equal(t, http.StatusOK, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/", ip, session.Token).Code)
+	nonces := newNonceReservations()
+	restored := newSessionStore(proxy.StateStore, nonces)
+	loaded, ok := restored.get(session.Token)
+	require.True(t, ok)
+	require.Equal(t, int64(1), loaded.Used)
+	require.Equal(t, int64(7), nonces.highest(contract.Id))
+
+	// the contract expires, the session is invalidated
+	proxy.MemStore.SetHeight(contract.Expiration() + 1)
+	requireErrorCode(t, sessionRequest(proxy, http.MethodGet, "/btc-mainnet-fullnode/", ip, session.Token), http.StatusPaymentRequired, ErrCodeContractExpired)
+	_, ok = proxy.sessions.get(session.Token)
+	require.False(t, ok)
+	claim, err = proxy.ClaimStore.Get(contract.Key())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), claim.Nonce)
+	requireErrorCode(t, sessionRequest(proxy, http.MethodPost, "/session?arkauth=5:8", ip, ""), http.StatusUnauthorized, ErrCodeContractExpired)
+}
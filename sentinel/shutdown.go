@@ -0,0 +1,105 @@
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// shutdownSignal returns a channel closed once the proxy is asked to stop
+func shutdownSignal() <-chan struct{} {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	stop := make(chan struct{})
+	go func() {
+		<-sig
+		signal.Stop(sig)
+		close(stop)
+	}()
+	return stop
+}
+
+// listenAndServe serves until the server is shut down
+func listenAndServe(serve func() error) {
+	if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(err)
+	}
+}
+
+// Shutdown stops the proxy gracefully. The servers stop accepting connections
+// and the requests being served are given up to the shutdown timeout to
+// complete, then the background workers are stopped and the stores flushed
+// and closed, so no claim written by a drained request is lost. It is called
+// once, the proxy can't serve afterwards.
+func (p Proxy) Shutdown(servers []*http.Server, grpcServer *grpc.Server) error {
+	timeout := time.Duration(p.Config.ShutdownTimeoutSec) * time.Second
+	p.logger.Info("shutting down, draining requests", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var lock sync.Mutex
+	var errs []error
+	fail := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		p.logger.Error("fail to shut down gracefully", "error", err)
+		errs = append(errs, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				// the requests still served are dropped
+				fail(fmt.Errorf("fail to drain requests of %s: %w", server.Addr, err))
+				_ = server.Close()
+			}
+		}(server)
+	}
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			drained := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(drained)
+			}()
+			select {
+			case <-drained:
+			case <-ctx.Done():
+				grpcServer.Stop()
+				fail(fmt.Errorf("fail to drain grpc calls: %w", ctx.Err()))
+			}
+		}()
+	}
+	wg.Wait()
+	close(p.stopping)
+
+	p.logger.Info("requests drained, closing stores")
+	for _, store := range []struct {
+		name   string
+		closer interface{ Close() error }
+	}{
+		{"claim store", p.ClaimStore},
+		{"state store", p.StateStore},
+		{"contract config store", p.ContractConfigStore},
+		{"audit log", p.AuditLog},
+		{"access log", p.AccessLog},
+	} {
+		if err := store.closer.Close(); err != nil {
+			fail(fmt.Errorf("fail to close %s: %w", store.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,93 @@
+package sentinel
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		_, _ = rw.Write([]byte("done"))
+	}))
+	defer upstream.Close()
+
+	claimStoreLocation := t.TempDir()
+	config := newTestConfig()
+	config.ClaimStoreLocation = claimStoreLocation
+	config.ShutdownTimeoutSec = 5
+	proxy := NewProxy(config)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Id = 5
+	proxy.MemStore.Put(contract)
+
+	router := proxy.getRouter()
+	var served int32
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+		atomic.StoreInt32(&served, 1)
+	})}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(listener) }()
+	url := "http://" + listener.Addr().String()
+
+	type result struct {
+		code int
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url + "/btc-mainnet-fullnode/?arkauth=5:3")
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{code: resp.StatusCode, body: string(body), err: err}
+	}()
+
+	// shut down while the slow request is being served, it completes first
+	<-started
+	require.NoError(t, proxy.Shutdown([]*http.Server{server}, nil))
+	require.Equal(t, int32(1), atomic.LoadInt32(&served))
+	res := <-results
+	require.NoError(t, res.err)
+	require.Equal(t, http.StatusOK, res.code)
+	require.Equal(t, "done", res.body)
+	require.ErrorIs(t, <-serveErr, http.ErrServerClosed)
+
+	// new connections are refused, and the background workers stopped
+	_, err = http.Get(url + RoutesHealth)
+	require.Error(t, err)
+	select {
+	case <-proxy.stopping:
+	default:
+		t.Fatal("background workers not stopped")
+	}
+
+	// the claim of the drained request was written to disk
+	claimStore, err := NewClaimStore(claimStoreLocation)
+	require.NoError(t, err)
+	defer claimStore.Close()
+	claim, err := claimStore.Get("5")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), claim.Nonce)
+}
@@ -0,0 +1,132 @@
+package sentinel
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+const (
+	// window over which the daily spend cap of a contract is enforced
+	dailySpendWindow = 24 * time.Hour
+	// spend within the same bucket is recorded once, to bound the memory
+	// used by busy contracts
+	dailySpendBucket = time.Minute
+)
+
+// PriceOracle returns the price in USD of one unit of a denom
+type PriceOracle interface {
+	USDPrice(denom string) (float64, error)
+}
+
+// ConfigPriceOracle is the default price oracle, its prices are read from the
+// configuration of the sentinel
+type ConfigPriceOracle struct {
+	prices map[string]float64
+}
+
+var _ PriceOracle = ConfigPriceOracle{}
+
+func NewConfigPriceOracle(config conf.Configuration) ConfigPriceOracle {
+	return ConfigPriceOracle{prices: config.USDPrices}
+}
+
+func (o ConfigPriceOracle) USDPrice(denom string) (float64, error) {
+	price, ok := o.prices[denom]
+	if !ok {
+		return 0, fmt.Errorf("no usd price for %s", denom)
+	}
+	return price, nil
+}
+
+// ValidateDailySpendCap checks the cap is a positive amount of USD, zero
+// disables the cap
+func ValidateDailySpendCap(capUSD float64) error {
+	if capUSD < 0 || math.IsNaN(capUSD) || math.IsInf(capUSD, 0) {
+		return fmt.Errorf("daily spend cap must be a positive amount of USD")
+	}
+	return nil
+}
+
+type spendRecord struct {
+	at    time.Time
+	units int64
+}
+
+// DailySpendTracker keeps the nonce units spent by each contract over a
+// rolling window
+type DailySpendTracker struct {
+	mu      sync.Mutex
+	window  time.Duration
+	records map[uint64][]spendRecord
+}
+
+func NewDailySpendTracker(window time.Duration) *DailySpendTracker {
+	return &DailySpendTracker{
+		window:  window,
+		records: make(map[uint64][]spendRecord),
+	}
+}
+
+// Spent returns the units spent by the contract within the window
+func (t *DailySpendTracker) Spent(contractId uint64, now time.Time) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	records := t.records[contractId]
+	cutoff := now.Add(-t.window)
+	i := 0
+	for i < len(records) && !records[i].at.After(cutoff) {
+		i++
+	}
+	records = records[i:]
+	if len(records) == 0 {
+		delete(t.records, contractId)
+		return 0
+	}
+	t.records[contractId] = records
+
+	var spent int64
+	for _, record := range records {
+		spent += record.units
+	}
+	return spent
+}
+
+// Add records units spent by the contract
+func (t *DailySpendTracker) Add(contractId uint64, units int64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records := t.records[contractId]
+	if n := len(records); n > 0 && now.Sub(records[n-1].at) < dailySpendBucket {
+		records[n-1].units += units
+		return
+	}
+	t.records[contractId] = append(records, spendRecord{at: now, units: units})
+}
+
+// Remove drops all state of the given contract
+func (t *DailySpendTracker) Remove(contractId uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.records, contractId)
+}
+
+// exceedsDailySpendCap returns true when spending the given units would take
+// the contract over its daily spend cap, once converted to USD
+func (p Proxy) exceedsDailySpendCap(contract types.Contract, units int64, capUSD float64, now time.Time) (bool, error) {
+	if capUSD <= 0 || contract.Rate.IsNil() {
+		return false, nil
+	}
+	price, err := p.PriceOracle.USDPrice(contract.Rate.Denom)
+	if err != nil {
+		return false, err
+	}
+	rateInUSD := float64(contract.Rate.Amount.Int64()) * price
+	spent := p.DailySpendTracker.Spent(contract.Id, now) + units
+	return float64(spent)*rateInUSD > capUSD, nil
+}
@@ -0,0 +1,85 @@
+package sentinel
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestDailySpendCap(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	testConfig.USDPrices = map[string]float64{"uarkeo": 0.25}
+	proxy := NewProxy(testConfig)
+
+	contract := types.NewContract(proxy.Config.ProviderPubKey, common.ETHService, types.GetRandomPubKey())
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Rate = cosmos.NewInt64Coin("uarkeo", 2) // 0.5 USD per query
+	contract.Deposit = cosmos.NewInt(1000)
+	contract.Height = 10
+	contract.Duration = 100
+	contract.Id = 77
+	contract.QueriesPerMinute = 100
+	proxy.MemStore.Put(contract)
+	proxy.MemStore.SetHeight(20)
+
+	pay := func(nonce, cost int64, capUSD float64) int {
+		aa := ArkAuth{ContractId: contract.Id, Spender: contract.Client, Nonce: nonce}
+		code, _ := proxy.paidTierCost(aa, "127.0.0.1:8080", cost, capUSD)
+		return code
+	}
+
+	// 3 USD is 6 queries
+	for nonce := int64(1); nonce <= 4; nonce++ {
+		require.Equal(t, http.StatusOK, pay(nonce, 1, 3))
+	}
+	require.Equal(t, int64(4), proxy.DailySpendTracker.Spent(contract.Id, time.Now()))
+	// a request costing 3 queries goes over the cap, one costing 2 reaches it
+	require.Equal(t, http.StatusTooManyRequests, pay(7, 3, 3))
+	require.Equal(t, http.StatusOK, pay(6, 2, 3))
+	require.Equal(t, http.StatusTooManyRequests, pay(7, 1, 3))
+
+	// raising the cap lets the contract spend again
+	require.Equal(t, http.StatusOK, pay(7, 1, 4))
+
+	// spend is only tracked while a cap is set
+	require.Equal(t, http.StatusOK, pay(8, 1, 0))
+	require.Equal(t, int64(7), proxy.DailySpendTracker.Spent(contract.Id, time.Now()))
+
+	// without a usd price the cap can't be enforced
+	proxy.PriceOracle = ConfigPriceOracle{}
+	require.Equal(t, http.StatusInternalServerError, pay(9, 1, 100))
+	price, err := NewConfigPriceOracle(testConfig).USDPrice("uarkeo")
+	require.NoError(t, err)
+	require.Equal(t, 0.25, price)
+}
+
+func TestDailySpendTracker(t *testing.T) {
+	tracker := NewDailySpendTracker(dailySpendWindow)
+	start := time.Now()
+
+	tracker.Add(1, 5, start)
+	tracker.Add(1, 5, start.Add(30*time.Second)) // same bucket
+	tracker.Add(1, 3, start.Add(2*time.Hour))
+	tracker.Add(2, 7, start)
+	require.Equal(t, int64(13), tracker.Spent(1, start.Add(2*time.Hour)))
+	require.Equal(t, int64(7), tracker.Spent(2, start))
+
+	// the window rolls over
+	require.Equal(t, int64(3), tracker.Spent(1, start.Add(dailySpendWindow+time.Minute)))
+	require.Zero(t, tracker.Spent(1, start.Add(dailySpendWindow+3*time.Hour)))
+
+	tracker.Remove(2)
+	require.Zero(t, tracker.Spent(2, start))
+
+	require.NoError(t, ValidateDailySpendCap(0))
+	require.NoError(t, ValidateDailySpendCap(12.5))
+	require.Error(t, ValidateDailySpendCap(-1))
+}
@@ -0,0 +1,99 @@
+package sentinel
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	SpendAlertHeader = "X-Spend-Alert"
+	// window over which the spend velocity of a contract is measured
+	spendVelocityWindow = time.Minute
+)
+
+type spendSample struct {
+	at    time.Time
+	nonce int64
+}
+
+// SpendVelocityTracker keeps a sliding window of the nonces observed per
+// contract, in order to detect contracts drained faster than expected
+type SpendVelocityTracker struct {
+	mu        sync.Mutex
+	window    time.Duration
+	samples   map[uint64][]spendSample
+	lastAlert map[uint64]time.Time
+}
+
+func NewSpendVelocityTracker(window time.Duration) *SpendVelocityTracker {
+	return &SpendVelocityTracker{
+		window:    window,
+		samples:   make(map[uint64][]spendSample),
+		lastAlert: make(map[uint64]time.Time),
+	}
+}
+
+// Record adds the nonce to the window of the given contract and returns the
+// spend velocity (nonceDelta / timeDelta) in queries per minute. The time
+// delta is never smaller than the window, so a burst of a few queries isn't
+// extrapolated into a high velocity.
+func (t *SpendVelocityTracker) Record(contractId uint64, nonce int64, now time.Time) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	samples := append(t.samples[contractId], spendSample{at: now, nonce: nonce})
+	cutoff := now.Add(-t.window)
+	i := 0
+	for i < len(samples)-1 && samples[i].at.Before(cutoff) {
+		i++
+	}
+	samples = samples[i:]
+	t.samples[contractId] = samples
+
+	first, last := samples[0], samples[len(samples)-1]
+	elapsed := last.at.Sub(first.at)
+	if elapsed < t.window {
+		elapsed = t.window
+	}
+	return float64(last.nonce-first.nonce) / elapsed.Minutes()
+}
+
+// ShouldAlert returns true if no alert was raised for the given contract
+// within the cooldown period, and marks the contract as alerted
+func (t *SpendVelocityTracker) ShouldAlert(contractId uint64, now time.Time, cooldown time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.lastAlert[contractId]
+	if ok && now.Sub(last) < cooldown {
+		return false
+	}
+	t.lastAlert[contractId] = now
+	return true
+}
+
+// Remove drops all state of the given contract
+func (t *SpendVelocityTracker) Remove(contractId uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.samples, contractId)
+	delete(t.lastAlert, contractId)
+}
+
+// checkSpendVelocity records the nonce of a paid request and returns true
+// when the contract is spent faster than configured. A
+// ContractSpendVelocityAlert is logged at most once per cooldown period.
+func (p Proxy) checkSpendVelocity(contractId uint64, nonce int64, now time.Time) bool {
+	if p.Config.MaxExpectedQueriesPerMinute <= 0 {
+		return false
+	}
+	velocity := p.SpendTracker.Record(contractId, nonce, now)
+	if velocity <= float64(p.Config.MaxExpectedQueriesPerMinute) {
+		return false
+	}
+	cooldown := time.Duration(p.Config.AlertCooldownSec) * time.Second
+	if p.SpendTracker.ShouldAlert(contractId, now, cooldown) {
+		p.logger.Error("ContractSpendVelocityAlert", "contract_id", contractId, "nonce", nonce, "spend_velocity", velocity, "max_expected", p.Config.MaxExpectedQueriesPerMinute)
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package sentinel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpendVelocityTracker(t *testing.T) {
+	tracker := NewSpendVelocityTracker(time.Minute)
+	now := time.Now()
+
+	// a single query isn't a velocity
+	require.Equal(t, float64(0), tracker.Record(1, 1, now))
+
+	// 30 queries in 30 seconds, measured over the whole window
+	for i := int64(2); i <= 31; i++ {
+		now = now.Add(time.Second)
+		tracker.Record(1, i, now)
+	}
+	require.Equal(t, float64(30), tracker.Record(1, 31, now))
+
+	// samples older than the window are dropped
+	now = now.Add(2 * time.Minute)
+	require.Equal(t, float64(0), tracker.Record(1, 31, now))
+
+	// other contracts are tracked independently
+	require.Equal(t, float64(0), tracker.Record(2, 500, now))
+
+	require.True(t, tracker.ShouldAlert(1, now, time.Minute))
+	require.False(t, tracker.ShouldAlert(1, now.Add(30*time.Second), time.Minute))
+	require.True(t, tracker.ShouldAlert(1, now.Add(time.Minute), time.Minute))
+
+	tracker.Remove(1)
+	require.True(t, tracker.ShouldAlert(1, now.Add(time.Minute), time.Minute))
+}
+
+func TestCheckSpendVelocity(t *testing.T) {
+	testConfig := newTestConfig()
+	testConfig.MaxExpectedQueriesPerMinute = 60
+	testConfig.AlertCooldownSec = 60
+	proxy := NewProxy(testConfig)
+
+	contractId := uint64(9)
+	start := time.Now()
+
+	// driven at the expected rate, no alert
+	for i := int64(1); i <= 120; i++ {
+		now := start.Add(time.Duration(i) * time.Second)
+		require.False(t, proxy.checkSpendVelocity(contractId, i, now), "nonce %d", i)
+	}
+	_, alerted := proxy.SpendTracker.lastAlert[contractId]
+	require.False(t, alerted)
+
+	// driven at twice the expected rate, alert fires
+	var high bool
+	var now time.Time
+	nonce := int64(120)
+	for i := 1; i <= 120 && !high; i++ {
+		nonce += 2
+		now = start.Add(120*time.Second + time.Duration(i)*time.Second)
+		high = proxy.checkSpendVelocity(contractId, nonce, now)
+	}
+	require.True(t, high)
+	alertedAt, alerted := proxy.SpendTracker.lastAlert[contractId]
+	require.True(t, alerted)
+	require.Equal(t, now, alertedAt)
+
+	// still high, but the alert isn't raised again during the cooldown
+	nonce += 2
+	require.True(t, proxy.checkSpendVelocity(contractId, nonce, now.Add(time.Second)))
+	require.Equal(t, alertedAt, proxy.SpendTracker.lastAlert[contractId])
+
+	// disabled when no max is configured
+	proxy.Config.MaxExpectedQueriesPerMinute = 0
+	nonce += 1000
+	require.False(t, proxy.checkSpendVelocity(contractId, nonce, now.Add(2*time.Second)))
+}
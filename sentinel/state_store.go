@@ -0,0 +1,256 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// namespaces of the state store, each holding the records of one of the in
+// memory structures of the proxy that must survive a restart
+const (
+	StateNamespaceBans            = "bans"
+	StateNamespaceSessions        = "sessions"
+	StateNamespacePoWTokens       = "pow-tokens"
+	StateNamespaceFlags           = "flags"
+	StateNamespaceAdminSignatures = "admin-signatures"
+)
+
+const (
+	// number of pending writes above which they are written to disk
+	stateStoreBatchSize = 100
+	// how often pending writes are written to disk, and expired records removed
+	stateStoreFlushInterval = 5 * time.Second
+)
+
+var stateWriteOptions = &opt.WriteOptions{Sync: true}
+
+// StateRecord is a value stored in a namespace of the state store
+type StateRecord struct {
+	Key     string          `json:"key"`
+	Value   json.RawMessage `json:"value"`
+	Expires int64           `json:"expires"` // unix timestamp, zero never expires
+}
+
+func (r StateRecord) IsExpired(now time.Time) bool {
+	return r.Expires > 0 && r.Expires <= now.Unix()
+}
+
+// StateStore persists the state of the proxy (bans, sessions, etc) in a single
+// level db, with a namespace per structure. Writes are batched, they are only
+// written to disk once enough of them are pending, when flushed, or when the
+// store is closed.
+type StateStore struct {
+	logger  zerolog.Logger
+	db      *leveldb.DB
+	lock    sync.Mutex
+	pending map[string][]byte // pending writes by key, nil for deletes
+}
+
+func NewStateStore(levelDbFolder string) (*StateStore, error) {
+	var db *leveldb.DB
+	var err error
+	if len(levelDbFolder) == 0 {
+		log.Warn().Msg("level db folder is empty, create in memory storage")
+		// no directory given, use in memory store
+		storage := storage.NewMemStorage()
+		db, err = leveldb.Open(storage, nil)
+		if err != nil {
+			return nil, fmt.Errorf("fail to in memory open level db: %w", err)
+		}
+	} else {
+		if err := os.MkdirAll(levelDbFolder, 0o755); err != nil {
+			return nil, fmt.Errorf("fail to create level db folder %s: %w", levelDbFolder, err)
+		}
+		db, err = leveldb.OpenFile(levelDbFolder, nil)
+		if lerrors.IsCorrupted(err) {
+			log.Warn().Err(err).Str("folder", levelDbFolder).Msg("level db is corrupted, attempt to recover")
+			db, err = leveldb.RecoverFile(levelDbFolder, nil)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("fail to open level db %s: %w", levelDbFolder, err)
+		}
+	}
+	store := &StateStore{
+		logger:  log.With().Str("module", "state-storage").Logger(),
+		db:      db,
+		pending: make(map[string][]byte),
+	}
+	// records which expired while the proxy was down are never loaded
+	if err := store.prune(time.Now()); err != nil {
+		return nil, fmt.Errorf("fail to prune expired records: %w", err)
+	}
+	return store, nil
+}
+
+func stateKey(namespace, key string) string {
+	return fmt.Sprintf("%s/%s", namespace, key)
+}
+
+// Set stores the value in the namespace, a zero ttl never expires
+func (s *StateStore) Set(namespace, key string, value interface{}, ttl time.Duration) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	record := StateRecord{Key: key, Value: buf}
+	if ttl > 0 {
+		record.Expires = time.Now().Add(ttl).Unix()
+	}
+	buf, err = json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.write(stateKey(namespace, key), buf)
+}
+
+func (s *StateStore) Remove(namespace, key string) error {
+	return s.write(stateKey(namespace, key), nil)
+}
+
+func (s *StateStore) write(key string, buf []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.pending[key] = buf
+	if len(s.pending) < stateStoreBatchSize {
+		return nil
+	}
+	return s.flush()
+}
+
+// Get decodes the unexpired value of the key into value, and returns whether
+// it was found
+func (s *StateStore) Get(namespace, key string, value interface{}) (bool, error) {
+	s.lock.Lock()
+	buf, ok := s.pending[stateKey(namespace, key)]
+	s.lock.Unlock()
+	if !ok {
+		var err error
+		buf, err = s.db.Get([]byte(stateKey(namespace, key)), nil)
+		if err == leveldb.ErrNotFound {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if buf == nil {
+		// removed, not flushed yet
+		return false, nil
+	}
+	var record StateRecord
+	if err := json.Unmarshal(buf, &record); err != nil {
+		return false, err
+	}
+	if record.IsExpired(time.Now()) {
+		return false, nil
+	}
+	return true, json.Unmarshal(record.Value, value)
+}
+
+// List returns the unexpired records of the namespace
+func (s *StateStore) List(namespace string) []StateRecord {
+	if err := s.Flush(); err != nil {
+		s.logger.Error().Err(err).Msg("fail to flush state store")
+	}
+	now := time.Now()
+	iterator := s.db.NewIterator(util.BytesPrefix([]byte(stateKey(namespace, ""))), nil)
+	defer iterator.Release()
+	var results []StateRecord
+	for iterator.Next() {
+		var record StateRecord
+		if err := json.Unmarshal(iterator.Value(), &record); err != nil {
+			s.logger.Error().Err(err).Msg("fail to unmarshal to state store record")
+			continue
+		}
+		if record.IsExpired(now) {
+			continue
+		}
+		results = append(results, record)
+	}
+	return results
+}
+
+// Flush writes the pending writes to disk
+func (s *StateStore) Flush() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.flush()
+}
+
+func (s *StateStore) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	batch := new(leveldb.Batch)
+	for key, buf := range s.pending {
+		if buf == nil {
+			batch.Delete([]byte(key))
+		} else {
+			batch.Put([]byte(key), buf)
+		}
+	}
+	if err := s.db.Write(batch, stateWriteOptions); err != nil {
+		return err
+	}
+	s.pending = make(map[string][]byte)
+	return nil
+}
+
+// Flusher periodically writes the pending writes to disk, and removes the
+// expired records, until stop is closed
+func (s *StateStore) Flusher(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if err := s.Flush(); err != nil {
+			s.logger.Error().Err(err).Msg("fail to flush state store")
+		}
+		if err := s.prune(time.Now()); err != nil {
+			s.logger.Error().Err(err).Msg("fail to prune state store")
+		}
+	}
+}
+
+// prune removes the records expired at the given time
+func (s *StateStore) prune(now time.Time) error {
+	iterator := s.db.NewIterator(nil, nil)
+	defer iterator.Release()
+	batch := new(leveldb.Batch)
+	for iterator.Next() {
+		var record StateRecord
+		if err := json.Unmarshal(iterator.Value(), &record); err != nil || record.IsExpired(now) {
+			batch.Delete(append([]byte(nil), iterator.Key()...))
+		}
+	}
+	if err := iterator.Error(); err != nil {
+		return err
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return s.db.Write(batch, stateWriteOptions)
+}
+
+// Close flushes the pending writes and closes the underlying db
+func (s *StateStore) Close() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,79 @@
+package sentinel
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStoreRestart(t *testing.T) {
+	dir, err := os.MkdirTemp("", "state-store")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	type ban struct {
+		Reason string `json:"reason"`
+	}
+	store, err := NewStateStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Set(StateNamespaceBans, "10.0.0.1", ban{Reason: "abuse"}, time.Hour))
+	require.NoError(t, store.Set(StateNamespaceBans, "10.0.0.2", ban{Reason: "expiring"}, time.Second))
+	require.NoError(t, store.Set(StateNamespaceBans, "10.0.0.3", ban{Reason: "lifted"}, 0))
+	require.NoError(t, store.Set(StateNamespaceSessions, "token", "contract-5", time.Hour))
+	require.NoError(t, store.Set(StateNamespaceFlags, "maintenance", true, 0))
+	require.NoError(t, store.Remove(StateNamespaceBans, "10.0.0.3"))
+
+	// pending writes are visible before being flushed
+	var b ban
+	found, err := store.Get(StateNamespaceBans, "10.0.0.1", &b)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "abuse", b.Reason)
+	found, err = store.Get(StateNamespaceBans, "10.0.0.3", &b)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// pending writes are flushed on close, simulate a restart of sentinel
+	require.NoError(t, store.Close())
+	time.Sleep(1100 * time.Millisecond)
+	store, err = NewStateStore(dir)
+	require.NoError(t, err)
+	defer store.Close()
+
+	bans := store.List(StateNamespaceBans)
+	require.Len(t, bans, 1)
+	require.Equal(t, "10.0.0.1", bans[0].Key)
+	found, err = store.Get(StateNamespaceBans, "10.0.0.2", &b)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	var session string
+	found, err = store.Get(StateNamespaceSessions, "token", &session)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "contract-5", session)
+
+	var maintenance bool
+	found, err = store.Get(StateNamespaceFlags, "maintenance", &maintenance)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, maintenance)
+	require.Empty(t, store.List(StateNamespacePoWTokens))
+}
+
+func TestStateStoreBatching(t *testing.T) {
+	store, err := NewStateStore("")
+	require.NoError(t, err)
+	defer store.Close()
+
+	for i := 0; i < stateStoreBatchSize-1; i++ {
+		require.NoError(t, store.Set(StateNamespaceSessions, fmt.Sprintf("session-%d", i), i, 0))
+	}
+	require.Len(t, store.pending, stateStoreBatchSize-1)
+	require.NoError(t, store.Set(StateNamespaceSessions, "last", 0, 0))
+	require.Empty(t, store.pending)
+	require.Len(t, store.List(StateNamespaceSessions), stateStoreBatchSize)
+}
@@ -0,0 +1,111 @@
+package sentinel
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+type (
+	Status         = api.Status
+	ContractStatus = api.ContractStatus
+	FreeTierStatus = api.FreeTierStatus
+)
+
+func NewContractStatus(contract types.Contract, height int64) ContractStatus {
+	status := ContractStatus{
+		Id:               contract.Id,
+		Service:          contract.Service.String(),
+		Type:             contract.Type.String(),
+		ExpirationHeight: contract.Expiration(),
+		Expired:          contract.IsExpired(height),
+		Deposit:          cosmos.ZeroInt(),
+		Spent:            cosmos.ZeroInt(),
+		RemainingDeposit: cosmos.ZeroInt(),
+		Height:           height,
+	}
+	if contract.Deposit.IsNil() || contract.Rate.IsNil() {
+		return status
+	}
+	status.Deposit = contract.Deposit
+
+	switch contract.Type {
+	case types.ContractType_PAY_AS_YOU_GO:
+		status.Spent = contract.Rate.Amount.MulRaw(contract.Nonce)
+	case types.ContractType_SUBSCRIPTION:
+		end := height
+		if end > contract.Expiration() {
+			end = contract.Expiration()
+		}
+		if end > contract.Height {
+			status.Spent = contract.Rate.Amount.MulRaw(end - contract.Height)
+		}
+	}
+	if status.Spent.GT(status.Deposit) {
+		status.Spent = status.Deposit
+	}
+	status.RemainingDeposit = status.Deposit.Sub(status.Spent)
+	return status
+}
+
+// handleStatus returns a summary of the sentinel
+func (p Proxy) handleStatus(w http.ResponseWriter, r *http.Request) {
+	config := p.config()
+	services := make([]string, 0, len(config.proxies))
+	upstreams := make(map[string][]UpstreamStatus, len(config.proxies))
+	for service, uris := range config.proxies {
+		services = append(services, service)
+		upstreams[service] = p.Upstreams.Status(uris)
+	}
+	sort.Strings(services)
+
+	status := Status{
+		ProviderPubKey:      config.ProviderPubKey,
+		Services:            services,
+		Upstreams:           upstreams,
+		FreeTierEnabled:     config.FreeTierEnabled,
+		FreeTierRateLimit:   config.FreeTierRateLimit,
+		Version:             Version,
+		Height:              p.MemStore.GetHeight(),
+		EventStream:         p.eventStream.Status(),
+		ContractConfigCache: p.ContractConfigStore.CacheStatus(),
+		MemStore:            p.MemStore.Status(),
+	}
+	if len(config.FreeTierClientMode) > 0 {
+		status.FreeTierClientRateLimit = config.FreeTierClientRateLimit
+	}
+	if len(config.ServiceFreeTiers) > 0 {
+		status.ServiceFreeTiers = make(map[string]FreeTierStatus, len(config.ServiceFreeTiers))
+		for service, freeTier := range config.ServiceFreeTiers {
+			status.ServiceFreeTiers[service] = FreeTierStatus{Enabled: freeTier.Enabled, RateLimit: freeTier.RateLimit}
+		}
+	}
+	respondWithJSON(w, http.StatusOK, status)
+}
+
+// handleContractStatus tells a client whether the sentinel knows about its
+// contract yet. Only the contracts held in memory are looked up, the chain
+// isn't queried, and contracts can't be listed, only found by their exact id.
+func (p Proxy) handleContractStatus(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	contractId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || contractId == 0 {
+		respondWithError(w, fmt.Sprintf("bad contractId: %s", id), http.StatusBadRequest)
+		return
+	}
+
+	contract, ok := p.MemStore.Peek(strconv.FormatUint(contractId, 10))
+	if !ok || contract.Id != contractId {
+		respondWithError(w, fmt.Sprintf("contract %d not found", contractId), http.StatusNotFound)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, NewContractStatus(contract, p.MemStore.GetHeight()))
+}
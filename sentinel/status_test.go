@@ -0,0 +1,118 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleStatus(t *testing.T) {
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	router := proxy.getRouter()
+	proxy.MemStore.SetHeight(20)
+
+	req := httptest.NewRequest(http.MethodGet, RoutesStatus, nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	var status Status
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &status))
+	require.Equal(t, testConfig.ProviderPubKey, status.ProviderPubKey)
+	require.Contains(t, status.Services, common.BTCService.String())
+	require.IsIncreasing(t, status.Services)
+	require.Equal(t, testConfig.FreeTierRateLimit, status.FreeTierRateLimit)
+	require.Equal(t, Version, status.Version)
+	require.Equal(t, int64(20), status.Height)
+	require.Equal(t, MemStoreStatus{}, status.MemStore)
+}
+
+func TestHandleContractStatus(t *testing.T) {
+	testConfig := newTestConfig()
+	// contracts are never fetched from the chain
+	chain := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected chain query: %s", req.URL.Path)
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer chain.Close()
+	testConfig.SourceChain = chain.URL
+	proxy := NewProxy(testConfig)
+	router := proxy.getRouter()
+	proxy.MemStore.SetHeight(20)
+
+	client := types.GetRandomPubKey()
+	payg := types.NewContract(testConfig.ProviderPubKey, common.BTCService, client)
+	payg.Type = types.ContractType_PAY_AS_YOU_GO
+	payg.Rate = cosmos.NewInt64Coin("uarkeo", 2)
+	payg.Deposit = cosmos.NewInt(100)
+	payg.Height = 10
+	payg.Duration = 100
+	payg.Id = 1
+	payg.Nonce = 15
+	proxy.MemStore.Put(payg)
+
+	sub := types.NewContract(testConfig.ProviderPubKey, common.ETHService, types.GetRandomPubKey())
+	sub.Type = types.ContractType_SUBSCRIPTION
+	sub.Rate = cosmos.NewInt64Coin("uarkeo", 3)
+	sub.Deposit = cosmos.NewInt(300)
+	sub.Height = 15
+	sub.Duration = 100
+	sub.Id = 2
+	proxy.MemStore.Put(sub)
+
+	query := func(id string) (*httptest.ResponseRecorder, ContractStatus) {
+		req := httptest.NewRequest(http.MethodGet, "/status/contract/"+id, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		var status ContractStatus
+		if response.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &status))
+		}
+		return response, status
+	}
+
+	response, status := query("1")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, payg.Id, status.Id)
+	require.Equal(t, common.BTCService.String(), status.Service)
+	require.Equal(t, types.ContractType_PAY_AS_YOU_GO.String(), status.Type)
+	require.Equal(t, int64(110), status.ExpirationHeight)
+	require.False(t, status.Expired)
+	require.Equal(t, int64(100), status.Deposit.Int64())
+	require.Equal(t, int64(30), status.Spent.Int64())
+	require.Equal(t, int64(70), status.RemainingDeposit.Int64())
+	require.Equal(t, int64(20), status.Height)
+	// the client of the contract isn't disclosed
+	require.NotContains(t, response.Body.String(), client.String())
+	// the status doesn't go through the paid tier
+	require.Empty(t, response.Header().Get("tier"))
+	require.Empty(t, proxy.ClaimStore.List())
+
+	// subscriptions are spent by the blocks elapsed
+	response, status = query("2")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, types.ContractType_SUBSCRIPTION.String(), status.Type)
+	require.Equal(t, int64(15), status.Spent.Int64())
+	require.Equal(t, int64(285), status.RemainingDeposit.Int64())
+
+	// contracts the sentinel doesn't know about yet
+	response, _ = query("3")
+	require.Equal(t, http.StatusNotFound, response.Code)
+	response, _ = query("0")
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	response, _ = query("bogus")
+	require.Equal(t, http.StatusBadRequest, response.Code)
+
+	// contracts can't be listed
+	req := httptest.NewRequest(http.MethodGet, "/status/contract/", nil)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.NotEqual(t, http.StatusOK, response.Code)
+}
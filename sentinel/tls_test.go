@@ -0,0 +1,109 @@
+package sentinel
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// writeSelfSignedCertificate writes a self-signed certificate and its key to
+// pem files in the given directory
+func writeSelfSignedCertificate(t *testing.T, dir string) (certFile, keyFile string, cert tls.Certificate) {
+	cert, err := selfSignedCertificate()
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile, cert
+}
+
+func TestServeTLS(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	certFile, keyFile, cert := writeSelfSignedCertificate(t, t.TempDir())
+	config := newTestConfig()
+	config.TLS = conf.TLSConfiguration{Cert: certFile, Key: keyFile, Port: "443", RedirectHTTP: true}
+	require.True(t, config.TLS.HasTLS())
+	tlsConfig, err := serverTLSConfig(config.TLS)
+	require.NoError(t, err)
+
+	proxy := NewProxy(config)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Authorization = types.ContractAuthorization_OPEN
+	contract.Id = 5
+	proxy.MemStore.Put(contract)
+
+	server := httptest.NewUnstartedServer(proxy.getRouter())
+	server.TLS = tlsConfig
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	// the client trusts the self-signed certificate and negotiates http/2
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	client := server.Client()
+	client.Transport.(*http.Transport).TLSClientConfig.RootCAs = pool
+
+	resp, err := client.Get(server.URL + RoutesHealth)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "HTTP/2.0", resp.Proto)
+
+	// paid requests are authorized by arkauth over tls as well
+	resp, err = client.Get(server.URL + "/btc-mainnet-fullnode/?arkauth=5:3")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, int64(3), proxy.ClaimStore.List()[0].Nonce)
+
+	// a missing certificate file fails to load
+	_, err = serverTLSConfig(conf.TLSConfiguration{Cert: certFile + ".missing", Key: keyFile})
+	require.Error(t, err)
+
+	// a self-signed certificate is generated when none is set
+	selfSigned := conf.TLSConfiguration{SelfSigned: true}
+	require.True(t, selfSigned.HasTLS())
+	tlsConfig, err = serverTLSConfig(selfSigned)
+	require.NoError(t, err)
+	require.Len(t, tlsConfig.Certificates, 1)
+	require.Contains(t, tlsConfig.NextProtos, "h2")
+	_, err = serverTLSConfig(conf.TLSConfiguration{})
+	require.Error(t, err)
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	for _, tc := range []struct {
+		port, host, target, location string
+	}{
+		{"443", "example.com", "/health?a=b", "https://example.com/health?a=b"},
+		{"443", "example.com:3636", "/", "https://example.com/"},
+		{"8443", "example.com:3636", "/btc-mainnet-fullnode/", "https://example.com:8443/btc-mainnet-fullnode/"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		redirectHTTPSHandler(tc.port).ServeHTTP(rec, req)
+		require.Equal(t, http.StatusMovedPermanently, rec.Code)
+		require.Equal(t, tc.location, rec.Header().Get("Location"))
+	}
+}
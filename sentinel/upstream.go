@@ -0,0 +1,300 @@
+package sentinel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+)
+
+const (
+	UpstreamBalancingFailover   = "failover"
+	UpstreamBalancingRoundRobin = "round-robin"
+)
+
+// parseUpstreams parses a comma separated list of upstream urls, in the order
+// they are tried
+func parseUpstreams(raw string) []*url.URL {
+	var upstreams []*url.URL
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		upstreams = append(upstreams, common.MustParseURL(item))
+	}
+	return upstreams
+}
+
+type UpstreamStatus = api.UpstreamStatus
+
+type upstreamState struct {
+	healthy   bool
+	checkedAt time.Time
+	err       string
+}
+
+// UpstreamHealth tracks the health of the upstreams of the services, and the
+// next upstream of the round-robin services. Upstreams are tracked by url, so
+// their health survives a reload.
+type UpstreamHealth struct {
+	lock   sync.Mutex
+	states map[string]upstreamState
+	next   map[string]int
+}
+
+func NewUpstreamHealth() *UpstreamHealth {
+	return &UpstreamHealth{
+		states: make(map[string]upstreamState),
+		next:   make(map[string]int),
+	}
+}
+
+// set records the outcome of a health check, or of a request, of an upstream
+func (u *UpstreamHealth) set(uri *url.URL, err error) (changed bool) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	state := upstreamState{healthy: err == nil, checkedAt: time.Now()}
+	if err != nil {
+		state.err = err.Error()
+	}
+	old, ok := u.states[uri.String()]
+	u.states[uri.String()] = state
+	return (ok && old.healthy != state.healthy) || (!ok && !state.healthy)
+}
+
+// healthy returns whether the upstream passed its last check, upstreams never
+// checked are healthy
+func (u *UpstreamHealth) healthy(uri *url.URL) bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	state, ok := u.states[uri.String()]
+	return !ok || state.healthy
+}
+
+// candidates returns the upstreams of a service in the order a request tries
+// them: the healthy ones first, starting from the next one in turn when round
+// robin, then the unhealthy ones as a last resort
+func (u *UpstreamHealth) candidates(service string, upstreams []*url.URL, roundRobin bool) []*url.URL {
+	healthy := make([]*url.URL, 0, len(upstreams))
+	var unhealthy []*url.URL
+	for _, uri := range upstreams {
+		if u.healthy(uri) {
+			healthy = append(healthy, uri)
+		} else {
+			unhealthy = append(unhealthy, uri)
+		}
+	}
+
+	if roundRobin && len(healthy) > 1 {
+		u.lock.Lock()
+		start := u.next[service] % len(healthy)
+		u.next[service] = start + 1
+		u.lock.Unlock()
+		healthy = append(healthy[start:], healthy[:start]...)
+	}
+	return append(healthy, unhealthy...)
+}
+
+// Status returns the health of the given upstreams
+func (u *UpstreamHealth) Status(upstreams []*url.URL) []UpstreamStatus {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	statuses := make([]UpstreamStatus, 0, len(upstreams))
+	for _, uri := range upstreams {
+		status := UpstreamStatus{URL: uri.Redacted(), Healthy: true}
+		if state, ok := u.states[uri.String()]; ok {
+			checkedAt := state.checkedAt
+			status.Healthy = state.healthy
+			status.CheckedAt = &checkedAt
+			status.Error = state.err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// probeUpstream checks the upstream serves the health path with a GET, or only
+// accepts connections when no path is given
+func probeUpstream(uri *url.URL, healthPath string) error {
+	if len(healthPath) == 0 {
+		return dialUpstream(uri)
+	}
+	target := *uri
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(healthPath, "/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamDialTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("health check returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// dialUpstream checks the upstream accepts connections
+func dialUpstream(uri *url.URL) error {
+	host := uri.Host
+	if len(uri.Port()) == 0 {
+		port := "80"
+		if uri.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(uri.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, upstreamDialTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// checkUpstreams probes every upstream of every service once
+func (p Proxy) checkUpstreams() {
+	config := p.config()
+	var wg sync.WaitGroup
+	for service, upstreams := range config.proxies {
+		for _, uri := range upstreams {
+			wg.Add(1)
+			go func(service string, uri *url.URL) {
+				defer wg.Done()
+				err := probeUpstream(uri, config.UpstreamHealthPath)
+				if !p.Upstreams.set(uri, err) {
+					return
+				}
+				if err != nil {
+					p.logger.Error("upstream unhealthy", "service", service, "upstream", uri.Redacted(), "error", err)
+				} else {
+					p.logger.Info("upstream healthy again", "service", service, "upstream", uri.Redacted())
+				}
+			}(service, uri)
+		}
+	}
+	wg.Wait()
+}
+
+// UpstreamHealthChecker probes the upstreams at the given interval, until the
+// proxy shuts down
+func (p Proxy) UpstreamHealthChecker(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.checkUpstreams()
+		select {
+		case <-p.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// upstreamFault returns whether a request failed because of the upstream,
+// rather than timing out or being canceled by the client
+func upstreamFault(r *http.Request, err error) bool {
+	return r.Context().Err() == nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled)
+}
+
+// retryable returns whether a request failing before any response can be sent
+// again to another upstream
+func retryable(r *http.Request, err error) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return r.ContentLength == 0 && upstreamFault(r, err)
+}
+
+// upstreamStatusError fails an upstream response with a server error, so the
+// request is tried again with the next candidate
+type upstreamStatusError struct {
+	code int
+}
+
+func (e upstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream returned %d", e.code)
+}
+
+// serveUpstream proxies the request to the first of the candidate upstreams. A
+// GET or HEAD request failing before any response is sent, or answered with a
+// server error, is tried again with the next candidate. The response of the
+// last candidate is sent as is. The request is paid for once, before it is
+// proxied, whatever the number of attempts. The returned func reports whether
+// the response was truncated. A client ip given is sent as the only
+// X-Forwarded-For of the upstream requests.
+func (p Proxy) serveUpstream(w http.ResponseWriter, r *http.Request, candidates []*url.URL, target func(*url.URL) url.URL, limits conf.ProxyLimits, contractId uint64, clientIP string) func() bool {
+	uri := candidates[0]
+	upstream := target(uri)
+	outgoing := r.WithContext(r.Context())
+	outgoing.URL = &upstream
+
+	proxy := common.NewSingleHostReverseProxy(outgoing.URL)
+	if len(clientIP) > 0 {
+		forwardClientIP(proxy, clientIP)
+	}
+	truncated := p.limitProxy(proxy, limits, contractId)
+	if len(candidates) > 1 && retryable(r, nil) {
+		modify := proxy.ModifyResponse
+		proxy.ModifyResponse = func(res *http.Response) error {
+			if res.StatusCode >= http.StatusInternalServerError {
+				return upstreamStatusError{code: res.StatusCode}
+			}
+			if modify != nil {
+				return modify(res)
+			}
+			return nil
+		}
+	}
+	failed := proxy.ErrorHandler
+	proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, err error) {
+		if upstreamFault(r, err) && p.Config.UpstreamHealthInterval > 0 && p.Upstreams.set(uri, err) {
+			// the health checks tell when the upstream is back
+			p.logger.Error("upstream unhealthy", "upstream", uri.Redacted(), "error", err)
+		}
+		if len(candidates) < 2 || !retryable(r, err) {
+			failed(rw, req, err)
+			return
+		}
+		p.logger.Error("upstream error, retrying with the next upstream", "error", err, "upstream", uri.Redacted(), "contract_id", contractId)
+		truncated = p.serveUpstream(w, r, candidates[1:], target, limits, contractId, clientIP)
+	}
+
+	proxy.ServeHTTP(w, outgoing)
+	return func() bool { return truncated() }
+}
+
+// upstreamURL returns the url of a request once sent to the given upstream
+func upstreamURL(incoming url.URL, uri *url.URL, pulledFromPath bool) url.URL {
+	target := incoming
+	target.Scheme = uri.Scheme
+	target.Host = uri.Host
+	target.User = uri.User
+	if pulledFromPath {
+		parts := strings.Split(incoming.Path, "/")
+		parts[1] = uri.Path // replace service name with uri path (if exists)
+		target.Path = path.Join(parts...)
+	}
+
+	// Sanitize URL
+	// ensure path always has "/" prefix
+	if len(target.Path) > 1 && !strings.HasPrefix(target.Path, "/") {
+		target.Path = fmt.Sprintf("/%s", target.Path)
+	}
+	return target
+}
@@ -0,0 +1,286 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func newNamedUpstream(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(name))
+	}))
+}
+
+// setUpstreams points the proxy to the given upstreams of the btc service only,
+// so health checks don't probe the default services
+func setUpstreams(p Proxy, uris ...*url.URL) {
+	proxies := map[string][]*url.URL{common.BTCService.String(): uris}
+	p.snapshot.Store(&configSnapshot{Configuration: p.config().Configuration, proxies: proxies})
+}
+
+func TestParseUpstreams(t *testing.T) {
+	upstreams := parseUpstreams("http://first:8332, http://second:8332/path,")
+	require.Len(t, upstreams, 2)
+	require.Equal(t, "http://first:8332", upstreams[0].String())
+	require.Equal(t, "http://second:8332/path", upstreams[1].String())
+}
+
+func TestUpstreamFailover(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+
+	first := newNamedUpstream("first")
+	second := newNamedUpstream("second")
+	defer second.Close()
+
+	testConfig := newTestConfig()
+	testConfig.UpstreamBalancing = UpstreamBalancingFailover
+	testConfig.UpstreamHealthInterval = 10
+	proxy := NewProxy(testConfig)
+	firstURL := common.MustParseURL(first.URL)
+	setUpstreams(proxy, firstURL, common.MustParseURL(second.URL))
+	router := proxy.getRouter()
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/btc-mainnet-fullnode/", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// every request goes to the first healthy upstream
+	for i := 0; i < 3; i++ {
+		response := serve(http.MethodGet)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "first", response.Body.String())
+	}
+
+	// the first upstream dies, the request failing on it is sent to the second
+	// one, and so are the next ones
+	first.Close()
+	for i := 0; i < 3; i++ {
+		response := serve(http.MethodGet)
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "second", response.Body.String())
+	}
+	require.False(t, proxy.Upstreams.healthy(firstURL))
+
+	// the dead upstream is still tried last
+	candidates := proxy.Upstreams.candidates(common.BTCService.String(), proxy.config().proxies[common.BTCService.String()], false)
+	require.Equal(t, first.URL, candidates[1].String())
+}
+
+func TestUpstreamRetryIdempotentOnly(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+
+	dead := newNamedUpstream("dead")
+	dead.Close()
+	alive := newNamedUpstream("alive")
+	defer alive.Close()
+
+	proxy := NewProxy(newTestConfig())
+	setUpstreams(proxy, common.MustParseURL(dead.URL), common.MustParseURL(alive.URL))
+	router := proxy.getRouter()
+
+	serve := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/btc-mainnet-fullnode/", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+
+	// a POST may have reached the upstream, it is not sent again
+	require.Equal(t, http.StatusBadGateway, serve(http.MethodPost).Code)
+	response := serve(http.MethodGet)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "alive", response.Body.String())
+
+	// without health checks, a failing upstream isn't marked unhealthy as
+	// nothing would mark it healthy again
+	require.True(t, proxy.Upstreams.healthy(common.MustParseURL(dead.URL)))
+
+	// no upstream left to retry with
+	setUpstreams(proxy, common.MustParseURL(dead.URL))
+	require.Equal(t, http.StatusBadGateway, serve(http.MethodGet).Code)
+}
+
+func TestUpstreamRoundRobin(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+
+	first := newNamedUpstream("first")
+	defer first.Close()
+	second := newNamedUpstream("second")
+
+	testConfig := newTestConfig()
+	testConfig.UpstreamBalancing = UpstreamBalancingRoundRobin
+	testConfig.UpstreamHealthInterval = 10
+	testConfig.UpstreamHealthPath = "/health"
+	proxy := NewProxy(testConfig)
+	secondURL := common.MustParseURL(second.URL)
+	secondURL.User = url.UserPassword("infra", "password")
+	setUpstreams(proxy, common.MustParseURL(first.URL), secondURL)
+	router := proxy.getRouter()
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+	status := func() Status {
+		req := httptest.NewRequest(http.MethodGet, RoutesStatus, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		require.Equal(t, http.StatusOK, response.Code)
+		var status Status
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &status))
+		return status
+	}
+
+	// requests are spread among the healthy upstreams
+	proxy.checkUpstreams()
+	counts := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		response := serve()
+		require.Equal(t, http.StatusOK, response.Code)
+		counts[response.Body.String()]++
+	}
+	require.Equal(t, map[string]int{"first": 2, "second": 2}, counts)
+
+	upstreams := status().Upstreams[common.BTCService.String()]
+	require.Len(t, upstreams, 2)
+	require.True(t, upstreams[0].Healthy)
+	require.True(t, upstreams[1].Healthy)
+	require.NotContains(t, upstreams[1].URL, "password")
+
+	// the second upstream dies, the health check moves all traffic to the
+	// first one
+	second.Close()
+	proxy.checkUpstreams()
+	for i := 0; i < 4; i++ {
+		response := serve()
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "first", response.Body.String())
+	}
+
+	upstreams = status().Upstreams[common.BTCService.String()]
+	require.True(t, upstreams[0].Healthy)
+	require.False(t, upstreams[1].Healthy)
+	require.NotEmpty(t, upstreams[1].Error)
+	require.NotNil(t, upstreams[1].CheckedAt)
+}
+
+func TestUpstreamServerErrorFailover(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+
+	// the flapping upstream fails every other request
+	var flaps, posts atomic.Int64
+	flapping := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPost {
+			posts.Add(1)
+		}
+		if flaps.Add(1)%2 == 0 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write([]byte("flapping"))
+	}))
+	defer flapping.Close()
+	stable := newNamedUpstream("stable")
+	defer stable.Close()
+
+	proxy := newLimitsTestProxy(conf.ProxyLimits{}, flapping)
+	setUpstreams(proxy, common.MustParseURL(flapping.URL), common.MustParseURL(stable.URL))
+	router := proxy.getRouter()
+
+	serve := func(method string, nonce int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=5:%d", nonce), nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		return response
+	}
+	paidNonce := func() int64 {
+		nonce, err := proxy.paidNonce(5)
+		require.NoError(t, err)
+		return nonce
+	}
+
+	// a server error is retried with the next upstream, the request is paid
+	// once
+	for nonce := int64(1); nonce <= 4; nonce++ {
+		response := serve(http.MethodGet, nonce)
+		require.Equal(t, http.StatusOK, response.Code)
+		expected := "flapping"
+		if nonce%2 == 0 {
+			expected = "stable"
+		}
+		require.Equal(t, expected, response.Body.String())
+		require.Equal(t, nonce, paidNonce())
+	}
+
+	// a POST reached the upstream, its server error is sent as is and isn't
+	// paid for
+	flaps.Store(1)
+	require.Equal(t, http.StatusServiceUnavailable, serve(http.MethodPost, 5).Code)
+	require.Equal(t, int64(1), posts.Load())
+	require.Equal(t, int64(4), paidNonce())
+
+	// the response of the last upstream is sent as is
+	setUpstreams(proxy, common.MustParseURL(flapping.URL))
+	flaps.Store(1)
+	require.Equal(t, http.StatusServiceUnavailable, serve(http.MethodGet, 5).Code)
+	require.Equal(t, int64(4), paidNonce())
+}
+
+func TestUpstreamTimeoutNotRetried(t *testing.T) {
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+
+	slow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		select {
+		case <-time.After(3 * time.Second):
+		case <-req.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	var fastCalls atomic.Int64
+	fast := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fastCalls.Add(1)
+	}))
+	defer fast.Close()
+
+	// the global timeout is lowered by the contract
+	proxy := newLimitsTestProxy(conf.ProxyLimits{UpstreamTimeoutSec: 60}, slow)
+	setUpstreams(proxy, common.MustParseURL(slow.URL), common.MustParseURL(fast.URL))
+	contractConf := NewContractConfiguration(5, NewCORs(), nil, 0)
+	contractConf.Limits = ContractLimits{UpstreamTimeoutSec: 1}
+	require.NoError(t, proxy.ContractConfigStore.Set(contractConf))
+	router := proxy.getRouter()
+
+	start := time.Now()
+	req := httptest.NewRequest(http.MethodGet, "/btc-mainnet-fullnode/?arkauth=5:1", nil)
+	req.RemoteAddr = "10.0.0.1:1000"
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, req)
+	require.Equal(t, http.StatusGatewayTimeout, response.Code)
+	require.Less(t, time.Since(start), 3*time.Second)
+
+	// the deadline is spent, the request isn't sent to the next upstream
+	require.Zero(t, fastCalls.Load())
+	nonce, err := proxy.paidNonce(5)
+	require.NoError(t, err)
+	require.Zero(t, nonce)
+}
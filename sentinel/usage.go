@@ -0,0 +1,141 @@
+package sentinel
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+)
+
+type (
+	Usage      = api.Usage
+	DailyUsage = api.DailyUsage
+)
+
+const (
+	// prefix of the keys of the contract usages in the claim store
+	usageKeyPrefix = "usage/"
+	// number of days of the request histogram of a contract
+	usageHistoryDays = 30
+	secondsPerDay    = 24 * 60 * 60
+)
+
+// ContractUsage counts the paid requests served for a contract
+type ContractUsage struct {
+	ContractId uint64 `json:"contract_id"`
+	Requests   int64  `json:"requests"`
+	// requests per day, a ring buffer indexed by the day number
+	Days [usageHistoryDays]UsageDay `json:"days"`
+}
+
+// UsageDay is the number of requests of a day, counted in days since the
+// unix epoch
+type UsageDay struct {
+	Day      int64 `json:"day"`
+	Requests int64 `json:"requests"`
+}
+
+func (u ContractUsage) Key() string {
+	return usageKeyPrefix + strconv.FormatUint(u.ContractId, 10)
+}
+
+// Add counts a request served at the given time
+func (u *ContractUsage) Add(at time.Time) {
+	number := at.Unix() / secondsPerDay
+	slot := &u.Days[number%usageHistoryDays]
+	if slot.Day != number {
+		*slot = UsageDay{Day: number}
+	}
+	slot.Requests++
+	u.Requests++
+}
+
+// Daily returns the requests of the days of the histogram, up to the given
+// time, oldest first
+func (u ContractUsage) Daily(now time.Time) []DailyUsage {
+	today := now.Unix() / secondsPerDay
+	daily := make([]DailyUsage, 0, usageHistoryDays)
+	for number := today - usageHistoryDays + 1; number <= today; number++ {
+		usage := DailyUsage{Date: time.Unix(number*secondsPerDay, 0).UTC().Format("2006-01-02")}
+		if slot := u.Days[number%usageHistoryDays]; slot.Day == number {
+			usage.Requests = slot.Requests
+		}
+		daily = append(daily, usage)
+	}
+	return daily
+}
+
+// handleUsage returns the usage of a contract to its spender. The request is
+// authenticated by an arkauth of the contract signed by the spender, its nonce
+// isn't consumed.
+func (p Proxy) handleUsage(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	contractId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil || contractId == 0 {
+		respondWithError(w, fmt.Sprintf("bad contractId: %s", id), http.StatusBadRequest)
+		return
+	}
+
+	aa, err := p.fetchArkAuth(r)
+	if err != nil {
+		respondWithProxyError(w, http.StatusBadRequest, newProxyError(ErrCodeBadArkAuth, "%w", err))
+		return
+	}
+	if aa.ContractId == 0 || len(aa.Signature) == 0 {
+		respondWithProxyError(w, http.StatusUnauthorized, newProxyError(ErrCodeBadArkAuth, "missing arkauth"))
+		return
+	}
+	if aa.ContractId != contractId {
+		respondWithProxyError(w, http.StatusForbidden, newProxyError(ErrCodeBadArkAuth, "arkauth of contract %d can't read the usage of contract %d", aa.ContractId, contractId))
+		return
+	}
+
+	key := strconv.FormatUint(contractId, 10)
+	contract, err := p.MemStore.Get(key)
+	if err != nil || contract.Client.IsEmpty() || !contract.Provider.Equals(p.Config.ProviderPubKey) {
+		respondWithProxyError(w, http.StatusNotFound, newProxyError(ErrCodeUnknownContract, "unknown contract %d", contractId))
+		return
+	}
+	// the usage is only shown to the spender, even for open contracts
+	if err := aa.Validate(p.Config.ProviderPubKey); err != nil {
+		respondWithProxyError(w, http.StatusUnauthorized, newProxyError(ErrCodeBadSignature, "%w", err))
+		return
+	}
+	if _, ok := p.arkAuthSpender(aa, contract); !ok {
+		respondWithProxyError(w, http.StatusForbidden, newProxyError(ErrCodeBadSignature, "invalid signature, not made by the client of contract %d", contractId))
+		return
+	}
+
+	usage, err := p.ClaimStore.GetUsage(contractId)
+	if err != nil {
+		respondWithProxyError(w, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "fail to get usage: %w", err))
+		return
+	}
+	nonce, err := p.paidNonce(contractId)
+	if err != nil {
+		respondWithProxyError(w, http.StatusInternalServerError, newProxyError(ErrCodeInternal, "fail to get nonce: %w", err))
+		return
+	}
+	height := p.MemStore.GetHeight()
+	res := Usage{
+		ContractId:       contractId,
+		Requests:         usage.Requests,
+		Nonce:            nonce,
+		ExpirationHeight: contract.Expiration(),
+		Height:           height,
+		Daily:            usage.Daily(time.Now()),
+	}
+	if contract.IsPayAsYouGo() {
+		// the claim may be ahead of the nonce known by the chain
+		if nonce > contract.Nonce {
+			contract.Nonce = nonce
+		}
+		remaining := NewContractStatus(contract, height).RemainingDeposit
+		res.RemainingDeposit = &remaining
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
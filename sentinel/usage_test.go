@@ -0,0 +1,142 @@
+package sentinel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func TestContractUsage(t *testing.T) {
+	usage := ContractUsage{ContractId: 3}
+	require.Equal(t, "usage/3", usage.Key())
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	usage.Add(start)
+	usage.Add(start.Add(time.Hour))
+	usage.Add(start.Add(24 * time.Hour))
+	daily := usage.Daily(start.Add(24 * time.Hour))
+	require.Len(t, daily, usageHistoryDays)
+	require.Equal(t, DailyUsage{Date: "2024-03-02", Requests: 1}, daily[usageHistoryDays-1])
+	require.Equal(t, DailyUsage{Date: "2024-03-01", Requests: 2}, daily[usageHistoryDays-2])
+	require.Equal(t, DailyUsage{Date: "2024-02-02", Requests: 0}, daily[0])
+
+	// the slot of a day is reused once it leaves the histogram
+	later := start.Add(usageHistoryDays * 24 * time.Hour)
+	usage.Add(later)
+	daily = usage.Daily(later)
+	require.Equal(t, DailyUsage{Date: "2024-03-31", Requests: 1}, daily[usageHistoryDays-1])
+	require.Equal(t, DailyUsage{Date: "2024-03-02", Requests: 1}, daily[0])
+	require.Equal(t, int64(4), usage.Requests)
+
+	// the usage is stored alongside the claims, but isn't listed with them
+	store, err := NewClaimStore("")
+	require.NoError(t, err)
+	defer store.Close()
+	empty, err := store.GetUsage(3)
+	require.NoError(t, err)
+	require.Zero(t, empty.Requests)
+	claim := NewClaim(3, types.GetRandomPubKey(), 4, "sig")
+	require.NoError(t, store.Commit(&claim, usage, nil))
+	stored, err := store.GetUsage(3)
+	require.NoError(t, err)
+	require.Equal(t, usage, stored)
+	require.Len(t, store.List(), 1)
+}
+
+func TestUsageEndpoint(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer upstream.Close()
+
+	visitors = make(map[string]*rate.Limiter) // reset visitors
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	proxy.MemStore.SetHeight(20)
+	router := proxy.getRouter()
+
+	newContract := func(id uint64) (types.Contract, *secp256k1.PrivKey) {
+		key := secp256k1.GenPrivKey()
+		spender, err := common.NewPubKeyFromCrypto(key.PubKey())
+		require.NoError(t, err)
+		contract := types.NewContract(testConfig.ProviderPubKey, common.BTCService, spender)
+		contract.Type = types.ContractType_PAY_AS_YOU_GO
+		contract.Authorization = types.ContractAuthorization_OPEN
+		contract.Rate = cosmos.NewInt64Coin("uarkeo", 2)
+		contract.Deposit = cosmos.NewInt(100)
+		contract.Height = 10
+		contract.Duration = 100
+		contract.Id = id
+		contract.QueriesPerMinute = 100
+		proxy.MemStore.Put(contract)
+		return contract, key
+	}
+	contract, key := newContract(1)
+	other, otherKey := newContract(2)
+	arkAuth := func(key *secp256k1.PrivKey, contractId uint64, nonce int64) string {
+		signature, err := key.Sign(types.GetBytesToSign(contractId, nonce))
+		require.NoError(t, err)
+		return api.ArkAuthString(contractId, nonce, signature)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+		return response
+	}
+
+	// paid requests of the open contract, unsigned
+	for nonce := int64(1); nonce <= 3; nonce++ {
+		response := get(fmt.Sprintf("/btc-mainnet-fullnode/?arkauth=1:%d", nonce))
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Equal(t, "paid", response.Header().Get("tier"))
+	}
+
+	// the usage of an open contract is still only shown to its spender
+	response := get("/v1/usage/1")
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	response = get("/v1/usage/1?arkauth=1:1")
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	response = get("/v1/usage/1?arkauth=" + arkAuth(otherKey, contract.Id, 1))
+	require.Equal(t, http.StatusForbidden, response.Code)
+	// the arkauth of another contract is refused
+	response = get("/v1/usage/1?arkauth=" + arkAuth(otherKey, other.Id, 1))
+	require.Equal(t, http.StatusForbidden, response.Code)
+	require.Contains(t, response.Body.String(), "can't read the usage of contract 1")
+
+	// any nonce of the spender, used or not
+	var usage Usage
+	for _, nonce := range []int64{1, 1, 500} {
+		response = get("/usage/1?arkauth=" + arkAuth(key, contract.Id, nonce))
+		require.Equal(t, http.StatusOK, response.Code)
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &usage))
+	}
+	require.Equal(t, contract.Id, usage.ContractId)
+	require.Equal(t, int64(3), usage.Requests)
+	require.Equal(t, int64(3), usage.Nonce)
+	require.Equal(t, int64(94), usage.RemainingDeposit.Int64())
+	require.Equal(t, contract.Expiration(), usage.ExpirationHeight)
+	require.Equal(t, int64(20), usage.Height)
+	require.Equal(t, int64(3), usage.Daily[usageHistoryDays-1].Requests)
+	nonce, err := proxy.paidNonce(contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), nonce)
+
+	// the usage endpoint isn't a paid request
+	response = get("/v1/usage/2?arkauth=" + arkAuth(otherKey, other.Id, 1))
+	require.Equal(t, http.StatusOK, response.Code)
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &usage))
+	require.Zero(t, usage.Requests)
+	require.Zero(t, usage.Nonce)
+	require.Equal(t, int64(100), usage.RemainingDeposit.Int64())
+}
@@ -0,0 +1,98 @@
+package sentinel
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+type ArkAuthValidation = api.ArkAuthValidation
+
+// handleValidate diagnoses the arkauth of the request for client developers.
+// It is a dry run: nothing is charged and the nonce isn't consumed. The
+// contract isn't looked up, so the answer doesn't tell whether it exists: the
+// signature is only checked against the spender pubkey given by the client.
+func (p Proxy) handleValidate(w http.ResponseWriter, r *http.Request) {
+	args := r.URL.Query()
+	raw := args.Get(QueryArkAuth)
+	if len(raw) == 0 {
+		raw = r.Header.Get(QueryArkAuth)
+	}
+	respondWithJSON(w, http.StatusOK, p.validateArkAuth(raw, args.Get(api.SpenderParam)))
+}
+
+// validateArkAuth runs the checks of a paid request that don't depend on the
+// state of the contract, the first one failing is reported
+func (p Proxy) validateArkAuth(raw, spender string) ArkAuthValidation {
+	res := ArkAuthValidation{
+		Provider: p.Config.ProviderPubKey,
+		Spender:  spender,
+	}
+	fail := func(code ErrorCode, format string, args ...interface{}) ArkAuthValidation {
+		res.Code = code
+		res.Error = fmt.Sprintf(format, args...)
+		return res
+	}
+
+	if len(raw) == 0 {
+		return fail(ErrCodeBadArkAuth, "missing arkauth, expected contract_id:nonce:signature")
+	}
+	aa, err := parseArkAuth(raw)
+	res.ContractId = aa.ContractId
+	res.Nonce = aa.Nonce
+	if err == nil || errors.Is(err, errArkAuthSignature) {
+		res.MessageToSign = string(p.signingDomain().BytesToSign(aa.ContractId, aa.Nonce))
+	}
+	switch {
+	case errors.Is(err, errArkAuthContractId):
+		return fail(ErrCodeBadArkAuth, "%s", err)
+	case errors.Is(err, errArkAuthNonce):
+		return fail(ErrCodeBadNonce, "%s", err)
+	case errors.Is(err, errArkAuthSignature):
+		return fail(ErrCodeBadSignature, "%s", err)
+	case err != nil:
+		return fail(ErrCodeBadArkAuth, "%s", err)
+	}
+	res.Signature = hex.EncodeToString(aa.Signature)
+
+	if aa.ContractId == 0 {
+		return fail(ErrCodeBadArkAuth, "contract id cannot be zero")
+	}
+	if err := aa.Validate(p.Config.ProviderPubKey); err != nil {
+		switch {
+		case errors.Is(err, types.ErrClaimContractIncomeBadNonce):
+			return fail(ErrCodeBadNonce, "nonce must be positive: %s", err)
+		case errors.Is(err, types.ErrClaimContractIncomeInvalidSignature):
+			return fail(ErrCodeBadSignature, "%s", err)
+		default:
+			return fail(ErrCodeInternal, "%s", err)
+		}
+	}
+	if len(aa.Signature) == 0 {
+		return fail(ErrCodeBadSignature, "missing signature, expected contract_id:nonce:signature")
+	}
+
+	if len(spender) > 0 {
+		pubkey, err := common.NewPubKey(spender)
+		if err != nil {
+			return fail(ErrCodeBadArkAuth, "bad spender pubkey: %s", err)
+		}
+		pk, err := cosmos.GetPubKeyFromBech32(cosmos.Bech32PubKeyTypeAccPub, pubkey.String())
+		if err != nil {
+			return fail(ErrCodeBadArkAuth, "bad spender pubkey: %s", err)
+		}
+		if !p.signingDomain().Verify(pk, aa.ContractId, aa.Nonce, aa.Signature) {
+			return fail(ErrCodeBadSignature, "invalid signature, the spender must sign %q", res.MessageToSign)
+		}
+		res.SignatureVerified = true
+	}
+
+	res.Valid = true
+	return res
+}
@@ -0,0 +1,126 @@
+package sentinel
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/sentinel/api"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func TestValidateArkAuth(t *testing.T) {
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+
+	key := secp256k1.GenPrivKey()
+	spender, err := common.NewPubKeyFromCrypto(key.PubKey())
+	require.NoError(t, err)
+	signature, err := key.Sign(types.GetBytesToSign(5, 3))
+	require.NoError(t, err)
+	sig := hex.EncodeToString(signature)
+
+	// happy path, without and with the spender
+	res := proxy.validateArkAuth("5:3:"+sig, "")
+	require.True(t, res.Valid)
+	require.Equal(t, uint64(5), res.ContractId)
+	require.Equal(t, int64(3), res.Nonce)
+	require.Equal(t, sig, res.Signature)
+	require.Equal(t, "5:3", res.MessageToSign)
+	require.Equal(t, testConfig.ProviderPubKey, res.Provider)
+	require.False(t, res.SignatureVerified)
+	require.Empty(t, res.Error)
+	res = proxy.validateArkAuth("5:3:"+sig, spender.String())
+	require.True(t, res.Valid)
+	require.True(t, res.SignatureVerified)
+
+	failure := func(raw, spender string, code ErrorCode, reason string) ArkAuthValidation {
+		res := proxy.validateArkAuth(raw, spender)
+		require.False(t, res.Valid, raw)
+		require.False(t, res.SignatureVerified, raw)
+		require.Equal(t, code, res.Code, raw)
+		require.Contains(t, res.Error, reason, raw)
+		return res
+	}
+	failure("", "", ErrCodeBadArkAuth, "missing arkauth")
+	failure("five:3:"+sig, "", ErrCodeBadArkAuth, "bad contract id")
+	failure("0:3:"+sig, "", ErrCodeBadArkAuth, "contract id cannot be zero")
+
+	// the fields parsed before the failure are returned
+	res = failure("5:three:"+sig, "", ErrCodeBadNonce, "bad nonce")
+	require.Equal(t, uint64(5), res.ContractId)
+	require.Empty(t, res.MessageToSign)
+	failure("5:0:"+sig, "", ErrCodeBadNonce, "nonce must be positive")
+	failure("5:-1:"+sig, "", ErrCodeBadNonce, "nonce must be positive")
+
+	res = failure("5:3:not hex!", "", ErrCodeBadSignature, "must be hex encoded")
+	require.Equal(t, int64(3), res.Nonce)
+	require.Equal(t, "5:3", res.MessageToSign)
+	failure("5:3", "", ErrCodeBadSignature, "missing signature")
+	failure("5:3:"+strings.Repeat("ab", 101), "", ErrCodeBadSignature, "too long")
+
+	// signed with another key, or over another message
+	other := types.GetRandomPubKey()
+	failure("5:3:"+sig, other.String(), ErrCodeBadSignature, `the spender must sign "5:3"`)
+	failure("5:4:"+sig, spender.String(), ErrCodeBadSignature, `the spender must sign "5:4"`)
+	failure("5:3:"+sig, "not a pubkey", ErrCodeBadArkAuth, "bad spender pubkey")
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	testConfig := newTestConfig()
+	proxy := NewProxy(testConfig)
+	router := proxy.getRouter()
+
+	key := secp256k1.GenPrivKey()
+	spender, err := common.NewPubKeyFromCrypto(key.PubKey())
+	require.NoError(t, err)
+	contract := newTestContract(testConfig.ProviderPubKey, common.BTCService, spender)
+	contract.Id = 5
+	proxy.MemStore.Put(contract)
+
+	validate := func(contractId uint64, header bool) ArkAuthValidation {
+		signature, err := key.Sign(types.GetBytesToSign(contractId, 3))
+		require.NoError(t, err)
+		arkauth := GenerateArkAuthString(contractId, 3, signature)
+		query := url.Values{api.SpenderParam: {spender.String()}}
+		if !header {
+			query.Set(QueryArkAuth, arkauth)
+		}
+		req := httptest.NewRequest(http.MethodGet, api.V1Prefix+RoutesValidate+"?"+query.Encode(), nil)
+		if header {
+			req.Header.Set(QueryArkAuth, arkauth)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, req)
+		require.Equal(t, http.StatusOK, response.Code)
+		var res ArkAuthValidation
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &res))
+		require.True(t, res.Valid)
+		require.True(t, res.SignatureVerified)
+		require.Equal(t, contractId, res.ContractId)
+		return res
+	}
+
+	// the answer for an unknown contract only differs by what the arkauth
+	// carries, the existence of the contract isn't leaked
+	known := validate(contract.Id, false)
+	unknown := validate(99, true)
+	unknown.ContractId = known.ContractId
+	unknown.Signature = known.Signature
+	unknown.MessageToSign = known.MessageToSign
+	require.Equal(t, known, unknown)
+
+	// nothing is charged
+	require.False(t, proxy.ClaimStore.Has(contract.Key()))
+	nonce, err := proxy.paidNonce(contract.Id)
+	require.NoError(t, err)
+	require.Zero(t, nonce)
+}
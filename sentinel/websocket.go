@@ -0,0 +1,155 @@
+package sentinel
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// what counts against the rate limit of a websocket
+const (
+	WebSocketRateLimitConnections = "connections"
+	WebSocketRateLimitMessages    = "messages"
+)
+
+var (
+	// how often the contract of a websocket is checked for expiry
+	webSocketExpiryInterval = 5 * time.Second
+
+	errWebSocketRateLimited = errors.New("websocket is rate limited")
+)
+
+// the auth middleware already checked the request, including its origin
+// against the CORS of the contract
+var webSocketUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// proxyWebSocket dials the upstream service with the given static headers,
+// upgrades the client connection and copies the messages both ways. The
+// arkauth is validated once by the auth middleware, when the connection is
+// opened. The socket is closed once the contract paying for it expires.
+func (p Proxy) proxyWebSocket(w http.ResponseWriter, r *http.Request, serviceName string, target url.URL, headers map[string]string) {
+	contractId := getContractId(r.Context())
+
+	if target.Scheme == "https" {
+		target.Scheme = "wss"
+	} else {
+		target.Scheme = "ws"
+	}
+	header := make(http.Header)
+	for name, value := range headers {
+		header.Set(name, value)
+	}
+	if target.User != nil {
+		passwd, _ := target.User.Password()
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(target.User.Username()+":"+passwd)))
+		target.User = nil
+	}
+	upstream, _, err := websocket.DefaultDialer.DialContext(r.Context(), target.String(), header)
+	if err != nil {
+		p.logger.Error("fail to dial upstream websocket", "error", err, "contract_id", contractId)
+		respondWithError(w, "fail to connect to upstream service", http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	client, err := webSocketUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already responded to the client
+		p.logger.Error("fail to upgrade websocket", "error", err, "contract_id", contractId)
+		return
+	}
+	defer client.Close()
+
+	var allow func() bool
+	if p.Config.WebSocketRateLimit == WebSocketRateLimitMessages {
+		allow = p.webSocketMessageLimiter(contractId, serviceName, p.getRemoteAddr(r))
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- copyWebSocket(upstream, client, allow) }()
+	go func() { errs <- copyWebSocket(client, upstream, nil) }()
+
+	ticker := time.NewTicker(webSocketExpiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-errs:
+			if errors.Is(err, errWebSocketRateLimited) {
+				closeWebSocket(client, websocket.ClosePolicyViolation, "rate limited")
+				closeWebSocket(upstream, websocket.CloseNormalClosure, "")
+			}
+			return
+		case <-ticker.C:
+			if contractId > 0 && p.isContractExpired(contractId) {
+				p.logger.Info("closing websocket of expired contract", "contract_id", contractId)
+				closeWebSocket(client, websocket.CloseNormalClosure, "contract expired")
+				closeWebSocket(upstream, websocket.CloseNormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+// webSocketMessageLimiter counts every message sent by the client against
+// the rate limit of its tier
+func (p Proxy) webSocketMessageLimiter(contractId uint64, serviceName, remoteAddr string) func() bool {
+	if contractId == 0 {
+		return func() bool {
+			_, err := p.freeTier(serviceName, remoteAddr)
+			return err == nil
+		}
+	}
+	key := strconv.FormatUint(contractId, 10)
+	return func() bool {
+		contract, err := p.MemStore.Get(key)
+		if err != nil {
+			return false
+		}
+		return !p.isRateLimited(contract.Id, key, int(contract.QueriesPerMinute))
+	}
+}
+
+func (p Proxy) isContractExpired(contractId uint64) bool {
+	contract, err := p.MemStore.Get(strconv.FormatUint(contractId, 10))
+	if err != nil {
+		return true
+	}
+	return contract.IsExpired(p.MemStore.GetHeight())
+}
+
+// copyWebSocket copies the messages of src to dst, until either side fails.
+// A close message from src is forwarded to dst.
+func copyWebSocket(dst, src *websocket.Conn, allow func() bool) error {
+	for {
+		msgType, msg, err := src.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				code := closeErr.Code
+				if code == websocket.CloseNoStatusReceived {
+					code = websocket.CloseNormalClosure
+				}
+				closeWebSocket(dst, code, closeErr.Text)
+			}
+			return err
+		}
+		if allow != nil && !allow() {
+			return errWebSocketRateLimited
+		}
+		if err := dst.WriteMessage(msgType, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func closeWebSocket(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
@@ -0,0 +1,107 @@
+package sentinel
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func newEchoWebSocketServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(rw, req, nil)
+		if err != nil {
+			t.Logf("fail to upgrade: %s", err)
+			return
+		}
+		defer conn.Close()
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func TestProxyWebSocket(t *testing.T) {
+	interval := webSocketExpiryInterval
+	webSocketExpiryInterval = 50 * time.Millisecond
+	defer func() { webSocketExpiryInterval = interval }()
+
+	upstream := newEchoWebSocketServer(t)
+	defer upstream.Close()
+
+	testConfig := newTestConfig()
+	testConfig.WebSocketRateLimit = WebSocketRateLimitMessages
+	proxy := NewProxy(testConfig)
+	setServiceURL(proxy, common.BTCService.String(), common.MustParseURL(upstream.URL))
+	newContract := func(id uint64, queriesPerMinute int64) {
+		contract := newTestContract(proxy.Config.ProviderPubKey, common.BTCService, types.GetRandomPubKey())
+		contract.Authorization = types.ContractAuthorization_OPEN
+		contract.Id = id
+		contract.QueriesPerMinute = queriesPerMinute
+		proxy.MemStore.Put(contract)
+	}
+	newContract(21, 100)
+	newContract(22, 3)
+	proxy.MemStore.SetHeight(20)
+	server := httptest.NewServer(proxy.getRouter())
+	defer server.Close()
+
+	dial := func(contractId uint64, nonce int64) *websocket.Conn {
+		wsURL := fmt.Sprintf("%s/btc-mainnet-fullnode/?arkauth=%d:%d", strings.Replace(server.URL, "http", "ws", 1), contractId, nonce)
+		conn, res, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+		return conn
+	}
+	echo := func(conn *websocket.Conn, msg string) error {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			return err
+		}
+		_, reply, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		require.Equal(t, msg, string(reply))
+		return nil
+	}
+
+	// messages are copied both ways
+	conn := dial(21, 1)
+	defer conn.Close()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, echo(conn, fmt.Sprintf(`{"id":%d}`, i)))
+	}
+	claim, err := proxy.ClaimStore.Get("21")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), claim.Nonce)
+
+	// messages count against the rate limit of the contract, the upgrade
+	// request already took one
+	limited := dial(22, 1)
+	defer limited.Close()
+	require.NoError(t, echo(limited, "one"))
+	require.NoError(t, echo(limited, "two"))
+	err = echo(limited, "three")
+	require.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), err)
+
+	// the socket is closed once the contract expires
+	proxy.MemStore.SetHeight(200)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+	require.Contains(t, err.Error(), "contract expired")
+}
@@ -17,8 +17,11 @@ import (
 	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
 	bankkeeper "github.com/cosmos/cosmos-sdk/x/bank/keeper"
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	distrkeeper "github.com/cosmos/cosmos-sdk/x/distribution/keeper"
+	distrtypes "github.com/cosmos/cosmos-sdk/x/distribution/types"
 	paramskeeper "github.com/cosmos/cosmos-sdk/x/params/keeper"
 	paramstypes "github.com/cosmos/cosmos-sdk/x/params/types"
+	stakingkeeper "github.com/cosmos/cosmos-sdk/x/staking/keeper"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 	"github.com/stretchr/testify/require"
 	"github.com/tendermint/tendermint/libs/log"
@@ -31,6 +34,8 @@ type (
 		ClaimKeeper   keeper.Keeper
 		AccountKeeper authkeeper.AccountKeeper
 		BankKeeper    bankkeeper.Keeper
+		StakingKeeper stakingkeeper.Keeper
+		DistrKeeper   distrkeeper.Keeper
 	}
 )
 
@@ -39,6 +44,8 @@ func CreateTestClaimKeepers(t testing.TB) (TestKeepers, sdk.Context) {
 	storeKey := sdk.NewKVStoreKey(types.StoreKey)
 	keyAcc := sdk.NewKVStoreKey(authtypes.StoreKey)
 	keyBank := sdk.NewKVStoreKey(banktypes.StoreKey)
+	keyStake := sdk.NewKVStoreKey(stakingtypes.StoreKey)
+	keyDistr := sdk.NewKVStoreKey(distrtypes.StoreKey)
 	keyParams := sdk.NewKVStoreKey(paramstypes.StoreKey)
 	tkeyParams := sdk.NewTransientStoreKey(paramstypes.TStoreKey)
 	memStoreKey := storetypes.NewMemoryStoreKey(types.MemStoreKey)
@@ -49,6 +56,8 @@ func CreateTestClaimKeepers(t testing.TB) (TestKeepers, sdk.Context) {
 	stateStore.MountStoreWithDB(memStoreKey, storetypes.StoreTypeMemory, nil)
 	stateStore.MountStoreWithDB(keyAcc, storetypes.StoreTypeIAVL, db)
 	stateStore.MountStoreWithDB(keyBank, storetypes.StoreTypeIAVL, db)
+	stateStore.MountStoreWithDB(keyStake, storetypes.StoreTypeIAVL, db)
+	stateStore.MountStoreWithDB(keyDistr, storetypes.StoreTypeIAVL, db)
 	stateStore.MountStoreWithDB(tkeyParams, storetypes.StoreTypeIAVL, db)
 	stateStore.MountStoreWithDB(keyParams, storetypes.StoreTypeIAVL, db)
 	require.NoError(t, stateStore.LoadLatestVersion())
@@ -71,6 +80,7 @@ func CreateTestClaimKeepers(t testing.TB) (TestKeepers, sdk.Context) {
 	accountKeeper := authkeeper.NewAccountKeeper(cdc, keyAcc, paramsKeeper.Subspace(authtypes.ModuleName), authtypes.ProtoBaseAccount, map[string][]string{
 		stakingtypes.BondedPoolName:    {authtypes.Burner, authtypes.Staking},
 		stakingtypes.NotBondedPoolName: {authtypes.Burner, authtypes.Staking},
+		distrtypes.ModuleName:          nil,
 		types.ModuleName:               {authtypes.Minter},
 		arkeotypes.ReserveName:         {},
 		arkeotypes.ProviderName:        {},
@@ -79,12 +89,21 @@ func CreateTestClaimKeepers(t testing.TB) (TestKeepers, sdk.Context) {
 	accountKeeper.SetParams(ctx, authtypes.DefaultParams())
 	bankKeeper := bankkeeper.NewBaseKeeper(cdc, keyBank, accountKeeper, paramsKeeper.Subspace(banktypes.ModuleName), nil)
 	bankKeeper.SetParams(ctx, banktypes.DefaultParams())
+	stakingKeeper := stakingkeeper.NewKeeper(cdc, keyStake, accountKeeper, bankKeeper, paramsKeeper.Subspace(stakingtypes.ModuleName))
+	stakingParams := stakingtypes.DefaultParams()
+	stakingParams.BondDenom = types.DefaultClaimDenom
+	stakingKeeper.SetParams(ctx, stakingParams)
+	distrKeeper := distrkeeper.NewKeeper(cdc, keyDistr, paramsKeeper.Subspace(distrtypes.ModuleName), accountKeeper, bankKeeper, &stakingKeeper, authtypes.FeeCollectorName)
+	distrKeeper.SetParams(ctx, distrtypes.DefaultParams())
+	distrKeeper.SetFeePool(ctx, distrtypes.InitialFeePool())
 
 	k := keeper.NewKeeper(
 		cdc,
 		storeKey,
 		accountKeeper,
 		bankKeeper,
+		&stakingKeeper,
+		distrKeeper,
 		memStoreKey,
 		paramsSubspace,
 	)
@@ -92,10 +111,11 @@ func CreateTestClaimKeepers(t testing.TB) (TestKeepers, sdk.Context) {
 	// Initialize params
 	airdropStartTime := time.Now().UTC().Add(-time.Hour) // started an hour ago
 	params := types.Params{
-		AirdropStartTime:   airdropStartTime,
-		DurationUntilDecay: types.DefaultDurationUntilDecay,
-		DurationOfDecay:    types.DefaultDurationOfDecay,
-		ClaimDenom:         types.DefaultClaimDenom,
+		AirdropStartTime:       airdropStartTime,
+		DurationUntilDecay:     types.DefaultDurationUntilDecay,
+		DurationOfDecay:        types.DefaultDurationOfDecay,
+		ClaimDenom:             types.DefaultClaimDenom,
+		MaxDelegateBasisPoints: types.DefaultMaxDelegateBasisPoints,
 	}
 
 	k.SetParams(ctx, params)
@@ -103,5 +123,7 @@ func CreateTestClaimKeepers(t testing.TB) (TestKeepers, sdk.Context) {
 		ClaimKeeper:   k,
 		AccountKeeper: accountKeeper,
 		BankKeeper:    bankKeeper,
+		StakingKeeper: stakingKeeper,
+		DistrKeeper:   distrKeeper,
 	}, ctx
 }
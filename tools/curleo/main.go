@@ -2,7 +2,6 @@ package main
 
 import (
 	"bytes"
-	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -18,6 +17,7 @@ import (
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/sentinel"
+	"github.com/arkeonetwork/arkeo/sentinel/conf"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 
 	"github.com/cosmos/cosmos-sdk/codec"
@@ -75,7 +75,7 @@ func main() {
 		println(fmt.Sprintf("no active contract found for provider:%s cbhain:%s - will attempt free tier", metadata.Configuration.ProviderPubKey.String(), service))
 	} else {
 		claim := curl.getClaim(contract.Id)
-		auth := curl.sign(*user, contract.Id, claim.Nonce+1)
+		auth := curl.sign(*user, metadata.Configuration, contract.Id, claim.Nonce+1)
 		values.Add(sentinel.QueryArkAuth, auth)
 	}
 	u.RawQuery = values.Encode()
@@ -187,7 +187,7 @@ func (c Curl) parseMetadata() sentinel.Metadata {
 	return meta
 }
 
-func (c Curl) sign(user string, contractId uint64, nonce int64) string {
+func (c Curl) sign(user string, config conf.Configuration, contractId uint64, nonce int64) string {
 	interfaceRegistry := codectypes.NewInterfaceRegistry()
 	std.RegisterInterfaces(interfaceRegistry)
 	ModuleBasics.RegisterInterfaces(interfaceRegistry)
@@ -202,6 +202,9 @@ func (c Curl) sign(user string, contractId uint64, nonce int64) string {
 		log.Fatal(err)
 	}
 	msg := sentinel.GenerateMessageToSign(contractId, nonce)
+	if config.SignatureDomain {
+		msg = sentinel.GenerateDomainMessageToSign(config.ChainId, config.ProviderPubKey, contractId, nonce)
+	}
 
 	println("invoking Sign...")
 	signature, pk, err := kb.Sign(user, []byte(msg))
@@ -215,8 +218,7 @@ func (c Curl) sign(user string, contractId uint64, nonce int64) string {
 		log.Fatal("bad signature")
 	}
 
-	sig := hex.EncodeToString(signature)
-	return fmt.Sprintf("%s:%s", msg, sig)
+	return sentinel.GenerateArkAuthString(contractId, nonce, signature)
 }
 
 func (c Curl) getSpender(user string) string {
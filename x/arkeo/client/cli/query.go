@@ -25,6 +25,16 @@ func GetQueryCmd(queryRoute string) *cobra.Command {
 
 	cmd.AddCommand(CmdQueryParams())
 	cmd.AddCommand(CmdActiveContract())
+	cmd.AddCommand(CmdProviderUptime())
+	cmd.AddCommand(CmdContractConfig())
+	cmd.AddCommand(CmdContractsByClient())
+	cmd.AddCommand(CmdContractsByProvider())
+	cmd.AddCommand(CmdExpiringContracts())
+	cmd.AddCommand(CmdContractCost())
+	cmd.AddCommand(CmdProviderRates())
+	cmd.AddCommand(CmdProviderBundles())
+	cmd.AddCommand(CmdSearchProviders())
+	cmd.AddCommand(CmdModuleBalances())
 
 	// this line is used by starport scaffolding # 1
 
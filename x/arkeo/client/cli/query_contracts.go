@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 
+	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 
 	"github.com/cosmos/cosmos-sdk/client"
@@ -76,3 +77,231 @@ func CmdShowContract() *cobra.Command {
 
 	return cmd
 }
+
+func CmdContractConfig() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "contract-config [contract-id]",
+		Short: "shows the settings of a contract set by its client",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			clientCtx := client.GetClientContextFromCmd(cmd)
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			argContractId, err := cast.ToUint64E(args[0])
+			if err != nil {
+				return err
+			}
+
+			params := &types.QueryContractConfigRequest{
+				ContractId: argContractId,
+			}
+
+			res, err := queryClient.ContractConfig(context.Background(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+func CmdContractsByClient() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "contracts-by-client [client-pubkey]",
+		Short: "list the contracts of a client, not yet settled",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientCtx := client.GetClientContextFromCmd(cmd)
+
+			pageReq, err := client.ReadPageRequest(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			service, err := cmd.Flags().GetString("service")
+			if err != nil {
+				return err
+			}
+
+			activeOnly, err := cmd.Flags().GetBool("active-only")
+			if err != nil {
+				return err
+			}
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryContractsByClientRequest{
+				Client:     args[0],
+				Service:    service,
+				ActiveOnly: activeOnly,
+				Pagination: pageReq,
+			}
+
+			res, err := queryClient.ContractsByClient(context.Background(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	cmd.Flags().String("service", "", "only list the contracts of the service")
+	cmd.Flags().Bool("active-only", false, "only list the open contracts")
+	flags.AddPaginationFlagsToCmd(cmd, cmd.Use)
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+func CmdContractsByProvider() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "contracts-by-provider [provider-pubkey] [service]",
+		Short: "list the open contracts against a provider for a service",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientCtx := client.GetClientContextFromCmd(cmd)
+
+			pageReq, err := client.ReadPageRequest(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			includeSettling, err := cmd.Flags().GetBool("include-settling")
+			if err != nil {
+				return err
+			}
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryContractsByProviderRequest{
+				Provider:        args[0],
+				Service:         args[1],
+				IncludeSettling: includeSettling,
+				Pagination:      pageReq,
+			}
+
+			res, err := queryClient.ContractsByProvider(context.Background(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	cmd.Flags().Bool("include-settling", false, "also list the expired contracts in their settlement period")
+	flags.AddPaginationFlagsToCmd(cmd, cmd.Use)
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+func CmdContractCost() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "contract-cost [provider-pubkey] [service] [contract-type] [duration] [rate] [queries-per-minute]",
+		Short: "estimate the cost and deposit of opening a contract",
+		Args:  cobra.ExactArgs(6),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientCtx := client.GetClientContextFromCmd(cmd)
+
+			contractType, err := cast.ToInt32E(args[2])
+			if err != nil {
+				return err
+			}
+			duration, err := cast.ToInt64E(args[3])
+			if err != nil {
+				return err
+			}
+			rate, err := cosmos.ParseCoin(args[4])
+			if err != nil {
+				return err
+			}
+			qpm, err := cast.ToInt64E(args[5])
+			if err != nil {
+				return err
+			}
+			bundle, err := cmd.Flags().GetUint64(flagBundle)
+			if err != nil {
+				return err
+			}
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryContractCostRequest{
+				Provider:         args[0],
+				Service:          args[1],
+				ContractType:     types.ContractType(contractType),
+				Duration:         duration,
+				Rate:             rate,
+				QueriesPerMinute: qpm,
+				BundleId:         bundle,
+			}
+
+			res, err := queryClient.ContractCost(context.Background(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	cmd.Flags().Uint64(flagBundle, 0, "id of the provider bundle to price the contract for, the service is ignored")
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+func CmdExpiringContracts() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "expiring-contracts [blocks]",
+		Short: "list the contracts settled within the given number of blocks",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientCtx := client.GetClientContextFromCmd(cmd)
+
+			blocks, err := cast.ToInt64E(args[0])
+			if err != nil {
+				return err
+			}
+
+			provider, err := cmd.Flags().GetString("provider")
+			if err != nil {
+				return err
+			}
+
+			service, err := cmd.Flags().GetString("service")
+			if err != nil {
+				return err
+			}
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryExpiringContractsRequest{
+				Blocks:   blocks,
+				Provider: provider,
+				Service:  service,
+			}
+
+			res, err := queryClient.ExpiringContracts(context.Background(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	cmd.Flags().String("provider", "", "only list the contracts against the provider pubkey")
+	cmd.Flags().String("service", "", "only list the contracts covering the service")
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
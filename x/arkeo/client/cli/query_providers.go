@@ -74,3 +74,147 @@ func CmdShowProvider() *cobra.Command {
 
 	return cmd
 }
+
+func CmdProviderUptime() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "provider-uptime [pubkey] [service]",
+		Short: "shows the fraction of blocks a provider was online for",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			clientCtx := client.GetClientContextFromCmd(cmd)
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryProviderUptimeRequest{
+				Pubkey:  args[0],
+				Service: args[1],
+			}
+
+			res, err := queryClient.ProviderUptime(context.Background(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+func CmdProviderRates() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "provider-rates [pubkey]",
+		Short: "shows the current rates and contract durations of a provider, for each of its services",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			clientCtx := client.GetClientContextFromCmd(cmd)
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryProviderRatesRequest{
+				Pubkey: args[0],
+			}
+
+			res, err := queryClient.ProviderRates(context.Background(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+func CmdProviderBundles() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "provider-bundles [pubkey]",
+		Short: "list the bundles of a provider, the groups of services a single contract can cover",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			clientCtx := client.GetClientContextFromCmd(cmd)
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryProviderBundlesRequest{
+				Pubkey: args[0],
+			}
+
+			res, err := queryClient.ProviderBundles(context.Background(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+func CmdSearchProviders() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "search-providers",
+		Short:   "search the providers by service, status, bond, rate and settlement duration",
+		Example: "search-providers --service eth-mainnet --max-rate 10uarkeo --sort rate",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientCtx := client.GetClientContextFromCmd(cmd)
+
+			pageReq, err := client.ReadPageRequest(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			params := &types.QuerySearchProvidersRequest{Pagination: pageReq}
+			for flag, value := range map[string]*string{
+				"service":       &params.Service,
+				"status":        &params.Status,
+				"min-bond":      &params.MinBond,
+				"max-rate":      &params.MaxRate,
+				"contract-type": &params.ContractType,
+				"sort":          &params.Sort,
+			} {
+				if *value, err = cmd.Flags().GetString(flag); err != nil {
+					return err
+				}
+			}
+			if params.MinSettlementDuration, err = cmd.Flags().GetInt64("min-settlement-duration"); err != nil {
+				return err
+			}
+			if params.MaxSettlementDuration, err = cmd.Flags().GetInt64("max-settlement-duration"); err != nil {
+				return err
+			}
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			res, err := queryClient.SearchProviders(context.Background(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	cmd.Flags().String("service", "", "only list the providers of the service")
+	cmd.Flags().String("status", "", "only list the providers with the status (online or offline)")
+	cmd.Flags().String("min-bond", "", "only list the providers with at least the bond")
+	cmd.Flags().String("max-rate", "", "only list the providers charging at most the rate (ie 10uarkeo)")
+	cmd.Flags().String("contract-type", "", "compare the subscription or pay-as-you-go rate, the lowest of both by default")
+	cmd.Flags().Int64("min-settlement-duration", 0, "only list the providers with at least the settlement duration")
+	cmd.Flags().Int64("max-settlement-duration", 0, "only list the providers with at most the settlement duration")
+	cmd.Flags().String("sort", "", "sort by bond (descending) or rate (ascending)")
+	flags.AddPaginationFlagsToCmd(cmd, cmd.Use)
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
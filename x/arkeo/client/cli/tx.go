@@ -34,9 +34,17 @@ func GetTxCmd() *cobra.Command {
 	cmd.AddCommand(CmdBondProvider())
 	cmd.AddCommand(CmdModProvider())
 	cmd.AddCommand(CmdOpenContract())
+	cmd.AddCommand(CmdOpenContractAuto())
 	cmd.AddCommand(CmdCloseContract())
 	cmd.AddCommand(CmdClaimContractIncome())
 	cmd.AddCommand(CmdSetVersion())
+	cmd.AddCommand(CmdSetBundle())
+	cmd.AddCommand(CmdReportProvider())
+	cmd.AddCommand(CmdSetContractConfig())
+	cmd.AddCommand(CmdExtendContract())
+	cmd.AddCommand(CmdUpdateContractDelegate())
+	cmd.AddCommand(CmdTopUpContract())
+	cmd.AddCommand(CmdAttestProviderDowntime())
 	// this line is used by starport scaffolding # 1
 
 	return cmd
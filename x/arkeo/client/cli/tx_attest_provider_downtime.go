@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+)
+
+const flagService = "service"
+
+func CmdAttestProviderDowntime() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attest-provider-downtime [contract-id]",
+		Short: "Attest the downtime of the provider of an open contract",
+		Long:  "Attest the provider of an open contract is not serving it. The provider bond is slashed once enough distinct clients attest its downtime within the attestation window. The downtime of a bundle contract is attested for one of its services, set with --service.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			argContractId, err := cast.ToUint64E(args[0])
+			if err != nil {
+				return err
+			}
+
+			argService, err := cmd.Flags().GetString(flagService)
+			if err != nil {
+				return err
+			}
+
+			clientCtx, err := client.GetClientTxContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			msg := types.NewMsgAttestProviderDowntime(
+				clientCtx.GetFromAddress(),
+				argContractId,
+				argService,
+			)
+			if err := msg.ValidateBasic(); err != nil {
+				return err
+			}
+			return tx.GenerateOrBroadcastTxCLI(clientCtx, cmd.Flags(), msg)
+		},
+	}
+
+	flags.AddTxFlagsToCmd(cmd)
+	cmd.Flags().String(flagService, "", "service of a bundle contract the provider is down for, the service of the contract by default")
+
+	return cmd
+}
@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+)
+
+func CmdExtendContract() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "extend-contract [contract-id] [duration] [deposit]",
+		Short: "Broadcast message extendContract",
+		Long:  "Extend an open contract by the given number of blocks, topping up its deposit. The deposit of a subscription must pay for the blocks added at the rate of the contract, a pay-as-you-go contract can be extended without a deposit.",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			argContractId, err := cast.ToUint64E(args[0])
+			if err != nil {
+				return err
+			}
+			argDuration, err := cast.ToInt64E(args[1])
+			if err != nil {
+				return err
+			}
+			deposit, ok := cosmos.NewIntFromString(args[2])
+			if !ok {
+				return fmt.Errorf("bad deposit amount: %s", args[2])
+			}
+
+			clientCtx, err := client.GetClientTxContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			msg := types.NewMsgExtendContract(
+				clientCtx.GetFromAddress(),
+				argContractId,
+				argDuration,
+				deposit,
+			)
+			if err := msg.ValidateBasic(); err != nil {
+				return err
+			}
+			return tx.GenerateOrBroadcastTxCLI(clientCtx, cmd.Flags(), msg)
+		},
+	}
+
+	flags.AddTxFlagsToCmd(cmd)
+
+	return cmd
+}
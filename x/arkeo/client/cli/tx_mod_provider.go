@@ -1,6 +1,9 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
@@ -12,6 +15,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const (
+	flagPayoutSplits = "payout-splits"
+	flagMetadataFile = "metadata-file"
+	flagMethodRates  = "method-rates"
+	flagMinDeposit   = "min-deposit"
+)
+
 func CmdModProvider() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "mod-provider [pubkey] [service] [metatadata-uri] [metadata-nonce] [status] [min-contract-duration] [max-contract-duration] [subscription-rates] [pay-as-you-go-rates] [settlement-duration]",
@@ -56,6 +66,51 @@ func CmdModProvider() *cobra.Command {
 				return err
 			}
 
+			argPayoutSplits, err := cmd.Flags().GetString(flagPayoutSplits)
+			if err != nil {
+				return err
+			}
+			payoutSplits, err := types.ParsePayoutSplits(argPayoutSplits)
+			if err != nil {
+				return err
+			}
+
+			argMethodRates, err := cmd.Flags().GetString(flagMethodRates)
+			if err != nil {
+				return err
+			}
+			methodRates, err := types.ParseMethodRates(argMethodRates)
+			if err != nil {
+				return err
+			}
+
+			minDeposit := cosmos.ZeroInt()
+			argMinDeposit, err := cmd.Flags().GetString(flagMinDeposit)
+			if err != nil {
+				return err
+			}
+			if len(argMinDeposit) > 0 {
+				var ok bool
+				minDeposit, ok = cosmos.NewIntFromString(argMinDeposit)
+				if !ok {
+					return fmt.Errorf("bad min deposit amount: %s", argMinDeposit)
+				}
+			}
+
+			// commit to the metadata served at the uri with its hash
+			argMetadataFile, err := cmd.Flags().GetString(flagMetadataFile)
+			if err != nil {
+				return err
+			}
+			var metadataHash string
+			if len(argMetadataFile) > 0 {
+				metadata, err := os.ReadFile(argMetadataFile)
+				if err != nil {
+					return err
+				}
+				metadataHash = types.MetadataHash(metadata)
+			}
+
 			clientCtx, err := client.GetClientTxContext(cmd)
 			if err != nil {
 				return err
@@ -74,6 +129,10 @@ func CmdModProvider() *cobra.Command {
 				argPayAsYouGoRate,
 				argSettlementDuration,
 			)
+			msg.PayoutSplits = payoutSplits
+			msg.MetadataHash = metadataHash
+			msg.MethodRates = methodRates
+			msg.MinDeposit = minDeposit
 
 			if err := msg.ValidateBasic(); err != nil {
 				return err
@@ -83,6 +142,10 @@ func CmdModProvider() *cobra.Command {
 	}
 
 	flags.AddTxFlagsToCmd(cmd)
+	cmd.Flags().String(flagPayoutSplits, "", "split provider income across addresses, as address:weight pairs in basis points (e.g. addr1:7000,addr2:3000)")
+	cmd.Flags().String(flagMethodRates, "", "charge the expensive requests a multiple of the rate, as pattern:multiplier pairs of JSON-RPC methods or url paths, a trailing * matching any suffix (e.g. debug_*:20,eth_getLogs:5)")
+	cmd.Flags().String(flagMinDeposit, "", "smallest deposit of the contracts opened with the provider, can't be below the min contract deposit param")
+	cmd.Flags().String(flagMetadataFile, "", "metadata json served at the metadata uri, its sha256 is committed to so clients can verify the metadata they fetch")
 
 	return cmd
 }
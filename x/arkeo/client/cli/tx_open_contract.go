@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/arkeonetwork/arkeo/common"
@@ -14,6 +15,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const (
+	flagBundle       = "bundle"
+	flagSpenders     = "spenders"
+	flagDepositDenom = "deposit-denom"
+)
+
 func CmdOpenContract() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "open-contract [provider_pubkey] [service] [client_pubkey] [c-type] [deposit] [duration] [rate] [queries-per-minute] [settlement-duration] [authorization-optional] [delegation-optional]",
@@ -80,6 +87,35 @@ func CmdOpenContract() *cobra.Command {
 				}
 			}
 
+			// a bundle contract covers the services of the bundle
+			argBundle, err := cmd.Flags().GetUint64(flagBundle)
+			if err != nil {
+				return err
+			}
+			if argBundle > 0 {
+				argService = ""
+			}
+
+			// restricts the contract to the listed keys
+			argSpenders, err := cmd.Flags().GetStringSlice(flagSpenders)
+			if err != nil {
+				return err
+			}
+			spenders := make([]common.PubKey, 0, len(argSpenders))
+			for _, arg := range argSpenders {
+				spender, err := common.NewPubKey(arg)
+				if err != nil {
+					return err
+				}
+				spenders = append(spenders, spender)
+			}
+
+			// pays the deposit and the open fee in an accepted denom
+			argDepositDenom, err := cmd.Flags().GetString(flagDepositDenom)
+			if err != nil {
+				return err
+			}
+
 			clientCtx, err := client.GetClientTxContext(cmd)
 			if err != nil {
 				return err
@@ -99,14 +135,70 @@ func CmdOpenContract() *cobra.Command {
 				types.ContractAuthorization(argContractAuth),
 				argQPM,
 			)
+			msg.BundleId = argBundle
+			msg.DepositDenom = argDepositDenom
+			if len(spenders) > 0 {
+				msg.Spenders = spenders
+			}
 			if err := msg.ValidateBasic(); err != nil {
 				return err
 			}
+
+			// the min deposit can't be checked without a node to query
+			if !clientCtx.Offline {
+				minDeposit, err := contractMinDeposit(clientCtx, pubkey, argService, argBundle)
+				if err != nil {
+					return err
+				}
+				if deposit.LT(minDeposit) {
+					return fmt.Errorf("deposit of %s is below the min contract deposit of %s", deposit, minDeposit)
+				}
+			}
 			return tx.GenerateOrBroadcastTxCLI(clientCtx, cmd.Flags(), msg)
 		},
 	}
 
 	flags.AddTxFlagsToCmd(cmd)
+	cmd.Flags().Uint64(flagBundle, 0, "id of the provider bundle to open the contract for, the service is ignored")
+	cmd.Flags().StringSlice(flagSpenders, nil, "comma separated pubkeys, the only keys allowed to sign the requests of the contract")
+	cmd.Flags().String(flagDepositDenom, "", "denom the deposit and the open fee are paid in, swapped at the rate of the deposit denoms param")
 
 	return cmd
 }
+
+// contractMinDeposit queries the smallest deposit of a contract with the
+// provider, on the service or on every service of the bundle
+func contractMinDeposit(clientCtx client.Context, pubkey common.PubKey, service string, bundleID uint64) (cosmos.Int, error) {
+	queryClient := types.NewQueryClient(clientCtx)
+	params, err := queryClient.Params(context.Background(), &types.QueryParamsRequest{})
+	if err != nil {
+		return cosmos.ZeroInt(), err
+	}
+
+	services := []string{service}
+	if bundleID > 0 {
+		res, err := queryClient.ProviderBundles(context.Background(), &types.QueryProviderBundlesRequest{Pubkey: pubkey.String()})
+		if err != nil {
+			return cosmos.ZeroInt(), err
+		}
+		services = nil
+		for _, bundle := range res.Bundles {
+			if bundle.Id != bundleID {
+				continue
+			}
+			for _, svc := range bundle.Services {
+				services = append(services, svc.String())
+			}
+		}
+	}
+
+	minDeposit := params.Params.MinContractDeposit
+	for _, svc := range services {
+		res, err := queryClient.FetchProvider(context.Background(), &types.QueryFetchProviderRequest{Pubkey: pubkey.String(), Service: svc})
+		if err != nil {
+			return cosmos.ZeroInt(), err
+		}
+		minDeposit = res.Provider.ContractMinDeposit(minDeposit)
+	}
+	return minDeposit, nil
+}
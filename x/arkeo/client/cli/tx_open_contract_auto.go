@@ -0,0 +1,300 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/input"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagDenom            = "denom"
+	flagDeposit          = "deposit"
+	flagQueriesPerMinute = "queries-per-minute"
+	flagAuthorization    = "authorization"
+	flagDelegate         = "delegate"
+)
+
+// openContractRequest is what the client asks open-contract-auto for, the
+// rest of the contract terms come from the provider
+type openContractRequest struct {
+	provider         common.PubKey
+	service          string
+	contractType     types.ContractType
+	duration         int64
+	denom            string     // empty when the provider has a single rate
+	deposit          cosmos.Int // nil for the required deposit
+	queriesPerMinute int64
+}
+
+// openContractPlan is the contract open-contract-auto opens, priced with the
+// provider terms
+type openContractPlan struct {
+	openContractRequest
+	terms              types.Provider
+	rate               cosmos.Coin
+	settlementDuration int64
+	minDeposit         cosmos.Int
+	openCost           cosmos.Coin
+}
+
+func CmdOpenContractAuto() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "open-contract-auto [provider_pubkey] [service] [client_pubkey] [c-type] [duration]",
+		Short: "Open a contract on the current terms of the provider",
+		Long: `Open a contract on the current terms of the provider. The rate, the
+settlement duration and the deposit of a subscription are taken from the
+provider record, the duration is checked against the provider limits. The
+contract type is subscription or pay-as-you-go. A summary of the contract is
+printed for confirmation, skipped with --yes.`,
+		Args: cobra.ExactArgs(5),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			req := openContractRequest{service: args[1]}
+			req.provider, err = common.NewPubKey(args[0])
+			if err != nil {
+				return err
+			}
+
+			cl, err := common.NewPubKey(args[2])
+			if err != nil {
+				return err
+			}
+
+			req.contractType, err = parseContractType(args[3])
+			if err != nil {
+				return err
+			}
+
+			req.duration, err = cast.ToInt64E(args[4])
+			if err != nil {
+				return err
+			}
+
+			req.denom, err = cmd.Flags().GetString(flagDenom)
+			if err != nil {
+				return err
+			}
+
+			argDeposit, err := cmd.Flags().GetString(flagDeposit)
+			if err != nil {
+				return err
+			}
+			if len(argDeposit) > 0 {
+				deposit, ok := cosmos.NewIntFromString(argDeposit)
+				if !ok {
+					return fmt.Errorf("bad deposit amount: %s", argDeposit)
+				}
+				req.deposit = deposit
+			}
+
+			req.queriesPerMinute, err = cmd.Flags().GetInt64(flagQueriesPerMinute)
+			if err != nil {
+				return err
+			}
+
+			argAuthorization, err := cmd.Flags().GetString(flagAuthorization)
+			if err != nil {
+				return err
+			}
+			authorization, ok := types.ContractAuthorization_value[strings.ToUpper(argAuthorization)]
+			if !ok {
+				return fmt.Errorf("bad authorization: %s, must be strict or open", argAuthorization)
+			}
+
+			delegate := common.EmptyPubKey
+			argDelegate, err := cmd.Flags().GetString(flagDelegate)
+			if err != nil {
+				return err
+			}
+			if len(argDelegate) > 0 {
+				delegate, err = common.NewPubKey(argDelegate)
+				if err != nil {
+					return err
+				}
+			}
+
+			clientCtx, err := client.GetClientTxContext(cmd)
+			if err != nil {
+				return err
+			}
+			if clientCtx.Offline {
+				return fmt.Errorf("the provider terms can't be queried offline, use open-contract instead")
+			}
+
+			plan, err := planOpenContract(cmd.Context(), types.NewQueryClient(clientCtx), req)
+			if err != nil {
+				return err
+			}
+
+			msg := types.NewMsgOpenContract(
+				clientCtx.GetFromAddress(),
+				req.provider,
+				req.service,
+				cl,
+				delegate,
+				req.contractType,
+				req.duration,
+				plan.settlementDuration,
+				plan.rate,
+				plan.deposit,
+				types.ContractAuthorization(authorization),
+				req.queriesPerMinute,
+			)
+			if err := msg.ValidateBasic(); err != nil {
+				return err
+			}
+
+			if !clientCtx.SkipConfirm {
+				plan.print(cmd.ErrOrStderr())
+				ok, err := input.GetConfirmation("open the contract", bufio.NewReader(cmd.InOrStdin()), cmd.ErrOrStderr())
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("contract not opened")
+				}
+				// confirmed already, the transaction isn't confirmed again
+				clientCtx = clientCtx.WithSkipConfirmation(true)
+			}
+			return tx.GenerateOrBroadcastTxCLI(clientCtx, cmd.Flags(), msg)
+		},
+	}
+
+	flags.AddTxFlagsToCmd(cmd)
+	cmd.Flags().String(flagDenom, "", "denom of the provider rate to pay, required when the provider has rates in several denoms")
+	cmd.Flags().String(flagDeposit, "", "deposit of a pay-as-you-go contract, a subscription deposit is rate*duration*queries per minute")
+	cmd.Flags().Int64(flagQueriesPerMinute, 0, "queries per minute the contract is allowed")
+	cmd.Flags().String(flagAuthorization, "strict", "strict or open, a pay-as-you-go contract must be strict")
+	cmd.Flags().String(flagDelegate, "", "pubkey allowed to make the requests of the contract on behalf of the client")
+
+	return cmd
+}
+
+// parseContractType parses a contract type by name, like pay-as-you-go, or
+// by number
+func parseContractType(arg string) (types.ContractType, error) {
+	if value, ok := types.ContractType_value[strings.ToUpper(strings.ReplaceAll(arg, "-", "_"))]; ok {
+		return types.ContractType(value), nil
+	}
+	value, err := cast.ToInt32E(arg)
+	if err != nil {
+		return 0, fmt.Errorf("bad contract type: %s, must be subscription or pay-as-you-go", arg)
+	}
+	if _, ok := types.ContractType_name[value]; !ok {
+		return 0, fmt.Errorf("bad contract type: %s, must be subscription or pay-as-you-go", arg)
+	}
+	return types.ContractType(value), nil
+}
+
+// planOpenContract checks the request against the current terms of the
+// provider and prices the contract, the same way the chain does on open
+func planOpenContract(ctx context.Context, queryClient types.QueryClient, req openContractRequest) (openContractPlan, error) {
+	plan := openContractPlan{openContractRequest: req}
+	res, err := queryClient.FetchProvider(ctx, &types.QueryFetchProviderRequest{Pubkey: req.provider.String(), Service: req.service})
+	if err != nil {
+		return plan, fmt.Errorf("fail to fetch provider %s for service %s: %w", req.provider, req.service, err)
+	}
+	plan.terms = res.Provider
+
+	if plan.terms.Status != types.ProviderStatus_ONLINE {
+		return plan, fmt.Errorf("provider is %s for service %s, contracts can only be opened with an online provider", plan.terms.Status, req.service)
+	}
+	if req.duration < plan.terms.MinContractDuration {
+		return plan, fmt.Errorf("duration of %d blocks is below the provider min contract duration of %d blocks", req.duration, plan.terms.MinContractDuration)
+	}
+	if req.duration > plan.terms.MaxContractDuration {
+		return plan, fmt.Errorf("duration of %d blocks exceeds the provider max contract duration of %d blocks", req.duration, plan.terms.MaxContractDuration)
+	}
+	if req.queriesPerMinute <= 0 {
+		return plan, fmt.Errorf("queries per minute must be greater than zero, set --%s", flagQueriesPerMinute)
+	}
+
+	rates := plan.terms.SubscriptionRate
+	if req.contractType == types.ContractType_PAY_AS_YOU_GO {
+		rates = plan.terms.PayAsYouGoRate
+		plan.settlementDuration = plan.terms.SettlementDuration
+	}
+	rates = cosmos.NewCoins(rates...)
+	switch {
+	case rates.Empty():
+		return plan, fmt.Errorf("provider has no %s rate for service %s", req.contractType, req.service)
+	case len(req.denom) > 0:
+		if !rates.AmountOf(req.denom).IsPositive() {
+			return plan, fmt.Errorf("provider has no %s rate in %s, its rates are %s", req.contractType, req.denom, rates)
+		}
+		plan.rate = cosmos.NewCoin(req.denom, rates.AmountOf(req.denom))
+	case len(rates) > 1:
+		return plan, fmt.Errorf("provider has %s rates in several denoms (%s), pick one with --%s", req.contractType, rates, flagDenom)
+	default:
+		plan.rate = rates[0]
+	}
+
+	params, err := queryClient.Params(ctx, &types.QueryParamsRequest{})
+	if err != nil {
+		return plan, fmt.Errorf("fail to fetch params: %w", err)
+	}
+	plan.minDeposit = plan.terms.ContractMinDeposit(params.Params.MinContractDeposit)
+
+	cost, err := queryClient.ContractCost(ctx, &types.QueryContractCostRequest{
+		Provider:         req.provider.String(),
+		Service:          req.service,
+		ContractType:     req.contractType,
+		Duration:         req.duration,
+		Rate:             plan.rate,
+		QueriesPerMinute: req.queriesPerMinute,
+	})
+	if err != nil {
+		return plan, fmt.Errorf("fail to fetch contract cost: %w", err)
+	}
+	plan.openCost = cost.OpenCost
+
+	switch req.contractType {
+	case types.ContractType_SUBSCRIPTION:
+		if !req.deposit.IsNil() && !req.deposit.Equal(cost.Deposit.Amount) {
+			return plan, fmt.Errorf("subscription deposit must be rate*duration*queries per minute, %s, not %s", cost.Deposit, req.deposit)
+		}
+		plan.deposit = cost.Deposit.Amount
+	case types.ContractType_PAY_AS_YOU_GO:
+		if req.deposit.IsNil() {
+			return plan, fmt.Errorf("pay-as-you-go contract needs a --%s, of at least %s%s", flagDeposit, plan.minDeposit, plan.rate.Denom)
+		}
+	}
+	if plan.deposit.LT(plan.minDeposit) {
+		return plan, fmt.Errorf("deposit of %s%s is below the min contract deposit of %s%s", plan.deposit, plan.rate.Denom, plan.minDeposit, plan.rate.Denom)
+	}
+
+	return plan, nil
+}
+
+// print writes the summary of the contract, for the client to confirm it
+func (plan openContractPlan) print(w io.Writer) {
+	unit := "block"
+	if plan.contractType == types.ContractType_PAY_AS_YOU_GO {
+		unit = "request"
+	}
+	_, _ = fmt.Fprintf(w, "provider:              %s\n", plan.provider)
+	_, _ = fmt.Fprintf(w, "service:               %s\n", plan.service)
+	_, _ = fmt.Fprintf(w, "subscription rates:    %s\n", cosmos.NewCoins(plan.terms.SubscriptionRate...))
+	_, _ = fmt.Fprintf(w, "pay-as-you-go rates:   %s\n", cosmos.NewCoins(plan.terms.PayAsYouGoRate...))
+	_, _ = fmt.Fprintf(w, "contract type:         %s\n", plan.contractType)
+	_, _ = fmt.Fprintf(w, "duration:              %d blocks (%d to %d)\n", plan.duration, plan.terms.MinContractDuration, plan.terms.MaxContractDuration)
+	_, _ = fmt.Fprintf(w, "rate:                  %s per %s\n", plan.rate, unit)
+	_, _ = fmt.Fprintf(w, "queries per minute:    %d\n", plan.queriesPerMinute)
+	if plan.contractType == types.ContractType_PAY_AS_YOU_GO {
+		_, _ = fmt.Fprintf(w, "settlement duration:   %d blocks\n", plan.settlementDuration)
+	}
+	_, _ = fmt.Fprintf(w, "deposit:               %s%s (min %s%s)\n", plan.deposit, plan.rate.Denom, plan.minDeposit, plan.rate.Denom)
+	_, _ = fmt.Fprintf(w, "open cost:             %s\n", plan.openCost)
+}
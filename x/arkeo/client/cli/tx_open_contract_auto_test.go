@@ -0,0 +1,231 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// mockQueryClient serves the provider and the params of the chain, pricing
+// contracts the way the chain does
+type mockQueryClient struct {
+	types.QueryClient
+	provider types.Provider
+	params   types.Params
+	err      error
+}
+
+func (m mockQueryClient) FetchProvider(_ context.Context, req *types.QueryFetchProviderRequest, _ ...grpc.CallOption) (*types.QueryFetchProviderResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if req.Pubkey != m.provider.PubKey.String() || req.Service != m.provider.Service.String() {
+		return nil, fmt.Errorf("not found")
+	}
+	return &types.QueryFetchProviderResponse{Provider: m.provider}, nil
+}
+
+func (m mockQueryClient) Params(_ context.Context, _ *types.QueryParamsRequest, _ ...grpc.CallOption) (*types.QueryParamsResponse, error) {
+	return &types.QueryParamsResponse{Params: m.params}, nil
+}
+
+func (m mockQueryClient) ContractCost(_ context.Context, req *types.QueryContractCostRequest, _ ...grpc.CallOption) (*types.QueryContractCostResponse, error) {
+	deposit := cosmos.NewCoin(req.Rate.Denom, cosmos.ZeroInt())
+	if req.ContractType == types.ContractType_SUBSCRIPTION {
+		deposit.Amount = req.Rate.Amount.MulRaw(req.Duration * req.QueriesPerMinute)
+	}
+	return &types.QueryContractCostResponse{OpenCost: cosmos.NewInt64Coin("uarkeo", 100), Deposit: deposit}, nil
+}
+
+func TestPlanOpenContract(t *testing.T) {
+	provider := types.NewProvider(types.GetRandomPubKey(), common.BTCService)
+	provider.Status = types.ProviderStatus_ONLINE
+	provider.MinContractDuration = 10
+	provider.MaxContractDuration = 500
+	provider.SettlementDuration = 20
+	provider.SubscriptionRate = cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 15))
+	provider.PayAsYouGoRate = cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 3), cosmos.NewInt64Coin("ibc/usdc", 1))
+	queryClient := mockQueryClient{provider: provider, params: types.Params{MinContractDeposit: cosmos.NewInt(1000)}}
+
+	subscription := openContractRequest{
+		provider:         provider.PubKey,
+		service:          common.BTCService.String(),
+		contractType:     types.ContractType_SUBSCRIPTION,
+		duration:         100,
+		queriesPerMinute: 2,
+	}
+	plan, err := planOpenContract(context.Background(), queryClient, subscription)
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt64Coin("uarkeo", 15), plan.rate)
+	require.Equal(t, cosmos.NewInt(3000), plan.deposit)
+	require.Equal(t, cosmos.NewInt(1000), plan.minDeposit)
+	require.Zero(t, plan.settlementDuration)
+	require.Equal(t, cosmos.NewInt64Coin("uarkeo", 100), plan.openCost)
+
+	// the deposit of a subscription is the one required
+	subscription.deposit = cosmos.NewInt(3000)
+	_, err = planOpenContract(context.Background(), queryClient, subscription)
+	require.NoError(t, err)
+
+	payg := openContractRequest{
+		provider:         provider.PubKey,
+		service:          common.BTCService.String(),
+		contractType:     types.ContractType_PAY_AS_YOU_GO,
+		duration:         100,
+		denom:            "ibc/usdc",
+		deposit:          cosmos.NewInt(1500),
+		queriesPerMinute: 10,
+	}
+	plan, err = planOpenContract(context.Background(), queryClient, payg)
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt64Coin("ibc/usdc", 1), plan.rate)
+	require.Equal(t, cosmos.NewInt(1500), plan.deposit)
+	require.Equal(t, int64(20), plan.settlementDuration)
+
+	var summary bytes.Buffer
+	plan.print(&summary)
+	require.Contains(t, summary.String(), "1ibc/usdc per request")
+	require.Contains(t, summary.String(), "settlement duration:   20 blocks")
+	require.Contains(t, summary.String(), "1500ibc/usdc (min 1000ibc/usdc)")
+
+	for _, tc := range []struct {
+		desc   string
+		modify func(req *openContractRequest, client *mockQueryClient)
+		err    string
+	}{
+		{
+			desc: "unknown provider",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				req.provider = types.GetRandomPubKey()
+			},
+			err: "fail to fetch provider",
+		},
+		{
+			desc: "query failure",
+			modify: func(_ *openContractRequest, client *mockQueryClient) {
+				client.err = fmt.Errorf("connection refused")
+			},
+			err: "connection refused",
+		},
+		{
+			desc: "offline provider",
+			modify: func(_ *openContractRequest, client *mockQueryClient) {
+				client.provider.Status = types.ProviderStatus_OFFLINE
+			},
+			err: "provider is OFFLINE for service btc-mainnet-fullnode",
+		},
+		{
+			desc: "duration below min",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				req.duration = 9
+			},
+			err: "duration of 9 blocks is below the provider min contract duration of 10 blocks",
+		},
+		{
+			desc: "duration over max",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				req.duration = 501
+			},
+			err: "duration of 501 blocks exceeds the provider max contract duration of 500 blocks",
+		},
+		{
+			desc: "no queries per minute",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				req.queriesPerMinute = 0
+			},
+			err: "queries per minute must be greater than zero",
+		},
+		{
+			desc: "no rate for the contract type",
+			modify: func(_ *openContractRequest, client *mockQueryClient) {
+				client.provider.PayAsYouGoRate = nil
+			},
+			err: "provider has no PAY_AS_YOU_GO rate for service btc-mainnet-fullnode",
+		},
+		{
+			desc: "no rate in the denom",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				req.denom = "ibc/atom"
+			},
+			err: "provider has no PAY_AS_YOU_GO rate in ibc/atom, its rates are 1ibc/usdc,3uarkeo",
+		},
+		{
+			desc: "several denoms",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				req.denom = ""
+			},
+			err: "provider has PAY_AS_YOU_GO rates in several denoms (1ibc/usdc,3uarkeo), pick one with --denom",
+		},
+		{
+			desc: "pay-as-you-go without a deposit",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				req.deposit = cosmos.Int{}
+			},
+			err: "pay-as-you-go contract needs a --deposit, of at least 1000ibc/usdc",
+		},
+		{
+			desc: "deposit below the min",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				req.deposit = cosmos.NewInt(999)
+			},
+			err: "deposit of 999ibc/usdc is below the min contract deposit of 1000ibc/usdc",
+		},
+		{
+			desc: "deposit below the provider min",
+			modify: func(_ *openContractRequest, client *mockQueryClient) {
+				client.provider.MinDeposit = cosmos.NewInt(2000)
+			},
+			err: "deposit of 1500ibc/usdc is below the min contract deposit of 2000ibc/usdc",
+		},
+		{
+			desc: "subscription deposit mismatch",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				*req = subscription
+				req.deposit = cosmos.NewInt(2999)
+			},
+			err: "subscription deposit must be rate*duration*queries per minute, 3000uarkeo, not 2999",
+		},
+		{
+			desc: "subscription deposit below the min",
+			modify: func(req *openContractRequest, _ *mockQueryClient) {
+				*req = subscription
+				req.deposit = cosmos.Int{}
+				req.duration = 20
+			},
+			err: "deposit of 600uarkeo is below the min contract deposit of 1000uarkeo",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			req, client := payg, queryClient
+			tc.modify(&req, &client)
+			_, err := planOpenContract(context.Background(), client, req)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestParseContractType(t *testing.T) {
+	for arg, expected := range map[string]types.ContractType{
+		"subscription":  types.ContractType_SUBSCRIPTION,
+		"pay-as-you-go": types.ContractType_PAY_AS_YOU_GO,
+		"PAY_AS_YOU_GO": types.ContractType_PAY_AS_YOU_GO,
+		"0":             types.ContractType_SUBSCRIPTION,
+		"1":             types.ContractType_PAY_AS_YOU_GO,
+	} {
+		contractType, err := parseContractType(arg)
+		require.NoError(t, err)
+		require.Equal(t, expected, contractType)
+	}
+	for _, arg := range []string{"", "payg", "2", "-1"} {
+		_, err := parseContractType(arg)
+		require.ErrorContains(t, err, "must be subscription or pay-as-you-go")
+	}
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+)
+
+func CmdSetBundle() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-bundle [pubkey] [id] [name] [services] [subscription-rates] [pay-as-you-go-rates]",
+		Short: "Broadcast message setBundle, an id of zero publishes a new bundle",
+		Long:  "Publish or update a bundle of services, services are comma separated (e.g. btc-mainnet-fullnode,eth-mainnet-fullnode)",
+		Args:  cobra.ExactArgs(6),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			pubkey, err := common.NewPubKey(args[0])
+			if err != nil {
+				return err
+			}
+			argId, err := cast.ToUint64E(args[1])
+			if err != nil {
+				return err
+			}
+			argName := args[2]
+			argServices := strings.Split(args[3], ",")
+			argSubscriptionRate, err := cosmos.ParseCoins(args[4])
+			if err != nil {
+				return err
+			}
+			argPayAsYouGoRate, err := cosmos.ParseCoins(args[5])
+			if err != nil {
+				return err
+			}
+
+			clientCtx, err := client.GetClientTxContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			msg := types.NewMsgSetBundle(
+				clientCtx.GetFromAddress(),
+				pubkey,
+				argId,
+				argName,
+				argServices,
+				argSubscriptionRate,
+				argPayAsYouGoRate,
+			)
+			if err := msg.ValidateBasic(); err != nil {
+				return err
+			}
+			return tx.GenerateOrBroadcastTxCLI(clientCtx, cmd.Flags(), msg)
+		},
+	}
+
+	flags.AddTxFlagsToCmd(cmd)
+
+	return cmd
+}
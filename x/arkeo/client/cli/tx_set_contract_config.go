@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagCORsAllowOrigins = "cors-allow-origins"
+	flagCORsAllowMethods = "cors-allow-methods"
+	flagCORsAllowHeaders = "cors-allow-headers"
+	flagWhitelistIPs     = "whitelist-ips"
+)
+
+func CmdSetContractConfig() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-contract-config [contract-id] [per-user-rate-limit]",
+		Short: "Broadcast message setContractConfig",
+		Long:  "Set the settings of a contract enforced by the sentinel of its provider, replacing the previous ones. The per user rate limit is in requests per minute, zero is unlimited.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			argContractId, err := cast.ToUint64E(args[0])
+			if err != nil {
+				return err
+			}
+			argPerUserRateLimit, err := cast.ToInt64E(args[1])
+			if err != nil {
+				return err
+			}
+
+			var cors types.ContractCORs
+			if cors.AllowOrigins, err = cmd.Flags().GetStringSlice(flagCORsAllowOrigins); err != nil {
+				return err
+			}
+			if cors.AllowMethods, err = cmd.Flags().GetStringSlice(flagCORsAllowMethods); err != nil {
+				return err
+			}
+			if cors.AllowHeaders, err = cmd.Flags().GetStringSlice(flagCORsAllowHeaders); err != nil {
+				return err
+			}
+			argWhitelistIps, err := cmd.Flags().GetStringSlice(flagWhitelistIPs)
+			if err != nil {
+				return err
+			}
+
+			clientCtx, err := client.GetClientTxContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			msg := types.NewMsgSetContractConfig(
+				clientCtx.GetFromAddress(),
+				argContractId,
+				cors,
+				argWhitelistIps,
+				argPerUserRateLimit,
+			)
+			if err := msg.ValidateBasic(); err != nil {
+				return err
+			}
+			return tx.GenerateOrBroadcastTxCLI(clientCtx, cmd.Flags(), msg)
+		},
+	}
+
+	flags.AddTxFlagsToCmd(cmd)
+	cmd.Flags().StringSlice(flagCORsAllowOrigins, nil, "origins allowed to call the contract (e.g. https://example.com)")
+	cmd.Flags().StringSlice(flagCORsAllowMethods, nil, "http methods allowed to call the contract (e.g. GET,POST)")
+	cmd.Flags().StringSlice(flagCORsAllowHeaders, nil, "http headers allowed to call the contract")
+	cmd.Flags().StringSlice(flagWhitelistIPs, nil, "ip addresses allowed to use the contract, any when empty")
+
+	return cmd
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+)
+
+func CmdUpdateContractDelegate() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update-contract-delegate [contract-id] [delegate-pubkey]",
+		Short: "Broadcast message updateContractDelegate",
+		Long:  "Replace the delegate spending an open contract, signed by the client of the contract. The signatures of the old delegate are refused from then on. Leave the delegate pubkey out to let the client spend the contract itself.",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			argContractId, err := cast.ToUint64E(args[0])
+			if err != nil {
+				return err
+			}
+			delegate := common.EmptyPubKey
+			if len(args) > 1 {
+				delegate, err = common.NewPubKey(args[1])
+				if err != nil {
+					return err
+				}
+			}
+
+			clientCtx, err := client.GetClientTxContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			msg := types.NewMsgUpdateContractDelegate(
+				clientCtx.GetFromAddress(),
+				argContractId,
+				delegate,
+			)
+			if err := msg.ValidateBasic(); err != nil {
+				return err
+			}
+			return tx.GenerateOrBroadcastTxCLI(clientCtx, cmd.Flags(), msg)
+		},
+	}
+
+	flags.AddTxFlagsToCmd(cmd)
+
+	return cmd
+}
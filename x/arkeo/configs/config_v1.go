@@ -6,23 +6,38 @@ import "github.com/arkeonetwork/arkeo/common"
 func NewConfigValue010() *ConfigVals {
 	return &ConfigVals{
 		int64values: map[ConfigName]int64{
-			HandlerBondProvider:        0,                          // enable/disable bond provider handler
-			HandlerModProvider:         0,                          // enable/disable mod provider handler
-			HandlerOpenContract:        0,                          // enable/disable open contract handler
-			HandlerCloseContract:       0,                          // enable/disable close contract handler
-			HandlerClaimContractIncome: 0,                          // enable/disable claim contract income handler
-			HandlerSetVersion:          0,                          // enable/disable set version handler
-			MaxContractLength:          5256000,                    // one year
-			MaxSupply:                  common.Tokens(121_000_000), // max supply of tokens
-			OpenContractCost:           common.Tokens(1),           // cost to open a contract
-			MinProviderBond:            common.Tokens(1),           // min bond for a data provider to be able to open contracts with
-			ReserveTax:                 1000,                       // reserve income off provider income, in basis points
-			BlocksPerYear:              5256666,                    // blocks per year
-			EmissionCurve:              6,                          // rate in which the reserve is depleted to pay validators
-			ValidatorPayoutCycle:       1,                          // how often validators are paid out rewards
-			VersionConsensus:           90,                         // out of 100, percentage of nodes on a specific version before it is accepted
+			HandlerBondProvider:           0,                          // enable/disable bond provider handler
+			HandlerModProvider:            0,                          // enable/disable mod provider handler
+			HandlerOpenContract:           0,                          // enable/disable open contract handler
+			HandlerCloseContract:          0,                          // enable/disable close contract handler
+			HandlerClaimContractIncome:    0,                          // enable/disable claim contract income handler
+			HandlerSetVersion:             0,                          // enable/disable set version handler
+			HandlerSetBundle:              0,                          // enable/disable set bundle handler
+			HandlerReportProvider:         0,                          // enable/disable report provider handler
+			HandlerSetContractConfig:      0,                          // enable/disable set contract config handler
+			HandlerExtendContract:         0,                          // enable/disable extend contract handler
+			HandlerUpdateDelegate:         0,                          // enable/disable update contract delegate handler
+			HandlerTopUpContract:          0,                          // enable/disable top up contract handler
+			HandlerAttestProviderDowntime: 0,                          // enable/disable attest provider downtime handler
+			MaxContractLength:             5256000,                    // one year
+			MaxSupply:                     common.Tokens(121_000_000), // max supply of tokens
+			OpenContractCost:              common.Tokens(1),           // cost to open a contract
+			MinProviderBond:               common.Tokens(1),           // min bond for a data provider to be able to open contracts with
+			ReserveTax:                    1000,                       // reserve income off provider income, in basis points
+			BlocksPerYear:                 5256666,                    // blocks per year
+			EmissionCurve:                 6,                          // rate in which the reserve is depleted to pay validators
+			ValidatorPayoutCycle:          1,                          // how often validators are paid out rewards
+			VersionConsensus:              90,                         // out of 100, percentage of nodes on a specific version before it is accepted
+			MaxClaimsPerBlock:             100,                        // max number of claims per provider per block
+			ClaimSoftLimitPerBlock:        20,                         // number of claims per provider per block before extra gas is charged
+			ClaimExcessGas:                10_000,                     // extra gas per claim above the soft limit, escalating with each claim
+			ClaimSignatureDomain:          0,                          // require claims signed over the chain id and provider, the bare contract_id:nonce is accepted while 0
+			MaxMetadataURILength:          100,                        // max length of a provider metadata uri
+			ContractExpiringSoonBlocks:    100,                        // blocks before the settlement of a pay-as-you-go contract it is announced, zero disables
+		},
+		boolValues: map[ConfigName]bool{},
+		stringValues: map[ConfigName]string{
+			MetadataURISchemes: "https,ipfs", // comma separated schemes a provider metadata uri may use
 		},
-		boolValues:   map[ConfigName]bool{},
-		stringValues: map[ConfigName]string{},
 	}
 }
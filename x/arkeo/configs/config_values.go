@@ -23,24 +23,52 @@ const (
 	EmissionCurve
 	ValidatorPayoutCycle
 	VersionConsensus
+	MaxClaimsPerBlock
+	ClaimSoftLimitPerBlock
+	ClaimExcessGas
+	HandlerSetBundle
+	HandlerReportProvider
+	HandlerSetContractConfig
+	ClaimSignatureDomain
+	MaxMetadataURILength
+	MetadataURISchemes
+	HandlerExtendContract
+	HandlerUpdateDelegate
+	HandlerTopUpContract
+	HandlerAttestProviderDowntime
+	ContractExpiringSoonBlocks
 )
 
 var nameToString = map[ConfigName]string{
-	HandlerBondProvider:        "HandlerBondProvider",
-	HandlerModProvider:         "HandlerModProvider",
-	HandlerOpenContract:        "HandlerOpenContract",
-	HandlerCloseContract:       "HandlerCloseContract",
-	HandlerClaimContractIncome: "HandlerClaimContractIncome",
-	HandlerSetVersion:          "HandlerSetVersion",
-	MaxSupply:                  "MaxSupply",
-	MaxContractLength:          "MaxContractLength",
-	OpenContractCost:           "OpenContractCost",
-	MinProviderBond:            "MinProviderBond",
-	ReserveTax:                 "ReserveTax",
-	BlocksPerYear:              "BlocksPerYear",
-	EmissionCurve:              "EmissionCurve",
-	ValidatorPayoutCycle:       "ValidatorPayoutCycle",
-	VersionConsensus:           "VersionConsensus",
+	HandlerBondProvider:           "HandlerBondProvider",
+	HandlerModProvider:            "HandlerModProvider",
+	HandlerOpenContract:           "HandlerOpenContract",
+	HandlerCloseContract:          "HandlerCloseContract",
+	HandlerClaimContractIncome:    "HandlerClaimContractIncome",
+	HandlerSetVersion:             "HandlerSetVersion",
+	MaxSupply:                     "MaxSupply",
+	MaxContractLength:             "MaxContractLength",
+	OpenContractCost:              "OpenContractCost",
+	MinProviderBond:               "MinProviderBond",
+	ReserveTax:                    "ReserveTax",
+	BlocksPerYear:                 "BlocksPerYear",
+	EmissionCurve:                 "EmissionCurve",
+	ValidatorPayoutCycle:          "ValidatorPayoutCycle",
+	VersionConsensus:              "VersionConsensus",
+	MaxClaimsPerBlock:             "MaxClaimsPerBlock",
+	ClaimSoftLimitPerBlock:        "ClaimSoftLimitPerBlock",
+	ClaimExcessGas:                "ClaimExcessGas",
+	HandlerSetBundle:              "HandlerSetBundle",
+	HandlerReportProvider:         "HandlerReportProvider",
+	HandlerSetContractConfig:      "HandlerSetContractConfig",
+	ClaimSignatureDomain:          "ClaimSignatureDomain",
+	MaxMetadataURILength:          "MaxMetadataURILength",
+	MetadataURISchemes:            "MetadataURISchemes",
+	HandlerExtendContract:         "HandlerExtendContract",
+	HandlerUpdateDelegate:         "HandlerUpdateDelegate",
+	HandlerTopUpContract:          "HandlerTopUpContract",
+	HandlerAttestProviderDowntime: "HandlerAttestProviderDowntime",
+	ContractExpiringSoonBlocks:    "ContractExpiringSoonBlocks",
 }
 
 // String implement fmt.stringer
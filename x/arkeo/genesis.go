@@ -21,11 +21,37 @@ func InitGenesis(ctx sdk.Context, k keeper.Keeper, genState types.GenesisState)
 	for _, contract := range genState.Contracts {
 		if err := k.SetContract(ctx, contract); err != nil {
 			ctx.Logger().Error("unable to set contract", "provider", contract.Provider, "service", contract.Service, "client", contract.Client, "error", err)
+			continue
+		}
+		// the indexes of the contracts of the clients and providers aren't
+		// exported, they are rebuilt from the contracts not yet settled
+		if contract.SettlementHeight == 0 {
+			if err := k.SetClientContract(ctx, contract.Client, contract.Id); err != nil {
+				ctx.Logger().Error("unable to index contract", "client", contract.Client, "contract", contract.Id, "error", err)
+			}
+			for _, service := range contract.ServiceSet() {
+				if err := k.SetProviderContract(ctx, contract.Provider, service, contract.Id); err != nil {
+					ctx.Logger().Error("unable to index contract", "provider", contract.Provider, "service", service, "contract", contract.Id, "error", err)
+				}
+			}
 		}
 	}
 	k.SetNextContractId(ctx, genState.NextContractId)
 	k.SetVersion(ctx, genState.Version)
 
+	for _, bundle := range genState.Bundles {
+		if err := k.SetBundle(ctx, bundle); err != nil {
+			ctx.Logger().Error("unable to set bundle", "provider", bundle.Provider, "id", bundle.Id, "error", err)
+		}
+	}
+	k.SetNextBundleId(ctx, genState.NextBundleId)
+
+	for _, config := range genState.ContractConfigs {
+		if err := k.SetContractConfig(ctx, config); err != nil {
+			ctx.Logger().Error("unable to set contract config", "contract", config.ContractId, "error", err)
+		}
+	}
+
 	for _, expirationSet := range genState.ContractExpirationSets {
 		if err := k.SetContractExpirationSet(ctx, expirationSet); err != nil {
 			ctx.Logger().Error("unable to set contract expiration set", "height", expirationSet.Height, "error", err)
@@ -70,6 +96,31 @@ func ExportGenesis(ctx sdk.Context, k keeper.Keeper) *types.GenesisState {
 	genesis.NextContractId = k.GetNextContractId(ctx)
 	genesis.Version = k.GetVersion(ctx)
 
+	// bundles
+	iter = k.GetBundleIterator(ctx)
+	for ; iter.Valid(); iter.Next() {
+		var bundle types.Bundle
+		if err := k.Cdc().Unmarshal(iter.Value(), &bundle); err != nil {
+			ctx.Logger().Error("unable to get bundle", "bundle", iter.Key(), "error", err)
+			continue
+		}
+		genesis.Bundles = append(genesis.Bundles, bundle)
+	}
+	iter.Close()
+	genesis.NextBundleId = k.GetNextBundleId(ctx)
+
+	// contract configs
+	iter = k.GetContractConfigIterator(ctx)
+	for ; iter.Valid(); iter.Next() {
+		var config types.ContractConfig
+		if err := k.Cdc().Unmarshal(iter.Value(), &config); err != nil {
+			ctx.Logger().Error("unable to get contract config", "contract", iter.Key(), "error", err)
+			continue
+		}
+		genesis.ContractConfigs = append(genesis.ContractConfigs, config)
+	}
+	iter.Close()
+
 	// contract expiration sets
 	iter = k.GetContractExpirationSetIterator(ctx)
 	for ; iter.Valid(); iter.Next() {
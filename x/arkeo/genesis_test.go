@@ -1,6 +1,10 @@
 package arkeo_test
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/arkeonetwork/arkeo/common"
@@ -11,6 +15,7 @@ import (
 	"github.com/arkeonetwork/arkeo/x/arkeo"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -32,6 +37,29 @@ func TestGenesis(t *testing.T) {
 	// this line is used by starport scaffolding # genesis/test/assert
 }
 
+func TestGenesisDepositDenoms(t *testing.T) {
+	genesisState := types.GenesisState{Params: types.DefaultParams()}
+	genesisState.Params.DepositDenoms = []types.DepositDenom{
+		{Denom: "ibc/usdc", Rate: cosmos.NewDecWithPrec(25, 1)},
+	}
+	require.NoError(t, genesisState.Validate())
+
+	ctx, k := keepertest.ArkeoKeeper(t)
+	arkeo.InitGenesis(ctx, k, genesisState)
+	got := arkeo.ExportGenesis(ctx, k)
+	require.NoError(t, got.Validate())
+	require.Len(t, got.Params.DepositDenoms, 1)
+	require.Equal(t, "ibc/usdc", got.Params.DepositDenoms[0].Denom)
+	require.True(t, got.Params.DepositDenoms[0].Rate.Equal(cosmos.NewDecWithPrec(25, 1)))
+
+	// and is imported again as is
+	ctx, k = keepertest.ArkeoKeeper(t)
+	arkeo.InitGenesis(ctx, k, *got)
+	denom, ok := k.GetParams(ctx).DepositDenom("ibc/usdc")
+	require.True(t, ok)
+	require.True(t, denom.Rate.Equal(cosmos.NewDecWithPrec(25, 1)))
+}
+
 func TestGenesisWithContracts(t *testing.T) {
 	ctx, k := keepertest.ArkeoKeeper(t)
 
@@ -132,6 +160,16 @@ func TestGenesisWithContracts(t *testing.T) {
 	err = k.SetContractExpirationSet(ctx, contractExpirationSet2)
 	require.NoError(t, err)
 
+	// configure a contract
+	contractConfig := types.ContractConfig{
+		ContractId:       1,
+		Cors:             types.ContractCORs{AllowOrigins: []string{"https://example.com"}},
+		WhitelistIps:     []string{"10.0.0.1"},
+		PerUserRateLimit: 60,
+	}
+	err = k.SetContractConfig(ctx, contractConfig)
+	require.NoError(t, err)
+
 	exportedGenesis := arkeo.ExportGenesis(ctx, k)
 	require.NotNil(t, exportedGenesis)
 
@@ -140,6 +178,7 @@ func TestGenesisWithContracts(t *testing.T) {
 	require.ElementsMatch(t, exportedGenesis.Contracts, contracts)
 	require.ElementsMatch(t, exportedGenesis.UserContractSets, []types.UserContractSet{user1ContractSet, user2ContractSet})
 	require.ElementsMatch(t, exportedGenesis.ContractExpirationSets, []types.ContractExpirationSet{contractExpirationSet1, contractExpirationSet2})
+	require.ElementsMatch(t, exportedGenesis.ContractConfigs, []types.ContractConfig{contractConfig})
 
 	ctx, freshKeeper := keepertest.ArkeoKeeper(t)
 	contract, err := freshKeeper.GetContract(ctx, 0)
@@ -151,9 +190,93 @@ func TestGenesisWithContracts(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, contract.IsEmpty())
 
+	// the index of the contracts of the clients is rebuilt
+	byClient, err := freshKeeper.ContractsByClient(sdk.WrapSDKContext(ctx), &types.QueryContractsByClientRequest{Client: user1PubKey.String()})
+	require.NoError(t, err)
+	require.ElementsMatch(t, byClient.Contracts, contracts[:2])
+	byProvider, err := freshKeeper.ContractsByProvider(sdk.WrapSDKContext(ctx), &types.QueryContractsByProviderRequest{
+		Provider:        providerPubkey.String(),
+		Service:         common.BTCService.String(),
+		IncludeSettling: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, byProvider.Contracts, 2)
+	require.ElementsMatch(t, []types.Contract{byProvider.Contracts[0].Contract, byProvider.Contracts[1].Contract}, []types.Contract{contracts[0], contracts[2]})
+
 	exportedGenesis2 := arkeo.ExportGenesis(ctx, freshKeeper)
 	require.ElementsMatch(t, exportedGenesis2.Providers, []types.Provider{provider})
 	require.ElementsMatch(t, exportedGenesis2.Contracts, contracts)
 	require.ElementsMatch(t, exportedGenesis2.UserContractSets, []types.UserContractSet{user1ContractSet, user2ContractSet})
 	require.ElementsMatch(t, exportedGenesis2.ContractExpirationSets, []types.ContractExpirationSet{contractExpirationSet1, contractExpirationSet2})
+	require.ElementsMatch(t, exportedGenesis2.ContractConfigs, []types.ContractConfig{contractConfig})
+}
+
+// releasedGenesis are arkeo states exported by previous releases, the fixtures
+// must never be regenerated. A release that migrates the state adds a fixture
+// exported by the release before it.
+var releasedGenesis = []struct {
+	version string
+	file    string
+}{
+	{"v1", "genesis_pay_as_you_go.json"},
+	{"v1", "genesis_subscription.json"},
+}
+
+// TestReleasedGenesis starts from the states exported by previous releases,
+// their values must be kept as is, fields added since then are left empty
+func TestReleasedGenesis(t *testing.T) {
+	for _, fixture := range releasedGenesis {
+		t.Run(fixture.version+"/"+fixture.file, func(t *testing.T) {
+			released, err := os.ReadFile(filepath.Join("testdata", fixture.version, fixture.file))
+			require.NoError(t, err)
+
+			var genesisState types.GenesisState
+			require.NoError(t, types.ModuleCdc.UnmarshalJSON(released, &genesisState))
+			require.NoError(t, genesisState.Validate())
+			require.NotEmpty(t, genesisState.Contracts)
+
+			ctx, k := keepertest.ArkeoKeeper(t)
+			arkeo.InitGenesis(ctx, k, genesisState)
+			exported := arkeo.ExportGenesis(ctx, k)
+			require.NoError(t, exported.Validate())
+			require.ElementsMatch(t, genesisState.Providers, exported.Providers)
+			require.ElementsMatch(t, genesisState.Contracts, exported.Contracts)
+			require.ElementsMatch(t, genesisState.ContractExpirationSets, exported.ContractExpirationSets)
+			require.Equal(t, genesisState.NextContractId, exported.NextContractId)
+			require.Equal(t, genesisState.Version, exported.Version)
+
+			// every released value is exported again
+			bz, err := types.ModuleCdc.MarshalJSON(exported)
+			require.NoError(t, err)
+			var want, got map[string]interface{}
+			require.NoError(t, json.Unmarshal(released, &want))
+			require.NoError(t, json.Unmarshal(bz, &got))
+			requireJSONSubset(t, "genesis", want, got)
+		})
+	}
+}
+
+// requireJSONSubset checks every value of want is found in got, got can have
+// more fields. Empty lists of want match missing lists in got.
+func requireJSONSubset(t *testing.T, path string, want, got interface{}) {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		require.Truef(t, ok, "%s is not an object", path)
+		for key, value := range w {
+			requireJSONSubset(t, path+"."+key, value, g[key])
+		}
+	case []interface{}:
+		if len(w) == 0 && got == nil {
+			return
+		}
+		g, ok := got.([]interface{})
+		require.Truef(t, ok, "%s is not a list", path)
+		require.Lenf(t, g, len(w), "%s", path)
+		for i := range w {
+			requireJSONSubset(t, fmt.Sprintf("%s[%d]", path, i), w[i], g[i])
+		}
+	default:
+		require.Equalf(t, want, got, "%s", path)
+	}
 }
@@ -0,0 +1,74 @@
+package keeper
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	gogotypes "github.com/gogo/protobuf/types"
+)
+
+func (k KVStore) getBundleKey(ctx cosmos.Context, id uint64) string {
+	return k.GetKey(ctx, prefixBundle, strconv.FormatUint(id, 10))
+}
+
+// GetBundleIterator iterate bundles
+func (k KVStore) GetBundleIterator(ctx cosmos.Context) cosmos.Iterator {
+	return k.getIterator(ctx, prefixBundle)
+}
+
+// GetBundle get the bundle with the given id, an empty bundle when not found
+func (k KVStore) GetBundle(ctx cosmos.Context, id uint64) (types.Bundle, error) {
+	var bundle types.Bundle
+	store := ctx.KVStore(k.storeKey)
+	key := k.getBundleKey(ctx, id)
+	if !store.Has([]byte(key)) {
+		return bundle, nil
+	}
+	err := k.cdc.Unmarshal(store.Get([]byte(key)), &bundle)
+	return bundle, err
+}
+
+// SetBundle save the bundle to key value store
+func (k KVStore) SetBundle(ctx cosmos.Context, bundle types.Bundle) error {
+	if bundle.Id == 0 || bundle.Provider.IsEmpty() || len(bundle.Services) == 0 {
+		return errors.New("cannot save a bundle with an empty id, provider pubkey, or services")
+	}
+	store := ctx.KVStore(k.storeKey)
+	store.Set([]byte(k.getBundleKey(ctx, bundle.Id)), k.cdc.MustMarshal(&bundle))
+	return nil
+}
+
+// BundleExists check whether the given bundle exist in the data store
+func (k KVStore) BundleExists(ctx cosmos.Context, id uint64) bool {
+	return k.has(ctx, k.getBundleKey(ctx, id))
+}
+
+func (k KVStore) GetAndIncrementNextBundleId(ctx cosmos.Context) uint64 {
+	bundleId := k.GetNextBundleId(ctx)
+	k.SetNextBundleId(ctx, bundleId+1)
+	return bundleId
+}
+
+// GetNextBundleId returns the id of the next bundle, bundle ids start at one
+// as zero publishes a new bundle
+func (k KVStore) GetNextBundleId(ctx cosmos.Context) uint64 {
+	store := ctx.KVStore(k.storeKey)
+	bz := store.Get([]byte(prefixBundleNextId))
+	if bz == nil {
+		return 1
+	}
+	val := gogotypes.UInt64Value{}
+	k.cdc.MustUnmarshal(bz, &val)
+	if val.GetValue() == 0 {
+		return 1
+	}
+	return val.GetValue()
+}
+
+func (k KVStore) SetNextBundleId(ctx cosmos.Context, bundleId uint64) {
+	bz := k.cdc.MustMarshal(&gogotypes.UInt64Value{Value: bundleId})
+	store := ctx.KVStore(k.storeKey)
+	store.Set([]byte(prefixBundleNextId), bz)
+}
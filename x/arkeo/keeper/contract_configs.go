@@ -0,0 +1,46 @@
+package keeper
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func (k KVStore) getContractConfigKey(ctx cosmos.Context, contractId uint64) string {
+	return k.GetKey(ctx, prefixContractConfig, strconv.FormatUint(contractId, 10))
+}
+
+// GetContractConfigIterator iterate contract configs
+func (k KVStore) GetContractConfigIterator(ctx cosmos.Context) cosmos.Iterator {
+	return k.getIterator(ctx, prefixContractConfig)
+}
+
+// GetContractConfig get the config of the given contract, an empty config
+// when not set
+func (k KVStore) GetContractConfig(ctx cosmos.Context, contractId uint64) (types.ContractConfig, error) {
+	config := types.ContractConfig{ContractId: contractId}
+	store := ctx.KVStore(k.storeKey)
+	key := k.getContractConfigKey(ctx, contractId)
+	if !store.Has([]byte(key)) {
+		return config, nil
+	}
+	err := k.cdc.Unmarshal(store.Get([]byte(key)), &config)
+	return config, err
+}
+
+// SetContractConfig save the contract config to key value store
+func (k KVStore) SetContractConfig(ctx cosmos.Context, config types.ContractConfig) error {
+	if config.ContractId == 0 {
+		return errors.New("cannot save a contract config with an empty contract id")
+	}
+	store := ctx.KVStore(k.storeKey)
+	store.Set([]byte(k.getContractConfigKey(ctx, config.ContractId)), k.cdc.MustMarshal(&config))
+	return nil
+}
+
+// ContractConfigExists check whether a config was set for the given contract
+func (k KVStore) ContractConfigExists(ctx cosmos.Context, contractId uint64) bool {
+	return k.has(ctx, k.getContractConfigKey(ctx, contractId))
+}
@@ -0,0 +1,122 @@
+package keeper
+
+import (
+	"cosmossdk.io/errors"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// OpenContractServices returns the services covered by the contract, the
+// services of the bundle when the message references one
+func (k KVStore) OpenContractServices(ctx cosmos.Context, msg *types.MsgOpenContract) (common.Services, error) {
+	if msg.BundleId == 0 {
+		service, err := common.NewService(msg.Service)
+		if err != nil {
+			return nil, err
+		}
+		return common.Services{service}, nil
+	}
+
+	bundle, err := k.GetBundle(ctx, msg.BundleId)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Id == 0 {
+		return nil, errors.Wrapf(types.ErrBundleNotFound, "bundle %d not found", msg.BundleId)
+	}
+	if !bundle.Provider.Equals(msg.Provider) {
+		return nil, errors.Wrapf(types.ErrInvalidBundle, "bundle %d belongs to provider %s", msg.BundleId, bundle.Provider)
+	}
+	return bundle.Services, nil
+}
+
+// OpenContractCost returns the fee charged to open the contract and the
+// deposit it must lock, once its rate is checked against the rates of the
+// provider (or of the bundle). The deposit of a pay-as-you-go contract is up
+// to the client, zero is returned.
+func (k KVStore) OpenContractCost(ctx cosmos.Context, msg *types.MsgOpenContract) (openCost, deposit cosmos.Coin, err error) {
+	services, err := k.OpenContractServices(ctx, msg)
+	if err != nil {
+		return openCost, deposit, err
+	}
+	provider, err := k.GetProvider(ctx, msg.Provider, services[0])
+	if err != nil {
+		return openCost, deposit, err
+	}
+	if provider.LastUpdate == 0 {
+		return openCost, deposit, errors.Wrapf(types.ErrProviderNotFound, "provider %s for service %s not found", msg.Provider, services[0])
+	}
+
+	// a bundle contract is charged the rates of the bundle rather than the
+	// rates of its services
+	if msg.BundleId > 0 {
+		bundle, err := k.GetBundle(ctx, msg.BundleId)
+		if err != nil {
+			return openCost, deposit, err
+		}
+		provider.SubscriptionRate = bundle.SubscriptionRate
+		provider.PayAsYouGoRate = bundle.PayAsYouGoRate
+	}
+
+	deposit = cosmos.NewCoin(msg.Rate.Denom, cosmos.ZeroInt())
+	switch msg.ContractType {
+	case types.ContractType_SUBSCRIPTION:
+		rate := cosmos.NewCoins(provider.SubscriptionRate...).AmountOf(msg.Rate.Denom)
+		if rate.IsZero() {
+			return openCost, deposit, errors.Wrapf(types.ErrOpenContractMismatchRate, "provider rates is 0, client sent %d", msg.Rate.Amount.Int64())
+		}
+		if !msg.Rate.Amount.Equal(rate) {
+			return openCost, deposit, errors.Wrapf(types.ErrOpenContractMismatchRate, "provider rates is %d, client sent %d", rate.Int64(), msg.Rate.Amount.Int64())
+		}
+		deposit.Amount = cosmos.NewInt(msg.Rate.Amount.Int64() * msg.Duration * msg.QueriesPerMinute)
+	case types.ContractType_PAY_AS_YOU_GO:
+		rate := cosmos.NewCoins(provider.PayAsYouGoRate...).AmountOf(msg.Rate.Denom)
+		if rate.IsZero() {
+			return openCost, deposit, errors.Wrapf(types.ErrOpenContractMismatchRate, "provider rates is 0, client sent %d", msg.Rate.Amount.Int64())
+		}
+		if !msg.Rate.Amount.Equal(rate) {
+			return openCost, deposit, errors.Wrapf(types.ErrOpenContractMismatchRate, "pay-as-you-go provider rate is %d, client sent %d", rate.Int64(), msg.Rate.Amount.Int64())
+		}
+	default:
+		return openCost, deposit, errors.Wrapf(types.ErrInvalidContractType, "%s", msg.ContractType.String())
+	}
+
+	return k.OpenContractFee(ctx), deposit, nil
+}
+
+// OpenContractFee returns the fee charged to open any contract, on top of its
+// deposit
+func (k KVStore) OpenContractFee(ctx cosmos.Context) cosmos.Coin {
+	return getCoin(configs.GetConfigValues(k.GetVersion(ctx)).GetInt64Value(configs.OpenContractCost))
+}
+
+// ContractRate returns the rate the provider (or the bundle) currently charges
+// for a contract of the type and denom of the given one
+func (k KVStore) ContractRate(ctx cosmos.Context, contract types.Contract) (cosmos.Int, error) {
+	var subscriptionRate, payAsYouGoRate []cosmos.Coin
+	if contract.BundleId > 0 {
+		bundle, err := k.GetBundle(ctx, contract.BundleId)
+		if err != nil {
+			return cosmos.ZeroInt(), err
+		}
+		subscriptionRate, payAsYouGoRate = bundle.SubscriptionRate, bundle.PayAsYouGoRate
+	} else {
+		provider, err := k.GetProvider(ctx, contract.Provider, contract.Service)
+		if err != nil {
+			return cosmos.ZeroInt(), err
+		}
+		subscriptionRate, payAsYouGoRate = provider.SubscriptionRate, provider.PayAsYouGoRate
+	}
+
+	switch contract.Type {
+	case types.ContractType_SUBSCRIPTION:
+		return cosmos.NewCoins(subscriptionRate...).AmountOf(contract.Rate.Denom), nil
+	case types.ContractType_PAY_AS_YOU_GO:
+		return cosmos.NewCoins(payAsYouGoRate...).AmountOf(contract.Rate.Denom), nil
+	default:
+		return cosmos.ZeroInt(), errors.Wrapf(types.ErrInvalidContractType, "%s", contract.Type.String())
+	}
+}
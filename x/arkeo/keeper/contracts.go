@@ -2,6 +2,8 @@ package keeper
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/arkeonetwork/arkeo/common"
@@ -130,6 +132,49 @@ func (k KVStore) RemoveContractExpirationSet(ctx cosmos.Context, height int64) {
 	k.del(ctx, k.GetKey(ctx, prefixContractExpirationSet, strconv.FormatInt(height, 10)))
 }
 
+// GetSettlingContracts returns the contracts the end blocker settles after the
+// from height, up to the to height included, by settlement height. A contract
+// is listed at the first height it is settled at: a closed pay-as-you-go
+// contract is settled before the end of its settlement period, and a contract
+// with a settlement period only expires at its expiration height.
+func (k KVStore) GetSettlingContracts(ctx cosmos.Context, from, to int64) ([]types.ExpiringContract, error) {
+	var contracts []types.ExpiringContract
+	seen := make(map[uint64]bool)
+	for height := from + 1; height <= to; height++ {
+		set, err := k.GetContractExpirationSet(ctx, height)
+		if err != nil {
+			return nil, err
+		}
+		for _, contractId := range set.ContractSet.ContractIds {
+			if seen[contractId] {
+				continue
+			}
+			contract, err := k.GetContract(ctx, contractId)
+			if err != nil {
+				return nil, err
+			}
+			if contract.Client.IsEmpty() || contract.SettlementHeight > 0 {
+				continue
+			}
+			if contract.Expiration() == height && contract.SettlementPeriodEnd() > height {
+				continue
+			}
+			seen[contractId] = true
+			contracts = append(contracts, types.ExpiringContract{
+				Contract:         contract,
+				SettlementHeight: height,
+			})
+		}
+	}
+	sort.SliceStable(contracts, func(i, j int) bool {
+		if contracts[i].SettlementHeight != contracts[j].SettlementHeight {
+			return contracts[i].SettlementHeight < contracts[j].SettlementHeight
+		}
+		return contracts[i].Contract.Id < contracts[j].Contract.Id
+	})
+	return contracts, nil
+}
+
 func (kvStore KVStore) GetAndIncrementNextContractId(ctx cosmos.Context) uint64 {
 	contractId := kvStore.GetNextContractId(ctx)
 	kvStore.SetNextContractId(ctx, contractId+1) // increment and set
@@ -215,7 +260,7 @@ func (k KVStore) GetActiveContractForUser(ctx cosmos.Context, user, provider com
 		if err != nil {
 			return types.Contract{}, err
 		}
-		if contract.Provider.Equals(provider) && contract.Service.Equals(service) && contract.IsOpen(ctx.BlockHeight()) {
+		if contract.Provider.Equals(provider) && contract.HasService(service) && contract.IsOpen(ctx.BlockHeight()) {
 			return contract, nil
 		}
 	}
@@ -247,3 +292,149 @@ func (k KVStore) RemoveFromUserContractSet(ctx cosmos.Context, user common.PubKe
 func (k KVStore) GetUserContractSetIterator(ctx cosmos.Context) cosmos.Iterator {
 	return k.getIterator(ctx, prefixUserContractSet)
 }
+
+// getClientContractPrefix returns the prefix of the index keys of the
+// contracts of the client, the ids are zero padded to sort them by id
+func (k KVStore) getClientContractPrefix(ctx cosmos.Context, client common.PubKey) string {
+	return k.GetKey(ctx, prefixClientContract, client.String()+"/")
+}
+
+func (k KVStore) getClientContractKey(ctx cosmos.Context, client common.PubKey, contractId uint64) string {
+	return fmt.Sprintf("%s%020d", k.getClientContractPrefix(ctx, client), contractId)
+}
+
+// SetClientContract indexes the contract under its client, until it is settled
+func (k KVStore) SetClientContract(ctx cosmos.Context, client common.PubKey, contractId uint64) error {
+	if client.IsEmpty() {
+		return errors.New("cannot index a contract with a blank client")
+	}
+	bz := k.cdc.MustMarshal(&gogotypes.UInt64Value{Value: contractId})
+	store := ctx.KVStore(k.storeKey)
+	store.Set([]byte(k.getClientContractKey(ctx, client, contractId)), bz)
+	return nil
+}
+
+// RemoveClientContract drops the contract from the index of its client
+func (k KVStore) RemoveClientContract(ctx cosmos.Context, client common.PubKey, contractId uint64) {
+	k.del(ctx, k.getClientContractKey(ctx, client, contractId))
+}
+
+// GetClientContractIterator iterate the contract ids indexed under the client
+func (k KVStore) GetClientContractIterator(ctx cosmos.Context, client common.PubKey) cosmos.Iterator {
+	store := ctx.KVStore(k.storeKey)
+	return cosmos.KVStorePrefixIterator(store, []byte(k.getClientContractPrefix(ctx, client)))
+}
+
+// getProviderContractPrefix returns the prefix of the index keys of the
+// contracts against the provider for the service, sorted by id
+func (k KVStore) getProviderContractPrefix(ctx cosmos.Context, provider common.PubKey, service common.Service) string {
+	return k.GetKey(ctx, prefixProviderContract, fmt.Sprintf("%s/%s/", provider, service))
+}
+
+func (k KVStore) getProviderContractKey(ctx cosmos.Context, provider common.PubKey, service common.Service, contractId uint64) string {
+	return fmt.Sprintf("%s%020d", k.getProviderContractPrefix(ctx, provider, service), contractId)
+}
+
+// SetProviderContract indexes the contract under its provider and a service it
+// covers, until it is settled
+func (k KVStore) SetProviderContract(ctx cosmos.Context, provider common.PubKey, service common.Service, contractId uint64) error {
+	if provider.IsEmpty() {
+		return errors.New("cannot index a contract with a blank provider")
+	}
+	bz := k.cdc.MustMarshal(&gogotypes.UInt64Value{Value: contractId})
+	store := ctx.KVStore(k.storeKey)
+	store.Set([]byte(k.getProviderContractKey(ctx, provider, service, contractId)), bz)
+	return nil
+}
+
+// RemoveProviderContract drops the contract from the index of its provider
+func (k KVStore) RemoveProviderContract(ctx cosmos.Context, provider common.PubKey, service common.Service, contractId uint64) {
+	k.del(ctx, k.getProviderContractKey(ctx, provider, service, contractId))
+}
+
+// GetProviderContractIterator iterate the contract ids indexed under the
+// provider and service
+func (k KVStore) GetProviderContractIterator(ctx cosmos.Context, provider common.PubKey, service common.Service) cosmos.Iterator {
+	store := ctx.KVStore(k.storeKey)
+	return cosmos.KVStorePrefixIterator(store, []byte(k.getProviderContractPrefix(ctx, provider, service)))
+}
+
+func (k KVStore) getClaimCountKey(ctx cosmos.Context, provider common.PubKey) string {
+	return k.GetKey(ctx, prefixClaimCount, provider.String())
+}
+
+// GetClaimCount get the number of claims of the provider in the current block.
+// The counts are kept in the transient store, dropped once the block is
+// committed.
+func (k KVStore) GetClaimCount(ctx cosmos.Context, provider common.PubKey) int64 {
+	key := k.getClaimCountKey(ctx, provider)
+	store := ctx.TransientStore(k.tkey)
+	if !store.Has([]byte(key)) {
+		return 0
+	}
+	var count types.ProtoInt64
+	k.cdc.MustUnmarshal(store.Get([]byte(key)), &count)
+	return count.Value
+}
+
+// SetClaimCount set the number of claims of the provider in the current block
+func (k KVStore) SetClaimCount(ctx cosmos.Context, provider common.PubKey, count int64) {
+	key := k.getClaimCountKey(ctx, provider)
+	store := ctx.TransientStore(k.tkey)
+	store.Set([]byte(key), k.cdc.MustMarshal(&types.ProtoInt64{Value: count}))
+}
+
+func (k KVStore) getReserveContributionKey(ctx cosmos.Context, denom string, contractId uint64) string {
+	return k.GetKey(ctx, prefixReserveContribution, fmt.Sprintf("%s/%d", denom, contractId))
+}
+
+// AddReserveContribution adds the amount to what the contract paid into the
+// reserve since the last validator payout
+func (k KVStore) AddReserveContribution(ctx cosmos.Context, contractId uint64, amount cosmos.Coin) {
+	if amount.IsZero() {
+		return
+	}
+	key := []byte(k.getReserveContributionKey(ctx, amount.Denom, contractId))
+	store := ctx.KVStore(k.storeKey)
+	contribution := types.ReserveContribution{
+		ContractId: contractId,
+		Amount:     cosmos.NewCoin(amount.Denom, cosmos.ZeroInt()),
+	}
+	if store.Has(key) {
+		k.cdc.MustUnmarshal(store.Get(key), &contribution)
+	}
+	contribution.Amount = contribution.Amount.Add(amount)
+	store.Set(key, k.cdc.MustMarshal(&contribution))
+}
+
+// GetReserveContributions returns what contracts paid into the reserve in the
+// denom since the last validator payout
+func (k KVStore) GetReserveContributions(ctx cosmos.Context, denom string) ([]types.ReserveContribution, error) {
+	store := ctx.KVStore(k.storeKey)
+	iter := cosmos.KVStorePrefixIterator(store, []byte(k.GetKey(ctx, prefixReserveContribution, denom+"/")))
+	defer iter.Close()
+	var contributions []types.ReserveContribution
+	for ; iter.Valid(); iter.Next() {
+		var contribution types.ReserveContribution
+		if err := k.cdc.Unmarshal(iter.Value(), &contribution); err != nil {
+			return nil, err
+		}
+		contributions = append(contributions, contribution)
+	}
+	return contributions, nil
+}
+
+// RemoveReserveContributions forgets the contributions in the denom, once
+// validators are paid out of them
+func (k KVStore) RemoveReserveContributions(ctx cosmos.Context, denom string) {
+	store := ctx.KVStore(k.storeKey)
+	iter := cosmos.KVStorePrefixIterator(store, []byte(k.GetKey(ctx, prefixReserveContribution, denom+"/")))
+	var keys [][]byte
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	for _, key := range keys {
+		store.Delete(key)
+	}
+}
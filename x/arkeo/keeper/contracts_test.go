@@ -5,7 +5,11 @@ import (
 
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/query"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestContract(t *testing.T) {
@@ -27,6 +31,88 @@ func TestContract(t *testing.T) {
 	require.False(t, k.ContractExists(ctx, contract.Id))
 }
 
+func TestContractConfig(t *testing.T) {
+	ctx, k := SetupKeeper(t)
+	require.Error(t, k.SetContractConfig(ctx, types.ContractConfig{}))
+
+	config, err := k.GetContractConfig(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), config.ContractId)
+	require.False(t, k.ContractConfigExists(ctx, 3))
+	_, err = k.ContractConfig(sdk.WrapSDKContext(ctx), &types.QueryContractConfigRequest{ContractId: 3})
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	config.Cors = types.ContractCORs{AllowMethods: []string{"GET"}}
+	config.WhitelistIps = []string{"10.0.0.1"}
+	config.PerUserRateLimit = 10
+	require.NoError(t, k.SetContractConfig(ctx, config))
+	require.True(t, k.ContractConfigExists(ctx, 3))
+
+	res, err := k.ContractConfig(sdk.WrapSDKContext(ctx), &types.QueryContractConfigRequest{ContractId: 3})
+	require.NoError(t, err)
+	require.Equal(t, config, res.Config)
+}
+
+func TestContractsByClient(t *testing.T) {
+	ctx, k := SetupKeeper(t)
+	ctx = ctx.WithBlockHeight(50)
+	goCtx := sdk.WrapSDKContext(ctx)
+	client := types.GetRandomPubKey()
+	require.Error(t, k.SetClientContract(ctx, common.EmptyPubKey, 1))
+
+	// contracts 1 to 3 are of the client, 2 is expired and 3 for another service
+	for id := uint64(1); id <= 4; id++ {
+		contract := types.NewContract(types.GetRandomPubKey(), common.BTCService, client)
+		contract.Id = id
+		contract.Height = 10
+		contract.Duration = 100
+		switch id {
+		case 2:
+			contract.Duration = 20
+		case 3:
+			contract.Service = common.ETHService
+		case 4:
+			contract.Client = types.GetRandomPubKey()
+		}
+		require.NoError(t, k.SetContract(ctx, contract))
+		require.NoError(t, k.SetClientContract(ctx, contract.Client, contract.Id))
+	}
+
+	_, err := k.ContractsByClient(goCtx, &types.QueryContractsByClientRequest{Client: "bogus"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = k.ContractsByClient(goCtx, &types.QueryContractsByClientRequest{Client: client.String(), Service: "bogus"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	ids := func(req *types.QueryContractsByClientRequest) []uint64 {
+		req.Client = client.String()
+		res, err := k.ContractsByClient(goCtx, req)
+		require.NoError(t, err)
+		ids := make([]uint64, 0, len(res.Contracts))
+		for _, contract := range res.Contracts {
+			ids = append(ids, contract.Id)
+		}
+		return ids
+	}
+	require.Equal(t, []uint64{1, 2, 3}, ids(&types.QueryContractsByClientRequest{}))
+	require.Equal(t, []uint64{1, 2}, ids(&types.QueryContractsByClientRequest{Service: common.BTCService.String()}))
+	require.Equal(t, []uint64{1, 3}, ids(&types.QueryContractsByClientRequest{ActiveOnly: true}))
+	require.Equal(t, []uint64{1}, ids(&types.QueryContractsByClientRequest{Service: common.BTCService.String(), ActiveOnly: true}))
+
+	// paginated
+	res, err := k.ContractsByClient(goCtx, &types.QueryContractsByClientRequest{
+		Client:     client.String(),
+		Pagination: &query.PageRequest{Limit: 2, CountTotal: true},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Contracts, 2)
+	require.Equal(t, uint64(3), res.Pagination.Total)
+	require.Equal(t, []uint64{3}, ids(&types.QueryContractsByClientRequest{Pagination: &query.PageRequest{Key: res.Pagination.NextKey}}))
+
+	// settled contracts are dropped from the index
+	k.RemoveClientContract(ctx, client, 1)
+	require.Equal(t, []uint64{3}, ids(&types.QueryContractsByClientRequest{ActiveOnly: true}))
+}
+
 func TestContractExpirationSet(t *testing.T) {
 	ctx, k := SetupKeeper(t)
 	set := types.ContractExpirationSet{}
@@ -1,6 +1,7 @@
 package keeper
 
 import (
+	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 )
@@ -16,7 +17,7 @@ func (k msgServer) EmitBondProviderEvent(ctx cosmos.Context, bond cosmos.Int, ms
 	)
 }
 
-func (k msgServer) EmitCloseContractEvent(ctx cosmos.Context, contract *types.Contract) error {
+func (k msgServer) EmitCloseContractEvent(ctx cosmos.Context, contract *types.Contract, refund cosmos.Int) error {
 	return ctx.EventManager().EmitTypedEvent(
 		&types.EventCloseContract{
 			ContractId: contract.Id,
@@ -24,6 +25,8 @@ func (k msgServer) EmitCloseContractEvent(ctx cosmos.Context, contract *types.Co
 			Service:    contract.Service.String(),
 			Client:     contract.Client,
 			Delegate:   contract.Delegate,
+			Services:   contract.ServiceSet().Strings(),
+			Refund:     refund,
 		},
 	)
 }
@@ -36,6 +39,7 @@ func (k msgServer) EmitModProviderEvent(ctx cosmos.Context, msg *types.MsgModPro
 			Service:             provider.Service.String(),
 			MetadataUri:         provider.MetadataUri,
 			MetadataNonce:       provider.MetadataNonce,
+			MetadataHash:        provider.MetadataHash,
 			Status:              provider.Status,
 			MinContractDuration: provider.MinContractDuration,
 			MaxContractDuration: provider.MaxContractDuration,
@@ -43,6 +47,9 @@ func (k msgServer) EmitModProviderEvent(ctx cosmos.Context, msg *types.MsgModPro
 			PayAsYouGoRate:      provider.PayAsYouGoRate,
 			Bond:                provider.Bond,
 			SettlementDuration:  provider.SettlementDuration,
+			PayoutSplits:        provider.PayoutSplits,
+			MethodRates:         provider.MethodRates,
+			MinDeposit:          provider.MinDeposit,
 		},
 	)
 }
@@ -64,11 +71,83 @@ func (k msgServer) EmitOpenContractEvent(ctx cosmos.Context, openCost int64, con
 			SettlementDuration: contract.SettlementDuration,
 			Authorization:      contract.Authorization,
 			QueriesPerMinute:   contract.QueriesPerMinute,
+			Services:           contract.ServiceSet().Strings(),
+			BundleId:           contract.BundleId,
+			MethodRates:        contract.MethodRates,
+			Spenders:           contract.Spenders,
 		},
 	)
 }
 
-func (mgr Manager) EmitContractSettlementEvent(ctx cosmos.Context, debt, valIncome cosmos.Int, contract *types.Contract) error {
+func (k msgServer) EmitSetBundleEvent(ctx cosmos.Context, bundle *types.Bundle) error {
+	return ctx.EventManager().EmitTypedEvent(
+		&types.EventSetBundle{
+			Provider:         bundle.Provider,
+			Id:               bundle.Id,
+			Name:             bundle.Name,
+			Services:         common.Services(bundle.Services).Strings(),
+			SubscriptionRate: bundle.SubscriptionRate,
+			PayAsYouGoRate:   bundle.PayAsYouGoRate,
+		},
+	)
+}
+
+func (k msgServer) EmitProviderStrikeEvent(ctx cosmos.Context, provider *types.Provider, contract *types.Contract, reason string) error {
+	return ctx.EventManager().EmitTypedEvent(
+		&types.EventProviderStrike{
+			Provider:   provider.PubKey,
+			Service:    provider.Service.String(),
+			ContractId: contract.Id,
+			Reporter:   contract.Client,
+			Reason:     reason,
+			Strikes:    provider.Strikes,
+		},
+	)
+}
+
+func (k msgServer) EmitAttestProviderDowntimeEvent(ctx cosmos.Context, contract *types.Contract, service common.Service, attestations uint64) error {
+	return ctx.EventManager().EmitTypedEvent(
+		&types.EventAttestProviderDowntime{
+			Provider:     contract.Provider,
+			Service:      service.String(),
+			ContractId:   contract.Id,
+			Client:       contract.Client,
+			Attestations: attestations,
+			Height:       ctx.BlockHeight(),
+		},
+	)
+}
+
+func (k msgServer) EmitSetContractConfigEvent(ctx cosmos.Context, contract *types.Contract, config *types.ContractConfig) error {
+	return ctx.EventManager().EmitTypedEvent(
+		&types.EventSetContractConfig{
+			ContractId:       contract.Id,
+			Provider:         contract.Provider,
+			Service:          contract.Service.String(),
+			Client:           contract.Client,
+			Cors:             config.Cors,
+			WhitelistIps:     config.WhitelistIps,
+			PerUserRateLimit: config.PerUserRateLimit,
+		},
+	)
+}
+
+func (k msgServer) EmitExtendContractEvent(ctx cosmos.Context, contract *types.Contract, oldExpiration int64) error {
+	evt := types.NewExtendContractEvent(contract, oldExpiration)
+	return ctx.EventManager().EmitTypedEvent(&evt)
+}
+
+func (k msgServer) EmitTopUpContractEvent(ctx cosmos.Context, contract *types.Contract, added cosmos.Int) error {
+	evt := types.NewTopUpContractEvent(contract, added)
+	return ctx.EventManager().EmitTypedEvent(&evt)
+}
+
+func (k msgServer) EmitUpdateContractDelegateEvent(ctx cosmos.Context, contract *types.Contract, oldDelegate common.PubKey) error {
+	evt := types.NewUpdateContractDelegateEvent(contract, oldDelegate, ctx.BlockHeight())
+	return ctx.EventManager().EmitTypedEvent(&evt)
+}
+
+func (mgr Manager) EmitContractSettlementEvent(ctx cosmos.Context, debt, valIncome cosmos.Int, payouts []types.ProviderPayout, contract *types.Contract) error {
 	return ctx.EventManager().EmitTypedEvent(
 		&types.EventSettleContract{
 			Provider:   contract.Provider,
@@ -81,10 +160,34 @@ func (mgr Manager) EmitContractSettlementEvent(ctx cosmos.Context, debt, valInco
 			Height:     contract.Height,
 			Paid:       debt,
 			Reserve:    valIncome,
+			Payouts:    payouts,
+			Services:   contract.ServiceSet().Strings(),
+		},
+	)
+}
+
+func (mgr Manager) EmitContractSpentEvent(ctx cosmos.Context, contract *types.Contract) error {
+	return ctx.EventManager().EmitTypedEvent(
+		&types.EventContractSpent{
+			ContractId: contract.Id,
+			Provider:   contract.Provider,
+			Client:     contract.Client,
+			Nonce:      contract.Nonce,
+			Height:     ctx.BlockHeight(),
 		},
 	)
 }
 
+func (mgr Manager) EmitContractExpiredEvent(ctx cosmos.Context, contract *types.Contract) error {
+	evt := types.NewContractExpiredEvent(contract, ctx.BlockHeight())
+	return ctx.EventManager().EmitTypedEvent(&evt)
+}
+
+func (mgr Manager) EmitContractExpiringSoonEvent(ctx cosmos.Context, contract *types.Contract, settlementHeight int64) error {
+	evt := types.NewContractExpiringSoonEvent(contract, settlementHeight, ctx.BlockHeight())
+	return ctx.EventManager().EmitTypedEvent(&evt)
+}
+
 func (mgr Manager) EmitValidatorPayoutEvent(ctx cosmos.Context, acc cosmos.AccAddress, rwd cosmos.Int) error {
 	return ctx.EventManager().EmitTypedEvent(
 		&types.EventValidatorPayout{
@@ -93,3 +196,21 @@ func (mgr Manager) EmitValidatorPayoutEvent(ctx cosmos.Context, acc cosmos.AccAd
 		},
 	)
 }
+
+func (mgr Manager) EmitPayoutContributionEvent(ctx cosmos.Context, acc cosmos.AccAddress, contractId uint64, amount cosmos.Int) error {
+	evt := types.NewPayoutContributionEvent(acc, contractId, amount)
+	return ctx.EventManager().EmitTypedEvent(&evt)
+}
+
+func (mgr Manager) EmitProviderSlashedEvent(ctx cosmos.Context, provider *types.Provider, slashed cosmos.Int, attestations uint64) error {
+	return ctx.EventManager().EmitTypedEvent(
+		&types.EventProviderSlashed{
+			Provider:     provider.PubKey,
+			Service:      provider.Service.String(),
+			Slashed:      slashed,
+			Bond:         provider.Bond,
+			Attestations: attestations,
+			Height:       ctx.BlockHeight(),
+		},
+	)
+}
@@ -0,0 +1,27 @@
+package keeper
+
+import (
+	"context"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func (k KVStore) ModuleBalances(c context.Context, req *types.QueryModuleBalancesRequest) (*types.QueryModuleBalancesResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	return &types.QueryModuleBalancesResponse{
+		Bonded:          totalBonded(ctx, k),
+		BondBalance:     k.GetBalanceOfModule(ctx, types.ProviderName, configs.Denom),
+		Deposits:        totalContractDeposits(ctx, k),
+		ContractBalance: k.GetBalance(ctx, k.GetModuleAccAddress(types.ContractName)),
+		Reserve:         k.GetBalance(ctx, k.GetModuleAccAddress(types.ReserveName)),
+	}, nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/store/prefix"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/query"
+	gogotypes "github.com/gogo/protobuf/types"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -88,3 +89,210 @@ func (k KVStore) ActiveContract(goCtx context.Context, req *types.QueryActiveCon
 
 	return &types.QueryActiveContractResponse{Contract: activeContract}, nil
 }
+
+func (k KVStore) ContractConfig(c context.Context, req *types.QueryContractConfigRequest) (*types.QueryContractConfigResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	if !k.ContractConfigExists(ctx, req.ContractId) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+
+	config, err := k.GetContractConfig(ctx, req.ContractId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &types.QueryContractConfigResponse{Config: config}, nil
+}
+
+func (k KVStore) ContractsByClient(c context.Context, req *types.QueryContractsByClientRequest) (*types.QueryContractsByClientResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	client, err := common.NewPubKey(req.Client)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid client pubkey")
+	}
+	var service common.Service
+	if len(req.Service) > 0 {
+		service, err = common.NewService(req.Service)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid service")
+		}
+	}
+
+	var contracts []types.Contract
+	store := ctx.KVStore(k.storeKey)
+	clientStore := prefix.NewStore(store, types.KeyPrefix(k.getClientContractPrefix(ctx, client)))
+
+	pageRes, err := query.FilteredPaginate(clientStore, req.Pagination, func(key, value []byte, accumulate bool) (bool, error) {
+		var id gogotypes.UInt64Value
+		if err := k.cdc.Unmarshal(value, &id); err != nil {
+			return false, err
+		}
+		contract, err := k.GetContract(ctx, id.GetValue())
+		if err != nil {
+			return false, err
+		}
+		if contract.IsEmpty() {
+			return false, nil
+		}
+		if len(req.Service) > 0 && !contract.HasService(service) {
+			return false, nil
+		}
+		if req.ActiveOnly && !contract.IsOpen(ctx.BlockHeight()) {
+			return false, nil
+		}
+		if accumulate {
+			contracts = append(contracts, contract)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &types.QueryContractsByClientResponse{Contracts: contracts, Pagination: pageRes}, nil
+}
+
+func (k KVStore) ContractsByProvider(c context.Context, req *types.QueryContractsByProviderRequest) (*types.QueryContractsByProviderResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	provider, err := common.NewPubKey(req.Provider)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid provider pubkey")
+	}
+	service, err := common.NewService(req.Service)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid service")
+	}
+
+	var contracts []types.ProviderContract
+	height := ctx.BlockHeight()
+	store := ctx.KVStore(k.storeKey)
+	providerStore := prefix.NewStore(store, types.KeyPrefix(k.getProviderContractPrefix(ctx, provider, service)))
+
+	pageRes, err := query.FilteredPaginate(providerStore, req.Pagination, func(key, value []byte, accumulate bool) (bool, error) {
+		var id gogotypes.UInt64Value
+		if err := k.cdc.Unmarshal(value, &id); err != nil {
+			return false, err
+		}
+		contract, err := k.GetContract(ctx, id.GetValue())
+		if err != nil {
+			return false, err
+		}
+		if contract.IsEmpty() {
+			return false, nil
+		}
+		// an expired contract is settling until the end blocker settles it
+		settling := !contract.IsOpen(height)
+		if settling && (!req.IncludeSettling || contract.SettlementHeight > 0) {
+			return false, nil
+		}
+		if accumulate {
+			var blocks int64
+			if contract.Expiration() > height {
+				blocks = contract.Expiration() - height
+			}
+			contracts = append(contracts, types.ProviderContract{
+				Contract:            contract,
+				BlocksUntilExpiry:   blocks,
+				Unsettled:           contract.Debt(height),
+				SettlementPeriodEnd: contract.SettlementPeriodEnd(),
+				Settling:            settling,
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &types.QueryContractsByProviderResponse{Contracts: contracts, Pagination: pageRes}, nil
+}
+
+func (k KVStore) ContractCost(c context.Context, req *types.QueryContractCostRequest) (*types.QueryContractCostResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	provider, err := common.NewPubKey(req.Provider)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid provider pubkey")
+	}
+
+	// priced the same way as the contract would be on open
+	openCost, deposit, err := k.OpenContractCost(ctx, &types.MsgOpenContract{
+		Provider:         provider,
+		Service:          req.Service,
+		ContractType:     req.ContractType,
+		Duration:         req.Duration,
+		Rate:             req.Rate,
+		QueriesPerMinute: req.QueriesPerMinute,
+		BundleId:         req.BundleId,
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &types.QueryContractCostResponse{OpenCost: openCost, Deposit: deposit}, nil
+}
+
+// maxExpiringContractsBlocks bounds the blocks the expiring contracts query
+// looks ahead, each block being a store read
+const maxExpiringContractsBlocks = 14400
+
+func (k KVStore) ExpiringContracts(c context.Context, req *types.QueryExpiringContractsRequest) (*types.QueryExpiringContractsResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	if req.Blocks <= 0 || req.Blocks > maxExpiringContractsBlocks {
+		return nil, status.Errorf(codes.InvalidArgument, "blocks must be between 1 and %d", maxExpiringContractsBlocks)
+	}
+	var provider common.PubKey
+	var err error
+	if len(req.Provider) > 0 {
+		provider, err = common.NewPubKey(req.Provider)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid provider pubkey")
+		}
+	}
+	var service common.Service
+	if len(req.Service) > 0 {
+		service, err = common.NewService(req.Service)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "invalid service")
+		}
+	}
+
+	height := ctx.BlockHeight()
+	settling, err := k.GetSettlingContracts(ctx, height, height+req.Blocks)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	contracts := make([]types.ExpiringContract, 0, len(settling))
+	for _, expiring := range settling {
+		if len(req.Provider) > 0 && !expiring.Contract.Provider.Equals(provider) {
+			continue
+		}
+		if len(req.Service) > 0 && !expiring.Contract.HasService(service) {
+			continue
+		}
+		expiring.BlocksUntilSettlement = expiring.SettlementHeight - height
+		contracts = append(contracts, expiring)
+	}
+
+	return &types.QueryExpiringContractsResponse{Contracts: contracts}, nil
+}
@@ -2,8 +2,12 @@ package keeper
 
 import (
 	"context"
+	"sort"
+	"strings"
 
 	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 
 	"github.com/cosmos/cosmos-sdk/store/prefix"
@@ -67,3 +71,307 @@ func (k KVStore) FetchProvider(c context.Context, req *types.QueryFetchProviderR
 
 	return &types.QueryFetchProviderResponse{Provider: val}, nil
 }
+
+func (k KVStore) ProviderUptime(c context.Context, req *types.QueryProviderUptimeRequest) (*types.QueryProviderUptimeResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	pk, err := common.NewPubKey(req.Pubkey)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "pubkey not found")
+	}
+
+	service, err := common.NewService(req.Service)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "service not found")
+	}
+
+	uptime, err := k.GetProviderUptime(ctx, pk, service)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+
+	record, err := k.GetProviderUptimeRecord(ctx, pk, service)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &types.QueryProviderUptimeResponse{Uptime: uptime, Record: record}, nil
+}
+
+func (k KVStore) ProviderRates(c context.Context, req *types.QueryProviderRatesRequest) (*types.QueryProviderRatesResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	pk, err := common.NewPubKey(req.Pubkey)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid pubkey")
+	}
+
+	// the providers of a pubkey are keyed by pubkey then service
+	store := ctx.KVStore(k.storeKey)
+	iter := sdk.KVStorePrefixIterator(store, []byte(k.GetKey(ctx, prefixProvider, pk.String()+"/")))
+	defer iter.Close()
+
+	rates := []types.ProviderServiceRates{}
+	for ; iter.Valid(); iter.Next() {
+		var provider types.Provider
+		if err := k.cdc.Unmarshal(iter.Value(), &provider); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		rates = append(rates, types.ProviderServiceRates{
+			Service:             provider.Service.String(),
+			Status:              provider.Status,
+			SubscriptionRate:    provider.SubscriptionRate,
+			PayAsYouGoRate:      provider.PayAsYouGoRate,
+			MinContractDuration: provider.MinContractDuration,
+			MaxContractDuration: provider.MaxContractDuration,
+			SettlementDuration:  provider.SettlementDuration,
+		})
+	}
+	if len(rates) == 0 {
+		return nil, status.Errorf(codes.NotFound, "provider %s not found", pk)
+	}
+
+	return &types.QueryProviderRatesResponse{Rates: rates}, nil
+}
+
+func (k KVStore) ProviderBundles(c context.Context, req *types.QueryProviderBundlesRequest) (*types.QueryProviderBundlesResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	pk, err := common.NewPubKey(req.Pubkey)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid pubkey")
+	}
+
+	// bundles are keyed by id only, every bundle is read
+	iter := k.GetBundleIterator(ctx)
+	defer iter.Close()
+	bundles := []types.Bundle{}
+	for ; iter.Valid(); iter.Next() {
+		var bundle types.Bundle
+		if err := k.cdc.Unmarshal(iter.Value(), &bundle); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if bundle.Provider.Equals(pk) {
+			bundles = append(bundles, bundle)
+		}
+	}
+
+	return &types.QueryProviderBundlesResponse{Bundles: bundles}, nil
+}
+
+// providerSearch holds the parsed filters of a SearchProviders request
+type providerSearch struct {
+	service       common.Service
+	status        *types.ProviderStatus
+	minBond       cosmos.Int
+	maxRate       *cosmos.Coin
+	rateDenom     string
+	contractType  *types.ContractType
+	minSettlement int64
+	maxSettlement int64
+	sort          string
+}
+
+func newProviderSearch(req *types.QuerySearchProvidersRequest) (providerSearch, error) {
+	search := providerSearch{
+		minBond:       cosmos.ZeroInt(),
+		rateDenom:     configs.Denom,
+		minSettlement: req.MinSettlementDuration,
+		maxSettlement: req.MaxSettlementDuration,
+		sort:          strings.ToLower(req.Sort),
+	}
+	if req.Service != "" {
+		service, err := common.NewService(req.Service)
+		if err != nil {
+			return search, status.Error(codes.InvalidArgument, "invalid service")
+		}
+		search.service = service
+	}
+	if req.Status != "" {
+		value, ok := types.ProviderStatus_value[strings.ToUpper(req.Status)]
+		if !ok {
+			return search, status.Errorf(codes.InvalidArgument, "invalid status %s", req.Status)
+		}
+		providerStatus := types.ProviderStatus(value)
+		search.status = &providerStatus
+	}
+	if req.MinBond != "" {
+		minBond, ok := cosmos.NewIntFromString(req.MinBond)
+		if !ok || minBond.IsNegative() {
+			return search, status.Errorf(codes.InvalidArgument, "invalid min bond %s", req.MinBond)
+		}
+		search.minBond = minBond
+	}
+	if req.MaxRate != "" {
+		maxRate, err := cosmos.ParseCoin(req.MaxRate)
+		if err != nil {
+			return search, status.Errorf(codes.InvalidArgument, "invalid max rate %s", req.MaxRate)
+		}
+		search.maxRate = &maxRate
+		search.rateDenom = maxRate.Denom
+	}
+	if req.ContractType != "" {
+		value, ok := types.ContractType_value[strings.ToUpper(strings.ReplaceAll(req.ContractType, "-", "_"))]
+		if !ok {
+			return search, status.Errorf(codes.InvalidArgument, "invalid contract type %s", req.ContractType)
+		}
+		contractType := types.ContractType(value)
+		search.contractType = &contractType
+	}
+	if search.minSettlement < 0 || search.maxSettlement < 0 || (search.maxSettlement > 0 && search.maxSettlement < search.minSettlement) {
+		return search, status.Error(codes.InvalidArgument, "invalid settlement duration range")
+	}
+	switch search.sort {
+	case "", "bond", "rate":
+	default:
+		return search, status.Errorf(codes.InvalidArgument, "invalid sort %s, expected bond or rate", req.Sort)
+	}
+	return search, nil
+}
+
+// rate returns the rate the provider charges in the denom of the search, the
+// lowest of its subscription and pay-as-you-go rates unless the search is
+// for a contract type. False is returned when it charges no rate in the denom.
+func (s providerSearch) rate(provider types.Provider) (cosmos.Int, bool) {
+	subscription := cosmos.NewCoins(provider.SubscriptionRate...).AmountOf(s.rateDenom)
+	payAsYouGo := cosmos.NewCoins(provider.PayAsYouGoRate...).AmountOf(s.rateDenom)
+	if s.contractType != nil {
+		rate := subscription
+		if *s.contractType == types.ContractType_PAY_AS_YOU_GO {
+			rate = payAsYouGo
+		}
+		return rate, rate.IsPositive()
+	}
+	switch {
+	case subscription.IsZero():
+		return payAsYouGo, payAsYouGo.IsPositive()
+	case payAsYouGo.IsZero():
+		return subscription, true
+	default:
+		return sdk.MinInt(subscription, payAsYouGo), true
+	}
+}
+
+func (s providerSearch) match(provider types.Provider) bool {
+	if !s.service.IsEmpty() && !provider.Service.Equals(s.service) {
+		return false
+	}
+	if s.status != nil && provider.Status != *s.status {
+		return false
+	}
+	if provider.Bond.IsNil() || provider.Bond.LT(s.minBond) {
+		return false
+	}
+	if provider.SettlementDuration < s.minSettlement {
+		return false
+	}
+	if s.maxSettlement > 0 && provider.SettlementDuration > s.maxSettlement {
+		return false
+	}
+	if s.maxRate != nil {
+		rate, ok := s.rate(provider)
+		if !ok || rate.GT(s.maxRate.Amount) {
+			return false
+		}
+	}
+	return true
+}
+
+func (k KVStore) SearchProviders(c context.Context, req *types.QuerySearchProvidersRequest) (*types.QuerySearchProvidersResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	ctx := sdk.UnwrapSDKContext(c)
+
+	search, err := newProviderSearch(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// providers are keyed by pubkey then service, a search scans every
+	// provider and filters them out as it goes
+	store := ctx.KVStore(k.storeKey)
+	providerStore := prefix.NewStore(store, types.KeyPrefix(prefixProvider.String()))
+
+	if search.sort == "" {
+		var providers []types.Provider
+		pageRes, err := query.FilteredPaginate(providerStore, req.Pagination, func(key, value []byte, accumulate bool) (bool, error) {
+			var provider types.Provider
+			if err := k.cdc.Unmarshal(value, &provider); err != nil {
+				return false, err
+			}
+			if !search.match(provider) {
+				return false, nil
+			}
+			if accumulate {
+				providers = append(providers, provider)
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return &types.QuerySearchProvidersResponse{Providers: providers, Pagination: pageRes}, nil
+	}
+
+	// sorted results are paginated by offset, every match is sorted first
+	if req.Pagination != nil && len(req.Pagination.Key) > 0 {
+		return nil, status.Error(codes.InvalidArgument, "sorted results are paginated by offset, not key")
+	}
+	iter := providerStore.Iterator(nil, nil)
+	defer iter.Close()
+	var providers []types.Provider
+	for ; iter.Valid(); iter.Next() {
+		var provider types.Provider
+		if err := k.cdc.Unmarshal(iter.Value(), &provider); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if !search.match(provider) {
+			continue
+		}
+		// the rate sort skips the providers without a rate in the denom
+		if _, ok := search.rate(provider); search.sort == "rate" && !ok {
+			continue
+		}
+		providers = append(providers, provider)
+	}
+
+	sort.SliceStable(providers, func(i, j int) bool {
+		if search.sort == "bond" {
+			return providers[i].Bond.GT(providers[j].Bond)
+		}
+		rateI, _ := search.rate(providers[i])
+		rateJ, _ := search.rate(providers[j])
+		return rateI.LT(rateJ)
+	})
+
+	pageRes := &query.PageResponse{}
+	offset, limit := uint64(0), uint64(query.DefaultLimit)
+	if req.Pagination != nil {
+		offset = req.Pagination.Offset
+		if req.Pagination.Limit > 0 {
+			limit = req.Pagination.Limit
+		}
+		if req.Pagination.CountTotal {
+			pageRes.Total = uint64(len(providers))
+		}
+	}
+	if offset > uint64(len(providers)) {
+		offset = uint64(len(providers))
+	}
+	end := offset + limit
+	if end > uint64(len(providers)) {
+		end = uint64(len(providers))
+	}
+
+	return &types.QuerySearchProvidersResponse{Providers: providers[offset:end], Pagination: pageRes}, nil
+}
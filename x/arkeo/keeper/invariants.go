@@ -0,0 +1,83 @@
+package keeper
+
+import (
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	stakingkeeper "github.com/cosmos/cosmos-sdk/x/staking/keeper"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// RegisterInvariants registers the invariants of the module with the crisis
+// module, they are checked by the end blocker as well
+func RegisterInvariants(ir sdk.InvariantRegistry, k Keeper, sk stakingkeeper.Keeper) {
+	ir.RegisterRoute(types.ModuleName, "bond-module", BondModuleInvariant(k, sk))
+	ir.RegisterRoute(types.ModuleName, "contract-module", ContractModuleInvariant(k, sk))
+}
+
+// BondModuleInvariant checks the providers module account holds enough to
+// back the bonds of the providers
+func BondModuleInvariant(k Keeper, sk stakingkeeper.Keeper) sdk.Invariant {
+	return func(ctx sdk.Context) (string, bool) {
+		mgr := NewManager(k, sk)
+		err := mgr.invariantBondModule(ctx)
+		msg := fmt.Sprintf("bonded: %s\nbond balance: %s\n", totalBonded(ctx, k), k.GetBalanceOfModule(ctx, types.ProviderName, configs.Denom))
+		if err != nil {
+			msg += err.Error()
+		}
+		return sdk.FormatInvariant(types.ModuleName, "bond-module", msg), err != nil
+	}
+}
+
+// ContractModuleInvariant checks the contracts module account holds enough to
+// back the deposits of the unsettled contracts
+func ContractModuleInvariant(k Keeper, sk stakingkeeper.Keeper) sdk.Invariant {
+	return func(ctx sdk.Context) (string, bool) {
+		mgr := NewManager(k, sk)
+		err := mgr.invariantContractModule(ctx)
+		msg := fmt.Sprintf("deposits: %s\ncontract balance: %s\n", totalContractDeposits(ctx, k), k.GetBalance(ctx, k.GetModuleAccAddress(types.ContractName)))
+		if err != nil {
+			msg += err.Error()
+		}
+		return sdk.FormatInvariant(types.ModuleName, "contract-module", msg), err != nil
+	}
+}
+
+// totalBonded returns the sum of the provider bonds
+func totalBonded(ctx cosmos.Context, k Keeper) cosmos.Int {
+	sum := cosmos.ZeroInt()
+	iter := k.GetProviderIterator(ctx)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		var provider types.Provider
+		if err := k.Cdc().Unmarshal(iter.Value(), &provider); err != nil {
+			ctx.Logger().Error("fail to unmarshal provider", "error", err)
+			continue
+		}
+		sum = sum.Add(provider.Bond)
+	}
+	return sum
+}
+
+// totalContractDeposits returns the sum of the deposits of the unsettled
+// contracts, less what they paid already, by denom
+func totalContractDeposits(ctx cosmos.Context, k Keeper) cosmos.Coins {
+	sums := cosmos.NewCoins()
+	iter := k.GetContractIterator(ctx)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		var contract types.Contract
+		if err := k.Cdc().Unmarshal(iter.Value(), &contract); err != nil {
+			ctx.Logger().Error("fail to unmarshal contract", "error", err)
+			continue
+		}
+		if contract.IsSettled(ctx.BlockHeight()) {
+			continue
+		}
+		sums = sums.Add(cosmos.NewCoin(contract.Rate.Denom, contract.Deposit.Sub(contract.Paid)))
+	}
+	return sums
+}
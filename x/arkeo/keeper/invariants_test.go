@@ -0,0 +1,87 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// invariantRegistry records the routes registered by the module
+type invariantRegistry map[string]sdk.Invariant
+
+func (r invariantRegistry) RegisterRoute(moduleName, route string, invar sdk.Invariant) {
+	r[moduleName+"/"+route] = invar
+}
+
+func TestInvariants(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+
+	registry := invariantRegistry{}
+	RegisterInvariants(registry, k, sk)
+	require.Len(t, registry, 2)
+	bondInvariant := registry["arkeo/bond-module"]
+	contractInvariant := registry["arkeo/contract-module"]
+	require.NotNil(t, bondInvariant)
+	require.NotNil(t, contractInvariant)
+
+	// a bonded provider, and an unsettled contract which paid part of its
+	// deposit
+	provider := types.NewProvider(types.GetRandomPubKey(), common.BTCService)
+	provider.Bond = cosmos.NewInt(500)
+	require.NoError(t, k.SetProvider(ctx, provider))
+	contract := types.NewContract(provider.PubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Rate = cosmos.NewInt64Coin(configs.Denom, 10)
+	contract.Deposit = cosmos.NewInt(500)
+	contract.Paid = cosmos.NewInt(200)
+	contract.Height = 5
+	contract.Duration = 100
+	contract.Id = 1
+	require.NoError(t, k.SetContract(ctx, contract))
+
+	require.NoError(t, k.MintToModule(ctx, types.ModuleName, getCoin(1300)))
+	require.NoError(t, k.SendFromModuleToModule(ctx, types.ModuleName, types.ProviderName, getCoins(500)))
+	require.NoError(t, k.SendFromModuleToModule(ctx, types.ModuleName, types.ContractName, getCoins(300)))
+	require.NoError(t, k.SendFromModuleToModule(ctx, types.ModuleName, types.ReserveName, getCoins(500)))
+
+	msg, broken := bondInvariant(ctx)
+	require.False(t, broken, msg)
+	msg, broken = contractInvariant(ctx)
+	require.False(t, broken, msg)
+
+	res, err := k.ModuleBalances(sdk.WrapSDKContext(ctx), &types.QueryModuleBalancesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt(500), res.Bonded)
+	require.Equal(t, cosmos.NewInt(500), res.BondBalance)
+	require.Equal(t, getCoins(300), res.Deposits)
+	require.Equal(t, getCoins(300), res.ContractBalance)
+	require.Equal(t, getCoins(500), res.Reserve)
+	_, err = k.ModuleBalances(sdk.WrapSDKContext(ctx), nil)
+	require.Error(t, err)
+
+	// a balance drained from the providers module breaks the bond invariant
+	acct := types.GetRandomBech32Addr()
+	require.NoError(t, k.SendFromModuleToAccount(ctx, types.ProviderName, acct, getCoins(1)))
+	msg, broken = bondInvariant(ctx)
+	require.True(t, broken)
+	require.Contains(t, msg, "bond module does not have enough token")
+	msg, broken = contractInvariant(ctx)
+	require.False(t, broken, msg)
+
+	// and one drained from the contracts module breaks the contract invariant
+	require.NoError(t, k.SendFromModuleToAccount(ctx, types.ContractName, acct, getCoins(1)))
+	msg, broken = contractInvariant(ctx)
+	require.True(t, broken)
+	require.Contains(t, msg, "contract module does not have enough token")
+
+	res, err = k.ModuleBalances(sdk.WrapSDKContext(ctx), &types.QueryModuleBalancesRequest{})
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt(499), res.BondBalance)
+	require.Equal(t, getCoins(299), res.ContractBalance)
+}
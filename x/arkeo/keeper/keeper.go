@@ -66,10 +66,22 @@ type Keeper interface {
 	FetchContract(c context.Context, req *types.QueryFetchContractRequest) (*types.QueryFetchContractResponse, error)
 	ContractAll(c context.Context, req *types.QueryAllContractRequest) (*types.QueryAllContractResponse, error)
 	ActiveContract(goCtx context.Context, req *types.QueryActiveContractRequest) (*types.QueryActiveContractResponse, error)
+	ProviderUptime(c context.Context, req *types.QueryProviderUptimeRequest) (*types.QueryProviderUptimeResponse, error)
+	ContractConfig(c context.Context, req *types.QueryContractConfigRequest) (*types.QueryContractConfigResponse, error)
+	ContractsByClient(c context.Context, req *types.QueryContractsByClientRequest) (*types.QueryContractsByClientResponse, error)
+	ContractsByProvider(c context.Context, req *types.QueryContractsByProviderRequest) (*types.QueryContractsByProviderResponse, error)
+	ContractCost(c context.Context, req *types.QueryContractCostRequest) (*types.QueryContractCostResponse, error)
+	ProviderRates(c context.Context, req *types.QueryProviderRatesRequest) (*types.QueryProviderRatesResponse, error)
+	ProviderBundles(c context.Context, req *types.QueryProviderBundlesRequest) (*types.QueryProviderBundlesResponse, error)
+	SearchProviders(c context.Context, req *types.QuerySearchProvidersRequest) (*types.QuerySearchProvidersResponse, error)
+	ModuleBalances(c context.Context, req *types.QueryModuleBalancesRequest) (*types.QueryModuleBalancesResponse, error)
+	ExpiringContracts(c context.Context, req *types.QueryExpiringContractsRequest) (*types.QueryExpiringContractsResponse, error)
 
 	// Keeper Interfaces
 	KeeperProvider
 	KeeperContract
+	KeeperBundle
+	KeeperContractConfig
 }
 
 type KeeperProvider interface {
@@ -78,6 +90,20 @@ type KeeperProvider interface {
 	SetProvider(_ cosmos.Context, _ types.Provider) error
 	ProviderExists(_ cosmos.Context, _ common.PubKey, _ common.Service) bool
 	RemoveProvider(_ cosmos.Context, _ common.PubKey, _ common.Service)
+	GetProviderUptimeRecord(_ cosmos.Context, _ common.PubKey, _ common.Service) (types.ProviderUptimeRecord, error)
+	SetProviderUptimeRecord(_ cosmos.Context, _ common.PubKey, _ common.Service, _ types.ProviderUptimeRecord)
+	RemoveProviderUptimeRecord(_ cosmos.Context, _ common.PubKey, _ common.Service)
+	GetProviderUptime(_ cosmos.Context, _ common.PubKey, _ common.Service) (float64, error)
+	HasProviderReport(ctx cosmos.Context, provider, reporter common.PubKey) bool
+	SetProviderReport(ctx cosmos.Context, provider, reporter common.PubKey)
+	RemoveProviderReports(ctx cosmos.Context, height int64)
+	GetDowntimeAttestation(ctx cosmos.Context, provider common.PubKey, service common.Service, contractId uint64) (types.DowntimeAttestation, error)
+	SetDowntimeAttestation(ctx cosmos.Context, attestation types.DowntimeAttestation) error
+	GetDowntimeAttestationIterator(ctx cosmos.Context, provider common.PubKey, service common.Service) cosmos.Iterator
+	GetDowntimeAttestationHeightIterator(ctx cosmos.Context, height int64) cosmos.Iterator
+	CountDowntimeAttestations(ctx cosmos.Context, provider common.PubKey, service common.Service, since int64) (uint64, error)
+	RemoveDowntimeAttestations(ctx cosmos.Context, provider common.PubKey, service common.Service)
+	PruneDowntimeAttestations(ctx cosmos.Context, height int64)
 }
 
 type KeeperContract interface {
@@ -91,13 +117,46 @@ type KeeperContract interface {
 	GetContractExpirationSet(_ cosmos.Context, _ int64) (types.ContractExpirationSet, error)
 	SetContractExpirationSet(_ cosmos.Context, _ types.ContractExpirationSet) error
 	RemoveContractExpirationSet(_ cosmos.Context, _ int64)
+	GetSettlingContracts(ctx cosmos.Context, from, to int64) ([]types.ExpiringContract, error)
 	RemoveFromUserContractSet(ctx cosmos.Context, user common.PubKey, contractId uint64) error
 	GetNextContractId(_ cosmos.Context) uint64
 	SetNextContractId(ctx cosmos.Context, contractId uint64)
 	GetAndIncrementNextContractId(ctx cosmos.Context) uint64
 	SetUserContractSet(ctx cosmos.Context, contractSet types.UserContractSet) error
 	GetUserContractSet(ctx cosmos.Context, pubkey common.PubKey) (types.UserContractSet, error)
+	SetClientContract(ctx cosmos.Context, client common.PubKey, contractId uint64) error
+	RemoveClientContract(ctx cosmos.Context, client common.PubKey, contractId uint64)
+	GetClientContractIterator(ctx cosmos.Context, client common.PubKey) cosmos.Iterator
+	SetProviderContract(ctx cosmos.Context, provider common.PubKey, service common.Service, contractId uint64) error
+	RemoveProviderContract(ctx cosmos.Context, provider common.PubKey, service common.Service, contractId uint64)
+	GetProviderContractIterator(ctx cosmos.Context, provider common.PubKey, service common.Service) cosmos.Iterator
 	GetActiveContractForUser(ctx cosmos.Context, user, provider common.PubKey, service common.Service) (types.Contract, error)
+	GetClaimCount(ctx cosmos.Context, provider common.PubKey) int64
+	SetClaimCount(ctx cosmos.Context, provider common.PubKey, count int64)
+	OpenContractServices(ctx cosmos.Context, msg *types.MsgOpenContract) (common.Services, error)
+	OpenContractCost(ctx cosmos.Context, msg *types.MsgOpenContract) (cosmos.Coin, cosmos.Coin, error)
+	OpenContractFee(ctx cosmos.Context) cosmos.Coin
+	ContractRate(ctx cosmos.Context, contract types.Contract) (cosmos.Int, error)
+	AddReserveContribution(ctx cosmos.Context, contractId uint64, amount cosmos.Coin)
+	GetReserveContributions(ctx cosmos.Context, denom string) ([]types.ReserveContribution, error)
+	RemoveReserveContributions(ctx cosmos.Context, denom string)
+}
+
+type KeeperBundle interface {
+	GetBundleIterator(_ cosmos.Context) cosmos.Iterator
+	GetBundle(_ cosmos.Context, _ uint64) (types.Bundle, error)
+	SetBundle(_ cosmos.Context, _ types.Bundle) error
+	BundleExists(_ cosmos.Context, _ uint64) bool
+	GetNextBundleId(_ cosmos.Context) uint64
+	SetNextBundleId(_ cosmos.Context, _ uint64)
+	GetAndIncrementNextBundleId(_ cosmos.Context) uint64
+}
+
+type KeeperContractConfig interface {
+	GetContractConfigIterator(_ cosmos.Context) cosmos.Iterator
+	GetContractConfig(_ cosmos.Context, _ uint64) (types.ContractConfig, error)
+	SetContractConfig(_ cosmos.Context, _ types.ContractConfig) error
+	ContractConfigExists(_ cosmos.Context, _ uint64) bool
 }
 
 const (
@@ -107,12 +166,24 @@ const (
 	prefixContractNextId        dbPrefix = "cni/"
 	prefixContractExpirationSet dbPrefix = "ces/"
 	prefixUserContractSet       dbPrefix = "ucs/"
+	prefixClaimCount            dbPrefix = "ccb/"
+	prefixProviderUptime        dbPrefix = "pu/"
+	prefixBundle                dbPrefix = "b/"
+	prefixBundleNextId          dbPrefix = "bni/"
+	prefixProviderReport        dbPrefix = "pr/"
+	prefixContractConfig        dbPrefix = "cc/"
+	prefixClientContract        dbPrefix = "clc/"
+	prefixProviderContract      dbPrefix = "prc/"
+	prefixReserveContribution   dbPrefix = "rc/"
+	prefixDowntimeAttestation   dbPrefix = "da/"
+	prefixDowntimeHeight        dbPrefix = "dah/"
 )
 
 type KVStore struct {
 	cdc           codec.BinaryCodec
 	storeKey      storetypes.StoreKey
 	memKey        storetypes.StoreKey
+	tkey          storetypes.StoreKey
 	paramstore    paramtypes.Subspace
 	coinKeeper    bankkeeper.Keeper
 	accountKeeper authkeeper.AccountKeeper
@@ -122,7 +193,8 @@ type KVStore struct {
 func NewKVStore(
 	cdc codec.BinaryCodec,
 	storeKey,
-	memKey storetypes.StoreKey,
+	memKey,
+	tkey storetypes.StoreKey,
 	ps paramtypes.Subspace,
 	coinKeeper bankkeeper.Keeper,
 	accountKeeper authkeeper.AccountKeeper,
@@ -137,6 +209,7 @@ func NewKVStore(
 		cdc:           cdc,
 		storeKey:      storeKey,
 		memKey:        memKey,
+		tkey:          tkey,
 		paramstore:    ps,
 		coinKeeper:    coinKeeper,
 		accountKeeper: accountKeeper,
@@ -160,7 +233,14 @@ func (k KVStore) Cdc() codec.BinaryCodec {
 
 // GetParams get all parameters as types.Params
 func (k KVStore) GetParams(ctx sdk.Context) types.Params {
-	return types.NewParams()
+	params := types.NewParams()
+	params.DepositDenoms = k.DepositDenoms(ctx)
+	params.MinContractDeposit = k.MinContractDeposit(ctx)
+	params.DowntimeAttestationThreshold = k.DowntimeAttestationThreshold(ctx)
+	params.DowntimeAttestationWindow = k.DowntimeAttestationWindow(ctx)
+	params.DowntimeAttestationInterval = k.DowntimeAttestationInterval(ctx)
+	params.DowntimeSlashBasisPoints = k.DowntimeSlashBasisPoints(ctx)
+	return params
 }
 
 // SetParams set the params
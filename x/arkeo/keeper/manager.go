@@ -42,6 +42,8 @@ func (mgr *Manager) BeginBlock(ctx cosmos.Context, req abci.RequestBeginBlock) e
 	if err := mgr.ValidatorPayout(ctx, req.LastCommitInfo.GetVotes()); err != nil {
 		ctx.Logger().Error("unable to settle contracts", "error", err)
 	}
+
+	mgr.ProviderUptimeBeginBlock(ctx)
 	return nil
 }
 
@@ -49,6 +51,13 @@ func (mgr Manager) EndBlock(ctx cosmos.Context) error {
 	if err := mgr.ContractEndBlock(ctx); err != nil {
 		ctx.Logger().Error("unable to settle contracts", "error", err)
 	}
+	if err := mgr.ContractExpiringSoonEndBlock(ctx); err != nil {
+		ctx.Logger().Error("unable to announce expiring contracts", "error", err)
+	}
+	if err := mgr.DowntimeEndBlock(ctx); err != nil {
+		ctx.Logger().Error("unable to slash attested providers", "error", err)
+	}
+	mgr.keeper.RemoveProviderReports(ctx, ctx.BlockHeight())
 
 	// invariant checks
 	if err := mgr.invariantBondModule(ctx); err != nil {
@@ -70,19 +79,7 @@ func (mgr Manager) Configs(ctx cosmos.Context) configs.ConfigValues {
 // test that the bond module has enough bond in it
 func (mgr Manager) invariantBondModule(ctx cosmos.Context) error {
 	balance := mgr.keeper.GetBalanceOfModule(ctx, types.ProviderName, configs.Denom)
-
-	sum := cosmos.ZeroInt()
-	iter := mgr.keeper.GetProviderIterator(ctx)
-	defer iter.Close()
-	for ; iter.Valid(); iter.Next() {
-		var provider types.Provider
-		if err := mgr.keeper.Cdc().Unmarshal(iter.Value(), &provider); err != nil {
-			ctx.Logger().Error("fail to unmarshal provider", "error", err)
-			continue
-		}
-		sum = sum.Add(provider.Bond)
-	}
-
+	sum := totalBonded(ctx, mgr.keeper)
 	if sum.GT(balance) {
 		// TODO: instead of returning an error and causing a panic, pause the bond provider handler and allow the chain to continue to function
 		return errors.Wrapf(types.ErrInvariantBondModule, "bond module does not have enough token in it to back the bond records (%s/%s)", sum.String(), balance.String())
@@ -93,21 +90,7 @@ func (mgr Manager) invariantBondModule(ctx cosmos.Context) error {
 
 // test that the contract module has enough bond in it
 func (mgr Manager) invariantContractModule(ctx cosmos.Context) error {
-	sums := cosmos.NewCoins()
-	iter := mgr.keeper.GetContractIterator(ctx)
-	defer iter.Close()
-	for ; iter.Valid(); iter.Next() {
-		var contract types.Contract
-		if err := mgr.keeper.Cdc().Unmarshal(iter.Value(), &contract); err != nil {
-			ctx.Logger().Error("fail to unmarshal contract", "error", err)
-			continue
-		}
-		if contract.IsSettled(ctx.BlockHeight()) {
-			continue
-		}
-		sums = sums.Add(cosmos.NewCoin(contract.Rate.Denom, contract.Deposit.Sub(contract.Paid)))
-	}
-
+	sums := totalContractDeposits(ctx, mgr.keeper)
 	for _, sum := range sums {
 		if sum.Amount.IsZero() {
 			continue
@@ -133,6 +116,9 @@ func (mgr Manager) invariantMaxSupply(ctx cosmos.Context) error {
 	return nil
 }
 
+// ContractEndBlock settles the contracts whose settlement period ends at the
+// block. The contracts expiring at the block are announced, a pay-as-you-go
+// contract with a settlement period is settled later on.
 func (mgr Manager) ContractEndBlock(ctx cosmos.Context) error {
 	set, err := mgr.keeper.GetContractExpirationSet(ctx, ctx.BlockHeight())
 	if err != nil {
@@ -153,6 +139,17 @@ func (mgr Manager) ContractEndBlock(ctx cosmos.Context) error {
 			continue
 		}
 
+		// contracts settled beforehand, like closed subscriptions, don't expire
+		if contract.Expiration() == ctx.BlockHeight() && contract.SettlementHeight == 0 {
+			if err := mgr.EmitContractExpiredEvent(ctx, &contract); err != nil {
+				ctx.Logger().Error("unable to emit contract expired event", "id", contractId, "error", err)
+			}
+			// settled once its settlement period is over
+			if contract.SettlementPeriodEnd() > ctx.BlockHeight() {
+				continue
+			}
+		}
+
 		_, err = mgr.SettleContract(ctx, contract, 0, true)
 		if err != nil {
 			ctx.Logger().Error("unable to settle contract", "id", contractId, "error", err)
@@ -163,6 +160,135 @@ func (mgr Manager) ContractEndBlock(ctx cosmos.Context) error {
 	return nil
 }
 
+// ContractExpiringSoonEndBlock announces the pay-as-you-go contracts settled
+// in the configured number of blocks, for their provider to claim the income
+// not claimed yet. A contract settled in fewer blocks than configured when it
+// is opened or closed isn't announced.
+func (mgr Manager) ContractExpiringSoonEndBlock(ctx cosmos.Context) error {
+	blocks := mgr.FetchConfig(ctx, configs.ContractExpiringSoonBlocks)
+	if blocks <= 0 {
+		return nil
+	}
+	settlementHeight := ctx.BlockHeight() + blocks
+	contracts, err := mgr.keeper.GetSettlingContracts(ctx, settlementHeight-1, settlementHeight)
+	if err != nil {
+		return err
+	}
+	for _, expiring := range contracts {
+		if !expiring.Contract.IsPayAsYouGo() {
+			continue
+		}
+		if err := mgr.EmitContractExpiringSoonEvent(ctx, &expiring.Contract, expiring.SettlementHeight); err != nil {
+			ctx.Logger().Error("unable to emit contract expiring soon event", "id", expiring.Contract.Id, "error", err)
+		}
+	}
+	return nil
+}
+
+// DowntimeEndBlock slashes the providers whose downtime was attested in the
+// block, once more distinct clients than the threshold attested it within the
+// window. The attestations leaving the window are pruned.
+func (mgr Manager) DowntimeEndBlock(ctx cosmos.Context) error {
+	params := mgr.keeper.GetParams(ctx)
+	window := params.DowntimeAttestationWindow
+	defer mgr.keeper.PruneDowntimeAttestations(ctx, ctx.BlockHeight()-window)
+	if params.DowntimeAttestationThreshold == 0 {
+		return nil
+	}
+
+	var attested []types.Provider
+	seen := make(map[string]bool)
+	iter := mgr.keeper.GetDowntimeAttestationHeightIterator(ctx, ctx.BlockHeight())
+	for ; iter.Valid(); iter.Next() {
+		var attestation types.DowntimeAttestation
+		if err := mgr.keeper.Cdc().Unmarshal(iter.Value(), &attestation); err != nil {
+			ctx.Logger().Error("fail to unmarshal downtime attestation", "error", err)
+			continue
+		}
+		provider := types.NewProvider(attestation.Provider, attestation.Service)
+		if seen[provider.Key()] {
+			continue
+		}
+		seen[provider.Key()] = true
+		attested = append(attested, provider)
+	}
+	iter.Close()
+
+	for _, provider := range attested {
+		attestations, err := mgr.keeper.CountDowntimeAttestations(ctx, provider.PubKey, provider.Service, ctx.BlockHeight()-window)
+		if err != nil {
+			return err
+		}
+		if attestations <= params.DowntimeAttestationThreshold {
+			continue
+		}
+		if err := mgr.SlashProvider(ctx, provider.PubKey, provider.Service, params.DowntimeSlashBasisPoints, attestations); err != nil {
+			ctx.Logger().Error("unable to slash provider", "provider", provider.PubKey, "service", provider.Service, "error", err)
+		}
+	}
+	return nil
+}
+
+// SlashProvider moves a fraction, in basis points, of the bond of the provider
+// into the reserve, and clears the attestations of its downtime it is slashed
+// for
+func (mgr Manager) SlashProvider(ctx cosmos.Context, pubkey common.PubKey, service common.Service, basisPoints int64, attestations uint64) error {
+	provider, err := mgr.keeper.GetProvider(ctx, pubkey, service)
+	if err != nil {
+		return err
+	}
+	slashed := common.GetSafeShare(cosmos.NewInt(basisPoints), cosmos.NewInt(configs.MaxBasisPoints), provider.Bond)
+
+	cacheCtx, commit := ctx.CacheContext()
+	if !slashed.IsZero() {
+		if err := mgr.keeper.SendFromModuleToModule(cacheCtx, types.ProviderName, types.ReserveName, cosmos.NewCoins(cosmos.NewCoin(configs.Denom, slashed))); err != nil {
+			return err
+		}
+		provider.Bond = provider.Bond.Sub(slashed)
+		if err := mgr.keeper.SetProvider(cacheCtx, provider); err != nil {
+			return err
+		}
+	}
+	mgr.keeper.RemoveDowntimeAttestations(cacheCtx, pubkey, service)
+	commit()
+
+	return mgr.EmitProviderSlashedEvent(ctx, &provider, slashed, attestations)
+}
+
+// ProviderUptimeBeginBlock counts the block for every ONLINE provider. The
+// block only counts as online when the provider is able to serve contracts
+// (has the minimum bond). OFFLINE providers aren't tracked, as going offline
+// resets the uptime record.
+func (mgr Manager) ProviderUptimeBeginBlock(ctx cosmos.Context) {
+	var providers []types.Provider
+	iter := mgr.keeper.GetProviderIterator(ctx)
+	for ; iter.Valid(); iter.Next() {
+		var provider types.Provider
+		if err := mgr.keeper.Cdc().Unmarshal(iter.Value(), &provider); err != nil {
+			ctx.Logger().Error("fail to unmarshal provider", "error", err)
+			continue
+		}
+		if provider.Status == types.ProviderStatus_ONLINE {
+			providers = append(providers, provider)
+		}
+	}
+	iter.Close()
+
+	minBond := cosmos.NewInt(mgr.FetchConfig(ctx, configs.MinProviderBond))
+	for _, provider := range providers {
+		record, err := mgr.keeper.GetProviderUptimeRecord(ctx, provider.PubKey, provider.Service)
+		if err != nil {
+			ctx.Logger().Error("fail to get provider uptime", "error", err)
+			continue
+		}
+		record.TotalBlocks++
+		if provider.Bond.GTE(minBond) {
+			record.OnlineBlocks++
+		}
+		mgr.keeper.SetProviderUptimeRecord(ctx, provider.PubKey, provider.Service, record)
+	}
+}
+
 // This function pays out rewards to validators.
 // TODO: the method of accomplishing this is admittedly quite inefficient. The
 // better approach would be to track live allocation via assigning "units" to
@@ -207,6 +333,11 @@ func (mgr Manager) ValidatorPayout(ctx cosmos.Context, votes []abci.VoteInfo) er
 			return nil
 		}
 
+		contributions, err := mgr.keeper.GetReserveContributions(ctx, bal.Denom)
+		if err != nil {
+			return err
+		}
+
 		for _, vote := range votes {
 			if !vote.SignedLastBlock {
 				ctx.Logger().Info("validator rewards skipped due to lack of signature", "validator", string(vote.Validator.Address))
@@ -264,12 +395,50 @@ func (mgr Manager) ValidatorPayout(ctx cosmos.Context, votes []abci.VoteInfo) er
 			if err := mgr.EmitValidatorPayoutEvent(ctx, acc, validatorReward); err != nil {
 				ctx.Logger().Error("unable to emit validator payout event", "validator", acc.String(), "error", err)
 			}
+			for _, contribution := range splitPayout(contributions, validatorReward) {
+				if err := mgr.EmitPayoutContributionEvent(ctx, acc, contribution.ContractId, contribution.Amount.Amount); err != nil {
+					ctx.Logger().Error("unable to emit payout contribution event", "validator", acc.String(), "contract", contribution.ContractId, "error", err)
+				}
+			}
 		}
+		mgr.keeper.RemoveReserveContributions(ctx, bal.Denom)
 	}
 
 	return nil
 }
 
+// splitPayout attributes the reward to the contracts that paid into the
+// reserve since the previous payout, pro rata to what each of them paid. The
+// rounding remainder goes to the last contract, so the amounts sum to the
+// reward.
+func splitPayout(contributions []types.ReserveContribution, reward cosmos.Int) []types.ReserveContribution {
+	total := cosmos.ZeroInt()
+	for _, contribution := range contributions {
+		total = total.Add(contribution.Amount.Amount)
+	}
+	if total.IsZero() || reward.IsZero() {
+		return nil
+	}
+
+	split := make([]types.ReserveContribution, 0, len(contributions))
+	remaining := reward
+	for i, contribution := range contributions {
+		amount := common.GetSafeShare(contribution.Amount.Amount, total, reward)
+		if i == len(contributions)-1 {
+			amount = remaining
+		}
+		remaining = remaining.Sub(amount)
+		if amount.IsZero() {
+			continue
+		}
+		split = append(split, types.ReserveContribution{
+			ContractId: contribution.ContractId,
+			Amount:     cosmos.NewCoin(contribution.Amount.Denom, amount),
+		})
+	}
+	return split
+}
+
 func (mgr Manager) calcBlockReward(totalReserve, emissionCurve, blocksPerYear int64) cosmos.Int {
 	// Block Rewards will take the latest reserve, divide it by the emission
 	// curve factor, then divide by blocks per year
@@ -299,19 +468,29 @@ func (mgr Manager) SettleContract(ctx cosmos.Context, contract types.Contract, n
 	if err != nil {
 		return contract, err
 	}
+	var payouts []types.ProviderPayout
 	if !debt.IsZero() {
-		provider, err := contract.Provider.GetMyAddress()
+		payouts, err = mgr.providerPayouts(ctx, contract, debt)
 		if err != nil {
 			return contract, err
 		}
-		if err := mgr.keeper.SendFromModuleToAccount(ctx, types.ContractName, provider, cosmos.NewCoins(cosmos.NewCoin(contract.Rate.Denom, debt))); err != nil {
-			return contract, err
+		for _, payout := range payouts {
+			if payout.Amount.IsZero() {
+				continue
+			}
+			if err := mgr.keeper.SendFromModuleToAccount(ctx, types.ContractName, payout.Address, cosmos.NewCoins(cosmos.NewCoin(contract.Rate.Denom, payout.Amount))); err != nil {
+				return contract, err
+			}
 		}
 		if err := mgr.keeper.SendFromModuleToModule(ctx, types.ContractName, types.ReserveName, cosmos.NewCoins(cosmos.NewCoin(contract.Rate.Denom, valIncome))); err != nil {
 			return contract, err
 		}
+		mgr.keeper.AddReserveContribution(ctx, contract.Id, cosmos.NewCoin(contract.Rate.Denom, valIncome))
 	}
 
+	// a pay-as-you-go contract is spent once its debt reaches the deposit,
+	// checked before a final settlement refunds the remainder
+	spent := contract.IsPayAsYouGo() && contract.Paid.LT(contract.Deposit) && contract.Paid.Add(totalDebt).GTE(contract.Deposit)
 	contract.Paid = contract.Paid.Add(totalDebt)
 	if isFinal {
 		remainder := contract.Deposit.Sub(contract.Paid)
@@ -335,6 +514,10 @@ func (mgr Manager) SettleContract(ctx cosmos.Context, contract types.Contract, n
 		if err != nil {
 			return contract, err
 		}
+		mgr.keeper.RemoveClientContract(ctx, contract.Client, contract.Id)
+		for _, service := range contract.ServiceSet() {
+			mgr.keeper.RemoveProviderContract(ctx, contract.Provider, service, contract.Id)
+		}
 	}
 
 	err = mgr.keeper.SetContract(ctx, contract)
@@ -342,36 +525,75 @@ func (mgr Manager) SettleContract(ctx cosmos.Context, contract types.Contract, n
 		return contract, err
 	}
 
-	if err = mgr.EmitContractSettlementEvent(ctx, totalDebt, valIncome, &contract); err != nil {
+	if err = mgr.EmitContractSettlementEvent(ctx, totalDebt, valIncome, payouts, &contract); err != nil {
 		return contract, err
 	}
 
+	if spent {
+		if err = mgr.EmitContractSpentEvent(ctx, &contract); err != nil {
+			return contract, err
+		}
+	}
+
 	return contract, nil
 }
 
-func (mgr Manager) contractDebt(ctx cosmos.Context, contract types.Contract) (cosmos.Int, error) {
-	var debt cosmos.Int
-	switch contract.Type {
-	case types.ContractType_SUBSCRIPTION:
-		height := ctx.BlockHeight()
-		if height > contract.SettlementPeriodEnd() {
-			height = contract.SettlementPeriodEnd()
-		}
-		debt = contract.Rate.Amount.MulRaw(height - contract.Height).Sub(contract.Paid)
-	case types.ContractType_PAY_AS_YOU_GO:
-		debt = contract.Rate.Amount.MulRaw(contract.Nonce).Sub(contract.Paid)
-	default:
-		return cosmos.ZeroInt(), errors.Wrapf(types.ErrInvalidContractType, "%s", contract.Type.String())
+// providerPayouts splits the provider's income across the payout splits
+// registered on the provider record. The income of a bundle contract is shared
+// evenly by its services, the remainder going to the first one, and each share
+// is split by the record of its service. Providers without splits are paid in
+// full to the address of their pubkey.
+func (mgr Manager) providerPayouts(ctx cosmos.Context, contract types.Contract, income cosmos.Int) ([]types.ProviderPayout, error) {
+	services := contract.ServiceSet()
+	share := income.QuoRaw(int64(len(services)))
+	remainder := income.Sub(share.MulRaw(int64(len(services))))
+
+	var payouts []types.ProviderPayout
+	for i, service := range services {
+		amount := share
+		if i == 0 {
+			amount = amount.Add(remainder)
+		}
+		provider, err := mgr.keeper.GetProvider(ctx, contract.Provider, service)
+		if err != nil {
+			return nil, err
+		}
+		if len(provider.PayoutSplits) > 0 {
+			payouts = mergePayouts(payouts, types.SplitPayout(amount, provider.PayoutSplits))
+			continue
+		}
+		addr, err := contract.Provider.GetMyAddress()
+		if err != nil {
+			return nil, err
+		}
+		payouts = mergePayouts(payouts, []types.ProviderPayout{{Address: addr, Amount: amount}})
 	}
+	return payouts, nil
+}
 
-	if debt.IsNegative() {
-		return cosmos.ZeroInt(), nil
+// mergePayouts adds the payouts to the list, a single payout per address
+func mergePayouts(payouts, more []types.ProviderPayout) []types.ProviderPayout {
+	for _, payout := range more {
+		merged := false
+		for i := range payouts {
+			if payouts[i].Address.Equals(payout.Address) {
+				payouts[i].Amount = payouts[i].Amount.Add(payout.Amount)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			payouts = append(payouts, payout)
+		}
 	}
+	return payouts
+}
 
-	// sanity check, ensure provider cannot take more than deposited into the contract
-	if contract.Paid.Add(debt).GT(contract.Deposit) {
-		return contract.Deposit.Sub(contract.Paid), nil
+func (mgr Manager) contractDebt(ctx cosmos.Context, contract types.Contract) (cosmos.Int, error) {
+	switch contract.Type {
+	case types.ContractType_SUBSCRIPTION, types.ContractType_PAY_AS_YOU_GO:
+		return contract.Debt(ctx.BlockHeight()), nil
+	default:
+		return cosmos.ZeroInt(), errors.Wrapf(types.ErrInvalidContractType, "%s", contract.Type.String())
 	}
-
-	return debt, nil
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/cosmos/cosmos-sdk/simapp"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 	abci "github.com/tendermint/tendermint/abci/types"
 )
@@ -84,8 +85,13 @@ func TestValidatorPayout(t *testing.T) {
 	require.NoError(t, k.MintToModule(ctx, types.ModuleName, coins[0]))
 	require.NoError(t, k.SendFromModuleToModule(ctx, types.ModuleName, types.ReserveName, coins))
 
+	// contracts that funded the reserve, in the native denom only
+	k.AddReserveContribution(ctx, 1, getCoin(1000))
+	k.AddReserveContribution(ctx, 2, getCoin(3000))
+	k.AddReserveContribution(ctx, 2, getCoin(3000))
+
 	mgr := NewManager(k, sk)
-	ctx = ctx.WithBlockHeight(mgr.FetchConfig(ctx, configs.ValidatorPayoutCycle))
+	ctx = ctx.WithBlockHeight(mgr.FetchConfig(ctx, configs.ValidatorPayoutCycle)).WithEventManager(sdk.NewEventManager())
 
 	votes := make([]abci.VoteInfo, len(vals))
 	for i, val := range vals {
@@ -104,6 +110,43 @@ func TestValidatorPayout(t *testing.T) {
 	require.NoError(t, mgr.ValidatorPayout(ctx, votes))
 	require.Equal(t, k.GetBalanceOfModule(ctx, types.ReserveName, configs.Denom).Int64(), 5000000000000-blockReward)
 
+	// the contributions following each payout event sum to its reward
+	var payouts []types.EventValidatorPayout
+	contributed := make(map[int]cosmos.Int)
+	contracts := make(map[uint64]bool)
+	for _, e := range ctx.EventManager().Events() {
+		switch e.Type {
+		case types.EventTypeValidatorPayout:
+			msg, err := sdk.ParseTypedEvent(abci.Event(e))
+			require.NoError(t, err)
+			payouts = append(payouts, *msg.(*types.EventValidatorPayout))
+			contributed[len(payouts)-1] = cosmos.ZeroInt()
+		case types.EventTypePayoutContribution:
+			msg, err := sdk.ParseTypedEvent(abci.Event(e))
+			require.NoError(t, err)
+			evt := *msg.(*types.EventPayoutContribution)
+			require.NotEmpty(t, payouts)
+			require.Equal(t, payouts[len(payouts)-1].Validator, evt.Validator)
+			contributed[len(payouts)-1] = contributed[len(payouts)-1].Add(evt.Amount)
+			contracts[evt.ContractId] = true
+		}
+	}
+	// a payout event per validator and denom
+	require.Len(t, payouts, 6)
+	require.Equal(t, map[uint64]bool{1: true, 2: true}, contracts)
+	withContributions := 0
+	for i, payout := range payouts {
+		if contributed[i].IsZero() {
+			continue
+		}
+		withContributions++
+		require.True(t, payout.Reward.Equal(contributed[i]), "payout %d: reward %s, contributions %s", i, payout.Reward, contributed[i])
+	}
+	require.Equal(t, 3, withContributions)
+	contributions, err := k.GetReserveContributions(ctx, configs.Denom)
+	require.NoError(t, err)
+	require.Empty(t, contributions)
+
 	// check validator balances
 	totalBal := cosmos.ZeroInt()
 	bal := k.GetBalance(ctx, acc1)
@@ -231,6 +274,11 @@ func TestContractEndBlock(t *testing.T) {
 	contractIdExpiring := contractSet.ContractSet.ContractIds[0]
 	require.Len(t, contractSet.ContractSet.ContractIds, 2)
 
+	// both contracts of user 2 are indexed under the client
+	res, err := k.ContractsByClient(sdk.WrapSDKContext(ctx), &types.QueryContractsByClientRequest{Client: user2PubKey.String()})
+	require.NoError(t, err)
+	require.Len(t, res.Contracts, 2)
+
 	// advance 100 blocks and call end block
 	ctx = ctx.WithBlockHeight(110)
 	err = mgr.ContractEndBlock(ctx)
@@ -244,6 +292,10 @@ func TestContractEndBlock(t *testing.T) {
 
 	// confirm the contract id left is not the one that expired.
 	require.NotEqual(t, contractIdExpiring, contractSet.ContractSet.ContractIds[0])
+	res, err = k.ContractsByClient(sdk.WrapSDKContext(ctx), &types.QueryContractsByClientRequest{Client: user2PubKey.String()})
+	require.NoError(t, err)
+	require.Len(t, res.Contracts, 1)
+	require.Equal(t, contractSet.ContractSet.ContractIds[0], res.Contracts[0].Id)
 
 	// cofirm user1 has no active contract.
 	activeContract, err = k.GetActiveContractForUser(ctx, user1PubKey, providerPubKey, common.BTCService)
@@ -257,6 +309,9 @@ func TestContractEndBlock(t *testing.T) {
 	contractSet, err = k.GetUserContractSet(ctx, user2PubKey)
 	require.NoError(t, err)
 	require.Nil(t, contractSet.ContractSet)
+	res, err = k.ContractsByClient(sdk.WrapSDKContext(ctx), &types.QueryContractsByClientRequest{Client: user2PubKey.String()})
+	require.NoError(t, err)
+	require.Empty(t, res.Contracts)
 }
 
 func TestContractEndBlockWithSettlementDuration(t *testing.T) {
@@ -344,6 +399,398 @@ func TestContractEndBlockWithSettlementDuration(t *testing.T) {
 	require.Equal(t, activeContract.SettlementHeight, activeContract.SettlementPeriodEnd())
 }
 
+func TestContractExpiredEvent(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	for _, service := range []common.Service{common.BTCService, common.ETHService} {
+		provider := types.NewProvider(providerPubKey, service)
+		provider.Bond = cosmos.NewInt(20000000000)
+		require.NoError(t, k.SetProvider(ctx, provider))
+		require.NoError(t, s.ModProviderHandle(ctx, &types.MsgModProvider{
+			Provider:            providerPubKey,
+			Service:             service.String(),
+			MinContractDuration: 10,
+			MaxContractDuration: 500,
+			Status:              types.ProviderStatus_ONLINE,
+			PayAsYouGoRate:      rates,
+			SubscriptionRate:    rates,
+			SettlementDuration:  10,
+		}))
+	}
+
+	open := func(service common.Service, contractType types.ContractType, settlementDuration int64) types.Contract {
+		client := types.GetRandomPubKey()
+		clientAddress, err := client.GetMyAddress()
+		require.NoError(t, err)
+		require.NoError(t, k.MintAndSendToAccount(ctx, clientAddress, getCoin(common.Tokens(10))))
+		_, err = s.OpenContract(ctx, &types.MsgOpenContract{
+			Provider:           providerPubKey,
+			Service:            service.String(),
+			Creator:            clientAddress,
+			Client:             client,
+			ContractType:       contractType,
+			Duration:           100,
+			Rate:               rates[0],
+			Deposit:            cosmos.NewInt(1500),
+			SettlementDuration: settlementDuration,
+		})
+		require.NoError(t, err)
+		contract, err := k.GetActiveContractForUser(ctx, client, providerPubKey, service)
+		require.NoError(t, err)
+		return contract
+	}
+	payg := open(common.BTCService, types.ContractType_PAY_AS_YOU_GO, 10)
+	subscription := open(common.ETHService, types.ContractType_SUBSCRIPTION, 0)
+	closed := open(common.BTCService, types.ContractType_SUBSCRIPTION, 0)
+	clientAddress, err := closed.Client.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, s.CloseContractHandle(ctx.WithBlockHeight(50), &types.MsgCloseContract{Creator: clientAddress, ContractId: closed.Id}))
+
+	endBlock := func(height int64) map[uint64]types.EventContractExpired {
+		ctx := ctx.WithBlockHeight(height).WithEventManager(sdk.NewEventManager())
+		require.NoError(t, mgr.ContractEndBlock(ctx))
+		expired := make(map[uint64]types.EventContractExpired)
+		for _, e := range ctx.EventManager().Events() {
+			if e.Type != types.EventTypeContractExpired {
+				continue
+			}
+			msg, err := sdk.ParseTypedEvent(abci.Event(e))
+			require.NoError(t, err)
+			evt := *msg.(*types.EventContractExpired)
+			expired[evt.ContractId] = evt
+		}
+		return expired
+	}
+
+	require.Empty(t, endBlock(109))
+
+	// both contracts expire, the closed one was settled already
+	expired := endBlock(payg.Expiration())
+	require.Len(t, expired, 2)
+	evt := expired[payg.Id]
+	require.Equal(t, providerPubKey, evt.Provider)
+	require.Equal(t, common.BTCService.String(), evt.Service)
+	require.Equal(t, payg.Client, evt.Client)
+	require.Equal(t, int64(110), evt.Height)
+	require.True(t, evt.SettlementPending)
+	require.False(t, expired[subscription.Id].SettlementPending)
+
+	// the pay-as-you-go contract can still be claimed, the subscription is
+	// settled
+	payg, err = k.GetContract(ctx, payg.Id)
+	require.NoError(t, err)
+	require.Zero(t, payg.SettlementHeight)
+	subscription, err = k.GetContract(ctx, subscription.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(110), subscription.SettlementHeight)
+
+	// the settlement doesn't announce the expiry again
+	require.Empty(t, endBlock(payg.SettlementPeriodEnd()))
+	payg, err = k.GetContract(ctx, payg.Id)
+	require.NoError(t, err)
+	require.Equal(t, payg.SettlementPeriodEnd(), payg.SettlementHeight)
+}
+
+func TestContractsByProvider(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(20000000000)
+	provider.LastUpdate = ctx.BlockHeight()
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	err = s.ModProviderHandle(ctx, &types.MsgModProvider{
+		Provider:            provider.PubKey,
+		Service:             common.BTCService.String(),
+		MinContractDuration: 10,
+		MaxContractDuration: 500,
+		Status:              types.ProviderStatus_ONLINE,
+		PayAsYouGoRate:      rates,
+		SubscriptionRate:    rates,
+		SettlementDuration:  10,
+	})
+	require.NoError(t, err)
+
+	userPubKey := types.GetRandomPubKey()
+	userAddress, err := userPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, userAddress, getCoin(common.Tokens(10))))
+	_, err = s.OpenContract(ctx, &types.MsgOpenContract{
+		Provider:           providerPubKey,
+		Service:            common.BTCService.String(),
+		Creator:            userAddress,
+		Client:             userPubKey,
+		ContractType:       types.ContractType_PAY_AS_YOU_GO,
+		Duration:           100,
+		Rate:               rates[0],
+		Deposit:            cosmos.NewInt(1500),
+		SettlementDuration: 10,
+	})
+	require.NoError(t, err)
+
+	contracts := func(includeSettling bool) []types.ProviderContract {
+		res, err := k.ContractsByProvider(sdk.WrapSDKContext(ctx), &types.QueryContractsByProviderRequest{
+			Provider:        providerPubKey.String(),
+			Service:         common.BTCService.String(),
+			IncludeSettling: includeSettling,
+		})
+		require.NoError(t, err)
+		return res.Contracts
+	}
+
+	// active, with 20 requests served and not claimed
+	ctx = ctx.WithBlockHeight(50)
+	contract, err := k.GetActiveContractForUser(ctx, userPubKey, providerPubKey, common.BTCService)
+	require.NoError(t, err)
+	contract.Nonce = 20
+	require.NoError(t, k.SetContract(ctx, contract))
+	active := contracts(false)
+	require.Len(t, active, 1)
+	require.Equal(t, contract.Id, active[0].Contract.Id)
+	require.Equal(t, int64(60), active[0].BlocksUntilExpiry)
+	require.Equal(t, cosmos.NewInt(300), active[0].Unsettled)
+	require.Equal(t, int64(120), active[0].SettlementPeriodEnd)
+	require.False(t, active[0].Settling)
+	require.Equal(t, active, contracts(true))
+
+	// expired, settling until the end of the settlement period
+	ctx = ctx.WithBlockHeight(115)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	require.Empty(t, contracts(false))
+	settling := contracts(true)
+	require.Len(t, settling, 1)
+	require.Zero(t, settling[0].BlocksUntilExpiry)
+	require.Equal(t, cosmos.NewInt(300), settling[0].Unsettled)
+	require.True(t, settling[0].Settling)
+
+	// settled by the end blocker
+	ctx = ctx.WithBlockHeight(120)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	require.Empty(t, contracts(false))
+	require.Empty(t, contracts(true))
+
+	_, err = k.ContractsByProvider(sdk.WrapSDKContext(ctx), &types.QueryContractsByProviderRequest{Provider: "bogus", Service: common.BTCService.String()})
+	require.Error(t, err)
+	_, err = k.ContractsByProvider(sdk.WrapSDKContext(ctx), &types.QueryContractsByProviderRequest{Provider: providerPubKey.String(), Service: "bogus"})
+	require.Error(t, err)
+}
+
+func TestExpiringContracts(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	for _, service := range []common.Service{common.BTCService, common.ETHService} {
+		provider := types.NewProvider(providerPubKey, service)
+		provider.Bond = cosmos.NewInt(20000000000)
+		require.NoError(t, k.SetProvider(ctx, provider))
+		require.NoError(t, s.ModProviderHandle(ctx, &types.MsgModProvider{
+			Provider:            providerPubKey,
+			Service:             service.String(),
+			MinContractDuration: 10,
+			MaxContractDuration: 500,
+			Status:              types.ProviderStatus_ONLINE,
+			PayAsYouGoRate:      rates,
+			SubscriptionRate:    rates,
+			SettlementDuration:  10,
+		}))
+	}
+
+	open := func(service common.Service, contractType types.ContractType, settlementDuration int64) types.Contract {
+		client := types.GetRandomPubKey()
+		clientAddress, err := client.GetMyAddress()
+		require.NoError(t, err)
+		require.NoError(t, k.MintAndSendToAccount(ctx, clientAddress, getCoin(common.Tokens(10))))
+		_, err = s.OpenContract(ctx, &types.MsgOpenContract{
+			Provider:           providerPubKey,
+			Service:            service.String(),
+			Creator:            clientAddress,
+			Client:             client,
+			ContractType:       contractType,
+			Duration:           100,
+			Rate:               rates[0],
+			Deposit:            cosmos.NewInt(1500),
+			SettlementDuration: settlementDuration,
+		})
+		require.NoError(t, err)
+		contract, err := k.GetActiveContractForUser(ctx, client, providerPubKey, service)
+		require.NoError(t, err)
+		return contract
+	}
+	// settled at 120, once its settlement period is over
+	payg := open(common.BTCService, types.ContractType_PAY_AS_YOU_GO, 10)
+	// settled at 110
+	subscription := open(common.ETHService, types.ContractType_SUBSCRIPTION, 0)
+	// settled at 60, closed at 50 with a settlement period of 10 blocks
+	closed := open(common.BTCService, types.ContractType_PAY_AS_YOU_GO, 10)
+	clientAddress, err := closed.Client.GetMyAddress()
+	require.NoError(t, err)
+	ctx = ctx.WithBlockHeight(50)
+	require.NoError(t, s.CloseContractHandle(ctx, &types.MsgCloseContract{Creator: clientAddress, ContractId: closed.Id}))
+
+	expiring := func(blocks int64, provider, service string) []types.ExpiringContract {
+		res, err := k.ExpiringContracts(sdk.WrapSDKContext(ctx), &types.QueryExpiringContractsRequest{
+			Blocks:   blocks,
+			Provider: provider,
+			Service:  service,
+		})
+		require.NoError(t, err)
+		return res.Contracts
+	}
+	ids := func(contracts []types.ExpiringContract) []uint64 {
+		ids := make([]uint64, 0, len(contracts))
+		for _, contract := range contracts {
+			ids = append(ids, contract.Contract.Id)
+		}
+		return ids
+	}
+
+	// settled at the last block of the range
+	require.Empty(t, expiring(9, "", ""))
+	contracts := expiring(10, "", "")
+	require.Len(t, contracts, 1)
+	require.Equal(t, closed.Id, contracts[0].Contract.Id)
+	require.Equal(t, int64(60), contracts[0].SettlementHeight)
+	require.Equal(t, int64(10), contracts[0].BlocksUntilSettlement)
+
+	// the pay-as-you-go contract expires at 110 but is only settled at 120
+	require.Equal(t, []uint64{closed.Id, subscription.Id}, ids(expiring(69, "", "")))
+	contracts = expiring(70, "", "")
+	require.Equal(t, []uint64{closed.Id, subscription.Id, payg.Id}, ids(contracts))
+	require.Equal(t, int64(120), contracts[2].SettlementHeight)
+	require.Equal(t, int64(70), contracts[2].BlocksUntilSettlement)
+
+	// filtered by provider and service
+	require.Len(t, expiring(70, providerPubKey.String(), ""), 3)
+	require.Empty(t, expiring(70, types.GetRandomPubKey().String(), ""))
+	require.Equal(t, []uint64{subscription.Id}, ids(expiring(70, "", common.ETHService.String())))
+	require.Equal(t, []uint64{closed.Id, payg.Id}, ids(expiring(70, providerPubKey.String(), common.BTCService.String())))
+
+	// contracts settled at the current height are excluded, settled ones
+	// aren't listed again
+	ctx = ctx.WithBlockHeight(60)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	require.Equal(t, []uint64{subscription.Id, payg.Id}, ids(expiring(60, "", "")))
+	ctx = ctx.WithBlockHeight(110)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	require.Equal(t, []uint64{payg.Id}, ids(expiring(10, "", "")))
+	ctx = ctx.WithBlockHeight(120)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	require.Empty(t, expiring(100, "", ""))
+
+	for _, req := range []*types.QueryExpiringContractsRequest{
+		nil,
+		{Blocks: 0},
+		{Blocks: -1},
+		{Blocks: maxExpiringContractsBlocks + 1},
+		{Blocks: 10, Provider: "bogus"},
+		{Blocks: 10, Service: "bogus"},
+	} {
+		_, err = k.ExpiringContracts(sdk.WrapSDKContext(ctx), req)
+		require.Error(t, err)
+	}
+}
+
+func TestContractExpiringSoonEvent(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(20000000000)
+	require.NoError(t, k.SetProvider(ctx, provider))
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	require.NoError(t, s.ModProviderHandle(ctx, &types.MsgModProvider{
+		Provider:            providerPubKey,
+		Service:             common.BTCService.String(),
+		MinContractDuration: 10,
+		MaxContractDuration: 500,
+		Status:              types.ProviderStatus_ONLINE,
+		PayAsYouGoRate:      rates,
+		SubscriptionRate:    rates,
+		SettlementDuration:  10,
+	}))
+
+	open := func(contractType types.ContractType, settlementDuration int64) types.Contract {
+		client := types.GetRandomPubKey()
+		clientAddress, err := client.GetMyAddress()
+		require.NoError(t, err)
+		require.NoError(t, k.MintAndSendToAccount(ctx, clientAddress, getCoin(common.Tokens(10))))
+		_, err = s.OpenContract(ctx, &types.MsgOpenContract{
+			Provider:           providerPubKey,
+			Service:            common.BTCService.String(),
+			Creator:            clientAddress,
+			Client:             client,
+			ContractType:       contractType,
+			Duration:           200,
+			Rate:               rates[0],
+			Deposit:            cosmos.NewInt(3000),
+			SettlementDuration: settlementDuration,
+		})
+		require.NoError(t, err)
+		contract, err := k.GetActiveContractForUser(ctx, client, providerPubKey, common.BTCService)
+		require.NoError(t, err)
+		return contract
+	}
+	payg := open(types.ContractType_PAY_AS_YOU_GO, 10)
+	payg.Nonce = 20
+	require.NoError(t, k.SetContract(ctx, payg))
+	open(types.ContractType_SUBSCRIPTION, 0)
+
+	endBlock := func(height int64) []types.EventContractExpiringSoon {
+		ctx := ctx.WithBlockHeight(height).WithEventManager(sdk.NewEventManager())
+		require.NoError(t, mgr.ContractExpiringSoonEndBlock(ctx))
+		var expiring []types.EventContractExpiringSoon
+		for _, e := range ctx.EventManager().Events() {
+			if e.Type != types.EventTypeContractExpiringSoon {
+				continue
+			}
+			msg, err := sdk.ParseTypedEvent(abci.Event(e))
+			require.NoError(t, err)
+			expiring = append(expiring, *msg.(*types.EventContractExpiringSoon))
+		}
+		return expiring
+	}
+
+	// announced once, the configured number of blocks before the end of its
+	// settlement period. The subscription has nothing left to claim.
+	blocks := mgr.FetchConfig(ctx, configs.ContractExpiringSoonBlocks)
+	require.Positive(t, blocks)
+	settlementHeight := payg.SettlementPeriodEnd()
+	require.Empty(t, endBlock(settlementHeight-blocks-1))
+	expiring := endBlock(settlementHeight - blocks)
+	require.Len(t, expiring, 1)
+	evt := expiring[0]
+	require.Equal(t, payg.Id, evt.ContractId)
+	require.Equal(t, providerPubKey, evt.Provider)
+	require.Equal(t, common.BTCService.String(), evt.Service)
+	require.Equal(t, payg.Client, evt.Client)
+	require.Equal(t, int64(20), evt.Nonce)
+	require.Equal(t, settlementHeight, evt.SettlementHeight)
+	require.Equal(t, settlementHeight-blocks, evt.Height)
+	require.Empty(t, endBlock(settlementHeight-blocks+1))
+	require.Empty(t, endBlock(payg.Expiration()-blocks))
+}
+
 func TestInvariantBondModule(t *testing.T) {
 	ctx, k, sk := SetupKeeperWithStaking(t)
 	mgr := NewManager(k, sk)
@@ -399,3 +846,358 @@ func TestInvariantMaxSupply(t *testing.T) {
 	require.NoError(t, k.MintToModule(ctx, types.ModuleName, getCoin(200_000_000*1e8)))
 	require.ErrorIs(t, mgr.invariantMaxSupply(ctx), types.ErrInvariantMaxSupply)
 }
+
+func TestSettleContractPayoutSplits(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(20)
+	mgr := NewManager(k, sk)
+
+	// setup provider with a 70/30 payout split
+	providerPubKey := types.GetRandomPubKey()
+	acc1 := types.GetRandomBech32Addr()
+	acc2 := types.GetRandomBech32Addr()
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(500)
+	provider.PayoutSplits = []types.PayoutSplit{
+		types.NewPayoutSplit(acc1, 7000),
+		types.NewPayoutSplit(acc2, 3000),
+	}
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	clientPubKey := types.GetRandomPubKey()
+	contract := types.NewContract(providerPubKey, common.BTCService, clientPubKey)
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Rate = cosmos.NewInt64Coin(configs.Denom, 7)
+	contract.Deposit = cosmos.NewInt(1000)
+	contract.Height = 10
+	contract.Duration = 100
+	contract.Id = 1
+	require.NoError(t, k.SetContract(ctx, contract))
+
+	require.NoError(t, k.MintToModule(ctx, types.ModuleName, getCoin(1000)))
+	require.NoError(t, k.SendFromModuleToModule(ctx, types.ModuleName, types.ContractName, getCoins(1000)))
+
+	expected2 := cosmos.ZeroInt()
+	var err error
+	for _, nonce := range []int64{3, 8, 13, 21} {
+		reserveBefore := k.GetBalanceOfModule(ctx, types.ReserveName, configs.Denom)
+		paidBefore := contract.Paid
+		contract, err = mgr.SettleContract(ctx, contract, nonce, false)
+		require.NoError(t, err)
+
+		debt := contract.Paid.Sub(paidBefore)
+		income := debt.Sub(k.GetBalanceOfModule(ctx, types.ReserveName, configs.Denom).Sub(reserveBefore))
+		expected2 = expected2.Add(income.MulRaw(3000).QuoRaw(10000))
+
+		// no funds are lost or created
+		bal1 := k.GetBalance(ctx, acc1).AmountOf(configs.Denom)
+		bal2 := k.GetBalance(ctx, acc2).AmountOf(configs.Denom)
+		reserve := k.GetBalanceOfModule(ctx, types.ReserveName, configs.Denom)
+		require.Equal(t, contract.Paid.Int64(), bal1.Add(bal2).Add(reserve).Int64())
+		require.Equal(t, contract.Deposit.Sub(contract.Paid).Int64(), k.GetBalanceOfModule(ctx, types.ContractName, configs.Denom).Int64())
+
+		// remainders go to the first address
+		require.Equal(t, expected2.Int64(), bal2.Int64())
+	}
+	require.Equal(t, int64(147), contract.Paid.Int64())
+
+	// the provider's own address receives nothing
+	providerAddr, err := providerPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.True(t, k.GetBalance(ctx, providerAddr).IsZero())
+}
+
+func TestSettleContractSpentEvent(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(20)
+	mgr := NewManager(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(500)
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	contract := types.NewContract(providerPubKey, common.BTCService, types.GetRandomPubKey())
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Rate = cosmos.NewInt64Coin(configs.Denom, 10)
+	contract.Deposit = cosmos.NewInt(100)
+	contract.Height = 10
+	contract.Duration = 100
+	contract.Id = 1
+	require.NoError(t, k.SetContract(ctx, contract))
+
+	require.NoError(t, k.MintToModule(ctx, types.ModuleName, getCoin(100)))
+	require.NoError(t, k.SendFromModuleToModule(ctx, types.ModuleName, types.ContractName, getCoins(100)))
+
+	spentEvents := func() int {
+		count := 0
+		for _, evt := range ctx.EventManager().Events() {
+			if evt.Type == types.EventTypeContractSpent {
+				count++
+			}
+		}
+		return count
+	}
+
+	var err error
+	// the deposit is not spent yet
+	contract, err = mgr.SettleContract(ctx, contract, 5, false)
+	require.NoError(t, err)
+	require.Equal(t, 0, spentEvents())
+
+	// the debt reaches the deposit, a higher nonce doesn't pay more
+	contract, err = mgr.SettleContract(ctx, contract, 12, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), contract.Paid.Int64())
+	require.Equal(t, 1, spentEvents())
+
+	// further settlements of the spent contract don't emit it again
+	contract, err = mgr.SettleContract(ctx, contract, 15, false)
+	require.NoError(t, err)
+	_, err = mgr.SettleContract(ctx, contract, 15, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, spentEvents())
+
+	var evt types.EventContractSpent
+	for _, e := range ctx.EventManager().Events() {
+		if e.Type != types.EventTypeContractSpent {
+			continue
+		}
+		msg, err := sdk.ParseTypedEvent(abci.Event(e))
+		require.NoError(t, err)
+		evt = *msg.(*types.EventContractSpent)
+	}
+	require.Equal(t, contract.Id, evt.ContractId)
+	require.Equal(t, providerPubKey, evt.Provider)
+	require.Equal(t, contract.Client, evt.Client)
+	require.Equal(t, int64(12), evt.Nonce)
+	require.Equal(t, int64(20), evt.Height)
+}
+
+func TestProviderUptime(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(20)
+	mgr := NewManager(k, sk)
+	s := newMsgServer(k, sk)
+
+	minBond := mgr.FetchConfig(ctx, configs.MinProviderBond)
+	online := types.NewProvider(types.GetRandomPubKey(), common.BTCService)
+	online.Bond = cosmos.NewInt(minBond)
+	online.Status = types.ProviderStatus_ONLINE
+	require.NoError(t, k.SetProvider(ctx, online))
+	offline := types.NewProvider(types.GetRandomPubKey(), common.BTCService)
+	offline.Bond = cosmos.NewInt(minBond)
+	offline.Status = types.ProviderStatus_OFFLINE
+	require.NoError(t, k.SetProvider(ctx, offline))
+
+	// unknown provider
+	_, err := k.GetProviderUptime(ctx, types.GetRandomPubKey(), common.BTCService)
+	require.Error(t, err)
+
+	for i := 0; i < 3; i++ {
+		mgr.ProviderUptimeBeginBlock(ctx)
+	}
+	uptime, err := k.GetProviderUptime(ctx, online.PubKey, online.Service)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, uptime)
+
+	// provider can't serve contracts without the min bond
+	online.Bond = cosmos.NewInt(minBond - 1)
+	require.NoError(t, k.SetProvider(ctx, online))
+	mgr.ProviderUptimeBeginBlock(ctx)
+	uptime, err = k.GetProviderUptime(ctx, online.PubKey, online.Service)
+	require.NoError(t, err)
+	require.Equal(t, 0.75, uptime)
+
+	res, err := k.ProviderUptime(sdk.WrapSDKContext(ctx), &types.QueryProviderUptimeRequest{
+		Pubkey:  online.PubKey.String(),
+		Service: online.Service.String(),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0.75, res.Uptime)
+	require.Equal(t, int64(4), res.Record.TotalBlocks)
+	require.Equal(t, int64(3), res.Record.OnlineBlocks)
+
+	// offline providers aren't tracked
+	record, err := k.GetProviderUptimeRecord(ctx, offline.PubKey, offline.Service)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), record.TotalBlocks)
+
+	// going offline on purpose resets the uptime
+	acct, err := online.PubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, s.ModProviderHandle(ctx, &types.MsgModProvider{
+		Creator:  acct,
+		Provider: online.PubKey,
+		Service:  online.Service.String(),
+		Status:   types.ProviderStatus_OFFLINE,
+	}))
+	mgr.ProviderUptimeBeginBlock(ctx)
+	record, err = k.GetProviderUptimeRecord(ctx, online.PubKey, online.Service)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), record.TotalBlocks)
+	require.Equal(t, int64(0), record.OnlineBlocks)
+}
+
+func TestDowntimeEndBlock(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(20)
+	mgr := NewManager(k, sk)
+	s := newMsgServer(k, sk)
+
+	params := types.DefaultParams()
+	params.DowntimeAttestationThreshold = 2
+	params.DowntimeAttestationWindow = 50
+	params.DowntimeAttestationInterval = 10
+	params.DowntimeSlashBasisPoints = 1000
+	k.SetParams(ctx, params)
+
+	providerPubKey := types.GetRandomPubKey()
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(100_000)
+	require.NoError(t, k.SetProvider(ctx, provider))
+	require.NoError(t, k.MintToModule(ctx, types.ModuleName, getCoin(100_000)))
+	require.NoError(t, k.SendFromModuleToModule(ctx, types.ModuleName, types.ProviderName, getCoins(100_000)))
+	reserve := k.GetBalanceOfModule(ctx, types.ReserveName, configs.Denom)
+
+	attest := func(id uint64) {
+		clientPubKey := types.GetRandomPubKey()
+		clientAcct, err := clientPubKey.GetMyAddress()
+		require.NoError(t, err)
+		contract := types.NewContract(providerPubKey, common.BTCService, clientPubKey)
+		contract.Rate = cosmos.NewInt64Coin(configs.Denom, 10)
+		contract.Duration = 1000
+		contract.Height = 10
+		contract.Id = id
+		require.NoError(t, k.SetContract(ctx, contract))
+		_, err = s.AttestProviderDowntime(ctx, types.NewMsgAttestProviderDowntime(clientAcct, id, ""))
+		require.NoError(t, err)
+	}
+	bond := func() cosmos.Int {
+		provider, err := k.GetProvider(ctx, providerPubKey, common.BTCService)
+		require.NoError(t, err)
+		return provider.Bond
+	}
+
+	// as many clients as the threshold don't get the provider slashed
+	attest(1)
+	attest(2)
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+	require.Equal(t, cosmos.NewInt(100_000), bond())
+
+	// one more within the window exceeds it
+	ctx = ctx.WithBlockHeight(30)
+	attest(3)
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+	require.Equal(t, cosmos.NewInt(90_000), bond())
+	require.Equal(t, cosmos.NewInt(90_000), k.GetBalanceOfModule(ctx, types.ProviderName, configs.Denom))
+	require.Equal(t, reserve.Add(cosmos.NewInt(10_000)), k.GetBalanceOfModule(ctx, types.ReserveName, configs.Denom))
+
+	var evt *types.EventProviderSlashed
+	for _, e := range ctx.EventManager().Events() {
+		if e.Type != types.EventTypeProviderSlashed {
+			continue
+		}
+		require.Nil(t, evt, "a single slash event is expected")
+		parsed, err := sdk.ParseTypedEvent(abci.Event(e))
+		require.NoError(t, err)
+		evt = parsed.(*types.EventProviderSlashed)
+	}
+	require.NotNil(t, evt)
+	require.Equal(t, providerPubKey, evt.Provider)
+	require.Equal(t, common.BTCService.String(), evt.Service)
+	require.Equal(t, cosmos.NewInt(10_000), evt.Slashed)
+	require.Equal(t, cosmos.NewInt(90_000), evt.Bond)
+	require.Equal(t, uint64(3), evt.Attestations)
+	require.Equal(t, int64(30), evt.Height)
+
+	// the attestations it was slashed for are cleared
+	count, err := k.CountDowntimeAttestations(ctx, providerPubKey, common.BTCService, 0)
+	require.NoError(t, err)
+	require.Zero(t, count)
+	ctx = ctx.WithBlockHeight(31)
+	attest(4)
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+	require.Equal(t, cosmos.NewInt(90_000), bond())
+}
+
+func TestDowntimeAttestationPruning(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(20)
+	mgr := NewManager(k, sk)
+	s := newMsgServer(k, sk)
+
+	params := types.DefaultParams()
+	params.DowntimeAttestationThreshold = 5
+	params.DowntimeAttestationWindow = 50
+	params.DowntimeAttestationInterval = 10
+	k.SetParams(ctx, params)
+
+	providerPubKey := types.GetRandomPubKey()
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(100_000)
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	clients := make(map[uint64]cosmos.AccAddress)
+	for id := uint64(1); id <= 2; id++ {
+		clientPubKey := types.GetRandomPubKey()
+		clientAcct, err := clientPubKey.GetMyAddress()
+		require.NoError(t, err)
+		contract := types.NewContract(providerPubKey, common.BTCService, clientPubKey)
+		contract.Rate = cosmos.NewInt64Coin(configs.Denom, 10)
+		contract.Duration = 1000
+		contract.Height = 10
+		contract.Id = id
+		require.NoError(t, k.SetContract(ctx, contract))
+		clients[id] = clientAcct
+	}
+	attest := func(id uint64) {
+		_, err := s.AttestProviderDowntime(ctx, types.NewMsgAttestProviderDowntime(clients[id], id, ""))
+		require.NoError(t, err)
+	}
+	count := func() uint64 {
+		count, err := k.CountDowntimeAttestations(ctx, providerPubKey, common.BTCService, ctx.BlockHeight()-params.DowntimeAttestationWindow)
+		require.NoError(t, err)
+		return count
+	}
+
+	attest(1)
+	attest(2)
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+
+	// the first contract attests again
+	ctx = ctx.WithBlockHeight(45)
+	attest(1)
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+
+	// the attestations are counted until the end of the window
+	ctx = ctx.WithBlockHeight(69)
+	require.Equal(t, uint64(2), count())
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+
+	// and pruned once out of it, unless replaced since
+	ctx = ctx.WithBlockHeight(70)
+	require.Equal(t, uint64(1), count())
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+	attestation, err := k.GetDowntimeAttestation(ctx, providerPubKey, common.BTCService, 2)
+	require.NoError(t, err)
+	require.Zero(t, attestation.Height)
+	attestation, err = k.GetDowntimeAttestation(ctx, providerPubKey, common.BTCService, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(45), attestation.Height)
+	iter := k.GetDowntimeAttestationHeightIterator(ctx, 20)
+	require.False(t, iter.Valid())
+	iter.Close()
+
+	// the second contract can attest again
+	attest(2)
+	require.Equal(t, uint64(2), count())
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+
+	ctx = ctx.WithBlockHeight(95)
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+	attestation, err = k.GetDowntimeAttestation(ctx, providerPubKey, common.BTCService, 1)
+	require.NoError(t, err)
+	require.Zero(t, attestation.Height)
+	require.Equal(t, uint64(1), count())
+}
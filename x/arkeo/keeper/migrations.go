@@ -0,0 +1,59 @@
+package keeper
+
+import (
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// Migrator migrates the store of the module to the next consensus version
+type Migrator struct {
+	keeper Keeper
+}
+
+// NewMigrator returns a new Migrator
+func NewMigrator(keeper Keeper) Migrator {
+	return Migrator{keeper: keeper}
+}
+
+// Migrate1to2 migrates the store from consensus version 1 to 2
+func (m Migrator) Migrate1to2(ctx cosmos.Context) error {
+	return migrateContractExpirations(ctx, m.keeper)
+}
+
+// migrateContractExpirations adds the contracts with a settlement period, like
+// pay-as-you-go contracts, opened before version 2 to the expiration set of
+// their expiration height, for them to be announced as expired. Only the
+// contracts yet to expire are added, the ones expired already are settled as
+// before.
+func migrateContractExpirations(ctx cosmos.Context, k Keeper) error {
+	var contracts []types.Contract
+	iter := k.GetContractIterator(ctx)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		var contract types.Contract
+		if err := k.Cdc().Unmarshal(iter.Value(), &contract); err != nil {
+			return err
+		}
+		if contract.SettlementHeight > 0 || contract.Expiration() == contract.SettlementPeriodEnd() {
+			continue
+		}
+		if contract.Expiration() >= ctx.BlockHeight() {
+			contracts = append(contracts, contract)
+		}
+	}
+
+	for _, contract := range contracts {
+		set, err := k.GetContractExpirationSet(ctx, contract.Expiration())
+		if err != nil {
+			return err
+		}
+		if set.Contains(contract.Id) {
+			continue
+		}
+		set.Append(contract.Id)
+		if err := k.SetContractExpirationSet(ctx, set); err != nil {
+			return err
+		}
+	}
+	return nil
+}
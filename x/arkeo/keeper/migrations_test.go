@@ -0,0 +1,70 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestMigrateContractExpirations(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(60)
+	mgr := NewManager(k, sk)
+
+	newContract := func(id uint64, contractType types.ContractType, duration, settlementDuration int64) types.Contract {
+		contract := types.NewContract(types.GetRandomPubKey(), common.BTCService, types.GetRandomPubKey())
+		contract.Type = contractType
+		contract.Rate = cosmos.NewInt64Coin(configs.Denom, 5)
+		contract.Height = 10
+		contract.Duration = duration
+		contract.SettlementDuration = settlementDuration
+		contract.Id = id
+		require.NoError(t, k.SetContract(ctx, contract))
+		return contract
+	}
+	payg := newContract(1, types.ContractType_PAY_AS_YOU_GO, 100, 10)
+	settled := newContract(2, types.ContractType_PAY_AS_YOU_GO, 100, 10)
+	settled.SettlementHeight = 50
+	require.NoError(t, k.SetContract(ctx, settled))
+	newContract(3, types.ContractType_SUBSCRIPTION, 100, 0)
+	newContract(4, types.ContractType_PAY_AS_YOU_GO, 100, 0)
+	// already past their expiration at the upgrade, in their settlement period
+	// or past it
+	settling := newContract(5, types.ContractType_PAY_AS_YOU_GO, 40, 30)
+	expired := newContract(6, types.ContractType_PAY_AS_YOU_GO, 20, 10)
+
+	// migrating twice doesn't add the contract twice
+	require.NoError(t, NewMigrator(k).Migrate1to2(ctx))
+	require.NoError(t, NewMigrator(k).Migrate1to2(ctx))
+
+	set, err := k.GetContractExpirationSet(ctx, payg.Expiration())
+	require.NoError(t, err)
+	require.Equal(t, []uint64{payg.Id}, set.ContractSet.ContractIds)
+	for _, contract := range []types.Contract{settling, expired} {
+		require.Less(t, contract.Expiration(), ctx.BlockHeight())
+		set, err = k.GetContractExpirationSet(ctx, contract.Expiration())
+		require.NoError(t, err)
+		require.Empty(t, set.ContractSet.ContractIds)
+	}
+
+	expiredEvents := func(height int64) int {
+		ctx := ctx.WithBlockHeight(height).WithEventManager(sdk.NewEventManager())
+		require.NoError(t, mgr.ContractEndBlock(ctx))
+		count := 0
+		for _, e := range ctx.EventManager().Events() {
+			if e.Type == types.EventTypeContractExpired {
+				count++
+			}
+		}
+		return count
+	}
+
+	// the backfilled contract is announced as expired
+	require.Equal(t, 1, expiredEvents(payg.Expiration()))
+}
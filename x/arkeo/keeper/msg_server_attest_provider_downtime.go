@@ -0,0 +1,137 @@
+package keeper
+
+import (
+	"context"
+
+	"cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func (k msgServer) AttestProviderDowntime(goCtx context.Context, msg *types.MsgAttestProviderDowntime) (*types.MsgAttestProviderDowntimeResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+
+	ctx.Logger().Info(
+		"receive MsgAttestProviderDowntime",
+		"contract_id", msg.ContractId,
+		"service", msg.Service,
+	)
+
+	cacheCtx, commit := ctx.CacheContext()
+	if err := k.AttestProviderDowntimeValidate(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed attest provider downtime validation", "err", err)
+		return nil, err
+	}
+
+	attestations, err := k.AttestProviderDowntimeHandle(cacheCtx, msg)
+	if err != nil {
+		ctx.Logger().Error("failed attest provider downtime handle", "err", err)
+		return nil, err
+	}
+
+	commit()
+
+	return &types.MsgAttestProviderDowntimeResponse{Attestations: attestations}, nil
+}
+
+func (k msgServer) AttestProviderDowntimeValidate(ctx cosmos.Context, msg *types.MsgAttestProviderDowntime) error {
+	if k.FetchConfig(ctx, configs.HandlerAttestProviderDowntime) > 0 {
+		return errors.Wrapf(types.ErrDisabledHandler, "attest provider downtime")
+	}
+	params := k.GetParams(ctx)
+	if params.DowntimeAttestationThreshold == 0 {
+		return errors.Wrapf(types.ErrDisabledHandler, "downtime attestations are disabled")
+	}
+
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+	if contract.IsEmpty() {
+		return errors.Wrapf(types.ErrContractNotFound, "id: %d", msg.ContractId)
+	}
+
+	// only the client of an open contract can attest the downtime of its
+	// provider, a provider without open contracts can't be attested against
+	client, err := contract.Client.GetMyAddress()
+	if err != nil {
+		return err
+	}
+	if !msg.MustGetSigner().Equals(client) {
+		return errors.Wrapf(types.ErrAttestProviderDowntimeUnauthorized, "only the client of the contract can attest the provider downtime")
+	}
+	if !contract.IsOpen(ctx.BlockHeight()) {
+		return errors.Wrapf(types.ErrAttestProviderDowntimeUnauthorized, "contract %d is not open", contract.Id)
+	}
+
+	service, err := attestedService(contract, msg)
+	if err != nil {
+		return err
+	}
+	if !k.ProviderExists(ctx, contract.Provider, service) {
+		return errors.Wrapf(types.ErrProviderNotFound, "provider %s for service %s not found", contract.Provider, service)
+	}
+
+	last, err := k.GetDowntimeAttestation(ctx, contract.Provider, service, contract.Id)
+	if err != nil {
+		return err
+	}
+	if last.Height > 0 && ctx.BlockHeight() < last.Height+params.DowntimeAttestationInterval {
+		return errors.Wrapf(types.ErrAttestProviderDowntimeTooSoon, "contract %d attested in block %d, next attestation in block %d", contract.Id, last.Height, last.Height+params.DowntimeAttestationInterval)
+	}
+
+	return nil
+}
+
+func (k msgServer) AttestProviderDowntimeHandle(ctx cosmos.Context, msg *types.MsgAttestProviderDowntime) (uint64, error) {
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return 0, err
+	}
+	service, err := attestedService(contract, msg)
+	if err != nil {
+		return 0, err
+	}
+
+	attestation := types.DowntimeAttestation{
+		Provider:   contract.Provider,
+		Service:    service,
+		ContractId: contract.Id,
+		Client:     contract.Client,
+		Height:     ctx.BlockHeight(),
+	}
+	if err := k.SetDowntimeAttestation(ctx, attestation); err != nil {
+		return 0, err
+	}
+
+	// the provider is slashed at the end of the block once the threshold is
+	// exceeded
+	since := ctx.BlockHeight() - k.GetParams(ctx).DowntimeAttestationWindow
+	attestations, err := k.CountDowntimeAttestations(ctx, contract.Provider, service, since)
+	if err != nil {
+		return 0, err
+	}
+
+	return attestations, k.EmitAttestProviderDowntimeEvent(ctx, &contract, service, attestations)
+}
+
+// attestedService is the service the provider is attested down for, one of the
+// services of a bundle contract, the downtime is attested and slashed against
+// the provider record of that service
+func attestedService(contract types.Contract, msg *types.MsgAttestProviderDowntime) (common.Service, error) {
+	if len(msg.Service) == 0 {
+		return contract.Service, nil
+	}
+	service, err := common.NewService(msg.Service)
+	if err != nil {
+		return service, errors.Wrapf(types.ErrInvalidService, "%s", msg.Service)
+	}
+	if !contract.HasService(service) {
+		return service, errors.Wrapf(types.ErrInvalidService, "contract %d is not for service %s", contract.Id, service)
+	}
+	return service, nil
+}
@@ -0,0 +1,112 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestAttestProviderDowntime(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(14)
+	s := newMsgServer(k, sk)
+
+	params := types.DefaultParams()
+	params.DowntimeAttestationWindow = 100
+	params.DowntimeAttestationInterval = 10
+	k.SetParams(ctx, params)
+
+	// setup
+	providerPubKey := types.GetRandomPubKey()
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(20000000000)
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	newContract := func(id uint64) (types.Contract, cosmos.AccAddress) {
+		clientPubKey := types.GetRandomPubKey()
+		clientAcct, err := clientPubKey.GetMyAddress()
+		require.NoError(t, err)
+		contract := types.NewContract(providerPubKey, common.BTCService, clientPubKey)
+		contract.Rate = cosmos.NewInt64Coin(configs.Denom, 10)
+		contract.Duration = 100
+		contract.Height = 10
+		contract.Id = id
+		require.NoError(t, k.SetContract(ctx, contract))
+		return contract, clientAcct
+	}
+	contract1, client1 := newContract(1)
+	contract2, client2 := newContract(2)
+
+	// only the client of the contract can attest the provider downtime
+	msg := types.NewMsgAttestProviderDowntime(client2, contract1.Id, "")
+	err := s.AttestProviderDowntimeValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrAttestProviderDowntimeUnauthorized)
+
+	// unknown contract
+	msg = types.NewMsgAttestProviderDowntime(client1, 50, "")
+	err = s.AttestProviderDowntimeValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrContractNotFound)
+
+	// happy path
+	msg = types.NewMsgAttestProviderDowntime(client1, contract1.Id, "")
+	res, err := s.AttestProviderDowntime(ctx, msg)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.Attestations)
+	attestation, err := k.GetDowntimeAttestation(ctx, providerPubKey, common.BTCService, contract1.Id)
+	require.NoError(t, err)
+	require.Equal(t, contract1.Client, attestation.Client)
+	require.Equal(t, int64(14), attestation.Height)
+
+	var evt *types.EventAttestProviderDowntime
+	for _, e := range ctx.EventManager().Events() {
+		if e.Type != types.EventTypeAttestProviderDowntime {
+			continue
+		}
+		require.Nil(t, evt, "a single attestation event is expected")
+		parsed, err := sdk.ParseTypedEvent(abci.Event(e))
+		require.NoError(t, err)
+		evt = parsed.(*types.EventAttestProviderDowntime)
+	}
+	require.NotNil(t, evt)
+	require.Equal(t, providerPubKey, evt.Provider)
+	require.Equal(t, common.BTCService.String(), evt.Service)
+	require.Equal(t, contract1.Id, evt.ContractId)
+	require.Equal(t, contract1.Client, evt.Client)
+	require.Equal(t, uint64(1), evt.Attestations)
+	require.Equal(t, int64(14), evt.Height)
+
+	// a contract can only attest once per interval
+	ctx = ctx.WithBlockHeight(23)
+	_, err = s.AttestProviderDowntime(ctx, msg)
+	require.ErrorIs(t, err, types.ErrAttestProviderDowntimeTooSoon)
+
+	// the same client attesting again isn't counted twice
+	ctx = ctx.WithBlockHeight(24)
+	res, err = s.AttestProviderDowntime(ctx, msg)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.Attestations)
+
+	// another client is
+	res, err = s.AttestProviderDowntime(ctx, types.NewMsgAttestProviderDowntime(client2, contract2.Id, ""))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res.Attestations)
+
+	// a closed contract cannot be used to attest the provider downtime
+	ctx = ctx.WithBlockHeight(contract1.Expiration() + 1)
+	err = s.AttestProviderDowntimeValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrAttestProviderDowntimeUnauthorized)
+
+	// disabled
+	ctx = ctx.WithBlockHeight(50)
+	params.DowntimeAttestationThreshold = 0
+	k.SetParams(ctx, params)
+	err = s.AttestProviderDowntimeValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrDisabledHandler)
+}
@@ -3,11 +3,14 @@ package keeper
 import (
 	"context"
 
+	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 
 	"cosmossdk.io/errors"
+	metrics "github.com/armon/go-metrics"
+	"github.com/cosmos/cosmos-sdk/telemetry"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
 
@@ -45,6 +48,10 @@ func (k msgServer) ClaimContractIncomeValidate(ctx cosmos.Context, msg *types.Ms
 		return err
 	}
 
+	if err := k.ClaimVolumeValidate(ctx, contract.Provider, msg.ClaimItems()); err != nil {
+		return err
+	}
+
 	if contract.Nonce >= msg.Nonce {
 		return errors.Wrapf(types.ErrClaimContractIncomeBadNonce, "contract nonce (%d) is greater than msg nonce (%d)", contract.Nonce, msg.Nonce)
 	}
@@ -58,15 +65,23 @@ func (k msgServer) ClaimContractIncomeValidate(ctx cosmos.Context, msg *types.Ms
 		return nil
 	}
 
-	pk, err := cosmos.GetPubKeyFromBech32(cosmos.Bech32PubKeyTypeAccPub, contract.GetSpender().String())
-	if err != nil {
-		return err
+	// the claim is signed by any of the spenders of the contract
+	domain := types.SigningDomain{
+		ChainId:  ctx.ChainID(),
+		Provider: contract.Provider,
+		Required: k.FetchConfig(ctx, configs.ClaimSignatureDomain) > 0,
 	}
-	if !pk.VerifySignature(msg.GetBytesToSign(), msg.Signature) {
-		return errors.Wrap(types.ErrClaimContractIncomeInvalidSignature, "")
+	for _, spender := range contract.AllowedSpenders() {
+		pk, err := cosmos.GetPubKeyFromBech32(cosmos.Bech32PubKeyTypeAccPub, spender.String())
+		if err != nil {
+			return err
+		}
+		if domain.Verify(pk, msg.ContractId, msg.Nonce, msg.Signature) {
+			return nil
+		}
 	}
 
-	return nil
+	return errors.Wrap(types.ErrClaimContractIncomeInvalidSignature, "")
 }
 
 func (k msgServer) ClaimContractIncomeHandle(ctx cosmos.Context, msg *types.MsgClaimContractIncome) error {
@@ -75,6 +90,44 @@ func (k msgServer) ClaimContractIncomeHandle(ctx cosmos.Context, msg *types.MsgC
 		return err
 	}
 
+	k.ClaimVolumeHandle(ctx, contract.Provider, msg.ClaimItems())
+
 	_, err = k.mgr.SettleContract(ctx, contract, msg.Nonce, false)
 	return err
 }
+
+// ClaimVolumeValidate rejects the claims once the provider exceeds the max
+// number of claims per block, across all of its contracts
+func (k msgServer) ClaimVolumeValidate(ctx cosmos.Context, provider common.PubKey, items int64) error {
+	max := k.FetchConfig(ctx, configs.MaxClaimsPerBlock)
+	count := k.GetClaimCount(ctx, provider)
+	if max > 0 && count+items > max {
+		telemetry.IncrCounterWithLabels(
+			[]string{types.ModuleName, "claims", "rejected"},
+			float32(items),
+			[]metrics.Label{telemetry.NewLabel("provider", provider.String())},
+		)
+		return errors.Wrapf(types.ErrClaimContractIncomeTooManyClaims, "provider has %d claims in block %d (max %d)", count, ctx.BlockHeight(), max)
+	}
+	return nil
+}
+
+// ClaimVolumeHandle counts the claims of the provider in the block, and
+// charges escalating gas for the claims above the soft limit
+func (k msgServer) ClaimVolumeHandle(ctx cosmos.Context, provider common.PubKey, items int64) {
+	softLimit := k.FetchConfig(ctx, configs.ClaimSoftLimitPerBlock)
+	excessGas := k.FetchConfig(ctx, configs.ClaimExcessGas)
+	count := k.GetClaimCount(ctx, provider)
+	for i := int64(1); i <= items; i++ {
+		if excess := count + i - softLimit; excess > 0 && excessGas > 0 {
+			ctx.GasMeter().ConsumeGas(uint64(excess*excessGas), "claim volume")
+		}
+	}
+	k.SetClaimCount(ctx, provider, count+items)
+
+	telemetry.IncrCounterWithLabels(
+		[]string{types.ModuleName, "claims"},
+		float32(items),
+		[]metrics.Label{telemetry.NewLabel("provider", provider.String())},
+	)
+}
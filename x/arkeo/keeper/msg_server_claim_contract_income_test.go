@@ -12,6 +12,8 @@ import (
 	"github.com/cosmos/cosmos-sdk/crypto/hd"
 	cKeys "github.com/cosmos/cosmos-sdk/crypto/keyring"
 	"github.com/cosmos/cosmos-sdk/std"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/module"
 	"github.com/stretchr/testify/require"
 )
@@ -66,12 +68,79 @@ func TestValidate(t *testing.T) {
 	require.NoError(t, err)
 	require.NoError(t, s.ClaimContractIncomeValidate(ctx, &msg))
 
+	// a claim signed for the domain of chain A is valid on chain A only
+	msg.Signature, _, err = kb.Sign("whatever", types.GetDomainBytesToSign("arkeo-a", contract.Provider, contract.Id, msg.Nonce))
+	require.NoError(t, err)
+	require.NoError(t, s.ClaimContractIncomeValidate(ctx.WithChainID("arkeo-a"), &msg))
+	err = s.ClaimContractIncomeValidate(ctx.WithChainID("arkeo-b"), &msg)
+	require.ErrorIs(t, err, types.ErrClaimContractIncomeInvalidSignature)
+
 	// check closed contract
 	ctx = ctx.WithBlockHeight(ctx.BlockHeight() + contract.Duration)
 	err = s.ClaimContractIncomeValidate(ctx, &msg)
 	require.ErrorIs(t, err, types.ErrClaimContractIncomeClosed)
 }
 
+func TestValidateContractSpenders(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(20)
+	s := newMsgServer(k, sk)
+
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	module.NewBasicManager().RegisterInterfaces(interfaceRegistry)
+	types.RegisterInterfaces(interfaceRegistry)
+	cdc := codec.NewProtoCodec(interfaceRegistry)
+
+	kb := cKeys.NewInMemory(cdc)
+	newKey := func(name string) common.PubKey {
+		info, _, err := kb.NewMnemonic(name, cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+		require.NoError(t, err)
+		pk, err := info.GetPubKey()
+		require.NoError(t, err)
+		pubkey, err := common.NewPubKeyFromCrypto(pk)
+		require.NoError(t, err)
+		return pubkey
+	}
+	client := newKey("client")
+	serverA := newKey("server-a")
+	serverB := newKey("server-b")
+	newKey("unlisted")
+
+	contract := types.NewContract(types.GetRandomPubKey(), common.BTCService, client)
+	contract.Spenders = []common.PubKey{serverA, serverB}
+	contract.Duration = 100
+	contract.Rate = cosmos.NewInt64Coin("uarkeo", 10)
+	contract.Height = 10
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Deposit = cosmos.NewInt(1000)
+	contract.Id = 1
+	require.NoError(t, k.SetContract(ctx, contract))
+
+	msg := types.MsgClaimContractIncome{
+		ContractId: contract.Id,
+		Creator:    types.GetRandomBech32Addr(),
+		Nonce:      20,
+	}
+	sign := func(name string) {
+		var err error
+		msg.Signature, _, err = kb.Sign(name, msg.GetBytesToSign())
+		require.NoError(t, err)
+	}
+
+	// claims signed by any listed spender are valid
+	sign("server-a")
+	require.NoError(t, s.ClaimContractIncomeValidate(ctx, &msg))
+	sign("server-b")
+	require.NoError(t, s.ClaimContractIncomeValidate(ctx, &msg))
+
+	// the client isn't listed, nor is anybody else
+	sign("client")
+	require.ErrorIs(t, s.ClaimContractIncomeValidate(ctx, &msg), types.ErrClaimContractIncomeInvalidSignature)
+	sign("unlisted")
+	require.ErrorIs(t, s.ClaimContractIncomeValidate(ctx, &msg), types.ErrClaimContractIncomeInvalidSignature)
+}
+
 func TestHandlePayAsYouGo(t *testing.T) {
 	ctx, k, sk := SetupKeeperWithStaking(t)
 
@@ -203,3 +272,142 @@ func TestHandleSubscription(t *testing.T) {
 	require.Equal(t, rname, int64(100))
 	require.Equal(t, rname+cname+acct, contract.Rate.Amount.Int64()*contract.Duration)
 }
+
+func TestClaimVolumeCap(t *testing.T) {
+	var err error
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(20).WithGasMeter(sdk.NewInfiniteGasMeter())
+
+	s := newMsgServer(k, sk)
+	maxClaims := s.FetchConfig(ctx, configs.MaxClaimsPerBlock)
+	softLimit := s.FetchConfig(ctx, configs.ClaimSoftLimitPerBlock)
+	excessGas := s.FetchConfig(ctx, configs.ClaimExcessGas)
+
+	// setup
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	module.NewBasicManager().RegisterInterfaces(interfaceRegistry)
+	types.RegisterInterfaces(interfaceRegistry)
+	cdc := codec.NewProtoCodec(interfaceRegistry)
+
+	provider := types.GetRandomPubKey()
+	kb := cKeys.NewInMemory(cdc)
+	info, _, err := kb.NewMnemonic("whatever", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+	pk, err := info.GetPubKey()
+	require.NoError(t, err)
+	client, err := common.NewPubKeyFromCrypto(pk)
+	require.NoError(t, err)
+
+	contract := types.NewContract(provider, common.BTCService, client)
+	contract.Duration = 100
+	contract.Rate = cosmos.NewInt64Coin("uarkeo", 10)
+	contract.Height = 10
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Deposit = cosmos.NewInt(contract.Duration * contract.Rate.Amount.Int64())
+	contract.Id = 1
+	require.NoError(t, k.SetContract(ctx, contract))
+
+	msg := types.MsgClaimContractIncome{
+		ContractId: contract.Id,
+		Creator:    types.GetRandomBech32Addr(),
+		Nonce:      20,
+	}
+	msg.Signature, _, err = kb.Sign("whatever", msg.GetBytesToSign())
+	require.NoError(t, err)
+
+	// claims up to the soft limit are charged no extra gas
+	gas := ctx.GasMeter().GasConsumed()
+	for i := int64(0); i < softLimit; i++ {
+		require.NoError(t, s.ClaimVolumeValidate(ctx, provider, msg.ClaimItems()))
+		s.ClaimVolumeHandle(ctx, provider, msg.ClaimItems())
+	}
+	require.Equal(t, gas, ctx.GasMeter().GasConsumed())
+
+	// claims above the soft limit are charged escalating gas
+	gas = ctx.GasMeter().GasConsumed()
+	s.ClaimVolumeHandle(ctx, provider, msg.ClaimItems())
+	require.Equal(t, gas+uint64(excessGas), ctx.GasMeter().GasConsumed())
+	gas = ctx.GasMeter().GasConsumed()
+	s.ClaimVolumeHandle(ctx, provider, msg.ClaimItems())
+	require.Equal(t, gas+uint64(2*excessGas), ctx.GasMeter().GasConsumed())
+
+	// fill the block up to the cap
+	for i := k.GetClaimCount(ctx, provider); i < maxClaims; i++ {
+		require.NoError(t, s.ClaimVolumeValidate(ctx, provider, msg.ClaimItems()))
+		s.ClaimVolumeHandle(ctx, provider, msg.ClaimItems())
+	}
+	require.Equal(t, maxClaims, k.GetClaimCount(ctx, provider))
+
+	// excess claims are rejected
+	err = s.ClaimContractIncomeValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrClaimContractIncomeTooManyClaims)
+
+	// other providers are not affected
+	require.NoError(t, s.ClaimVolumeValidate(ctx, types.GetRandomPubKey(), 1))
+
+	// the counts are dropped with the block, the next block accepts claims
+	// again
+	ctx.MultiStore().(storetypes.CommitMultiStore).Commit()
+	require.Equal(t, int64(0), k.GetClaimCount(ctx, provider))
+	ctx = ctx.WithBlockHeight(21)
+	require.NoError(t, s.ClaimContractIncomeValidate(ctx, &msg))
+}
+
+func TestClaimVolumeCapAcrossContracts(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(20).WithGasMeter(sdk.NewInfiniteGasMeter())
+
+	s := newMsgServer(k, sk)
+	maxClaims := s.FetchConfig(ctx, configs.MaxClaimsPerBlock)
+
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	module.NewBasicManager().RegisterInterfaces(interfaceRegistry)
+	types.RegisterInterfaces(interfaceRegistry)
+	cdc := codec.NewProtoCodec(interfaceRegistry)
+
+	kb := cKeys.NewInMemory(cdc)
+	info, _, err := kb.NewMnemonic("whatever", cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+	require.NoError(t, err)
+	pk, err := info.GetPubKey()
+	require.NoError(t, err)
+	client, err := common.NewPubKeyFromCrypto(pk)
+	require.NoError(t, err)
+
+	// the provider serves the client through several contracts
+	provider := types.GetRandomPubKey()
+	msgs := make([]types.MsgClaimContractIncome, 4)
+	for i := range msgs {
+		contract := types.NewContract(provider, common.BTCService, client)
+		contract.Duration = 100
+		contract.Rate = cosmos.NewInt64Coin("uarkeo", 10)
+		contract.Height = 10
+		contract.Type = types.ContractType_PAY_AS_YOU_GO
+		contract.Deposit = cosmos.NewInt(contract.Duration * contract.Rate.Amount.Int64())
+		contract.Id = uint64(i + 1)
+		require.NoError(t, k.SetContract(ctx, contract))
+
+		msgs[i] = types.MsgClaimContractIncome{
+			ContractId: contract.Id,
+			Creator:    types.GetRandomBech32Addr(),
+			Nonce:      20,
+		}
+		msgs[i].Signature, _, err = kb.Sign("whatever", msgs[i].GetBytesToSign())
+		require.NoError(t, err)
+	}
+
+	// claims spread over the contracts count toward the same cap
+	for i := int64(0); i < maxClaims; i++ {
+		msg := msgs[i%int64(len(msgs))]
+		require.NoError(t, s.ClaimContractIncomeValidate(ctx, &msg))
+		s.ClaimVolumeHandle(ctx, provider, msg.ClaimItems())
+	}
+	require.Equal(t, maxClaims, k.GetClaimCount(ctx, provider))
+
+	// none of the contracts of the provider can claim in this block
+	for i := range msgs {
+		err = s.ClaimContractIncomeValidate(ctx, &msgs[i])
+		require.ErrorIs(t, err, types.ErrClaimContractIncomeTooManyClaims)
+	}
+}
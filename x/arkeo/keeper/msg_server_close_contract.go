@@ -92,10 +92,17 @@ func (k msgServer) CloseContractHandle(ctx cosmos.Context, msg *types.MsgCloseCo
 		}
 	}
 
-	_, err = k.mgr.SettleContract(ctx, contract, 0, contract.IsSubscription())
+	// a subscription is settled right away, the client gets back what is left
+	// of the deposit once the rate of the blocks gone by is paid. A
+	// pay-as-you-go contract is refunded once its settlement duration is over.
+	settled, err := k.mgr.SettleContract(ctx, contract, 0, contract.IsSubscription())
 	if err != nil {
 		return err
 	}
+	refund := cosmos.ZeroInt()
+	if contract.IsSubscription() {
+		refund = contract.Deposit.Sub(settled.Deposit)
+	}
 
-	return k.EmitCloseContractEvent(ctx, &contract)
+	return k.EmitCloseContractEvent(ctx, &contract, refund)
 }
@@ -8,6 +8,9 @@ import (
 	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	abci "github.com/tendermint/tendermint/abci/types"
 )
 
 func TestCloseContractValidate(t *testing.T) {
@@ -173,6 +176,10 @@ func TestCloseSubscriptionContract(t *testing.T) {
 	_, err = s.CloseContract(ctx, &closeContractMsg)
 	require.ErrorIs(t, err, types.ErrCloseContractUnauthorized)
 
+	byClient, err := k.ContractsByClient(sdk.WrapSDKContext(ctx), &types.QueryContractsByClientRequest{Client: userPubKey.String()})
+	require.NoError(t, err)
+	require.Len(t, byClient.Contracts, 1)
+
 	// confirm that the contract can be closed by the client
 	closeContractMsg.Creator = userAddress
 	_, err = s.CloseContract(ctx, &closeContractMsg)
@@ -181,6 +188,11 @@ func TestCloseSubscriptionContract(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, contract.IsEmpty())
 
+	// the closed contract is settled, it is dropped from the index of the client
+	byClient, err = k.ContractsByClient(sdk.WrapSDKContext(ctx), &types.QueryContractsByClientRequest{Client: userPubKey.String()})
+	require.NoError(t, err)
+	require.Empty(t, byClient.Contracts)
+
 	// reopen contract this time with a delagate address.
 	openContractMessage.Delegate = user2PubKey
 	_, err = s.OpenContract(ctx, &openContractMessage)
@@ -294,3 +306,178 @@ func TestClosePayAsYouGoContract(t *testing.T) {
 	_, err = s.CloseContract(ctx, &closeContractMsg)
 	require.NoError(t, err)
 }
+
+func TestCloseSubscriptionContractRefund(t *testing.T) {
+	rate, err := cosmos.ParseCoin("5uarkeo")
+	require.NoError(t, err)
+
+	// the deposit covers two queries per minute, twice the rate times the
+	// duration
+	setup := func(t *testing.T) (cosmos.Context, *msgServer, types.Contract) {
+		ctx, k, sk := SetupKeeperWithStaking(t)
+		ctx = ctx.WithBlockHeight(10)
+		s := newMsgServer(k, sk)
+
+		clientPubKey := types.GetRandomPubKey()
+		clientAccount, err := clientPubKey.GetMyAddress()
+		require.NoError(t, err)
+		require.NoError(t, k.MintAndSendToAccount(ctx, clientAccount, getCoin(common.Tokens(10))))
+		require.NoError(t, s.OpenContractHandle(ctx, &types.MsgOpenContract{
+			Creator:          clientAccount,
+			Client:           clientPubKey,
+			Service:          common.BTCService.String(),
+			Provider:         types.GetRandomPubKey(),
+			Deposit:          cosmos.NewInt(1000),
+			Rate:             rate,
+			Duration:         100,
+			QueriesPerMinute: 2,
+			ContractType:     types.ContractType_SUBSCRIPTION,
+		}))
+		contract, err := k.GetContract(ctx, 1)
+		require.NoError(t, err)
+		require.False(t, contract.IsEmpty())
+		return ctx, s, contract
+	}
+
+	closeRefund := func(t *testing.T, ctx cosmos.Context) cosmos.Int {
+		var refund cosmos.Int
+		for _, e := range ctx.EventManager().Events() {
+			if e.Type != types.EventTypeCloseContract {
+				continue
+			}
+			msg, err := sdk.ParseTypedEvent(abci.Event(e))
+			require.NoError(t, err)
+			refund = msg.(*types.EventCloseContract).Refund
+		}
+		require.False(t, refund.IsNil(), "a close contract event is expected")
+		return refund
+	}
+
+	tests := []struct {
+		name     string
+		height   int64
+		refund   int64
+		provider int64 // income of the provider, after the reserve tax
+	}{
+		// the rate of the blocks gone by is paid, the rest is refunded
+		{name: "early close", height: 30, refund: 900, provider: 90},
+		{name: "close on expiry", height: 110, refund: 500, provider: 450},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, s, contract := setup(t)
+			ctx = ctx.WithBlockHeight(tt.height)
+			providerAddress, err := contract.Provider.GetMyAddress()
+			require.NoError(t, err)
+			clientBefore := s.GetBalance(ctx, contract.ClientAddress()).AmountOf(configs.Denom)
+
+			_, err = s.CloseContract(ctx, &types.MsgCloseContract{
+				Creator:    contract.ClientAddress(),
+				ContractId: contract.Id,
+			})
+			require.NoError(t, err)
+
+			require.Equal(t, tt.refund, closeRefund(t, ctx).Int64())
+			clientAfter := s.GetBalance(ctx, contract.ClientAddress()).AmountOf(configs.Denom)
+			require.Equal(t, tt.refund, clientAfter.Sub(clientBefore).Int64())
+			require.Equal(t, tt.provider, s.GetBalance(ctx, providerAddress).AmountOf(configs.Denom).Int64())
+			require.True(t, s.GetBalanceOfModule(ctx, types.ContractName, configs.Denom).IsZero())
+
+			contract, err = s.GetContract(ctx, contract.Id)
+			require.NoError(t, err)
+			require.Equal(t, tt.height, contract.SettlementHeight)
+			require.Equal(t, int64(1000)-tt.refund, contract.Paid.Int64())
+		})
+	}
+
+	t.Run("close after expiry", func(t *testing.T) {
+		ctx, s, contract := setup(t)
+		ctx = ctx.WithBlockHeight(111)
+		clientBefore := s.GetBalance(ctx, contract.ClientAddress()).AmountOf(configs.Denom)
+
+		_, err := s.CloseContract(ctx, &types.MsgCloseContract{
+			Creator:    contract.ClientAddress(),
+			ContractId: contract.Id,
+		})
+		require.ErrorIs(t, err, types.ErrCloseContractAlreadyClosed)
+
+		// nothing is refunded, the contract is settled on its own
+		require.Equal(t, clientBefore, s.GetBalance(ctx, contract.ClientAddress()).AmountOf(configs.Denom))
+		require.Equal(t, int64(1000), s.GetBalanceOfModule(ctx, types.ContractName, configs.Denom).Int64())
+	})
+}
+
+func TestContractsByClientAcrossProviders(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	clientPubKey := types.GetRandomPubKey()
+	clientAccount, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, clientAccount, getCoin(common.Tokens(10))))
+	otherPubKey := types.GetRandomPubKey()
+	otherAccount, err := otherPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, otherAccount, getCoin(common.Tokens(10))))
+
+	open := func(creator cosmos.AccAddress, client, provider common.PubKey, service common.Service, duration int64) types.Contract {
+		require.NoError(t, s.OpenContractHandle(ctx, &types.MsgOpenContract{
+			Creator:      creator,
+			Client:       client,
+			Service:      service.String(),
+			Provider:     provider,
+			Deposit:      cosmos.NewInt(5 * duration),
+			Rate:         cosmos.NewInt64Coin(configs.Denom, 5),
+			Duration:     duration,
+			ContractType: types.ContractType_SUBSCRIPTION,
+		}))
+		contract, err := k.GetActiveContractForUser(ctx, client, provider, service)
+		require.NoError(t, err)
+		require.False(t, contract.IsEmpty())
+		return contract
+	}
+
+	// the client opens contracts with three providers, another client with
+	// one of them
+	provider1, provider2, provider3 := types.GetRandomPubKey(), types.GetRandomPubKey(), types.GetRandomPubKey()
+	closed := open(clientAccount, clientPubKey, provider1, common.BTCService, 100)
+	expiring := open(clientAccount, clientPubKey, provider2, common.BTCService, 20)
+	kept := open(clientAccount, clientPubKey, provider3, common.ETHService, 100)
+	open(otherAccount, otherPubKey, provider1, common.BTCService, 100)
+
+	byClient := func(height int64) []types.Contract {
+		res, err := k.ContractsByClient(sdk.WrapSDKContext(ctx.WithBlockHeight(height)), &types.QueryContractsByClientRequest{
+			Client:     clientPubKey.String(),
+			ActiveOnly: true,
+		})
+		require.NoError(t, err)
+		return res.Contracts
+	}
+	contracts := byClient(15)
+	require.Len(t, contracts, 3)
+	require.ElementsMatch(t, []uint64{closed.Id, expiring.Id, kept.Id}, []uint64{contracts[0].Id, contracts[1].Id, contracts[2].Id})
+	for _, contract := range contracts {
+		require.Equal(t, clientPubKey, contract.Client)
+		require.Equal(t, int64(10)+contract.Duration, contract.Expiration())
+		require.Equal(t, cosmos.NewInt(5*contract.Duration), contract.Deposit)
+	}
+
+	// a closed contract is dropped from the index of the client
+	ctx = ctx.WithBlockHeight(14)
+	require.NoError(t, s.CloseContractHandle(ctx, &types.MsgCloseContract{Creator: clientAccount, ContractId: closed.Id}))
+	contracts = byClient(15)
+	require.Len(t, contracts, 2)
+	require.ElementsMatch(t, []uint64{expiring.Id, kept.Id}, []uint64{contracts[0].Id, contracts[1].Id})
+
+	// and an expired one once it is settled by the end blocker
+	require.Len(t, byClient(31), 1)
+	ctx = ctx.WithBlockHeight(30)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	res, err := k.ContractsByClient(sdk.WrapSDKContext(ctx), &types.QueryContractsByClientRequest{Client: clientPubKey.String()})
+	require.NoError(t, err)
+	require.Len(t, res.Contracts, 1)
+	require.Equal(t, kept.Id, res.Contracts[0].Id)
+	require.Equal(t, provider3, res.Contracts[0].Provider)
+}
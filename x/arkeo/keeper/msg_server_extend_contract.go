@@ -0,0 +1,191 @@
+package keeper
+
+import (
+	"context"
+
+	"cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func (k msgServer) ExtendContract(goCtx context.Context, msg *types.MsgExtendContract) (*types.MsgExtendContractResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+
+	ctx.Logger().Info(
+		"receive MsgExtendContract",
+		"contract_id", msg.ContractId,
+		"duration", msg.Duration,
+		"deposit", msg.Deposit,
+	)
+
+	cacheCtx, commit := ctx.CacheContext()
+	if err := k.ExtendContractValidate(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed extend contract validation", "err", err)
+		return nil, err
+	}
+
+	if err := k.ExtendContractHandle(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed extend contract handle", "err", err)
+		return nil, err
+	}
+
+	commit()
+
+	return &types.MsgExtendContractResponse{}, nil
+}
+
+func (k msgServer) ExtendContractValidate(ctx cosmos.Context, msg *types.MsgExtendContract) error {
+	if k.FetchConfig(ctx, configs.HandlerExtendContract) > 0 {
+		return errors.Wrapf(types.ErrDisabledHandler, "extend contract")
+	}
+
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+	if contract.IsEmpty() {
+		return errors.Wrapf(types.ErrContractNotFound, "id: %d", msg.ContractId)
+	}
+
+	// only the client of the contract, or its delegate, can extend it
+	if err := k.extendContractValidateSigner(msg, contract); err != nil {
+		return err
+	}
+
+	height := ctx.BlockHeight()
+	if contract.IsSettlementPeriod(height) {
+		return errors.Wrapf(types.ErrExtendContractSettlementPeriod, "contract %d expired at %d", contract.Id, contract.Expiration())
+	}
+	if contract.IsExpired(height) {
+		return errors.Wrapf(types.ErrExtendContractExpired, "contract %d expired at %d", contract.Id, contract.Expiration())
+	}
+
+	// the contract can't run for longer than the provider allows from now on,
+	// same as a contract opened now
+	remaining := contract.Expiration() + msg.Duration - height
+	for _, service := range contract.ServiceSet() {
+		provider, err := k.GetProvider(ctx, contract.Provider, service)
+		if err != nil {
+			return err
+		}
+		if provider.Status != types.ProviderStatus_ONLINE {
+			return errors.Wrapf(types.ErrOpenContractBadProviderStatus, "has status %s", provider.Status.String())
+		}
+		if remaining > provider.MaxContractDuration {
+			return errors.Wrapf(types.ErrOpenContractDuration, "extended contract runs for %d blocks, exceeding the maximum duration of %d from provider", remaining, provider.MaxContractDuration)
+		}
+	}
+
+	// the deposit is topped up at the rate of the contract, which must still be
+	// the rate charged by the provider
+	rate, err := k.ContractRate(ctx, contract)
+	if err != nil {
+		return err
+	}
+	if !rate.Equal(contract.Rate.Amount) {
+		return errors.Wrapf(types.ErrOpenContractMismatchRate, "provider rate is now %d, contract rate is %d, open a new contract", rate.Int64(), contract.Rate.Amount.Int64())
+	}
+	if contract.IsSubscription() {
+		deposit := contract.Rate.Amount.MulRaw(msg.Duration).MulRaw(contract.QueriesPerMinute)
+		if !deposit.Equal(msg.Deposit) {
+			return errors.Wrapf(types.ErrOpenContractMismatchRate, "mismatch of rate*duration and deposit: %d * %d * %d != %d", contract.Rate.Amount.Int64(), msg.Duration, contract.QueriesPerMinute, msg.Deposit.Int64())
+		}
+	}
+
+	return nil
+}
+
+func (k msgServer) extendContractValidateSigner(msg *types.MsgExtendContract, contract types.Contract) error {
+	ok, err := isClientOrDelegate(msg.MustGetSigner(), contract)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Wrapf(types.ErrExtendContractUnauthorized, "only the client or the delegate of the contract can extend it")
+	}
+	return nil
+}
+
+// isClientOrDelegate returns true when the signer is the client of the
+// contract, or its delegate
+func isClientOrDelegate(signer cosmos.AccAddress, contract types.Contract) (bool, error) {
+	client, err := contract.Client.GetMyAddress()
+	if err != nil {
+		return false, err
+	}
+	if signer.Equals(client) {
+		return true, nil
+	}
+	if !contract.Delegate.IsEmpty() {
+		delegate, err := contract.Delegate.GetMyAddress()
+		if err != nil {
+			return false, err
+		}
+		if signer.Equals(delegate) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (k msgServer) ExtendContractHandle(ctx cosmos.Context, msg *types.MsgExtendContract) error {
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+
+	if msg.Deposit.IsPositive() {
+		if err := k.SendFromAccountToModule(ctx, msg.MustGetSigner(), types.ContractName, cosmos.NewCoins(cosmos.NewCoin(contract.Rate.Denom, msg.Deposit))); err != nil {
+			return errors.Wrapf(err, "failed to send deposit=%d", msg.Deposit.Int64())
+		}
+	}
+
+	// the end blocker settles the contracts of the expiration sets, the
+	// contract is moved to the sets of its new expiration
+	oldExpiration := contract.Expiration()
+	for _, height := range contractExpirationHeights(contract) {
+		if err := k.updateContractExpirationSet(ctx, height, contract.Id, false); err != nil {
+			return err
+		}
+	}
+
+	contract.Duration += msg.Duration
+	contract.Deposit = contract.Deposit.Add(msg.Deposit)
+
+	for _, height := range contractExpirationHeights(contract) {
+		if err := k.updateContractExpirationSet(ctx, height, contract.Id, true); err != nil {
+			return err
+		}
+	}
+
+	if err := k.SetContract(ctx, contract); err != nil {
+		return err
+	}
+
+	return k.EmitExtendContractEvent(ctx, &contract, oldExpiration)
+}
+
+// contractExpirationHeights returns the heights of the expiration sets the
+// contract is in, its expiration and the end of its settlement period
+func contractExpirationHeights(contract types.Contract) []int64 {
+	if contract.Expiration() == contract.SettlementPeriodEnd() {
+		return []int64{contract.Expiration()}
+	}
+	return []int64{contract.Expiration(), contract.SettlementPeriodEnd()}
+}
+
+func (k msgServer) updateContractExpirationSet(ctx cosmos.Context, height int64, contractId uint64, add bool) error {
+	set, err := k.GetContractExpirationSet(ctx, height)
+	if err != nil {
+		return err
+	}
+	if add {
+		set.Append(contractId)
+	} else {
+		set.Remove(contractId)
+	}
+	return k.SetContractExpirationSet(ctx, set)
+}
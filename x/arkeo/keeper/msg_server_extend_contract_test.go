@@ -0,0 +1,224 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestExtendContractValidate(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(50)
+	s := newMsgServer(k, sk)
+
+	// setup
+	providerPubKey := types.GetRandomPubKey()
+	clientPubKey := types.GetRandomPubKey()
+	clientAcct, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	delegatePubKey := types.GetRandomPubKey()
+	delegateAcct, err := delegatePubKey.GetMyAddress()
+	require.NoError(t, err)
+
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(500_00000000)
+	provider.Status = types.ProviderStatus_ONLINE
+	provider.MinContractDuration = 10
+	provider.MaxContractDuration = 500
+	provider.SubscriptionRate = rates
+	provider.PayAsYouGoRate = rates
+	provider.LastUpdate = 1
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	contract := types.NewContract(providerPubKey, common.BTCService, clientPubKey)
+	contract.Delegate = delegatePubKey
+	contract.Type = types.ContractType_SUBSCRIPTION
+	contract.Rate = cosmos.NewInt64Coin(configs.Denom, 15)
+	contract.QueriesPerMinute = 1
+	contract.Duration = 100
+	contract.Deposit = cosmos.NewInt(1500)
+	contract.Height = 10
+	contract.Id = 1
+	require.NoError(t, k.SetContract(ctx, contract))
+
+	// happy path, by the client or the delegate
+	msg := types.NewMsgExtendContract(clientAcct, contract.Id, 100, cosmos.NewInt(1500))
+	require.NoError(t, s.ExtendContractValidate(ctx, msg))
+	msg.Creator = delegateAcct
+	require.NoError(t, s.ExtendContractValidate(ctx, msg))
+
+	// anybody else can't extend the contract
+	msg.Creator = types.GetRandomBech32Addr()
+	err = s.ExtendContractValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrExtendContractUnauthorized)
+	msg.Creator = clientAcct
+
+	// unknown contract
+	msg.ContractId = 50
+	err = s.ExtendContractValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrContractNotFound)
+	msg.ContractId = contract.Id
+
+	// the extended contract would run past the max duration of the provider
+	// (expires at 110 + 450, 510 blocks from now)
+	msg.Duration = 450
+	msg.Deposit = cosmos.NewInt(450 * 15)
+	err = s.ExtendContractValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrOpenContractDuration)
+	msg.Duration = 440
+	msg.Deposit = cosmos.NewInt(440 * 15)
+	require.NoError(t, s.ExtendContractValidate(ctx, msg))
+
+	// the deposit must pay for the blocks added
+	msg.Duration = 100
+	msg.Deposit = cosmos.NewInt(1000)
+	err = s.ExtendContractValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrOpenContractMismatchRate)
+	msg.Deposit = cosmos.NewInt(1500)
+
+	// the provider changed its rate since the contract was opened
+	provider.SubscriptionRate = cosmos.NewCoins(cosmos.NewInt64Coin(configs.Denom, 20))
+	require.NoError(t, k.SetProvider(ctx, provider))
+	err = s.ExtendContractValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrOpenContractMismatchRate)
+	provider.SubscriptionRate = rates
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	// the provider went offline
+	provider.Status = types.ProviderStatus_OFFLINE
+	require.NoError(t, k.SetProvider(ctx, provider))
+	err = s.ExtendContractValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrOpenContractBadProviderStatus)
+	provider.Status = types.ProviderStatus_ONLINE
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	// an expired contract can't be extended
+	err = s.ExtendContractValidate(ctx.WithBlockHeight(111), msg)
+	require.ErrorIs(t, err, types.ErrExtendContractExpired)
+
+	// nor a pay-as-you-go contract during its settlement period
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.SettlementDuration = 10
+	require.NoError(t, k.SetContract(ctx, contract))
+	msg.Deposit = cosmos.ZeroInt()
+	require.NoError(t, s.ExtendContractValidate(ctx, msg))
+	err = s.ExtendContractValidate(ctx.WithBlockHeight(115), msg)
+	require.ErrorIs(t, err, types.ErrExtendContractSettlementPeriod)
+	err = s.ExtendContractValidate(ctx.WithBlockHeight(125), msg)
+	require.ErrorIs(t, err, types.ErrExtendContractExpired)
+}
+
+func TestExtendContract(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	service := common.BTCService
+	provider := types.NewProvider(providerPubKey, service)
+	provider.Bond = cosmos.NewInt(10000000000)
+	provider.LastUpdate = ctx.BlockHeight()
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	require.NoError(t, s.ModProviderHandle(ctx, &types.MsgModProvider{
+		Provider:            providerPubKey,
+		Service:             service.String(),
+		MinContractDuration: 10,
+		MaxContractDuration: 500,
+		Status:              types.ProviderStatus_ONLINE,
+		PayAsYouGoRate:      rates,
+		SubscriptionRate:    rates,
+	}))
+
+	clientPubKey := types.GetRandomPubKey()
+	clientAcct, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, clientAcct, getCoin(common.Tokens(10))))
+
+	_, err = s.OpenContract(sdk.WrapSDKContext(ctx), &types.MsgOpenContract{
+		Provider:         providerPubKey,
+		Service:          service.String(),
+		Creator:          clientAcct,
+		Client:           clientPubKey,
+		ContractType:     types.ContractType_SUBSCRIPTION,
+		Duration:         100,
+		Rate:             cosmos.NewInt64Coin(configs.Denom, 15),
+		Deposit:          cosmos.NewInt(1500),
+		QueriesPerMinute: 1,
+	})
+	require.NoError(t, err)
+	contract, err := k.GetActiveContractForUser(ctx, clientPubKey, providerPubKey, service)
+	require.NoError(t, err)
+	require.Equal(t, int64(110), contract.Expiration())
+
+	ctx = ctx.WithBlockHeight(60)
+	balance := k.GetBalanceOfModule(ctx, types.ContractName, configs.Denom)
+	msg := types.NewMsgExtendContract(clientAcct, contract.Id, 100, cosmos.NewInt(1500))
+	_, err = s.ExtendContract(sdk.WrapSDKContext(ctx), msg)
+	require.NoError(t, err)
+
+	// same contract, with the new duration and deposit
+	extended, err := k.GetContract(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(200), extended.Duration)
+	require.Equal(t, int64(210), extended.Expiration())
+	require.Equal(t, cosmos.NewInt(3000), extended.Deposit)
+	require.Equal(t, balance.AddRaw(1500), k.GetBalanceOfModule(ctx, types.ContractName, configs.Denom))
+
+	// the contract moved to the expiration set of its new expiration
+	set, err := k.GetContractExpirationSet(ctx, 110)
+	require.NoError(t, err)
+	require.NotContains(t, set.ContractSet.ContractIds, contract.Id)
+	set, err = k.GetContractExpirationSet(ctx, 210)
+	require.NoError(t, err)
+	require.Contains(t, set.ContractSet.ContractIds, contract.Id)
+
+	var evt *types.EventExtendContract
+	for _, e := range ctx.EventManager().Events() {
+		if e.Type != types.EventTypeExtendContract {
+			continue
+		}
+		require.Nil(t, evt, "a single extend contract event is expected")
+		parsed, err := sdk.ParseTypedEvent(abci.Event(e))
+		require.NoError(t, err)
+		evt = parsed.(*types.EventExtendContract)
+	}
+	require.NotNil(t, evt)
+	require.Equal(t, contract.Id, evt.ContractId)
+	require.Equal(t, providerPubKey, evt.Provider)
+	require.Equal(t, clientPubKey, evt.Client)
+	require.Equal(t, int64(110), evt.OldExpiration)
+	require.Equal(t, int64(210), evt.NewExpiration)
+	require.Equal(t, int64(200), evt.Duration)
+	require.Equal(t, cosmos.NewInt(3000), evt.Deposit)
+
+	// the contract is still open past its old expiration
+	ctx = ctx.WithBlockHeight(110)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	extended, err = k.GetContract(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Zero(t, extended.SettlementHeight)
+	active, err := k.GetActiveContractForUser(ctx.WithBlockHeight(111), clientPubKey, providerPubKey, service)
+	require.NoError(t, err)
+	require.Equal(t, contract.Id, active.Id)
+
+	// and is settled once its new expiration is reached
+	ctx = ctx.WithBlockHeight(210)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	extended, err = k.GetContract(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(210), extended.SettlementHeight)
+	require.Equal(t, cosmos.NewInt(3000), extended.Paid)
+}
@@ -21,12 +21,16 @@ func (k msgServer) ModProvider(goCtx context.Context, msg *types.MsgModProvider)
 		"service", msg.Service,
 		"metatadata uri", msg.MetadataUri,
 		"metadata nonce", msg.MetadataNonce,
+		"metadata hash", msg.MetadataHash,
 		"status", msg.Status,
 		"min contract duration", msg.MinContractDuration,
 		"max contract duration", msg.MaxContractDuration,
 		"subscription rate", msg.SubscriptionRate,
 		"pay-as-you-go rate", msg.PayAsYouGoRate,
 		"settlement duration", msg.SettlementDuration,
+		"payout splits", msg.PayoutSplits,
+		"method rates", msg.MethodRates,
+		"min deposit", msg.MinDeposit,
 	)
 
 	cacheCtx, commit := ctx.CacheContext()
@@ -59,6 +63,19 @@ func (k msgServer) ModProviderValidate(ctx cosmos.Context, msg *types.MsgModProv
 		}
 	}
 
+	maxURILength := k.FetchConfig(ctx, configs.MaxMetadataURILength)
+	schemes := k.mgr.Configs(ctx).GetStringValue(configs.MetadataURISchemes)
+	if err := types.ValidateMetadataURI(msg.MetadataUri, maxURILength, schemes); err != nil {
+		return err
+	}
+
+	// a provider can require more than the module floor, not less
+	if !msg.MinDeposit.IsNil() && msg.MinDeposit.IsPositive() {
+		if floor := k.GetParams(ctx).MinContractDeposit; msg.MinDeposit.LT(floor) {
+			return errors.Wrapf(types.ErrInvalidModProviderMinDeposit, "min deposit is below the min contract deposit (%s/%s)", msg.MinDeposit, floor)
+		}
+	}
+
 	service, err := common.NewService(msg.Service)
 	if err != nil {
 		return err
@@ -84,9 +101,13 @@ func (k msgServer) ModProviderHandle(ctx cosmos.Context, msg *types.MsgModProvid
 		return err
 	}
 
-	// update metadata URI
+	// update metadata URI, the hash of the previous metadata doesn't hold for
+	// the new URI so it is replaced too
 	if len(msg.MetadataUri) > 0 {
 		provider.MetadataUri = msg.MetadataUri
+		provider.MetadataHash = msg.MetadataHash
+	} else if len(msg.MetadataHash) > 0 {
+		provider.MetadataHash = msg.MetadataHash
 	}
 
 	// update metadata nonce
@@ -94,7 +115,11 @@ func (k msgServer) ModProviderHandle(ctx cosmos.Context, msg *types.MsgModProvid
 		provider.MetadataNonce = msg.MetadataNonce
 	}
 
-	// update status
+	// update status, going offline on purpose doesn't count against the
+	// provider uptime
+	if provider.Status == types.ProviderStatus_ONLINE && msg.Status == types.ProviderStatus_OFFLINE {
+		k.RemoveProviderUptimeRecord(ctx, provider.PubKey, provider.Service)
+	}
 	provider.Status = msg.Status
 
 	// update contract durations
@@ -106,6 +131,18 @@ func (k msgServer) ModProviderHandle(ctx cosmos.Context, msg *types.MsgModProvid
 	provider.PayAsYouGoRate = msg.PayAsYouGoRate
 	provider.SettlementDuration = msg.SettlementDuration
 
+	// update income payout splits
+	provider.PayoutSplits = msg.PayoutSplits
+
+	// update the rate schedule of the expensive requests
+	provider.MethodRates = msg.MethodRates
+
+	// update the min deposit of the contracts
+	provider.MinDeposit = cosmos.ZeroInt()
+	if !msg.MinDeposit.IsNil() {
+		provider.MinDeposit = msg.MinDeposit
+	}
+
 	provider.LastUpdate = ctx.BlockHeight()
 
 	if err := k.SetProvider(ctx, provider); err != nil {
@@ -1,12 +1,15 @@
 package keeper
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
 )
 
 func TestModProviderValidate(t *testing.T) {
@@ -41,6 +44,21 @@ func TestModProviderValidate(t *testing.T) {
 	msg.MaxContractDuration = 5256000 * 2
 	err = s.ModProviderValidate(ctx, &msg)
 	require.ErrorIs(t, err, types.ErrInvalidModProviderMaxContractDuration)
+	msg.MaxContractDuration = 500
+
+	// metadata uri must use an allowed scheme
+	msg.MetadataUri = "https://mad.hatter.net/metadata.json"
+	require.NoError(t, s.ModProviderValidate(ctx, &msg))
+	msg.MetadataUri = "ipfs://bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi"
+	require.NoError(t, s.ModProviderValidate(ctx, &msg))
+	msg.MetadataUri = "http://mad.hatter.net/metadata.json"
+	err = s.ModProviderValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrInvalidModProviderMetdataURI)
+
+	// metadata uri is longer than the max length
+	msg.MetadataUri = "https://mad.hatter.net/" + strings.Repeat("a", 100)
+	err = s.ModProviderValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrInvalidModProviderMetdataURI)
 }
 
 func TestModProviderHandle(t *testing.T) {
@@ -85,4 +103,33 @@ func TestModProviderHandle(t *testing.T) {
 	require.Equal(t, provider.Status, types.ProviderStatus_ONLINE)
 	require.Equal(t, provider.SubscriptionRate[0].Amount.Int64(), int64(11))
 	require.Equal(t, provider.PayAsYouGoRate[0].Amount.Int64(), int64(12))
+	require.Empty(t, provider.MetadataHash)
+
+	// the metadata hash is committed to along with the uri
+	hash := types.MetadataHash([]byte(`{"version":"1"}`))
+	msg.MetadataUri = "https://mad.hatter.net/metadata.json"
+	msg.MetadataHash = hash
+	require.NoError(t, s.ModProviderHandle(ctx, &msg))
+	provider, err = k.GetProvider(ctx, msg.Provider, common.BTCService)
+	require.NoError(t, err)
+	require.Equal(t, hash, provider.MetadataHash)
+
+	found := false
+	for _, e := range ctx.EventManager().Events() {
+		if e.Type != types.EventTypeModProvider {
+			continue
+		}
+		evt, err := sdk.ParseTypedEvent(abci.Event(e))
+		require.NoError(t, err)
+		found = evt.(*types.EventModProvider).MetadataHash == hash
+	}
+	require.True(t, found)
+
+	// a new uri without a hash drops the hash of the previous metadata
+	msg.MetadataUri = "https://mad.hatter.net/metadata2.json"
+	msg.MetadataHash = ""
+	require.NoError(t, s.ModProviderHandle(ctx, &msg))
+	provider, err = k.GetProvider(ctx, msg.Provider, common.BTCService)
+	require.NoError(t, err)
+	require.Empty(t, provider.MetadataHash)
 }
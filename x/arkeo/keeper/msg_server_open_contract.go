@@ -6,7 +6,6 @@ import (
 	"cosmossdk.io/errors"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 
-	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
@@ -26,6 +25,7 @@ func (k msgServer) OpenContract(goCtx context.Context, msg *types.MsgOpenContrac
 		"rate", msg.Rate,
 		"settlement duration", msg.SettlementDuration,
 		"authorization", msg.Authorization,
+		"bundle id", msg.BundleId,
 	)
 
 	// CacheContext implies NewEventManager
@@ -49,17 +49,68 @@ func (k msgServer) OpenContractValidate(ctx cosmos.Context, msg *types.MsgOpenCo
 		return errors.Wrapf(types.ErrDisabledHandler, "open contract")
 	}
 
-	service, err := common.NewService(msg.Service)
+	services, err := k.OpenContractServices(ctx, msg)
 	if err != nil {
 		return err
 	}
-	provider, err := k.GetProvider(ctx, msg.Provider, service)
+
+	// every service of the contract must be served by an online provider, a
+	// bundle is settled after the longest settlement duration of its services
+	var settlementDuration int64
+	for _, service := range services {
+		provider, err := k.GetProvider(ctx, msg.Provider, service)
+		if err != nil {
+			return err
+		}
+		if err := k.openContractValidateProvider(ctx, msg, provider); err != nil {
+			return err
+		}
+		if provider.SettlementDuration > settlementDuration {
+			settlementDuration = provider.SettlementDuration
+		}
+	}
+
+	_, deposit, err := k.OpenContractCost(ctx, msg)
 	if err != nil {
 		return err
 	}
+	switch msg.ContractType {
+	case types.ContractType_SUBSCRIPTION:
+		if !deposit.Amount.Equal(msg.Deposit) {
+			return errors.Wrapf(types.ErrOpenContractMismatchRate, "mismatch of rate*duration and deposit: %d * %d * %d != %d", msg.Rate.Amount.Int64(), msg.Duration, msg.QueriesPerMinute, msg.Deposit.Int64())
+		}
+	case types.ContractType_PAY_AS_YOU_GO:
+		if msg.SettlementDuration != settlementDuration {
+			return errors.Wrapf(types.ErrOpenContractMismatchSettlementDuration, "pay-as-you-go provider settlement duration is %d, client sent %d", settlementDuration, msg.SettlementDuration)
+		}
+	}
+
+	if len(msg.DepositDenom) > 0 {
+		if _, ok := k.GetParams(ctx).DepositDenom(msg.DepositDenom); !ok {
+			return errors.Wrapf(types.ErrOpenContractDepositDenom, "%s", msg.DepositDenom)
+		}
+		if msg.Rate.Denom != configs.Denom {
+			return errors.Wrapf(types.ErrOpenContractDepositDenom, "only a deposit in %s can be swapped, the rate is in %s", configs.Denom, msg.Rate.Denom)
+		}
+	}
 
+	for _, service := range services {
+		activeContract, err := k.GetActiveContractForUser(ctx, msg.GetSpender(), msg.Provider, service)
+		if err != nil {
+			return err
+		}
+
+		if !activeContract.IsEmpty() && !activeContract.IsExpired(ctx.BlockHeight()) {
+			return errors.Wrapf(types.ErrOpenContractAlreadyOpen, "%s expires in %d blocks", service, activeContract.Expiration()-ctx.BlockHeight())
+		}
+	}
+
+	return nil
+}
+
+func (k msgServer) openContractValidateProvider(ctx cosmos.Context, msg *types.MsgOpenContract, provider types.Provider) error {
 	if provider.LastUpdate == 0 {
-		return errors.Wrapf(types.ErrProviderNotFound, "provider %s for service %s not found", msg.Provider, msg.Service)
+		return errors.Wrapf(types.ErrProviderNotFound, "provider %s for service %s not found", msg.Provider, provider.Service)
 	}
 
 	minBond := k.FetchConfig(ctx, configs.MinProviderBond)
@@ -79,64 +130,38 @@ func (k msgServer) OpenContractValidate(ctx cosmos.Context, msg *types.MsgOpenCo
 		return errors.Wrapf(types.ErrOpenContractDuration, "duration below allowed minimum duration from provider")
 	}
 
-	switch msg.ContractType {
-	case types.ContractType_SUBSCRIPTION:
-		if cosmos.NewCoins(provider.SubscriptionRate...).AmountOf(msg.Rate.Denom).IsZero() {
-			return errors.Wrapf(types.ErrOpenContractMismatchRate, "provider rates is 0, client sent %d", msg.Rate.Amount.Int64())
-		}
-		if !msg.Rate.Amount.Equal(cosmos.NewCoins(provider.SubscriptionRate...).AmountOf(msg.Rate.Denom)) {
-			return errors.Wrapf(types.ErrOpenContractMismatchRate, "provider rates is %d, client sent %d", cosmos.NewCoins(provider.SubscriptionRate...).AmountOf(msg.Rate.Denom).Int64(), msg.Rate.Amount.Int64())
-		}
-		if !cosmos.NewInt(msg.Rate.Amount.Int64() * msg.Duration * msg.QueriesPerMinute).Equal(msg.Deposit) {
-			return errors.Wrapf(types.ErrOpenContractMismatchRate, "mismatch of rate*duration and deposit: %d * %d * %d != %d", msg.Rate.Amount.Int64(), msg.Duration, msg.QueriesPerMinute, msg.Deposit.Int64())
-		}
-	case types.ContractType_PAY_AS_YOU_GO:
-		if cosmos.NewCoins(provider.PayAsYouGoRate...).AmountOf(msg.Rate.Denom).IsZero() {
-			return errors.Wrapf(types.ErrOpenContractMismatchRate, "provider rates is 0, client sent %d", msg.Rate.Amount.Int64())
-		}
-		if !msg.Rate.Amount.Equal(cosmos.NewCoins(provider.PayAsYouGoRate...).AmountOf(msg.Rate.Denom)) {
-			return errors.Wrapf(types.ErrOpenContractMismatchRate, "pay-as-you-go provider rate is %d, client sent %d", cosmos.NewCoins(provider.PayAsYouGoRate...).AmountOf(msg.Rate.Denom).Int64(), msg.Rate.Amount.Int64())
-		}
-		if msg.SettlementDuration != provider.SettlementDuration {
-			return errors.Wrapf(types.ErrOpenContractMismatchSettlementDuration, "pay-as-you-go provider settlement duration is %d, client sent %d", provider.SettlementDuration, msg.SettlementDuration)
-		}
-	default:
-		return errors.Wrapf(types.ErrInvalidContractType, "%s", msg.ContractType.String())
-	}
-
-	activeContract, err := k.GetActiveContractForUser(ctx, msg.GetSpender(), msg.Provider, service)
-	if err != nil {
-		return err
-	}
-
-	if !activeContract.IsEmpty() && !activeContract.IsExpired(ctx.BlockHeight()) {
-		return errors.Wrapf(types.ErrOpenContractAlreadyOpen, "expires in %d blocks", activeContract.Expiration()-ctx.BlockHeight())
+	minDeposit := provider.ContractMinDeposit(k.GetParams(ctx).MinContractDeposit)
+	if msg.Deposit.IsNil() || msg.Deposit.LT(minDeposit) {
+		return errors.Wrapf(types.ErrOpenContractMinDeposit, "deposit of %s is below the minimum of %s for service %s", msg.Deposit, minDeposit, provider.Service)
 	}
 
 	return nil
 }
 
 func (k msgServer) OpenContractHandle(ctx cosmos.Context, msg *types.MsgOpenContract) error {
-	openCost := k.FetchConfig(ctx, configs.OpenContractCost)
-	if openCost > 0 {
-		if err := k.SendFromAccountToModule(ctx, msg.MustGetSigner(), types.ReserveName, getCoins(openCost)); err != nil {
-			return errors.Wrapf(err, "failed to send open contract costs openCost=%d", openCost)
-		}
-	}
-
-	if err := k.SendFromAccountToModule(ctx, msg.MustGetSigner(), types.ContractName, cosmos.NewCoins(cosmos.NewCoin(msg.Rate.Denom, msg.Deposit))); err != nil {
-		return errors.Wrapf(err, "failed to send deposit=%d", msg.Deposit.Int64())
+	openCost := k.OpenContractFee(ctx)
+	if err := k.payOpenContract(ctx, msg, openCost); err != nil {
+		return err
 	}
 
-	service, err := common.NewService(msg.Service)
+	services, err := k.OpenContractServices(ctx, msg)
 	if err != nil {
 		return err
 	}
+	// the rate schedule can't change under an open contract
+	var methodRates [][]types.MethodRate
+	for _, service := range services {
+		provider, err := k.GetProvider(ctx, msg.Provider, service)
+		if err != nil {
+			return err
+		}
+		methodRates = append(methodRates, provider.MethodRates)
+	}
 
 	contract := types.Contract{
 		Provider:           msg.Provider,
 		Id:                 k.Keeper.GetAndIncrementNextContractId(ctx),
-		Service:            service,
+		Service:            services[0],
 		Type:               msg.ContractType,
 		Client:             msg.Client,
 		Delegate:           msg.Delegate,
@@ -148,6 +173,12 @@ func (k msgServer) OpenContractHandle(ctx cosmos.Context, msg *types.MsgOpenCont
 		SettlementDuration: msg.SettlementDuration,
 		Authorization:      msg.Authorization,
 		QueriesPerMinute:   msg.QueriesPerMinute,
+		MethodRates:        types.MergeMethodRates(methodRates...),
+		Spenders:           msg.Spenders,
+	}
+	if msg.BundleId > 0 {
+		contract.Services = services
+		contract.BundleId = msg.BundleId
 	}
 
 	// create expiration set
@@ -164,6 +195,18 @@ func (k msgServer) OpenContractHandle(ctx cosmos.Context, msg *types.MsgOpenCont
 		return err
 	}
 
+	// a contract with a settlement period also expires before it is settled
+	if contract.Expiration() != contract.SettlementPeriodEnd() {
+		expirySet, err := k.GetContractExpirationSet(ctx, contract.Expiration())
+		if err != nil {
+			return err
+		}
+		expirySet.Append(contract.Id)
+		if err := k.SetContractExpirationSet(ctx, expirySet); err != nil {
+			return err
+		}
+	}
+
 	// create user set.
 	userSet, err := k.GetUserContractSet(ctx, msg.GetSpender())
 	if err != nil {
@@ -180,10 +223,57 @@ func (k msgServer) OpenContractHandle(ctx cosmos.Context, msg *types.MsgOpenCont
 		return err
 	}
 
+	if err := k.SetClientContract(ctx, contract.Client, contract.Id); err != nil {
+		return err
+	}
+	for _, service := range contract.ServiceSet() {
+		if err := k.SetProviderContract(ctx, contract.Provider, service, contract.Id); err != nil {
+			return err
+		}
+	}
+
 	err = k.SetContract(ctx, contract)
 	if err != nil {
 		return err
 	}
+	k.AddReserveContribution(ctx, contract.Id, openCost)
+
+	return k.EmitOpenContractEvent(ctx, openCost.Amount.Int64(), &contract)
+}
+
+// payOpenContract sends the open contract cost to the reserve, and the deposit
+// to the contract module. When paid in an accepted deposit denom, both are
+// swapped against the reserve at the rate of the params: the signer pays the
+// reserve their native amount in that denom, and the reserve sends the
+// deposit to the contract module in the native denom. The contract records
+// the native deposit and is settled in the native denom, a later change of
+// the rate doesn't affect it.
+func (k msgServer) payOpenContract(ctx cosmos.Context, msg *types.MsgOpenContract, openCost cosmos.Coin) error {
+	signer := msg.MustGetSigner()
+	deposit := cosmos.NewCoins(cosmos.NewCoin(msg.Rate.Denom, msg.Deposit))
+	if len(msg.DepositDenom) == 0 {
+		if openCost.IsPositive() {
+			if err := k.SendFromAccountToModule(ctx, signer, types.ReserveName, cosmos.NewCoins(openCost)); err != nil {
+				return errors.Wrapf(err, "failed to send open contract costs openCost=%d", openCost.Amount.Int64())
+			}
+		}
+		if err := k.SendFromAccountToModule(ctx, signer, types.ContractName, deposit); err != nil {
+			return errors.Wrapf(err, "failed to send deposit=%d", msg.Deposit.Int64())
+		}
+		return nil
+	}
 
-	return k.EmitOpenContractEvent(ctx, openCost, &contract)
+	depositDenom, ok := k.GetParams(ctx).DepositDenom(msg.DepositDenom)
+	if !ok {
+		return errors.Wrapf(types.ErrOpenContractDepositDenom, "%s", msg.DepositDenom)
+	}
+	paid := cosmos.NewCoin(depositDenom.Denom, depositDenom.Cost(openCost.Amount.Add(msg.Deposit)))
+	if err := k.SendFromAccountToModule(ctx, signer, types.ReserveName, cosmos.NewCoins(paid)); err != nil {
+		return errors.Wrapf(err, "failed to send open contract costs and deposit=%s", paid)
+	}
+	if err := k.SendFromModuleToModule(ctx, types.ReserveName, types.ContractName, deposit); err != nil {
+		return errors.Wrapf(err, "failed to swap %s for deposit=%d%s", paid, msg.Deposit.Int64(), msg.Rate.Denom)
+	}
+	ctx.Logger().Info("open contract costs and deposit swapped", "paid", paid, "open cost", openCost, "deposit", deposit)
+	return nil
 }
@@ -158,6 +158,53 @@ func TestOpenContractHandle(t *testing.T) {
 	require.Len(t, userSet.ContractSet.ContractIds, 1)
 }
 
+func TestOpenContractMethodRates(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	providerAcct, err := providerPubKey.GetMyAddress()
+	require.NoError(t, err)
+	service := common.BTCService
+	provider := types.NewProvider(providerPubKey, service)
+	provider.Bond = cosmos.NewInt(10000000000)
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	modMsg := types.NewMsgModProvider(providerAcct, providerPubKey, service.String(), "", 0, types.ProviderStatus_ONLINE, 10, 500, rates, rates, 0)
+	modMsg.MethodRates = []types.MethodRate{types.NewMethodRate("debug_*", 20), types.NewMethodRate("eth_getLogs", 5)}
+	require.NoError(t, modMsg.ValidateBasic())
+	require.NoError(t, s.ModProviderHandle(ctx, modMsg))
+	provider, err = k.GetProvider(ctx, providerPubKey, service)
+	require.NoError(t, err)
+	require.Equal(t, modMsg.MethodRates, provider.MethodRates)
+
+	clientPubKey := types.GetRandomPubKey()
+	clientAcct, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, clientAcct, getCoin(common.Tokens(10))))
+	require.NoError(t, s.OpenContractHandle(ctx, &types.MsgOpenContract{
+		Provider:         providerPubKey,
+		Service:          service.String(),
+		Creator:          clientAcct,
+		Client:           clientPubKey,
+		ContractType:     types.ContractType_PAY_AS_YOU_GO,
+		Duration:         100,
+		Rate:             cosmos.NewInt64Coin("uarkeo", 15),
+		Deposit:          cosmos.NewInt(1000),
+		QueriesPerMinute: 1,
+	}))
+
+	// the contract keeps the rate schedule it was opened with
+	modMsg.MethodRates = []types.MethodRate{types.NewMethodRate("debug_*", 100)}
+	require.NoError(t, s.ModProviderHandle(ctx, modMsg))
+	contract, err := k.GetActiveContractForUser(ctx, clientPubKey, providerPubKey, service)
+	require.NoError(t, err)
+	require.Equal(t, []types.MethodRate{types.NewMethodRate("debug_*", 20), types.NewMethodRate("eth_getLogs", 5)}, contract.MethodRates)
+}
+
 func TestOpenContract(t *testing.T) {
 	ctx, k, sk := SetupKeeperWithStaking(t)
 	ctx = ctx.WithBlockHeight(10)
@@ -355,3 +402,285 @@ func TestOpenContractWithSettlementPeriod(t *testing.T) {
 	_, err = s.ClaimContractIncome(ctx, &claimMsg)
 	require.ErrorIs(t, err, types.ErrClaimContractIncomeClosed)
 }
+
+func TestContractCost(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	service := common.BTCService
+	provider := types.NewProvider(providerPubKey, service)
+	provider.Bond = cosmos.NewInt(500_00000000)
+	provider.Status = types.ProviderStatus_ONLINE
+	provider.MaxContractDuration = 1000
+	provider.MinContractDuration = 10
+	provider.SubscriptionRate = cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 15))
+	provider.PayAsYouGoRate = cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 2))
+	provider.LastUpdate = ctx.BlockHeight()
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	req := &types.QueryContractCostRequest{
+		Provider:         providerPubKey.String(),
+		Service:          service.String(),
+		ContractType:     types.ContractType_SUBSCRIPTION,
+		Duration:         100,
+		Rate:             cosmos.NewInt64Coin("uarkeo", 15),
+		QueriesPerMinute: 2,
+	}
+	res, err := k.ContractCost(ctx, req)
+	require.NoError(t, err)
+	openCost := getCoin(configs.GetConfigValues(k.GetVersion(ctx)).GetInt64Value(configs.OpenContractCost))
+	require.Equal(t, openCost, res.OpenCost)
+	require.Equal(t, cosmos.NewInt64Coin("uarkeo", 15*100*2), res.Deposit)
+
+	// opening the contract charges what was quoted
+	clientPubKey := types.GetRandomPubKey()
+	clientAddress, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, clientAddress, getCoin(common.Tokens(10))))
+	before := k.GetBalance(ctx, clientAddress).AmountOf("uarkeo")
+	msg := types.MsgOpenContract{
+		Provider:         providerPubKey,
+		Service:          service.String(),
+		Creator:          clientAddress,
+		Client:           clientPubKey,
+		ContractType:     req.ContractType,
+		Duration:         req.Duration,
+		Rate:             req.Rate,
+		Deposit:          res.Deposit.Amount.AddRaw(1),
+		QueriesPerMinute: req.QueriesPerMinute,
+	}
+	_, err = s.OpenContract(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrOpenContractMismatchRate)
+	msg.Deposit = res.Deposit.Amount
+	_, err = s.OpenContract(ctx, &msg)
+	require.NoError(t, err)
+	require.Equal(t, res.OpenCost.Amount.Add(res.Deposit.Amount), before.Sub(k.GetBalance(ctx, clientAddress).AmountOf("uarkeo")))
+
+	// the deposit of a pay-as-you-go contract is up to the client
+	req.ContractType = types.ContractType_PAY_AS_YOU_GO
+	req.Rate = cosmos.NewInt64Coin("uarkeo", 2)
+	res, err = k.ContractCost(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, openCost, res.OpenCost)
+	require.True(t, res.Deposit.IsZero())
+
+	// the same checks as on open apply
+	req.Rate = cosmos.NewInt64Coin("uarkeo", 3)
+	_, err = k.ContractCost(ctx, req)
+	require.ErrorContains(t, err, types.ErrOpenContractMismatchRate.Error())
+	req.Service = common.ETHService.String()
+	_, err = k.ContractCost(ctx, req)
+	require.ErrorContains(t, err, types.ErrProviderNotFound.Error())
+	req.Service = service.String()
+	req.BundleId = 1
+	_, err = k.ContractCost(ctx, req)
+	require.ErrorContains(t, err, types.ErrBundleNotFound.Error())
+}
+
+func TestOpenContractDepositDenom(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	providerAcct, err := providerPubKey.GetMyAddress()
+	require.NoError(t, err)
+	service := common.BTCService
+	provider := types.NewProvider(providerPubKey, service)
+	provider.Bond = cosmos.NewInt(10000000000)
+	provider.LastUpdate = ctx.BlockHeight()
+	require.NoError(t, k.SetProvider(ctx, provider))
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	require.NoError(t, s.ModProviderHandle(ctx, &types.MsgModProvider{
+		Provider:            providerPubKey,
+		Service:             service.String(),
+		MinContractDuration: 10,
+		MaxContractDuration: 500,
+		Status:              types.ProviderStatus_ONLINE,
+		PayAsYouGoRate:      rates,
+		SubscriptionRate:    rates,
+	}))
+
+	usdc := "ibc/498A0751C798A0D9A389AA3691123DADA57DAA4FE165D5C75894505B876BA6E4"
+	clientPubKey := types.GetRandomPubKey()
+	clientAcct, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, clientAcct, cosmos.NewInt64Coin(usdc, common.Tokens(10))))
+
+	msg := types.MsgOpenContract{
+		Provider:         providerPubKey,
+		Service:          service.String(),
+		Creator:          clientAcct,
+		Client:           clientPubKey,
+		ContractType:     types.ContractType_SUBSCRIPTION,
+		Duration:         100,
+		Rate:             cosmos.NewInt64Coin(configs.Denom, 15),
+		Deposit:          cosmos.NewInt(1500),
+		QueriesPerMinute: 1,
+		DepositDenom:     usdc,
+	}
+
+	// the denom isn't accepted until it is set in the params
+	_, err = s.OpenContract(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrOpenContractDepositDenom)
+
+	params := types.DefaultParams()
+	params.DepositDenoms = []types.DepositDenom{{Denom: usdc, Rate: cosmos.NewDecWithPrec(25, 1)}}
+	require.NoError(t, params.Validate())
+	k.SetParams(ctx, params)
+	got := k.GetParams(ctx).DepositDenoms
+	require.Len(t, got, 1)
+	require.Equal(t, usdc, got[0].Denom)
+	require.True(t, got[0].Rate.Equal(cosmos.NewDecWithPrec(25, 1)))
+
+	// the reserve doesn't hold the native deposit yet
+	_, err = s.OpenContract(ctx, &msg)
+	require.Error(t, err)
+
+	require.NoError(t, k.MintToModule(ctx, types.ReserveName, cosmos.NewInt64Coin(configs.Denom, 5000)))
+	reserve := k.GetBalanceOfModule(ctx, types.ReserveName, configs.Denom)
+	escrow := k.GetBalanceOfModule(ctx, types.ContractName, configs.Denom)
+	_, err = s.OpenContract(ctx, &msg)
+	require.NoError(t, err)
+
+	// the client paid the reserve the open cost and the deposit in usdc, at
+	// 2.5uarkeo per usdc, the reserve escrowed the native deposit
+	fee := k.OpenContractFee(ctx).Amount
+	paid := fee.AddRaw(1500).MulRaw(2).QuoRaw(5)
+	contract, err := k.GetActiveContractForUser(ctx, clientPubKey, providerPubKey, service)
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt(1500), contract.Deposit)
+	require.Equal(t, configs.Denom, contract.Rate.Denom)
+	require.Equal(t, cosmos.NewInt(common.Tokens(10)).Sub(paid), k.GetBalance(ctx, clientAcct).AmountOf(usdc))
+	require.Equal(t, paid, k.GetBalanceOfModule(ctx, types.ReserveName, usdc))
+	require.Equal(t, reserve.SubRaw(1500), k.GetBalanceOfModule(ctx, types.ReserveName, configs.Denom))
+	require.Equal(t, escrow.AddRaw(1500), k.GetBalanceOfModule(ctx, types.ContractName, configs.Denom))
+
+	// a rate update mid-contract doesn't change the contract, it is settled
+	// in the native denom
+	params.DepositDenoms[0].Rate = cosmos.NewDec(5)
+	k.SetParams(ctx, params)
+	require.NoError(t, mgr.ContractEndBlock(ctx.WithBlockHeight(110)))
+	contract, err = k.GetContract(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt(1500), contract.Deposit)
+	require.Equal(t, cosmos.NewInt(1500), contract.Paid)
+	require.True(t, k.GetBalance(ctx, providerAcct).AmountOf(configs.Denom).IsPositive())
+	require.True(t, k.GetBalance(ctx, providerAcct).AmountOf(usdc).IsZero())
+
+	// the new rate applies to the contracts opened afterwards
+	ctx = ctx.WithBlockHeight(111)
+	msg.Duration = 10
+	msg.Deposit = cosmos.NewInt(150)
+	_, err = s.OpenContract(ctx, &msg)
+	require.NoError(t, err)
+	paid = paid.Add(fee.AddRaw(150).QuoRaw(5))
+	require.Equal(t, cosmos.NewInt(common.Tokens(10)).Sub(paid), k.GetBalance(ctx, clientAcct).AmountOf(usdc))
+
+	// only an accepted denom can pay the deposit
+	msg.DepositDenom = "ibc/0000000000000000000000000000000000000000000000000000000000000000"
+	_, err = s.OpenContract(ctx.WithBlockHeight(200), &msg)
+	require.ErrorIs(t, err, types.ErrOpenContractDepositDenom)
+}
+
+func TestOpenContractMinDeposit(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	service := common.BTCService
+	provider := types.NewProvider(providerPubKey, service)
+	provider.Bond = cosmos.NewInt(10000000000)
+	provider.LastUpdate = ctx.BlockHeight()
+	require.NoError(t, k.SetProvider(ctx, provider))
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	modProvider := types.MsgModProvider{
+		Provider:            providerPubKey,
+		Service:             service.String(),
+		MinContractDuration: 10,
+		MaxContractDuration: 500,
+		Status:              types.ProviderStatus_ONLINE,
+		PayAsYouGoRate:      rates,
+		SubscriptionRate:    rates,
+	}
+	require.NoError(t, s.ModProviderHandle(ctx, &modProvider))
+
+	clientPubKey := types.GetRandomPubKey()
+	clientAcct, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, clientAcct, getCoin(common.Tokens(10))))
+
+	msg := types.MsgOpenContract{
+		Provider:         providerPubKey,
+		Service:          service.String(),
+		Creator:          clientAcct,
+		Client:           clientPubKey,
+		ContractType:     types.ContractType_PAY_AS_YOU_GO,
+		Duration:         100,
+		Rate:             cosmos.NewInt64Coin(configs.Denom, 15),
+		Deposit:          cosmos.NewInt(1),
+		QueriesPerMinute: 1,
+	}
+
+	// no floor by default
+	require.True(t, k.GetParams(ctx).MinContractDeposit.IsZero())
+	require.NoError(t, s.OpenContractValidate(ctx, &msg))
+
+	// the deposit must reach the floor of the module
+	params := types.DefaultParams()
+	params.MinContractDeposit = cosmos.NewInt(1000)
+	require.NoError(t, params.Validate())
+	k.SetParams(ctx, params)
+	err = s.OpenContractValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrOpenContractMinDeposit)
+	msg.Deposit = cosmos.NewInt(999)
+	err = s.OpenContractValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrOpenContractMinDeposit)
+	msg.Deposit = cosmos.NewInt(1000)
+	require.NoError(t, s.OpenContractValidate(ctx, &msg))
+
+	// the provider can't require less than the floor
+	modProvider.MinDeposit = cosmos.NewInt(500)
+	require.NoError(t, modProvider.ValidateBasic())
+	err = s.ModProviderValidate(ctx, &modProvider)
+	require.ErrorIs(t, err, types.ErrInvalidModProviderMinDeposit)
+	modProvider.MinDeposit = cosmos.NewInt(-1)
+	require.ErrorIs(t, modProvider.ValidateBasic(), types.ErrInvalidModProviderMinDeposit)
+
+	// but can require more
+	modProvider.MinDeposit = cosmos.NewInt(3000)
+	require.NoError(t, s.ModProviderValidate(ctx, &modProvider))
+	require.NoError(t, s.ModProviderHandle(ctx, &modProvider))
+	provider, err = k.GetProvider(ctx, providerPubKey, service)
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt(3000), provider.MinDeposit)
+	require.Equal(t, cosmos.NewInt(3000), provider.ContractMinDeposit(k.GetParams(ctx).MinContractDeposit))
+	err = s.OpenContractValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrOpenContractMinDeposit)
+	msg.Deposit = cosmos.NewInt(2999)
+	err = s.OpenContractValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrOpenContractMinDeposit)
+
+	// a deposit equal to the minimum opens the contract
+	msg.Deposit = cosmos.NewInt(3000)
+	_, err = s.OpenContract(ctx, &msg)
+	require.NoError(t, err)
+	contract, err := k.GetActiveContractForUser(ctx, clientPubKey, providerPubKey, service)
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt(3000), contract.Deposit)
+
+	// a floor raised above the override of the provider applies
+	params.MinContractDeposit = cosmos.NewInt(5000)
+	k.SetParams(ctx, params)
+	require.Equal(t, cosmos.NewInt(5000), provider.ContractMinDeposit(k.GetParams(ctx).MinContractDeposit))
+
+	// a negative floor is refused
+	params.MinContractDeposit = cosmos.NewInt(-1)
+	require.Error(t, params.Validate())
+}
@@ -0,0 +1,93 @@
+package keeper
+
+import (
+	"context"
+
+	"cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func (k msgServer) ReportProvider(goCtx context.Context, msg *types.MsgReportProvider) (*types.MsgReportProviderResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+
+	ctx.Logger().Info(
+		"receive MsgReportProvider",
+		"contract_id", msg.ContractId,
+		"reason", msg.Reason,
+	)
+
+	cacheCtx, commit := ctx.CacheContext()
+	if err := k.ReportProviderValidate(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed report provider validation", "err", err)
+		return nil, err
+	}
+
+	strikes, err := k.ReportProviderHandle(cacheCtx, msg)
+	if err != nil {
+		ctx.Logger().Error("failed report provider handle", "err", err)
+		return nil, err
+	}
+
+	commit()
+
+	return &types.MsgReportProviderResponse{Strikes: strikes}, nil
+}
+
+func (k msgServer) ReportProviderValidate(ctx cosmos.Context, msg *types.MsgReportProvider) error {
+	if k.FetchConfig(ctx, configs.HandlerReportProvider) > 0 {
+		return errors.Wrapf(types.ErrDisabledHandler, "report provider")
+	}
+
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+	if contract.IsEmpty() {
+		return errors.Wrapf(types.ErrContractNotFound, "id: %d", msg.ContractId)
+	}
+
+	// only the client of an open contract can report its provider
+	client, err := contract.Client.GetMyAddress()
+	if err != nil {
+		return err
+	}
+	if !msg.MustGetSigner().Equals(client) {
+		return errors.Wrapf(types.ErrReportProviderUnauthorized, "only the client of the contract can report the provider")
+	}
+	if !contract.IsOpen(ctx.BlockHeight()) {
+		return errors.Wrapf(types.ErrReportProviderUnauthorized, "contract %d is not open", contract.Id)
+	}
+
+	if !k.ProviderExists(ctx, contract.Provider, contract.Service) {
+		return errors.Wrapf(types.ErrProviderNotFound, "provider %s for service %s not found", contract.Provider, contract.Service)
+	}
+
+	if k.HasProviderReport(ctx, contract.Provider, contract.Client) {
+		return errors.Wrapf(types.ErrReportProviderAlreadyReported, "provider %s reported by %s in block %d", contract.Provider, contract.Client, ctx.BlockHeight())
+	}
+
+	return nil
+}
+
+func (k msgServer) ReportProviderHandle(ctx cosmos.Context, msg *types.MsgReportProvider) (uint64, error) {
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return 0, err
+	}
+
+	provider, err := k.GetProvider(ctx, contract.Provider, contract.Service)
+	if err != nil {
+		return 0, err
+	}
+	provider.Strikes++
+	if err := k.SetProvider(ctx, provider); err != nil {
+		return 0, err
+	}
+	k.SetProviderReport(ctx, contract.Provider, contract.Client)
+
+	return provider.Strikes, k.EmitProviderStrikeEvent(ctx, &provider, &contract, msg.Reason)
+}
@@ -0,0 +1,109 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestReportProvider(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(14)
+	s := newMsgServer(k, sk)
+
+	// setup
+	providerPubKey := types.GetRandomPubKey()
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(20000000000)
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	newContract := func(id uint64) (types.Contract, cosmos.AccAddress) {
+		clientPubKey := types.GetRandomPubKey()
+		clientAcct, err := clientPubKey.GetMyAddress()
+		require.NoError(t, err)
+		contract := types.NewContract(providerPubKey, common.BTCService, clientPubKey)
+		contract.Rate = cosmos.NewInt64Coin(configs.Denom, 10)
+		contract.Duration = 100
+		contract.Height = 10
+		contract.Id = id
+		require.NoError(t, k.SetContract(ctx, contract))
+		return contract, clientAcct
+	}
+	contract1, client1 := newContract(1)
+	contract2, client2 := newContract(2)
+
+	strikes := func() uint64 {
+		provider, err := k.GetProvider(ctx, providerPubKey, common.BTCService)
+		require.NoError(t, err)
+		return provider.Strikes
+	}
+
+	// only the client of the contract can report the provider
+	msg := types.NewMsgReportProvider(client2, contract1.Id, "stale blocks")
+	err := s.ReportProviderValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrReportProviderUnauthorized)
+
+	// unknown contract
+	msg = types.NewMsgReportProvider(client1, 50, "stale blocks")
+	err = s.ReportProviderValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrContractNotFound)
+
+	// happy path
+	msg = types.NewMsgReportProvider(client1, contract1.Id, "stale blocks")
+	res, err := s.ReportProvider(ctx, msg)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.Strikes)
+	require.Equal(t, uint64(1), strikes())
+
+	var evt *types.EventProviderStrike
+	for _, e := range ctx.EventManager().Events() {
+		if e.Type != types.EventTypeProviderStrike {
+			continue
+		}
+		require.Nil(t, evt, "a single strike event is expected")
+		parsed, err := sdk.ParseTypedEvent(abci.Event(e))
+		require.NoError(t, err)
+		evt = parsed.(*types.EventProviderStrike)
+	}
+	require.NotNil(t, evt)
+	require.Equal(t, providerPubKey, evt.Provider)
+	require.Equal(t, common.BTCService.String(), evt.Service)
+	require.Equal(t, contract1.Id, evt.ContractId)
+	require.Equal(t, contract1.Client, evt.Reporter)
+	require.Equal(t, "stale blocks", evt.Reason)
+	require.Equal(t, uint64(1), evt.Strikes)
+
+	// the same client cannot report the provider again in the same block
+	_, err = s.ReportProvider(ctx, msg)
+	require.ErrorIs(t, err, types.ErrReportProviderAlreadyReported)
+	require.Equal(t, uint64(1), strikes())
+
+	// another client can
+	_, err = s.ReportProvider(ctx, types.NewMsgReportProvider(client2, contract2.Id, "wrong chain id"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), strikes())
+
+	// reports are cleared at the end of the block
+	k.RemoveProviderReports(ctx, ctx.BlockHeight())
+	res, err = s.ReportProvider(ctx, msg)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), res.Strikes)
+
+	// and in the next block
+	ctx = ctx.WithBlockHeight(15)
+	res, err = s.ReportProvider(ctx, msg)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), res.Strikes)
+
+	// a closed contract cannot be used to report the provider
+	ctx = ctx.WithBlockHeight(contract1.Expiration() + 1)
+	err = s.ReportProviderValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrReportProviderUnauthorized)
+}
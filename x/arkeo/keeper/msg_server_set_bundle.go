@@ -0,0 +1,93 @@
+package keeper
+
+import (
+	"context"
+
+	"cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func (k msgServer) SetBundle(goCtx context.Context, msg *types.MsgSetBundle) (*types.MsgSetBundleResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+
+	ctx.Logger().Info(
+		"receive MsgSetBundle",
+		"provider", msg.Provider,
+		"id", msg.Id,
+		"name", msg.Name,
+		"services", msg.Services,
+		"subscription rate", msg.SubscriptionRate,
+		"pay-as-you-go rate", msg.PayAsYouGoRate,
+	)
+
+	cacheCtx, commit := ctx.CacheContext()
+	if err := k.SetBundleValidate(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed set bundle validation", "err", err)
+		return nil, err
+	}
+
+	id, err := k.SetBundleHandle(cacheCtx, msg)
+	if err != nil {
+		ctx.Logger().Error("failed set bundle handle", "err", err)
+		return nil, err
+	}
+
+	commit()
+
+	return &types.MsgSetBundleResponse{Id: id}, nil
+}
+
+func (k msgServer) SetBundleValidate(ctx cosmos.Context, msg *types.MsgSetBundle) error {
+	if k.FetchConfig(ctx, configs.HandlerSetBundle) > 0 {
+		return errors.Wrapf(types.ErrDisabledHandler, "set bundle")
+	}
+
+	services, err := msg.ParseServices()
+	if err != nil {
+		return err
+	}
+	for _, service := range services {
+		if !k.ProviderExists(ctx, msg.Provider, service) {
+			return errors.Wrapf(types.ErrProviderNotFound, "provider %s for service %s not found", msg.Provider, service)
+		}
+	}
+
+	if msg.Id > 0 {
+		bundle, err := k.GetBundle(ctx, msg.Id)
+		if err != nil {
+			return err
+		}
+		if bundle.Id == 0 {
+			return errors.Wrapf(types.ErrBundleNotFound, "bundle %d not found", msg.Id)
+		}
+		if !bundle.Provider.Equals(msg.Provider) {
+			return errors.Wrapf(types.ErrInvalidBundle, "bundle %d belongs to provider %s", msg.Id, bundle.Provider)
+		}
+	}
+
+	return nil
+}
+
+func (k msgServer) SetBundleHandle(ctx cosmos.Context, msg *types.MsgSetBundle) (uint64, error) {
+	services, err := msg.ParseServices()
+	if err != nil {
+		return 0, err
+	}
+
+	bundle := types.NewBundle(msg.Provider, msg.Name, services)
+	bundle.Id = msg.Id
+	if bundle.Id == 0 {
+		bundle.Id = k.GetAndIncrementNextBundleId(ctx)
+	}
+	bundle.SubscriptionRate = msg.SubscriptionRate
+	bundle.PayAsYouGoRate = msg.PayAsYouGoRate
+
+	if err := k.SetBundle(ctx, bundle); err != nil {
+		return 0, err
+	}
+	return bundle.Id, k.EmitSetBundleEvent(ctx, &bundle)
+}
@@ -0,0 +1,316 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestSetBundle(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	acc, err := providerPubKey.GetMyAddress()
+	require.NoError(t, err)
+	rates, err := cosmos.ParseCoins("25uarkeo")
+	require.NoError(t, err)
+
+	msg := types.NewMsgSetBundle(acc, providerPubKey, 0, "bitcoin and ethereum", []string{common.BTCService.String(), common.ETHService.String()}, rates, rates)
+
+	// the provider must serve every service of the bundle
+	err = s.SetBundleValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrProviderNotFound)
+	provider := types.NewProvider(providerPubKey, common.BTCService)
+	provider.Bond = cosmos.NewInt(20000000000)
+	require.NoError(t, k.SetProvider(ctx, provider))
+	err = s.SetBundleValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrProviderNotFound)
+	provider.Service = common.ETHService
+	require.NoError(t, k.SetProvider(ctx, provider))
+	require.NoError(t, s.SetBundleValidate(ctx, msg))
+
+	// publish the bundle
+	res, err := s.SetBundle(ctx, msg)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.Id)
+	bundle, err := k.GetBundle(ctx, res.Id)
+	require.NoError(t, err)
+	require.Equal(t, msg.Name, bundle.Name)
+	require.Equal(t, common.Services{common.BTCService, common.ETHService}, common.Services(bundle.Services))
+	require.Equal(t, uint64(2), k.GetNextBundleId(ctx))
+
+	// update the bundle
+	msg.Id = res.Id
+	msg.Name = "updated"
+	res, err = s.SetBundle(ctx, msg)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res.Id)
+	bundle, err = k.GetBundle(ctx, res.Id)
+	require.NoError(t, err)
+	require.Equal(t, "updated", bundle.Name)
+	require.Equal(t, uint64(2), k.GetNextBundleId(ctx))
+
+	// unknown bundle
+	msg.Id = 50
+	err = s.SetBundleValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrBundleNotFound)
+
+	// another provider cannot update the bundle
+	otherPubKey := types.GetRandomPubKey()
+	otherAcc, err := otherPubKey.GetMyAddress()
+	require.NoError(t, err)
+	provider.PubKey = otherPubKey
+	require.NoError(t, k.SetProvider(ctx, provider))
+	provider.Service = common.BTCService
+	require.NoError(t, k.SetProvider(ctx, provider))
+	other := types.NewMsgSetBundle(otherAcc, otherPubKey, 1, "stolen", msg.Services, rates, rates)
+	err = s.SetBundleValidate(ctx, other)
+	require.ErrorIs(t, err, types.ErrInvalidBundle)
+
+	// the bundles are listed by provider
+	require.NoError(t, k.SetBundle(ctx, types.Bundle{
+		Provider: otherPubKey,
+		Id:       k.GetAndIncrementNextBundleId(ctx),
+		Name:     "ethereum only",
+		Services: []common.Service{common.ETHService},
+	}))
+	bundles, err := k.ProviderBundles(sdk.WrapSDKContext(ctx), &types.QueryProviderBundlesRequest{Pubkey: providerPubKey.String()})
+	require.NoError(t, err)
+	require.Len(t, bundles.Bundles, 1)
+	require.Equal(t, "updated", bundles.Bundles[0].Name)
+	bundles, err = k.ProviderBundles(sdk.WrapSDKContext(ctx), &types.QueryProviderBundlesRequest{Pubkey: otherPubKey.String()})
+	require.NoError(t, err)
+	require.Len(t, bundles.Bundles, 1)
+	require.Equal(t, uint64(2), bundles.Bundles[0].Id)
+	bundles, err = k.ProviderBundles(sdk.WrapSDKContext(ctx), &types.QueryProviderBundlesRequest{Pubkey: types.GetRandomPubKey().String()})
+	require.NoError(t, err)
+	require.Empty(t, bundles.Bundles)
+}
+
+func TestBundleContract(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	// create a provider for 3 services, two of them bundled
+	providerPubKey := types.GetRandomPubKey()
+	providerAddress, err := providerPubKey.GetMyAddress()
+	require.NoError(t, err)
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	bundleRates, err := cosmos.ParseCoins("25uarkeo")
+	require.NoError(t, err)
+	for _, service := range []common.Service{common.BTCService, common.ETHService, common.MockService} {
+		provider := types.NewProvider(providerPubKey, service)
+		provider.Bond = cosmos.NewInt(20000000000)
+		provider.LastUpdate = ctx.BlockHeight()
+		provider.Status = types.ProviderStatus_ONLINE
+		provider.MinContractDuration = 10
+		provider.MaxContractDuration = 500
+		provider.SubscriptionRate = rates
+		provider.PayAsYouGoRate = rates
+		require.NoError(t, k.SetProvider(ctx, provider))
+	}
+
+	res, err := s.SetBundle(ctx, types.NewMsgSetBundle(providerAddress, providerPubKey, 0, "btc+eth", []string{common.BTCService.String(), common.ETHService.String()}, bundleRates, bundleRates))
+	require.NoError(t, err)
+
+	// open a contract on the bundle
+	clientPubKey := types.GetRandomPubKey()
+	clientAddress, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, clientAddress, getCoin(common.Tokens(10))))
+
+	msg := types.MsgOpenContract{
+		Provider:     providerPubKey,
+		BundleId:     res.Id,
+		Creator:      clientAddress,
+		Client:       clientPubKey,
+		ContractType: types.ContractType_PAY_AS_YOU_GO,
+		Duration:     100,
+		Rate:         rates[0],
+		Deposit:      cosmos.NewInt(2500),
+	}
+
+	// the rate of the bundle applies, not the rate of its services
+	err = s.OpenContractValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrOpenContractMismatchRate)
+	msg.Rate = bundleRates[0]
+
+	// unknown bundle
+	msg.BundleId = 50
+	err = s.OpenContractValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrBundleNotFound)
+	msg.BundleId = res.Id
+
+	// every service of the bundle must be online
+	provider, err := k.GetProvider(ctx, providerPubKey, common.ETHService)
+	require.NoError(t, err)
+	provider.Status = types.ProviderStatus_OFFLINE
+	require.NoError(t, k.SetProvider(ctx, provider))
+	err = s.OpenContractValidate(ctx, &msg)
+	require.ErrorIs(t, err, types.ErrOpenContractBadProviderStatus)
+	provider.Status = types.ProviderStatus_ONLINE
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	_, err = s.OpenContract(ctx, &msg)
+	require.NoError(t, err)
+
+	// the contract serves both services of the bundle, and only those
+	btc, err := k.GetActiveContractForUser(ctx, clientPubKey, providerPubKey, common.BTCService)
+	require.NoError(t, err)
+	require.False(t, btc.IsEmpty())
+	require.True(t, btc.IsBundle())
+	require.Equal(t, res.Id, btc.BundleId)
+	eth, err := k.GetActiveContractForUser(ctx, clientPubKey, providerPubKey, common.ETHService)
+	require.NoError(t, err)
+	require.Equal(t, btc.Id, eth.Id)
+	mock, err := k.GetActiveContractForUser(ctx, clientPubKey, providerPubKey, common.MockService)
+	require.NoError(t, err)
+	require.True(t, mock.IsEmpty())
+
+	// a contract for a service of the bundle is already open
+	single := msg
+	single.BundleId = 0
+	single.Service = common.ETHService.String()
+	single.Rate = rates[0]
+	single.Deposit = cosmos.NewInt(1500)
+	err = s.OpenContractValidate(ctx, &single)
+	require.ErrorIs(t, err, types.ErrOpenContractAlreadyOpen)
+
+	// settle the contract, queries across both services are paid at the
+	// bundle rate
+	contract, err := mgr.SettleContract(ctx, btc, 40, true)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), contract.Paid.Int64())
+	require.Equal(t, int64(900), k.GetBalance(ctx, providerAddress).AmountOf(configs.Denom).Int64())
+	// the client is refunded the unused deposit
+	require.Equal(t, common.Tokens(10)-common.Tokens(1)-1000, k.GetBalance(ctx, clientAddress).AmountOf(configs.Denom).Int64())
+}
+
+func TestBundleProviderRecords(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	params := types.DefaultParams()
+	params.DowntimeAttestationThreshold = 1
+	params.DowntimeAttestationWindow = 50
+	params.DowntimeAttestationInterval = 10
+	params.DowntimeSlashBasisPoints = 1000
+	k.SetParams(ctx, params)
+
+	// the records of the services of the bundle have their own terms
+	providerPubKey := types.GetRandomPubKey()
+	providerAddress, err := providerPubKey.GetMyAddress()
+	require.NoError(t, err)
+	bundleRates, err := cosmos.ParseCoins("25uarkeo")
+	require.NoError(t, err)
+	acc1 := types.GetRandomBech32Addr()
+	acc2 := types.GetRandomBech32Addr()
+	for _, service := range []common.Service{common.BTCService, common.ETHService} {
+		provider := types.NewProvider(providerPubKey, service)
+		provider.Bond = cosmos.NewInt(100_000)
+		provider.LastUpdate = ctx.BlockHeight()
+		provider.Status = types.ProviderStatus_ONLINE
+		provider.MinContractDuration = 10
+		provider.MaxContractDuration = 500
+		provider.SubscriptionRate = bundleRates
+		provider.PayAsYouGoRate = bundleRates
+		switch service {
+		case common.BTCService:
+			provider.SettlementDuration = 10
+			provider.MethodRates = []types.MethodRate{types.NewMethodRate("debug_*", 20), types.NewMethodRate("eth_getLogs", 5)}
+			provider.PayoutSplits = []types.PayoutSplit{types.NewPayoutSplit(acc1, 7000), types.NewPayoutSplit(acc2, 3000)}
+		case common.ETHService:
+			provider.SettlementDuration = 30
+			provider.MethodRates = []types.MethodRate{types.NewMethodRate("eth_getLogs", 8)}
+		}
+		require.NoError(t, k.SetProvider(ctx, provider))
+	}
+	require.NoError(t, k.MintToModule(ctx, types.ModuleName, getCoin(200_000)))
+	require.NoError(t, k.SendFromModuleToModule(ctx, types.ModuleName, types.ProviderName, getCoins(200_000)))
+
+	res, err := s.SetBundle(ctx, types.NewMsgSetBundle(providerAddress, providerPubKey, 0, "btc+eth", []string{common.BTCService.String(), common.ETHService.String()}, bundleRates, bundleRates))
+	require.NoError(t, err)
+
+	openContract := func() (types.Contract, cosmos.AccAddress) {
+		clientPubKey := types.GetRandomPubKey()
+		clientAddress, err := clientPubKey.GetMyAddress()
+		require.NoError(t, err)
+		require.NoError(t, k.MintAndSendToAccount(ctx, clientAddress, getCoin(common.Tokens(10))))
+		msg := types.MsgOpenContract{
+			Provider:     providerPubKey,
+			BundleId:     res.Id,
+			Creator:      clientAddress,
+			Client:       clientPubKey,
+			ContractType: types.ContractType_PAY_AS_YOU_GO,
+			Duration:     100,
+			Rate:         bundleRates[0],
+			Deposit:      cosmos.NewInt(2500),
+		}
+
+		// the bundle is settled after the longest settlement duration of its
+		// services
+		msg.SettlementDuration = 10
+		err = s.OpenContractValidate(ctx, &msg)
+		require.ErrorIs(t, err, types.ErrOpenContractMismatchSettlementDuration)
+		msg.SettlementDuration = 30
+		_, err = s.OpenContract(ctx, &msg)
+		require.NoError(t, err)
+
+		contract, err := k.GetActiveContractForUser(ctx, clientPubKey, providerPubKey, common.BTCService)
+		require.NoError(t, err)
+		require.True(t, contract.IsBundle())
+		return contract, clientAddress
+	}
+	contract1, client1 := openContract()
+	contract2, client2 := openContract()
+	require.Equal(t, int64(30), contract1.SettlementDuration)
+
+	// the rate schedules of the services are merged
+	require.Equal(t, []types.MethodRate{types.NewMethodRate("debug_*", 20), types.NewMethodRate("eth_getLogs", 8)}, contract1.MethodRates)
+
+	// the income is shared by the services, each share is paid by the payout
+	// splits of the record of its service
+	contract, err := mgr.SettleContract(ctx, contract1, 40, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), contract.Paid.Int64())
+	require.Equal(t, int64(315), k.GetBalance(ctx, acc1).AmountOf(configs.Denom).Int64())
+	require.Equal(t, int64(135), k.GetBalance(ctx, acc2).AmountOf(configs.Denom).Int64())
+	require.Equal(t, int64(450), k.GetBalance(ctx, providerAddress).AmountOf(configs.Denom).Int64())
+
+	// the downtime is attested for a service of the bundle
+	msg := types.NewMsgAttestProviderDowntime(client1, contract1.Id, common.MockService.String())
+	err = s.AttestProviderDowntimeValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrInvalidService)
+	_, err = s.AttestProviderDowntime(ctx, types.NewMsgAttestProviderDowntime(client1, contract1.Id, common.ETHService.String()))
+	require.NoError(t, err)
+	_, err = s.AttestProviderDowntime(ctx, types.NewMsgAttestProviderDowntime(client2, contract2.Id, common.ETHService.String()))
+	require.NoError(t, err)
+	attestation, err := k.GetDowntimeAttestation(ctx, providerPubKey, common.ETHService, contract1.Id)
+	require.NoError(t, err)
+	require.Equal(t, ctx.BlockHeight(), attestation.Height)
+	attestation, err = k.GetDowntimeAttestation(ctx, providerPubKey, common.BTCService, contract1.Id)
+	require.NoError(t, err)
+	require.Zero(t, attestation.Height)
+
+	// and slashed against the record of that service only
+	require.NoError(t, mgr.DowntimeEndBlock(ctx))
+	eth, err := k.GetProvider(ctx, providerPubKey, common.ETHService)
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt(90_000), eth.Bond)
+	btc, err := k.GetProvider(ctx, providerPubKey, common.BTCService)
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt(100_000), btc.Bond)
+}
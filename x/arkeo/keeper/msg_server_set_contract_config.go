@@ -0,0 +1,93 @@
+package keeper
+
+import (
+	"context"
+
+	"cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func (k msgServer) SetContractConfig(goCtx context.Context, msg *types.MsgSetContractConfig) (*types.MsgSetContractConfigResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+
+	ctx.Logger().Info(
+		"receive MsgSetContractConfig",
+		"contract_id", msg.ContractId,
+		"per_user_rate_limit", msg.PerUserRateLimit,
+	)
+
+	cacheCtx, commit := ctx.CacheContext()
+	if err := k.SetContractConfigValidate(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed set contract config validation", "err", err)
+		return nil, err
+	}
+
+	if err := k.SetContractConfigHandle(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed set contract config handle", "err", err)
+		return nil, err
+	}
+
+	commit()
+
+	return &types.MsgSetContractConfigResponse{}, nil
+}
+
+func (k msgServer) SetContractConfigValidate(ctx cosmos.Context, msg *types.MsgSetContractConfig) error {
+	if k.FetchConfig(ctx, configs.HandlerSetContractConfig) > 0 {
+		return errors.Wrapf(types.ErrDisabledHandler, "set contract config")
+	}
+
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+	if contract.IsEmpty() {
+		return errors.Wrapf(types.ErrContractNotFound, "id: %d", msg.ContractId)
+	}
+	if contract.IsExpired(ctx.BlockHeight()) {
+		return errors.Wrapf(types.ErrContractConfigUnauthorized, "contract %d is expired", contract.Id)
+	}
+
+	// only the client of the contract, or its delegate, can configure it
+	signer := msg.MustGetSigner()
+	client, err := contract.Client.GetMyAddress()
+	if err != nil {
+		return err
+	}
+	if signer.Equals(client) {
+		return nil
+	}
+	if !contract.Delegate.IsEmpty() {
+		delegate, err := contract.Delegate.GetMyAddress()
+		if err != nil {
+			return err
+		}
+		if signer.Equals(delegate) {
+			return nil
+		}
+	}
+	return errors.Wrapf(types.ErrContractConfigUnauthorized, "only the client or the delegate of the contract can set its config")
+}
+
+func (k msgServer) SetContractConfigHandle(ctx cosmos.Context, msg *types.MsgSetContractConfig) error {
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+
+	config := types.ContractConfig{
+		ContractId:       msg.ContractId,
+		Cors:             msg.Cors,
+		WhitelistIps:     msg.WhitelistIps,
+		PerUserRateLimit: msg.PerUserRateLimit,
+	}
+	if err := k.SetContractConfig(ctx, config); err != nil {
+		return err
+	}
+
+	return k.EmitSetContractConfigEvent(ctx, &contract, &config)
+}
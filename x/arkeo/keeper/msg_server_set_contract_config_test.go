@@ -0,0 +1,94 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestSetContractConfig(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(14)
+	s := newMsgServer(k, sk)
+
+	// setup
+	providerPubKey := types.GetRandomPubKey()
+	clientPubKey := types.GetRandomPubKey()
+	clientAcct, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	delegatePubKey := types.GetRandomPubKey()
+	delegateAcct, err := delegatePubKey.GetMyAddress()
+	require.NoError(t, err)
+
+	contract := types.NewContract(providerPubKey, common.BTCService, clientPubKey)
+	contract.Delegate = delegatePubKey
+	contract.Rate = cosmos.NewInt64Coin(configs.Denom, 10)
+	contract.Duration = 100
+	contract.Height = 10
+	contract.Id = 1
+	require.NoError(t, k.SetContract(ctx, contract))
+
+	cors := types.ContractCORs{AllowOrigins: []string{"https://example.com"}}
+
+	// only the client or the delegate of the contract can set its config
+	msg := types.NewMsgSetContractConfig(types.GetRandomBech32Addr(), contract.Id, cors, nil, 60)
+	err = s.SetContractConfigValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrContractConfigUnauthorized)
+
+	// unknown contract
+	msg = types.NewMsgSetContractConfig(clientAcct, 50, cors, nil, 60)
+	err = s.SetContractConfigValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrContractNotFound)
+
+	// happy path
+	msg = types.NewMsgSetContractConfig(clientAcct, contract.Id, cors, []string{"10.0.0.1"}, 60)
+	_, err = s.SetContractConfig(ctx, msg)
+	require.NoError(t, err)
+	config, err := k.GetContractConfig(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, contract.Id, config.ContractId)
+	require.Equal(t, cors, config.Cors)
+	require.Equal(t, []string{"10.0.0.1"}, config.WhitelistIps)
+	require.Equal(t, int64(60), config.PerUserRateLimit)
+
+	var evt *types.EventSetContractConfig
+	for _, e := range ctx.EventManager().Events() {
+		if e.Type != types.EventTypeSetContractConfig {
+			continue
+		}
+		require.Nil(t, evt, "a single set contract config event is expected")
+		parsed, err := sdk.ParseTypedEvent(abci.Event(e))
+		require.NoError(t, err)
+		evt = parsed.(*types.EventSetContractConfig)
+	}
+	require.NotNil(t, evt)
+	require.Equal(t, contract.Id, evt.ContractId)
+	require.Equal(t, providerPubKey, evt.Provider)
+	require.Equal(t, common.BTCService.String(), evt.Service)
+	require.Equal(t, clientPubKey, evt.Client)
+	require.Equal(t, cors, evt.Cors)
+	require.Equal(t, []string{"10.0.0.1"}, evt.WhitelistIps)
+	require.Equal(t, int64(60), evt.PerUserRateLimit)
+
+	// the delegate replaces the config
+	msg = types.NewMsgSetContractConfig(delegateAcct, contract.Id, types.ContractCORs{}, nil, 0)
+	_, err = s.SetContractConfig(ctx, msg)
+	require.NoError(t, err)
+	config, err = k.GetContractConfig(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Empty(t, config.WhitelistIps)
+	require.Equal(t, int64(0), config.PerUserRateLimit)
+
+	// an expired contract can't be configured
+	ctx = ctx.WithBlockHeight(contract.Expiration() + 1)
+	msg = types.NewMsgSetContractConfig(clientAcct, contract.Id, cors, nil, 60)
+	err = s.SetContractConfigValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrContractConfigUnauthorized)
+}
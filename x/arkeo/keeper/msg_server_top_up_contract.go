@@ -0,0 +1,93 @@
+package keeper
+
+import (
+	"context"
+
+	"cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func (k msgServer) TopUpContract(goCtx context.Context, msg *types.MsgTopUpContract) (*types.MsgTopUpContractResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+
+	ctx.Logger().Info(
+		"receive MsgTopUpContract",
+		"contract_id", msg.ContractId,
+		"deposit", msg.Deposit,
+	)
+
+	cacheCtx, commit := ctx.CacheContext()
+	if err := k.TopUpContractValidate(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed top up contract validation", "err", err)
+		return nil, err
+	}
+
+	if err := k.TopUpContractHandle(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed top up contract handle", "err", err)
+		return nil, err
+	}
+
+	commit()
+
+	return &types.MsgTopUpContractResponse{}, nil
+}
+
+func (k msgServer) TopUpContractValidate(ctx cosmos.Context, msg *types.MsgTopUpContract) error {
+	if k.FetchConfig(ctx, configs.HandlerTopUpContract) > 0 {
+		return errors.Wrapf(types.ErrDisabledHandler, "top up contract")
+	}
+
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+	if contract.IsEmpty() {
+		return errors.Wrapf(types.ErrContractNotFound, "id: %d", msg.ContractId)
+	}
+
+	// only the client of the contract, or its delegate, can top it up
+	ok, err := isClientOrDelegate(msg.MustGetSigner(), contract)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Wrapf(types.ErrTopUpContractUnauthorized, "only the client or the delegate of the contract can top it up")
+	}
+
+	// the deposit of a subscription pays for its duration, extend it instead
+	if !contract.IsPayAsYouGo() {
+		return errors.Wrapf(types.ErrTopUpContractType, "contract %d is a %s", contract.Id, contract.Type.String())
+	}
+
+	// a contract settled, once closed or expired, can't be spent anymore
+	if contract.SettlementHeight > 0 {
+		return errors.Wrapf(types.ErrTopUpContractClosed, "contract %d settled at %d", contract.Id, contract.SettlementHeight)
+	}
+	if contract.IsExpired(ctx.BlockHeight()) {
+		return errors.Wrapf(types.ErrTopUpContractClosed, "contract %d expired at %d", contract.Id, contract.Expiration())
+	}
+
+	return nil
+}
+
+func (k msgServer) TopUpContractHandle(ctx cosmos.Context, msg *types.MsgTopUpContract) error {
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+
+	if err := k.SendFromAccountToModule(ctx, msg.MustGetSigner(), types.ContractName, cosmos.NewCoins(cosmos.NewCoin(contract.Rate.Denom, msg.Deposit))); err != nil {
+		return errors.Wrapf(err, "failed to send deposit=%d", msg.Deposit.Int64())
+	}
+
+	contract.Deposit = contract.Deposit.Add(msg.Deposit)
+	if err := k.SetContract(ctx, contract); err != nil {
+		return err
+	}
+
+	return k.EmitTopUpContractEvent(ctx, &contract, msg.Deposit)
+}
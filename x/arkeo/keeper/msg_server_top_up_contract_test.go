@@ -0,0 +1,190 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestTopUpContractValidate(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(50)
+	s := newMsgServer(k, sk)
+
+	// setup
+	providerPubKey := types.GetRandomPubKey()
+	clientPubKey := types.GetRandomPubKey()
+	clientAcct, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	delegatePubKey := types.GetRandomPubKey()
+	delegateAcct, err := delegatePubKey.GetMyAddress()
+	require.NoError(t, err)
+
+	contract := types.NewContract(providerPubKey, common.BTCService, clientPubKey)
+	contract.Delegate = delegatePubKey
+	contract.Type = types.ContractType_PAY_AS_YOU_GO
+	contract.Rate = cosmos.NewInt64Coin(configs.Denom, 15)
+	contract.QueriesPerMinute = 1
+	contract.Duration = 100
+	contract.SettlementDuration = 10
+	contract.Deposit = cosmos.NewInt(1500)
+	contract.Height = 10
+	contract.Id = 1
+	require.NoError(t, k.SetContract(ctx, contract))
+
+	// happy path, by the client or the delegate
+	msg := types.NewMsgTopUpContract(clientAcct, contract.Id, cosmos.NewInt(500))
+	require.NoError(t, s.TopUpContractValidate(ctx, msg))
+	msg.Creator = delegateAcct
+	require.NoError(t, s.TopUpContractValidate(ctx, msg))
+
+	// anybody else can't top up the contract
+	msg.Creator = types.GetRandomBech32Addr()
+	err = s.TopUpContractValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrTopUpContractUnauthorized)
+	msg.Creator = clientAcct
+
+	// unknown contract
+	msg.ContractId = 50
+	err = s.TopUpContractValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrContractNotFound)
+	msg.ContractId = contract.Id
+
+	// an expired contract can't be topped up, during its settlement period
+	// either
+	err = s.TopUpContractValidate(ctx.WithBlockHeight(111), msg)
+	require.ErrorIs(t, err, types.ErrTopUpContractClosed)
+	err = s.TopUpContractValidate(ctx.WithBlockHeight(125), msg)
+	require.ErrorIs(t, err, types.ErrTopUpContractClosed)
+
+	// the deposit of a subscription pays for its duration
+	contract.Type = types.ContractType_SUBSCRIPTION
+	require.NoError(t, k.SetContract(ctx, contract))
+	err = s.TopUpContractValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrTopUpContractType)
+}
+
+func TestTopUpContract(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	providerPubKey := types.GetRandomPubKey()
+	service := common.BTCService
+	provider := types.NewProvider(providerPubKey, service)
+	provider.Bond = cosmos.NewInt(10000000000)
+	provider.LastUpdate = ctx.BlockHeight()
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+	require.NoError(t, s.ModProviderHandle(ctx, &types.MsgModProvider{
+		Provider:            providerPubKey,
+		Service:             service.String(),
+		MinContractDuration: 10,
+		MaxContractDuration: 500,
+		Status:              types.ProviderStatus_ONLINE,
+		PayAsYouGoRate:      rates,
+		SubscriptionRate:    rates,
+	}))
+
+	clientPubKey := types.GetRandomPubKey()
+	clientAcct, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, clientAcct, getCoin(common.Tokens(10))))
+
+	_, err = s.OpenContract(sdk.WrapSDKContext(ctx), &types.MsgOpenContract{
+		Provider:         providerPubKey,
+		Service:          service.String(),
+		Creator:          clientAcct,
+		Client:           clientPubKey,
+		ContractType:     types.ContractType_PAY_AS_YOU_GO,
+		Duration:         100,
+		Rate:             cosmos.NewInt64Coin(configs.Denom, 15),
+		Deposit:          cosmos.NewInt(150),
+		QueriesPerMinute: 1,
+	})
+	require.NoError(t, err)
+	contract, err := k.GetActiveContractForUser(ctx, clientPubKey, providerPubKey, service)
+	require.NoError(t, err)
+
+	claim := func(nonce int64) types.Contract {
+		require.NoError(t, s.ClaimContractIncomeHandle(ctx, &types.MsgClaimContractIncome{
+			ContractId: contract.Id,
+			Creator:    types.GetRandomBech32Addr(),
+			Nonce:      nonce,
+		}))
+		claimed, err := k.GetContract(ctx, contract.Id)
+		require.NoError(t, err)
+		return claimed
+	}
+
+	// top up before the deposit is spent
+	ctx = ctx.WithBlockHeight(20)
+	require.Equal(t, cosmos.NewInt(75), claim(5).Paid)
+	balance := k.GetBalanceOfModule(ctx, types.ContractName, configs.Denom)
+	msg := types.NewMsgTopUpContract(clientAcct, contract.Id, cosmos.NewInt(150))
+	_, err = s.TopUpContract(sdk.WrapSDKContext(ctx), msg)
+	require.NoError(t, err)
+
+	toppedUp, err := k.GetContract(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, cosmos.NewInt(300), toppedUp.Deposit)
+	require.Equal(t, contract.Duration, toppedUp.Duration)
+	require.Equal(t, balance.AddRaw(150), k.GetBalanceOfModule(ctx, types.ContractName, configs.Denom))
+
+	var evt *types.EventTopUpContract
+	for _, e := range ctx.EventManager().Events() {
+		if e.Type != types.EventTypeTopUpContract {
+			continue
+		}
+		require.Nil(t, evt, "a single top up contract event is expected")
+		parsed, err := sdk.ParseTypedEvent(abci.Event(e))
+		require.NoError(t, err)
+		evt = parsed.(*types.EventTopUpContract)
+	}
+	require.NotNil(t, evt)
+	require.Equal(t, contract.Id, evt.ContractId)
+	require.Equal(t, providerPubKey, evt.Provider)
+	require.Equal(t, clientPubKey, evt.Client)
+	require.Equal(t, cosmos.NewInt(150), evt.Added)
+	require.Equal(t, cosmos.NewInt(300), evt.Deposit)
+
+	// the deposit is spent, the same contract keeps its id and nonce once
+	// topped up
+	ctx = ctx.WithBlockHeight(30)
+	spent := claim(20)
+	require.Equal(t, cosmos.NewInt(300), spent.Paid)
+	require.Equal(t, int64(20), spent.Nonce)
+	_, err = s.TopUpContract(sdk.WrapSDKContext(ctx), types.NewMsgTopUpContract(clientAcct, contract.Id, cosmos.NewInt(75)))
+	require.NoError(t, err)
+	claimed := claim(25)
+	require.Equal(t, cosmos.NewInt(375), claimed.Deposit)
+	require.Equal(t, cosmos.NewInt(375), claimed.Paid)
+	require.Equal(t, int64(25), claimed.Nonce)
+
+	// a closed contract can't be topped up, once settled at the end of its
+	// settlement duration
+	ctx = ctx.WithBlockHeight(40)
+	_, err = s.CloseContract(sdk.WrapSDKContext(ctx), &types.MsgCloseContract{
+		Creator:    clientAcct,
+		ContractId: contract.Id,
+	})
+	require.NoError(t, err)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	closed, err := k.GetContract(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(40), closed.SettlementHeight)
+	_, err = s.TopUpContract(sdk.WrapSDKContext(ctx), types.NewMsgTopUpContract(clientAcct, contract.Id, cosmos.NewInt(75)))
+	require.ErrorIs(t, err, types.ErrTopUpContractClosed)
+	_, err = s.TopUpContract(sdk.WrapSDKContext(ctx.WithBlockHeight(41)), types.NewMsgTopUpContract(clientAcct, contract.Id, cosmos.NewInt(75)))
+	require.ErrorIs(t, err, types.ErrTopUpContractClosed)
+}
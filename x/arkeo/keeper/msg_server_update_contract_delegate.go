@@ -0,0 +1,122 @@
+package keeper
+
+import (
+	"context"
+
+	"cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+func (k msgServer) UpdateContractDelegate(goCtx context.Context, msg *types.MsgUpdateContractDelegate) (*types.MsgUpdateContractDelegateResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+
+	ctx.Logger().Info(
+		"receive MsgUpdateContractDelegate",
+		"contract_id", msg.ContractId,
+		"delegate", msg.Delegate,
+	)
+
+	cacheCtx, commit := ctx.CacheContext()
+	if err := k.UpdateContractDelegateValidate(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed update contract delegate validation", "err", err)
+		return nil, err
+	}
+
+	if err := k.UpdateContractDelegateHandle(cacheCtx, msg); err != nil {
+		ctx.Logger().Error("failed update contract delegate handle", "err", err)
+		return nil, err
+	}
+
+	commit()
+
+	return &types.MsgUpdateContractDelegateResponse{}, nil
+}
+
+func (k msgServer) UpdateContractDelegateValidate(ctx cosmos.Context, msg *types.MsgUpdateContractDelegate) error {
+	if k.FetchConfig(ctx, configs.HandlerUpdateDelegate) > 0 {
+		return errors.Wrapf(types.ErrDisabledHandler, "update contract delegate")
+	}
+
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+	if contract.IsEmpty() {
+		return errors.Wrapf(types.ErrContractNotFound, "id: %d", msg.ContractId)
+	}
+
+	// only the client can rotate the delegate, a compromised delegate must not
+	// be able to lock the client out
+	client, err := contract.Client.GetMyAddress()
+	if err != nil {
+		return err
+	}
+	if !msg.MustGetSigner().Equals(client) {
+		return errors.Wrapf(types.ErrUpdateContractDelegateUnauthorized, "only the client of the contract can update its delegate")
+	}
+
+	if contract.IsExpired(ctx.BlockHeight()) {
+		return errors.Wrapf(types.ErrUpdateContractDelegateExpired, "contract %d expired at %d", contract.Id, contract.Expiration())
+	}
+
+	spender := contract.Client
+	if !msg.Delegate.IsEmpty() {
+		spender = msg.Delegate
+	}
+	if spender.Equals(contract.GetSpender()) {
+		return errors.Wrapf(types.ErrInvalidPubKey, "%s already spends contract %d", spender, contract.Id)
+	}
+
+	// the new spender can't hold two open contracts with the provider for a
+	// service, same as when opening one
+	for _, service := range contract.ServiceSet() {
+		activeContract, err := k.GetActiveContractForUser(ctx, spender, contract.Provider, service)
+		if err != nil {
+			return err
+		}
+		if !activeContract.IsEmpty() && !activeContract.IsExpired(ctx.BlockHeight()) {
+			return errors.Wrapf(types.ErrOpenContractAlreadyOpen, "%s expires in %d blocks", service, activeContract.Expiration()-ctx.BlockHeight())
+		}
+	}
+
+	return nil
+}
+
+func (k msgServer) UpdateContractDelegateHandle(ctx cosmos.Context, msg *types.MsgUpdateContractDelegate) error {
+	contract, err := k.GetContract(ctx, msg.ContractId)
+	if err != nil {
+		return err
+	}
+
+	// the contract is looked up by its spender, it moves to the contract set
+	// of the new one. The claims are checked against the signature of the
+	// spender, the ones signed by the old delegate are refused from now on.
+	if err := k.RemoveFromUserContractSet(ctx, contract.GetSpender(), contract.Id); err != nil {
+		return err
+	}
+
+	oldDelegate := contract.Delegate
+	contract.Delegate = msg.Delegate
+
+	userSet, err := k.GetUserContractSet(ctx, contract.GetSpender())
+	if err != nil {
+		return err
+	}
+	if userSet.ContractSet == nil {
+		userSet.ContractSet = &types.ContractSet{}
+	}
+	userSet.ContractSet.ContractIds = append(userSet.ContractSet.ContractIds, contract.Id)
+	if err := k.SetUserContractSet(ctx, userSet); err != nil {
+		return err
+	}
+
+	if err := k.SetContract(ctx, contract); err != nil {
+		return err
+	}
+
+	return k.EmitUpdateContractDelegateEvent(ctx, &contract, oldDelegate)
+}
@@ -0,0 +1,181 @@
+package keeper
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	"github.com/cosmos/cosmos-sdk/crypto/hd"
+	cKeys "github.com/cosmos/cosmos-sdk/crypto/keyring"
+	"github.com/cosmos/cosmos-sdk/std"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/module"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestUpdateContractDelegate(t *testing.T) {
+	ctx, k, sk := SetupKeeperWithStaking(t)
+	ctx = ctx.WithBlockHeight(10)
+	s := newMsgServer(k, sk)
+	mgr := NewManager(k, sk)
+
+	// setup
+	interfaceRegistry := codectypes.NewInterfaceRegistry()
+	std.RegisterInterfaces(interfaceRegistry)
+	module.NewBasicManager().RegisterInterfaces(interfaceRegistry)
+	types.RegisterInterfaces(interfaceRegistry)
+	cdc := codec.NewProtoCodec(interfaceRegistry)
+	kb := cKeys.NewInMemory(cdc)
+	newDelegateKey := func(name string) common.PubKey {
+		info, _, err := kb.NewMnemonic(name, cKeys.English, `m/44'/931'/0'/0/0`, "", hd.Secp256k1)
+		require.NoError(t, err)
+		pk, err := info.GetPubKey()
+		require.NoError(t, err)
+		pubkey, err := common.NewPubKeyFromCrypto(pk)
+		require.NoError(t, err)
+		return pubkey
+	}
+	oldDelegate := newDelegateKey("old")
+	newDelegate := newDelegateKey("new")
+
+	providerPubKey := types.GetRandomPubKey()
+	providerAcct, err := providerPubKey.GetMyAddress()
+	require.NoError(t, err)
+	service := common.BTCService
+	provider := types.NewProvider(providerPubKey, service)
+	provider.Bond = cosmos.NewInt(10000000000)
+	provider.LastUpdate = ctx.BlockHeight()
+	require.NoError(t, k.SetProvider(ctx, provider))
+
+	rates, err := cosmos.ParseCoins("10uarkeo")
+	require.NoError(t, err)
+	require.NoError(t, s.ModProviderHandle(ctx, &types.MsgModProvider{
+		Provider:            providerPubKey,
+		Service:             service.String(),
+		MinContractDuration: 10,
+		MaxContractDuration: 500,
+		Status:              types.ProviderStatus_ONLINE,
+		PayAsYouGoRate:      rates,
+		SubscriptionRate:    rates,
+		SettlementDuration:  10,
+	}))
+
+	clientPubKey := types.GetRandomPubKey()
+	clientAcct, err := clientPubKey.GetMyAddress()
+	require.NoError(t, err)
+	require.NoError(t, k.MintAndSendToAccount(ctx, clientAcct, getCoin(common.Tokens(10))))
+
+	_, err = s.OpenContract(sdk.WrapSDKContext(ctx), &types.MsgOpenContract{
+		Provider:           providerPubKey,
+		Service:            service.String(),
+		Creator:            clientAcct,
+		Client:             clientPubKey,
+		Delegate:           oldDelegate,
+		ContractType:       types.ContractType_PAY_AS_YOU_GO,
+		Duration:           100,
+		Rate:               cosmos.NewInt64Coin(configs.Denom, 10),
+		Deposit:            cosmos.NewInt(1000),
+		SettlementDuration: 10,
+		QueriesPerMinute:   10,
+	})
+	require.NoError(t, err)
+	contract, err := k.GetActiveContractForUser(ctx, oldDelegate, providerPubKey, service)
+	require.NoError(t, err)
+	require.False(t, contract.IsEmpty())
+
+	// only the client can rotate the delegate, not the delegate itself
+	oldDelegateAcct, err := oldDelegate.GetMyAddress()
+	require.NoError(t, err)
+	msg := types.NewMsgUpdateContractDelegate(oldDelegateAcct, contract.Id, newDelegate)
+	err = s.UpdateContractDelegateValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrUpdateContractDelegateUnauthorized)
+	msg.Creator = types.GetRandomBech32Addr()
+	err = s.UpdateContractDelegateValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrUpdateContractDelegateUnauthorized)
+	msg.Creator = clientAcct
+
+	// the delegate must change
+	msg.Delegate = oldDelegate
+	err = s.UpdateContractDelegateValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrInvalidPubKey)
+	msg.Delegate = newDelegate
+
+	// the old delegate signed some requests before the rotation
+	claim := types.MsgClaimContractIncome{
+		ContractId: contract.Id,
+		Creator:    providerAcct,
+		Nonce:      20,
+	}
+	claim.Signature, _, err = kb.Sign("old", claim.GetBytesToSign())
+	require.NoError(t, err)
+	require.NoError(t, s.ClaimContractIncomeValidate(ctx, &claim))
+	_, err = s.ClaimContractIncome(sdk.WrapSDKContext(ctx), &claim)
+	require.NoError(t, err)
+
+	ctx = ctx.WithBlockHeight(20)
+	_, err = s.UpdateContractDelegate(sdk.WrapSDKContext(ctx), msg)
+	require.NoError(t, err)
+
+	// the contract is looked up by its new spender
+	rotated, err := k.GetContract(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, newDelegate, rotated.Delegate)
+	require.Equal(t, clientPubKey, rotated.Client)
+	require.Equal(t, int64(20), rotated.Nonce)
+	active, err := k.GetActiveContractForUser(ctx, newDelegate, providerPubKey, service)
+	require.NoError(t, err)
+	require.Equal(t, contract.Id, active.Id)
+	active, err = k.GetActiveContractForUser(ctx, oldDelegate, providerPubKey, service)
+	require.NoError(t, err)
+	require.True(t, active.IsEmpty())
+
+	var evt *types.EventUpdateContractDelegate
+	for _, e := range ctx.EventManager().Events() {
+		if e.Type != types.EventTypeUpdateContractDelegate {
+			continue
+		}
+		parsed, err := sdk.ParseTypedEvent(abci.Event(e))
+		require.NoError(t, err)
+		evt = parsed.(*types.EventUpdateContractDelegate)
+	}
+	require.NotNil(t, evt)
+	require.Equal(t, contract.Id, evt.ContractId)
+	require.Equal(t, oldDelegate, evt.OldDelegate)
+	require.Equal(t, newDelegate, evt.NewDelegate)
+	require.Equal(t, int64(20), evt.Height)
+
+	// signatures of the old delegate are refused from now on
+	claim.Nonce = 30
+	claim.Signature, _, err = kb.Sign("old", claim.GetBytesToSign())
+	require.NoError(t, err)
+	err = s.ClaimContractIncomeValidate(ctx, &claim)
+	require.ErrorIs(t, err, types.ErrClaimContractIncomeInvalidSignature)
+	claim.Signature, _, err = kb.Sign("new", claim.GetBytesToSign())
+	require.NoError(t, err)
+	_, err = s.ClaimContractIncome(sdk.WrapSDKContext(ctx), &claim)
+	require.NoError(t, err)
+
+	// the contract is settled the same way, the provider is paid for the
+	// nonces of both delegates and the client refunded the remainder
+	clientBalance := k.GetBalance(ctx, clientAcct).AmountOf(configs.Denom)
+	ctx = ctx.WithBlockHeight(120)
+	require.NoError(t, mgr.ContractEndBlock(ctx))
+	settled, err := k.GetContract(ctx, contract.Id)
+	require.NoError(t, err)
+	require.Equal(t, int64(120), settled.SettlementHeight)
+	require.Equal(t, cosmos.NewInt(300), settled.Paid)
+	require.Equal(t, clientBalance.AddRaw(700), k.GetBalance(ctx, clientAcct).AmountOf(configs.Denom))
+	userSet, err := k.GetUserContractSet(ctx, newDelegate)
+	require.NoError(t, err)
+	require.Nil(t, userSet.ContractSet)
+
+	// an expired contract keeps its delegate
+	msg.Delegate = types.GetRandomPubKey()
+	err = s.UpdateContractDelegateValidate(ctx, msg)
+	require.ErrorIs(t, err, types.ErrUpdateContractDelegateExpired)
+}
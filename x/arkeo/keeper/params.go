@@ -1 +1,48 @@
 package keeper
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/types"
+)
+
+// DepositDenoms returns the DepositDenoms param, the param may not be set on
+// chains started before it was introduced
+func (k KVStore) DepositDenoms(ctx sdk.Context) (res []types.DepositDenom) {
+	k.paramstore.GetIfExists(ctx, types.KeyDepositDenoms, &res)
+	return
+}
+
+// MinContractDeposit returns the MinContractDeposit param, zero on chains
+// started before it was introduced
+func (k KVStore) MinContractDeposit(ctx sdk.Context) cosmos.Int {
+	res := cosmos.ZeroInt()
+	k.paramstore.GetIfExists(ctx, types.KeyMinContractDeposit, &res)
+	return res
+}
+
+// DowntimeAttestationThreshold returns the DowntimeAttestationThreshold param,
+// zero (attestations disabled) on chains started before it was introduced
+func (k KVStore) DowntimeAttestationThreshold(ctx sdk.Context) (res uint64) {
+	k.paramstore.GetIfExists(ctx, types.KeyDowntimeAttestationThreshold, &res)
+	return
+}
+
+// DowntimeAttestationWindow returns the DowntimeAttestationWindow param
+func (k KVStore) DowntimeAttestationWindow(ctx sdk.Context) (res int64) {
+	k.paramstore.GetIfExists(ctx, types.KeyDowntimeAttestationWindow, &res)
+	return
+}
+
+// DowntimeAttestationInterval returns the DowntimeAttestationInterval param
+func (k KVStore) DowntimeAttestationInterval(ctx sdk.Context) (res int64) {
+	k.paramstore.GetIfExists(ctx, types.KeyDowntimeAttestationInterval, &res)
+	return
+}
+
+// DowntimeSlashBasisPoints returns the DowntimeSlashBasisPoints param
+func (k KVStore) DowntimeSlashBasisPoints(ctx sdk.Context) (res int64) {
+	k.paramstore.GetIfExists(ctx, types.KeyDowntimeSlashBasisPoints, &res)
+	return
+}
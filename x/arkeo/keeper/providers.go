@@ -2,6 +2,7 @@ package keeper
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
@@ -63,3 +64,197 @@ func (k KVStore) RemoveProvider(ctx cosmos.Context, pubkey common.PubKey, servic
 	record := types.NewProvider(pubkey, service)
 	k.del(ctx, k.GetKey(ctx, prefixProvider, record.Key()))
 }
+
+func (k KVStore) getProviderUptimeKey(ctx cosmos.Context, pubkey common.PubKey, service common.Service) string {
+	record := types.NewProvider(pubkey, service)
+	return k.GetKey(ctx, prefixProviderUptime, record.Key())
+}
+
+// GetProviderUptimeRecord get the uptime record of the given provider
+func (k KVStore) GetProviderUptimeRecord(ctx cosmos.Context, pubkey common.PubKey, service common.Service) (types.ProviderUptimeRecord, error) {
+	var record types.ProviderUptimeRecord
+	store := ctx.KVStore(k.storeKey)
+	key := k.getProviderUptimeKey(ctx, pubkey, service)
+	if !store.Has([]byte(key)) {
+		return record, nil
+	}
+	err := k.cdc.Unmarshal(store.Get([]byte(key)), &record)
+	return record, err
+}
+
+// SetProviderUptimeRecord save the uptime record of the given provider
+func (k KVStore) SetProviderUptimeRecord(ctx cosmos.Context, pubkey common.PubKey, service common.Service, record types.ProviderUptimeRecord) {
+	store := ctx.KVStore(k.storeKey)
+	store.Set([]byte(k.getProviderUptimeKey(ctx, pubkey, service)), k.cdc.MustMarshal(&record))
+}
+
+// RemoveProviderUptimeRecord reset the uptime of the given provider
+func (k KVStore) RemoveProviderUptimeRecord(ctx cosmos.Context, pubkey common.PubKey, service common.Service) {
+	k.del(ctx, k.getProviderUptimeKey(ctx, pubkey, service))
+}
+
+// GetProviderUptime returns the fraction of blocks the provider was online
+// for, since it last came ONLINE
+func (k KVStore) GetProviderUptime(ctx cosmos.Context, pubkey common.PubKey, service common.Service) (float64, error) {
+	if !k.ProviderExists(ctx, pubkey, service) {
+		return 0, errors.New("provider not found")
+	}
+	record, err := k.GetProviderUptimeRecord(ctx, pubkey, service)
+	if err != nil {
+		return 0, err
+	}
+	if record.TotalBlocks == 0 {
+		return 0, nil
+	}
+	return float64(record.OnlineBlocks) / float64(record.TotalBlocks), nil
+}
+
+func (k KVStore) getProviderReportKey(ctx cosmos.Context, height int64, provider, reporter common.PubKey) string {
+	return k.GetKey(ctx, prefixProviderReport, fmt.Sprintf("%d/%s/%s", height, provider, reporter))
+}
+
+// HasProviderReport checks if the reporter already reported the provider in
+// the current block
+func (k KVStore) HasProviderReport(ctx cosmos.Context, provider, reporter common.PubKey) bool {
+	store := ctx.KVStore(k.storeKey)
+	return store.Has([]byte(k.getProviderReportKey(ctx, ctx.BlockHeight(), provider, reporter)))
+}
+
+// SetProviderReport records the reporter reported the provider in the current
+// block
+func (k KVStore) SetProviderReport(ctx cosmos.Context, provider, reporter common.PubKey) {
+	store := ctx.KVStore(k.storeKey)
+	store.Set([]byte(k.getProviderReportKey(ctx, ctx.BlockHeight(), provider, reporter)), []byte{1})
+}
+
+// RemoveProviderReports remove the reports of the given block, they are only
+// relevant for the duration of the block
+func (k KVStore) RemoveProviderReports(ctx cosmos.Context, height int64) {
+	store := ctx.KVStore(k.storeKey)
+	prefix := k.GetKey(ctx, prefixProviderReport, fmt.Sprintf("%d/", height))
+	iter := cosmos.KVStorePrefixIterator(store, []byte(prefix))
+	var keys [][]byte
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	for _, key := range keys {
+		store.Delete(key)
+	}
+}
+
+func (k KVStore) getDowntimeAttestationKey(ctx cosmos.Context, provider common.PubKey, service common.Service, contractId uint64) string {
+	return k.GetKey(ctx, prefixDowntimeAttestation, fmt.Sprintf("%s/%s/%d", provider, service, contractId))
+}
+
+func (k KVStore) getDowntimeHeightKey(ctx cosmos.Context, height int64, provider common.PubKey, service common.Service, contractId uint64) string {
+	return k.GetKey(ctx, prefixDowntimeHeight, fmt.Sprintf("%d/%s/%s/%d", height, provider, service, contractId))
+}
+
+// GetDowntimeAttestation get the last attestation of the downtime of the
+// provider by the contract, empty when there is none within the window
+func (k KVStore) GetDowntimeAttestation(ctx cosmos.Context, provider common.PubKey, service common.Service, contractId uint64) (types.DowntimeAttestation, error) {
+	var record types.DowntimeAttestation
+	store := ctx.KVStore(k.storeKey)
+	key := k.getDowntimeAttestationKey(ctx, provider, service, contractId)
+	if !store.Has([]byte(key)) {
+		return record, nil
+	}
+	err := k.cdc.Unmarshal(store.Get([]byte(key)), &record)
+	return record, err
+}
+
+// SetDowntimeAttestation save the attestation, replacing the previous one of
+// the contract. It is indexed by height, to be pruned once out of the window.
+func (k KVStore) SetDowntimeAttestation(ctx cosmos.Context, attestation types.DowntimeAttestation) error {
+	if attestation.Provider.IsEmpty() || attestation.Service.IsEmpty() {
+		return errors.New("cannot save a downtime attestation with an empty provider or service")
+	}
+	store := ctx.KVStore(k.storeKey)
+	buf := k.cdc.MustMarshal(&attestation)
+	store.Set([]byte(k.getDowntimeAttestationKey(ctx, attestation.Provider, attestation.Service, attestation.ContractId)), buf)
+	store.Set([]byte(k.getDowntimeHeightKey(ctx, attestation.Height, attestation.Provider, attestation.Service, attestation.ContractId)), buf)
+	return nil
+}
+
+// GetDowntimeAttestationIterator iterate the attestations of the downtime of
+// the provider
+func (k KVStore) GetDowntimeAttestationIterator(ctx cosmos.Context, provider common.PubKey, service common.Service) cosmos.Iterator {
+	store := ctx.KVStore(k.storeKey)
+	prefix := k.GetKey(ctx, prefixDowntimeAttestation, fmt.Sprintf("%s/%s/", provider, service))
+	return cosmos.KVStorePrefixIterator(store, []byte(prefix))
+}
+
+// GetDowntimeAttestationHeightIterator iterate the attestations made at the
+// given height, some may have been replaced since
+func (k KVStore) GetDowntimeAttestationHeightIterator(ctx cosmos.Context, height int64) cosmos.Iterator {
+	store := ctx.KVStore(k.storeKey)
+	prefix := k.GetKey(ctx, prefixDowntimeHeight, fmt.Sprintf("%d/", height))
+	return cosmos.KVStorePrefixIterator(store, []byte(prefix))
+}
+
+// CountDowntimeAttestations returns the number of distinct clients that
+// attested the downtime of the provider after the given height
+func (k KVStore) CountDowntimeAttestations(ctx cosmos.Context, provider common.PubKey, service common.Service, since int64) (uint64, error) {
+	clients := make(map[string]bool)
+	iter := k.GetDowntimeAttestationIterator(ctx, provider, service)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		var attestation types.DowntimeAttestation
+		if err := k.cdc.Unmarshal(iter.Value(), &attestation); err != nil {
+			return 0, err
+		}
+		if attestation.Height > since {
+			clients[attestation.Client.String()] = true
+		}
+	}
+	return uint64(len(clients)), nil
+}
+
+// RemoveDowntimeAttestations remove the attestations of the downtime of the
+// provider, once it has been slashed for them. Their height index is left to
+// the pruning.
+func (k KVStore) RemoveDowntimeAttestations(ctx cosmos.Context, provider common.PubKey, service common.Service) {
+	store := ctx.KVStore(k.storeKey)
+	iter := k.GetDowntimeAttestationIterator(ctx, provider, service)
+	var keys [][]byte
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	for _, key := range keys {
+		store.Delete(key)
+	}
+}
+
+// PruneDowntimeAttestations remove the attestations made at the given height,
+// unless the contract attested again since
+func (k KVStore) PruneDowntimeAttestations(ctx cosmos.Context, height int64) {
+	store := ctx.KVStore(k.storeKey)
+	iter := k.GetDowntimeAttestationHeightIterator(ctx, height)
+	var keys [][]byte
+	var attestations []types.DowntimeAttestation
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+		var attestation types.DowntimeAttestation
+		if err := k.cdc.Unmarshal(iter.Value(), &attestation); err != nil {
+			ctx.Logger().Error("fail to unmarshal downtime attestation", "error", err)
+			continue
+		}
+		attestations = append(attestations, attestation)
+	}
+	iter.Close()
+	for _, key := range keys {
+		store.Delete(key)
+	}
+	for _, attestation := range attestations {
+		current, err := k.GetDowntimeAttestation(ctx, attestation.Provider, attestation.Service, attestation.ContractId)
+		if err != nil {
+			ctx.Logger().Error("fail to get downtime attestation", "error", err)
+			continue
+		}
+		if current.Height == attestation.Height {
+			k.del(ctx, k.getDowntimeAttestationKey(ctx, attestation.Provider, attestation.Service, attestation.ContractId))
+		}
+	}
+}
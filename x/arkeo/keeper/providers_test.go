@@ -7,6 +7,11 @@ import (
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/query"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestProvider(t *testing.T) {
@@ -28,3 +33,195 @@ func TestProvider(t *testing.T) {
 	k.RemoveProvider(ctx, provider.PubKey, provider.Service)
 	require.False(t, k.ProviderExists(ctx, provider.PubKey, provider.Service))
 }
+
+func TestProviderRates(t *testing.T) {
+	ctx, k := SetupKeeper(t)
+
+	pubkey := types.GetRandomPubKey()
+	btc := types.NewProvider(pubkey, common.BTCService)
+	btc.Status = types.ProviderStatus_ONLINE
+	btc.MinContractDuration = 10
+	btc.MaxContractDuration = 1000
+	btc.SettlementDuration = 20
+	btc.SubscriptionRate = cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 10), cosmos.NewInt64Coin("uatom", 2))
+	btc.PayAsYouGoRate = cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 20), cosmos.NewInt64Coin("uatom", 4))
+	require.NoError(t, k.SetProvider(ctx, btc))
+	eth := types.NewProvider(pubkey, common.ETHService)
+	eth.Status = types.ProviderStatus_OFFLINE
+	eth.MinContractDuration = 5
+	eth.MaxContractDuration = 500
+	eth.PayAsYouGoRate = cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 7))
+	require.NoError(t, k.SetProvider(ctx, eth))
+	// another provider of the same service isn't listed
+	other := types.NewProvider(types.GetRandomPubKey(), common.BTCService)
+	other.SubscriptionRate = cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 1))
+	require.NoError(t, k.SetProvider(ctx, other))
+
+	res, err := k.ProviderRates(sdk.WrapSDKContext(ctx), &types.QueryProviderRatesRequest{Pubkey: pubkey.String()})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []types.ProviderServiceRates{
+		{
+			Service:             common.BTCService.String(),
+			Status:              types.ProviderStatus_ONLINE,
+			SubscriptionRate:    btc.SubscriptionRate,
+			PayAsYouGoRate:      btc.PayAsYouGoRate,
+			MinContractDuration: 10,
+			MaxContractDuration: 1000,
+			SettlementDuration:  20,
+		},
+		{
+			Service:             common.ETHService.String(),
+			Status:              types.ProviderStatus_OFFLINE,
+			PayAsYouGoRate:      eth.PayAsYouGoRate,
+			MinContractDuration: 5,
+			MaxContractDuration: 500,
+		},
+	}, res.Rates)
+
+	// unknown provider
+	_, err = k.ProviderRates(sdk.WrapSDKContext(ctx), &types.QueryProviderRatesRequest{Pubkey: types.GetRandomPubKey().String()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = k.ProviderRates(sdk.WrapSDKContext(ctx), &types.QueryProviderRatesRequest{Pubkey: "bogus"})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSearchProviders(t *testing.T) {
+	ctx, k := SetupKeeper(t)
+	goCtx := sdk.WrapSDKContext(ctx)
+
+	// 36 providers, half of them btc and half eth, with bonds of 100 to 3600,
+	// subscription rates of 1 to 9 uarkeo, pay-as-you-go rates in uatom for one
+	// in four and settlement durations of 0 to 40
+	for i := 0; i < 36; i++ {
+		service := common.BTCService
+		if i%2 == 1 {
+			service = common.ETHService
+		}
+		provider := types.NewProvider(types.GetRandomPubKey(), service)
+		provider.Status = types.ProviderStatus_ONLINE
+		if i%3 == 0 {
+			provider.Status = types.ProviderStatus_OFFLINE
+		}
+		provider.Bond = cosmos.NewInt(int64(i+1) * 100)
+		provider.SubscriptionRate = cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", int64(i%9)+1))
+		if i%4 == 0 {
+			provider.PayAsYouGoRate = cosmos.NewCoins(cosmos.NewInt64Coin("uatom", int64(i%5)+1))
+		}
+		provider.SettlementDuration = int64(i%5) * 10
+		require.NoError(t, k.SetProvider(ctx, provider))
+	}
+
+	search := func(req types.QuerySearchProvidersRequest) []types.Provider {
+		res, err := k.SearchProviders(goCtx, &req)
+		require.NoError(t, err)
+		return res.Providers
+	}
+
+	require.Len(t, search(types.QuerySearchProvidersRequest{}), 36)
+
+	// by service and status
+	providers := search(types.QuerySearchProvidersRequest{Service: common.ETHService.String(), Status: "online"})
+	require.Len(t, providers, 12)
+	for _, provider := range providers {
+		require.True(t, provider.Service.Equals(common.ETHService))
+		require.Equal(t, types.ProviderStatus_ONLINE, provider.Status)
+	}
+	require.Len(t, search(types.QuerySearchProvidersRequest{Status: "OFFLINE"}), 12)
+
+	// by min bond and max rate
+	providers = search(types.QuerySearchProvidersRequest{MinBond: "3000", MaxRate: "3uarkeo"})
+	require.Len(t, providers, 1) // i = 29, of bond 3000 and rate 3
+	for _, provider := range providers {
+		require.True(t, provider.Bond.GTE(cosmos.NewInt(3000)))
+		require.True(t, provider.SubscriptionRate.AmountOf("uarkeo").LTE(cosmos.NewInt(3)))
+	}
+
+	// by max rate in another denom, only the providers charging in uatom
+	providers = search(types.QuerySearchProvidersRequest{MaxRate: "2uatom"})
+	require.Len(t, providers, 3) // i = 0, 20 (rate 1) and 16 (rate 2)
+	for _, provider := range providers {
+		require.True(t, provider.PayAsYouGoRate.AmountOf("uatom").LTE(cosmos.NewInt(2)))
+	}
+	require.Empty(t, search(types.QuerySearchProvidersRequest{MaxRate: "2uatom", ContractType: "SUBSCRIPTION"}))
+	require.Len(t, search(types.QuerySearchProvidersRequest{MaxRate: "2uatom", ContractType: "pay-as-you-go"}), 3)
+
+	// by settlement duration range
+	providers = search(types.QuerySearchProvidersRequest{MinSettlementDuration: 10, MaxSettlementDuration: 20})
+	require.Len(t, providers, 14)
+	for _, provider := range providers {
+		require.True(t, provider.SettlementDuration >= 10 && provider.SettlementDuration <= 20)
+	}
+
+	// all the filters combined
+	providers = search(types.QuerySearchProvidersRequest{
+		Service:               common.BTCService.String(),
+		Status:                "ONLINE",
+		MinBond:               "1000",
+		MaxRate:               "5uarkeo",
+		MinSettlementDuration: 20,
+	})
+	for _, provider := range providers {
+		require.True(t, provider.Service.Equals(common.BTCService))
+		require.Equal(t, types.ProviderStatus_ONLINE, provider.Status)
+		require.True(t, provider.Bond.GTE(cosmos.NewInt(1000)))
+		require.True(t, provider.SubscriptionRate.AmountOf("uarkeo").LTE(cosmos.NewInt(5)))
+		require.GreaterOrEqual(t, provider.SettlementDuration, int64(20))
+	}
+	require.Len(t, providers, 2) // i = 22 and 28
+
+	// sorted by bond descending, paginated by offset
+	res, err := k.SearchProviders(goCtx, &types.QuerySearchProvidersRequest{
+		Service:    common.BTCService.String(),
+		Sort:       "bond",
+		Pagination: &query.PageRequest{Offset: 2, Limit: 5, CountTotal: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, uint64(18), res.Pagination.Total)
+	require.Len(t, res.Providers, 5)
+	for i, provider := range res.Providers {
+		require.Equal(t, cosmos.NewInt(int64(3100-i*200)), provider.Bond)
+	}
+
+	// sorted by rate ascending
+	providers = search(types.QuerySearchProvidersRequest{Sort: "rate", Pagination: &query.PageRequest{Limit: 100}})
+	require.Len(t, providers, 36)
+	for i := 1; i < len(providers); i++ {
+		require.True(t, providers[i-1].SubscriptionRate.AmountOf("uarkeo").LTE(providers[i].SubscriptionRate.AmountOf("uarkeo")))
+	}
+	providers = search(types.QuerySearchProvidersRequest{Sort: "rate", MaxRate: "9uatom"})
+	require.Len(t, providers, 9)
+	for i := 1; i < len(providers); i++ {
+		require.True(t, providers[i-1].PayAsYouGoRate.AmountOf("uatom").LTE(providers[i].PayAsYouGoRate.AmountOf("uatom")))
+	}
+
+	// unsorted results are paginated by key
+	res, err = k.SearchProviders(goCtx, &types.QuerySearchProvidersRequest{
+		Status:     "online",
+		Pagination: &query.PageRequest{Limit: 10},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Providers, 10)
+	require.NotNil(t, res.Pagination.NextKey)
+	next, err := k.SearchProviders(goCtx, &types.QuerySearchProvidersRequest{
+		Status:     "online",
+		Pagination: &query.PageRequest{Key: res.Pagination.NextKey, Limit: 100},
+	})
+	require.NoError(t, err)
+	require.Len(t, next.Providers, 14)
+
+	// bad filters
+	for _, req := range []types.QuerySearchProvidersRequest{
+		{Service: "bogus"},
+		{Status: "bogus"},
+		{MinBond: "-1"},
+		{MaxRate: "bogus"},
+		{ContractType: "bogus"},
+		{MinSettlementDuration: 20, MaxSettlementDuration: 10},
+		{Sort: "bogus"},
+		{Sort: "bond", Pagination: &query.PageRequest{Key: []byte("key")}},
+	} {
+		req := req
+		_, err := k.SearchProviders(goCtx, &req)
+		require.Equal(t, codes.InvalidArgument, status.Code(err), req.String())
+	}
+}
@@ -14,6 +14,13 @@ func RegisterCodec(cdc *codec.LegacyAmino) {
 	cdc.RegisterConcrete(&MsgCloseContract{}, "arkeo/CloseContract", nil)
 	cdc.RegisterConcrete(&MsgClaimContractIncome{}, "arkeo/ClaimContractIncome", nil)
 	cdc.RegisterConcrete(&MsgSetVersion{}, "arkeo/SetVersion", nil)
+	cdc.RegisterConcrete(&MsgSetBundle{}, "arkeo/SetBundle", nil)
+	cdc.RegisterConcrete(&MsgReportProvider{}, "arkeo/ReportProvider", nil)
+	cdc.RegisterConcrete(&MsgSetContractConfig{}, "arkeo/SetContractConfig", nil)
+	cdc.RegisterConcrete(&MsgExtendContract{}, "arkeo/ExtendContract", nil)
+	cdc.RegisterConcrete(&MsgUpdateContractDelegate{}, "arkeo/UpdateContractDelegate", nil)
+	cdc.RegisterConcrete(&MsgTopUpContract{}, "arkeo/TopUpContract", nil)
+	cdc.RegisterConcrete(&MsgAttestProviderDowntime{}, "arkeo/AttestProviderDowntime", nil)
 	// this line is used by starport scaffolding # 2
 }
 
@@ -36,6 +43,27 @@ func RegisterInterfaces(registry cdctypes.InterfaceRegistry) {
 	registry.RegisterImplementations((*sdk.Msg)(nil),
 		&MsgSetVersion{},
 	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgSetBundle{},
+	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgReportProvider{},
+	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgSetContractConfig{},
+	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgExtendContract{},
+	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgUpdateContractDelegate{},
+	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgTopUpContract{},
+	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgAttestProviderDowntime{},
+	)
 	// this line is used by starport scaffolding # 3
 
 	msgservice.RegisterMsgServiceDesc(registry, &_Msg_serviceDesc)
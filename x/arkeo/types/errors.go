@@ -41,4 +41,29 @@ var (
 	ErrInvariantMaxSupply                     = errors.Register(ModuleName, 32, "max supply invariant")
 	ErrInvalidAuthorization                   = errors.Register(ModuleName, 33, "invalid authorization")
 	ErrInvalidVersion                         = errors.Register(ModuleName, 34, "version cannot be zero or lower")
+	ErrInvalidModProviderPayoutSplit          = errors.Register(ModuleName, 35, "invalid mod provider payout split")
+	ErrClaimContractIncomeTooManyClaims       = errors.Register(ModuleName, 36, "too many claims for provider in block")
+	ErrBundleNotFound                         = errors.Register(ModuleName, 37, "bundle not found")
+	ErrInvalidBundle                          = errors.Register(ModuleName, 38, "invalid bundle")
+	ErrReportProviderUnauthorized             = errors.Register(ModuleName, 39, "unauthorized to report provider")
+	ErrReportProviderAlreadyReported          = errors.Register(ModuleName, 40, "provider already reported in block")
+	ErrInvalidReportReason                    = errors.Register(ModuleName, 41, "invalid report reason")
+	ErrInvalidContractConfig                  = errors.Register(ModuleName, 42, "invalid contract config")
+	ErrContractConfigUnauthorized             = errors.Register(ModuleName, 43, "unauthorized to set contract config")
+	ErrInvalidModProviderMetadataHash         = errors.Register(ModuleName, 44, "invalid mod provider metadata hash")
+	ErrExtendContractUnauthorized             = errors.Register(ModuleName, 45, "unauthorized to extend contract")
+	ErrExtendContractExpired                  = errors.Register(ModuleName, 46, "cannot extend an expired contract")
+	ErrExtendContractSettlementPeriod         = errors.Register(ModuleName, 47, "cannot extend a contract in its settlement period")
+	ErrUpdateContractDelegateUnauthorized     = errors.Register(ModuleName, 48, "unauthorized to update contract delegate")
+	ErrUpdateContractDelegateExpired          = errors.Register(ModuleName, 49, "cannot update the delegate of an expired contract")
+	ErrInvalidModProviderMethodRate           = errors.Register(ModuleName, 50, "invalid mod provider method rate")
+	ErrInvalidContractSpenders                = errors.Register(ModuleName, 51, "invalid contract spenders")
+	ErrOpenContractDepositDenom               = errors.Register(ModuleName, 52, "deposit denom not accepted")
+	ErrTopUpContractUnauthorized              = errors.Register(ModuleName, 53, "unauthorized to top up contract")
+	ErrTopUpContractClosed                    = errors.Register(ModuleName, 54, "cannot top up a closed contract")
+	ErrTopUpContractType                      = errors.Register(ModuleName, 55, "only a pay-as-you-go contract can be topped up")
+	ErrInvalidModProviderMinDeposit           = errors.Register(ModuleName, 56, "invalid mod provider min deposit")
+	ErrOpenContractMinDeposit                 = errors.Register(ModuleName, 57, "deposit below the min contract deposit")
+	ErrAttestProviderDowntimeUnauthorized     = errors.Register(ModuleName, 58, "unauthorized to attest provider downtime")
+	ErrAttestProviderDowntimeTooSoon          = errors.Register(ModuleName, 59, "provider downtime attested too soon")
 )
@@ -1,16 +1,29 @@
 package types
 
 import (
+	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 )
 
 const (
-	EventTypeBondProvider    = "arkeo.arkeo.EventBondProvider"
-	EventTypeModProvider     = "arkeo.arkeo.EventModProvider"
-	EventTypeOpenContract    = "arkeo.arkeo.EventOpenContract"
-	EventTypeSettleContract  = "arkeo.arkeo.EventSettleContract"
-	EventTypeCloseContract   = "arkeo.arkeo.EventCloseContract"
-	EventTypeValidatorPayout = "arkeo.arkeo.EventValidatorPayout"
+	EventTypeBondProvider           = "arkeo.arkeo.EventBondProvider"
+	EventTypeModProvider            = "arkeo.arkeo.EventModProvider"
+	EventTypeOpenContract           = "arkeo.arkeo.EventOpenContract"
+	EventTypeSettleContract         = "arkeo.arkeo.EventSettleContract"
+	EventTypeCloseContract          = "arkeo.arkeo.EventCloseContract"
+	EventTypeValidatorPayout        = "arkeo.arkeo.EventValidatorPayout"
+	EventTypeSetBundle              = "arkeo.arkeo.EventSetBundle"
+	EventTypeContractSpent          = "arkeo.arkeo.EventContractSpent"
+	EventTypeProviderStrike         = "arkeo.arkeo.EventProviderStrike"
+	EventTypeSetContractConfig      = "arkeo.arkeo.EventSetContractConfig"
+	EventTypeContractExpired        = "arkeo.arkeo.EventContractExpired"
+	EventTypePayoutContribution     = "arkeo.arkeo.EventPayoutContribution"
+	EventTypeExtendContract         = "arkeo.arkeo.EventExtendContract"
+	EventTypeUpdateContractDelegate = "arkeo.arkeo.EventUpdateContractDelegate"
+	EventTypeTopUpContract          = "arkeo.arkeo.EventTopUpContract"
+	EventTypeAttestProviderDowntime = "arkeo.arkeo.EventAttestProviderDowntime"
+	EventTypeProviderSlashed        = "arkeo.arkeo.EventProviderSlashed"
+	EventTypeContractExpiringSoon   = "arkeo.arkeo.EventContractExpiringSoon"
 )
 
 func NewOpenContractEvent(openCost int64, contract *Contract) EventOpenContract {
@@ -29,6 +42,8 @@ func NewOpenContractEvent(openCost int64, contract *Contract) EventOpenContract
 		SettlementDuration: contract.SettlementDuration,
 		Authorization:      contract.Authorization,
 		QueriesPerMinute:   contract.QueriesPerMinute,
+		Services:           contract.ServiceSet().Strings(),
+		BundleId:           contract.BundleId,
 	}
 }
 
@@ -44,16 +59,74 @@ func NewContractSettlementEvent(debt, valIncome cosmos.Int, contract *Contract)
 		Height:     contract.Height,
 		Paid:       debt,
 		Reserve:    valIncome,
+		Services:   contract.ServiceSet().Strings(),
 	}
 }
 
-func NewCloseContractEvent(contract *Contract) EventCloseContract {
+func NewCloseContractEvent(contract *Contract, refund cosmos.Int) EventCloseContract {
 	return EventCloseContract{
 		ContractId: contract.Id,
 		Provider:   contract.Provider,
 		Service:    contract.Service.String(),
 		Client:     contract.Client,
 		Delegate:   contract.Delegate,
+		Services:   contract.ServiceSet().Strings(),
+		Refund:     refund,
+	}
+}
+
+func NewContractExpiredEvent(contract *Contract, height int64) EventContractExpired {
+	return EventContractExpired{
+		ContractId:        contract.Id,
+		Provider:          contract.Provider,
+		Service:           contract.Service.String(),
+		Client:            contract.Client,
+		Delegate:          contract.Delegate,
+		Services:          contract.ServiceSet().Strings(),
+		Nonce:             contract.Nonce,
+		Height:            height,
+		SettlementPending: contract.SettlementPeriodEnd() > height,
+	}
+}
+
+func NewContractExpiringSoonEvent(contract *Contract, settlementHeight, height int64) EventContractExpiringSoon {
+	return EventContractExpiringSoon{
+		ContractId:       contract.Id,
+		Provider:         contract.Provider,
+		Service:          contract.Service.String(),
+		Client:           contract.Client,
+		Services:         contract.ServiceSet().Strings(),
+		Nonce:            contract.Nonce,
+		SettlementHeight: settlementHeight,
+		Height:           height,
+	}
+}
+
+func NewExtendContractEvent(contract *Contract, oldExpiration int64) EventExtendContract {
+	return EventExtendContract{
+		ContractId:    contract.Id,
+		Provider:      contract.Provider,
+		Service:       contract.Service.String(),
+		Client:        contract.Client,
+		Delegate:      contract.Delegate,
+		Services:      contract.ServiceSet().Strings(),
+		OldExpiration: oldExpiration,
+		NewExpiration: contract.Expiration(),
+		Duration:      contract.Duration,
+		Deposit:       contract.Deposit,
+	}
+}
+
+func NewTopUpContractEvent(contract *Contract, added cosmos.Int) EventTopUpContract {
+	return EventTopUpContract{
+		ContractId: contract.Id,
+		Provider:   contract.Provider,
+		Service:    contract.Service.String(),
+		Client:     contract.Client,
+		Delegate:   contract.Delegate,
+		Services:   contract.ServiceSet().Strings(),
+		Added:      added,
+		Deposit:    contract.Deposit,
 	}
 }
 
@@ -72,3 +145,24 @@ func NewValidatorPayoutEvent(acc cosmos.AccAddress, reward cosmos.Int) EventVali
 		Reward:    reward,
 	}
 }
+
+func NewPayoutContributionEvent(acc cosmos.AccAddress, contractId uint64, amount cosmos.Int) EventPayoutContribution {
+	return EventPayoutContribution{
+		Validator:  acc,
+		ContractId: contractId,
+		Amount:     amount,
+	}
+}
+
+func NewUpdateContractDelegateEvent(contract *Contract, oldDelegate common.PubKey, height int64) EventUpdateContractDelegate {
+	return EventUpdateContractDelegate{
+		ContractId:  contract.Id,
+		Provider:    contract.Provider,
+		Service:     contract.Service.String(),
+		Client:      contract.Client,
+		OldDelegate: oldDelegate,
+		NewDelegate: contract.Delegate,
+		Services:    contract.ServiceSet().Strings(),
+		Height:      height,
+	}
+}
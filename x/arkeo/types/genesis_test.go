@@ -3,6 +3,7 @@ package types_test
 import (
 	"testing"
 
+	"github.com/arkeonetwork/arkeo/common/cosmos"
 	"github.com/arkeonetwork/arkeo/x/arkeo/types"
 
 	"github.com/stretchr/testify/require"
@@ -27,6 +28,95 @@ func TestGenesisState_Validate(t *testing.T) {
 			},
 			valid: true,
 		},
+		{
+			desc: "accepted deposit denom",
+			genState: &types.GenesisState{
+				Params: types.Params{DepositDenoms: []types.DepositDenom{{Denom: "ibc/usdc", Rate: cosmos.NewDec(2)}}},
+			},
+			valid: true,
+		},
+		{
+			desc: "native deposit denom",
+			genState: &types.GenesisState{
+				Params: types.Params{DepositDenoms: []types.DepositDenom{{Denom: "uarkeo", Rate: cosmos.NewDec(1)}}},
+			},
+			valid: false,
+		},
+		{
+			desc: "duplicate deposit denom",
+			genState: &types.GenesisState{
+				Params: types.Params{DepositDenoms: []types.DepositDenom{
+					{Denom: "ibc/usdc", Rate: cosmos.NewDec(2)},
+					{Denom: "ibc/usdc", Rate: cosmos.NewDec(3)},
+				}},
+			},
+			valid: false,
+		},
+		{
+			desc: "zero deposit denom rate",
+			genState: &types.GenesisState{
+				Params: types.Params{DepositDenoms: []types.DepositDenom{{Denom: "ibc/usdc", Rate: cosmos.ZeroDec()}}},
+			},
+			valid: false,
+		},
+		{
+			desc: "min contract deposit",
+			genState: &types.GenesisState{
+				Params: types.Params{MinContractDeposit: cosmos.NewInt(100)},
+			},
+			valid: true,
+		},
+		{
+			desc: "negative min contract deposit",
+			genState: &types.GenesisState{
+				Params: types.Params{MinContractDeposit: cosmos.NewInt(-1)},
+			},
+			valid: false,
+		},
+		{
+			desc: "downtime attestations",
+			genState: &types.GenesisState{
+				Params: types.Params{
+					DowntimeAttestationThreshold: 3,
+					DowntimeAttestationWindow:    100,
+					DowntimeAttestationInterval:  10,
+					DowntimeSlashBasisPoints:     500,
+				},
+			},
+			valid: true,
+		},
+		{
+			desc: "negative downtime attestation window",
+			genState: &types.GenesisState{
+				Params: types.Params{DowntimeAttestationWindow: -1},
+			},
+			valid: false,
+		},
+		{
+			desc: "downtime slash over max basis points",
+			genState: &types.GenesisState{
+				Params: types.Params{DowntimeSlashBasisPoints: 10001},
+			},
+			valid: false,
+		},
+		{
+			desc: "downtime attestations without a window",
+			genState: &types.GenesisState{
+				Params: types.Params{DowntimeAttestationThreshold: 3},
+			},
+			valid: false,
+		},
+		{
+			desc: "downtime attestation interval over the window",
+			genState: &types.GenesisState{
+				Params: types.Params{
+					DowntimeAttestationThreshold: 3,
+					DowntimeAttestationWindow:    100,
+					DowntimeAttestationInterval:  101,
+				},
+			},
+			valid: false,
+		},
 		// this line is used by starport scaffolding # types/genesis/testcase
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
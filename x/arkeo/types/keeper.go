@@ -4,11 +4,18 @@ import (
 	"encoding/json"
 	fmt "fmt"
 	"strconv"
+	"strings"
+
+	"cosmossdk.io/errors"
 
 	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 )
 
+// PayoutSplitTotalWeight is the sum, in basis points, that the weights of a
+// provider's payout splits must add up to
+const PayoutSplitTotalWeight uint64 = 10_000
+
 func NewProvider(pubkey common.PubKey, service common.Service) Provider {
 	return Provider{
 		PubKey:           pubkey,
@@ -16,6 +23,7 @@ func NewProvider(pubkey common.PubKey, service common.Service) Provider {
 		Bond:             cosmos.ZeroInt(),
 		SubscriptionRate: make([]cosmos.Coin, 0),
 		PayAsYouGoRate:   make([]cosmos.Coin, 0),
+		MinDeposit:       cosmos.ZeroInt(),
 	}
 }
 
@@ -23,6 +31,206 @@ func (provider Provider) Key() string {
 	return fmt.Sprintf("%s/%s", provider.PubKey, provider.Service)
 }
 
+// ContractMinDeposit returns the smallest deposit of the contracts opened with
+// the provider, the min deposit of the provider when it is above the floor of
+// the module
+func (provider Provider) ContractMinDeposit(floor cosmos.Int) cosmos.Int {
+	if floor.IsNil() {
+		floor = cosmos.ZeroInt()
+	}
+	if !provider.MinDeposit.IsNil() && provider.MinDeposit.GT(floor) {
+		return provider.MinDeposit
+	}
+	return floor
+}
+
+func NewPayoutSplit(addr cosmos.AccAddress, weight uint64) PayoutSplit {
+	return PayoutSplit{
+		Address: addr,
+		Weight:  weight,
+	}
+}
+
+// ParsePayoutSplits parses a comma separated list of address:weight pairs,
+// for example "arkeo1abc...:7000,arkeo1def...:3000"
+func ParsePayoutSplits(raw string) ([]PayoutSplit, error) {
+	splits := make([]PayoutSplit, 0)
+	if len(strings.TrimSpace(raw)) == 0 {
+		return splits, nil
+	}
+	for _, item := range strings.Split(raw, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid payout split (%s), expected address:weight", item)
+		}
+		addr, err := cosmos.AccAddressFromBech32(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		weight, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		splits = append(splits, NewPayoutSplit(addr, weight))
+	}
+	return splits, nil
+}
+
+// ValidatePayoutSplits ensures every split has an address and a non-zero
+// weight, no address is listed twice, and the weights sum to 100%. An empty
+// list is valid and means all income goes to the provider's own address.
+func ValidatePayoutSplits(splits []PayoutSplit) error {
+	if len(splits) == 0 {
+		return nil
+	}
+	total := uint64(0)
+	seen := make(map[string]bool)
+	for _, split := range splits {
+		if split.Address.Empty() {
+			return errors.Wrap(ErrInvalidModProviderPayoutSplit, "address cannot be empty")
+		}
+		if split.Weight == 0 {
+			return errors.Wrapf(ErrInvalidModProviderPayoutSplit, "weight for %s cannot be zero", split.Address)
+		}
+		if seen[split.Address.String()] {
+			return errors.Wrapf(ErrInvalidModProviderPayoutSplit, "duplicate address %s", split.Address)
+		}
+		seen[split.Address.String()] = true
+		total += split.Weight
+	}
+	if total != PayoutSplitTotalWeight {
+		return errors.Wrapf(ErrInvalidModProviderPayoutSplit, "weights must sum to %d (%d)", PayoutSplitTotalWeight, total)
+	}
+	return nil
+}
+
+// SplitPayout divides the given amount across the payout splits. Each share is
+// rounded down and the remainder goes to the first address, so the sum of the
+// payouts always equals the amount.
+func SplitPayout(amount cosmos.Int, splits []PayoutSplit) []ProviderPayout {
+	payouts := make([]ProviderPayout, len(splits))
+	remainder := amount
+	for i, split := range splits {
+		share := amount.MulRaw(int64(split.Weight)).QuoRaw(int64(PayoutSplitTotalWeight))
+		payouts[i] = ProviderPayout{
+			Address: split.Address,
+			Amount:  share,
+		}
+		remainder = remainder.Sub(share)
+	}
+	if len(payouts) > 0 {
+		payouts[0].Amount = payouts[0].Amount.Add(remainder)
+	}
+	return payouts
+}
+
+// limits of the rate schedule of a provider
+const (
+	MaxMethodRates          = 32
+	MaxMethodRatePattern    = 128
+	MaxMethodRateMultiplier = 1000
+)
+
+func NewMethodRate(pattern string, multiplier uint64) MethodRate {
+	return MethodRate{
+		Pattern:    pattern,
+		Multiplier: multiplier,
+	}
+}
+
+// ParseMethodRates parses a comma separated list of pattern:multiplier pairs,
+// for example "debug_*:20,eth_getLogs:5"
+func ParseMethodRates(raw string) ([]MethodRate, error) {
+	rates := make([]MethodRate, 0)
+	if len(strings.TrimSpace(raw)) == 0 {
+		return rates, nil
+	}
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		// an url path may hold a colon, the multiplier is after the last one
+		idx := strings.LastIndex(item, ":")
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid method rate (%s), expected pattern:multiplier", item)
+		}
+		multiplier, err := strconv.ParseUint(item[idx+1:], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, NewMethodRate(item[:idx], multiplier))
+	}
+	return rates, nil
+}
+
+// ValidateMethodRates ensures the rate schedule isn't too long, every pattern
+// is set once with at most a trailing wildcard, and every multiplier is
+// between 1 and MaxMethodRateMultiplier
+func ValidateMethodRates(rates []MethodRate) error {
+	if len(rates) > MaxMethodRates {
+		return errors.Wrapf(ErrInvalidModProviderMethodRate, "too many method rates (%d/%d)", len(rates), MaxMethodRates)
+	}
+	seen := make(map[string]bool)
+	for _, rate := range rates {
+		if len(rate.Pattern) == 0 || len(rate.Pattern) > MaxMethodRatePattern {
+			return errors.Wrapf(ErrInvalidModProviderMethodRate, "pattern must be between 1 and %d characters", MaxMethodRatePattern)
+		}
+		if strings.Contains(strings.TrimSuffix(rate.Pattern, "*"), "*") {
+			return errors.Wrapf(ErrInvalidModProviderMethodRate, "pattern %s can only end with a wildcard", rate.Pattern)
+		}
+		if rate.Multiplier < 1 || rate.Multiplier > MaxMethodRateMultiplier {
+			return errors.Wrapf(ErrInvalidModProviderMethodRate, "multiplier of %s must be between 1 and %d", rate.Pattern, MaxMethodRateMultiplier)
+		}
+		if seen[rate.Pattern] {
+			return errors.Wrapf(ErrInvalidModProviderMethodRate, "duplicate pattern %s", rate.Pattern)
+		}
+		seen[rate.Pattern] = true
+	}
+	return nil
+}
+
+// MergeMethodRates merges the rate schedules of several services, like the
+// services of a bundle, keeping the highest multiplier of a pattern set by
+// more than one of them
+func MergeMethodRates(schedules ...[]MethodRate) []MethodRate {
+	var merged []MethodRate
+	index := make(map[string]int)
+	for _, rates := range schedules {
+		for _, rate := range rates {
+			i, ok := index[rate.Pattern]
+			if !ok {
+				index[rate.Pattern] = len(merged)
+				merged = append(merged, rate)
+				continue
+			}
+			if rate.Multiplier > merged[i].Multiplier {
+				merged[i].Multiplier = rate.Multiplier
+			}
+		}
+	}
+	return merged
+}
+
+// MethodRateMultiplier returns the multiplier of the rate schedule matching
+// the method (or url path). An exact pattern wins over a wildcard, and the
+// longest wildcard over the shorter ones. False is returned when no pattern
+// matches.
+func MethodRateMultiplier(rates []MethodRate, method string) (uint64, bool) {
+	if len(method) == 0 {
+		return 0, false
+	}
+	var multiplier uint64
+	longest := -1
+	for _, rate := range rates {
+		if rate.Pattern == method {
+			return rate.Multiplier, true
+		}
+		prefix := strings.TrimSuffix(rate.Pattern, "*")
+		if prefix != rate.Pattern && strings.HasPrefix(method, prefix) && len(prefix) > longest {
+			multiplier, longest = rate.Multiplier, len(prefix)
+		}
+	}
+	return multiplier, longest >= 0
+}
+
 func NewContract(provider common.PubKey, service common.Service, client common.PubKey) Contract {
 	return Contract{
 		Provider: provider,
@@ -34,6 +242,20 @@ func NewContract(provider common.PubKey, service common.Service, client common.P
 	}
 }
 
+func NewBundle(provider common.PubKey, name string, services common.Services) Bundle {
+	return Bundle{
+		Provider:         provider,
+		Name:             name,
+		Services:         services,
+		SubscriptionRate: make([]cosmos.Coin, 0),
+		PayAsYouGoRate:   make([]cosmos.Coin, 0),
+	}
+}
+
+func (bundle Bundle) Key() string {
+	return strconv.FormatUint(bundle.Id, 10)
+}
+
 func (contract Contract) Key() string {
 	return strconv.FormatUint(contract.Id, 10)
 }
@@ -45,6 +267,39 @@ func (contract Contract) GetSpender() common.PubKey {
 	return contract.Client
 }
 
+// AllowedSpenders returns the keys allowed to sign the arkauths and claims of
+// the contract, its list of spenders when set, its spender otherwise
+func (contract Contract) AllowedSpenders() []common.PubKey {
+	if len(contract.Spenders) > 0 {
+		return contract.Spenders
+	}
+	return []common.PubKey{contract.GetSpender()}
+}
+
+// MaxContractSpenders is the longest list of spenders a contract can be
+// restricted to
+const MaxContractSpenders = 16
+
+// ValidateContractSpenders ensures the list of spenders isn't too long and
+// holds valid pubkeys, each listed once. An empty list is valid and leaves the
+// contract to its client or delegate.
+func ValidateContractSpenders(spenders []common.PubKey) error {
+	if len(spenders) > MaxContractSpenders {
+		return errors.Wrapf(ErrInvalidContractSpenders, "too many spenders (%d/%d)", len(spenders), MaxContractSpenders)
+	}
+	seen := make(map[string]bool)
+	for _, spender := range spenders {
+		if _, err := common.NewPubKey(spender.String()); err != nil {
+			return errors.Wrapf(ErrInvalidContractSpenders, "invalid spender pubkey (%s): %s", spender, err)
+		}
+		if seen[spender.String()] {
+			return errors.Wrapf(ErrInvalidContractSpenders, "duplicate spender %s", spender)
+		}
+		seen[spender.String()] = true
+	}
+	return nil
+}
+
 // Expiration Contracts progress through the following states
 // Open -> Expired -> Settled
 // for Subscription contracts, they expire and settle on the same block
@@ -65,6 +320,37 @@ func (contract Contract) SettlementPeriodEnd() int64 {
 	return contract.Expiration()
 }
 
+// Debt returns what the client owes the provider at the given height and
+// isn't paid yet: the blocks gone by for a subscription, the nonce times the
+// rate for pay-as-you-go. It never exceeds what is left of the deposit.
+func (contract Contract) Debt(height int64) cosmos.Int {
+	var debt cosmos.Int
+	switch contract.Type {
+	case ContractType_SUBSCRIPTION:
+		// the provider earns the rate of each block gone by, what is left of
+		// the deposit goes back to the client on an early close
+		if height > contract.SettlementPeriodEnd() {
+			height = contract.SettlementPeriodEnd()
+		}
+		debt = contract.Rate.Amount.MulRaw(height - contract.Height).Sub(contract.Paid)
+	case ContractType_PAY_AS_YOU_GO:
+		debt = contract.Rate.Amount.MulRaw(contract.Nonce).Sub(contract.Paid)
+	default:
+		return cosmos.ZeroInt()
+	}
+
+	if debt.IsNegative() {
+		return cosmos.ZeroInt()
+	}
+
+	// sanity check, ensure provider cannot take more than deposited into the contract
+	if contract.Paid.Add(debt).GT(contract.Deposit) {
+		return contract.Deposit.Sub(contract.Paid)
+	}
+
+	return debt
+}
+
 func (contract Contract) IsPayAsYouGo() bool {
 	return contract.Type == ContractType_PAY_AS_YOU_GO
 }
@@ -73,6 +359,24 @@ func (contract Contract) IsSubscription() bool {
 	return contract.Type == ContractType_SUBSCRIPTION
 }
 
+// IsBundle returns whether the contract covers the services of a bundle
+func (contract Contract) IsBundle() bool {
+	return len(contract.Services) > 0
+}
+
+// ServiceSet returns every service the contract can be used for
+func (contract Contract) ServiceSet() common.Services {
+	if contract.IsBundle() {
+		return contract.Services
+	}
+	return common.Services{contract.Service}
+}
+
+// HasService checks whether the contract can be used for the given service
+func (contract Contract) HasService(service common.Service) bool {
+	return contract.ServiceSet().Contains(service)
+}
+
 func (contract Contract) IsOpenAuthorization() bool {
 	return contract.Authorization == ContractAuthorization_OPEN
 }
@@ -147,6 +451,27 @@ func (exp *ContractExpirationSet) Append(id uint64) {
 	exp.ContractSet.ContractIds = append(exp.ContractSet.ContractIds, id)
 }
 
+// Contains returns true if the contract is in the set
+func (exp *ContractExpirationSet) Contains(id uint64) bool {
+	for _, contractId := range exp.ContractSet.ContractIds {
+		if contractId == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove drops the contract from the set
+func (exp *ContractExpirationSet) Remove(id uint64) {
+	ids := exp.ContractSet.ContractIds[:0]
+	for _, contractId := range exp.ContractSet.ContractIds {
+		if contractId != id {
+			ids = append(ids, contractId)
+		}
+	}
+	exp.ContractSet.ContractIds = ids
+}
+
 func (contractAuth *ContractAuthorization) UnmarshalJSON(b []byte) error {
 	var item interface{}
 	if err := json.Unmarshal(b, &item); err != nil {
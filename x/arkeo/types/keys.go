@@ -15,6 +15,9 @@ const (
 
 	// MemStoreKey defines the in-memory store key
 	MemStoreKey = "mem_arkeo"
+
+	// TStoreKey defines the transient store key
+	TStoreKey = "transient_arkeo"
 )
 
 func KeyPrefix(p string) []byte {
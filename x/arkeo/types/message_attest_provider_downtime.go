@@ -0,0 +1,55 @@
+package types
+
+import (
+	"cosmossdk.io/errors"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const TypeMsgAttestProviderDowntime = "attest_provider_downtime"
+
+var _ sdk.Msg = &MsgAttestProviderDowntime{}
+
+func NewMsgAttestProviderDowntime(creator cosmos.AccAddress, contractId uint64, service string) *MsgAttestProviderDowntime {
+	return &MsgAttestProviderDowntime{
+		Creator:    creator,
+		ContractId: contractId,
+		Service:    service,
+	}
+}
+
+func (msg *MsgAttestProviderDowntime) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgAttestProviderDowntime) Type() string {
+	return TypeMsgAttestProviderDowntime
+}
+
+func (msg *MsgAttestProviderDowntime) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Creator}
+}
+
+func (msg *MsgAttestProviderDowntime) MustGetSigner() sdk.AccAddress {
+	return msg.Creator
+}
+
+func (msg *MsgAttestProviderDowntime) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgAttestProviderDowntime) ValidateBasic() error {
+	if msg.ContractId == 0 {
+		return errors.Wrapf(ErrContractNotFound, "contract id cannot be zero")
+	}
+	if len(msg.Service) > 0 {
+		if _, err := common.NewService(msg.Service); err != nil {
+			return errors.Wrapf(ErrInvalidService, "%s", msg.Service)
+		}
+	}
+	return nil
+}
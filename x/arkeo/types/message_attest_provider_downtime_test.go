@@ -0,0 +1,27 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttestProviderDowntimeValidateBasic(t *testing.T) {
+	acct := GetRandomBech32Addr()
+
+	// happy path
+	msg := NewMsgAttestProviderDowntime(acct, 3, "")
+	require.NoError(t, msg.ValidateBasic())
+
+	msg.ContractId = 0
+	require.ErrorIs(t, msg.ValidateBasic(), ErrContractNotFound)
+
+	// the service of a bundle contract
+	msg = NewMsgAttestProviderDowntime(acct, 3, common.ETHService.String())
+	require.NoError(t, msg.ValidateBasic())
+
+	msg.Service = "bogus"
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidService)
+}
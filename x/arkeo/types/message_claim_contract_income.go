@@ -43,6 +43,12 @@ func (msg *MsgClaimContractIncome) GetSignBytes() []byte {
 	return sdk.MustSortJSON(bz)
 }
 
+// ClaimItems is the number of claims carried by the message, counted against
+// the per block claim limit of the provider
+func (msg *MsgClaimContractIncome) ClaimItems() int64 {
+	return 1
+}
+
 func (msg *MsgClaimContractIncome) GetBytesToSign() []byte {
 	return GetBytesToSign(msg.ContractId, msg.Nonce)
 }
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"cosmossdk.io/errors"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const TypeMsgExtendContract = "extend_contract"
+
+var _ sdk.Msg = &MsgExtendContract{}
+
+func NewMsgExtendContract(creator cosmos.AccAddress, contractId uint64, duration int64, deposit cosmos.Int) *MsgExtendContract {
+	return &MsgExtendContract{
+		Creator:    creator,
+		ContractId: contractId,
+		Duration:   duration,
+		Deposit:    deposit,
+	}
+}
+
+func (msg *MsgExtendContract) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgExtendContract) Type() string {
+	return TypeMsgExtendContract
+}
+
+func (msg *MsgExtendContract) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Creator}
+}
+
+func (msg *MsgExtendContract) MustGetSigner() sdk.AccAddress {
+	return msg.Creator
+}
+
+func (msg *MsgExtendContract) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgExtendContract) ValidateBasic() error {
+	if msg.ContractId == 0 {
+		return errors.Wrapf(ErrContractNotFound, "contract id cannot be zero")
+	}
+	if msg.Duration <= 0 {
+		return errors.Wrapf(ErrOpenContractDuration, "duration must be positive")
+	}
+	if msg.Deposit.IsNil() || msg.Deposit.IsNegative() {
+		return errors.Wrapf(ErrInsufficientFunds, "deposit cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtendContractValidateBasic(t *testing.T) {
+	// setup
+	pubkey := GetRandomPubKey()
+	acct, err := pubkey.GetMyAddress()
+	require.NoError(t, err)
+
+	// happy path
+	msg := NewMsgExtendContract(acct, 50, 100, cosmos.NewInt(500))
+	require.NoError(t, msg.ValidateBasic())
+
+	// a pay-as-you-go contract can be extended without topping up its deposit
+	msg.Deposit = cosmos.ZeroInt()
+	require.NoError(t, msg.ValidateBasic())
+
+	msg.Deposit = cosmos.NewInt(-1)
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInsufficientFunds)
+
+	msg.Deposit = cosmos.NewInt(500)
+	msg.Duration = 0
+	require.ErrorIs(t, msg.ValidateBasic(), ErrOpenContractDuration)
+
+	msg.Duration = 100
+	msg.ContractId = 0
+	require.ErrorIs(t, msg.ValidateBasic(), ErrContractNotFound)
+}
@@ -1,6 +1,10 @@
 package types
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
 	"cosmossdk.io/errors"
 
 	"github.com/arkeonetwork/arkeo/common"
@@ -12,6 +16,10 @@ import (
 
 const TypeMsgModProvider = "mod_provider"
 
+// MetadataURILengthLimit is the hard limit on the length of a metadata uri,
+// the max length set by the configs can't go beyond it
+const MetadataURILengthLimit = 256
+
 var _ sdk.Msg = &MsgModProvider{}
 
 func NewMsgModProvider(creator cosmos.AccAddress, provider common.PubKey, service, metadataUri string,
@@ -83,9 +91,14 @@ func (msg *MsgModProvider) ValidateBasic() error {
 			return errors.Wrapf(ErrInvalidModProviderMetdataURI, "(%s)", err)
 		}
 	*/
-	// Ensure URIs don't get too long and cause chain bloat
-	if len(msg.MetadataUri) > 100 {
-		return errors.Wrapf(ErrInvalidModProviderMetdataURI, "length is too long (%d/100)", len(msg.MetadataUri))
+	// Ensure URIs don't get too long and cause chain bloat, the max length and
+	// the schemes allowed are checked against the configs by the handler
+	if len(msg.MetadataUri) > MetadataURILengthLimit {
+		return errors.Wrapf(ErrInvalidModProviderMetdataURI, "length is too long (%d/%d)", len(msg.MetadataUri), MetadataURILengthLimit)
+	}
+
+	if err := ValidateMetadataHash(msg.MetadataHash); err != nil {
+		return err
 	}
 
 	// check durations
@@ -119,5 +132,63 @@ func (msg *MsgModProvider) ValidateBasic() error {
 		return errors.Wrapf(ErrInvalidModProviderRate, "all pay-as-you-go rates must be positive")
 	}
 
+	if err := ValidatePayoutSplits(msg.PayoutSplits); err != nil {
+		return err
+	}
+
+	if err := ValidateMethodRates(msg.MethodRates); err != nil {
+		return err
+	}
+
+	if !msg.MinDeposit.IsNil() && msg.MinDeposit.IsNegative() {
+		return errors.Wrapf(ErrInvalidModProviderMinDeposit, "min deposit cannot be negative")
+	}
+
+	return nil
+}
+
+// MetadataHash returns the hex encoded sha256 of the metadata json, as
+// committed to by the provider in MsgModProvider
+func MetadataHash(metadata []byte) string {
+	hash := sha256.Sum256(metadata)
+	return hex.EncodeToString(hash[:])
+}
+
+// ValidateMetadataHash checks the metadata hash is either empty or a lower case
+// hex encoded sha256
+func ValidateMetadataHash(hash string) error {
+	if len(hash) == 0 {
+		return nil
+	}
+	if len(hash) != sha256.Size*2 || strings.ToLower(hash) != hash {
+		return errors.Wrapf(ErrInvalidModProviderMetadataHash, "expected a lower case hex sha256, got %q", hash)
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return errors.Wrapf(ErrInvalidModProviderMetadataHash, "%s", err)
+	}
 	return nil
 }
+
+// ValidateMetadataURI checks the metadata uri isn't longer than maxLength and
+// uses one of the comma separated schemes, an empty uri leaves the metadata of
+// the provider unchanged
+func ValidateMetadataURI(uri string, maxLength int64, schemes string) error {
+	if len(uri) == 0 {
+		return nil
+	}
+	if maxLength > 0 && int64(len(uri)) > maxLength {
+		return errors.Wrapf(ErrInvalidModProviderMetdataURI, "length is too long (%d/%d)", len(uri), maxLength)
+	}
+	if len(schemes) == 0 {
+		return nil
+	}
+	// the scheme is matched as a prefix rather than with url.Parse, whose
+	// behavior may change between golang versions
+	for _, scheme := range strings.Split(schemes, ",") {
+		prefix := strings.TrimSpace(scheme) + "://"
+		if len(uri) > len(prefix) && strings.EqualFold(uri[:len(prefix)], prefix) {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrInvalidModProviderMetdataURI, "uri must use one of the schemes %s", schemes)
+}
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/arkeonetwork/arkeo/common"
@@ -35,4 +37,182 @@ func TestModProviderValidateBasic(t *testing.T) {
 	msg.MetadataUri = "http://mad.hatter.net/testsdkfjlsdkfjlsdfjsldfjkdsljflsdjfkdsjflsdjkfsdjlfsdjkfldsjflksjdfljsdlkfjsdlkfjdsklfjsdlkfjsdkljflksdjfklsdjflskdjflksdjflksdjfldsjflksdjfldskjflsdkfjsdlkjfksdljflskdjfsdlkjfdksljflsdkjfkldsjfsdlkfjlksdjfklsdjflkdsjfklsdjfsdkljflksdjflksdfjdklsjfl?foo=baz"
 	err = msg.ValidateBasic()
 	require.ErrorIs(t, err, ErrInvalidModProviderMetdataURI)
+	msg.MetadataUri = "https://mad.hatter.net/metadata.json"
+
+	// metadata hash must be a hex sha256
+	msg.MetadataHash = MetadataHash([]byte(`{"version":"1"}`))
+	require.NoError(t, msg.ValidateBasic())
+	msg.MetadataHash = strings.ToUpper(msg.MetadataHash)
+	err = msg.ValidateBasic()
+	require.ErrorIs(t, err, ErrInvalidModProviderMetadataHash)
+	msg.MetadataHash = "abcd"
+	err = msg.ValidateBasic()
+	require.ErrorIs(t, err, ErrInvalidModProviderMetadataHash)
+}
+
+func TestModProviderValidatePayoutSplits(t *testing.T) {
+	pubkey := GetRandomPubKey()
+	acct, err := pubkey.GetMyAddress()
+	require.NoError(t, err)
+
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+
+	acc1 := GetRandomBech32Addr()
+	acc2 := GetRandomBech32Addr()
+
+	msg := MsgModProvider{
+		Creator:             acct,
+		Provider:            pubkey,
+		Service:             common.BTCService.String(),
+		MinContractDuration: 12,
+		MaxContractDuration: 30,
+		SubscriptionRate:    rates,
+		PayAsYouGoRate:      rates,
+		PayoutSplits: []PayoutSplit{
+			NewPayoutSplit(acc1, 7000),
+			NewPayoutSplit(acc2, 3000),
+		},
+	}
+	require.NoError(t, msg.ValidateBasic())
+
+	// weights do not sum to 100%
+	msg.PayoutSplits[1].Weight = 2000
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderPayoutSplit)
+
+	// zero weight
+	msg.PayoutSplits[0].Weight = 10000
+	msg.PayoutSplits[1].Weight = 0
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderPayoutSplit)
+
+	// duplicate address
+	msg.PayoutSplits[0].Weight = 7000
+	msg.PayoutSplits[1] = NewPayoutSplit(acc1, 3000)
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderPayoutSplit)
+}
+
+func TestModProviderValidateMethodRates(t *testing.T) {
+	pubkey := GetRandomPubKey()
+	acct, err := pubkey.GetMyAddress()
+	require.NoError(t, err)
+
+	rates, err := cosmos.ParseCoins("15uarkeo")
+	require.NoError(t, err)
+
+	methodRates, err := ParseMethodRates("debug_*:20, eth_getLogs:5,/v1/blocks:3")
+	require.NoError(t, err)
+	require.Equal(t, []MethodRate{
+		NewMethodRate("debug_*", 20),
+		NewMethodRate("eth_getLogs", 5),
+		NewMethodRate("/v1/blocks", 3),
+	}, methodRates)
+	_, err = ParseMethodRates("debug_*")
+	require.Error(t, err)
+
+	msg := MsgModProvider{
+		Creator:             acct,
+		Provider:            pubkey,
+		Service:             common.BTCService.String(),
+		MinContractDuration: 12,
+		MaxContractDuration: 30,
+		SubscriptionRate:    rates,
+		PayAsYouGoRate:      rates,
+		MethodRates:         methodRates,
+	}
+	require.NoError(t, msg.ValidateBasic())
+
+	// multiplier out of range
+	msg.MethodRates[0].Multiplier = 0
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderMethodRate)
+	msg.MethodRates[0].Multiplier = MaxMethodRateMultiplier + 1
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderMethodRate)
+	msg.MethodRates[0].Multiplier = MaxMethodRateMultiplier
+	require.NoError(t, msg.ValidateBasic())
+
+	// wildcard in the middle of the pattern, empty or duplicate pattern
+	msg.MethodRates[0].Pattern = "debug_*_call"
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderMethodRate)
+	msg.MethodRates[0].Pattern = ""
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderMethodRate)
+	msg.MethodRates[0].Pattern = "eth_getLogs"
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderMethodRate)
+
+	// too many rates
+	msg.MethodRates = nil
+	for i := 0; i <= MaxMethodRates; i++ {
+		msg.MethodRates = append(msg.MethodRates, NewMethodRate(fmt.Sprintf("method_%d", i), 2))
+	}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderMethodRate)
+	msg.MethodRates = msg.MethodRates[:MaxMethodRates]
+	require.NoError(t, msg.ValidateBasic())
+}
+
+func TestMethodRateMultiplier(t *testing.T) {
+	rates := []MethodRate{
+		NewMethodRate("debug_*", 20),
+		NewMethodRate("debug_trace*", 30),
+		NewMethodRate("debug_traceTransaction", 40),
+		NewMethodRate("eth_getLogs", 5),
+	}
+	for method, expected := range map[string]uint64{
+		"debug_getRawBlock":      20,
+		"debug_traceCall":        30,
+		"debug_traceTransaction": 40,
+		"eth_getLogs":            5,
+	} {
+		multiplier, ok := MethodRateMultiplier(rates, method)
+		require.True(t, ok, method)
+		require.Equal(t, expected, multiplier, method)
+	}
+	for _, method := range []string{"", "eth_getLogsX", "eth_blockNumber", "debug"} {
+		_, ok := MethodRateMultiplier(rates, method)
+		require.False(t, ok, method)
+	}
+}
+
+func TestMergeMethodRates(t *testing.T) {
+	btc := []MethodRate{
+		NewMethodRate("debug_*", 20),
+		NewMethodRate("eth_getLogs", 5),
+	}
+	eth := []MethodRate{
+		NewMethodRate("eth_getLogs", 8),
+		NewMethodRate("debug_*", 10),
+		NewMethodRate("/v1/blocks", 3),
+	}
+	// the highest multiplier of a pattern is kept
+	require.Equal(t, []MethodRate{
+		NewMethodRate("debug_*", 20),
+		NewMethodRate("eth_getLogs", 8),
+		NewMethodRate("/v1/blocks", 3),
+	}, MergeMethodRates(btc, eth))
+	require.Equal(t, btc, MergeMethodRates(btc, nil))
+	require.Empty(t, MergeMethodRates(nil, nil))
+}
+
+func TestSplitPayout(t *testing.T) {
+	acc1 := GetRandomBech32Addr()
+	acc2 := GetRandomBech32Addr()
+	acc3 := GetRandomBech32Addr()
+	splits := []PayoutSplit{
+		NewPayoutSplit(acc1, 3334),
+		NewPayoutSplit(acc2, 3333),
+		NewPayoutSplit(acc3, 3333),
+	}
+
+	for _, amt := range []int64{0, 1, 2, 10, 99, 101, 1_000_003} {
+		payouts := SplitPayout(cosmos.NewInt(amt), splits)
+		require.Len(t, payouts, 3)
+		sum := cosmos.ZeroInt()
+		for _, payout := range payouts {
+			require.False(t, payout.Amount.IsNegative())
+			sum = sum.Add(payout.Amount)
+		}
+		require.Equal(t, amt, sum.Int64())
+	}
+
+	payouts := SplitPayout(cosmos.NewInt(10), splits)
+	require.Equal(t, int64(4), payouts[0].Amount.Int64())
+	require.Equal(t, int64(3), payouts[1].Amount.Int64())
+	require.Equal(t, int64(3), payouts[2].Amount.Int64())
 }
@@ -68,10 +68,14 @@ func (msg *MsgOpenContract) ValidateBasic() error {
 		return errors.Wrapf(ErrInvalidPubKey, "invalid pubkey (%s)", err)
 	}
 
-	// verify service
-	_, err = common.NewService(msg.Service)
-	if err != nil {
-		return errors.Wrapf(ErrInvalidService, "invalid service (%s): %s", msg.Service, err)
+	// verify service, a bundle contract gets its services from the bundle
+	if msg.BundleId == 0 {
+		_, err = common.NewService(msg.Service)
+		if err != nil {
+			return errors.Wrapf(ErrInvalidService, "invalid service (%s): %s", msg.Service, err)
+		}
+	} else if len(msg.Service) > 0 {
+		return errors.Wrapf(ErrInvalidService, "cannot set both a service (%s) and a bundle (%d)", msg.Service, msg.BundleId)
 	}
 
 	// verify client
@@ -118,5 +122,24 @@ func (msg *MsgOpenContract) ValidateBasic() error {
 		return errors.Wrapf(ErrInvalidAuthorization, "pay-as-you-go contract cannot use open authorization")
 	}
 
+	// the spenders sign the requests, an open contract doesn't check them
+	if err := ValidateContractSpenders(msg.Spenders); err != nil {
+		return err
+	}
+	if len(msg.Spenders) > 0 && msg.Authorization == ContractAuthorization_OPEN {
+		return errors.Wrapf(ErrInvalidAuthorization, "contract restricted to spenders cannot use open authorization")
+	}
+
+	// the deposit paid in another denom is swapped for the rate denom, the
+	// accepted denoms are checked against the params
+	if len(msg.DepositDenom) > 0 {
+		if err := sdk.ValidateDenom(msg.DepositDenom); err != nil {
+			return errors.Wrapf(ErrOpenContractDepositDenom, "invalid deposit denom (%s): %s", msg.DepositDenom, err)
+		}
+		if msg.DepositDenom == msg.Rate.Denom {
+			return errors.Wrapf(ErrOpenContractDepositDenom, "deposit denom %s is the rate denom, leave it empty", msg.DepositDenom)
+		}
+	}
+
 	return nil
 }
@@ -36,8 +36,90 @@ func TestBondProviderValidateBasic(t *testing.T) {
 	err = msg.ValidateBasic()
 	require.NoError(t, err)
 
+	// a bundle contract takes its services from the bundle
+	msg.BundleId = 1
+	err = msg.ValidateBasic()
+	require.ErrorIs(t, err, ErrInvalidService)
+	msg.Service = ""
+	err = msg.ValidateBasic()
+	require.NoError(t, err)
+	msg.BundleId = 0
+	err = msg.ValidateBasic()
+	require.ErrorIs(t, err, ErrInvalidService)
+	msg.Service = common.BTCService.String()
+
 	msg.Authorization = ContractAuthorization_OPEN
 	msg.ContractType = ContractType_PAY_AS_YOU_GO
 	err = msg.ValidateBasic()
 	require.ErrorIs(t, err, ErrInvalidAuthorization)
 }
+
+func TestOpenContractValidateSpenders(t *testing.T) {
+	pubkey := GetRandomPubKey()
+	acct, err := pubkey.GetMyAddress()
+	require.NoError(t, err)
+
+	msg := MsgOpenContract{
+		Creator:          acct,
+		Provider:         GetRandomPubKey(),
+		Client:           pubkey,
+		Service:          common.BTCService.String(),
+		ContractType:     ContractType_PAY_AS_YOU_GO,
+		Duration:         100,
+		Rate:             cosmos.NewInt64Coin("uarkeo", 10),
+		QueriesPerMinute: 10,
+		Spenders:         []common.PubKey{GetRandomPubKey(), GetRandomPubKey()},
+	}
+	require.NoError(t, msg.ValidateBasic())
+
+	// listed twice
+	msg.Spenders = append(msg.Spenders, msg.Spenders[0])
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidContractSpenders)
+
+	// not a pubkey
+	msg.Spenders = []common.PubKey{common.PubKey("bogus")}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidContractSpenders)
+
+	// too many
+	msg.Spenders = nil
+	for i := 0; i <= MaxContractSpenders; i++ {
+		msg.Spenders = append(msg.Spenders, GetRandomPubKey())
+	}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidContractSpenders)
+	msg.Spenders = msg.Spenders[:MaxContractSpenders]
+	require.NoError(t, msg.ValidateBasic())
+
+	// an open subscription doesn't check who signs its requests
+	msg.ContractType = ContractType_SUBSCRIPTION
+	msg.Authorization = ContractAuthorization_OPEN
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidAuthorization)
+	msg.Spenders = nil
+	require.NoError(t, msg.ValidateBasic())
+}
+
+func TestOpenContractValidateDepositDenom(t *testing.T) {
+	pubkey := GetRandomPubKey()
+	acct, err := pubkey.GetMyAddress()
+	require.NoError(t, err)
+
+	msg := MsgOpenContract{
+		Creator:          acct,
+		Provider:         GetRandomPubKey(),
+		Client:           pubkey,
+		Service:          common.BTCService.String(),
+		ContractType:     ContractType_SUBSCRIPTION,
+		Duration:         100,
+		Rate:             cosmos.NewInt64Coin("uarkeo", 10),
+		QueriesPerMinute: 10,
+		DepositDenom:     "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2",
+	}
+	require.NoError(t, msg.ValidateBasic())
+
+	// not a denom
+	msg.DepositDenom = "1bad denom"
+	require.ErrorIs(t, msg.ValidateBasic(), ErrOpenContractDepositDenom)
+
+	// the rate denom is paid without a swap
+	msg.DepositDenom = "uarkeo"
+	require.ErrorIs(t, msg.ValidateBasic(), ErrOpenContractDepositDenom)
+}
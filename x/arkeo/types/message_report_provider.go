@@ -0,0 +1,56 @@
+package types
+
+import (
+	"cosmossdk.io/errors"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const TypeMsgReportProvider = "report_provider"
+
+// MaxReportReasonLength limits the reason of a report, to keep them from
+// bloating the chain
+const MaxReportReasonLength = 256
+
+var _ sdk.Msg = &MsgReportProvider{}
+
+func NewMsgReportProvider(creator cosmos.AccAddress, contractId uint64, reason string) *MsgReportProvider {
+	return &MsgReportProvider{
+		Creator:    creator,
+		ContractId: contractId,
+		Reason:     reason,
+	}
+}
+
+func (msg *MsgReportProvider) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgReportProvider) Type() string {
+	return TypeMsgReportProvider
+}
+
+func (msg *MsgReportProvider) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Creator}
+}
+
+func (msg *MsgReportProvider) MustGetSigner() sdk.AccAddress {
+	return msg.Creator
+}
+
+func (msg *MsgReportProvider) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgReportProvider) ValidateBasic() error {
+	if msg.ContractId == 0 {
+		return errors.Wrapf(ErrContractNotFound, "contract id cannot be zero")
+	}
+	if len(msg.Reason) == 0 || len(msg.Reason) > MaxReportReasonLength {
+		return errors.Wrapf(ErrInvalidReportReason, "reason must be between 1 and %d characters", MaxReportReasonLength)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportProviderValidateBasic(t *testing.T) {
+	acct := GetRandomBech32Addr()
+
+	// happy path
+	msg := NewMsgReportProvider(acct, 3, "stale blocks")
+	require.NoError(t, msg.ValidateBasic())
+
+	msg.ContractId = 0
+	require.ErrorIs(t, msg.ValidateBasic(), ErrContractNotFound)
+	msg.ContractId = 3
+
+	msg.Reason = ""
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidReportReason)
+	msg.Reason = strings.Repeat("a", MaxReportReasonLength+1)
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidReportReason)
+}
@@ -0,0 +1,116 @@
+package types
+
+import (
+	"cosmossdk.io/errors"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	types "github.com/cosmos/cosmos-sdk/types"
+)
+
+const TypeMsgSetBundle = "set_bundle"
+
+// limits of a bundle, to keep them from bloating the chain
+const (
+	MaxBundleNameLength = 64
+	MaxBundleServices   = 10
+)
+
+var _ sdk.Msg = &MsgSetBundle{}
+
+func NewMsgSetBundle(creator cosmos.AccAddress, provider common.PubKey, id uint64, name string, services []string, subscriptionRate, payAsYouGoRate types.Coins) *MsgSetBundle {
+	return &MsgSetBundle{
+		Creator:          creator,
+		Provider:         provider,
+		Id:               id,
+		Name:             name,
+		Services:         services,
+		SubscriptionRate: subscriptionRate,
+		PayAsYouGoRate:   payAsYouGoRate,
+	}
+}
+
+func (msg *MsgSetBundle) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgSetBundle) Type() string {
+	return TypeMsgSetBundle
+}
+
+func (msg *MsgSetBundle) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Creator}
+}
+
+func (msg *MsgSetBundle) MustGetSigner() sdk.AccAddress {
+	return msg.Creator
+}
+
+func (msg *MsgSetBundle) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+// ParseServices parses the services of the bundle, rejecting duplicates
+func (msg *MsgSetBundle) ParseServices() (common.Services, error) {
+	services := make(common.Services, 0, len(msg.Services))
+	for _, name := range msg.Services {
+		service, err := common.NewService(name)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidService, "invalid service (%s): %s", name, err)
+		}
+		if services.Contains(service) {
+			return nil, errors.Wrapf(ErrInvalidBundle, "duplicate service %s", name)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+func (msg *MsgSetBundle) ValidateBasic() error {
+	// verify pubkey
+	_, err := common.NewPubKey(msg.Provider.String())
+	if err != nil {
+		return errors.Wrapf(ErrInvalidPubKey, "invalid provider pubkey (%s): %s", msg.Provider, err)
+	}
+
+	signer := msg.MustGetSigner()
+	provider, err := msg.Provider.GetMyAddress()
+	if err != nil {
+		return err
+	}
+	if !signer.Equals(provider) {
+		return errors.Wrapf(ErrProviderBadSigner, "Signer: %s, Provider Address: %s", msg.GetSigners(), provider)
+	}
+
+	if len(msg.Name) == 0 || len(msg.Name) > MaxBundleNameLength {
+		return errors.Wrapf(ErrInvalidBundle, "name must be between 1 and %d characters", MaxBundleNameLength)
+	}
+
+	if len(msg.Services) < 2 || len(msg.Services) > MaxBundleServices {
+		return errors.Wrapf(ErrInvalidBundle, "a bundle must have between 2 and %d services (%d)", MaxBundleServices, len(msg.Services))
+	}
+	if _, err := msg.ParseServices(); err != nil {
+		return err
+	}
+
+	subRate := cosmos.NewCoins(msg.SubscriptionRate...)
+	if err := subRate.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid subscription rate")
+	}
+	if !subRate.IsAllPositive() {
+		return errors.Wrapf(ErrInvalidModProviderRate, "all subscription rates must be positive")
+	}
+
+	payRate := cosmos.NewCoins(msg.PayAsYouGoRate...)
+	if err := payRate.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid pay-as-you-go rate")
+	}
+	if !payRate.IsAllPositive() {
+		return errors.Wrapf(ErrInvalidModProviderRate, "all pay-as-you-go rates must be positive")
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetBundleValidateBasic(t *testing.T) {
+	// setup
+	pubkey := GetRandomPubKey()
+	acct, err := pubkey.GetMyAddress()
+	require.NoError(t, err)
+
+	rates, err := cosmos.ParseCoins("25uarkeo")
+	require.NoError(t, err)
+
+	// happy path
+	msg := MsgSetBundle{
+		Creator:          acct,
+		Provider:         pubkey,
+		Name:             "btc",
+		Services:         []string{common.BTCService.String(), "btc-mainnet-unchained"},
+		SubscriptionRate: rates,
+		PayAsYouGoRate:   rates,
+	}
+	require.NoError(t, msg.ValidateBasic())
+	services, err := msg.ParseServices()
+	require.NoError(t, err)
+	require.Equal(t, common.Services{common.BTCService, 34}, services)
+
+	// signed by someone else
+	msg.Creator = GetRandomBech32Addr()
+	require.ErrorIs(t, msg.ValidateBasic(), ErrProviderBadSigner)
+	msg.Creator = acct
+
+	// missing name
+	msg.Name = ""
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidBundle)
+	msg.Name = "btc"
+
+	// a single service
+	msg.Services = []string{common.BTCService.String()}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidBundle)
+
+	// duplicate service
+	msg.Services = []string{common.BTCService.String(), common.BTCService.String()}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidBundle)
+
+	// unknown service
+	msg.Services = []string{common.BTCService.String(), "bogus"}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidService)
+	msg.Services = []string{common.BTCService.String(), "btc-mainnet-unchained"}
+
+	// bad rates
+	msg.PayAsYouGoRate = cosmos.Coins{cosmos.NewInt64Coin("uarkeo", 0)}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidModProviderRate)
+}
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"net"
+
+	"cosmossdk.io/errors"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const TypeMsgSetContractConfig = "set_contract_config"
+
+// limits of the contract configurations, to keep them from bloating the chain
+const (
+	MaxContractConfigListLength  = 32
+	MaxContractConfigValueLength = 256
+)
+
+var _ sdk.Msg = &MsgSetContractConfig{}
+
+func NewMsgSetContractConfig(creator cosmos.AccAddress, contractId uint64, cors ContractCORs, whitelistIps []string, perUserRateLimit int64) *MsgSetContractConfig {
+	return &MsgSetContractConfig{
+		Creator:          creator,
+		ContractId:       contractId,
+		Cors:             cors,
+		WhitelistIps:     whitelistIps,
+		PerUserRateLimit: perUserRateLimit,
+	}
+}
+
+func (msg *MsgSetContractConfig) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgSetContractConfig) Type() string {
+	return TypeMsgSetContractConfig
+}
+
+func (msg *MsgSetContractConfig) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Creator}
+}
+
+func (msg *MsgSetContractConfig) MustGetSigner() sdk.AccAddress {
+	return msg.Creator
+}
+
+func (msg *MsgSetContractConfig) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgSetContractConfig) ValidateBasic() error {
+	if msg.ContractId == 0 {
+		return errors.Wrapf(ErrContractNotFound, "contract id cannot be zero")
+	}
+	lists := map[string][]string{
+		"cors allow origins": msg.Cors.AllowOrigins,
+		"cors allow methods": msg.Cors.AllowMethods,
+		"cors allow headers": msg.Cors.AllowHeaders,
+		"whitelist ips":      msg.WhitelistIps,
+	}
+	for name, list := range lists {
+		if len(list) > MaxContractConfigListLength {
+			return errors.Wrapf(ErrInvalidContractConfig, "too many %s (%d/%d)", name, len(list), MaxContractConfigListLength)
+		}
+		for _, value := range list {
+			if len(value) == 0 || len(value) > MaxContractConfigValueLength {
+				return errors.Wrapf(ErrInvalidContractConfig, "%s must be between 1 and %d characters", name, MaxContractConfigValueLength)
+			}
+		}
+	}
+	for _, ip := range msg.WhitelistIps {
+		if net.ParseIP(ip) == nil {
+			return errors.Wrapf(ErrInvalidContractConfig, "invalid whitelist ip: %s", ip)
+		}
+	}
+	if msg.PerUserRateLimit < 0 {
+		return errors.Wrapf(ErrInvalidContractConfig, "per user rate limit cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetContractConfigValidateBasic(t *testing.T) {
+	acct := GetRandomBech32Addr()
+	cors := ContractCORs{
+		AllowOrigins: []string{"https://example.com"},
+		AllowMethods: []string{"GET", "POST"},
+	}
+
+	// happy path
+	msg := NewMsgSetContractConfig(acct, 3, cors, []string{"10.0.0.1", "::1"}, 60)
+	require.NoError(t, msg.ValidateBasic())
+	require.NoError(t, NewMsgSetContractConfig(acct, 3, ContractCORs{}, nil, 0).ValidateBasic())
+
+	msg.ContractId = 0
+	require.ErrorIs(t, msg.ValidateBasic(), ErrContractNotFound)
+	msg.ContractId = 3
+
+	msg.PerUserRateLimit = -1
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidContractConfig)
+	msg.PerUserRateLimit = 60
+
+	msg.WhitelistIps = []string{"10.0.0.300"}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidContractConfig)
+	msg.WhitelistIps = make([]string, MaxContractConfigListLength+1)
+	for i := range msg.WhitelistIps {
+		msg.WhitelistIps[i] = "10.0.0.1"
+	}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidContractConfig)
+	msg.WhitelistIps = nil
+
+	msg.Cors.AllowHeaders = []string{""}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidContractConfig)
+	msg.Cors.AllowHeaders = []string{strings.Repeat("a", MaxContractConfigValueLength+1)}
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidContractConfig)
+	msg.Cors.AllowHeaders = nil
+	require.NoError(t, msg.ValidateBasic())
+}
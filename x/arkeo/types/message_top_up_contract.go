@@ -0,0 +1,52 @@
+package types
+
+import (
+	"cosmossdk.io/errors"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const TypeMsgTopUpContract = "top_up_contract"
+
+var _ sdk.Msg = &MsgTopUpContract{}
+
+func NewMsgTopUpContract(creator cosmos.AccAddress, contractId uint64, deposit cosmos.Int) *MsgTopUpContract {
+	return &MsgTopUpContract{
+		Creator:    creator,
+		ContractId: contractId,
+		Deposit:    deposit,
+	}
+}
+
+func (msg *MsgTopUpContract) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgTopUpContract) Type() string {
+	return TypeMsgTopUpContract
+}
+
+func (msg *MsgTopUpContract) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Creator}
+}
+
+func (msg *MsgTopUpContract) MustGetSigner() sdk.AccAddress {
+	return msg.Creator
+}
+
+func (msg *MsgTopUpContract) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgTopUpContract) ValidateBasic() error {
+	if msg.ContractId == 0 {
+		return errors.Wrapf(ErrContractNotFound, "contract id cannot be zero")
+	}
+	if msg.Deposit.IsNil() || !msg.Deposit.IsPositive() {
+		return errors.Wrapf(ErrInsufficientFunds, "deposit must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopUpContractValidateBasic(t *testing.T) {
+	// setup
+	pubkey := GetRandomPubKey()
+	acct, err := pubkey.GetMyAddress()
+	require.NoError(t, err)
+
+	// happy path
+	msg := NewMsgTopUpContract(acct, 50, cosmos.NewInt(500))
+	require.NoError(t, msg.ValidateBasic())
+
+	msg.Deposit = cosmos.ZeroInt()
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInsufficientFunds)
+	msg.Deposit = cosmos.NewInt(-1)
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInsufficientFunds)
+
+	msg.Deposit = cosmos.NewInt(500)
+	msg.ContractId = 0
+	require.ErrorIs(t, msg.ValidateBasic(), ErrContractNotFound)
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"cosmossdk.io/errors"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+const TypeMsgUpdateContractDelegate = "update_contract_delegate"
+
+var _ sdk.Msg = &MsgUpdateContractDelegate{}
+
+func NewMsgUpdateContractDelegate(creator cosmos.AccAddress, contractId uint64, delegate common.PubKey) *MsgUpdateContractDelegate {
+	return &MsgUpdateContractDelegate{
+		Creator:    creator,
+		ContractId: contractId,
+		Delegate:   delegate,
+	}
+}
+
+func (msg *MsgUpdateContractDelegate) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgUpdateContractDelegate) Type() string {
+	return TypeMsgUpdateContractDelegate
+}
+
+func (msg *MsgUpdateContractDelegate) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Creator}
+}
+
+func (msg *MsgUpdateContractDelegate) MustGetSigner() sdk.AccAddress {
+	return msg.Creator
+}
+
+func (msg *MsgUpdateContractDelegate) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgUpdateContractDelegate) ValidateBasic() error {
+	if msg.ContractId == 0 {
+		return errors.Wrapf(ErrContractNotFound, "contract id cannot be zero")
+	}
+	// an empty delegate lets the client spend the contract itself
+	if !msg.Delegate.IsEmpty() {
+		if _, err := common.NewPubKey(msg.Delegate.String()); err != nil {
+			return errors.Wrapf(ErrInvalidPubKey, "invalid delegate pubkey (%s)", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateContractDelegateValidateBasic(t *testing.T) {
+	// setup
+	pubkey := GetRandomPubKey()
+	acct, err := pubkey.GetMyAddress()
+	require.NoError(t, err)
+
+	// happy path
+	msg := NewMsgUpdateContractDelegate(acct, 50, GetRandomPubKey())
+	require.NoError(t, msg.ValidateBasic())
+
+	// the delegate can be removed
+	msg.Delegate = common.EmptyPubKey
+	require.NoError(t, msg.ValidateBasic())
+
+	msg.Delegate = common.PubKey("bogus")
+	require.ErrorIs(t, msg.ValidateBasic(), ErrInvalidPubKey)
+
+	msg.Delegate = GetRandomPubKey()
+	msg.ContractId = 0
+	require.ErrorIs(t, msg.ValidateBasic(), ErrContractNotFound)
+}
@@ -1,8 +1,34 @@
 package types
 
 import (
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	paramtypes "github.com/cosmos/cosmos-sdk/x/params/types"
 	"gopkg.in/yaml.v2"
+
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	"github.com/arkeonetwork/arkeo/x/arkeo/configs"
+)
+
+var (
+	KeyDepositDenoms                    = []byte("DepositDenoms")
+	DefaultDepositDenoms []DepositDenom = nil
+
+	KeyMinContractDeposit     = []byte("MinContractDeposit")
+	DefaultMinContractDeposit = cosmos.ZeroInt()
+
+	KeyDowntimeAttestationThreshold            = []byte("DowntimeAttestationThreshold")
+	DefaultDowntimeAttestationThreshold uint64 = 5
+
+	KeyDowntimeAttestationWindow           = []byte("DowntimeAttestationWindow")
+	DefaultDowntimeAttestationWindow int64 = 14400 // about a day
+
+	KeyDowntimeAttestationInterval           = []byte("DowntimeAttestationInterval")
+	DefaultDowntimeAttestationInterval int64 = 1200 // about two hours
+
+	KeyDowntimeSlashBasisPoints           = []byte("DowntimeSlashBasisPoints")
+	DefaultDowntimeSlashBasisPoints int64 = 100
 )
 
 var _ paramtypes.ParamSet = (*Params)(nil)
@@ -19,21 +45,146 @@ func NewParams() Params {
 
 // DefaultParams returns a default set of parameters
 func DefaultParams() Params {
-	return NewParams()
+	params := NewParams()
+	params.DepositDenoms = DefaultDepositDenoms
+	params.MinContractDeposit = DefaultMinContractDeposit
+	params.DowntimeAttestationThreshold = DefaultDowntimeAttestationThreshold
+	params.DowntimeAttestationWindow = DefaultDowntimeAttestationWindow
+	params.DowntimeAttestationInterval = DefaultDowntimeAttestationInterval
+	params.DowntimeSlashBasisPoints = DefaultDowntimeSlashBasisPoints
+	return params
 }
 
 // ParamSetPairs get the params.ParamSet
 func (p *Params) ParamSetPairs() paramtypes.ParamSetPairs {
-	return paramtypes.ParamSetPairs{}
+	return paramtypes.ParamSetPairs{
+		paramtypes.NewParamSetPair(KeyDepositDenoms, &p.DepositDenoms, validateDepositDenoms),
+		paramtypes.NewParamSetPair(KeyMinContractDeposit, &p.MinContractDeposit, validateMinContractDeposit),
+		paramtypes.NewParamSetPair(KeyDowntimeAttestationThreshold, &p.DowntimeAttestationThreshold, validateDowntimeAttestationThreshold),
+		paramtypes.NewParamSetPair(KeyDowntimeAttestationWindow, &p.DowntimeAttestationWindow, validateBlocks("downtime attestation window")),
+		paramtypes.NewParamSetPair(KeyDowntimeAttestationInterval, &p.DowntimeAttestationInterval, validateBlocks("downtime attestation interval")),
+		paramtypes.NewParamSetPair(KeyDowntimeSlashBasisPoints, &p.DowntimeSlashBasisPoints, validateDowntimeSlashBasisPoints),
+	}
 }
 
 // Validate validates the set of params
 func (p Params) Validate() error {
+	if err := validateDepositDenoms(p.DepositDenoms); err != nil {
+		return err
+	}
+	if err := validateMinContractDeposit(p.MinContractDeposit); err != nil {
+		return err
+	}
+	if err := validateBlocks("downtime attestation window")(p.DowntimeAttestationWindow); err != nil {
+		return err
+	}
+	if err := validateBlocks("downtime attestation interval")(p.DowntimeAttestationInterval); err != nil {
+		return err
+	}
+	if err := validateDowntimeSlashBasisPoints(p.DowntimeSlashBasisPoints); err != nil {
+		return err
+	}
+	if p.DowntimeAttestationThreshold > 0 && p.DowntimeAttestationWindow == 0 {
+		return fmt.Errorf("downtime attestation window must be set when attestations are enabled")
+	}
+	// the last attestation of a contract is kept as long as the window, it
+	// can't enforce a longer interval
+	if p.DowntimeAttestationThreshold > 0 && p.DowntimeAttestationInterval > p.DowntimeAttestationWindow {
+		return fmt.Errorf("downtime attestation interval (%d) must not exceed the window (%d)", p.DowntimeAttestationInterval, p.DowntimeAttestationWindow)
+	}
 	return nil
 }
 
+// DepositDenom returns the accepted deposit denom, false when the denom isn't
+// accepted
+func (p Params) DepositDenom(denom string) (DepositDenom, bool) {
+	for _, d := range p.DepositDenoms {
+		if d.Denom == denom {
+			return d, true
+		}
+	}
+	return DepositDenom{}, false
+}
+
 // String implements the Stringer interface.
 func (p Params) String() string {
 	out, _ := yaml.Marshal(p)
 	return string(out)
 }
+
+// Cost returns the amount of the denom paying for the native deposit, rounded
+// up so the reserve never sells native tokens below the rate
+func (d DepositDenom) Cost(deposit cosmos.Int) cosmos.Int {
+	return sdk.NewDecFromInt(deposit).Quo(d.Rate).Ceil().TruncateInt()
+}
+
+func validateDepositDenoms(i interface{}) error {
+	v, ok := i.([]DepositDenom)
+	if !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	seen := make(map[string]bool, len(v))
+	for _, d := range v {
+		if err := sdk.ValidateDenom(d.Denom); err != nil {
+			return fmt.Errorf("invalid deposit denom: %w", err)
+		}
+		if d.Denom == configs.Denom {
+			return fmt.Errorf("invalid deposit denom: %s is the native denom", d.Denom)
+		}
+		if seen[d.Denom] {
+			return fmt.Errorf("invalid deposit denom: %s is listed twice", d.Denom)
+		}
+		seen[d.Denom] = true
+		if d.Rate.IsNil() || !d.Rate.IsPositive() {
+			return fmt.Errorf("invalid deposit denom: rate of %s must be positive", d.Denom)
+		}
+	}
+	return nil
+}
+
+func validateMinContractDeposit(i interface{}) error {
+	v, ok := i.(cosmos.Int)
+	if !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	// unset, no floor
+	if v.IsNil() {
+		return nil
+	}
+	if v.IsNegative() {
+		return fmt.Errorf("min contract deposit must not be negative: %s", v)
+	}
+	return nil
+}
+
+func validateDowntimeAttestationThreshold(i interface{}) error {
+	if _, ok := i.(uint64); !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	return nil
+}
+
+// validateBlocks validates a number of blocks
+func validateBlocks(name string) func(i interface{}) error {
+	return func(i interface{}) error {
+		v, ok := i.(int64)
+		if !ok {
+			return fmt.Errorf("invalid parameter type: %T", i)
+		}
+		if v < 0 {
+			return fmt.Errorf("%s must not be negative: %d", name, v)
+		}
+		return nil
+	}
+}
+
+func validateDowntimeSlashBasisPoints(i interface{}) error {
+	v, ok := i.(int64)
+	if !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	if v < 0 || v > configs.MaxBasisPoints {
+		return fmt.Errorf("downtime slash basis points must be between 0 and %d: %d", configs.MaxBasisPoints, v)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package types
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/common"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+)
+
+// releasedFields are the field numbers of released messages, stored on chain,
+// exported with the genesis or signed by clients. Fields can be added to these
+// messages, but a released field must keep its number and type, and the number
+// of a removed field must be reserved.
+var releasedFields = map[reflect.Type]map[string]int{
+	reflect.TypeOf(Provider{}): {
+		"pub_key":               1,
+		"service":               2,
+		"metadata_uri":          3,
+		"metadata_nonce":        4,
+		"status":                5,
+		"min_contract_duration": 6,
+		"max_contract_duration": 7,
+		"subscription_rate":     8,
+		"pay_as_you_go_rate":    9,
+		"bond":                  10,
+		"last_update":           11,
+		"settlement_duration":   12,
+	},
+	reflect.TypeOf(Contract{}): {
+		"provider":            1,
+		"service":             2,
+		"client":              3,
+		"delegate":            4,
+		"type":                5,
+		"height":              6,
+		"duration":            7,
+		"rate":                8,
+		"deposit":             9,
+		"paid":                10,
+		"nonce":               11,
+		"settlement_height":   12,
+		"id":                  13,
+		"settlement_duration": 14,
+		"authorization":       15,
+		"queries_per_minute":  16,
+	},
+	reflect.TypeOf(GenesisState{}): {
+		"params":                   1,
+		"providers":                2,
+		"contracts":                3,
+		"next_contract_id":         4,
+		"contract_expiration_sets": 5,
+		"user_contract_sets":       6,
+		"version":                  7,
+	},
+	reflect.TypeOf(MsgClaimContractIncome{}): {
+		"creator":     1,
+		"contract_id": 2,
+		"signature":   4,
+		"nonce":       5,
+	},
+}
+
+// reservedFields are the numbers of removed fields, they must not be reused
+var reservedFields = map[reflect.Type][]int{
+	reflect.TypeOf(MsgClaimContractIncome{}): {3},
+}
+
+func TestProtoFieldNumbers(t *testing.T) {
+	for typ, released := range releasedFields {
+		fields := make(map[string]int)
+		numbers := make(map[int]string)
+		for _, prop := range proto.GetProperties(typ).Prop {
+			if prop.Tag == 0 {
+				continue
+			}
+			fields[prop.OrigName] = prop.Tag
+			numbers[prop.Tag] = prop.OrigName
+		}
+		for name, number := range released {
+			require.Equalf(t, number, fields[name], "%s.%s must keep field number %d, reserve it when the field is removed", typ.Name(), name, number)
+		}
+		for _, number := range reservedFields[typ] {
+			name, used := numbers[number]
+			require.Falsef(t, used, "%s.%s reuses the reserved field number %d", typ.Name(), name, number)
+		}
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	buf, err := os.ReadFile(filepath.Join("testdata", "v1", name))
+	require.NoError(t, err)
+	bz, err := hex.DecodeString(strings.TrimSpace(string(buf)))
+	require.NoError(t, err)
+	return bz
+}
+
+// TestProtoCompatibility decodes records serialized by the first release, the
+// fixtures must never be regenerated. Fields added since then decode to their
+// zero value, and the records encode to the same bytes.
+func TestProtoCompatibility(t *testing.T) {
+	providerPubKey := common.PubKey("tarkeopub1addwnpepqtsg8syrpcn60t2nnvnhtk6psr8qxlrtwjk8rmpkhxk9vy9wkd8ewmqv7rh")
+	clientPubKey := common.PubKey("tarkeopub1addwnpepq2res6tu0m73ulk5sepgp6g3y37schfgymxy8z6l3lc78k7ju9u45yajwem")
+
+	bz := readFixture(t, "provider.hex")
+	var provider Provider
+	require.NoError(t, provider.Unmarshal(bz))
+	require.Equal(t, providerPubKey, provider.PubKey)
+	require.Equal(t, common.BTCService, provider.Service)
+	require.Equal(t, "localhost:3636", provider.MetadataUri)
+	require.Equal(t, uint64(0), provider.MetadataNonce)
+	require.Equal(t, ProviderStatus_ONLINE, provider.Status)
+	require.Equal(t, int64(3), provider.MinContractDuration)
+	require.Equal(t, int64(10), provider.MaxContractDuration)
+	require.Equal(t, cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 10)), cosmos.NewCoins(provider.SubscriptionRate...))
+	require.Equal(t, cosmos.NewCoins(cosmos.NewInt64Coin("uarkeo", 3)), cosmos.NewCoins(provider.PayAsYouGoRate...))
+	require.Equal(t, int64(1000000000000), provider.Bond.Int64())
+	require.Equal(t, int64(1), provider.LastUpdate)
+	require.Equal(t, int64(11), provider.SettlementDuration)
+	require.Empty(t, provider.PayoutSplits)
+	require.Zero(t, provider.Strikes)
+	reencoded, err := provider.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, bz, reencoded)
+
+	bz = readFixture(t, "contract.hex")
+	var contract Contract
+	require.NoError(t, contract.Unmarshal(bz))
+	require.Equal(t, providerPubKey, contract.Provider)
+	require.Equal(t, common.BTCService, contract.Service)
+	require.Equal(t, clientPubKey, contract.Client)
+	require.True(t, contract.Delegate.IsEmpty())
+	require.Equal(t, ContractType_PAY_AS_YOU_GO, contract.Type)
+	require.Equal(t, int64(2), contract.Height)
+	require.Equal(t, int64(10), contract.Duration)
+	require.Equal(t, cosmos.NewInt64Coin("uarkeo", 3), contract.Rate)
+	require.Equal(t, int64(3), contract.Deposit.Int64())
+	require.Equal(t, int64(3), contract.Paid.Int64())
+	require.Equal(t, int64(1), contract.Nonce)
+	require.Equal(t, int64(15), contract.SettlementHeight)
+	require.Equal(t, uint64(1), contract.Id)
+	require.Equal(t, int64(11), contract.SettlementDuration)
+	require.Equal(t, ContractAuthorization_STRICT, contract.Authorization)
+	require.Equal(t, int64(1), contract.QueriesPerMinute)
+	require.Empty(t, contract.Services)
+	require.Zero(t, contract.BundleId)
+	reencoded, err = contract.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, bz, reencoded)
+}
@@ -0,0 +1,44 @@
+package types
+
+import (
+	"fmt"
+
+	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+
+	"github.com/arkeonetwork/arkeo/common"
+)
+
+// SigningDomain binds the messages signed by the spenders of contracts to a
+// chain and a provider. The bare contract_id:nonce message can be replayed
+// on another network having a contract with the same id, the message of the
+// domain can't.
+type SigningDomain struct {
+	ChainId  string
+	Provider common.PubKey
+	// refuse the bare messages, they are accepted while the clients upgrade
+	Required bool
+}
+
+// GetDomainBytesToSign prefixes the message of GetBytesToSign with the chain
+// id and the provider pubkey
+func GetDomainBytesToSign(chainId string, provider common.PubKey, contractId uint64, nonce int64) []byte {
+	return []byte(fmt.Sprintf("%s:%s:%d:%d", chainId, provider, contractId, nonce))
+}
+
+// BytesToSign returns the message the spender must sign, the bare one unless
+// the domain is required
+func (d SigningDomain) BytesToSign(contractId uint64, nonce int64) []byte {
+	if d.Required {
+		return GetDomainBytesToSign(d.ChainId, d.Provider, contractId, nonce)
+	}
+	return GetBytesToSign(contractId, nonce)
+}
+
+// Verify checks the signature of the spender over the message of the domain,
+// or over the bare message when the domain isn't required
+func (d SigningDomain) Verify(pk cryptotypes.PubKey, contractId uint64, nonce int64, signature []byte) bool {
+	if pk.VerifySignature(GetDomainBytesToSign(d.ChainId, d.Provider, contractId, nonce), signature) {
+		return true
+	}
+	return !d.Required && pk.VerifySignature(GetBytesToSign(contractId, nonce), signature)
+}
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/crypto/keys/secp256k1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigningDomain(t *testing.T) {
+	key := secp256k1.GenPrivKey()
+	provider := GetRandomPubKey()
+	chainA := SigningDomain{ChainId: "arkeo-a", Provider: provider, Required: true}
+	chainB := SigningDomain{ChainId: "arkeo-b", Provider: provider, Required: true}
+	require.Equal(t, "arkeo-a:"+provider.String()+":5:3", string(chainA.BytesToSign(5, 3)))
+
+	signature, err := key.Sign(chainA.BytesToSign(5, 3))
+	require.NoError(t, err)
+	require.True(t, chainA.Verify(key.PubKey(), 5, 3, signature))
+	require.False(t, chainA.Verify(key.PubKey(), 5, 4, signature))
+
+	// a signature for chain A is rejected on chain B, or for another provider
+	require.False(t, chainB.Verify(key.PubKey(), 5, 3, signature))
+	otherProvider := chainA
+	otherProvider.Provider = GetRandomPubKey()
+	require.False(t, otherProvider.Verify(key.PubKey(), 5, 3, signature))
+	chainB.Required = false
+	require.False(t, chainB.Verify(key.PubKey(), 5, 3, signature))
+
+	// the bare message is only accepted when the domain isn't required
+	bare, err := key.Sign(GetBytesToSign(5, 3))
+	require.NoError(t, err)
+	require.False(t, chainA.Verify(key.PubKey(), 5, 3, bare))
+	require.True(t, chainB.Verify(key.PubKey(), 5, 3, bare))
+	require.Equal(t, "5:3", string(chainB.BytesToSign(5, 3)))
+}
@@ -23,6 +23,9 @@ func GetQueryCmd(queryRoute string) *cobra.Command {
 
 	cmd.AddCommand(CmdQueryParams())
 	cmd.AddCommand(CmdClaimRecord())
+	cmd.AddCommand(CmdListClaims())
+	cmd.AddCommand(CmdShowClaim())
+	cmd.AddCommand(CmdClaimRecordForeign())
 
 	// this line is used by starport scaffolding # 1
 
@@ -13,6 +13,11 @@ import (
 
 var _ = strconv.Itoa(0)
 
+const (
+	flagChain   = "chain"
+	flagAddress = "address"
+)
+
 func CmdClaimRecord() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "claim-record [address] [chain]",
@@ -59,3 +64,140 @@ func CmdClaimRecord() *cobra.Command {
 
 	return cmd
 }
+
+func CmdListClaims() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list-claims",
+		Short: "list the claim records, optionally of a single chain or address",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientCtx, err := client.GetClientQueryContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			reqChain, err := cmd.Flags().GetString(flagChain)
+			if err != nil {
+				return err
+			}
+			if len(reqChain) > 0 {
+				if _, err := types.ChainFromString(reqChain); err != nil {
+					return fmt.Errorf("invalid chain %s", reqChain)
+				}
+			}
+			reqAddress, err := cmd.Flags().GetString(flagAddress)
+			if err != nil {
+				return err
+			}
+
+			pageReq, err := client.ReadPageRequest(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryClaimRecordsRequest{
+				Chain:      reqChain,
+				Address:    reqAddress,
+				Pagination: pageReq,
+			}
+
+			res, err := queryClient.ClaimRecords(cmd.Context(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	cmd.Flags().String(flagChain, "", "only list the claim records of this chain (arkeo or ethereum)")
+	cmd.Flags().String(flagAddress, "", "only list the claim records of this address")
+	flags.AddPaginationFlagsToCmd(cmd, cmd.Use)
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+func CmdShowClaim() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show-claim [chain] [address]",
+		Short: "shows the claim record of an address",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chain, err := types.ChainFromString(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid chain %s", args[0])
+			}
+			reqAddress := args[1]
+			if !types.IsValidAddress(reqAddress, chain) {
+				return fmt.Errorf("invalid address %s", reqAddress)
+			}
+
+			clientCtx, err := client.GetClientQueryContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryClaimRecordRequest{
+				Chain:   chain,
+				Address: reqAddress,
+			}
+
+			res, err := queryClient.ClaimRecord(cmd.Context(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
+
+func CmdClaimRecordForeign() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "record-foreign [chain] [address]",
+		Short: "shows the claim record of a foreign chain address, before any wallet is connected",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			chain, err := types.ChainFromString(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid chain %s", args[0])
+			}
+			reqAddress, err := types.NormalizeAddress(args[1], chain)
+			if err != nil {
+				return err
+			}
+
+			clientCtx, err := client.GetClientQueryContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			queryClient := types.NewQueryClient(clientCtx)
+
+			params := &types.QueryClaimRecordByForeignAddressRequest{
+				Chain:   chain,
+				Address: reqAddress,
+			}
+
+			res, err := queryClient.ClaimRecordByForeignAddress(cmd.Context(), params)
+			if err != nil {
+				return err
+			}
+
+			return clientCtx.PrintProto(res)
+		},
+	}
+
+	flags.AddQueryFlagsToCmd(cmd)
+
+	return cmd
+}
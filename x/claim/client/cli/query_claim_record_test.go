@@ -0,0 +1,112 @@
+package cli_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tmcli "github.com/tendermint/tendermint/libs/cli"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	clitestutil "github.com/cosmos/cosmos-sdk/testutil/cli"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/testutil/network"
+	"github.com/arkeonetwork/arkeo/testutil/utils"
+	"github.com/arkeonetwork/arkeo/x/claim/client/cli"
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+)
+
+func networkWithClaimRecords(t *testing.T, n int) (*network.Network, []types.ClaimRecord) {
+	t.Helper()
+	cfg := network.DefaultConfig()
+	state := types.GenesisState{}
+	require.NoError(t, cfg.Codec.UnmarshalJSON(cfg.GenesisState[types.ModuleName], &state))
+
+	for i := 0; i < n; i++ {
+		state.ClaimRecords = append(state.ClaimRecords, types.ClaimRecord{
+			Chain:          types.ARKEO,
+			Address:        utils.GetRandomArkeoAddress().String(),
+			AmountClaim:    sdk.NewInt64Coin(types.DefaultClaimDenom, int64(100+i)),
+			AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, int64(100+i)),
+			AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, int64(100+i)),
+		})
+	}
+	state.ClaimRecords = append(state.ClaimRecords, types.ClaimRecord{
+		Chain:          types.ETHEREUM,
+		Address:        "0xdafea492d9c6733ae3d56b7ed1adb60692c98bc5",
+		AmountClaim:    sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+		AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+		AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+		Locked:         true,
+	})
+
+	buf, err := cfg.Codec.MarshalJSON(&state)
+	require.NoError(t, err)
+	cfg.GenesisState[types.ModuleName] = buf
+	return network.New(t, cfg), state.ClaimRecords
+}
+
+func TestListClaims(t *testing.T) {
+	net, claimRecords := networkWithClaimRecords(t, 4)
+	ctx := net.Validators[0].ClientCtx
+
+	list := func(args ...string) types.QueryClaimRecordsResponse {
+		args = append(args, fmt.Sprintf("--%s=json", tmcli.OutputFlag))
+		out, err := clitestutil.ExecTestCLICmd(ctx, cli.CmdListClaims(), args)
+		require.NoError(t, err)
+		var resp types.QueryClaimRecordsResponse
+		require.NoError(t, net.Config.Codec.UnmarshalJSON(out.Bytes(), &resp))
+		return resp
+	}
+
+	t.Run("all", func(t *testing.T) {
+		require.ElementsMatch(t, claimRecords, list().ClaimRecords)
+	})
+	t.Run("by chain", func(t *testing.T) {
+		require.ElementsMatch(t, claimRecords[:4], list("--chain=arkeo").ClaimRecords)
+		require.Equal(t, claimRecords[4:], list("--chain=ethereum").ClaimRecords)
+	})
+	t.Run("by address", func(t *testing.T) {
+		require.Equal(t, claimRecords[2:3], list("--address="+claimRecords[2].Address).ClaimRecords)
+		require.Empty(t, list("--chain=ethereum", "--address="+claimRecords[2].Address).ClaimRecords)
+	})
+	t.Run("by page", func(t *testing.T) {
+		var paged []types.ClaimRecord
+		for offset := 0; offset < len(claimRecords); offset += 2 {
+			resp := list(fmt.Sprintf("--%s=%d", flags.FlagOffset, offset), fmt.Sprintf("--%s=%d", flags.FlagLimit, 2))
+			require.LessOrEqual(t, len(resp.ClaimRecords), 2)
+			paged = append(paged, resp.ClaimRecords...)
+		}
+		require.ElementsMatch(t, claimRecords, paged)
+
+		resp := list(fmt.Sprintf("--%s", flags.FlagCountTotal), fmt.Sprintf("--%s=%d", flags.FlagLimit, 1))
+		require.Equal(t, uint64(len(claimRecords)), resp.Pagination.Total)
+	})
+	t.Run("invalid chain", func(t *testing.T) {
+		_, err := clitestutil.ExecTestCLICmd(ctx, cli.CmdListClaims(), []string{"--chain=bitcoin"})
+		require.Error(t, err)
+	})
+}
+
+func TestShowClaim(t *testing.T) {
+	net, claimRecords := networkWithClaimRecords(t, 2)
+	ctx := net.Validators[0].ClientCtx
+
+	for _, claimRecord := range claimRecords {
+		chain := strings.ToLower(claimRecord.Chain.String())
+		args := []string{chain, claimRecord.Address, fmt.Sprintf("--%s=json", tmcli.OutputFlag)}
+		out, err := clitestutil.ExecTestCLICmd(ctx, cli.CmdShowClaim(), args)
+		require.NoError(t, err)
+		var resp types.QueryClaimRecordResponse
+		require.NoError(t, net.Config.Codec.UnmarshalJSON(out.Bytes(), &resp))
+		require.NotNil(t, resp.ClaimRecord)
+		require.Equal(t, claimRecord, *resp.ClaimRecord)
+	}
+
+	_, err := clitestutil.ExecTestCLICmd(ctx, cli.CmdShowClaim(), []string{"ethereum", claimRecords[0].Address})
+	require.Error(t, err)
+	_, err = clitestutil.ExecTestCLICmd(ctx, cli.CmdShowClaim(), []string{"bitcoin", claimRecords[0].Address})
+	require.Error(t, err)
+}
@@ -27,6 +27,10 @@ func GetTxCmd() *cobra.Command {
 	cmd.AddCommand(CmdClaimArkeo())
 	cmd.AddCommand(CmdTransferClaim())
 	cmd.AddCommand(CmdAddClaim())
+	cmd.AddCommand(CmdLockClaim())
+	cmd.AddCommand(CmdUnlockClaim())
+	cmd.AddCommand(CmdClaimWithProof())
+	cmd.AddCommand(CmdAddClaimsCSV())
 	// this line is used by starport scaffolding # 1
 
 	return cmd
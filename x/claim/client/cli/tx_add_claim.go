@@ -9,6 +9,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const flagVestingDuration = "vesting-duration"
+
 func CmdAddClaim() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "add-claim [chain] [address] [amount]",
@@ -19,6 +21,7 @@ func CmdAddClaim() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().Duration(flagVestingDuration, 0, "vesting duration of the claim, overrides the vesting_duration param when set")
 	flags.AddTxFlagsToCmd(cmd)
 
 	return cmd
@@ -14,6 +14,8 @@ import (
 	"github.com/arkeonetwork/arkeo/x/claim/types"
 )
 
+const flagVestingDuration = "vesting-duration"
+
 func CmdAddClaim() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "add-claim [chain] [address] [amount]",
@@ -32,6 +34,11 @@ func CmdAddClaim() *cobra.Command {
 				return err
 			}
 
+			vestingDuration, err := cmd.Flags().GetDuration(flagVestingDuration)
+			if err != nil {
+				return err
+			}
+
 			clientCtx, err := client.GetClientTxContext(cmd)
 			if err != nil {
 				return err
@@ -42,6 +49,7 @@ func CmdAddClaim() *cobra.Command {
 				chain,
 				argAddress,
 				argAmount,
+				vestingDuration,
 			)
 			if err := msg.ValidateBasic(); err != nil {
 				return err
@@ -50,6 +58,7 @@ func CmdAddClaim() *cobra.Command {
 		},
 	}
 
+	cmd.Flags().Duration(flagVestingDuration, 0, "vesting duration of the claim, overrides the vesting_duration param when set")
 	flags.AddTxFlagsToCmd(cmd)
 
 	return cmd
@@ -0,0 +1,28 @@
+//go:build !testnet
+
+package cli
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/spf13/cobra"
+)
+
+const flagMerge = "merge"
+
+func CmdAddClaimsCSV() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add-claims-csv [file]",
+		Short: "Broadcast messages add-multiple-claims for the claims of a csv file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			return fmt.Errorf("add-claims-csv command only available on testnet build")
+		},
+	}
+
+	cmd.Flags().Bool(flagMerge, false, "add the claims to the existing claim records of their addresses rather than failing")
+	flags.AddTxFlagsToCmd(cmd)
+
+	return cmd
+}
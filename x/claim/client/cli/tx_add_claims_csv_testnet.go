@@ -0,0 +1,155 @@
+//go:build testnet
+
+package cli
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+)
+
+const flagMerge = "merge"
+
+func CmdAddClaimsCSV() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add-claims-csv [file]",
+		Short: "Broadcast messages add-multiple-claims for the claims of a csv file",
+		Long: `Broadcast messages add-multiple-claims for the claims of a csv file, one
+chain,address,amount[,vesting-duration] record per line, lines starting with #
+being ignored. The claims are sent in transactions of at most
+` + fmt.Sprint(types.MaxClaimsPerMsg) + ` claims, once the whole file is validated. With --dry-run
+the file is only validated.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			merge, err := cmd.Flags().GetBool(flagMerge)
+			if err != nil {
+				return err
+			}
+
+			clientCtx, err := client.GetClientTxContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			claims, err := readClaimsCSV(file)
+			if err != nil {
+				return fmt.Errorf("invalid csv file %s: %w", args[0], err)
+			}
+
+			// nothing is sent unless the whole file is valid
+			var msgs []*types.MsgAddMultipleClaims
+			for start := 0; start < len(claims); start += types.MaxClaimsPerMsg {
+				end := start + types.MaxClaimsPerMsg
+				if end > len(claims) {
+					end = len(claims)
+				}
+				msg := types.NewMsgAddMultipleClaims(clientCtx.GetFromAddress(), claims[start:end], merge)
+				if err := msg.ValidateBasic(); err != nil {
+					return fmt.Errorf("invalid claims %d to %d: %w", start+1, end, err)
+				}
+				msgs = append(msgs, msg)
+			}
+			if clientCtx.Simulate {
+				return clientCtx.PrintString(fmt.Sprintf("%d claims in %d transactions are valid\n", len(claims), len(msgs)))
+			}
+
+			// the transactions are sent one after the other, before the
+			// account sequence is updated on chain
+			txf := tx.NewFactoryCLI(clientCtx, cmd.Flags())
+			if !clientCtx.GenerateOnly {
+				txf, err = txf.Prepare(clientCtx)
+				if err != nil {
+					return err
+				}
+			}
+			for _, msg := range msgs {
+				if err := tx.GenerateOrBroadcastTxWithFactory(clientCtx, txf, msg); err != nil {
+					return err
+				}
+				txf = txf.WithSequence(txf.Sequence() + 1)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool(flagMerge, false, "add the claims to the existing claim records of their addresses rather than failing")
+	flags.AddTxFlagsToCmd(cmd)
+
+	return cmd
+}
+
+// readClaimsCSV reads the claims of a csv file, addresses claimed twice in the
+// file are rejected
+func readClaimsCSV(r io.Reader) ([]types.AddClaimEntry, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var claims []types.AddClaimEntry
+	seen := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != 3 && len(record) != 4 {
+			return nil, fmt.Errorf("line %d: expected chain,address,amount[,vesting-duration]", line)
+		}
+
+		chain, err := types.ChainFromString(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid chain(%s)", line, record[0])
+		}
+		amount, err := cast.ToInt64E(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount(%s): %w", line, record[2], err)
+		}
+		claim := types.AddClaimEntry{
+			Chain:   chain,
+			Address: record[1],
+			Amount:  amount,
+		}
+		if len(record) == 4 && len(record[3]) > 0 {
+			claim.VestingDuration, err = time.ParseDuration(record[3])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid vesting duration(%s): %w", line, record[3], err)
+			}
+		}
+		if err := claim.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		key := chain.String() + "/" + strings.ToLower(claim.Address)
+		if first, ok := seen[key]; ok {
+			return nil, fmt.Errorf("line %d: %s address %s already claimed line %d", line, chain, claim.Address, first)
+		}
+		seen[key] = line
+		claims = append(claims, claim)
+	}
+	if len(claims) == 0 {
+		return nil, fmt.Errorf("no claims")
+	}
+	return claims, nil
+}
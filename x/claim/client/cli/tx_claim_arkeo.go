@@ -8,6 +8,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const (
+	flagValidator           = "validator"
+	flagDelegateBasisPoints = "delegate-basis-points"
+)
+
 func CmdClaimArkeo() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "claim-arkeo",
@@ -22,6 +27,14 @@ func CmdClaimArkeo() *cobra.Command {
 			msg := types.NewMsgClaimArkeo(
 				clientCtx.GetFromAddress(),
 			)
+			msg.Validator, err = cmd.Flags().GetString(flagValidator)
+			if err != nil {
+				return err
+			}
+			msg.DelegateBasisPoints, err = cmd.Flags().GetUint64(flagDelegateBasisPoints)
+			if err != nil {
+				return err
+			}
 			if err := msg.ValidateBasic(); err != nil {
 				return err
 			}
@@ -30,6 +43,8 @@ func CmdClaimArkeo() *cobra.Command {
 	}
 
 	flags.AddTxFlagsToCmd(cmd)
+	cmd.Flags().String(flagValidator, "", "validator to delegate part of the claim to")
+	cmd.Flags().Uint64(flagDelegateBasisPoints, 0, "portion of the claim delegated to the validator, in basis points")
 
 	return cmd
 }
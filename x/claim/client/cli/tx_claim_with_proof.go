@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/cosmos/cosmos-sdk/client/tx"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+)
+
+func CmdClaimWithProof() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "claim-with-proof [chain] [address] [amount] [proof]",
+		Short: "Broadcast message claim-with-proof, the proof being the comma separated hex hashes of the siblings of the claim leaf",
+		Args:  cobra.RangeArgs(3, 4),
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			argChain := args[0]
+			chain, err := types.ChainFromString(argChain)
+			if err != nil {
+				return fmt.Errorf("invalid chain(%s),err: %w", argChain, err)
+			}
+			argAddress := args[1]
+
+			argAmount, err := cast.ToInt64E(args[2])
+			if err != nil {
+				return err
+			}
+
+			// a tree of a single claim has an empty proof
+			var proof []string
+			if len(args) > 3 && len(args[3]) > 0 {
+				proof = strings.Split(args[3], ",")
+			}
+
+			clientCtx, err := client.GetClientTxContext(cmd)
+			if err != nil {
+				return err
+			}
+
+			msg := types.NewMsgClaimWithProof(
+				clientCtx.GetFromAddress(),
+				chain,
+				argAddress,
+				argAmount,
+				proof,
+			)
+			if err := msg.ValidateBasic(); err != nil {
+				return err
+			}
+			return tx.GenerateOrBroadcastTxCLI(clientCtx, cmd.Flags(), msg)
+		},
+	}
+
+	flags.AddTxFlagsToCmd(cmd)
+
+	return cmd
+}
@@ -14,6 +14,12 @@ func InitGenesis(ctx sdk.Context, k keeper.Keeper, genState types.GenesisState)
 	if err != nil {
 		panic(err) // if genesis fails we should panic
 	}
+	for _, key := range genState.MerkleClaims {
+		k.SetMerkleClaim(ctx, key)
+	}
+	if genState.ClaimsExpired {
+		k.SetClaimsExpired(ctx)
+	}
 }
 
 // ExportGenesis returns the module's exported genesis
@@ -26,5 +32,7 @@ func ExportGenesis(ctx sdk.Context, k keeper.Keeper) *types.GenesisState {
 		panic(err)
 	}
 	genesis.ClaimRecords = claimRecords
+	genesis.MerkleClaims = k.GetMerkleClaims(ctx)
+	genesis.ClaimsExpired = k.ClaimsExpired(ctx)
 	return genesis
 }
@@ -1,12 +1,21 @@
 package keeper
 
 import (
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/arkeonetwork/arkeo/common"
 	"github.com/arkeonetwork/arkeo/x/claim/types"
 	"github.com/cosmos/cosmos-sdk/store/prefix"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	vestingexported "github.com/cosmos/cosmos-sdk/x/auth/vesting/exported"
+	vestingtypes "github.com/cosmos/cosmos-sdk/x/auth/vesting/types"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 	"github.com/pkg/errors"
 )
 
@@ -42,6 +51,49 @@ func (k Keeper) SetClaimRecords(ctx sdk.Context, claimRecords []types.ClaimRecor
 	return nil
 }
 
+// AddClaimRecords adds a claim record per entry, each of its actions claimable
+// for the amount. An address already having a claim record fails the whole
+// batch, unless merge is set: the claim is then added to the existing record.
+func (k Keeper) AddClaimRecords(ctx sdk.Context, entries []types.AddClaimEntry, merge bool) error {
+	cacheCtx, commit := ctx.CacheContext()
+	for _, entry := range entries {
+		coin := sdk.NewCoin(types.DefaultClaimDenom, sdk.NewInt(entry.Amount))
+		claimRecord := types.ClaimRecord{
+			Chain:           entry.Chain,
+			Address:         entry.Address,
+			AmountClaim:     coin,
+			AmountVote:      coin,
+			AmountDelegate:  coin,
+			IsTransferable:  entry.Chain == types.ARKEO,
+			VestingDuration: entry.VestingDuration,
+		}
+
+		existing, err := k.GetClaimRecord(cacheCtx, entry.Address, entry.Chain)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get claim record for %s", entry.Address)
+		}
+		if existing.Address != "" {
+			if !merge {
+				return errors.Wrapf(types.ErrClaimRecordExists, "%s address %s", entry.Chain, entry.Address)
+			}
+			// addresses are case insensitive, and a lock must outlive the
+			// claims of the record
+			claimRecord.Address = existing.Address
+			claimRecord.Locked = existing.Locked
+			claimRecord, err = mergeClaimRecords(existing, claimRecord)
+			if err != nil {
+				return errors.Wrapf(err, "failed to merge claim records for %s", entry.Address)
+			}
+		}
+
+		if err := k.SetClaimRecord(cacheCtx, claimRecord); err != nil {
+			return errors.Wrapf(err, "failed to set claim record for %s", entry.Address)
+		}
+	}
+	commit()
+	return nil
+}
+
 // GetClaimables get claimables for genesis export
 func (k Keeper) GetClaimRecords(ctx sdk.Context, chain types.Chain) ([]types.ClaimRecord, error) {
 	store := ctx.KVStore(k.storeKey)
@@ -65,8 +117,16 @@ func (k Keeper) GetClaimRecords(ctx sdk.Context, chain types.Chain) ([]types.Cla
 }
 
 func (k Keeper) GetAllClaimRecords(ctx sdk.Context) ([]types.ClaimRecord, error) {
-	claimRecords := []types.ClaimRecord{}
+	// chains in enum order, ranging over the map would make the order of the
+	// records, and of anything derived from them, differ between nodes
+	chains := make([]int32, 0, len(types.Chain_name))
 	for chain := range types.Chain_name {
+		chains = append(chains, chain)
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i] < chains[j] })
+
+	claimRecords := []types.ClaimRecord{}
+	for _, chain := range chains {
 		records, err := k.GetClaimRecords(ctx, types.Chain(chain))
 		if err != nil {
 			return nil, err
@@ -129,6 +189,9 @@ func (k Keeper) GetClaimableAmountForAction(ctx sdk.Context, addr string, action
 	if claimRecord.IsEmpty() {
 		return sdk.Coin{}, nil
 	}
+	if k.ClaimDeadlinePassed(ctx) {
+		return sdk.Coin{}, types.ErrAirdropEnded
+	}
 
 	params := k.GetParams(ctx)
 
@@ -180,6 +243,14 @@ func (k Keeper) GetModuleAccountBalance(ctx sdk.Context) sdk.Coin {
 
 // ClaimCoins remove claimable amount entry and transfer it to user's account
 func (k Keeper) ClaimCoinsForAction(ctx sdk.Context, addr string, action types.Action) (sdk.Coin, error) {
+	return k.ClaimCoinsForActionWithDelegation(ctx, addr, action, nil, 0)
+}
+
+// ClaimCoinsForActionWithDelegation remove claimable amount entry, delegate
+// the given portion of it (in basis points) to the validator and transfer the
+// rest to user's account. The whole claim fails when the validator can't
+// receive the delegation. A delegated portion rounding to zero is transferred.
+func (k Keeper) ClaimCoinsForActionWithDelegation(ctx sdk.Context, addr string, action types.Action, valAddr sdk.ValAddress, delegateBasisPoints uint64) (sdk.Coin, error) {
 	claimableAmount, err := k.GetClaimableAmountForAction(ctx, addr, action, types.ARKEO)
 	if err != nil {
 		return claimableAmount, err
@@ -193,39 +264,240 @@ func (k Keeper) ClaimCoinsForAction(ctx sdk.Context, addr string, action types.A
 	if err != nil {
 		return sdk.Coin{}, err
 	}
+	if claimRecord.Locked {
+		return sdk.Coin{}, errors.Wrapf(types.ErrClaimLocked, "claim record for %s is locked", addr)
+	}
 
 	accountAddress, err := sdk.AccAddressFromBech32(addr)
 	if err != nil {
 		return sdk.Coin{}, err
 	}
+
+	delegated := sdk.NewCoin(claimableAmount.Denom, sdk.ZeroInt())
+	var validator stakingtypes.Validator
+	if delegateBasisPoints > 0 {
+		validator, err = k.delegationValidator(ctx, valAddr, delegateBasisPoints, claimableAmount.Denom)
+		if err != nil {
+			return sdk.Coin{}, err
+		}
+		delegated.Amount = common.GetSafeShare(
+			sdk.NewIntFromUint64(delegateBasisPoints),
+			sdk.NewIntFromUint64(types.MaxBasisPoints),
+			claimableAmount.Amount,
+		)
+	}
+	liquid := claimableAmount.Sub(delegated)
+
 	err = k.bankKeeper.SendCoinsFromModuleToAccount(ctx, types.ModuleName, accountAddress, sdk.NewCoins(claimableAmount))
 	if err != nil {
 		return sdk.Coin{}, err
 	}
 
+	// the coins must vest before being delegated so the delegation is
+	// tracked as vesting
+	vestingDuration := claimRecord.VestingDuration
+	if vestingDuration == 0 {
+		vestingDuration = k.VestingDuration(ctx)
+	}
+	var vestingEnd int64
+	if vestingDuration > 0 {
+		vestingEnd, err = k.vestClaim(ctx, accountAddress, claimableAmount, vestingDuration)
+		if err != nil {
+			return sdk.Coin{}, err
+		}
+	}
+
 	claimRecord = setClaimableAmountForAction(claimRecord, action, sdk.Coin{}) // set to nil/zero to mark as completed.
 	err = k.SetClaimRecord(ctx, claimRecord)
 	if err != nil {
 		return sdk.Coin{}, err
 	}
 
+	if delegated.IsPositive() {
+		if _, err := k.stakingKeeper.Delegate(ctx, accountAddress, delegated.Amount, stakingtypes.Unbonded, validator, true); err != nil {
+			return sdk.Coin{}, errors.Wrapf(err, "failed to delegate %s to %s", delegated, valAddr)
+		}
+	}
+
+	attributes := []sdk.Attribute{
+		sdk.NewAttribute(sdk.AttributeKeySender, addr),
+		sdk.NewAttribute(sdk.AttributeKeyAmount, claimableAmount.String()),
+		sdk.NewAttribute(types.AttributeKeyLiquidAmount, liquid.String()),
+		sdk.NewAttribute(types.AttributeKeyDelegatedAmount, delegated.String()),
+	}
+	if delegated.IsPositive() {
+		attributes = append(attributes, sdk.NewAttribute(types.AttributeKeyValidator, valAddr.String()))
+	}
+	if vestingEnd > 0 {
+		attributes = append(attributes, sdk.NewAttribute(types.AttributeKeyVestingEndTime, strconv.FormatInt(vestingEnd, 10)))
+	}
 	ctx.EventManager().EmitEvents(sdk.Events{
-		sdk.NewEvent(
-			types.EventTypeClaim,
-			sdk.NewAttribute(sdk.AttributeKeySender, addr),
-			sdk.NewAttribute(sdk.AttributeKeyAmount, claimableAmount.String()),
-		),
+		sdk.NewEvent(types.EventTypeClaim, attributes...),
 	})
 
 	return claimableAmount, nil
 }
 
-// // FundRemainingsToCommunity fund remainings to the community when airdrop period end
-// func (k Keeper) fundRemainingsToCommunity(ctx sdk.Context) error {
-// 	moduleAccAddr := k.GetModuleAccountAddress(ctx)
-// 	amt := k.GetModuleAccountBalance(ctx)
-// 	return k.distrKeeper.FundCommunityPool(ctx, sdk.NewCoins(amt), moduleAccAddr)
-// }
+// claimVestingPeriods is the number of steps the coins of a claim vest in,
+// once merged with the schedule of an account already vesting
+const claimVestingPeriods = 100
+
+// vestClaim locks the claimed coins, already sent to the account, in a
+// schedule vesting them linearly over the duration. A base account becomes a
+// continuous vesting account. Each claim of an account already vesting keeps
+// its own schedule: the account becomes a periodic vesting account merging the
+// schedule of the coins still vesting, the coins already vested stay vested,
+// with the one of the claim, vesting in claimVestingPeriods steps. It returns
+// the end time of the schedule of the account.
+func (k Keeper) vestClaim(ctx sdk.Context, addr sdk.AccAddress, amount sdk.Coin, duration time.Duration) (int64, error) {
+	now := ctx.BlockTime().Unix()
+	end := ctx.BlockTime().Add(duration).Unix()
+	switch acc := k.accountKeeper.GetAccount(ctx, addr).(type) {
+	case *authtypes.BaseAccount:
+		k.accountKeeper.SetAccount(ctx, vestingtypes.NewContinuousVestingAccount(acc, sdk.NewCoins(amount), now, end))
+		return end, nil
+	case *vestingtypes.ContinuousVestingAccount:
+		// the coins still vesting vest from now on, or from the start of a
+		// schedule yet to start
+		start, remainingStart := acc.StartTime, now
+		if now < start {
+			start, remainingStart = now, acc.StartTime
+		}
+		vested := acc.GetVestedCoins(ctx.BlockTime())
+		events := []vestingEvent{{time: now, amount: vested}}
+		events = append(events, linearVestingEvents(acc.OriginalVesting.Sub(vested...), remainingStart, acc.EndTime)...)
+		events = append(events, linearVestingEvents(sdk.NewCoins(amount), now, end)...)
+		periodic := vestingtypes.NewPeriodicVestingAccountRaw(acc.BaseVestingAccount, start, nil)
+		periodic.VestingPeriods, periodic.EndTime = vestingPeriods(periodic.StartTime, events)
+		periodic.OriginalVesting = acc.OriginalVesting.Add(amount)
+		k.accountKeeper.SetAccount(ctx, periodic)
+		return periodic.EndTime, nil
+	case *vestingtypes.PeriodicVestingAccount:
+		events := periodVestingEvents(acc.StartTime, acc.VestingPeriods)
+		events = append(events, linearVestingEvents(sdk.NewCoins(amount), now, end)...)
+		if now < acc.StartTime {
+			acc.StartTime = now
+		}
+		acc.VestingPeriods, acc.EndTime = vestingPeriods(acc.StartTime, events)
+		acc.OriginalVesting = acc.OriginalVesting.Add(amount)
+		k.accountKeeper.SetAccount(ctx, acc)
+		return acc.EndTime, nil
+	default:
+		return 0, errors.Wrapf(types.ErrCannotVest, "account %s of type %T can't receive vesting claims", addr, acc)
+	}
+}
+
+// vestingEvent is an amount of coins vesting at a unix time
+type vestingEvent struct {
+	time   int64
+	amount sdk.Coins
+}
+
+// linearVestingEvents vests the coins from start to end in up to
+// claimVestingPeriods equal steps, one per second at most
+func linearVestingEvents(coins sdk.Coins, start, end int64) []vestingEvent {
+	if coins.IsZero() {
+		return nil
+	}
+	steps := end - start
+	if steps > claimVestingPeriods {
+		steps = claimVestingPeriods
+	}
+	if steps <= 0 {
+		return []vestingEvent{{time: start, amount: coins}}
+	}
+	events := make([]vestingEvent, 0, steps)
+	for i := int64(0); i < steps; i++ {
+		var part sdk.Coins
+		for _, coin := range coins {
+			amount := coin.Amount.MulRaw(i + 1).QuoRaw(steps).Sub(coin.Amount.MulRaw(i).QuoRaw(steps))
+			if amount.IsPositive() {
+				part = part.Add(sdk.NewCoin(coin.Denom, amount))
+			}
+		}
+		events = append(events, vestingEvent{time: start + (end-start)*(i+1)/steps, amount: part})
+	}
+	return events
+}
+
+// periodVestingEvents returns the events of the periods of a schedule
+func periodVestingEvents(start int64, periods vestingtypes.Periods) []vestingEvent {
+	events := make([]vestingEvent, 0, len(periods))
+	for _, period := range periods {
+		start += period.Length
+		events = append(events, vestingEvent{time: start, amount: period.Amount})
+	}
+	return events
+}
+
+// vestingPeriods merges the events in the periods of a schedule starting at
+// start, and returns them along with the end time of the schedule
+func vestingPeriods(start int64, events []vestingEvent) (vestingtypes.Periods, int64) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].time < events[j].time
+	})
+	var periods vestingtypes.Periods
+	end := start
+	for _, event := range events {
+		if event.amount.IsZero() {
+			continue
+		}
+		if len(periods) > 0 && event.time == end {
+			last := &periods[len(periods)-1]
+			last.Amount = last.Amount.Add(event.amount...)
+			continue
+		}
+		periods = append(periods, vestingtypes.Period{Length: event.time - end, Amount: event.amount})
+		end = event.time
+	}
+	return periods, end
+}
+
+// VestingClaimedCoins returns the coins of the claim denom still vesting and
+// already vested in the account, both zero when the account doesn't vest
+func (k Keeper) VestingClaimedCoins(ctx sdk.Context, addr sdk.AccAddress) (locked, unlocked sdk.Coin) {
+	denom := k.ClaimDenom(ctx)
+	locked = sdk.NewCoin(denom, sdk.ZeroInt())
+	unlocked = sdk.NewCoin(denom, sdk.ZeroInt())
+	acc, ok := k.accountKeeper.GetAccount(ctx, addr).(vestingexported.VestingAccount)
+	if !ok {
+		return locked, unlocked
+	}
+	locked.Amount = acc.GetVestingCoins(ctx.BlockTime()).AmountOf(denom)
+	unlocked.Amount = acc.GetVestedCoins(ctx.BlockTime()).AmountOf(denom)
+	return locked, unlocked
+}
+
+// delegationValidator returns the validator a claim can be delegated to
+func (k Keeper) delegationValidator(ctx sdk.Context, valAddr sdk.ValAddress, delegateBasisPoints uint64, denom string) (stakingtypes.Validator, error) {
+	if max := k.MaxDelegateBasisPoints(ctx); delegateBasisPoints > max {
+		return stakingtypes.Validator{}, errors.Wrapf(types.ErrInvalidDelegateShare, "cannot delegate more than %d basis points: %d", max, delegateBasisPoints)
+	}
+	if bondDenom := k.stakingKeeper.BondDenom(ctx); denom != bondDenom {
+		return stakingtypes.Validator{}, errors.Wrapf(types.ErrInvalidDelegateShare, "cannot delegate %s, bond denom is %s", denom, bondDenom)
+	}
+	if valAddr.Empty() {
+		return stakingtypes.Validator{}, errors.Wrap(types.ErrInvalidValidator, "validator cannot be empty")
+	}
+	validator, found := k.stakingKeeper.GetValidator(ctx, valAddr)
+	if !found {
+		return stakingtypes.Validator{}, errors.Wrapf(types.ErrInvalidValidator, "validator %s not found", valAddr)
+	}
+	if validator.IsJailed() {
+		return stakingtypes.Validator{}, errors.Wrapf(types.ErrInvalidValidator, "validator %s is jailed", valAddr)
+	}
+	return validator, nil
+}
+
+// IsClaimAuthority returns true if the given address can lock and unlock
+// claims, either governance or the compliance authority
+func (k Keeper) IsClaimAuthority(ctx sdk.Context, addr sdk.AccAddress) bool {
+	if addr.Equals(authtypes.NewModuleAddress(govtypes.ModuleName)) {
+		return true
+	}
+	compliance := k.ComplianceAuthority(ctx)
+	return len(compliance) > 0 && addr.String() == compliance
+}
 
 func chainToStorePrefix(chain types.Chain) []byte {
 	switch chain {
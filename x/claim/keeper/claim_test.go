@@ -2,6 +2,7 @@ package keeper_test
 
 import (
 	"testing"
+	"time"
 
 	testkeeper "github.com/arkeonetwork/arkeo/testutil/keeper"
 	"github.com/arkeonetwork/arkeo/testutil/utils"
@@ -356,3 +357,70 @@ func TestClaimDecay(t *testing.T) {
 	balanceAfter3 := keepers.BankKeeper.GetBalance(sdkCtx, addrArkeo3, types.DefaultClaimDenom)
 	require.Equal(t, balanceAfter3.Sub(balanceBefore3), sdk.NewInt64Coin(types.DefaultClaimDenom, 0))
 }
+
+func TestAddClaimRecords(t *testing.T) {
+	keepers, ctx := testkeeper.CreateTestClaimKeepers(t)
+	k := keepers.ClaimKeeper
+
+	totalClaimable := func() int64 {
+		records, err := k.GetAllClaimRecords(ctx)
+		require.NoError(t, err)
+		total := int64(0)
+		for _, record := range records {
+			claimable, err := k.GetUserTotalClaimable(ctx, record.Address, record.Chain)
+			require.NoError(t, err)
+			if !claimable.IsNil() {
+				total += claimable.Amount.Int64()
+			}
+		}
+		return total
+	}
+
+	addr1 := utils.GetRandomArkeoAddress().String()
+	addr2 := utils.GetRandomArkeoAddress().String()
+	ethAddr := utils.GetRandomETHAddress()
+	entries := []types.AddClaimEntry{
+		{Chain: types.ARKEO, Address: addr1, Amount: 100},
+		{Chain: types.ARKEO, Address: addr2, Amount: 200, VestingDuration: time.Hour},
+		{Chain: types.ETHEREUM, Address: ethAddr, Amount: 300},
+	}
+	require.NoError(t, k.AddClaimRecords(ctx, entries, false))
+	// each action of a claim is claimable for the amount
+	require.Equal(t, int64(3*600), totalClaimable())
+	record, err := k.GetClaimRecord(ctx, addr2, types.ARKEO)
+	require.NoError(t, err)
+	require.True(t, record.IsTransferable)
+	require.Equal(t, time.Hour, record.VestingDuration)
+	record, err = k.GetClaimRecord(ctx, ethAddr, types.ETHEREUM)
+	require.NoError(t, err)
+	require.False(t, record.IsTransferable)
+
+	// an existing claim record fails the whole batch without merge
+	addr3 := utils.GetRandomArkeoAddress().String()
+	entries = []types.AddClaimEntry{
+		{Chain: types.ARKEO, Address: addr3, Amount: 50},
+		{Chain: types.ARKEO, Address: addr1, Amount: 50},
+	}
+	err = k.AddClaimRecords(ctx, entries, false)
+	require.ErrorIs(t, err, types.ErrClaimRecordExists)
+	record, err = k.GetClaimRecord(ctx, addr3, types.ARKEO)
+	require.NoError(t, err)
+	require.True(t, record.IsEmpty())
+	require.Equal(t, int64(3*600), totalClaimable())
+
+	// the claimed coins and the claimable ones add up to the claims added
+	require.NoError(t, keepers.BankKeeper.MintCoins(ctx, types.ModuleName, sdk.NewCoins(sdk.NewInt64Coin(types.DefaultClaimDenom, 10000))))
+	claimed, err := k.ClaimCoinsForAction(ctx, addr1, types.ACTION_CLAIM)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), claimed.Amount.Int64())
+	require.Equal(t, int64(3*600-100), totalClaimable())
+
+	// merged claims are added to the existing records
+	require.NoError(t, k.AddClaimRecords(ctx, entries, true))
+	require.Equal(t, int64(3*600-100+3*100), totalClaimable())
+	record, err = k.GetClaimRecord(ctx, addr1, types.ARKEO)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), record.AmountClaim.Amount.Int64())
+	require.Equal(t, int64(150), record.AmountVote.Amount.Int64())
+	require.Equal(t, int64(150), record.AmountDelegate.Amount.Int64())
+}
@@ -0,0 +1,112 @@
+package keeper
+
+import (
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ClaimDeadlinePassed returns true once the claim deadline is reached, the
+// records left can't be claimed anymore
+func (k Keeper) ClaimDeadlinePassed(ctx sdk.Context) bool {
+	deadline := k.ClaimDeadline(ctx)
+	if deadline == 0 {
+		return false
+	}
+	return !ctx.BlockTime().Before(k.AirdropStartTime(ctx).Add(deadline))
+}
+
+// ClaimsExpired returns true once the unclaimed records expired and the coins
+// left were swept
+func (k Keeper) ClaimsExpired(ctx sdk.Context) bool {
+	return ctx.KVStore(k.storeKey).Has([]byte(types.ClaimsExpiredKey))
+}
+
+func (k Keeper) SetClaimsExpired(ctx sdk.Context) {
+	ctx.KVStore(k.storeKey).Set([]byte(types.ClaimsExpiredKey), []byte{1})
+}
+
+// ExpireClaims zeroes out the records left unclaimed once the claim deadline
+// is reached, and sweeps the coins left in the module to the sweep address or
+// the community pool. A partially claimed record only expires the actions not
+// yet claimed. It runs once, and either fully or not at all.
+func (k Keeper) ExpireClaims(ctx sdk.Context) error {
+	if k.ClaimsExpired(ctx) || !k.ClaimDeadlinePassed(ctx) {
+		return nil
+	}
+
+	cacheCtx, commit := ctx.CacheContext()
+	claimRecords, err := k.GetAllClaimRecords(cacheCtx)
+	if err != nil {
+		return err
+	}
+	denom := k.ClaimDenom(cacheCtx)
+	for _, claimRecord := range claimRecords {
+		unclaimed := unclaimedAmount(claimRecord)
+		if unclaimed.IsZero() {
+			continue
+		}
+		claimRecord = setClaimableAmountForAllActions(claimRecord, sdk.Coin{})
+		if err := k.SetClaimRecord(cacheCtx, claimRecord); err != nil {
+			return err
+		}
+		cacheCtx.EventManager().EmitEvent(
+			sdk.NewEvent(
+				types.EventTypeClaimExpired,
+				sdk.NewAttribute(types.AttributeKeyChain, claimRecord.Chain.String()),
+				sdk.NewAttribute(types.AttributeKeyAddress, claimRecord.Address),
+				sdk.NewAttribute(sdk.AttributeKeyAmount, sdk.NewCoin(denom, unclaimed).String()),
+			),
+		)
+	}
+
+	if err := k.sweepModuleBalance(cacheCtx); err != nil {
+		return err
+	}
+	k.SetClaimsExpired(cacheCtx)
+	commit()
+	return nil
+}
+
+func (k Keeper) sweepModuleBalance(ctx sdk.Context) error {
+	balance := k.GetModuleAccountBalance(ctx)
+	if balance.IsNil() || balance.IsZero() {
+		return nil
+	}
+
+	recipient := k.SweepAddress(ctx)
+	if len(recipient) == 0 {
+		if err := k.distrKeeper.FundCommunityPool(ctx, sdk.NewCoins(balance), k.GetModuleAccountAddress(ctx)); err != nil {
+			return err
+		}
+		recipient = "community_pool"
+	} else {
+		addr, err := sdk.AccAddressFromBech32(recipient)
+		if err != nil {
+			return err
+		}
+		if err := k.bankKeeper.SendCoinsFromModuleToAccount(ctx, types.ModuleName, addr, sdk.NewCoins(balance)); err != nil {
+			return err
+		}
+	}
+
+	ctx.EventManager().EmitEvent(
+		sdk.NewEvent(
+			types.EventTypeClaimsSwept,
+			sdk.NewAttribute(types.AttributeKeyRecipient, recipient),
+			sdk.NewAttribute(sdk.AttributeKeyAmount, balance.String()),
+		),
+	)
+	return nil
+}
+
+// unclaimedAmount sums what is left to claim of every action of the record,
+// without decay
+func unclaimedAmount(claimRecord types.ClaimRecord) sdk.Int {
+	total := sdk.ZeroInt()
+	for _, amount := range []sdk.Coin{claimRecord.AmountClaim, claimRecord.AmountVote, claimRecord.AmountDelegate} {
+		if !amount.IsNil() {
+			total = total.Add(amount.Amount)
+		}
+	}
+	return total
+}
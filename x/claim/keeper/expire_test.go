@@ -0,0 +1,186 @@
+package keeper_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	testkeeper "github.com/arkeonetwork/arkeo/testutil/keeper"
+	"github.com/arkeonetwork/arkeo/testutil/utils"
+	"github.com/arkeonetwork/arkeo/x/claim"
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+)
+
+func setupExpiringClaims(t *testing.T) (testkeeper.TestKeepers, sdk.Context, []types.ClaimRecord, time.Time) {
+	keepers, ctx := testkeeper.CreateTestClaimKeepers(t)
+
+	params := keepers.ClaimKeeper.GetParams(ctx)
+	params.ClaimDeadline = 2 * time.Hour
+	keepers.ClaimKeeper.SetParams(ctx, params)
+	deadline := params.AirdropStartTime.Add(params.ClaimDeadline)
+
+	claimRecords := []types.ClaimRecord{
+		{
+			Chain:          types.ARKEO,
+			Address:        utils.GetRandomArkeoAddress().String(),
+			AmountClaim:    sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+			AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+			AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+		},
+		{
+			Chain:          types.ETHEREUM,
+			Address:        "0xDAFEA492D9c6733ae3d56b7Ed1ADB60692c98Bc5",
+			AmountClaim:    sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+			AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+			AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+		},
+		// partially claimed, only the vote and delegate actions are left
+		{
+			Chain:          types.ARKEO,
+			Address:        utils.GetRandomArkeoAddress().String(),
+			AmountClaim:    sdk.Coin{},
+			AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, 50),
+			AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, 50),
+		},
+	}
+	require.NoError(t, keepers.ClaimKeeper.SetClaimRecords(ctx, claimRecords))
+	require.NoError(t, keepers.BankKeeper.MintCoins(ctx, types.ModuleName, sdk.NewCoins(sdk.NewInt64Coin(types.DefaultClaimDenom, 1000))))
+	return keepers, ctx, claimRecords, deadline
+}
+
+func TestExpireClaims(t *testing.T) {
+	keepers, ctx, claimRecords, deadline := setupExpiringClaims(t)
+
+	// nothing expires before the deadline
+	before := ctx.WithBlockTime(deadline.Add(-time.Second)).WithEventManager(sdk.NewEventManager())
+	require.NoError(t, keepers.ClaimKeeper.ExpireClaims(before))
+	require.False(t, keepers.ClaimKeeper.ClaimsExpired(before))
+	require.Empty(t, before.EventManager().Events())
+	_, err := keepers.ClaimKeeper.GetClaimableAmountForAction(before, claimRecords[0].Address, types.ACTION_CLAIM, types.ARKEO)
+	require.NoError(t, err)
+
+	// the records left expire on the deadline
+	ctx = ctx.WithBlockTime(deadline).WithEventManager(sdk.NewEventManager())
+	_, err = keepers.ClaimKeeper.GetClaimableAmountForAction(ctx, claimRecords[0].Address, types.ACTION_CLAIM, types.ARKEO)
+	require.ErrorIs(t, err, types.ErrAirdropEnded)
+	require.NoError(t, keepers.ClaimKeeper.ExpireClaims(ctx))
+	require.True(t, keepers.ClaimKeeper.ClaimsExpired(ctx))
+
+	expired := make(map[string]string)
+	swept := 0
+	for _, evt := range ctx.EventManager().Events() {
+		attrs := make(map[string]string)
+		for _, attr := range evt.Attributes {
+			attrs[string(attr.Key)] = string(attr.Value)
+		}
+		switch evt.Type {
+		case types.EventTypeClaimExpired:
+			expired[attrs[types.AttributeKeyAddress]] = attrs[sdk.AttributeKeyAmount]
+		case types.EventTypeClaimsSwept:
+			swept++
+			require.Equal(t, "community_pool", attrs[types.AttributeKeyRecipient])
+			require.Equal(t, "1000uarkeo", attrs[sdk.AttributeKeyAmount])
+		}
+	}
+	require.Equal(t, 1, swept)
+	// the partial claim only expires what is left
+	require.Equal(t, map[string]string{
+		claimRecords[0].Address: "300uarkeo",
+		claimRecords[1].Address: "600uarkeo",
+		claimRecords[2].Address: "100uarkeo",
+	}, expired)
+
+	for _, claimRecord := range claimRecords {
+		record, err := keepers.ClaimKeeper.GetClaimRecord(ctx, claimRecord.Address, claimRecord.Chain)
+		require.NoError(t, err)
+		require.True(t, record.IsEmpty())
+	}
+	require.True(t, keepers.ClaimKeeper.GetModuleAccountBalance(ctx).IsZero())
+	require.Equal(t, sdk.NewDecCoins(sdk.NewInt64DecCoin(types.DefaultClaimDenom, 1000)), keepers.DistrKeeper.GetFeePoolCommunityCoins(ctx))
+
+	// claims only expire once
+	require.NoError(t, keepers.BankKeeper.MintCoins(ctx, types.ModuleName, sdk.NewCoins(sdk.NewInt64Coin(types.DefaultClaimDenom, 10))))
+	ctx = ctx.WithBlockTime(deadline.Add(time.Hour)).WithEventManager(sdk.NewEventManager())
+	require.NoError(t, keepers.ClaimKeeper.ExpireClaims(ctx))
+	require.Empty(t, ctx.EventManager().Events())
+	require.Equal(t, int64(10), keepers.ClaimKeeper.GetModuleAccountBalance(ctx).Amount.Int64())
+
+	// the expiry survives a genesis export and import
+	genesis := claim.ExportGenesis(ctx, keepers.ClaimKeeper)
+	require.True(t, genesis.ClaimsExpired)
+	require.NoError(t, genesis.Validate())
+	imported, importedCtx := testkeeper.CreateTestClaimKeepers(t)
+	claim.InitGenesis(importedCtx, imported.ClaimKeeper, *genesis)
+	require.True(t, imported.ClaimKeeper.ClaimsExpired(importedCtx))
+	importedRecords, err := imported.ClaimKeeper.GetAllClaimRecords(importedCtx)
+	require.NoError(t, err)
+	require.Len(t, importedRecords, len(claimRecords))
+	for _, record := range importedRecords {
+		require.True(t, record.IsEmpty())
+	}
+}
+
+func TestExpireClaimsToSweepAddress(t *testing.T) {
+	keepers, ctx, claimRecords, deadline := setupExpiringClaims(t)
+	sweepAddress := utils.GetRandomArkeoAddress()
+	params := keepers.ClaimKeeper.GetParams(ctx)
+	params.SweepAddress = sweepAddress.String()
+	keepers.ClaimKeeper.SetParams(ctx, params)
+
+	// a partial claim on the deadline block is too late
+	ctx = ctx.WithBlockTime(deadline)
+	claimed, err := keepers.ClaimKeeper.ClaimCoinsForAction(ctx, claimRecords[2].Address, types.ACTION_VOTE)
+	require.ErrorIs(t, err, types.ErrAirdropEnded)
+	require.True(t, claimed.IsNil())
+
+	require.NoError(t, keepers.ClaimKeeper.ExpireClaims(ctx))
+	require.Equal(t, int64(1000), keepers.BankKeeper.GetBalance(ctx, sweepAddress, types.DefaultClaimDenom).Amount.Int64())
+	require.True(t, keepers.DistrKeeper.GetFeePoolCommunityCoins(ctx).IsZero())
+
+	// claims never expire without a deadline
+	keepers, ctx, _, deadline = setupExpiringClaims(t)
+	params = keepers.ClaimKeeper.GetParams(ctx)
+	params.ClaimDeadline = 0
+	keepers.ClaimKeeper.SetParams(ctx, params)
+	ctx = ctx.WithBlockTime(deadline.Add(24 * time.Hour))
+	require.NoError(t, keepers.ClaimKeeper.ExpireClaims(ctx))
+	require.False(t, keepers.ClaimKeeper.ClaimsExpired(ctx))
+	require.Equal(t, int64(1000), keepers.ClaimKeeper.GetModuleAccountBalance(ctx).Amount.Int64())
+}
+
+func TestExpireClaimsEventOrder(t *testing.T) {
+	// every node must emit the expiries in the same order: by chain, then by
+	// address as the records are stored
+	for i := 0; i < 10; i++ {
+		keepers, ctx, claimRecords, deadline := setupExpiringClaims(t)
+		sort.Slice(claimRecords, func(i, j int) bool {
+			if claimRecords[i].Chain != claimRecords[j].Chain {
+				return claimRecords[i].Chain < claimRecords[j].Chain
+			}
+			return strings.ToLower(claimRecords[i].Address) < strings.ToLower(claimRecords[j].Address)
+		})
+
+		ctx = ctx.WithBlockTime(deadline).WithEventManager(sdk.NewEventManager())
+		require.NoError(t, keepers.ClaimKeeper.ExpireClaims(ctx))
+
+		var expired []string
+		for _, evt := range ctx.EventManager().Events() {
+			if evt.Type != types.EventTypeClaimExpired {
+				continue
+			}
+			attrs := make(map[string]string)
+			for _, attr := range evt.Attributes {
+				attrs[string(attr.Key)] = string(attr.Value)
+			}
+			expired = append(expired, attrs[types.AttributeKeyChain]+"/"+attrs[types.AttributeKeyAddress])
+		}
+		expected := make([]string, 0, len(claimRecords))
+		for _, claimRecord := range claimRecords {
+			expected = append(expected, claimRecord.Chain.String()+"/"+claimRecord.Address)
+		}
+		require.Equal(t, expected, expired)
+	}
+}
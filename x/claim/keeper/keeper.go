@@ -20,6 +20,8 @@ type (
 		paramstore    paramtypes.Subspace
 		accountKeeper types.AccountKeeper
 		bankKeeper    types.BankKeeper
+		stakingKeeper types.StakingKeeper
+		distrKeeper   types.DistrKeeper
 	}
 )
 
@@ -28,6 +30,8 @@ func NewKeeper(
 	storeKey storetypes.StoreKey,
 	accountKeeper types.AccountKeeper,
 	bankKeeper types.BankKeeper,
+	stakingKeeper types.StakingKeeper,
+	distrKeeper types.DistrKeeper,
 	memKey storetypes.StoreKey,
 	ps paramtypes.Subspace,
 ) Keeper {
@@ -41,6 +45,8 @@ func NewKeeper(
 		storeKey:      storeKey,
 		accountKeeper: accountKeeper,
 		bankKeeper:    bankKeeper,
+		stakingKeeper: stakingKeeper,
+		distrKeeper:   distrKeeper,
 		memKey:        memKey,
 		paramstore:    ps,
 	}
@@ -16,16 +16,18 @@ func (k msgServer) AddClaim(goCtx context.Context, msg *types.MsgAddClaim) (*typ
 	ctx.Logger().Info("receive add-claim request", "chain", msg.Chain.String(),
 		"address", msg.Address,
 		"creator", msg.Creator,
-		"amount", msg.Amount)
+		"amount", msg.Amount,
+		"vesting_duration", msg.VestingDuration)
 	// This method is only provide on testnet for test purpose , so allow to override the record
 	coin := sdk.NewCoin(types.DefaultClaimDenom, sdk.NewInt(msg.Amount))
 	claim := types.ClaimRecord{
-		Chain:          msg.Chain,
-		Address:        msg.Address,
-		AmountClaim:    coin,
-		AmountVote:     coin,
-		AmountDelegate: coin,
-		IsTransferable: false,
+		Chain:           msg.Chain,
+		Address:         msg.Address,
+		AmountClaim:     coin,
+		AmountVote:      coin,
+		AmountDelegate:  coin,
+		IsTransferable:  false,
+		VestingDuration: msg.VestingDuration,
 	}
 	if msg.Chain == types.ARKEO {
 		claim.IsTransferable = true
@@ -4,6 +4,7 @@ package keeper_test
 
 import (
 	"testing"
+	"time"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/stretchr/testify/require"
@@ -17,7 +18,7 @@ func TestAddClaim(t *testing.T) {
 	sdkCtx := sdk.UnwrapSDKContext(ctx)
 	creator := utils.GetRandomArkeoAddress()
 	target := utils.GetRandomArkeoAddress()
-	addClaimMsg := types.NewMsgAddClaim(creator.String(), types.ARKEO, target.String(), 1000_00000000)
+	addClaimMsg := types.NewMsgAddClaim(creator, types.ARKEO, target.String(), 1000_00000000, 0)
 	result, err := msgServer.AddClaim(ctx, addClaimMsg)
 	require.NotNil(t, result)
 	require.Nil(t, err)
@@ -31,8 +32,17 @@ func TestAddClaim(t *testing.T) {
 	require.True(t, cr.AmountClaim.Equal(sdk.NewCoin(types.DefaultClaimDenom, sdk.NewInt(addClaimMsg.Amount))))
 	require.True(t, cr.AmountVote.Equal(sdk.NewCoin(types.DefaultClaimDenom, sdk.NewInt(addClaimMsg.Amount))))
 	require.True(t, cr.AmountDelegate.Equal(sdk.NewCoin(types.DefaultClaimDenom, sdk.NewInt(addClaimMsg.Amount))))
+	require.Zero(t, cr.VestingDuration)
 
-	addClaimMsg = types.NewMsgAddClaim(creator.String(), types.ETHEREUM, utils.GetRandomETHAddress(), 1000_00000000)
+	// the vesting duration of the claim overrides the param
+	addClaimMsg = types.NewMsgAddClaim(creator, types.ARKEO, target.String(), 1000_00000000, 24*time.Hour)
+	_, err = msgServer.AddClaim(ctx, addClaimMsg)
+	require.Nil(t, err)
+	cr, err = keepers.ClaimKeeper.GetClaimRecord(sdkCtx, target.String(), types.ARKEO)
+	require.Nil(t, err)
+	require.Equal(t, 24*time.Hour, cr.VestingDuration)
+
+	addClaimMsg = types.NewMsgAddClaim(creator, types.ETHEREUM, utils.GetRandomETHAddress(), 1000_00000000, 0)
 	result, err = msgServer.AddClaim(ctx, addClaimMsg)
 	require.NotNil(t, result)
 	require.Nil(t, err)
@@ -0,0 +1,14 @@
+//go:build !testnet
+
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+)
+
+func (k msgServer) AddMultipleClaims(goCtx context.Context, msg *types.MsgAddMultipleClaims) (*types.MsgAddMultipleClaimsResponse, error) {
+	return nil, fmt.Errorf("MsgAddMultipleClaims is only support on testnet.")
+}
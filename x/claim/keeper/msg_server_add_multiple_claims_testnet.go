@@ -0,0 +1,25 @@
+//go:build testnet
+
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+)
+
+func (k msgServer) AddMultipleClaims(goCtx context.Context, msg *types.MsgAddMultipleClaims) (*types.MsgAddMultipleClaimsResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+	ctx.Logger().Info("receive add-multiple-claims request",
+		"creator", msg.Creator,
+		"claims", len(msg.Claims),
+		"merge", msg.Merge)
+	// This method is only provide on testnet for test purpose
+	if err := k.Keeper.AddClaimRecords(ctx, msg.Claims, msg.Merge); err != nil {
+		return nil, fmt.Errorf("fail to add claim records,err: %w", err)
+	}
+	return &types.MsgAddMultipleClaimsResponse{}, nil
+}
@@ -20,7 +20,19 @@ func (k msgServer) ClaimArkeo(goCtx context.Context, msg *types.MsgClaimArkeo) (
 		return nil, errors.Wrapf(types.ErrNoClaimableAmount, "no claimable amount for %s", msg.Creator)
 	}
 
-	_, err = k.ClaimCoinsForAction(ctx, msg.Creator.String(), types.ACTION_CLAIM)
+	if arkeoClaim.Locked {
+		return nil, errors.Wrapf(types.ErrClaimLocked, "claim record for %s is locked", msg.Creator)
+	}
+
+	var valAddr sdk.ValAddress
+	if msg.DelegateBasisPoints > 0 {
+		valAddr, err = sdk.ValAddressFromBech32(msg.Validator)
+		if err != nil {
+			return nil, errors.Wrapf(types.ErrInvalidValidator, "invalid validator address (%s): %s", msg.Validator, err)
+		}
+	}
+
+	_, err = k.ClaimCoinsForActionWithDelegation(ctx, msg.Creator.String(), types.ACTION_CLAIM, valAddr, msg.DelegateBasisPoints)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to claim coins for %s", msg.Creator)
 	}
@@ -1,11 +1,18 @@
 package keeper_test
 
 import (
+	"strconv"
 	"testing"
+	"time"
 
+	keepertest "github.com/arkeonetwork/arkeo/testutil/keeper"
 	"github.com/arkeonetwork/arkeo/testutil/utils"
 	"github.com/arkeonetwork/arkeo/x/claim/types"
+	"github.com/cosmos/cosmos-sdk/crypto/keys/ed25519"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	vestingtypes "github.com/cosmos/cosmos-sdk/x/auth/vesting/types"
+	stakingtypes "github.com/cosmos/cosmos-sdk/x/staking/types"
 	"github.com/stretchr/testify/require"
 )
 
@@ -65,3 +72,287 @@ func TestClaimArkeo(t *testing.T) {
 	_, err = msgServer.ClaimArkeo(ctx, &claimMessage2)
 	require.ErrorIs(t, err, types.ErrNoClaimableAmount)
 }
+
+func createValidator(t *testing.T, keepers keepertest.TestKeepers, ctx sdk.Context, jailed bool) sdk.ValAddress {
+	pk := ed25519.GenPrivKey().PubKey()
+	valAddr := sdk.ValAddress(pk.Address())
+	validator, err := stakingtypes.NewValidator(valAddr, pk, stakingtypes.Description{})
+	require.NoError(t, err)
+	validator.Jailed = jailed
+	keepers.StakingKeeper.SetValidator(ctx, validator)
+	require.NoError(t, keepers.StakingKeeper.SetValidatorByConsAddr(ctx, validator))
+	return valAddr
+}
+
+func TestClaimArkeoWithDelegation(t *testing.T) {
+	msgServer, keepers, ctx := setupMsgServer(t)
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+
+	err := keepers.BankKeeper.MintCoins(sdkCtx, types.ModuleName, sdk.NewCoins(sdk.NewInt64Coin(types.DefaultClaimDenom, 10000)))
+	require.NoError(t, err)
+
+	validator := createValidator(t, keepers, sdkCtx, false)
+	jailed := createValidator(t, keepers, sdkCtx, true)
+	notBondedPool := authtypes.NewModuleAddress(stakingtypes.NotBondedPoolName)
+
+	newClaim := func(amount int64) sdk.AccAddress {
+		addr := utils.GetRandomArkeoAddress()
+		require.NoError(t, keepers.ClaimKeeper.SetClaimRecord(sdkCtx, types.ClaimRecord{
+			Chain:          types.ARKEO,
+			Address:        addr.String(),
+			AmountClaim:    sdk.NewInt64Coin(types.DefaultClaimDenom, amount),
+			AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, amount),
+			AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, amount),
+		}))
+		return addr
+	}
+	balance := func(addr sdk.AccAddress) int64 {
+		return keepers.BankKeeper.GetBalance(sdkCtx, addr, types.DefaultClaimDenom).Amount.Int64()
+	}
+	delegated := func(addr sdk.AccAddress, valAddr sdk.ValAddress) int64 {
+		delegation, found := keepers.StakingKeeper.GetDelegation(sdkCtx, addr, valAddr)
+		if !found {
+			return 0
+		}
+		return delegation.Shares.TruncateInt64()
+	}
+
+	tests := []struct {
+		name        string
+		amount      int64
+		validator   sdk.ValAddress
+		basisPoints uint64
+		liquid      int64
+		delegated   int64
+		err         error
+	}{
+		{name: "split", amount: 100, validator: validator, basisPoints: 2500, liquid: 75, delegated: 25},
+		{name: "zero fraction", amount: 100, validator: validator, basisPoints: 0, liquid: 100},
+		{name: "whole claim", amount: 100, validator: validator, basisPoints: types.MaxBasisPoints, delegated: 100},
+		{name: "too small to delegate", amount: 1, validator: validator, basisPoints: 2500, liquid: 1},
+		{name: "jailed validator", amount: 100, validator: jailed, basisPoints: 2500, err: types.ErrInvalidValidator},
+		{name: "unknown validator", amount: 100, validator: sdk.ValAddress(utils.GetRandomArkeoAddress()), basisPoints: 2500, err: types.ErrInvalidValidator},
+		{name: "above max share", amount: 100, validator: validator, basisPoints: types.MaxBasisPoints + 1, err: types.ErrInvalidDelegateShare},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := newClaim(tt.amount)
+			moduleBefore := keepers.ClaimKeeper.GetModuleAccountBalance(sdkCtx).Amount.Int64()
+			poolBefore := balance(notBondedPool)
+			sdkCtx = sdkCtx.WithEventManager(sdk.NewEventManager())
+
+			_, err := msgServer.ClaimArkeo(sdk.WrapSDKContext(sdkCtx), &types.MsgClaimArkeo{
+				Creator:             addr,
+				Validator:           tt.validator.String(),
+				DelegateBasisPoints: tt.basisPoints,
+			})
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				// nothing was claimed
+				claimRecord, err := keepers.ClaimKeeper.GetClaimRecord(sdkCtx, addr.String(), types.ARKEO)
+				require.NoError(t, err)
+				require.Equal(t, tt.amount, claimRecord.AmountClaim.Amount.Int64())
+				require.Equal(t, moduleBefore, keepers.ClaimKeeper.GetModuleAccountBalance(sdkCtx).Amount.Int64())
+				require.Zero(t, balance(addr))
+				require.Zero(t, delegated(addr, tt.validator))
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, tt.liquid, balance(addr))
+			require.Equal(t, tt.delegated, delegated(addr, tt.validator))
+			// the module account paid for both parts
+			require.Equal(t, moduleBefore-tt.amount, keepers.ClaimKeeper.GetModuleAccountBalance(sdkCtx).Amount.Int64())
+			require.Equal(t, poolBefore+tt.delegated, balance(notBondedPool))
+
+			claimRecord, err := keepers.ClaimKeeper.GetClaimRecord(sdkCtx, addr.String(), types.ARKEO)
+			require.NoError(t, err)
+			require.True(t, claimRecord.AmountClaim.IsZero())
+
+			attributes := map[string]string{}
+			for _, event := range sdkCtx.EventManager().Events() {
+				if event.Type != types.EventTypeClaim {
+					continue
+				}
+				for _, attr := range event.Attributes {
+					attributes[string(attr.Key)] = string(attr.Value)
+				}
+			}
+			require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, tt.liquid).String(), attributes[types.AttributeKeyLiquidAmount])
+			require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, tt.delegated).String(), attributes[types.AttributeKeyDelegatedAmount])
+		})
+	}
+
+	// the delegated share is bounded by params
+	params := keepers.ClaimKeeper.GetParams(sdkCtx)
+	params.MaxDelegateBasisPoints = 5000
+	keepers.ClaimKeeper.SetParams(sdkCtx, params)
+	addr := newClaim(100)
+	_, err = msgServer.ClaimArkeo(sdk.WrapSDKContext(sdkCtx), &types.MsgClaimArkeo{
+		Creator:             addr,
+		Validator:           validator.String(),
+		DelegateBasisPoints: 5001,
+	})
+	require.ErrorIs(t, err, types.ErrInvalidDelegateShare)
+	_, err = msgServer.ClaimArkeo(sdk.WrapSDKContext(sdkCtx), &types.MsgClaimArkeo{
+		Creator:             addr,
+		Validator:           validator.String(),
+		DelegateBasisPoints: 5000,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(50), balance(addr))
+	require.Equal(t, int64(50), delegated(addr, validator))
+}
+
+func TestClaimArkeoVesting(t *testing.T) {
+	msgServer, keepers, ctx := setupMsgServer(t)
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+	now := sdkCtx.BlockTime()
+
+	err := keepers.BankKeeper.MintCoins(sdkCtx, types.ModuleName, sdk.NewCoins(sdk.NewInt64Coin(types.DefaultClaimDenom, 10000)))
+	require.NoError(t, err)
+	params := keepers.ClaimKeeper.GetParams(sdkCtx)
+	params.VestingDuration = 100 * time.Second
+	keepers.ClaimKeeper.SetParams(sdkCtx, params)
+
+	newClaim := func(amount int64, vestingDuration time.Duration) sdk.AccAddress {
+		addr := utils.GetRandomArkeoAddress()
+		require.NoError(t, keepers.ClaimKeeper.SetClaimRecord(sdkCtx, types.ClaimRecord{
+			Chain:           types.ARKEO,
+			Address:         addr.String(),
+			AmountClaim:     sdk.NewInt64Coin(types.DefaultClaimDenom, amount),
+			VestingDuration: vestingDuration,
+		}))
+		return addr
+	}
+	vestingAccount := func(addr sdk.AccAddress) *vestingtypes.ContinuousVestingAccount {
+		acc, ok := keepers.AccountKeeper.GetAccount(sdkCtx, addr).(*vestingtypes.ContinuousVestingAccount)
+		require.True(t, ok)
+		return acc
+	}
+	periodicAccount := func(addr sdk.AccAddress) *vestingtypes.PeriodicVestingAccount {
+		acc, ok := keepers.AccountKeeper.GetAccount(sdkCtx, addr).(*vestingtypes.PeriodicVestingAccount)
+		require.True(t, ok)
+		return acc
+	}
+	coins := func(amount int64) sdk.Coins {
+		return sdk.NewCoins(sdk.NewInt64Coin(types.DefaultClaimDenom, amount))
+	}
+
+	// a new account vests the claim over the param
+	addr := newClaim(100, 0)
+	sdkCtx = sdkCtx.WithEventManager(sdk.NewEventManager())
+	_, err = msgServer.ClaimArkeo(sdk.WrapSDKContext(sdkCtx), &types.MsgClaimArkeo{Creator: addr})
+	require.NoError(t, err)
+	acc := vestingAccount(addr)
+	require.Equal(t, coins(100), acc.OriginalVesting)
+	require.Equal(t, now.Unix(), acc.StartTime)
+	require.Equal(t, now.Add(100*time.Second).Unix(), acc.EndTime)
+	require.Equal(t, int64(100), keepers.BankKeeper.GetBalance(sdkCtx, addr, types.DefaultClaimDenom).Amount.Int64())
+	require.True(t, keepers.BankKeeper.SpendableCoins(sdkCtx, addr).IsZero())
+	vestingEnd := ""
+	for _, event := range sdkCtx.EventManager().Events() {
+		for _, attr := range event.Attributes {
+			if event.Type == types.EventTypeClaim && string(attr.Key) == types.AttributeKeyVestingEndTime {
+				vestingEnd = string(attr.Value)
+			}
+		}
+	}
+	require.Equal(t, strconv.FormatInt(acc.EndTime, 10), vestingEnd)
+
+	// half of it is unlocked half way through
+	res, err := keepers.ClaimKeeper.ClaimRecord(sdk.WrapSDKContext(sdkCtx.WithBlockTime(now.Add(50*time.Second))), &types.QueryClaimRecordRequest{
+		Address: addr.String(),
+		Chain:   types.ARKEO,
+	})
+	require.NoError(t, err)
+	require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, 50), res.VestingLocked)
+	require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, 50), res.VestingUnlocked)
+
+	// the claim of an account already vesting keeps its own schedule, the coins
+	// already vested stay vested
+	addr = newClaim(100, 300*time.Second)
+	base := keepers.AccountKeeper.NewAccountWithAddress(sdkCtx, addr).(*authtypes.BaseAccount)
+	keepers.AccountKeeper.SetAccount(sdkCtx, vestingtypes.NewContinuousVestingAccount(base, coins(100), now.Add(-100*time.Second).Unix(), now.Add(100*time.Second).Unix()))
+	require.NoError(t, keepers.BankKeeper.SendCoinsFromModuleToAccount(sdkCtx, types.ModuleName, addr, coins(100)))
+	_, err = msgServer.ClaimArkeo(ctx, &types.MsgClaimArkeo{Creator: addr})
+	require.NoError(t, err)
+	periodic := periodicAccount(addr)
+	require.Equal(t, coins(200), periodic.OriginalVesting)
+	require.Equal(t, now.Add(-100*time.Second).Unix(), periodic.StartTime)
+	require.Equal(t, now.Add(300*time.Second).Unix(), periodic.EndTime)
+	require.Equal(t, int64(200), keepers.BankKeeper.GetBalance(sdkCtx, addr, types.DefaultClaimDenom).Amount.Int64())
+	require.Equal(t, coins(50), periodic.GetVestedCoins(now))
+	require.Equal(t, coins(133), periodic.GetVestedCoins(now.Add(100*time.Second)))
+	require.Equal(t, coins(200), periodic.GetVestedCoins(now.Add(300*time.Second)))
+
+	// a claim after the schedule ended doesn't lock the coins vested again
+	addr = newClaim(100, 0)
+	base = keepers.AccountKeeper.NewAccountWithAddress(sdkCtx, addr).(*authtypes.BaseAccount)
+	keepers.AccountKeeper.SetAccount(sdkCtx, vestingtypes.NewContinuousVestingAccount(base, coins(100), now.Add(-200*time.Second).Unix(), now.Add(-100*time.Second).Unix()))
+	require.NoError(t, keepers.BankKeeper.SendCoinsFromModuleToAccount(sdkCtx, types.ModuleName, addr, coins(100)))
+	_, err = msgServer.ClaimArkeo(ctx, &types.MsgClaimArkeo{Creator: addr})
+	require.NoError(t, err)
+	periodic = periodicAccount(addr)
+	require.Equal(t, coins(200), periodic.OriginalVesting)
+	require.Equal(t, now.Add(100*time.Second).Unix(), periodic.EndTime)
+	require.Equal(t, coins(100), periodic.GetVestedCoins(now))
+	require.Equal(t, coins(100), keepers.BankKeeper.SpendableCoins(sdkCtx, addr))
+	require.Equal(t, coins(150), periodic.GetVestedCoins(now.Add(50*time.Second)))
+
+	// a schedule ending later than the claim isn't shortened
+	addr = newClaim(100, 0)
+	base = keepers.AccountKeeper.NewAccountWithAddress(sdkCtx, addr).(*authtypes.BaseAccount)
+	keepers.AccountKeeper.SetAccount(sdkCtx, vestingtypes.NewContinuousVestingAccount(base, coins(100), now.Unix(), now.Add(1000*time.Second).Unix()))
+	_, err = msgServer.ClaimArkeo(ctx, &types.MsgClaimArkeo{Creator: addr})
+	require.NoError(t, err)
+	periodic = periodicAccount(addr)
+	require.Equal(t, coins(200), periodic.OriginalVesting)
+	require.Equal(t, now.Add(1000*time.Second).Unix(), periodic.EndTime)
+	require.Equal(t, coins(110), periodic.GetVestedCoins(now.Add(100*time.Second)))
+
+	// a later claim is merged in the periodic schedule
+	require.NoError(t, keepers.ClaimKeeper.SetClaimRecord(sdkCtx, types.ClaimRecord{
+		Chain:       types.ARKEO,
+		Address:     addr.String(),
+		AmountClaim: sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+	}))
+	_, err = msgServer.ClaimArkeo(ctx, &types.MsgClaimArkeo{Creator: addr})
+	require.NoError(t, err)
+	periodic = periodicAccount(addr)
+	require.Equal(t, coins(300), periodic.OriginalVesting)
+	require.Equal(t, now.Unix(), periodic.StartTime)
+	require.Equal(t, now.Add(1000*time.Second).Unix(), periodic.EndTime)
+	require.Equal(t, coins(210), periodic.GetVestedCoins(now.Add(100*time.Second)))
+	require.Equal(t, coins(300), periodic.GetVestedCoins(now.Add(1000*time.Second)))
+
+	// the delegated part of the claim is tracked as vesting
+	validator := createValidator(t, keepers, sdkCtx, false)
+	addr = newClaim(100, 0)
+	_, err = msgServer.ClaimArkeo(ctx, &types.MsgClaimArkeo{
+		Creator:             addr,
+		Validator:           validator.String(),
+		DelegateBasisPoints: 2500,
+	})
+	require.NoError(t, err)
+	acc = vestingAccount(addr)
+	require.Equal(t, coins(100), acc.OriginalVesting)
+	require.Equal(t, coins(25), acc.DelegatedVesting)
+
+	// other vesting accounts can't receive vesting claims
+	addr = newClaim(100, 0)
+	base = keepers.AccountKeeper.NewAccountWithAddress(sdkCtx, addr).(*authtypes.BaseAccount)
+	keepers.AccountKeeper.SetAccount(sdkCtx, vestingtypes.NewDelayedVestingAccount(base, coins(100), now.Add(time.Hour).Unix()))
+	_, err = msgServer.ClaimArkeo(ctx, &types.MsgClaimArkeo{Creator: addr})
+	require.ErrorIs(t, err, types.ErrCannotVest)
+
+	// a zero vesting duration pays the claim out at once
+	params.VestingDuration = 0
+	keepers.ClaimKeeper.SetParams(sdkCtx, params)
+	addr = newClaim(100, 0)
+	_, err = msgServer.ClaimArkeo(ctx, &types.MsgClaimArkeo{Creator: addr})
+	require.NoError(t, err)
+	_, ok := keepers.AccountKeeper.GetAccount(sdkCtx, addr).(*authtypes.BaseAccount)
+	require.True(t, ok)
+	require.Equal(t, coins(100), keepers.BankKeeper.SpendableCoins(sdkCtx, addr))
+}
@@ -27,6 +27,10 @@ func (k msgServer) ClaimEth(goCtx context.Context, msg *types.MsgClaimEth) (*typ
 	if ethClaim.IsEmpty() || ethClaim.AmountClaim.IsZero() {
 		return nil, errors.Wrapf(types.ErrNoClaimableAmount, "no claimable amount for %s", msg.Creator)
 	}
+
+	if ethClaim.Locked {
+		return nil, errors.Wrapf(types.ErrClaimLocked, "claim record for %s is locked", msg.EthAddress)
+	}
 	totalAmountClaimable := getInitialClaimableAmountTotal(ethClaim)
 
 	// validate signature
@@ -43,11 +47,12 @@ func (k msgServer) ClaimEth(goCtx context.Context, msg *types.MsgClaimEth) (*typ
 
 	// create new arkeo claim
 	arkeoClaim := types.ClaimRecord{
-		Address:        msg.Creator.String(),
-		Chain:          types.ARKEO,
-		AmountClaim:    ethClaim.AmountClaim,
-		AmountVote:     ethClaim.AmountVote,
-		AmountDelegate: ethClaim.AmountDelegate,
+		Address:         msg.Creator.String(),
+		Chain:           types.ARKEO,
+		AmountClaim:     ethClaim.AmountClaim,
+		AmountVote:      ethClaim.AmountVote,
+		AmountDelegate:  ethClaim.AmountDelegate,
+		VestingDuration: ethClaim.VestingDuration,
 	}
 
 	// set eth claim to completed
@@ -207,5 +212,10 @@ func mergeClaimRecords(claimA, claimB types.ClaimRecord) (types.ClaimRecord, err
 		claimA.AmountVote = claimA.AmountVote.Add(claimB.AmountVote)
 	}
 
+	// the merged claim vests as slowly as the slowest of the two
+	if claimB.VestingDuration > claimA.VestingDuration {
+		claimA.VestingDuration = claimB.VestingDuration
+	}
+
 	return claimA, nil
 }
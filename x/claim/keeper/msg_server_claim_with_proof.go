@@ -0,0 +1,125 @@
+package keeper
+
+import (
+	"context"
+	"strings"
+
+	"cosmossdk.io/errors"
+	"github.com/cosmos/cosmos-sdk/store/prefix"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+)
+
+// ClaimWithProof adds the claim of a leaf of the merkle tree whose root is in
+// the params, proven by the hashes of its siblings. An arkeo claim can only be
+// made by its address and is claimed right away, an ethereum claim is then
+// claimed with MsgClaimEth.
+func (k msgServer) ClaimWithProof(goCtx context.Context, msg *types.MsgClaimWithProof) (*types.MsgClaimWithProofResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+
+	rawRoot := k.MerkleRoot(ctx)
+	if len(rawRoot) == 0 {
+		return nil, errors.Wrap(types.ErrMerkleRootNotSet, "claims with a proof are disabled")
+	}
+	root, err := types.DecodeMerkleHash(rawRoot)
+	if err != nil {
+		return nil, errors.Wrapf(types.ErrMerkleRootNotSet, "invalid merkle root: %s", err)
+	}
+	proof, err := msg.ProofHashes()
+	if err != nil {
+		return nil, errors.Wrapf(types.ErrInvalidMerkleProof, "%s", err)
+	}
+	leaf := types.MerkleLeaf(msg.Chain, msg.Address, msg.Amount)
+	if !types.VerifyMerkleProof(root, leaf, proof) {
+		return nil, errors.Wrapf(types.ErrInvalidMerkleProof, "claim of %d for %s is not in the merkle tree", msg.Amount, msg.Address)
+	}
+	if k.IsMerkleLeafClaimed(ctx, root, leaf) {
+		return nil, errors.Wrapf(types.ErrAlreadyClaimed, "claim of %d for %s", msg.Amount, msg.Address)
+	}
+	if msg.Chain == types.ARKEO && !strings.EqualFold(msg.Address, msg.Creator.String()) {
+		return nil, errors.Wrapf(sdkerrors.ErrUnauthorized, "%s cannot claim for %s", msg.Creator, msg.Address)
+	}
+
+	coin := sdk.NewInt64Coin(k.ClaimDenom(ctx), msg.Amount)
+	claimRecord := types.ClaimRecord{
+		Chain:          msg.Chain,
+		Address:        msg.Address,
+		AmountClaim:    coin,
+		AmountVote:     coin,
+		AmountDelegate: coin,
+		IsTransferable: msg.Chain == types.ARKEO,
+	}
+
+	// see if there is an existing claim so we can merge it
+	existing, err := k.GetClaimRecord(ctx, msg.Address, msg.Chain)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get claim record for %s", msg.Address)
+	}
+	if !existing.IsEmpty() {
+		claimRecord.Address = existing.Address
+	}
+	claimRecord, err = mergeClaimRecords(existing, claimRecord)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to merge claim records for %s", msg.Address)
+	}
+	if err := k.SetClaimRecord(ctx, claimRecord); err != nil {
+		return nil, errors.Wrapf(err, "failed to set claim record for %s", msg.Address)
+	}
+	k.SetMerkleLeafClaimed(ctx, root, leaf)
+
+	ctx.EventManager().EmitEvents(sdk.Events{
+		sdk.NewEvent(
+			types.EventTypeClaimWithProof,
+			sdk.NewAttribute(types.AttributeKeyChain, msg.Chain.String()),
+			sdk.NewAttribute(types.AttributeKeyAddress, strings.ToLower(msg.Address)),
+			sdk.NewAttribute(sdk.AttributeKeyAmount, coin.String()),
+		),
+	})
+
+	if msg.Chain == types.ARKEO {
+		if _, err := k.ClaimCoinsForAction(ctx, msg.Creator.String(), types.ACTION_CLAIM); err != nil {
+			return nil, errors.Wrapf(err, "failed to claim coins for %s", msg.Creator)
+		}
+	}
+
+	return &types.MsgClaimWithProofResponse{}, nil
+}
+
+// IsMerkleLeafClaimed returns true if the leaf was already claimed against the
+// merkle root. Leaves are tracked by root, so a new root can give an address
+// the same amount again.
+func (k Keeper) IsMerkleLeafClaimed(ctx sdk.Context, root, leaf []byte) bool {
+	return k.merkleClaimsStore(ctx).Has(merkleClaimKey(root, leaf))
+}
+
+// SetMerkleLeafClaimed marks the leaf as claimed against the merkle root
+func (k Keeper) SetMerkleLeafClaimed(ctx sdk.Context, root, leaf []byte) {
+	k.SetMerkleClaim(ctx, merkleClaimKey(root, leaf))
+}
+
+// SetMerkleClaim marks the key of a leaf as claimed
+func (k Keeper) SetMerkleClaim(ctx sdk.Context, key []byte) {
+	k.merkleClaimsStore(ctx).Set(key, []byte{1})
+}
+
+// GetMerkleClaims returns the keys of the leaves claimed, for genesis export
+func (k Keeper) GetMerkleClaims(ctx sdk.Context) [][]byte {
+	iterator := k.merkleClaimsStore(ctx).Iterator(nil, nil)
+	defer iterator.Close()
+
+	var keys [][]byte
+	for ; iterator.Valid(); iterator.Next() {
+		keys = append(keys, iterator.Key())
+	}
+	return keys
+}
+
+func (k Keeper) merkleClaimsStore(ctx sdk.Context) prefix.Store {
+	return prefix.NewStore(ctx.KVStore(k.storeKey), types.KeyPrefix(types.MerkleClaimsStorePrefix))
+}
+
+func merkleClaimKey(root, leaf []byte) []byte {
+	return append(append([]byte{}, root...), leaf...)
+}
@@ -0,0 +1,100 @@
+package keeper_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/testutil/utils"
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimWithProof(t *testing.T) {
+	msgServer, keepers, ctx := setupMsgServer(t)
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+
+	err := keepers.BankKeeper.MintCoins(sdkCtx, types.ModuleName, sdk.NewCoins(sdk.NewInt64Coin(types.DefaultClaimDenom, 10000)))
+	require.NoError(t, err)
+
+	addrArkeo := utils.GetRandomArkeoAddress()
+	otherArkeo := utils.GetRandomArkeoAddress()
+	addrEth := "0xDAFEA492D9c6733ae3d56b7Ed1ADB60692c98Bc5"
+	type leaf struct {
+		chain   types.Chain
+		address string
+		amount  int64
+	}
+	leaves := []leaf{
+		{types.ARKEO, addrArkeo.String(), 100},
+		{types.ARKEO, otherArkeo.String(), 200},
+		{types.ETHEREUM, addrEth, 300},
+	}
+	hashes := make([][]byte, 0, len(leaves))
+	for _, l := range leaves {
+		hashes = append(hashes, types.MerkleLeaf(l.chain, l.address, l.amount))
+	}
+	proof := func(index int) []string {
+		siblings, err := types.MerkleProof(hashes, index)
+		require.NoError(t, err)
+		encoded := make([]string, 0, len(siblings))
+		for _, sibling := range siblings {
+			encoded = append(encoded, hex.EncodeToString(sibling))
+		}
+		return encoded
+	}
+	claim := func(creator sdk.AccAddress, index int, amount int64, proof []string) error {
+		l := leaves[index]
+		_, err := msgServer.ClaimWithProof(ctx, types.NewMsgClaimWithProof(creator, l.chain, l.address, amount, proof))
+		return err
+	}
+
+	// disabled until a root is set
+	require.ErrorIs(t, claim(addrArkeo, 0, 100, proof(0)), types.ErrMerkleRootNotSet)
+
+	params := keepers.ClaimKeeper.GetParams(sdkCtx)
+	params.MerkleRoot = hex.EncodeToString(types.MerkleRoot(hashes))
+	keepers.ClaimKeeper.SetParams(sdkCtx, params)
+
+	// invalid proofs
+	require.ErrorIs(t, claim(addrArkeo, 0, 1000, proof(0)), types.ErrInvalidMerkleProof)
+	require.ErrorIs(t, claim(addrArkeo, 0, 100, proof(1)), types.ErrInvalidMerkleProof)
+	require.ErrorIs(t, claim(addrArkeo, 0, 100, nil), types.ErrInvalidMerkleProof)
+
+	// an arkeo claim is made by its address only, and claimed right away
+	require.ErrorIs(t, claim(addrArkeo, 1, 200, proof(1)), sdkerrors.ErrUnauthorized)
+	require.NoError(t, claim(addrArkeo, 0, 100, proof(0)))
+	balance := keepers.BankKeeper.GetBalance(sdkCtx, addrArkeo, types.DefaultClaimDenom)
+	require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, 100), balance)
+	claimRecord, err := keepers.ClaimKeeper.GetClaimRecord(sdkCtx, addrArkeo.String(), types.ARKEO)
+	require.NoError(t, err)
+	require.True(t, claimRecord.AmountClaim.IsNil() || claimRecord.AmountClaim.IsZero())
+	require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, 100), claimRecord.AmountVote)
+	require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, 100), claimRecord.AmountDelegate)
+
+	found := false
+	for _, evt := range sdkCtx.EventManager().Events() {
+		if evt.Type == types.EventTypeClaimWithProof {
+			found = true
+		}
+	}
+	require.True(t, found)
+
+	// already claimed
+	require.ErrorIs(t, claim(addrArkeo, 0, 100, proof(0)), types.ErrAlreadyClaimed)
+	balance = keepers.BankKeeper.GetBalance(sdkCtx, addrArkeo, types.DefaultClaimDenom)
+	require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, 100), balance)
+
+	// an ethereum claim is added for its owner to claim with MsgClaimEth
+	require.NoError(t, claim(otherArkeo, 2, 300, proof(2)))
+	claimRecord, err = keepers.ClaimKeeper.GetClaimRecord(sdkCtx, addrEth, types.ETHEREUM)
+	require.NoError(t, err)
+	require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, 300), claimRecord.AmountClaim)
+	require.False(t, claimRecord.IsTransferable)
+	require.ErrorIs(t, claim(otherArkeo, 2, 300, proof(2)), types.ErrAlreadyClaimed)
+	require.True(t, keepers.BankKeeper.GetBalance(sdkCtx, otherArkeo, types.DefaultClaimDenom).IsZero())
+
+	// leaves claimed are exported
+	require.Len(t, keepers.ClaimKeeper.GetMerkleClaims(sdkCtx), 2)
+}
@@ -0,0 +1,49 @@
+package keeper
+
+import (
+	"context"
+	"strings"
+
+	"cosmossdk.io/errors"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+)
+
+func (k msgServer) LockClaim(goCtx context.Context, msg *types.MsgLockClaim) (*types.MsgLockClaimResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+	if err := k.setClaimLocked(ctx, msg.Authority, msg.Chain, msg.Address, true); err != nil {
+		return nil, err
+	}
+
+	ctx.EventManager().EmitEvents(sdk.Events{
+		sdk.NewEvent(
+			types.EventTypeClaimLocked,
+			sdk.NewAttribute(types.AttributeKeyAuthority, msg.Authority.String()),
+			sdk.NewAttribute(types.AttributeKeyChain, msg.Chain.String()),
+			sdk.NewAttribute(types.AttributeKeyAddress, strings.ToLower(msg.Address)),
+		),
+	})
+
+	return &types.MsgLockClaimResponse{}, nil
+}
+
+func (k msgServer) setClaimLocked(ctx sdk.Context, authority sdk.AccAddress, chain types.Chain, address string, locked bool) error {
+	if !k.IsClaimAuthority(ctx, authority) {
+		return errors.Wrapf(types.ErrInvalidAuthority, "%s is not allowed to lock claims", authority)
+	}
+
+	claimRecord, err := k.GetClaimRecord(ctx, address, chain)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get claim record for %s", address)
+	}
+	if claimRecord.IsEmpty() {
+		return errors.Wrapf(types.ErrNoClaimableAmount, "no claim record for %s", address)
+	}
+
+	claimRecord.Locked = locked
+	if err := k.SetClaimRecord(ctx, claimRecord); err != nil {
+		return errors.Wrapf(err, "failed to set claim record for %s", address)
+	}
+	return nil
+}
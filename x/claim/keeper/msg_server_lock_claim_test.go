@@ -0,0 +1,78 @@
+package keeper_test
+
+import (
+	"testing"
+
+	"github.com/arkeonetwork/arkeo/testutil/utils"
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
+	govtypes "github.com/cosmos/cosmos-sdk/x/gov/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockClaim(t *testing.T) {
+	msgServer, keepers, ctx := setupMsgServer(t)
+	sdkCtx := sdk.UnwrapSDKContext(ctx)
+
+	compliance := utils.GetRandomArkeoAddress()
+	params := keepers.ClaimKeeper.GetParams(sdkCtx)
+	params.ComplianceAuthority = compliance.String()
+	keepers.ClaimKeeper.SetParams(sdkCtx, params)
+
+	addrArkeo := utils.GetRandomArkeoAddress()
+	claimRecord := types.ClaimRecord{
+		Chain:          types.ARKEO,
+		Address:        addrArkeo.String(),
+		AmountClaim:    sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+		AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+		AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+	}
+	require.NoError(t, keepers.ClaimKeeper.SetClaimRecord(sdkCtx, claimRecord))
+
+	err := keepers.BankKeeper.MintCoins(sdkCtx, types.ModuleName, sdk.NewCoins(sdk.NewInt64Coin(types.DefaultClaimDenom, 10000)))
+	require.NoError(t, err)
+
+	// only governance or the compliance authority can lock a claim
+	_, err = msgServer.LockClaim(ctx, types.NewMsgLockClaim(utils.GetRandomArkeoAddress(), types.ARKEO, addrArkeo.String()))
+	require.ErrorIs(t, err, types.ErrInvalidAuthority)
+
+	_, err = msgServer.LockClaim(ctx, types.NewMsgLockClaim(compliance, types.ARKEO, addrArkeo.String()))
+	require.NoError(t, err)
+	claimRecord, err = keepers.ClaimKeeper.GetClaimRecord(sdkCtx, addrArkeo.String(), types.ARKEO)
+	require.NoError(t, err)
+	require.True(t, claimRecord.Locked)
+
+	found := false
+	for _, evt := range sdkCtx.EventManager().Events() {
+		if evt.Type == types.EventTypeClaimLocked {
+			found = true
+		}
+	}
+	require.True(t, found)
+
+	// locked claims can't be claimed
+	claimMessage := types.MsgClaimArkeo{Creator: addrArkeo}
+	_, err = msgServer.ClaimArkeo(ctx, &claimMessage)
+	require.ErrorIs(t, err, types.ErrClaimLocked)
+	_, err = keepers.ClaimKeeper.ClaimCoinsForAction(sdkCtx, addrArkeo.String(), types.ACTION_VOTE)
+	require.ErrorIs(t, err, types.ErrClaimLocked)
+	balance := keepers.BankKeeper.GetBalance(sdkCtx, addrArkeo, types.DefaultClaimDenom)
+	require.True(t, balance.IsZero())
+
+	// unlock via governance
+	_, err = msgServer.UnlockClaim(ctx, types.NewMsgUnlockClaim(utils.GetRandomArkeoAddress(), types.ARKEO, addrArkeo.String()))
+	require.ErrorIs(t, err, types.ErrInvalidAuthority)
+	gov := authtypes.NewModuleAddress(govtypes.ModuleName)
+	_, err = msgServer.UnlockClaim(ctx, types.NewMsgUnlockClaim(gov, types.ARKEO, addrArkeo.String()))
+	require.NoError(t, err)
+
+	_, err = msgServer.ClaimArkeo(ctx, &claimMessage)
+	require.NoError(t, err)
+	balance = keepers.BankKeeper.GetBalance(sdkCtx, addrArkeo, types.DefaultClaimDenom)
+	require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, 100), balance)
+
+	// can't lock a claim that doesn't exist
+	_, err = msgServer.LockClaim(ctx, types.NewMsgLockClaim(gov, types.ARKEO, utils.GetRandomArkeoAddress().String()))
+	require.ErrorIs(t, err, types.ErrNoClaimableAmount)
+}
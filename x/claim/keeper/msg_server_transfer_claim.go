@@ -22,18 +22,23 @@ func (k msgServer) TransferClaim(goCtx context.Context, msg *types.MsgTransferCl
 		return nil, errors.Wrapf(types.ErrNoClaimableAmount, "no claimable amount for %s", msg.Creator)
 	}
 
+	if originalClaim.Locked {
+		return nil, errors.Wrapf(types.ErrClaimLocked, "claim record for %s is locked", msg.Creator)
+	}
+
 	if !originalClaim.IsTransferable {
 		return nil, errors.Wrapf(types.ErrClaimRecordNotTransferrable, "claim record for %s is not transferable", msg.Creator)
 	}
 
 	// create new arkeo claim
 	arkeoClaim := types.ClaimRecord{
-		Address:        msg.ToAddress.String(),
-		Chain:          types.ARKEO,
-		AmountClaim:    originalClaim.AmountClaim,
-		AmountVote:     originalClaim.AmountVote,
-		AmountDelegate: originalClaim.AmountDelegate,
-		IsTransferable: false,
+		Address:         msg.ToAddress.String(),
+		Chain:           types.ARKEO,
+		AmountClaim:     originalClaim.AmountClaim,
+		AmountVote:      originalClaim.AmountVote,
+		AmountDelegate:  originalClaim.AmountDelegate,
+		IsTransferable:  false,
+		VestingDuration: originalClaim.VestingDuration,
 	}
 
 	// set claim to completed
@@ -0,0 +1,28 @@
+package keeper
+
+import (
+	"context"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/arkeonetwork/arkeo/x/claim/types"
+)
+
+func (k msgServer) UnlockClaim(goCtx context.Context, msg *types.MsgUnlockClaim) (*types.MsgUnlockClaimResponse, error) {
+	ctx := sdk.UnwrapSDKContext(goCtx)
+	if err := k.setClaimLocked(ctx, msg.Authority, msg.Chain, msg.Address, false); err != nil {
+		return nil, err
+	}
+
+	ctx.EventManager().EmitEvents(sdk.Events{
+		sdk.NewEvent(
+			types.EventTypeClaimUnlocked,
+			sdk.NewAttribute(types.AttributeKeyAuthority, msg.Authority.String()),
+			sdk.NewAttribute(types.AttributeKeyChain, msg.Chain.String()),
+			sdk.NewAttribute(types.AttributeKeyAddress, strings.ToLower(msg.Address)),
+		),
+	})
+
+	return &types.MsgUnlockClaimResponse{}, nil
+}
@@ -9,12 +9,19 @@ import (
 
 // GetParams get all parameters as types.Params
 func (k Keeper) GetParams(ctx sdk.Context) types.Params {
-	return types.NewParams(
+	params := types.NewParams(
 		k.ClaimDenom(ctx),
 		k.AirdropStartTime(ctx),
 		k.DurationUntilDecay(ctx),
 		k.DurationOfDecay(ctx),
 	)
+	params.ComplianceAuthority = k.ComplianceAuthority(ctx)
+	params.MaxDelegateBasisPoints = k.MaxDelegateBasisPoints(ctx)
+	params.MerkleRoot = k.MerkleRoot(ctx)
+	params.VestingDuration = k.VestingDuration(ctx)
+	params.ClaimDeadline = k.ClaimDeadline(ctx)
+	params.SweepAddress = k.SweepAddress(ctx)
+	return params
 }
 
 // SetParams set the params
@@ -44,3 +51,45 @@ func (k Keeper) ClaimDenom(ctx sdk.Context) (res string) {
 	k.paramstore.Get(ctx, types.KeyClaimDenom, &res)
 	return
 }
+
+// ComplianceAuthority returns the ComplianceAuthority param, the param may
+// not be set on chains started before it was introduced
+func (k Keeper) ComplianceAuthority(ctx sdk.Context) (res string) {
+	k.paramstore.GetIfExists(ctx, types.KeyComplianceAuthority, &res)
+	return
+}
+
+// MaxDelegateBasisPoints returns the MaxDelegateBasisPoints param, claims can't
+// be delegated on chains started before it was introduced until it is set
+func (k Keeper) MaxDelegateBasisPoints(ctx sdk.Context) (res uint64) {
+	k.paramstore.GetIfExists(ctx, types.KeyMaxDelegateBasisPoints, &res)
+	return
+}
+
+// MerkleRoot returns the MerkleRoot param, empty when claims with a proof are
+// disabled or on chains started before it was introduced
+func (k Keeper) MerkleRoot(ctx sdk.Context) (res string) {
+	k.paramstore.GetIfExists(ctx, types.KeyMerkleRoot, &res)
+	return
+}
+
+// VestingDuration returns the VestingDuration param, claims are paid out at
+// once on chains started before it was introduced until it is set
+func (k Keeper) VestingDuration(ctx sdk.Context) (res time.Duration) {
+	k.paramstore.GetIfExists(ctx, types.KeyVestingDuration, &res)
+	return
+}
+
+// ClaimDeadline returns the ClaimDeadline param, claims never expire on chains
+// started before it was introduced until it is set
+func (k Keeper) ClaimDeadline(ctx sdk.Context) (res time.Duration) {
+	k.paramstore.GetIfExists(ctx, types.KeyClaimDeadline, &res)
+	return
+}
+
+// SweepAddress returns the SweepAddress param, empty sweeps to the community
+// pool
+func (k Keeper) SweepAddress(ctx sdk.Context) (res string) {
+	k.paramstore.GetIfExists(ctx, types.KeySweepAddress, &res)
+	return
+}
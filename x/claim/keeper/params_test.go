@@ -2,8 +2,10 @@ package keeper_test
 
 import (
 	"testing"
+	"time"
 
 	testkeeper "github.com/arkeonetwork/arkeo/testutil/keeper"
+	"github.com/arkeonetwork/arkeo/testutil/utils"
 	"github.com/arkeonetwork/arkeo/x/claim/types"
 	"github.com/stretchr/testify/require"
 )
@@ -12,6 +14,9 @@ func TestGetParams(t *testing.T) {
 	keepers, ctx := testkeeper.CreateTestClaimKeepers(t)
 	params := types.DefaultParams()
 	params.ClaimDenom = "Test!"
+	params.VestingDuration = time.Hour
+	params.ClaimDeadline = 24 * time.Hour
+	params.SweepAddress = utils.GetRandomArkeoAddress().String()
 	keepers.ClaimKeeper.SetParams(ctx, params)
 	got := keepers.ClaimKeeper.GetParams(ctx)
 	require.EqualValues(t, params, got)
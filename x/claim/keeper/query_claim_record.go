@@ -2,9 +2,12 @@ package keeper
 
 import (
 	"context"
+	"errors"
 
 	"github.com/arkeonetwork/arkeo/x/claim/types"
+	"github.com/cosmos/cosmos-sdk/store/prefix"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/query"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -20,7 +23,112 @@ func (k Keeper) ClaimRecord(goCtx context.Context, req *types.QueryClaimRecordRe
 		return nil, status.Error(codes.NotFound, err.Error())
 	}
 
-	return &types.QueryClaimRecordResponse{
+	res := &types.QueryClaimRecordResponse{
 		ClaimRecord: &claimRecord,
-	}, nil
+	}
+	// only arkeo accounts vest their claims
+	if req.Chain == types.ARKEO {
+		if addr, err := sdk.AccAddressFromBech32(req.Address); err == nil {
+			res.VestingLocked, res.VestingUnlocked = k.VestingClaimedCoins(ctx, addr)
+		}
+	}
+	return res, nil
+}
+
+func (k Keeper) ClaimRecords(goCtx context.Context, req *types.QueryClaimRecordsRequest) (*types.QueryClaimRecordsResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+
+	ctx := sdk.UnwrapSDKContext(goCtx)
+	recordsPrefix := []byte(types.ClaimRecordsStorePrefix)
+	chains := []types.Chain{types.ARKEO, types.ETHEREUM}
+	if len(req.Chain) > 0 {
+		chain, err := types.ChainFromString(req.Chain)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid chain %s", req.Chain)
+		}
+		recordsPrefix = chainToStorePrefix(chain)
+		chains = []types.Chain{chain}
+	}
+
+	// an address has a single record per chain, no need to scan the store
+	if len(req.Address) > 0 {
+		claimRecords := []types.ClaimRecord{}
+		for _, chain := range chains {
+			if !types.IsValidAddress(req.Address, chain) {
+				continue
+			}
+			claimRecord, err := k.GetClaimRecord(ctx, req.Address, chain)
+			if err != nil {
+				return nil, status.Error(codes.Internal, err.Error())
+			}
+			if claimRecord.Address != "" {
+				claimRecords = append(claimRecords, claimRecord)
+			}
+		}
+		return &types.QueryClaimRecordsResponse{ClaimRecords: claimRecords}, nil
+	}
+
+	store := prefix.NewStore(ctx.KVStore(k.storeKey), recordsPrefix)
+	claimRecords := []types.ClaimRecord{}
+	pageRes, err := query.Paginate(store, req.Pagination, func(key, value []byte) error {
+		var claimRecord types.ClaimRecord
+		if err := k.cdc.Unmarshal(value, &claimRecord); err != nil {
+			return err
+		}
+		claimRecords = append(claimRecords, claimRecord)
+		return nil
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &types.QueryClaimRecordsResponse{ClaimRecords: claimRecords, Pagination: pageRes}, nil
+}
+
+func (k Keeper) ClaimRecordByForeignAddress(goCtx context.Context, req *types.QueryClaimRecordByForeignAddressRequest) (*types.QueryClaimRecordByForeignAddressResponse, error) {
+	if req == nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid request")
+	}
+	address, err := types.NormalizeAddress(req.Address, req.Chain)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctx := sdk.UnwrapSDKContext(goCtx)
+	claimRecord, err := k.GetClaimRecord(ctx, address, req.Chain)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if claimRecord.Address == "" {
+		return nil, status.Errorf(codes.NotFound, "no claim record for %s address %s", req.Chain, address)
+	}
+
+	res := &types.QueryClaimRecordByForeignAddressResponse{
+		Address:     address,
+		ClaimRecord: claimRecord,
+		Claimed:     true,
+	}
+	for _, action := range []types.Action{types.ACTION_CLAIM, types.ACTION_VOTE, types.ACTION_DELEGATE} {
+		initial := getInitialClaimableAmount(claimRecord, action)
+		completed := initial.IsNil() || initial.IsZero()
+		amount, err := k.GetClaimableAmountForAction(ctx, address, action, req.Chain)
+		if err != nil && !errors.Is(err, types.ErrAirdropEnded) {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if amount.IsNil() {
+			amount = sdk.NewCoin(k.GetParams(ctx).ClaimDenom, sdk.ZeroInt())
+		}
+		res.Claimable = append(res.Claimable, types.ActionClaimable{
+			Action:    action,
+			Amount:    amount,
+			Completed: completed,
+		})
+		res.Claimed = res.Claimed && completed
+	}
+	// ethereum records can't be claimed directly, they are emptied once moved
+	// to an arkeo address
+	res.Transferred = req.Chain == types.ETHEREUM && res.Claimed
+	return res, nil
 }
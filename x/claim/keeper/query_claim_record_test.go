@@ -1,13 +1,17 @@
 package keeper_test
 
 import (
+	"strings"
 	"testing"
 
 	testkeeper "github.com/arkeonetwork/arkeo/testutil/keeper"
 	"github.com/arkeonetwork/arkeo/testutil/utils"
 	"github.com/arkeonetwork/arkeo/x/claim/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/query"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestClaimRecord(t *testing.T) {
@@ -58,3 +62,151 @@ func TestClaimRecord(t *testing.T) {
 	resp, _ = keepers.ClaimKeeper.ClaimRecord(ctx, &req)
 	require.Equal(t, *resp.ClaimRecord, types.ClaimRecord{})
 }
+
+func TestClaimRecords(t *testing.T) {
+	keepers, ctx := testkeeper.CreateTestClaimKeepers(t)
+
+	var claimRecords []types.ClaimRecord
+	for i := 0; i < 3; i++ {
+		claimRecords = append(claimRecords, types.ClaimRecord{
+			Chain:          types.ARKEO,
+			Address:        utils.GetRandomArkeoAddress().String(),
+			AmountClaim:    sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+			AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+			AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+		})
+	}
+	ethRecord := types.ClaimRecord{
+		Chain:          types.ETHEREUM,
+		Address:        "0xDAFEA492D9c6733ae3d56b7Ed1ADB60692c98Bc5",
+		AmountClaim:    sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+		AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+		AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+	}
+	claimRecords = append(claimRecords, ethRecord)
+	require.NoError(t, keepers.ClaimKeeper.SetClaimRecords(ctx, claimRecords))
+
+	// every chain
+	resp, err := keepers.ClaimKeeper.ClaimRecords(ctx, &types.QueryClaimRecordsRequest{})
+	require.NoError(t, err)
+	require.ElementsMatch(t, claimRecords, resp.ClaimRecords)
+
+	// a single chain
+	resp, err = keepers.ClaimKeeper.ClaimRecords(ctx, &types.QueryClaimRecordsRequest{Chain: "ethereum"})
+	require.NoError(t, err)
+	require.Equal(t, []types.ClaimRecord{ethRecord}, resp.ClaimRecords)
+	resp, err = keepers.ClaimKeeper.ClaimRecords(ctx, &types.QueryClaimRecordsRequest{Chain: "arkeo"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, claimRecords[:3], resp.ClaimRecords)
+
+	// a single address
+	resp, err = keepers.ClaimKeeper.ClaimRecords(ctx, &types.QueryClaimRecordsRequest{Address: claimRecords[1].Address})
+	require.NoError(t, err)
+	require.Equal(t, []types.ClaimRecord{claimRecords[1]}, resp.ClaimRecords)
+	resp, err = keepers.ClaimKeeper.ClaimRecords(ctx, &types.QueryClaimRecordsRequest{Chain: "ethereum", Address: claimRecords[1].Address})
+	require.NoError(t, err)
+	require.Empty(t, resp.ClaimRecords)
+
+	// pages
+	var paged []types.ClaimRecord
+	var next []byte
+	for {
+		resp, err = keepers.ClaimKeeper.ClaimRecords(ctx, &types.QueryClaimRecordsRequest{
+			Pagination: &query.PageRequest{Key: next, Limit: 3, CountTotal: true},
+		})
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(resp.ClaimRecords), 3)
+		paged = append(paged, resp.ClaimRecords...)
+		next = resp.Pagination.NextKey
+		if next == nil {
+			break
+		}
+	}
+	require.ElementsMatch(t, claimRecords, paged)
+
+	_, err = keepers.ClaimKeeper.ClaimRecords(ctx, &types.QueryClaimRecordsRequest{Chain: "bitcoin"})
+	require.Error(t, err)
+}
+
+func TestClaimRecordByForeignAddress(t *testing.T) {
+	keepers, ctx := testkeeper.CreateTestClaimKeepers(t)
+
+	const ethAddr = "0xDAFEA492D9c6733ae3d56b7Ed1ADB60692c98Bc5"
+	arkeoAddr := utils.GetRandomArkeoAddress().String()
+	claimRecords := []types.ClaimRecord{
+		{
+			Chain:          types.ETHEREUM,
+			Address:        strings.ToLower(ethAddr),
+			AmountClaim:    sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+			AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+			AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, 200),
+		},
+		{
+			Chain:          types.ARKEO,
+			Address:        arkeoAddr,
+			AmountClaim:    sdk.Coin{},
+			AmountVote:     sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+			AmountDelegate: sdk.NewInt64Coin(types.DefaultClaimDenom, 100),
+		},
+	}
+	require.NoError(t, keepers.ClaimKeeper.SetClaimRecords(ctx, claimRecords))
+
+	// any case of the address finds the record, the address is checksummed
+	for _, addr := range []string{ethAddr, strings.ToLower(ethAddr), "0x" + strings.ToUpper(ethAddr[2:])} {
+		resp, err := keepers.ClaimKeeper.ClaimRecordByForeignAddress(ctx, &types.QueryClaimRecordByForeignAddressRequest{
+			Chain:   types.ETHEREUM,
+			Address: addr,
+		})
+		require.NoError(t, err)
+		require.Equal(t, ethAddr, resp.Address)
+		require.Equal(t, claimRecords[0], resp.ClaimRecord)
+		require.Len(t, resp.Claimable, 3)
+		for _, claimable := range resp.Claimable {
+			require.Equal(t, sdk.NewInt64Coin(types.DefaultClaimDenom, 200), claimable.Amount)
+			require.False(t, claimable.Completed)
+		}
+		require.False(t, resp.Claimed)
+		require.False(t, resp.Transferred)
+	}
+
+	// the completed action has nothing left to claim
+	resp, err := keepers.ClaimKeeper.ClaimRecordByForeignAddress(ctx, &types.QueryClaimRecordByForeignAddressRequest{
+		Chain:   types.ARKEO,
+		Address: strings.ToUpper(arkeoAddr),
+	})
+	require.NoError(t, err)
+	require.Equal(t, arkeoAddr, resp.Address)
+	require.Equal(t, types.ActionClaimable{
+		Action:    types.ACTION_CLAIM,
+		Amount:    sdk.NewInt64Coin(types.DefaultClaimDenom, 0),
+		Completed: true,
+	}, resp.Claimable[0])
+	require.False(t, resp.Claimable[1].Completed)
+	require.False(t, resp.Claimed)
+
+	// an emptied ethereum record was moved to an arkeo address
+	claimRecords[0].AmountClaim = sdk.Coin{}
+	claimRecords[0].AmountVote = sdk.Coin{}
+	claimRecords[0].AmountDelegate = sdk.Coin{}
+	require.NoError(t, keepers.ClaimKeeper.SetClaimRecord(ctx, claimRecords[0]))
+	resp, err = keepers.ClaimKeeper.ClaimRecordByForeignAddress(ctx, &types.QueryClaimRecordByForeignAddressRequest{
+		Chain:   types.ETHEREUM,
+		Address: ethAddr,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.Claimed)
+	require.True(t, resp.Transferred)
+
+	// a bad checksum is rejected rather than looked up
+	_, err = keepers.ClaimKeeper.ClaimRecordByForeignAddress(ctx, &types.QueryClaimRecordByForeignAddressRequest{
+		Chain:   types.ETHEREUM,
+		Address: "0xdAFEA492D9c6733ae3d56b7Ed1ADB60692c98Bc5",
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = keepers.ClaimKeeper.ClaimRecordByForeignAddress(ctx, &types.QueryClaimRecordByForeignAddressRequest{
+		Chain:   types.ETHEREUM,
+		Address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+	})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -1,6 +1,12 @@
 package types
 
-import sdk "github.com/cosmos/cosmos-sdk/types"
+import (
+	"fmt"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ethereum/go-ethereum/common"
+)
 
 func IsValidAddress(address string, chain Chain) bool {
 	switch chain {
@@ -13,3 +19,30 @@ func IsValidAddress(address string, chain Chain) bool {
 		return false
 	}
 }
+
+// NormalizeAddress returns the canonical form of the address on the chain:
+// checksummed hex for ethereum and lowercase bech32 for arkeo. A mixed case
+// ethereum address must carry a valid checksum.
+func NormalizeAddress(address string, chain Chain) (string, error) {
+	address = strings.TrimSpace(address)
+	switch chain {
+	case ETHEREUM:
+		if !IsValidEthAddress(address) {
+			return "", fmt.Errorf("invalid ethereum address %s", address)
+		}
+		checksummed := common.HexToAddress(address).Hex()
+		hex := strings.TrimPrefix(strings.TrimPrefix(address, "0x"), "0X")
+		if hex != strings.ToLower(hex) && hex != strings.ToUpper(hex) && hex != checksummed[2:] {
+			return "", fmt.Errorf("invalid ethereum address checksum %s, expected %s", address, checksummed)
+		}
+		return checksummed, nil
+	case ARKEO:
+		addr, err := sdk.AccAddressFromBech32(address)
+		if err != nil {
+			return "", fmt.Errorf("invalid arkeo address %s: %w", address, err)
+		}
+		return addr.String(), nil
+	default:
+		return "", fmt.Errorf("invalid chain %s", chain)
+	}
+}
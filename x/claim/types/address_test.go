@@ -0,0 +1,53 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	const checksummed = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	arkeoAddr := sdk.AccAddress([]byte("claim_normalize_addr")).String()
+	foreignAddr, err := bech32.ConvertAndEncode("thor", []byte("claim_normalize_addr"))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		chain    Chain
+		address  string
+		expected string
+		err      string
+	}{
+		{name: "eth checksummed", chain: ETHEREUM, address: checksummed, expected: checksummed},
+		{name: "eth lowercase", chain: ETHEREUM, address: strings.ToLower(checksummed), expected: checksummed},
+		{name: "eth uppercase", chain: ETHEREUM, address: "0x" + strings.ToUpper(checksummed[2:]), expected: checksummed},
+		{name: "eth without prefix", chain: ETHEREUM, address: strings.ToLower(checksummed[2:]), expected: checksummed},
+		{name: "eth surrounding spaces", chain: ETHEREUM, address: " " + checksummed + "\n", expected: checksummed},
+		{name: "eth bad checksum", chain: ETHEREUM, address: "0x5AAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", err: "checksum"},
+		{name: "eth too short", chain: ETHEREUM, address: checksummed[:40], err: "invalid ethereum address"},
+		{name: "eth not hex", chain: ETHEREUM, address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeg", err: "invalid ethereum address"},
+		{name: "eth empty", chain: ETHEREUM, address: "", err: "invalid ethereum address"},
+		{name: "eth given an arkeo address", chain: ETHEREUM, address: arkeoAddr, err: "invalid ethereum address"},
+		{name: "arkeo", chain: ARKEO, address: arkeoAddr, expected: arkeoAddr},
+		{name: "arkeo uppercase", chain: ARKEO, address: strings.ToUpper(arkeoAddr), expected: arkeoAddr},
+		{name: "arkeo mixed case", chain: ARKEO, address: strings.ToUpper(arkeoAddr[:3]) + arkeoAddr[3:], err: "invalid arkeo address"},
+		{name: "arkeo wrong prefix", chain: ARKEO, address: foreignAddr, err: "invalid arkeo address"},
+		{name: "arkeo given an eth address", chain: ARKEO, address: checksummed, err: "invalid arkeo address"},
+		{name: "unknown chain", chain: Chain(10), address: checksummed, err: "invalid chain"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			normalized, err := NormalizeAddress(tc.address, tc.chain)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, normalized)
+		})
+	}
+}
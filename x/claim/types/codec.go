@@ -12,6 +12,10 @@ func RegisterCodec(cdc *codec.LegacyAmino) {
 	cdc.RegisterConcrete(&MsgClaimArkeo{}, "claim/ClaimArkeo", nil)
 	cdc.RegisterConcrete(&MsgTransferClaim{}, "claim/TransferClaim", nil)
 	cdc.RegisterConcrete(&MsgAddClaim{}, "claim/AddClaim", nil)
+	cdc.RegisterConcrete(&MsgLockClaim{}, "claim/LockClaim", nil)
+	cdc.RegisterConcrete(&MsgUnlockClaim{}, "claim/UnlockClaim", nil)
+	cdc.RegisterConcrete(&MsgClaimWithProof{}, "claim/ClaimWithProof", nil)
+	cdc.RegisterConcrete(&MsgAddMultipleClaims{}, "claim/AddMultipleClaims", nil)
 	// this line is used by starport scaffolding # 2
 }
 
@@ -28,6 +32,18 @@ func RegisterInterfaces(registry cdctypes.InterfaceRegistry) {
 	registry.RegisterImplementations((*sdk.Msg)(nil),
 		&MsgAddClaim{},
 	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgLockClaim{},
+	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgUnlockClaim{},
+	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgClaimWithProof{},
+	)
+	registry.RegisterImplementations((*sdk.Msg)(nil),
+		&MsgAddMultipleClaims{},
+	)
 	// this line is used by starport scaffolding # 3
 
 	msgservice.RegisterMsgServiceDesc(registry, &_Msg_serviceDesc)
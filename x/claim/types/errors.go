@@ -9,4 +9,13 @@ var (
 	ErrNoClaimableAmount           = errors.Register(ModuleName, 2, "No Claimable Arkeo")
 	ErrInvalidSignature            = errors.Register(ModuleName, 3, "Invalid signature")
 	ErrClaimRecordNotTransferrable = errors.Register(ModuleName, 4, "Claim record can not be transferred")
+	ErrClaimLocked                 = errors.Register(ModuleName, 5, "Claim record is locked")
+	ErrInvalidAuthority            = errors.Register(ModuleName, 6, "Invalid authority")
+	ErrInvalidValidator            = errors.Register(ModuleName, 7, "Invalid validator")
+	ErrInvalidDelegateShare        = errors.Register(ModuleName, 8, "Invalid delegate share")
+	ErrMerkleRootNotSet            = errors.Register(ModuleName, 9, "Merkle root not set")
+	ErrInvalidMerkleProof          = errors.Register(ModuleName, 10, "Invalid merkle proof")
+	ErrAlreadyClaimed              = errors.Register(ModuleName, 11, "Already claimed")
+	ErrCannotVest                  = errors.Register(ModuleName, 12, "Claim can not vest in account")
+	ErrClaimRecordExists           = errors.Register(ModuleName, 13, "Claim record already exists")
 )
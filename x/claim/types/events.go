@@ -1,6 +1,21 @@
 package types
 
 const (
-	EventTypeClaim        = "claim"
-	EventTypeClaimFromEth = "claim_from_eth"
+	EventTypeClaim          = "claim"
+	EventTypeClaimFromEth   = "claim_from_eth"
+	EventTypeClaimLocked    = "claim_locked"
+	EventTypeClaimUnlocked  = "claim_unlocked"
+	EventTypeClaimWithProof = "claim_with_proof"
+	EventTypeClaimExpired   = "claim_expired"
+	EventTypeClaimsSwept    = "claims_swept"
+
+	AttributeKeyAuthority = "authority"
+	AttributeKeyChain     = "chain"
+	AttributeKeyAddress   = "address"
+
+	AttributeKeyLiquidAmount    = "liquid_amount"
+	AttributeKeyDelegatedAmount = "delegated_amount"
+	AttributeKeyValidator       = "validator"
+	AttributeKeyVestingEndTime  = "vesting_end_time"
+	AttributeKeyRecipient       = "recipient"
 )
@@ -2,6 +2,7 @@ package types_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/arkeonetwork/arkeo/x/claim/types"
 	"github.com/stretchr/testify/require"
@@ -26,6 +27,27 @@ func TestGenesisState_Validate(t *testing.T) {
 			},
 			valid: true,
 		},
+		{
+			desc: "negative vesting duration",
+			genState: &types.GenesisState{
+				Params: types.Params{VestingDuration: -time.Hour},
+			},
+			valid: false,
+		},
+		{
+			desc: "negative claim deadline",
+			genState: &types.GenesisState{
+				Params: types.Params{ClaimDeadline: -time.Hour},
+			},
+			valid: false,
+		},
+		{
+			desc: "invalid sweep address",
+			genState: &types.GenesisState{
+				Params: types.Params{SweepAddress: "bogus"},
+			},
+			valid: false,
+		},
 		// this line is used by starport scaffolding # types/genesis/testcase
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
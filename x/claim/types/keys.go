@@ -13,11 +13,23 @@ const (
 	// MemStoreKey defines the in-memory store key
 	MemStoreKey = "mem_claim"
 
+	// ClaimRecordsStorePrefix is the common prefix of the claim records of
+	// every chain
+	ClaimRecordsStorePrefix = "claimrecords"
+
 	// ClaimRecordsStorePrefix defines the store prefix for the claim records (by arkeo address)
 	ClaimRecordsArkeoStorePrefix = "claimrecordsarkeo"
 
 	// ClaimRecordsStorePrefix defines the store prefix for the claim records (by eth address)
 	ClaimRecordsEthStorePrefix = "claimrecordsethereum"
+
+	// MerkleClaimsStorePrefix defines the store prefix for the leaves of the
+	// merkle tree already claimed
+	MerkleClaimsStorePrefix = "merkleclaims"
+
+	// ClaimsExpiredKey is set once the unclaimed records expired and the
+	// coins left were swept
+	ClaimsExpiredKey = "claimsexpired"
 )
 
 func KeyPrefix(p string) []byte {
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// prefixes of the hashed data, so a leaf can't be passed off as a node
+var (
+	merkleLeafPrefix = []byte{0x00}
+	merkleNodePrefix = []byte{0x01}
+)
+
+// MerkleLeaf returns the hash of the leaf of a claim in the merkle tree of the
+// claims: sha256(0x00 || "<chain>:<lowercase address>:<amount>")
+func MerkleLeaf(chain Chain, address string, amount int64) []byte {
+	data := fmt.Sprintf("%s:%s:%d", chain.String(), strings.ToLower(address), amount)
+	return merkleHash(merkleLeafPrefix, []byte(data))
+}
+
+// merkleNode returns the hash of the parent of two nodes. The nodes are sorted
+// before being hashed, so a proof doesn't need to tell the side of each node.
+func merkleNode(a, b []byte) []byte {
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return merkleHash(merkleNodePrefix, a, b)
+}
+
+func merkleHash(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// MerkleRoot returns the root of the tree of the given leaves. A node without
+// sibling is moved up a level as is.
+func MerkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+	level := leaves
+	for len(level) > 1 {
+		level = merkleLevel(level)
+	}
+	return level[0]
+}
+
+// MerkleProof returns the siblings of the leaf at the given index, from the
+// leaf up to the root
+func MerkleProof(leaves [][]byte, index int) ([][]byte, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, fmt.Errorf("leaf index out of range: %d", index)
+	}
+	var proof [][]byte
+	level := leaves
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof = append(proof, level[sibling])
+		}
+		level = merkleLevel(level)
+		index /= 2
+	}
+	return proof, nil
+}
+
+func merkleLevel(nodes [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(nodes)+1)/2)
+	for i := 0; i < len(nodes); i += 2 {
+		if i+1 == len(nodes) {
+			next = append(next, nodes[i])
+			continue
+		}
+		next = append(next, merkleNode(nodes[i], nodes[i+1]))
+	}
+	return next
+}
+
+// VerifyMerkleProof returns whether the proof leads from the leaf to the root
+func VerifyMerkleProof(root, leaf []byte, proof [][]byte) bool {
+	if len(root) == 0 {
+		return false
+	}
+	node := leaf
+	for _, sibling := range proof {
+		node = merkleNode(node, sibling)
+	}
+	return bytes.Equal(node, root)
+}
+
+// DecodeMerkleHash decodes a hex encoded hash of the merkle tree, "0x"
+// prefixed or not
+func DecodeMerkleHash(raw string) ([]byte, error) {
+	raw = strings.TrimPrefix(strings.TrimPrefix(raw, "0x"), "0X")
+	hash, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) != sha256.Size {
+		return nil, fmt.Errorf("hash must be %d bytes long: %d", sha256.Size, len(hash))
+	}
+	return hash, nil
+}
@@ -0,0 +1,55 @@
+package types
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerkleProof(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 4, 7, 16} {
+		t.Run(fmt.Sprintf("%d leaves", size), func(t *testing.T) {
+			leaves := make([][]byte, 0, size)
+			for i := 0; i < size; i++ {
+				leaves = append(leaves, MerkleLeaf(ARKEO, fmt.Sprintf("address%d", i), int64(100+i)))
+			}
+			root := MerkleRoot(leaves)
+			require.Len(t, root, 32)
+
+			for i, leaf := range leaves {
+				proof, err := MerkleProof(leaves, i)
+				require.NoError(t, err)
+				require.True(t, VerifyMerkleProof(root, leaf, proof))
+
+				// another leaf, or another root, doesn't verify
+				require.False(t, VerifyMerkleProof(root, MerkleLeaf(ARKEO, fmt.Sprintf("address%d", i), 1), proof))
+				require.False(t, VerifyMerkleProof(MerkleLeaf(ETHEREUM, "root", 1), leaf, proof))
+			}
+			_, err := MerkleProof(leaves, size)
+			require.Error(t, err)
+		})
+	}
+
+	// addresses are case insensitive
+	require.Equal(t, MerkleLeaf(ETHEREUM, "0xDAFEA492D9c6733ae3d56b7Ed1ADB60692c98Bc5", 1), MerkleLeaf(ETHEREUM, "0xdafea492d9c6733ae3d56b7ed1adb60692c98bc5", 1))
+	require.False(t, VerifyMerkleProof(nil, MerkleLeaf(ARKEO, "address", 1), nil))
+}
+
+func TestDecodeMerkleHash(t *testing.T) {
+	hash := MerkleLeaf(ARKEO, "address", 1)
+	encoded := hex.EncodeToString(hash)
+
+	decoded, err := DecodeMerkleHash(encoded)
+	require.NoError(t, err)
+	require.Equal(t, hash, decoded)
+	decoded, err = DecodeMerkleHash("0x" + encoded)
+	require.NoError(t, err)
+	require.Equal(t, hash, decoded)
+
+	_, err = DecodeMerkleHash("zz")
+	require.Error(t, err)
+	_, err = DecodeMerkleHash(encoded[:10])
+	require.Error(t, err)
+}
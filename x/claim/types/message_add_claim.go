@@ -1,6 +1,8 @@
 package types
 
 import (
+	"time"
+
 	"cosmossdk.io/errors"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -11,12 +13,13 @@ const TypeMsgAddClaim = "add_claim"
 
 var _ sdk.Msg = &MsgAddClaim{}
 
-func NewMsgAddClaim(creator cosmos.AccAddress, chain Chain, address string, amount int64) *MsgAddClaim {
+func NewMsgAddClaim(creator cosmos.AccAddress, chain Chain, address string, amount int64, vestingDuration time.Duration) *MsgAddClaim {
 	return &MsgAddClaim{
-		Creator: creator,
-		Chain:   chain,
-		Address: address,
-		Amount:  amount,
+		Creator:         creator,
+		Chain:           chain,
+		Address:         address,
+		Amount:          amount,
+		VestingDuration: vestingDuration,
 	}
 }
 
@@ -48,5 +51,8 @@ func (msg *MsgAddClaim) ValidateBasic() error {
 	if msg.Amount <= 0 {
 		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "amount should larger than 0")
 	}
+	if msg.VestingDuration < 0 {
+		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "vesting duration cannot be negative")
+	}
 	return nil
 }
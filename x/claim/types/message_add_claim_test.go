@@ -2,6 +2,7 @@ package types
 
 import (
 	"testing"
+	"time"
 
 	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 	"github.com/stretchr/testify/require"
@@ -35,6 +36,17 @@ func TestMsgAddClaim_ValidateBasic(t *testing.T) {
 			},
 			err: sdkerrors.ErrInvalidRequest,
 		},
+		{
+			name: "invalid vesting duration",
+			msg: MsgAddClaim{
+				Creator:         sample.AccAddress(),
+				Address:         sample.AccAddress().String(),
+				Chain:           ARKEO,
+				Amount:          100,
+				VestingDuration: -time.Hour,
+			},
+			err: sdkerrors.ErrInvalidRequest,
+		},
 		{
 			name: "valid address",
 			msg: MsgAddClaim{
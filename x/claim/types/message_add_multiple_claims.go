@@ -0,0 +1,81 @@
+package types
+
+import (
+	"strings"
+
+	"cosmossdk.io/errors"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+const TypeMsgAddMultipleClaims = "add_multiple_claims"
+
+// MaxClaimsPerMsg is the most claims a MsgAddMultipleClaims can add
+const MaxClaimsPerMsg = 1000
+
+var _ sdk.Msg = &MsgAddMultipleClaims{}
+
+func NewMsgAddMultipleClaims(creator cosmos.AccAddress, claims []AddClaimEntry, merge bool) *MsgAddMultipleClaims {
+	return &MsgAddMultipleClaims{
+		Creator: creator,
+		Claims:  claims,
+		Merge:   merge,
+	}
+}
+
+func (msg *MsgAddMultipleClaims) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgAddMultipleClaims) Type() string {
+	return TypeMsgAddMultipleClaims
+}
+
+func (msg *MsgAddMultipleClaims) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Creator}
+}
+
+func (msg *MsgAddMultipleClaims) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgAddMultipleClaims) ValidateBasic() error {
+	if len(msg.Claims) == 0 {
+		return errors.Wrap(sdkerrors.ErrInvalidRequest, "no claims")
+	}
+	if len(msg.Claims) > MaxClaimsPerMsg {
+		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "too many claims, %d > %d", len(msg.Claims), MaxClaimsPerMsg)
+	}
+	seen := make(map[string]bool, len(msg.Claims))
+	for i, claim := range msg.Claims {
+		if err := claim.Validate(); err != nil {
+			return errors.Wrapf(err, "claim %d", i)
+		}
+		key := claim.Chain.String() + "/" + strings.ToLower(claim.Address)
+		if seen[key] {
+			return errors.Wrapf(sdkerrors.ErrInvalidRequest, "duplicate claim for %s address %s", claim.Chain, claim.Address)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Validate checks the claim entry the same way as a MsgAddClaim
+func (e AddClaimEntry) Validate() error {
+	_, ok := Chain_value[e.Chain.String()]
+	if !ok {
+		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "invalid chain(%s)", e.Chain)
+	}
+	if !IsValidAddress(e.Address, e.Chain) {
+		return errors.Wrap(sdkerrors.ErrInvalidAddress, "invalid address")
+	}
+	if e.Amount <= 0 {
+		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "amount should larger than 0")
+	}
+	if e.VestingDuration < 0 {
+		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "vesting duration cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/testutil/sample"
+)
+
+func TestMsgAddMultipleClaims_ValidateBasic(t *testing.T) {
+	address := sample.AccAddress().String()
+	tooMany := make([]AddClaimEntry, MaxClaimsPerMsg+1)
+	for i := range tooMany {
+		tooMany[i] = AddClaimEntry{Chain: ARKEO, Address: sample.AccAddress().String(), Amount: 100}
+	}
+
+	tests := []struct {
+		name string
+		msg  MsgAddMultipleClaims
+		err  error
+	}{
+		{
+			name: "no claims",
+			msg: MsgAddMultipleClaims{
+				Creator: sample.AccAddress(),
+			},
+			err: sdkerrors.ErrInvalidRequest,
+		},
+		{
+			name: "too many claims",
+			msg: MsgAddMultipleClaims{
+				Creator: sample.AccAddress(),
+				Claims:  tooMany,
+			},
+			err: sdkerrors.ErrInvalidRequest,
+		},
+		{
+			name: "invalid claim",
+			msg: MsgAddMultipleClaims{
+				Creator: sample.AccAddress(),
+				Claims: []AddClaimEntry{
+					{Chain: ARKEO, Address: address, Amount: 100},
+					{Chain: ETHEREUM, Address: address, Amount: 100},
+				},
+			},
+			err: sdkerrors.ErrInvalidAddress,
+		},
+		{
+			name: "duplicate address",
+			msg: MsgAddMultipleClaims{
+				Creator: sample.AccAddress(),
+				Claims: []AddClaimEntry{
+					{Chain: ARKEO, Address: address, Amount: 100},
+					{Chain: ARKEO, Address: strings.ToUpper(address), Amount: 200},
+				},
+			},
+			err: sdkerrors.ErrInvalidRequest,
+		},
+		{
+			name: "valid claims",
+			msg: MsgAddMultipleClaims{
+				Creator: sample.AccAddress(),
+				Claims: []AddClaimEntry{
+					{Chain: ARKEO, Address: address, Amount: 100},
+					{Chain: ARKEO, Address: sample.AccAddress().String(), Amount: 100},
+					{Chain: ETHEREUM, Address: "0xdafea492d9c6733ae3d56b7ed1adb60692c98bc5", Amount: 100},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.ValidateBasic()
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"cosmossdk.io/errors"
 	"github.com/arkeonetwork/arkeo/common/cosmos"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
@@ -33,5 +34,13 @@ func (msg *MsgClaimArkeo) GetSignBytes() []byte {
 }
 
 func (msg *MsgClaimArkeo) ValidateBasic() error {
+	if msg.DelegateBasisPoints > MaxBasisPoints {
+		return errors.Wrapf(ErrInvalidDelegateShare, "cannot delegate more than %d basis points: %d", MaxBasisPoints, msg.DelegateBasisPoints)
+	}
+	if msg.DelegateBasisPoints > 0 || len(msg.Validator) > 0 {
+		if _, err := sdk.ValAddressFromBech32(msg.Validator); err != nil {
+			return errors.Wrapf(ErrInvalidValidator, "invalid validator address (%s): %s", msg.Validator, err)
+		}
+	}
 	return nil
 }
@@ -2,7 +2,77 @@ package types
 
 import (
 	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/testutil/sample"
 )
 
 func TestMsgClaimArkeo_ValidateBasic(t *testing.T) {
+	validator := sdk.ValAddress(sample.AccAddress()).String()
+	tests := []struct {
+		name string
+		msg  MsgClaimArkeo
+		err  error
+	}{
+		{
+			name: "liquid claim",
+			msg: MsgClaimArkeo{
+				Creator: sample.AccAddress(),
+			},
+		},
+		{
+			name: "delegated claim",
+			msg: MsgClaimArkeo{
+				Creator:             sample.AccAddress(),
+				Validator:           validator,
+				DelegateBasisPoints: 2500,
+			},
+		},
+		{
+			name: "fully delegated claim",
+			msg: MsgClaimArkeo{
+				Creator:             sample.AccAddress(),
+				Validator:           validator,
+				DelegateBasisPoints: MaxBasisPoints,
+			},
+		},
+		{
+			name: "too many basis points",
+			msg: MsgClaimArkeo{
+				Creator:             sample.AccAddress(),
+				Validator:           validator,
+				DelegateBasisPoints: MaxBasisPoints + 1,
+			},
+			err: ErrInvalidDelegateShare,
+		},
+		{
+			name: "missing validator",
+			msg: MsgClaimArkeo{
+				Creator:             sample.AccAddress(),
+				DelegateBasisPoints: 2500,
+			},
+			err: ErrInvalidValidator,
+		},
+		{
+			name: "invalid validator",
+			msg: MsgClaimArkeo{
+				Creator:             sample.AccAddress(),
+				Validator:           sample.AccAddress().String(),
+				DelegateBasisPoints: 2500,
+			},
+			err: ErrInvalidValidator,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.ValidateBasic()
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
 }
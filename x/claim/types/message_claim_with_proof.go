@@ -0,0 +1,72 @@
+package types
+
+import (
+	"cosmossdk.io/errors"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+const TypeMsgClaimWithProof = "claim_with_proof"
+
+var _ sdk.Msg = &MsgClaimWithProof{}
+
+func NewMsgClaimWithProof(creator cosmos.AccAddress, chain Chain, address string, amount int64, proof []string) *MsgClaimWithProof {
+	return &MsgClaimWithProof{
+		Creator: creator,
+		Chain:   chain,
+		Address: address,
+		Amount:  amount,
+		Proof:   proof,
+	}
+}
+
+func (msg *MsgClaimWithProof) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgClaimWithProof) Type() string {
+	return TypeMsgClaimWithProof
+}
+
+func (msg *MsgClaimWithProof) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Creator}
+}
+
+func (msg *MsgClaimWithProof) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgClaimWithProof) ValidateBasic() error {
+	if msg.Creator.Empty() {
+		return errors.Wrap(sdkerrors.ErrInvalidAddress, "creator cannot be empty")
+	}
+	_, ok := Chain_value[msg.Chain.String()]
+	if !ok {
+		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "invalid chain(%s)", msg.Chain)
+	}
+	if !IsValidAddress(msg.Address, msg.Chain) {
+		return errors.Wrap(sdkerrors.ErrInvalidAddress, "invalid address")
+	}
+	if msg.Amount <= 0 {
+		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "amount should larger than 0")
+	}
+	if _, err := msg.ProofHashes(); err != nil {
+		return errors.Wrapf(ErrInvalidMerkleProof, "%s", err)
+	}
+	return nil
+}
+
+// ProofHashes returns the decoded hashes of the proof
+func (msg *MsgClaimWithProof) ProofHashes() ([][]byte, error) {
+	proof := make([][]byte, 0, len(msg.Proof))
+	for _, raw := range msg.Proof {
+		hash, err := DecodeMerkleHash(raw)
+		if err != nil {
+			return nil, err
+		}
+		proof = append(proof, hash)
+	}
+	return proof, nil
+}
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"encoding/hex"
+	"testing"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/testutil/sample"
+)
+
+func TestMsgClaimWithProof_ValidateBasic(t *testing.T) {
+	proof := []string{hex.EncodeToString(MerkleLeaf(ARKEO, "address", 1))}
+	tests := []struct {
+		name string
+		msg  MsgClaimWithProof
+		err  error
+	}{
+		{
+			name: "empty creator",
+			msg: MsgClaimWithProof{
+				Chain:   ARKEO,
+				Address: sample.AccAddress().String(),
+				Amount:  100,
+				Proof:   proof,
+			},
+			err: sdkerrors.ErrInvalidAddress,
+		},
+		{
+			name: "invalid address",
+			msg: MsgClaimWithProof{
+				Creator: sample.AccAddress(),
+				Chain:   ARKEO,
+				Address: "invalid_address",
+				Amount:  100,
+				Proof:   proof,
+			},
+			err: sdkerrors.ErrInvalidAddress,
+		},
+		{
+			name: "invalid amount",
+			msg: MsgClaimWithProof{
+				Creator: sample.AccAddress(),
+				Chain:   ARKEO,
+				Address: sample.AccAddress().String(),
+				Proof:   proof,
+			},
+			err: sdkerrors.ErrInvalidRequest,
+		},
+		{
+			name: "invalid proof",
+			msg: MsgClaimWithProof{
+				Creator: sample.AccAddress(),
+				Chain:   ARKEO,
+				Address: sample.AccAddress().String(),
+				Amount:  100,
+				Proof:   []string{"not a hash"},
+			},
+			err: ErrInvalidMerkleProof,
+		},
+		{
+			name: "valid",
+			msg: MsgClaimWithProof{
+				Creator: sample.AccAddress(),
+				Chain:   ETHEREUM,
+				Address: "0xDAFEA492D9c6733ae3d56b7Ed1ADB60692c98Bc5",
+				Amount:  100,
+				Proof:   proof,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.ValidateBasic()
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
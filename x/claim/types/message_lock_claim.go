@@ -0,0 +1,51 @@
+package types
+
+import (
+	"cosmossdk.io/errors"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+const TypeMsgLockClaim = "lock_claim"
+
+var _ sdk.Msg = &MsgLockClaim{}
+
+func NewMsgLockClaim(authority cosmos.AccAddress, chain Chain, address string) *MsgLockClaim {
+	return &MsgLockClaim{
+		Authority: authority,
+		Chain:     chain,
+		Address:   address,
+	}
+}
+
+func (msg *MsgLockClaim) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgLockClaim) Type() string {
+	return TypeMsgLockClaim
+}
+
+func (msg *MsgLockClaim) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Authority}
+}
+
+func (msg *MsgLockClaim) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgLockClaim) ValidateBasic() error {
+	if msg.Authority.Empty() {
+		return errors.Wrap(sdkerrors.ErrInvalidAddress, "authority cannot be empty")
+	}
+	_, ok := Chain_value[msg.Chain.String()]
+	if !ok {
+		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "invalid chain(%s)", msg.Chain)
+	}
+	if !IsValidAddress(msg.Address, msg.Chain) {
+		return errors.Wrap(sdkerrors.ErrInvalidAddress, "invalid address")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package types
+
+import (
+	"testing"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/arkeonetwork/arkeo/testutil/sample"
+)
+
+func TestMsgLockClaim_ValidateBasic(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  MsgLockClaim
+		err  error
+	}{
+		{
+			name: "empty authority",
+			msg: MsgLockClaim{
+				Address: sample.AccAddress().String(),
+				Chain:   ARKEO,
+			},
+			err: sdkerrors.ErrInvalidAddress,
+		},
+		{
+			name: "invalid chain",
+			msg: MsgLockClaim{
+				Authority: sample.AccAddress(),
+				Address:   sample.AccAddress().String(),
+				Chain:     Chain(100),
+			},
+			err: sdkerrors.ErrInvalidRequest,
+		},
+		{
+			name: "invalid address",
+			msg: MsgLockClaim{
+				Authority: sample.AccAddress(),
+				Address:   "invalid_address",
+				Chain:     ARKEO,
+			},
+			err: sdkerrors.ErrInvalidAddress,
+		},
+		{
+			name: "valid",
+			msg: MsgLockClaim{
+				Authority: sample.AccAddress(),
+				Address:   sample.AccAddress().String(),
+				Chain:     ARKEO,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.ValidateBasic()
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"cosmossdk.io/errors"
+	"github.com/arkeonetwork/arkeo/common/cosmos"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+const TypeMsgUnlockClaim = "unlock_claim"
+
+var _ sdk.Msg = &MsgUnlockClaim{}
+
+func NewMsgUnlockClaim(authority cosmos.AccAddress, chain Chain, address string) *MsgUnlockClaim {
+	return &MsgUnlockClaim{
+		Authority: authority,
+		Chain:     chain,
+		Address:   address,
+	}
+}
+
+func (msg *MsgUnlockClaim) Route() string {
+	return RouterKey
+}
+
+func (msg *MsgUnlockClaim) Type() string {
+	return TypeMsgUnlockClaim
+}
+
+func (msg *MsgUnlockClaim) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Authority}
+}
+
+func (msg *MsgUnlockClaim) GetSignBytes() []byte {
+	bz := ModuleCdc.MustMarshalJSON(msg)
+	return sdk.MustSortJSON(bz)
+}
+
+func (msg *MsgUnlockClaim) ValidateBasic() error {
+	if msg.Authority.Empty() {
+		return errors.Wrap(sdkerrors.ErrInvalidAddress, "authority cannot be empty")
+	}
+	_, ok := Chain_value[msg.Chain.String()]
+	if !ok {
+		return errors.Wrapf(sdkerrors.ErrInvalidRequest, "invalid chain(%s)", msg.Chain)
+	}
+	if !IsValidAddress(msg.Address, msg.Chain) {
+		return errors.Wrap(sdkerrors.ErrInvalidAddress, "invalid address")
+	}
+	return nil
+}
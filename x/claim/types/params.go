@@ -4,6 +4,7 @@ import (
 	fmt "fmt"
 	time "time"
 
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	paramtypes "github.com/cosmos/cosmos-sdk/x/params/types"
 )
 
@@ -27,6 +28,39 @@ var (
 	DeafultAirdropStartTime time.Time = time.Now().UTC()
 )
 
+var (
+	KeyComplianceAuthority            = []byte("ComplianceAuthority")
+	DefaultComplianceAuthority string = ""
+)
+
+// MaxBasisPoints is the whole of a claim, in basis points
+const MaxBasisPoints uint64 = 10_000
+
+var (
+	KeyMaxDelegateBasisPoints            = []byte("MaxDelegateBasisPoints")
+	DefaultMaxDelegateBasisPoints uint64 = MaxBasisPoints
+)
+
+var (
+	KeyMerkleRoot            = []byte("MerkleRoot")
+	DefaultMerkleRoot string = ""
+)
+
+var (
+	KeyVestingDuration                   = []byte("VestingDuration")
+	DefaultVestingDuration time.Duration = 0
+)
+
+var (
+	KeyClaimDeadline                   = []byte("ClaimDeadline")
+	DefaultClaimDeadline time.Duration = 0
+)
+
+var (
+	KeySweepAddress            = []byte("SweepAddress")
+	DefaultSweepAddress string = ""
+)
+
 var _ paramtypes.ParamSet = (*Params)(nil)
 
 // ParamKeyTable the param key table for launch module
@@ -47,10 +81,16 @@ func NewParams(claimDenom string, airdropStartTime time.Time, durationUntilDecay
 // DefaultParams returns a default set of parameters
 func DefaultParams() Params {
 	return Params{
-		ClaimDenom:         DefaultClaimDenom,
-		DurationUntilDecay: DefaultDurationUntilDecay,
-		DurationOfDecay:    DefaultDurationOfDecay,
-		AirdropStartTime:   DeafultAirdropStartTime,
+		ClaimDenom:             DefaultClaimDenom,
+		DurationUntilDecay:     DefaultDurationUntilDecay,
+		DurationOfDecay:        DefaultDurationOfDecay,
+		AirdropStartTime:       DeafultAirdropStartTime,
+		ComplianceAuthority:    DefaultComplianceAuthority,
+		MaxDelegateBasisPoints: DefaultMaxDelegateBasisPoints,
+		MerkleRoot:             DefaultMerkleRoot,
+		VestingDuration:        DefaultVestingDuration,
+		ClaimDeadline:          DefaultClaimDeadline,
+		SweepAddress:           DefaultSweepAddress,
 	}
 }
 
@@ -61,12 +101,24 @@ func (p *Params) ParamSetPairs() paramtypes.ParamSetPairs {
 		paramtypes.NewParamSetPair(KeyDurationUntilDecay, &p.DurationUntilDecay, validateDurationUntilDecay),
 		paramtypes.NewParamSetPair(KeyDurationOfDecay, &p.DurationOfDecay, validateDurationOfDecay),
 		paramtypes.NewParamSetPair(KeyClaimDenom, &p.ClaimDenom, validateClaimDenom),
+		paramtypes.NewParamSetPair(KeyComplianceAuthority, &p.ComplianceAuthority, validateComplianceAuthority),
+		paramtypes.NewParamSetPair(KeyMaxDelegateBasisPoints, &p.MaxDelegateBasisPoints, validateMaxDelegateBasisPoints),
+		paramtypes.NewParamSetPair(KeyMerkleRoot, &p.MerkleRoot, validateMerkleRoot),
+		paramtypes.NewParamSetPair(KeyVestingDuration, &p.VestingDuration, validateVestingDuration),
+		paramtypes.NewParamSetPair(KeyClaimDeadline, &p.ClaimDeadline, validateClaimDeadline),
+		paramtypes.NewParamSetPair(KeySweepAddress, &p.SweepAddress, validateSweepAddress),
 	}
 }
 
 // Validate validates the set of params
 func (p Params) Validate() error {
-	return nil
+	if err := validateVestingDuration(p.VestingDuration); err != nil {
+		return err
+	}
+	if err := validateClaimDeadline(p.ClaimDeadline); err != nil {
+		return err
+	}
+	return validateSweepAddress(p.SweepAddress)
 }
 
 func validateAirdropStartTime(i interface{}) error {
@@ -100,3 +152,78 @@ func validateClaimDenom(i interface{}) error {
 	}
 	return nil
 }
+
+func validateComplianceAuthority(i interface{}) error {
+	v, ok := i.(string)
+	if !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	if len(v) == 0 {
+		return nil
+	}
+	if _, err := sdk.AccAddressFromBech32(v); err != nil {
+		return fmt.Errorf("invalid compliance authority: %w", err)
+	}
+	return nil
+}
+
+func validateMaxDelegateBasisPoints(i interface{}) error {
+	v, ok := i.(uint64)
+	if !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	if v > MaxBasisPoints {
+		return fmt.Errorf("max delegate basis points cannot be more than %d: %d", MaxBasisPoints, v)
+	}
+	return nil
+}
+
+func validateMerkleRoot(i interface{}) error {
+	v, ok := i.(string)
+	if !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	if len(v) == 0 {
+		return nil
+	}
+	if _, err := DecodeMerkleHash(v); err != nil {
+		return fmt.Errorf("invalid merkle root: %w", err)
+	}
+	return nil
+}
+
+func validateVestingDuration(i interface{}) error {
+	v, ok := i.(time.Duration)
+	if !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	if v < 0 {
+		return fmt.Errorf("vesting duration cannot be negative: %s", v)
+	}
+	return nil
+}
+
+func validateClaimDeadline(i interface{}) error {
+	v, ok := i.(time.Duration)
+	if !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	if v < 0 {
+		return fmt.Errorf("claim deadline cannot be negative: %s", v)
+	}
+	return nil
+}
+
+func validateSweepAddress(i interface{}) error {
+	v, ok := i.(string)
+	if !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+	if len(v) == 0 {
+		return nil
+	}
+	if _, err := sdk.AccAddressFromBech32(v); err != nil {
+		return fmt.Errorf("invalid sweep address: %w", err)
+	}
+	return nil
+}